@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/events"
+	"healthctl/pkg/fleet"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/report"
+)
+
+// agentCommand runs the suites in the cluster every interval and pushes the reports to the fleet server
+func agentCommand(args []string) int {
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	server := fs.String("server", "", "URL of the fleet server, overrides agent.server of the config")
+	once := fs.Bool("once", false, "push a single report and exit")
+	shardIndex := fs.Int("shard", -1, "shard of the namespaces this agent checks when agent.shards is set, defaults to the ordinal of the StatefulSet pod")
+	fs.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *server != "" {
+		cfg.Agent.Server = *server
+	}
+	client, err := fleet.NewClient(cfg.Agent)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	interval := defaultDashboardInterval
+	if cfg.Agent.Interval != "" {
+		if interval, err = time.ParseDuration(cfg.Agent.Interval); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid agent.interval %q: %v\n", cfg.Agent.Interval, err)
+			return 2
+		}
+	}
+	kc, err := k8s.NewK8sClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		return 2
+	}
+	var shard *report.Shard
+	if cfg.Agent.Shards > 1 {
+		shard = &report.Shard{Index: *shardIndex, Count: cfg.Agent.Shards}
+		if shard.Index < 0 {
+			if shard.Index, err = podOrdinal(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 2
+			}
+		}
+	}
+
+	recorder, err := events.New(cfg.Events)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if recorder != nil && shard != nil && recorder.ConfigMapName != "" {
+		// every shard would replace the findings of the others
+		log.Printf("events.configMap is not written by sharded agents")
+		recorder.ConfigMapName = ""
+	}
+
+	opts := serviceOptions(cfg.Agent.Suites, 1, config.ClusterStatePath(k8s.CurrentCluster(), "history.json"))
+	opts.shard = shard
+	log.Printf("healthctl agent reporting to %s every %s", client.Server, interval)
+	for {
+		r, err := buildReport(kc, cfg, opts)
+		if err == nil {
+			maps.Copy(r.Labels, cfg.Agent.Labels)
+			r.Shard = shard
+			err = client.Push(r)
+			if recorder != nil {
+				if recordErr := recorder.Record(kc, r, config.StatePath("events.json")); recordErr != nil {
+					log.Printf("agent run: %v", recordErr)
+				}
+			}
+		}
+		if err != nil {
+			log.Printf("agent run failed: %v", err)
+		}
+		if *once {
+			if err != nil {
+				return 2
+			}
+			return 0
+		}
+		time.Sleep(interval)
+	}
+}
+
+// podOrdinal returns the ordinal of the StatefulSet pod the agent runs in from its hostname, e.g. 2 for
+// healthctl-agent-2
+func podOrdinal() (int, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return 0, err
+	}
+	ordinal, err := strconv.Atoi(hostname[strings.LastIndex(hostname, "-")+1:])
+	if err != nil {
+		return 0, fmt.Errorf("hostname %s is not a StatefulSet pod, set the shard of the agent with -shard", hostname)
+	}
+	return ordinal, nil
+}
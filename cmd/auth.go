@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"healthctl/pkg/k8s"
+)
+
+func authCommand(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl auth check")
+		return 2
+	}
+
+	results, err := k8s.CheckAuth()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTEXT\tCLUSTER\tAUTH\tSTATUS\tUSER")
+	for _, result := range results {
+		status := "OK"
+		user := result.User
+		if result.Refreshed {
+			status = "OK (token refreshed)"
+		}
+		if result.Error != nil {
+			status = "FAILED"
+			user = result.Error.Error()
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", result.Context, result.Cluster, result.AuthType, status, user)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		fmt.Printf("\n%d of %d contexts failed to authenticate\n", failed, len(results))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/findings"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+)
+
+func baselineCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl baseline create|clear [flags]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("baseline "+args[0], flag.ExitOnError)
+	suites := fs.String("suite", "all", "comma separated list of suites to run (k8s, infra, paas, smf, upf, storage, network, security) or all")
+	baselineFile := fs.String("file", config.StatePath("baseline.json"), "baseline file")
+	fs.Parse(args[1:])
+
+	switch args[0] {
+	case "create":
+		kc, err := k8s.NewK8sClient()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+			return 2
+		}
+		checks, result, err := collectFindings(kc, *suites)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		for _, check := range checks {
+			if check.Result == models.ResultError {
+				fmt.Fprintf(os.Stderr, "WARNING: %s could not run, its findings are not in the baseline: %s\n", check.Check, check.Cause)
+			}
+		}
+		baseline := findings.NewBaseline(result, time.Now())
+		if err := baseline.Save(*baselineFile); err != nil {
+			fmt.Fprintln(os.Stderr, "Error saving baseline:", err)
+			return 2
+		}
+		fmt.Printf("Baseline with %d accepted findings written to %s\n", len(baseline.Findings), *baselineFile)
+	case "clear":
+		if err := os.Remove(*baselineFile); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintln(os.Stderr, "Error removing baseline:", err)
+			return 2
+		}
+		fmt.Printf("Baseline %s removed\n", *baselineFile)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown baseline command %q\n", args[0])
+		return 2
+	}
+	return 0
+}
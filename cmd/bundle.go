@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"healthctl/pkg/bundle"
+	"healthctl/pkg/k8s"
+)
+
+func bundleCommand(args []string) int {
+	if len(args) == 0 || args[0] != "create" {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl bundle create [flags]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("bundle create", flag.ExitOnError)
+	opts := addCheckFlags(fs, "")
+	file := fs.String("f", "", "bundle file to write, defaults to healthctl-<cluster>-<time>.tar.gz")
+	eventsSince := fs.Duration("events-since", time.Hour, "include events observed within this duration")
+	logLines := fs.Int64("log-lines", 500, "number of log lines collected from every container of a failing pod")
+	fs.Parse(args[1:])
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	kc, err := k8s.NewK8sClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		return 2
+	}
+
+	r, err := buildReport(kc, cfg, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *file == "" {
+		*file = bundle.Name(r.Cluster, r.Generated) + ".tar.gz"
+	}
+
+	if err := bundle.Create(*file, kc, r, bundle.Options{EventsSince: *eventsSince, LogLines: *logLines}); err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating bundle:", err)
+		return 2
+	}
+	fmt.Printf("Support bundle with %d findings written to %s\n", len(r.Findings), *file)
+	return 0
+}
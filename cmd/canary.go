@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"healthctl/pkg/canary"
+	"healthctl/pkg/k8s"
+)
+
+func canaryCommand(args []string) int {
+	fs := flag.NewFlagSet("canary", flag.ExitOnError)
+	namespace := fs.String("namespace", "default", "namespace of the deployments")
+	baseline := fs.String("baseline", "", "deployment running the current version")
+	canaryDeployment := fs.String("canary", "", "deployment running the new version")
+	window := fs.Duration("window", 10*time.Minute, "how long both deployments are observed")
+	interval := fs.Duration("interval", 30*time.Second, "how often restarts and resource usage are sampled")
+	output := fs.String("o", "text", "output format, text or json")
+	fs.Parse(args)
+	if *baseline == "" || *canaryDeployment == "" {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl canary -baseline <deployment> -canary <deployment> [flags]")
+		fs.PrintDefaults()
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	kc, err := k8s.NewK8sClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		return 2
+	}
+
+	if *output == "text" {
+		fmt.Printf("Comparing %s with %s in %s for %s\n", *canaryDeployment, *baseline, *namespace, *window)
+	}
+	analysis, err := canary.Analyze(kc, cfg.Canary, *namespace, *baseline, *canaryDeployment, *window, *interval)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error analyzing canary:", err)
+		return 2
+	}
+
+	if *output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(analysis); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing analysis:", err)
+			return 2
+		}
+	} else {
+		fmt.Printf("%-20s %14s %14s\n", "SIGNAL", "BASELINE", "CANARY")
+		for _, comparison := range analysis.Comparisons {
+			status := ""
+			if comparison.Failed {
+				status = "  FAILED: " + comparison.Reason
+			}
+			fmt.Printf("%-20s %14.2f %14.2f%s\n", comparison.Signal, comparison.Baseline, comparison.Canary, status)
+		}
+		fmt.Printf("Recommendation: %s\n", analysis.Recommendation)
+	}
+	if analysis.Recommendation == canary.Abort {
+		return 1
+	}
+	return 0
+}
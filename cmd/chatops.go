@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"healthctl/pkg/findings"
+	"healthctl/pkg/models"
+	"healthctl/pkg/notify"
+	"healthctl/pkg/testsuite"
+)
+
+const slashUsage = "Usage:\n" +
+	"• `/healthctl check <suite|check>` run a suite, or every check whose name contains the text\n" +
+	"• `/healthctl report [cluster]` run all suites on the cluster, default the cluster healthctl serve runs against\n" +
+	"• `/healthctl help` show this help"
+
+// handleSlashCommand answers the /healthctl slack slash command. Slack expects an answer within 3 seconds, so
+// the user gets an acknowledgement and the result is posted to the response URL of the command when done.
+func (s *server) handleSlashCommand(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	chatops := s.cfg.Serve.Slack
+	if err := notify.VerifySlackRequest(chatops.SigningSecret, r.Header.Get("X-Slack-Request-Timestamp"), r.Header.Get("X-Slack-Signature"), body); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	command, err := notify.ParseSlashCommand(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	args := strings.Fields(command.Text)
+	if len(args) == 0 {
+		args = []string{"help"}
+	}
+	log.Printf("slack user %s (%s) in %s: %s %s", command.UserName, command.UserID, command.ChannelID, command.Command, command.Text)
+	w.Header().Set("Content-Type", "application/json")
+	if !chatops.Allowed(command.UserID, args[0]) {
+		w.Write(notify.SlashResponse(false, fmt.Sprintf("You are not allowed to run `%s`.", args[0])))
+		return
+	}
+	switch {
+	case args[0] == "check" && len(args) == 2:
+		w.Write(notify.SlashResponse(false, fmt.Sprintf("Running `%s`, the result is posted to the channel.", command.Text)))
+		go s.respond(command, func() (string, error) { return s.slackCheck(args[1]) })
+	case args[0] == "report" && len(args) <= 2:
+		cluster := ""
+		if len(args) == 2 {
+			cluster = args[1]
+		}
+		w.Write(notify.SlashResponse(false, fmt.Sprintf("Running `%s`, the result is posted to the channel.", command.Text)))
+		go s.respond(command, func() (string, error) { return s.slackReport(cluster) })
+	default:
+		w.Write(notify.SlashResponse(false, slashUsage))
+	}
+}
+
+// respond runs a slash command and posts its result, or the error, in the channel it was typed in
+func (s *server) respond(command notify.SlashCommand, run func() (string, error)) {
+	s.mutex.Lock()
+	text, err := run()
+	s.mutex.Unlock()
+	if err != nil {
+		text = fmt.Sprintf("`%s` failed: %v", command.Text, err)
+	}
+	text = fmt.Sprintf("<@%s> ran `%s %s`\n%s", command.UserID, command.Command, command.Text, text)
+	if err := notify.Respond(command.ResponseURL, true, text); err != nil {
+		log.Printf("responding to slack command %q: %v", command.Text, err)
+	}
+}
+
+// slackCheck runs a suite, or all suites keeping the checks whose name contains the target
+func (s *server) slackCheck(target string) (string, error) {
+	suites := target
+	if _, found := testsuite.GetSuite(target); !found {
+		suites = "all"
+	}
+	checks, result, err := collectFindings(s.kc, suites)
+	if err != nil {
+		return "", err
+	}
+	if suites == "all" {
+		checks, result = matchingChecks(target, checks, result)
+		if len(checks) == 0 {
+			return fmt.Sprintf("No suite or check matches %q.", target), nil
+		}
+	}
+	result = findings.AssignTeams(result, s.cfg, s.kc.GetNamespaceLabels())
+	result = findings.Annotate(result, s.cfg.KnowledgeBase)
+	return notify.ResultText(fmt.Sprintf("%s on cluster %s", target, s.cluster), checks, result), nil
+}
+
+// matchingChecks keeps the check results and findings of checks whose name contains the target, ignoring case
+func matchingChecks(target string, checks []models.CheckResult, result []models.Finding) ([]models.CheckResult, []models.Finding) {
+	target = strings.ToLower(target)
+	matched := []models.CheckResult{}
+	for _, check := range checks {
+		if strings.Contains(strings.ToLower(check.Check), target) {
+			matched = append(matched, check)
+		}
+	}
+	matchedFindings := []models.Finding{}
+	for _, finding := range result {
+		if strings.Contains(strings.ToLower(finding.Check), target) {
+			matchedFindings = append(matchedFindings, finding)
+		}
+	}
+	return matched, matchedFindings
+}
+
+// slackReport runs all suites on a cluster of the kubeconfig, an empty cluster is the cluster healthctl serve runs against
+func (s *server) slackReport(cluster string) (string, error) {
+	if cluster == "" {
+		cluster = s.cluster
+	}
+	kc, err := s.clientFor(cluster)
+	if err != nil {
+		return "", err
+	}
+	checks, result, err := collectFindings(kc, "all")
+	if err != nil {
+		return "", err
+	}
+	result = findings.AssignTeams(result, s.cfg, kc.GetNamespaceLabels())
+	result = findings.Annotate(result, s.cfg.KnowledgeBase)
+	return notify.ResultText(fmt.Sprintf("report of cluster %s", cluster), checks, result), nil
+}
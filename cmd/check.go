@@ -0,0 +1,236 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/findings"
+	"healthctl/pkg/healthcheck"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/maintenance"
+	"healthctl/pkg/models"
+	"healthctl/pkg/notify"
+	"healthctl/pkg/output"
+	"healthctl/pkg/report"
+	"healthctl/pkg/tsdb"
+)
+
+// checkOptions are the flags of every command that runs the test suites
+type checkOptions struct {
+	suites          *string
+	suppressionFile *string
+	baselineFile    *string
+	team            *string
+	evidence        *bool
+	shards          *int
+	noCache         *bool
+	retryFailed     *bool
+	resume          *bool
+	historyFile     string
+	// journalFile records the checks of the run as they finish, the long running modes keep no journal
+	journalFile string
+	// shard is the shard of the namespaces an agent of a sharded fleet checks
+	shard *report.Shard
+	// checks are run in addition to the selected suites
+	checks []healthcheck.Check
+	// progress gets the results of every check as soon as it finished
+	progress func(checks []models.CheckResult, result []models.Finding)
+}
+
+func addCheckFlags(fs *flag.FlagSet, baselineDefault string) *checkOptions {
+	return &checkOptions{
+		suites:          fs.String("suite", "all", "comma separated list of suites to run (k8s, infra, paas, smf, upf, storage, network, security) or all"),
+		suppressionFile: fs.String("suppressions", config.StatePath("suppressions.yaml"), "file with accepted findings that must not fail the suite"),
+		baselineFile:    fs.String("baseline", baselineDefault, "baseline file, only findings not in the baseline are reported. Empty to report all"),
+		team:            fs.String("team", "", "only report the findings owned by this team"),
+		evidence:        fs.Bool("evidence", false, "collect the yaml of failing objects, their owners, nodes and events into the report"),
+		shards:          fs.Int("shards", 1, "split the namespaces into this many shards checked in parallel, for clusters with tens of thousands of pods"),
+		noCache:         fs.Bool("no-cache", false, "run every check instead of reusing the cached results of the last runs"),
+		retryFailed:     fs.Bool("retry-failed", false, "only run the checks that failed or could not run in the last run again, reuse the others"),
+		resume:          fs.Bool("resume", false, "resume the last run that was interrupted, from the last check it completed"),
+		historyFile:     config.ClusterStatePath(k8s.CurrentCluster(), "history.json"),
+		journalFile:     config.StatePath("journal.json"),
+	}
+}
+
+// serviceOptions are the options of the long running modes, which report every finding of the suites
+// fresh on every run and track the finding history in their own file
+func serviceOptions(suites []string, shards int, historyFile string) *checkOptions {
+	selected := "all"
+	if len(suites) > 0 {
+		selected = strings.Join(suites, ",")
+	}
+	suppressionFile, none, disabled, noCache := config.StatePath("suppressions.yaml"), "", false, true
+	return &checkOptions{
+		suites:          &selected,
+		suppressionFile: &suppressionFile,
+		baselineFile:    &none,
+		team:            &none,
+		evidence:        &disabled,
+		shards:          &shards,
+		noCache:         &noCache,
+		retryFailed:     &disabled,
+		resume:          &disabled,
+		historyFile:     historyFile,
+	}
+}
+
+func checkCommand(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	opts := addCheckFlags(fs, config.StatePath("baseline.json"))
+	format := fs.String("o", "text", fmt.Sprintf("output format, one of %v", report.Formats()))
+	notifyTeams := fs.Bool("notify", false, "send every team its failing findings through the configured notifier")
+	fs.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	writer, err := report.GetWriter(*format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	outputs, err := output.FromConfig(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	exporters := tsdb.FromConfig(cfg)
+	calendar, err := maintenance.New(cfg.Maintenance)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	kc, err := k8s.NewK8sClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		return 2
+	}
+
+	var stream *report.Stream
+	if *format == "ndjson" {
+		// the check results and findings are written while the suites run
+		stream = report.NewStream(os.Stdout)
+		opts.progress = func(checks []models.CheckResult, result []models.Finding) {
+			if err := stream.Checks(checks, result); err != nil {
+				fmt.Fprintln(os.Stderr, "Error writing report:", err)
+			}
+		}
+	}
+	r, err := buildReport(kc, cfg, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if stream != nil {
+		err = stream.Report(r)
+	} else {
+		err = writer.Write(os.Stdout, r)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing report:", err)
+		return 2
+	}
+	if err := output.Upload(outputs, r); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if len(exporters) > 0 {
+		var usage *k8s.ResourceUsageReport
+		if resourceUsage, err := kc.GetResourceUsageReport(); err != nil {
+			fmt.Fprintln(os.Stderr, "WARNING: exporting metrics without usage:", err)
+		} else {
+			usage = &resourceUsage
+		}
+		if err := tsdb.Push(exporters, r, usage); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	if *notifyTeams {
+		if cfg.Notifier.Slack == nil {
+			fmt.Fprintln(os.Stderr, "No slack notifier configured in", *configFile)
+		} else if err := notify.NotifyTeams(cfg.Notifier.Slack, r.Cluster, calendar.Unmuted(r.Cluster, r.Findings, r.Generated)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	if r.Failing() {
+		return 1
+	}
+	if r.Incomplete() {
+		return 3
+	}
+	return 0
+}
+
+// buildReport runs the suites and applies the finding history, suppressions, team ownership and baseline
+func buildReport(kc *k8s.K8sClient, cfg *config.Config, opts *checkOptions) (report.Report, error) {
+	checks, err := selectSuites(*opts.suites)
+	if err != nil {
+		return report.Report{}, err
+	}
+	checks = append(checks, opts.checks...)
+	runner := healthcheck.NewRunner(kc, cfg, checks...)
+	runner.HistoryFile = opts.historyFile
+	runner.Team = *opts.team
+	runner.Evidence = *opts.evidence
+	runner.Shards = *opts.shards
+	runner.Shard = opts.shard
+	runner.Progress = opts.progress
+	if !*opts.noCache {
+		if runner.Cache, err = healthcheck.NewCache(config.StatePath("cache.json"), cfg.Cache); err != nil {
+			return report.Report{}, err
+		}
+	}
+	if opts.journalFile != "" {
+		runner.Journal = &healthcheck.Journal{File: opts.journalFile}
+		switch {
+		case *opts.retryFailed && *opts.resume:
+			return report.Report{}, fmt.Errorf("-retry-failed and -resume can not be combined")
+		case *opts.retryFailed:
+			runner.Journal.Mode = healthcheck.JournalRetryFailed
+		case *opts.resume:
+			runner.Journal.Mode = healthcheck.JournalResume
+		}
+	}
+	runner.Logf = func(format string, args ...interface{}) {
+		fmt.Fprintln(os.Stderr, "Error", fmt.Sprintf(format, args...))
+	}
+
+	if runner.Suppressions, err = findings.LoadSuppressions(*opts.suppressionFile); err != nil {
+		return report.Report{}, err
+	}
+	if *opts.baselineFile != "" {
+		if runner.Baseline, err = findings.LoadBaseline(*opts.baselineFile); err != nil {
+			return report.Report{}, fmt.Errorf("reading baseline: %v", err)
+		}
+	}
+	return runner.Run()
+}
+
+// selectSuites returns the suites of a comma separated list of suite names, or all
+func selectSuites(suites string) ([]healthcheck.Check, error) {
+	if suites == "all" {
+		return healthcheck.Suites()
+	}
+	names := []string{}
+	for _, name := range strings.Split(suites, ",") {
+		names = append(names, strings.TrimSpace(name))
+	}
+	return healthcheck.Suites(names...)
+}
+
+// collectFindings runs the selected suites and returns the result of every check and their findings
+func collectFindings(kc *k8s.K8sClient, suites string) ([]models.CheckResult, []models.Finding, error) {
+	checks, err := selectSuites(suites)
+	if err != nil {
+		return nil, nil, err
+	}
+	results, result := healthcheck.NewRunner(kc, nil, checks...).Collect()
+	return results, result, nil
+}
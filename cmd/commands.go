@@ -0,0 +1,172 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/report"
+	"healthctl/pkg/testsuite"
+)
+
+var configFile = flag.String("config", config.StatePath("config.yaml"), "healthctl configuration file")
+
+var loadedConfig *config.Config
+
+// loadConfig reads the configuration file once and applies the connection settings to the kubernetes clients
+func loadConfig() (*config.Config, error) {
+	if loadedConfig != nil {
+		return loadedConfig, nil
+	}
+	cfg, err := config.Load(*configFile, templateVars())
+	if err != nil {
+		return nil, err
+	}
+	k8s.SetConnectionDefaults(cfg.Connection)
+	if cfg.HasSecretReferences() {
+		// the secrets are read as the user of the kubeconfig, the impersonated user only runs the checks
+		kc, err := k8s.NewBaseK8sClient()
+		if err != nil {
+			return nil, fmt.Errorf("resolving secret references in config: %v", err)
+		}
+		if err := cfg.ResolveSecrets(kc.GetSecretValue); err != nil {
+			return nil, err
+		}
+	}
+	testsuite.Configure(cfg.Checks)
+	k8s.SetDebugPorts(cfg.Debug)
+	if k8s.InsecureConnection() {
+		fmt.Fprintln(os.Stderr, "WARNING: API server certificates are not verified, the connection is not secure")
+	}
+	if user, groups := k8s.Impersonation(); user != "" {
+		fmt.Fprintf(os.Stderr, "running as %s %v, the results show what this user can see\n", user, groups)
+	}
+	loadedConfig = cfg
+	return cfg, nil
+}
+
+// templateVars returns the variables of the cluster in use for the templates of the configuration file.
+// HEALTHCTL_CLUSTER names the cluster where there is no kubeconfig, e.g. for agents running in the cluster.
+func templateVars() config.TemplateVars {
+	cluster := os.Getenv("HEALTHCTL_CLUSTER")
+	if cluster == "" {
+		cluster = k8s.CurrentCluster()
+	}
+	return config.TemplateVars{
+		Cluster:     cluster,
+		Context:     k8s.CurrentContext(),
+		Environment: os.Getenv("HEALTHCTL_ENVIRONMENT"),
+		Namespaces: func() ([]string, error) {
+			kc, err := k8s.NewK8sClient()
+			if err != nil {
+				return nil, err
+			}
+			return kc.GetClusterNamespaces(), nil
+		},
+	}
+}
+
+// runCommand runs a headless healthctl command and returns the process exit code
+func runCommand(args []string) int {
+	switch args[0] {
+	case "check":
+		return checkCommand(args[1:])
+	case "baseline":
+		return baselineCommand(args[1:])
+	case "auth":
+		return authCommand(args[1:])
+	case "bundle":
+		return bundleCommand(args[1:])
+	case "runbook":
+		return runbookCommand(args[1:])
+	case "finalizers":
+		return finalizersCommand(args[1:])
+	case "debug-level":
+		return debugLevelCommand(args[1:])
+	case "debug-session":
+		return debugSessionCommand(args[1:])
+	case "profile":
+		return profileCommand(args[1:])
+	case "jvm-dump":
+		return jvmDumpCommand(args[1:])
+	case "compare":
+		return compareCommand(args[1:])
+	case "migrate":
+		return migrateCommand(args[1:])
+	case "drill":
+		return drillCommand(args[1:])
+	case "wait":
+		return waitCommand(args[1:])
+	case "canary":
+		return canaryCommand(args[1:])
+	case "loadtest":
+		return loadtestCommand(args[1:])
+	case "serve":
+		return serveCommand(args[1:])
+	case "findings":
+		return findingsCommand(args[1:])
+	case "agent":
+		return agentCommand(args[1:])
+	case "node-agent":
+		return nodeAgentCommand(args[1:])
+	case "config":
+		return configCommand(args[1:])
+	case "report":
+		return reportCommand(args[1:])
+	case "snapshot":
+		return snapshotCommand(args[1:])
+	case "query":
+		return queryCommand(args[1:])
+	case "grafana":
+		return grafanaCommand(args[1:])
+	case "operator":
+		return operatorCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		usage()
+		return 2
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: healthctl [flags] [command]\n\n")
+	fmt.Fprintf(os.Stderr, "Without a command the interactive terminal UI is started.\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  check              run the test suites and report findings\n")
+	fmt.Fprintf(os.Stderr, "  baseline create    accept all current findings, later checks only report new ones\n")
+	fmt.Fprintf(os.Stderr, "  baseline clear     remove the baseline\n")
+	fmt.Fprintf(os.Stderr, "  auth check         verify authentication works for every kubeconfig context\n")
+	fmt.Fprintf(os.Stderr, "  bundle create      write a support bundle with the report, manifests, events and logs\n")
+	fmt.Fprintf(os.Stderr, "  runbook <id>       run the runbook of a finding step by step\n")
+	fmt.Fprintf(os.Stderr, "  finalizers list    list objects stuck in Terminating and their finalizers\n")
+	fmt.Fprintf(os.Stderr, "  finalizers remove  remove orphaned finalizers of a stuck object after confirmation\n")
+	fmt.Fprintf(os.Stderr, "  debug-level set    set the debug level of the pods matching a selector, glob or regex concurrently\n")
+	fmt.Fprintf(os.Stderr, "  debug-level reset  reset the debug levels set before, or of the matching pods\n")
+	fmt.Fprintf(os.Stderr, "  debug-session      raise the debug level of pods, capture their logs, usage and events, restore the levels and bundle it all\n")
+	fmt.Fprintf(os.Stderr, "  profile            capture heap, goroutine and CPU pprof profiles of Go services over a port-forward\n")
+	fmt.Fprintf(os.Stderr, "  jvm-dump           capture thread dumps, heap summaries and GC logs of Java pods with jcmd\n")
+	fmt.Fprintf(os.Stderr, "  drill backup-restore back up the drill namespace, restore it into a scratch namespace and verify it starts\n")
+	fmt.Fprintf(os.Stderr, "  wait               block until the selected workloads rolled out and are healthy, for deploy pipelines\n")
+	fmt.Fprintf(os.Stderr, "  canary             compare a canary deployment with its baseline and recommend promote or abort\n")
+	fmt.Fprintf(os.Stderr, "  loadtest           ramp up HTTP load against ingresses and services while the suites run\n")
+	fmt.Fprintf(os.Stderr, "  serve              run the web dashboard and REST API, receive Alertmanager notifications and slack commands\n")
+	fmt.Fprintf(os.Stderr, "  findings list      list the findings tracked by serve with their state: open, acknowledged, suppressed, resolved\n")
+	fmt.Fprintf(os.Stderr, "  findings ack <id>  acknowledge a finding with -assignee and -comment, findings reopen <id> undoes it\n")
+	fmt.Fprintf(os.Stderr, "  agent              run the suites in the cluster and push the reports to a fleet server\n")
+	fmt.Fprintf(os.Stderr, "  node-agent         run the host checks of a node and report them to serve, manifest prints its DaemonSet\n")
+	fmt.Fprintf(os.Stderr, "  config lint        validate the configuration file, config schema prints its JSON Schema\n")
+	fmt.Fprintf(os.Stderr, "  report schema      print the JSON Schema of the json report, apiVersion %s\n", report.APIVersion)
+	fmt.Fprintf(os.Stderr, "  report startup     print the time to ready of every workload by phase and the workloads whose startup degraded\n")
+	fmt.Fprintf(os.Stderr, "  report usage       print the usage, requests and limits of every container as csv for spreadsheets\n")
+	fmt.Fprintf(os.Stderr, "  query \"SELECT ...\"  run SQL over the collected runs, findings, usage samples and pod startups, query -tables lists them\n")
+	fmt.Fprintf(os.Stderr, "  snapshot create    write the findings and the usage of every namespace to a snapshot file\n")
+	fmt.Fprintf(os.Stderr, "  snapshot diff      list the added, removed and changed findings and usage between two snapshots, reports or baselines\n")
+	fmt.Fprintf(os.Stderr, "  compare            run the suites against two contexts and diff their versions, workloads, configuration and findings\n")
+	fmt.Fprintf(os.Stderr, "  migrate plan       inventory a namespace and report the blockers of moving it to another cluster\n")
+	fmt.Fprintf(os.Stderr, "  grafana provision  create or update the dashboards of the exported metrics in Grafana\n")
+	fmt.Fprintf(os.Stderr, "  operator crd       print the HealthCheck CustomResourceDefinition reconciled by serve with serve.operator\n\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
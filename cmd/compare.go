@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"healthctl/pkg/compare"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+)
+
+// contextsFlag collects the values of the repeated -context flag
+type contextsFlag []string
+
+func (c *contextsFlag) String() string { return strings.Join(*c, ",") }
+
+func (c *contextsFlag) Set(value string) error {
+	*c = append(*c, value)
+	return nil
+}
+
+// compareCommand runs the same suites against two clusters and prints their differences side by side. It
+// exits with 1 when the clusters differ, like diff.
+func compareCommand(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	var contexts contextsFlag
+	fs.Var(&contexts, "context", "kubeconfig context of a cluster, given twice")
+	suites := fs.String("suite", "all", "comma separated list of suites to run on both clusters or all")
+	namespaces := fs.String("namespaces", "", "comma separated namespace globs whose workloads and config maps are compared, defaults to all")
+	format := fs.String("o", "text", "output format, text or json")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl compare -context <staging> -context <prod> [-suite <suites>] [-namespaces <globs>] [-o text|json]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if len(contexts) != 2 || (*format != "text" && *format != "json") {
+		fs.Usage()
+		return 2
+	}
+	globs := []string{}
+	if *namespaces != "" {
+		globs = strings.Split(*namespaces, ",")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	// the clusters are checked one after the other, the suites share the package settings
+	clusters := make([]compare.Cluster, 2)
+	for i, contextName := range contexts {
+		fmt.Fprintf(os.Stderr, "Checking %s\n", contextName)
+		kc, err := k8s.NewK8sClientForContext(contextName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating kubernetes client for %s: %v\n", contextName, err)
+			return 2
+		}
+		opts := serviceOptions(strings.Split(*suites, ","), 1, "")
+		r, err := buildReport(kc, cfg, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error checking %s: %v\n", contextName, err)
+			return 2
+		}
+		if clusters[i], err = compare.Collect(kc, contextName, r, globs); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	comparison := compare.Compare(clusters[0], clusters[1])
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(comparison); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	} else {
+		printComparison(comparison)
+	}
+	if comparison.Differs() {
+		return 1
+	}
+	return 0
+}
+
+// printComparison prints the versions, the objects of only one cluster, the drift and the findings of only
+// one cluster side by side, differing versions are marked with *
+func printComparison(c compare.Comparison) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "VERSION\t%s\t%s\t\n", c.Left, c.Right)
+	for _, row := range c.Versions {
+		marker := ""
+		if row.Left != row.Right {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", row.Key, valueOrNone(row.Left), valueOrNone(row.Right), marker)
+	}
+	w.Flush()
+
+	for _, side := range []struct {
+		context string
+		objects []models.ResourceRef
+	}{{c.Left, c.OnlyLeft}, {c.Right, c.OnlyRight}} {
+		if len(side.objects) == 0 {
+			continue
+		}
+		fmt.Printf("\nOnly in %s:\n", side.context)
+		for _, object := range side.objects {
+			fmt.Printf("  %s\n", object)
+		}
+	}
+
+	if len(c.Drift) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "DRIFT\tSETTING\t%s\t%s\n", c.Left, c.Right)
+		for _, row := range c.Drift {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", row.Resource, row.Key, valueOrNone(row.Left), valueOrNone(row.Right))
+		}
+		w.Flush()
+	}
+
+	for _, side := range []struct {
+		context  string
+		findings []models.Finding
+	}{{c.Left, c.Findings.OnlyLeft}, {c.Right, c.Findings.OnlyRight}} {
+		if len(side.findings) == 0 {
+			continue
+		}
+		fmt.Printf("\nFindings only in %s:\n", side.context)
+		for _, finding := range side.findings {
+			fmt.Printf("  [%s] %s %s: %s\n", finding.Severity, finding.Check, finding.Resource, finding.Message)
+		}
+	}
+	fmt.Printf("\n%d objects only in %s, %d only in %s, %d settings drifted, %d findings only in %s, %d only in %s, %d in both\n",
+		len(c.OnlyLeft), c.Left, len(c.OnlyRight), c.Right, len(c.Drift),
+		len(c.Findings.OnlyLeft), c.Left, len(c.Findings.OnlyRight), c.Right, c.Findings.Both)
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"healthctl/pkg/auth"
+	"healthctl/pkg/config"
+	"healthctl/pkg/models"
+	"healthctl/pkg/report"
+	"healthctl/pkg/testsuite"
+)
+
+// configCommand validates the configuration file and prints its JSON Schema
+func configCommand(args []string) int {
+	if len(args) == 0 || (args[0] != "lint" && args[0] != "schema") {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl config lint [file] | healthctl config schema")
+		return 2
+	}
+	if args[0] == "schema" {
+		return printSchema(config.Schema())
+	}
+
+	file := *configFile
+	if len(args) > 1 {
+		file = args[1]
+	}
+	suites := []string{}
+	for _, suite := range testsuite.Suites {
+		suites = append(suites, suite.Name)
+	}
+	issues, err := config.Lint(file, templateVars(), config.References{
+		Suites:     suites,
+		Checks:     testsuite.CheckNames(),
+		Roles:      []string{auth.RoleViewer.String(), auth.RoleOperator.String(), auth.RoleAdmin.String()},
+		Severities: []string{string(models.SeverityInfo), string(models.SeverityWarning), string(models.SeverityCritical)},
+		Formats:    report.Formats(),
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	if len(issues) > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d issues\n", file, len(issues))
+		return 1
+	}
+	fmt.Printf("%s: ok\n", file)
+	return 0
+}
+
+// reportCommand prints the JSON Schema of the json report or the pod startup report
+func reportCommand(args []string) int {
+	if len(args) > 0 && args[0] == "startup" {
+		return startupReportCommand(args[1:])
+	}
+	if len(args) > 0 && args[0] == "usage" {
+		return usageReportCommand(args[1:])
+	}
+	if len(args) != 1 || args[0] != "schema" {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl report schema | healthctl report startup [-o text|json] [-degraded] | healthctl report usage")
+		return 2
+	}
+	return printSchema(report.Schema())
+}
+
+func printSchema(schema map[string]interface{}) int {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(schema); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"healthctl/pkg/audit"
+	"healthctl/pkg/auth"
+	"healthctl/pkg/config"
+	"healthctl/pkg/findings"
+	"healthctl/pkg/fleet"
+	"healthctl/pkg/healthcheck"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+	"healthctl/pkg/output"
+	"healthctl/pkg/report"
+	"healthctl/pkg/tsdb"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// defaultDashboardInterval is the time between dashboard runs when serve.interval is not set
+const defaultDashboardInterval = 5 * time.Minute
+
+// defaultAgentTimeout is how long an agent may not report before its cluster is stale
+const defaultAgentTimeout = 15 * time.Minute
+
+// maxAgentReport limits the size of reports pushed by agents
+const maxAgentReport = 64 << 20
+
+// clientFor returns the client of a cluster of the kubeconfig, clients are created once
+func (s *server) clientFor(cluster string) (*k8s.K8sClient, error) {
+	if cluster == s.cluster {
+		return s.kc, nil
+	}
+	if kc, found := s.clients[cluster]; found {
+		return kc, nil
+	}
+	contextName, err := k8s.ContextForCluster(cluster)
+	if err != nil {
+		return nil, err
+	}
+	kc, err := k8s.NewK8sClientForContext(contextName)
+	if err != nil {
+		return nil, err
+	}
+	s.clients[cluster] = kc
+	return kc, nil
+}
+
+// runDashboard checks every cluster each interval and in between the clusters a run was requested for
+func (s *server) runDashboard(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for _, cluster := range s.clusters {
+		s.runCluster(cluster)
+	}
+	for {
+		select {
+		case <-ticker.C:
+			for _, cluster := range s.clusters {
+				s.runCluster(cluster)
+			}
+		case cluster := <-s.trigger:
+			s.runCluster(cluster)
+		}
+	}
+}
+
+// runCluster runs the dashboard suites on a cluster and stores the report
+func (s *server) runCluster(cluster string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	kc, err := s.clientFor(cluster)
+	if err != nil {
+		log.Printf("checking cluster %s: %v", cluster, err)
+		return
+	}
+	opts := serviceOptions(s.cfg.Serve.Suites, s.cfg.Serve.Shards, s.store.HistoryFile(cluster))
+	if s.nodeAgents != nil && cluster == s.cluster {
+		opts.checks = []healthcheck.Check{s.nodeAgents.Check()}
+	}
+	r, err := buildReport(kc, s.cfg, opts)
+	if err != nil {
+		log.Printf("checking cluster %s: %v", cluster, err)
+		return
+	}
+	r.Cluster = cluster
+	var usage *k8s.ResourceUsageReport
+	if len(s.exporters) > 0 {
+		if resourceUsage, err := kc.GetResourceUsageReport(); err != nil {
+			log.Printf("usage of cluster %s: %v", cluster, err)
+		} else {
+			usage = &resourceUsage
+		}
+	}
+	s.processReport(r, usage)
+	if s.events != nil {
+		if err := s.events.Record(kc, r, s.store.EventFile(cluster)); err != nil {
+			log.Printf("cluster %s: %v", cluster, err)
+		}
+	}
+}
+
+// processReport stores and uploads the report of a dashboard run or an agent, exports its metrics, syncs the
+// tickets and notifies the teams. The usage of agent clusters is not known, their metrics have no usage.
+// Reports of the same cluster are processed one after the other.
+func (s *server) processReport(r report.Report, usage *k8s.ResourceUsageReport) {
+	lock := s.reportLock(r.Cluster)
+	lock.Lock()
+	defer lock.Unlock()
+	if r.Labels == nil {
+		r.Labels = make(map[string]string)
+	}
+	maps.Copy(r.Labels, s.cfg.InventoryLabels(r.Cluster))
+	if err := s.store.Save(r); err != nil {
+		log.Printf("storing report of cluster %s: %v", r.Cluster, err)
+	}
+	if window, active := s.maintenance.Active(r.Cluster, "", r.Generated); active {
+		log.Printf("cluster %s is in maintenance window %s, its findings are recorded without notifications", r.Cluster, window.Name)
+	}
+	if err := output.Upload(s.outputs, r); err != nil {
+		log.Printf("cluster %s: %v", r.Cluster, err)
+	}
+	if err := tsdb.Push(s.exporters, r, usage); err != nil {
+		log.Printf("cluster %s: %v", r.Cluster, err)
+	}
+	if s.tickets != nil {
+		if err := s.tickets.Sync(r.Cluster, r.Checks, r.Findings, s.store.TicketFile(r.Cluster), r.Generated); err != nil {
+			log.Printf("syncing tickets of cluster %s: %v", r.Cluster, err)
+		}
+	}
+	if s.notifier != nil && s.notifySelector.Matches(labels.Set(r.Labels)) {
+		err := s.notifier.Notify(r.Cluster, r.Checks, r.Findings, s.store.NotificationFile(r.Cluster), r.Generated)
+		if err != nil {
+			log.Printf("notifying teams of cluster %s: %v", r.Cluster, err)
+		}
+	}
+}
+
+// reportLock returns the mutex serializing the reports of a cluster
+func (s *server) reportLock(cluster string) *sync.Mutex {
+	s.reportingMutex.Lock()
+	defer s.reportingMutex.Unlock()
+	if s.reporting[cluster] == nil {
+		s.reporting[cluster] = &sync.Mutex{}
+	}
+	return s.reporting[cluster]
+}
+
+// apiAgentReport receives the report of a fleet agent, the cluster is the common name of its certificate
+func (s *server) apiAgentReport(w http.ResponseWriter, r *http.Request) {
+	cluster, ok := fleet.AgentCluster(r)
+	if !ok {
+		http.Error(w, "a verified client certificate is required", http.StatusUnauthorized)
+		return
+	}
+	if slices.Contains(s.clusters, cluster) {
+		http.Error(w, fmt.Sprintf("cluster %s is checked by the server itself", cluster), http.StatusConflict)
+		return
+	}
+	pushed, err := report.Decode(http.MaxBytesReader(w, r.Body, maxAgentReport))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid report: %v", err), http.StatusBadRequest)
+		return
+	}
+	pushed.Cluster = cluster
+	if pushed.Shard != nil {
+		if shard := pushed.Shard; shard.Count < 1 || shard.Index < 0 || shard.Index >= shard.Count {
+			http.Error(w, fmt.Sprintf("invalid shard %d of %d", shard.Index, shard.Count), http.StatusBadRequest)
+			return
+		}
+		var complete bool
+		if pushed, complete = s.mergeShard(pushed); !complete {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+	}
+	s.processReport(pushed, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mergeShard keeps the report of a shard of an agent cluster and returns the merged report once every
+// shard reported. A shard that reports again before the others replaces its earlier report.
+func (s *server) mergeShard(r report.Report) (report.Report, bool) {
+	s.partialMutex.Lock()
+	defer s.partialMutex.Unlock()
+	shards := s.partial[r.Cluster]
+	if len(shards) != r.Shard.Count {
+		// first report or the agents were scaled, the reports of the old shards do not fit
+		shards = make([]report.Report, r.Shard.Count)
+		s.partial[r.Cluster] = shards
+	}
+	shards[r.Shard.Index] = r
+	for _, shard := range shards {
+		if shard.Shard == nil {
+			return r, false
+		}
+	}
+	delete(s.partial, r.Cluster)
+	return report.Merge(shards), true
+}
+
+// allClusters returns the clusters the server checks followed by the clusters of agents
+func (s *server) allClusters() []string {
+	clusters := slices.Clone(s.clusters)
+	stored, err := s.store.Clusters()
+	if err != nil {
+		log.Printf("listing stored clusters: %v", err)
+	}
+	for _, cluster := range stored {
+		if !slices.Contains(clusters, cluster) {
+			clusters = append(clusters, cluster)
+		}
+	}
+	return clusters
+}
+
+// registerAPI adds the REST API the dashboard is built on, clusters are passed as query parameter since
+// cluster names may contain slashes
+func (s *server) registerAPI(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/clusters", s.auth.Require(auth.RoleViewer, s.apiClusters))
+	mux.HandleFunc("GET /api/v1/fleet", s.auth.Require(auth.RoleViewer, s.apiFleet))
+	mux.HandleFunc("GET /api/v1/report", s.auth.Require(auth.RoleViewer, s.apiReport))
+	mux.HandleFunc("GET /api/v1/history", s.auth.Require(auth.RoleViewer, s.apiHistory))
+	mux.HandleFunc("GET /api/v1/findings", s.auth.Require(auth.RoleViewer, s.apiFindings))
+	mux.HandleFunc("POST /api/v1/run", s.auth.Require(auth.RoleOperator, s.apiRun))
+	mux.HandleFunc("POST /api/v1/acknowledge", s.auth.Require(auth.RoleOperator, s.apiAcknowledge))
+	mux.HandleFunc("POST /api/v1/reopen", s.auth.Require(auth.RoleOperator, s.apiReopen))
+	mux.HandleFunc("POST /api/v1/suppress", s.auth.Require(auth.RoleAdmin, s.apiSuppress))
+	// agents authenticate with their client certificate
+	mux.HandleFunc("POST "+fleet.ReportPath, s.apiAgentReport)
+}
+
+// clusterSummaries returns the clusters matching the selector query parameter, an empty selector matches all
+func (s *server) clusterSummaries(w http.ResponseWriter, r *http.Request) ([]fleet.ClusterSummary, bool) {
+	selector, err := labels.Parse(r.URL.Query().Get("selector"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid selector: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+	summaries := []fleet.ClusterSummary{}
+	for _, cluster := range s.allClusters() {
+		summary := fleet.ClusterSummary{Name: cluster, Agent: !slices.Contains(s.clusters, cluster)}
+		if runs, err := s.store.Runs(cluster); err == nil && len(runs) > 0 {
+			summary.Latest = &runs[len(runs)-1]
+			summary.Labels = summary.Latest.Labels
+		}
+		summary.Stale = summary.Agent && (summary.Latest == nil || time.Since(summary.Latest.Generated) > s.agentTimeout)
+		summaries = append(summaries, summary)
+	}
+	return fleet.Filter(summaries, selector), true
+}
+
+func (s *server) apiClusters(w http.ResponseWriter, r *http.Request) {
+	if summaries, ok := s.clusterSummaries(w, r); ok {
+		respondJSON(w, summaries)
+	}
+}
+
+func (s *server) apiFleet(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("groupBy")
+	if key == "" {
+		http.Error(w, "groupBy is required", http.StatusBadRequest)
+		return
+	}
+	if summaries, ok := s.clusterSummaries(w, r); ok {
+		respondJSON(w, fleet.GroupBy(summaries, key))
+	}
+}
+
+func (s *server) apiReport(w http.ResponseWriter, r *http.Request) {
+	cluster, ok := s.knownCluster(w, r)
+	if !ok {
+		return
+	}
+	latest, err := s.store.Latest(cluster)
+	if os.IsNotExist(err) {
+		http.Error(w, "no report yet", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, latest)
+}
+
+func (s *server) apiHistory(w http.ResponseWriter, r *http.Request) {
+	cluster, ok := s.knownCluster(w, r)
+	if !ok {
+		return
+	}
+	runs, err := s.store.Runs(cluster)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, runs)
+}
+
+func (s *server) apiRun(w http.ResponseWriter, r *http.Request) {
+	cluster := r.URL.Query().Get("cluster")
+	if !slices.Contains(s.clusters, cluster) || s.cfg.Serve.DisableChecks {
+		http.Error(w, fmt.Sprintf("cluster %q is not checked by the server", cluster), http.StatusNotFound)
+		return
+	}
+	select {
+	case s.trigger <- cluster:
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.Error(w, "too many runs queued", http.StatusServiceUnavailable)
+	}
+}
+
+func (s *server) apiFindings(w http.ResponseWriter, r *http.Request) {
+	cluster, ok := s.knownCluster(w, r)
+	if !ok {
+		return
+	}
+	records, err := s.store.Findings(cluster, models.FindingState(r.URL.Query().Get("state")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, records)
+}
+
+// findingAction is the body of the suppress, acknowledge and reopen requests
+type findingAction struct {
+	ID       string `json:"id"`
+	By       string `json:"by"`
+	Reason   string `json:"reason,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	Assignee string `json:"assignee,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+func (s *server) apiSuppress(w http.ResponseWriter, r *http.Request) {
+	cluster, action, ok := s.findingAction(w, r)
+	if !ok {
+		return
+	}
+	// finding IDs do not include the cluster, the suppression must not hide the finding in other clusters
+	suppression := findings.Suppression{ID: action.ID, Cluster: cluster, Reason: action.Reason, Expires: action.Expires}
+	if err := findings.AddSuppression(config.StatePath("suppressions.yaml"), suppression); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recordAction(audit.Entry{User: action.By, Action: "suppress finding", Target: action.ID, Detail: action.Reason})
+
+	// the suppression applies to the latest report right away instead of with the next run
+	latest, err := s.store.Latest(cluster)
+	if err == nil {
+		latest.Findings = findings.Suppress(latest.Findings, []findings.Suppression{suppression}, time.Now())
+		err = s.store.Replace(latest)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) apiAcknowledge(w http.ResponseWriter, r *http.Request) {
+	cluster, action, ok := s.findingAction(w, r)
+	if !ok {
+		return
+	}
+	ack := models.Acknowledgement{By: action.By, Assignee: action.Assignee, Comment: action.Comment, At: time.Now()}
+	if err := s.store.Acknowledge(cluster, action.ID, ack); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recordAction(audit.Entry{User: action.By, Action: "acknowledge finding", Target: action.ID, Detail: ackDetail(cluster, ack)})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) apiReopen(w http.ResponseWriter, r *http.Request) {
+	cluster, action, ok := s.findingAction(w, r)
+	if !ok {
+		return
+	}
+	if err := s.store.Reopen(cluster, action.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recordAction(audit.Entry{User: action.By, Action: "reopen finding", Target: action.ID, Detail: cluster})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findingAction decodes the body of a finding action on a known cluster, the user must be named unless
+// the request is authenticated
+func (s *server) findingAction(w http.ResponseWriter, r *http.Request) (string, findingAction, bool) {
+	action := findingAction{}
+	cluster, ok := s.knownCluster(w, r)
+	if !ok {
+		return "", action, false
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBody)).Decode(&action); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return "", action, false
+	}
+	if identity, found := auth.FromContext(r.Context()); found && s.auth != nil {
+		// an authenticated user cannot act in the name of someone else
+		action.By = identity.Name
+	}
+	if action.ID == "" || action.By == "" {
+		http.Error(w, "id and by are required", http.StatusBadRequest)
+		return "", action, false
+	}
+	return cluster, action, true
+}
+
+// knownCluster returns the cluster parameter when it is one of the dashboard or agent clusters
+func (s *server) knownCluster(w http.ResponseWriter, r *http.Request) (string, bool) {
+	cluster := r.URL.Query().Get("cluster")
+	if slices.Contains(s.allClusters(), cluster) {
+		return cluster, true
+	}
+	http.Error(w, fmt.Sprintf("unknown cluster %q", cluster), http.StatusNotFound)
+	return "", false
+}
+
+func recordAction(entry audit.Entry) {
+	if err := audit.Record(entry); err != nil {
+		log.Printf("writing audit log: %v", err)
+	}
+}
+
+func respondJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("writing response: %v", err)
+	}
+}
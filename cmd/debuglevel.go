@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"healthctl/pkg/audit"
+	"healthctl/pkg/config"
+	"healthctl/pkg/k8s"
+)
+
+// debugLevelsFile keeps the pods whose debug level was set, so debug-level reset finds them afterwards
+var debugLevelsFile = config.StatePath("debuglevels.json")
+
+// debugLevelEntry is a container whose debug level was set
+type debugLevelEntry struct {
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Container string    `json:"container,omitempty"`
+	Level     string    `json:"level"`
+	Time      time.Time `json:"time"`
+}
+
+func debugLevelCommand(args []string) int {
+	if len(args) == 0 || (args[0] != "set" && args[0] != "reset") {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl debug-level set|reset [flags]")
+		return 2
+	}
+	fs := flag.NewFlagSet("debug-level "+args[0], flag.ExitOnError)
+	namespace := fs.String("namespace", "", "namespace of the pods")
+	selector := fs.String("selector", "", "label selector of the pods, e.g. app=api")
+	pods := fs.String("pods", "", "glob of the pod names, e.g. api-*")
+	regex := fs.String("regex", "", "regular expression the pod names must match, instead of -pods")
+	container := fs.String("container", "", "container of the eTrace endpoint, defaults to the default container of the pods")
+	level := fs.String("level", "", "debug level to set: "+strings.Join(k8s.DebugLevels, ", "))
+	all := fs.Bool("all", false, "set the debug level of every pod of the namespace, instead of -selector, -pods or -regex")
+	fs.Usage = func() {
+		if args[0] == "set" {
+			fmt.Fprintln(os.Stderr, "Usage: healthctl debug-level set -namespace <namespace> (-selector <selector> | -pods <glob> | -regex <regex> | -all) -level <level>")
+			fmt.Fprintln(os.Stderr, "At least one of -selector, -pods or -regex is needed, -all sets the level of every pod of the namespace.")
+		} else {
+			fmt.Fprintln(os.Stderr, "Usage: healthctl debug-level reset [-namespace <namespace> [-selector <selector>] [-pods <glob> | -regex <regex>]]")
+			fmt.Fprintln(os.Stderr, "Without a namespace the pods of every debug-level set since the last reset are reset.")
+		}
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+	if args[0] == "set" && (*namespace == "" || !slices.Contains(k8s.DebugLevels, *level)) {
+		fs.Usage()
+		return 2
+	}
+	if args[0] == "set" && *selector == "" && *pods == "" && *regex == "" && !*all {
+		// tracing every pod of a namespace by accident floods the logs, so it has to be asked for
+		fs.Usage()
+		return 2
+	}
+
+	if _, err := loadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	kc, err := k8s.NewK8sClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		return 2
+	}
+	target := k8s.DebugTarget{Namespace: *namespace, Selector: *selector, Pods: *pods, Regex: *regex, Container: *container}
+	if args[0] == "set" {
+		results, err := kc.SetDebugLevels(target, *level)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		return reportDebugLevels(target.Namespace, "set debug level "+*level, results, *level)
+	}
+
+	targets := []k8s.DebugTarget{target}
+	if *namespace == "" {
+		if targets, err = recordedDebugTargets(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if len(targets) == 0 {
+			fmt.Println("No debug levels were set since the last reset")
+			return 0
+		}
+	}
+	code := 0
+	running := make(map[string]bool)
+	for _, target := range targets {
+		results, err := kc.ResetDebugLevels(target)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		for _, result := range results {
+			running[target.Namespace+"/"+result.Pod] = true
+		}
+		code = max(code, reportDebugLevels(target.Namespace, "reset debug level", results, ""))
+	}
+	if *namespace == "" {
+		// recorded pods that are gone restarted with the default level
+		if err := forgetDebugLevels(func(entry debugLevelEntry) bool { return !running[entry.Namespace+"/"+entry.Pod] }); err != nil {
+			fmt.Fprintln(os.Stderr, "Error recording the debug levels:", err)
+		}
+	}
+	return code
+}
+
+// reportDebugLevels prints the result of every pod, records them in the audit log and keeps the pods whose
+// level was set for the reset, an empty level forgets the reset pods. It returns 1 when a pod failed.
+func reportDebugLevels(namespace, action string, results []k8s.DebugLevelResult, level string) int {
+	if len(results) == 0 {
+		fmt.Printf("No running pod matches in %s\n", namespace)
+		return 0
+	}
+	failed := 0
+	for _, result := range results {
+		entry := audit.Entry{Action: action, Target: namespace + "/" + result.Pod, Detail: result.Container, Output: result.Output}
+		if result.Err != nil {
+			failed++
+			entry.Error = result.Err.Error()
+			fmt.Printf("%-50s failed: %v\n", result.Pod, result.Err)
+		} else {
+			fmt.Printf("%-50s ok, port %d\n", result.Pod, result.Port)
+		}
+		if err := audit.Record(entry); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing audit log:", err)
+		}
+	}
+	fmt.Printf("%d of %d pods succeeded\n", len(results)-failed, len(results))
+	if err := recordDebugLevels(namespace, results, level); err != nil {
+		fmt.Fprintln(os.Stderr, "Error recording the debug levels:", err)
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func loadDebugLevels() ([]debugLevelEntry, error) {
+	entries := []debugLevelEntry{}
+	data, err := os.ReadFile(debugLevelsFile)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", debugLevelsFile, err)
+	}
+	return entries, nil
+}
+
+// recordDebugLevels keeps the containers whose level was set and forgets those that were reset
+func recordDebugLevels(namespace string, results []k8s.DebugLevelResult, level string) error {
+	entries, err := loadDebugLevels()
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		entries = slices.DeleteFunc(entries, func(entry debugLevelEntry) bool {
+			return entry.Namespace == namespace && entry.Pod == result.Pod && entry.Container == result.Container
+		})
+		if level != "" {
+			entries = append(entries, debugLevelEntry{Namespace: namespace, Pod: result.Pod, Container: result.Container, Level: level, Time: time.Now()})
+		}
+	}
+	return saveDebugLevels(entries)
+}
+
+// forgetDebugLevels removes the recorded containers the function returns true for
+func forgetDebugLevels(forget func(debugLevelEntry) bool) error {
+	entries, err := loadDebugLevels()
+	if err != nil {
+		return err
+	}
+	return saveDebugLevels(slices.DeleteFunc(entries, forget))
+}
+
+func saveDebugLevels(entries []debugLevelEntry) error {
+	if err := os.MkdirAll(filepath.Dir(debugLevelsFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(debugLevelsFile, data, 0600)
+}
+
+// recordedDebugTargets returns a target per namespace and container matching exactly the recorded pods
+func recordedDebugTargets() ([]k8s.DebugTarget, error) {
+	entries, err := loadDebugLevels()
+	if err != nil {
+		return nil, err
+	}
+	byTarget := make(map[k8s.DebugTarget][]string)
+	for _, entry := range entries {
+		key := k8s.DebugTarget{Namespace: entry.Namespace, Container: entry.Container}
+		byTarget[key] = append(byTarget[key], regexp.QuoteMeta(entry.Pod))
+	}
+	targets := []k8s.DebugTarget{}
+	for target, pods := range byTarget {
+		target.Regex = strings.Join(pods, "|")
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Namespace+"/"+targets[i].Container < targets[j].Namespace+"/"+targets[j].Container
+	})
+	return targets, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"healthctl/pkg/bundle"
+	"healthctl/pkg/k8s"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// debugSessionCommand raises the debug level of the selected pods that do not trace yet, captures the logs
+// and usage of all selected pods and the events of the namespace for a while, turns the raised level off
+// again and writes everything into a bundle
+func debugSessionCommand(args []string) int {
+	fs := flag.NewFlagSet("debug-session", flag.ExitOnError)
+	namespace := fs.String("namespace", "", "namespace of the pods")
+	selector := fs.String("selector", "", "label selector of the pods, e.g. app=api")
+	pods := fs.String("pods", "", "glob of the pod names, e.g. api-*")
+	regex := fs.String("regex", "", "regular expression the pod names must match, instead of -pods")
+	container := fs.String("container", "", "container of the eTrace endpoint, defaults to the default container of the pods")
+	level := fs.String("level", "DEBUG_3", "debug level during the session: "+strings.Join(k8s.DebugLevels, ", "))
+	duration := fs.Duration("duration", 5*time.Minute, "how long logs and usage are captured, an interrupt ends the session early")
+	interval := fs.Duration("metrics-interval", bundle.DefaultMetricsInterval, "interval of the usage samples")
+	file := fs.String("f", "", "bundle file to write, defaults to healthctl-debug-<namespace>-<time>.tar.gz")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl debug-session -namespace <namespace> [-selector <selector>] [-pods <glob> | -regex <regex>] [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *namespace == "" || !slices.Contains(k8s.DebugLevels, *level) || *duration <= 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if _, err := loadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if k8s.ReadOnly() {
+		fmt.Fprintln(os.Stderr, "debug levels can not be raised in read-only mode")
+		return 2
+	}
+	kc, err := k8s.NewK8sClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		return 2
+	}
+	target := k8s.DebugTarget{Namespace: *namespace, Selector: *selector, Pods: *pods, Regex: *regex, Container: *container}
+	selected, err := kc.DebugTargetPods(target)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(selected) == 0 {
+		fmt.Fprintf(os.Stderr, "No running pod matches in %s\n", *namespace)
+		return 1
+	}
+
+	// the levels are restored when the session is interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	session := bundle.Session{Namespace: *namespace, Container: *container, Level: *level, Started: time.Now()}
+	for _, pod := range selected {
+		session.Pods = append(session.Pods, pod.Name)
+	}
+	if *file == "" {
+		*file = bundle.SessionName(*namespace, session.Started) + ".tar.gz"
+	}
+
+	// pods that already trace, or whose level is unknown, are left as they are, so the session does not
+	// turn off tracing somebody else enabled
+	idle := []v1.Pod{}
+	for i, result := range kc.PodDebugLevels(selected, *container) {
+		switch {
+		case result.Err != nil:
+			fmt.Printf("%-50s left as is, reading its debug level failed: %v\n", result.Pod, result.Err)
+		case result.Output != "":
+			fmt.Printf("%-50s left as is, it already traces at %s\n", result.Pod, result.Output)
+		default:
+			idle = append(idle, selected[i])
+		}
+	}
+	session.Set = kc.SetPodDebugLevels(idle, *container, *level)
+	code := 0
+	if len(idle) > 0 {
+		code = reportDebugLevels(*namespace, "set debug level "+*level, session.Set, *level)
+	}
+	raised := []v1.Pod{}
+	for i, result := range session.Set {
+		if result.Err == nil {
+			raised = append(raised, idle[i])
+		}
+	}
+	fmt.Printf("Capturing the logs and usage of %d pods for %s, interrupt to end the session early\n", len(selected), *duration)
+	capture, captureErr := bundle.CaptureSession(ctx, kc, selected, session.Started, *duration, *interval)
+	session.Ended = time.Now()
+
+	fmt.Println("Restoring the debug levels")
+	session.Restored = kc.RestorePodDebugLevels(raised, *container, *level)
+	if len(raised) > 0 {
+		code = max(code, reportDebugLevels(*namespace, "restore debug level", session.Restored, ""))
+	}
+	if captureErr != nil {
+		fmt.Fprintln(os.Stderr, "Error capturing the session:", captureErr)
+		return 2
+	}
+
+	if err := bundle.WriteSession(*file, kc, session, capture); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing the session bundle:", err)
+		return 2
+	}
+	fmt.Printf("Debug session of %s written to %s\n", session.Ended.Sub(session.Started).Round(time.Second), *file)
+	return code
+}
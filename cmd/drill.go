@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"healthctl/pkg/audit"
+	"healthctl/pkg/config"
+	"healthctl/pkg/drill"
+	"healthctl/pkg/k8s"
+)
+
+func drillCommand(args []string) int {
+	if len(args) == 0 || args[0] != "backup-restore" {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl drill backup-restore [flags]")
+		return 2
+	}
+	fs := flag.NewFlagSet("drill backup-restore", flag.ExitOnError)
+	keep := fs.Bool("keep", false, "keep the restored namespace, the backup and the restore for inspection")
+	every := fs.Duration("every", 0, "repeat the drill at this interval until interrupted, e.g. 24h")
+	fs.Parse(args[1:])
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	kc, err := k8s.NewK8sClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		return 2
+	}
+
+	for {
+		code := runDrill(kc, cfg.Drill, drill.Options{Keep: *keep})
+		if *every == 0 {
+			return code
+		}
+		fmt.Printf("Next drill at %s\n\n", time.Now().Add(*every).Format(time.RFC3339))
+		time.Sleep(*every)
+	}
+}
+
+// runDrill runs one drill, prints its steps and records it in the drill history and the audit log
+func runDrill(kc *k8s.K8sClient, cfg config.Drill, opts drill.Options) int {
+	opts.Progress = func(step string) { fmt.Printf("%s %s...\n", time.Now().Format(time.TimeOnly), step) }
+	result, err := drill.Run(kc, cfg, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	for _, step := range result.Steps {
+		status := "ok"
+		if step.Error != "" {
+			status = "FAILED: " + step.Error
+		}
+		fmt.Printf("  %-9s %-6s %s %s\n", step.Name, step.Duration, status, step.Detail)
+	}
+
+	entry := audit.Entry{Action: "backup-restore drill", Target: "Namespace/" + result.Namespace, Detail: result.Target}
+	if !result.Success {
+		entry.Error = "drill failed"
+	}
+	if err := audit.Record(entry); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing audit log:", err)
+	}
+	if err := drill.Record(result); err != nil {
+		fmt.Fprintln(os.Stderr, "Error saving drill history:", err)
+	}
+	if !result.Success {
+		fmt.Printf("Drill %s of namespace %s failed\n", result.Name, result.Namespace)
+		return 1
+	}
+	fmt.Printf("Drill %s of namespace %s succeeded in %s\n", result.Name, result.Namespace, result.Finished.Sub(result.Started).Round(time.Second))
+	return 0
+}
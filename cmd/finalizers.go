@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"healthctl/pkg/audit"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+	"healthctl/pkg/testsuite"
+)
+
+func finalizersCommand(args []string) int {
+	if len(args) == 0 || (args[0] != "list" && args[0] != "remove") {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl finalizers list|remove [flags]")
+		return 2
+	}
+	if _, err := loadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	kc, err := k8s.NewK8sClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		return 2
+	}
+	if args[0] == "list" {
+		return listFinalizers(kc)
+	}
+	return removeFinalizers(kc, args[1:])
+}
+
+func listFinalizers(kc *k8s.K8sClient) int {
+	stuck, err := k8s.GetStuckObjects(kc.Client, testsuite.StuckAfter())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	for _, object := range stuck {
+		fmt.Printf("%s terminating for %s\n", object.Resource, time.Since(object.Deleted).Round(time.Minute))
+		for _, finalizer := range object.Finalizers {
+			owner, builtin := k8s.FinalizerOwner(finalizer)
+			kind := "custom"
+			if builtin {
+				kind = "builtin"
+			}
+			fmt.Printf("  %s (%s, %s)\n", finalizer, owner, kind)
+		}
+	}
+	return 0
+}
+
+// removeFinalizers removes the finalizers of a stuck object one by one after confirmation. Only objects
+// that are terminating for longer than the stuck threshold qualify, finalizers of kubernetes itself need -force.
+func removeFinalizers(kc *k8s.K8sClient, args []string) int {
+	fs := flag.NewFlagSet("finalizers remove", flag.ExitOnError)
+	only := fs.String("finalizer", "", "only remove this finalizer")
+	force := fs.Bool("force", false, "also remove finalizers of kubernetes controllers")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl finalizers remove [flags] <kind> [<namespace>/]<name>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	if k8s.ReadOnly() {
+		fmt.Fprintln(os.Stderr, "finalizers can not be removed in read-only mode")
+		return 2
+	}
+	ref := models.ResourceRef{Kind: fs.Arg(0), Name: fs.Arg(1)}
+	if namespace, name, found := strings.Cut(fs.Arg(1), "/"); found {
+		ref.Namespace, ref.Name = namespace, name
+	}
+
+	object, err := kc.GetObject(ref)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	deleted := object.GetDeletionTimestamp()
+	if deleted == nil || time.Since(deleted.Time) < testsuite.StuckAfter() {
+		fmt.Fprintf(os.Stderr, "%s is not stuck in Terminating for more than %s, its finalizers are left alone\n", ref, testsuite.StuckAfter())
+		return 2
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	for _, finalizer := range object.GetFinalizers() {
+		if *only != "" && finalizer != *only {
+			continue
+		}
+		owner, builtin := k8s.FinalizerOwner(finalizer)
+		if builtin && !*force {
+			fmt.Printf("Keeping %s, it is removed by the %s, use -force to remove it anyway\n", finalizer, owner)
+			continue
+		}
+		fmt.Printf("%s is terminating for %s, finalizer %s should be removed by the %s\n", ref, time.Since(deleted.Time).Round(time.Minute), finalizer, owner)
+		if confirm(in, "Remove it? Cleanup done by the controller is skipped [y]es/[n]o: ", "yn") != "y" {
+			continue
+		}
+		err := kc.RemoveFinalizer(ref, finalizer)
+		entry := audit.Entry{Action: "remove finalizer", Target: ref.String(), Detail: finalizer}
+		if err != nil {
+			entry.Error = err.Error()
+			fmt.Fprintln(os.Stderr, "Error removing finalizer:", err)
+		}
+		if err := audit.Record(entry); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing audit log:", err)
+		}
+		if err != nil {
+			return 1
+		}
+		fmt.Printf("Removed %s\n", finalizer)
+	}
+	return 0
+}
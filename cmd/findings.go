@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"healthctl/pkg/audit"
+	"healthctl/pkg/config"
+	"healthctl/pkg/models"
+	"healthctl/pkg/results"
+)
+
+// findingsCommand lists the finding lifecycle of the results store of healthctl serve and acknowledges findings
+func findingsCommand(args []string) int {
+	if len(args) == 0 || (args[0] != "list" && args[0] != "ack" && args[0] != "reopen") {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl findings list|ack|reopen [flags] [finding-id]")
+		return 2
+	}
+	fs := flag.NewFlagSet("findings "+args[0], flag.ExitOnError)
+	storeDir := fs.String("store", config.StatePath("results"), "results store of healthctl serve")
+	cluster := fs.String("cluster", "", "cluster of the findings, required when the store has several clusters")
+	state := fs.String("state", "", "list only findings in this state: open, acknowledged, suppressed or resolved")
+	assignee := fs.String("assignee", "", "who is assigned to the finding")
+	comment := fs.String("comment", "", "comment of the acknowledgement")
+	fs.Parse(args[1:])
+
+	store := results.NewStore(*storeDir)
+	if *cluster == "" {
+		clusters, err := store.Clusters()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if len(clusters) != 1 {
+			fmt.Fprintf(os.Stderr, "The store has %d clusters, select one with -cluster: %v\n", len(clusters), clusters)
+			return 2
+		}
+		*cluster = clusters[0]
+	}
+
+	if args[0] == "list" {
+		return listFindings(store, *cluster, models.FindingState(*state))
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: healthctl findings %s [flags] <finding-id>\n", args[0])
+		return 2
+	}
+	id := fs.Arg(0)
+	by := audit.CurrentUser()
+
+	entry := audit.Entry{Action: "reopen finding", Target: id, Detail: *cluster}
+	var err error
+	if args[0] == "ack" {
+		ack := models.Acknowledgement{By: by, Assignee: *assignee, Comment: *comment, At: time.Now()}
+		err = store.Acknowledge(*cluster, id, ack)
+		entry = audit.Entry{Action: "acknowledge finding", Target: id, Detail: ackDetail(*cluster, ack)}
+	} else {
+		err = store.Reopen(*cluster, id)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if err := audit.Record(entry); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing audit log:", err)
+	}
+	return 0
+}
+
+func listFindings(store *results.Store, cluster string, state models.FindingState) int {
+	records, err := store.Findings(cluster, state)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATE\tSEVERITY\tRESOURCE\tOPENED\tASSIGNEE\tMESSAGE")
+	for _, record := range records {
+		assignee := ""
+		if record.Acknowledgement != nil {
+			assignee = record.Acknowledgement.Assignee
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", record.ID, record.State, record.Severity, record.Resource,
+			record.Opened.Format(time.DateTime), assignee, record.Message)
+	}
+	w.Flush()
+	return 0
+}
+
+// ackDetail describes an acknowledgement for the audit log
+func ackDetail(cluster string, ack models.Acknowledgement) string {
+	detail := fmt.Sprintf("cluster %s", cluster)
+	if ack.Assignee != "" {
+		detail += ", assigned to " + ack.Assignee
+	}
+	if ack.Comment != "" {
+		detail += ": " + ack.Comment
+	}
+	return detail
+}
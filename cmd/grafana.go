@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"healthctl/pkg/grafana"
+)
+
+func grafanaCommand(args []string) int {
+	if len(args) == 0 || args[0] != "provision" {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl grafana provision [-url url] [-folder title] [-datasource uid]")
+		return 2
+	}
+	return grafanaProvisionCommand(args[1:])
+}
+
+// grafanaProvisionCommand creates or updates the embedded dashboards of the exported metrics in Grafana
+func grafanaProvisionCommand(args []string) int {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fs := flag.NewFlagSet("grafana provision", flag.ExitOnError)
+	url := fs.String("url", cfg.Grafana.URL, "url of Grafana, the token is read from grafana.token of the config or GRAFANA_TOKEN")
+	folder := fs.String("folder", cfg.Grafana.Folder, "title of the folder of the dashboards, default "+grafana.DefaultFolder)
+	datasource := fs.String("datasource", cfg.Grafana.Datasource, "uid of the Prometheus datasource the dashboards query by default")
+	fs.Parse(args)
+
+	settings := cfg.Grafana
+	settings.URL, settings.Folder, settings.Datasource = *url, *folder, *datasource
+	if settings.Token == "" && settings.Username == "" {
+		settings.Token = os.Getenv("GRAFANA_TOKEN")
+	}
+	client, err := grafana.New(settings)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	dashboards, err := grafana.Dashboards(settings.Datasource)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	provisioned, err := client.Provision(dashboards)
+	for _, dashboard := range provisioned {
+		fmt.Printf("%s (version %d): %s\n", dashboard.Title, dashboard.Version, dashboard.URL)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
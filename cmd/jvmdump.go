@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/profiles"
+)
+
+// jvmDumpCommand captures thread dumps, heap summaries and GC logs of Java pods with jcmd into the profile
+// store, where the evidence and support bundles of their findings find them, and optionally into a tar.gz
+func jvmDumpCommand(args []string) int {
+	fs := flag.NewFlagSet("jvm-dump", flag.ExitOnError)
+	namespace := fs.String("namespace", "", "namespace of the pods")
+	selector := fs.String("selector", "", "label selector of the pods, e.g. app=billing")
+	pods := fs.String("pods", "", "glob of the pod names, e.g. billing-*")
+	regex := fs.String("regex", "", "regular expression the pod names must match, instead of -pods")
+	container := fs.String("container", "", "container of the JVM, defaults to the default container of the pods")
+	gcLog := fs.String("gc-log", "", "GC log file in the container, read from the -Xlog:gc flag of the JVM by default")
+	file := fs.String("f", "", "also write the diagnostics into this tar.gz")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl jvm-dump -namespace <namespace> [-selector <selector>] [-pods <glob> | -regex <regex>] [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *namespace == "" {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if k8s.ReadOnly() {
+		fmt.Fprintln(os.Stderr, "JVM diagnostics can not be captured in read-only mode, jcmd runs with exec")
+		return 2
+	}
+	kc, err := k8s.NewK8sClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		return 2
+	}
+	targets, err := kc.DebugTargetPods(k8s.DebugTarget{Namespace: *namespace, Selector: *selector, Pods: *pods, Regex: *regex})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(targets) == 0 {
+		fmt.Fprintf(os.Stderr, "No running pod matches in %s\n", *namespace)
+		return 1
+	}
+
+	fmt.Printf("Capturing %s of %d pods\n", strings.Join(k8s.JVMDiagnostics, ", "), len(targets))
+	captured := time.Now()
+	saved := make([][]profiles.File, len(targets))
+	errs := make([]error, len(targets))
+	k8s.ExecEach(len(targets), func(i int) {
+		pod := targets[i]
+		result, err := kc.CaptureJVMDiagnostics(pod, *container, *gcLog)
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		saved[i], errs[i] = saveProfiles(pod, captured, result, cfg.Checks.Profiles.Keep)
+	})
+	return reportProfiles(targets, saved, errs, *file, captured)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/loadtest"
+	"healthctl/pkg/models"
+)
+
+func loadtestCommand(args []string) int {
+	fs := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	targetList := fs.String("targets", "", "comma separated URLs, ingress/<namespace>/<name> or service/<namespace>/<name>:<port>")
+	rps := fs.Int("rps", 100, "requests per second of the last step")
+	steps := fs.Int("steps", 5, "number of steps the rate is ramped up in")
+	stepDuration := fs.Duration("step-duration", time.Minute, "duration of every step")
+	checkInterval := fs.Duration("check-interval", 30*time.Second, "how often the suites run during a step")
+	suites := fs.String("suite", "k8s", "comma separated list of suites to run during the load or all")
+	output := fs.String("o", "text", "output format, text or json")
+	fs.Parse(args)
+	if *targetList == "" || *rps < 1 || *steps < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl loadtest -targets <targets> [flags]")
+		fs.PrintDefaults()
+		return 2
+	}
+
+	if _, err := loadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	kc, err := k8s.NewK8sClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		return 2
+	}
+	targets := []loadtest.Target{}
+	for _, spec := range strings.Split(*targetList, ",") {
+		target, err := loadtest.ResolveTarget(kc.Client, strings.TrimSpace(spec))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		targets = append(targets, target)
+	}
+	if _, _, err := collectFindings(kc, *suites); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	check := func() []models.CheckResult {
+		checks, _, _ := collectFindings(kc, *suites)
+		return checks
+	}
+	plan := loadtest.Plan{RPS: *rps, Steps: *steps, StepDuration: *stepDuration, CheckInterval: *checkInterval}
+	if *output == "text" {
+		fmt.Printf("Ramping up to %d rps in %d steps of %s against %d targets\n", *rps, *steps, *stepDuration, len(targets))
+	}
+	report := loadtest.Run(targets, plan, check)
+
+	if *output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing report:", err)
+			return 2
+		}
+	} else {
+		if len(report.FailingBefore) > 0 {
+			fmt.Printf("Failing before the load: %s\n", strings.Join(report.FailingBefore, ", "))
+		}
+		fmt.Printf("%6s %9s %9s %7s %9s %9s %9s  %s\n", "RPS", "ACHIEVED", "REQUESTS", "ERRORS", "P50", "P95", "P99", "NEW FAILURES")
+		for _, step := range report.Steps {
+			fmt.Printf("%6d %9.1f %9d %6.1f%% %9s %9s %9s  %s\n", step.RPS, step.AchievedRPS, step.Requests, step.ErrorPercent(),
+				step.P50.Round(time.Millisecond), step.P95.Round(time.Millisecond), step.P99.Round(time.Millisecond), strings.Join(step.NewFailures, ", "))
+		}
+		if report.DegradedAt > 0 {
+			fmt.Printf("The cluster degraded at %d rps\n", report.DegradedAt)
+		} else {
+			fmt.Printf("No degradation up to %d rps\n", *rps)
+		}
+	}
+	if report.DegradedAt > 0 {
+		return 1
+	}
+	return 0
+}
@@ -4,18 +4,23 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 
+	"healthctl/pkg/config"
+	"healthctl/pkg/findings"
+	"healthctl/pkg/healthcheck"
 	"healthctl/pkg/k8s"
 	"healthctl/pkg/models"
-	"healthctl/pkg/testsuite"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+	v1 "k8s.io/api/core/v1"
 )
 
 type testInfoUI struct {
@@ -29,6 +34,9 @@ type testInfoUI struct {
 	context   *tview.TableCell
 	nodes     *tview.TableCell
 	apiserver *tview.TableCell
+	// control pauses and resumes the last run started from the UI, lastCommand is its suite button
+	control     *healthcheck.Control
+	lastCommand string
 }
 
 var Logo = []string{
@@ -48,12 +56,17 @@ var HEALTH_PAAS = "PAAS health"
 var HEALTH_SMF = "SMF health"
 var HEALTH_UPF = "UPF health"
 var HEALTH_STORAGE = "Storage health"
+var HEALTH_NETWORK = "Network health"
+var HEALTH_SECURITY = "Security health"
 var ACTIVE_ALERTS = "Active Alerts"
 var HEALTH_REDIS = "Redis status"
 var COLLECT_KARGO = "Collect Kargo"
 var SET_DEBUG_LEVEL = "Set Debug Level"
 var FLUSH_REDIS = "Flush Redis"
 var RESOURCE_USAGE = "Resource Usage"
+var PAUSE_RUN = "Pause/Resume Run"
+var RETRY_FAILED = "Re-run Failed"
+var RESUME_RUN = "Resume Run"
 
 func createApplication() (app *tview.Application) {
 	app = tview.NewApplication()
@@ -107,6 +120,16 @@ func createApplication() (app *tview.Application) {
 	afn_tools.AddItem(tview.NewBox(), 1, 0, false)
 	afn_tools.AddItem(CreateNewButton(HEALTH_STORAGE, sendCommand(pages, infoUI, HEALTH_STORAGE)), 0, 1, false)
 	afn_tools.AddItem(tview.NewBox(), 1, 0, false)
+	afn_tools.AddItem(CreateNewButton(HEALTH_NETWORK, sendCommand(pages, infoUI, HEALTH_NETWORK)), 0, 1, false)
+	afn_tools.AddItem(tview.NewBox(), 1, 0, false)
+	afn_tools.AddItem(CreateNewButton(HEALTH_SECURITY, sendCommand(pages, infoUI, HEALTH_SECURITY)), 0, 1, false)
+	afn_tools.AddItem(tview.NewBox(), 1, 0, false)
+	afn_tools.AddItem(CreateNewButton(PAUSE_RUN, PauseRun(infoUI)), 0, 1, false)
+	afn_tools.AddItem(tview.NewBox(), 1, 0, false)
+	afn_tools.AddItem(CreateNewButton(RETRY_FAILED, RerunSuite(pages, infoUI, healthcheck.JournalRetryFailed)), 0, 1, false)
+	afn_tools.AddItem(tview.NewBox(), 1, 0, false)
+	afn_tools.AddItem(CreateNewButton(RESUME_RUN, RerunSuite(pages, infoUI, healthcheck.JournalResume)), 0, 1, false)
+	afn_tools.AddItem(tview.NewBox(), 1, 0, false)
 	afn_tools.AddItem(CreateNewButton(ACTIVE_ALERTS, Alerts(pages)), 0, 1, false)
 	afn_tools.AddItem(tview.NewBox(), 1, 0, false)
 	afn_tools.AddItem(CreateNewButton(HEALTH_REDIS, RedisStatus(pages)), 0, 1, false)
@@ -127,6 +150,65 @@ func createApplication() (app *tview.Application) {
 	return app
 }
 
+// PauseRun pauses the running suite before its next check, or resumes it when it is paused
+func PauseRun(infoUI *testInfoUI) func() {
+	return func() {
+		if !running(infoUI) {
+			log.Printf("[yellow]No suite is running[-]\n")
+			return
+		}
+		if infoUI.control.Paused() {
+			infoUI.control.Resume()
+			log.Printf("[green]Run resumed[-]\n")
+			return
+		}
+		infoUI.control.Pause()
+		log.Printf("[yellow]Run paused after the running check, select %s again to resume[-]\n", PAUSE_RUN)
+	}
+}
+
+// RerunSuite runs a suite again with the results of its last run in the journal: only the failed checks
+// are run again when retrying, the checks an interrupted run did not complete when resuming
+func RerunSuite(pages *tview.Pages, infoUI *testInfoUI, mode healthcheck.JournalMode) func() {
+	return func() {
+		commands := []string{HEALTH_K8s, HEALTH_INFRA, HEALTH_PAAS, HEALTH_SMF, HEALTH_UPF, HEALTH_STORAGE, HEALTH_NETWORK, HEALTH_SECURITY}
+		selected := 0
+		for index, command := range commands {
+			if command == infoUI.lastCommand {
+				selected = index
+			}
+		}
+		title := "Re-run Failed Checks"
+		if mode == healthcheck.JournalResume {
+			title = "Resume Interrupted Run"
+		}
+
+		cancelFunc := func() {
+			pages.SwitchToPage("main")
+			pages.RemovePage("modal")
+		}
+
+		form := tview.NewForm()
+		form.SetBackgroundColor(tcell.ColorDarkSlateGray)
+		form.AddDropDown("Suite", commands, selected, nil)
+		form.AddButton("Start", func() {
+			_, command := form.GetFormItemByLabel("Suite").(*tview.DropDown).GetCurrentOption()
+			stop(infoUI)()
+			pages.SwitchToPage("main")
+			clearLogPanel(pages)
+			pages.RemovePage("modal")
+			startRun(infoUI, command, mode)
+		})
+		form.AddButton("Cancel", cancelFunc)
+		form.SetCancelFunc(cancelFunc)
+		form.SetButtonsAlign(tview.AlignCenter)
+		form.SetBorder(true).SetTitle(title)
+
+		modal := createModalForm(pages, form, 13, 80)
+		pages.AddPage("modal", modal, true, true)
+	}
+}
+
 func SetDebugLevel(pages *tview.Pages) func() {
 	kc, _ := k8s.NewK8sClient()
 	return func() {
@@ -134,15 +216,24 @@ func SetDebugLevel(pages *tview.Pages) func() {
 		form := tview.NewForm()
 		//form.SetBackgroundColor(tcell.ColorDarkCyan)
 		var namespaceSelection, podSelection, containerSelection, levelSelection *tview.DropDown
+		var containers []k8s.Container
 		namespaceSelection = tview.NewDropDown()
 		//namespaceSelection.SetBackgroundColor(tcell.ColorLightCyan)
-		namespaceSelection.SetOptions(kc.GetClusterNamespaces(), func(text string, index int) {
+		namespaceSelection.SetOptions(kc.GetClusterNamespaces(), func(namespace string, index int) {
 			//get all pods in selected namespace
-			podSelection.SetOptions(kc.GetPods(text), func(text string, index int) {
-				//get all containers in selected pod
-				containerSelection.SetOptions(kc.GetContainers(text), func(text string, index int) {
+			podSelection.SetOptions(kc.GetPods(namespace), func(pod string, index int) {
+				//get all containers in selected pod, init and ephemeral containers are listed with their kind
+				var err error
+				if containers, err = kc.GetContainers(namespace, pod); err != nil {
+					log.Printf("[red]Error fetching the containers of %s/%s: %v[-]\n", namespace, pod, err)
+				}
+				options := []string{}
+				for _, container := range containers {
+					options = append(options, container.String())
+				}
+				containerSelection.SetOptions(options, func(text string, index int) {
 					//Set level selection
-					levelSelection.SetOptions([]string{"DEBUG_1", "DEBUG_2", "DEBUG_3"}, nil).SetLabel("Level")
+					levelSelection.SetOptions(k8s.DebugLevels, nil).SetLabel("Level")
 				}).SetLabel("Container")
 			}).SetLabel("Pod")
 		}).SetLabel("Namespace")
@@ -151,17 +242,44 @@ func SetDebugLevel(pages *tview.Pages) func() {
 		//podSelection.SetBackgroundColor(tcell.ColorLightCyan)
 		containerSelection = tview.NewDropDown()
 		levelSelection = tview.NewDropDown()
+		// a glob targets every matching pod of the namespace instead of the selected pod
+		podGlob := tview.NewInputField().SetLabel("Pod glob").SetFieldWidth(30)
 
 		form.AddFormItem(namespaceSelection)
 		form.AddFormItem(podSelection)
+		form.AddFormItem(podGlob)
 		form.AddFormItem(containerSelection)
 		form.AddFormItem(levelSelection)
 
 		form.AddButton("Submit", func() {
 			_, namespace := form.GetFormItemByLabel("Namespace").(*tview.DropDown).GetCurrentOption()
 			_, podName := form.GetFormItemByLabel("Pod").(*tview.DropDown).GetCurrentOption()
-			_, containerName := form.GetFormItemByLabel("Container").(*tview.DropDown).GetCurrentOption()
+			containerIndex, _ := form.GetFormItemByLabel("Container").(*tview.DropDown).GetCurrentOption()
+			containerName := ""
+			if containerIndex >= 0 && containerIndex < len(containers) {
+				containerName = containers[containerIndex].Name
+			}
 			_, debugLevel := form.GetFormItemByLabel("Level").(*tview.DropDown).GetCurrentOption()
+			if glob := podGlob.GetText(); glob != "" {
+				log.Printf("Setting Debug Level for %s/%s/%s to %s\n", namespace, glob, containerName, debugLevel)
+				results, err := kc.SetDebugLevels(k8s.DebugTarget{Namespace: namespace, Pods: glob, Container: containerName}, debugLevel)
+				if err != nil {
+					log.Printf("[red]Error setting Debug Level: %v[-]\n", err)
+				}
+				for _, result := range results {
+					if result.Err != nil {
+						log.Printf("[red]%s: %v[-]\n", result.Pod, result.Err)
+					} else {
+						log.Printf("[green]%s: Debug Level set[-]\n", result.Pod)
+					}
+				}
+				if err := recordDebugLevels(namespace, results, debugLevel); err != nil {
+					log.Printf("[red]Error recording the debug levels: %v[-]\n", err)
+				}
+				pages.SwitchToPage("main")
+				pages.RemovePage("modal")
+				return
+			}
 			log.Printf("Setting Debug Level for %s/%s/%s to %s\n", namespace, podName, containerName, debugLevel)
 			if kc.SetDebugLevel(namespace, podName, containerName, debugLevel) {
 				log.Printf("[green]Debug Level set successfully for Container: %s Pod: %s Namespace: %s[-]\n", containerName, podName, namespace)
@@ -176,7 +294,7 @@ func SetDebugLevel(pages *tview.Pages) func() {
 			pages.RemovePage("modal")
 		}).SetButtonsAlign(tview.AlignCenter)
 		form.SetBorder(true).SetTitle("Set Debug Level")
-		modal := createModalForm(pages, form, 13, 80)
+		modal := createModalForm(pages, form, 15, 80)
 		pages.AddPage("modal", modal, true, true)
 	}
 }
@@ -317,6 +435,8 @@ func Alerts(pages *tview.Pages) func() {
 			return
 		}
 		displayAlerts(alertList)
+		incidents := k8s.CorrelateAlerts(alertList, kc.GetPodIssues(), kc.GetNodeIssues())
+		displayIncidents(incidents)
 	}
 }
 
@@ -396,6 +516,24 @@ func displayAlerts(alertList []k8s.Alert) {
 	log.Printf("| %-33s | %-8s | %-24s | %s | %-40s\n", "", "", "", centerText("Total Alerts", 40), strconv.Itoa(len(alertList)))
 	equalFormatter()
 }
+func displayIncidents(incidents []k8s.Incident) {
+	lineFormatter := func() {
+		log.Printf("%s", strings.Repeat("─", 140))
+	}
+
+	lineFormatter()
+	log.Printf("%s", centerText("Correlated Incidents", 140))
+	lineFormatter()
+	for index, incident := range incidents {
+		if len(incident.Pods) == 0 && len(incident.Nodes) == 0 {
+			log.Printf("%3d. [green]%s[-]", index+1, incident.Summary())
+		} else {
+			log.Printf("%3d. [red]%s[-]", index+1, incident.Summary())
+		}
+	}
+	lineFormatter()
+}
+
 func createMetadataPanel(infoUI *testInfoUI) *tview.Table {
 	metadata := tview.NewTable()
 	metadata.SetBorder(true).SetTitle("Cluster Details")
@@ -427,13 +565,16 @@ func createMainLayout(infoUI *testInfoUI, output tview.Primitive, afn_tools *tvi
 	metadata := createMetadataPanel(infoUI)
 
 	kc, _ := k8s.NewK8sClient()
-	config := k8s.GetClustersFromKubeConfig()
+	config, _ := k8s.GetClustersFromKubeConfig()
 	clusters := []string{}
 	for index, _ := range config.Clusters {
 		clusters = append(clusters, index)
 	}
 	handler := func(text string, index int) {
-		kc.SetContext(config, text)
+		if err := kc.SetContext(config, text); err != nil {
+			log.Printf("[red]Error switching to cluster %s: %v[-]\n", text, err)
+			return
+		}
 		infoUI.context.SetText(config.CurrentContext)
 		infoUI.cluster.SetText(text)
 		nodes := kc.GetClusterNodes()
@@ -540,48 +681,114 @@ func centerText(text string, width int) string {
 	return text
 }
 
-func runTests(selectedCommand string) {
-	kc, _ := k8s.NewK8sClient()
-	rl := []models.ResourceCheck{}
-	switch selectedCommand {
-	case HEALTH_K8s:
-		rl = testsuite.CheckK8s(kc.Client)
-		break
-	case HEALTH_INFRA:
-		rl = testsuite.CheckINFRA(kc.Client)
-		break
-	case HEALTH_PAAS:
-		rl = testsuite.CheckPAAS(kc.Client)
-		break
-	case HEALTH_SMF:
-		rl = testsuite.CheckSMF(kc.Client)
-		break
-	case HEALTH_UPF:
-		rl = testsuite.CheckUPF(kc.Client)
-		break
-	case HEALTH_STORAGE:
-		rl = testsuite.CheckStorage(kc.Client)
-		break
-	default:
+// suites are the built-in suites run by the suite buttons
+var suites = map[string]string{
+	HEALTH_K8s:      "k8s",
+	HEALTH_INFRA:    "infra",
+	HEALTH_PAAS:     "paas",
+	HEALTH_SMF:      "smf",
+	HEALTH_UPF:      "upf",
+	HEALTH_STORAGE:  "storage",
+	HEALTH_NETWORK:  "network",
+	HEALTH_SECURITY: "security",
+}
+
+// startRun runs the suite of the command in the background, the run can be paused and resumed with its
+// control and is stopped when the run is cancelled. The journal mode tells which results of the last run
+// are reused.
+func startRun(infoUI *testInfoUI, selectedCommand string, mode healthcheck.JournalMode) {
+	control := healthcheck.NewControl()
+	ctx, cancel := context.WithCancel(context.Background())
+	context.AfterFunc(ctx, control.Stop)
+	infoUI.ctx = ctx
+	infoUI.cancel = cancel
+	infoUI.control = control
+	infoUI.lastCommand = selectedCommand
+	go func() {
+		defer cancel()
+		runTests(selectedCommand, control, mode)
+	}()
+}
+
+// running returns true while a run started from the UI did not finish
+func running(infoUI *testInfoUI) bool {
+	return infoUI.ctx != nil && infoUI.ctx.Err() == nil
+}
+
+func runTests(selectedCommand string, control *healthcheck.Control, mode healthcheck.JournalMode) {
+	suite, found := suites[selectedCommand]
+	if !found {
 		log.Printf("Please select a test to run")
+		return
+	}
+	kc, _ := k8s.NewK8sClient()
+	cfg, _ := loadConfig()
+	checks, err := healthcheck.Suites(suite)
+	if err != nil {
+		log.Printf("[red]%v[-]\n", err)
+		return
+	}
+	runner := healthcheck.NewRunner(kc, cfg, checks...)
+	runner.Control = control
+	runner.Journal = &healthcheck.Journal{File: config.StatePath("journal.json"), Mode: mode}
+	runner.Logf = func(format string, args ...interface{}) {
+		log.Printf("[red]%s[-]\n", fmt.Sprintf(format, args...))
 	}
 
 	log.Printf("| %-5s | %-150s | %-7s |\n", "─────", "──────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────", "──────")
 	log.Printf("| %s | %s | %s  |\n", centerText("No.", 5), centerText("Test Summary", 150), centerText("Result", 7))
 	log.Printf("| %-5s | %-150s | %-7s |\n", "─────", "──────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────", "──────")
 
-	status := ""
-	for index, resc := range rl {
-		if resc.Status {
-			status = "[:green::]PASS[:-::]"
-		} else {
-			status = "[:red::]FAIL[:-::]"
+	index := 0
+	runner.Progress = func(checks []models.CheckResult, result []models.Finding) {
+		for _, check := range checks {
+			index++
+			details, status := check.Details, ""
+			switch check.Result {
+			case models.ResultPass:
+				status = "[:green::]PASS[:-::]"
+			case models.ResultSkipped:
+				status = "[:blue::]SKIP[:-::]"
+			case models.ResultError:
+				status = "[:yellow::]ERR [:-::]"
+				details = fmt.Sprintf("%s: %s", check.Details, check.Cause)
+			default:
+				status = "[:red::]FAIL[:-::]"
+			}
+			if check.Cached {
+				details += " (reused)"
+			}
+			log.Printf("| %s | %-150s | %-7s %s|\n", centerText(strconv.Itoa(index), 5), details, status, "   ")
+			log.Printf("| %-5s | %-150s | %-7s |\n", "─────", "──────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────", "──────")
 		}
-		log.Printf("| %s | %-150s | %-7s %s|\n", centerText(strconv.Itoa(index+1), 5), resc.Details, status, "   ")
-		log.Printf("| %-5s | %-150s | %-7s |\n", "─────", "──────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────", "──────")
 	}
-	log.Printf("| %-5s | %s | %-7s |\n", "", centerText("Total Tests", 150), strconv.Itoa(len(rl)))
+	results, result := runner.Collect()
+	log.Printf("| %-5s | %s | %-7s |\n", "", centerText("Total Tests", 150), strconv.Itoa(len(results)))
 	log.Printf("| %-5s | %-150s | %-7s |\n", "─────", "──────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────", "──────")
+
+	displayScorecard(findings.NewScorecard(result, kc.GetClusterNamespaces()))
+}
+
+func displayScorecard(scorecard findings.Scorecard) {
+	scoreColor := func(score int) string {
+		if score >= 90 {
+			return "green"
+		} else if score >= 60 {
+			return "yellow"
+		}
+		return "red"
+	}
+
+	log.Printf("| %-40s | %-7s | %-8s | %-7s |\n", "────────────────────────────────────────", "───────", "────────", "───────")
+	log.Printf("| %-40s | [%s]%-7d[-] | %-8s | %-7s |\n", "Cluster Health Score", scoreColor(scorecard.Cluster.Score), scorecard.Cluster.Score, "Critical", "Warning")
+	log.Printf("| %-40s | %-7s | %-8s | %-7s |\n", "────────────────────────────────────────", "───────", "────────", "───────")
+	for _, score := range scorecard.Namespaces {
+		if score.Critical == 0 && score.Warning == 0 {
+			continue
+		}
+		log.Printf("| %-40s | [%s]%-7d[-] | %-8d | %-7d |\n", score.Name, scoreColor(score.Score), score.Score, score.Critical, score.Warning)
+	}
+	log.Printf("| %-40s | %-7s | %-8s | %-7s |\n", "────────────────────────────────────────", "───────", "────────", "───────")
 }
 
 func sendCommand(pages *tview.Pages, infoUI *testInfoUI, selectedCommand string) func() {
@@ -590,18 +797,8 @@ func sendCommand(pages *tview.Pages, infoUI *testInfoUI, selectedCommand string)
 			stop(infoUI)()
 			pages.SwitchToPage("main")
 			clearLogPanel(pages)
-			runTests(selectedCommand)
 			pages.RemovePage("modal")
-			ctx, cancel := context.WithCancel(context.Background())
-			infoUI.ctx = ctx
-			infoUI.cancel = cancel
-			go func() {
-				defer func() {
-					cancel()
-					infoUI.ctx = nil
-				}()
-				fmt.Println("In sendCommand")
-			}()
+			startRun(infoUI, selectedCommand, healthcheck.JournalRecord)
 		}
 
 		cancelFunc := func() {
@@ -697,9 +894,22 @@ func DisplayResourceUsageReport(pages *tview.Pages) func() {
 			log.Printf("────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────────")
 		}
 		clearLogPanel(pages)
-		kc, _ := k8s.NewK8sClient()
-		kc.GetResourceUsageReport()
-		r := kc.GetResourceUsageReport()
+		kc, err := k8s.NewK8sClient()
+		if err != nil {
+			log.Printf("[red]%v", err)
+			return
+		}
+		r, err := kc.GetResourceUsageReport()
+		if err != nil {
+			log.Printf("[red]Error fetching resource usage: %v", err)
+			return
+		}
+		for _, finding := range r.Findings {
+			log.Printf("[yellow]%s", finding.Message)
+		}
+		if !r.MetricsAvailable {
+			log.Printf("[yellow]Showing requests and limits only")
+		}
 
 		// log.Printf("| %s | %s | %s\n", centerText("Pod", 33), centerText("Container", 40), centerText("CPU/Memory", 40))
 
@@ -709,15 +919,50 @@ func DisplayResourceUsageReport(pages *tview.Pages) func() {
 			equalFormatter()
 			for _, containerUsage := range res.ContainerUsages {
 				log.Printf("%s", centerText(fmt.Sprint("Container: ", containerUsage.Name), 140))
+				if !containerUsage.HasMetrics {
+					log.Printf("| Memory : request %s limit %s", quantityOrNone(containerUsage.Requests, v1.ResourceMemory), quantityOrNone(containerUsage.Limits, v1.ResourceMemory))
+					log.Printf("| CPU : request %s limit %s", quantityOrNone(containerUsage.Requests, v1.ResourceCPU), quantityOrNone(containerUsage.Limits, v1.ResourceCPU))
+					continue
+				}
 				log.Printf("| Memory : %s", createProgressBarMemory(containerUsage.MemoryUsage, 10))
 				log.Printf("| CPU : %s", createProgressBarCPU(containerUsage.CPUUsage, 100))
 
 			}
 			equalFormatter()
 		}
+
+		cfg, err := loadConfig()
+		if err != nil || !cfg.Cost.Configured() {
+			return
+		}
+		r.EstimateCost(cfg.Cost)
+		displayCostReport(r.Cost)
+	}
+}
+
+// displayCostReport logs the estimated monthly cost per namespace and the overprovisioned workloads
+func displayCostReport(cost *k8s.CostReport) {
+	log.Printf("%s", centerText("Estimated monthly cost of requests", 140))
+	for _, namespace := range cost.Namespaces {
+		log.Printf("| %-40s %8.2f cores %8.2f GB %10.2f %s", namespace.Namespace, namespace.CPUCores, namespace.MemoryGB, namespace.MonthlyCost, cost.Currency)
+	}
+	if len(cost.Overprovisioned) == 0 {
+		return
+	}
+	log.Printf("%s", centerText("Overprovisioned workloads", 140))
+	for _, workload := range cost.Overprovisioned {
+		log.Printf("[yellow]| %s/%s uses %.0f%% cpu and %.0f%% memory of its requests, %.2f of %.2f %s per month unused", workload.Namespace, workload.Workload, workload.CPUPercent, workload.MemoryPercent, workload.MonthlyWaste, workload.MonthlyCost, cost.Currency)
 	}
 }
 
+// quantityOrNone formats a resource quantity, or "none" when it is not set
+func quantityOrNone(resources v1.ResourceList, name v1.ResourceName) string {
+	if quantity, found := resources[name]; found {
+		return quantity.String()
+	}
+	return "none"
+}
+
 func createProgressBarCPU(percentage float64, barLength int) string {
 	// Determine how many blocks to fill based on the percentage
 	filledLength := int((percentage / 100) * float64(barLength))
@@ -758,6 +1003,30 @@ func createProgressBarMemory(percentage float64, barLength int) string {
 }
 
 func main() {
+	flag.Usage = usage
+	k8s.AddFlags(flag.CommandLine)
+	flag.Parse()
+	// config lint must report the problems of a config that does not load
+	if flag.Arg(0) != "config" {
+		if _, err := loadConfig(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args()))
+	}
+
+	// fail with a readable error instead of a panic deep inside the UI
+	if _, err := k8s.GetClustersFromKubeConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if _, err := k8s.NewK8sClient(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		os.Exit(1)
+	}
+
 	app := createApplication()
 
 	if err := app.Run(); err != nil {
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/migration"
+	"healthctl/pkg/models"
+)
+
+func migrateCommand(args []string) int {
+	if len(args) == 0 || args[0] != "plan" {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl migrate plan -namespace <namespace> -target-context <context> [flags]")
+		return 2
+	}
+	return migratePlanCommand(args[1:])
+}
+
+// migratePlanCommand inventories a namespace of the current cluster and verifies the target cluster has what
+// it needs. It exits with 1 when there are blockers.
+func migratePlanCommand(args []string) int {
+	fs := flag.NewFlagSet("migrate plan", flag.ExitOnError)
+	namespace := fs.String("namespace", "", "namespace to migrate")
+	targetContext := fs.String("target-context", "", "kubeconfig context of the target cluster")
+	targetNamespace := fs.String("target-namespace", "", "namespace in the target cluster, defaults to -namespace")
+	format := fs.String("o", "text", "output format, text or json")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl migrate plan -namespace <namespace> -target-context <context> [-target-namespace <namespace>] [-o text|json]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *namespace == "" || *targetContext == "" || (*format != "text" && *format != "json") {
+		fs.Usage()
+		return 2
+	}
+	if *targetNamespace == "" {
+		*targetNamespace = *namespace
+	}
+
+	if _, err := loadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	kc, err := k8s.NewK8sClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		return 2
+	}
+	target, err := k8s.NewK8sClientForContext(*targetContext)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating kubernetes client for %s: %v\n", *targetContext, err)
+		return 2
+	}
+	inventory, err := migration.Collect(kc, *namespace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error inventorying %s: %v\n", *namespace, err)
+		return 2
+	}
+	findings, err := migration.Verify(target, inventory, *targetNamespace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error verifying %s: %v\n", *targetContext, err)
+		return 2
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(struct {
+			Inventory migration.Inventory `json:"inventory"`
+			Target    string              `json:"target"`
+			Findings  []models.Finding    `json:"findings"`
+		}{inventory, *targetContext + "/" + *targetNamespace, findings})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	} else {
+		printMigrationPlan(inventory, *targetContext, *targetNamespace, findings)
+	}
+	if migration.Blockers(findings) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func printMigrationPlan(inventory migration.Inventory, targetContext, targetNamespace string, findings []models.Finding) {
+	fmt.Printf("Namespace %s: %d workloads, %d config maps, %d secrets, %d claims, %d external dependencies\n", inventory.Namespace,
+		len(inventory.Workloads), len(inventory.ConfigMaps), len(inventory.Secrets), len(inventory.Claims), len(inventory.Dependencies))
+	for _, workload := range inventory.Workloads {
+		replicas := ""
+		if workload.Replicas != nil {
+			replicas = fmt.Sprintf(" x%d", *workload.Replicas)
+		}
+		fmt.Printf("  %s%s %s\n", workload.Resource, replicas, strings.Join(workload.Images, ", "))
+	}
+	for _, claim := range inventory.Claims {
+		fmt.Printf("  PersistentVolumeClaim %s %s %s %s\n", claim.Name, claim.Size, valueOrNone(claim.StorageClass), strings.Join(claim.AccessModes, ","))
+	}
+	usage := []string{}
+	for name, quantity := range inventory.Usage {
+		usage = append(usage, fmt.Sprintf("%s=%s", name, quantity))
+	}
+	if len(usage) > 0 {
+		sort.Strings(usage)
+		fmt.Printf("  quota usage %s\n", strings.Join(usage, " "))
+	}
+
+	fmt.Printf("\nTarget %s/%s:\n", targetContext, targetNamespace)
+	for _, severity := range []models.Severity{models.SeverityCritical, models.SeverityWarning, models.SeverityInfo} {
+		for _, finding := range findings {
+			if finding.Severity == severity {
+				fmt.Printf("  [%s] %s: %s\n", finding.Severity, finding.Resource, finding.Message)
+			}
+		}
+	}
+	if blockers := migration.Blockers(findings); blockers > 0 {
+		fmt.Printf("\n%d blockers, resolve them before the migration\n", blockers)
+	} else {
+		fmt.Println("\nNo blockers")
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/fleet"
+	"healthctl/pkg/models"
+	"healthctl/pkg/nodeagent"
+)
+
+// nodeAgentTimeout limits a report call of the node agent
+const nodeAgentTimeout = 30 * time.Second
+
+// nodeAgentCommand runs the host checks of the node every interval and reports them to serve
+func nodeAgentCommand(args []string) int {
+	if len(args) > 0 && args[0] == "manifest" {
+		return nodeAgentManifestCommand(args[1:])
+	}
+	fs := flag.NewFlagSet("node-agent", flag.ExitOnError)
+	server := fs.String("server", "", "host:port of the node agent listener of serve, overrides nodeAgent.server of the config")
+	ca := fs.String("ca", "", "CA of the certificate of serve, overrides nodeAgent.tls.ca of the config")
+	once := fs.Bool("once", false, "run the checks once, print the results and exit without reporting")
+	fs.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	settings := cfg.NodeAgent
+	if *server != "" {
+		settings.Server = *server
+	}
+	if settings.Token == "" {
+		settings.Token = os.Getenv("HEALTHCTL_NODE_AGENT_TOKEN")
+	}
+	if *ca != "" {
+		if settings.TLS == nil {
+			settings.TLS = &config.AgentTLS{}
+		}
+		settings.TLS.CA = *ca
+	}
+	node := os.Getenv("NODE_NAME")
+	if node == "" {
+		if node, err = os.Hostname(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	host, err := nodeagent.NewHost(node, settings)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *once {
+		for _, check := range host.Run() {
+			text := check.Details
+			switch {
+			case check.Error != "":
+				text = check.Error
+			case check.Skipped != "":
+				text = check.Skipped
+			}
+			fmt.Printf("%-20s %-8s %s\n", check.Label, check.Result(), text)
+			for _, finding := range check.Findings {
+				fmt.Printf("%-20s %-8s %s\n", "", finding.Severity, finding.Message)
+			}
+		}
+		if sample := host.SampleNetwork(); sample != nil {
+			if sample.Error != "" {
+				fmt.Printf("%-20s %-8s %s\n", nodeagent.LabelNetwork, models.ResultError, sample.Error)
+			}
+			for _, flow := range sample.Flows {
+				fmt.Printf("%-20s %-8d %s -> %s:%d\n", flow.Kind, flow.Count, flow.Local, flow.Remote, flow.Port)
+			}
+		}
+		return 0
+	}
+
+	if settings.Server == "" {
+		fmt.Fprintln(os.Stderr, "no server configured, set nodeAgent.server of the config or -server")
+		return 2
+	}
+	interval := defaultDashboardInterval
+	if settings.Interval != "" {
+		if interval, err = time.ParseDuration(settings.Interval); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid nodeAgent.interval %q: %v\n", settings.Interval, err)
+			return 2
+		}
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if settings.TLS != nil {
+		if tlsConfig, err = fleet.ClientTLSConfig(*settings.TLS); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	client, err := nodeagent.Dial(settings.Server, settings.Token, tlsConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer client.Close()
+
+	log.Printf("healthctl node agent of %s reporting to %s every %s", node, settings.Server, interval)
+	for {
+		report := nodeagent.NodeReport{Node: node, Time: time.Now(), Checks: host.Run(), Network: host.SampleNetwork()}
+		ctx, cancel := context.WithTimeout(context.Background(), nodeAgentTimeout)
+		if err := client.Report(ctx, report); err != nil {
+			log.Printf("node agent report failed: %v", err)
+		}
+		cancel()
+		time.Sleep(interval)
+	}
+}
+
+// nodeAgentManifestCommand prints the DaemonSet of the node agent
+func nodeAgentManifestCommand(args []string) int {
+	fs := flag.NewFlagSet("node-agent manifest", flag.ExitOnError)
+	namespace := fs.String("namespace", "healthctl", "namespace of the DaemonSet")
+	image := fs.String("image", "healthctl:latest", "image of healthctl")
+	server := fs.String("server", "healthctl.healthctl.svc:9090", "host:port of the node agent listener of serve")
+	fs.Parse(args)
+
+	manifest, err := nodeagent.Manifest(*namespace, *image, *server)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Print(manifest)
+	return 0
+}
+
+// newNodeAgents starts the gRPC listener of the node agents, it uses the certificate of serve.tls and
+// requires a token
+func (s *server) newNodeAgents(cfg *config.ServeNodeAgents) (*nodeagent.Server, error) {
+	staleAfter := nodeagent.DefaultStaleAfter
+	if cfg.StaleAfter != "" {
+		var err error
+		if staleAfter, err = time.ParseDuration(cfg.StaleAfter); err != nil {
+			return nil, fmt.Errorf("invalid serve.nodeAgents.staleAfter %q: %v", cfg.StaleAfter, err)
+		}
+	}
+	address := cfg.Listen
+	if address == "" {
+		address = ":9090"
+	}
+	if s.cfg.Serve.TLS == nil {
+		return nil, fmt.Errorf("serve.nodeAgents requires the certificate of serve.tls")
+	}
+	tlsConfig, err := fleet.ServerTLSConfig(*s.cfg.Serve.TLS)
+	if err != nil {
+		return nil, err
+	}
+	agents, err := nodeagent.NewServer(cfg.Token, staleAfter, s.kc.Client)
+	if err != nil {
+		return nil, fmt.Errorf("serve.nodeAgents: %v", err)
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("node agent listener: %v", err)
+	}
+	agents.Network = cfg.Network
+	go func() {
+		log.Printf("healthctl accepting node agent reports on %s", address)
+		log.Printf("node agent listener: %v", agents.Serve(listener, tlsConfig))
+	}()
+	return agents, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/operator"
+)
+
+// operatorResync is how often serve lists the HealthChecks for the ones that are due
+const operatorResync = 30 * time.Second
+
+// operatorBatch limits the HealthChecks reconciled per resync, the others wait for the next one so the
+// dashboard runs in between
+const operatorBatch = 5
+
+func operatorCommand(args []string) int {
+	if len(args) != 1 || args[0] != "crd" {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl operator crd")
+		return 2
+	}
+	fmt.Print(operator.CRD)
+	return 0
+}
+
+// newController returns the controller of the HealthChecks of the cluster serve runs in
+func (s *server) newController(cfg *config.ServeOperator) (*operator.Controller, error) {
+	controller := &operator.Controller{
+		Client:      s.kc,
+		Config:      s.cfg,
+		Cluster:     s.cluster,
+		Namespaces:  cfg.Namespaces,
+		Suites:      cfg.Suites,
+		StateDir:    config.StatePath("operator"),
+		Maintenance: s.maintenance,
+		Logf:        log.Printf,
+	}
+	if cfg.Interval != "" {
+		interval, err := time.ParseDuration(cfg.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid serve.operator.interval %q: %v", cfg.Interval, err)
+		}
+		controller.Interval = interval
+	}
+	if cfg.MinInterval != "" {
+		interval, err := time.ParseDuration(cfg.MinInterval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid serve.operator.minInterval %q: %v", cfg.MinInterval, err)
+		}
+		controller.MinInterval = interval
+	}
+	return controller, nil
+}
+
+// runOperator reconciles the HealthChecks that are due, one at a time like the dashboard runs and at most
+// operatorBatch per resync
+func (s *server) runOperator(controller *operator.Controller) {
+	ticker := time.NewTicker(operatorResync)
+	defer ticker.Stop()
+	for ; ; <-ticker.C {
+		due, err := controller.Due(time.Now())
+		if err != nil {
+			log.Printf("operator: %v", err)
+			continue
+		}
+		for _, hc := range due[:min(len(due), operatorBatch)] {
+			s.mutex.Lock()
+			err := controller.Reconcile(hc)
+			s.mutex.Unlock()
+			if err != nil {
+				log.Printf("operator: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"healthctl/pkg/bundle"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/profiles"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// profileCommand captures pprof profiles of Go services on demand into the profile store, where the
+// evidence and support bundles find them, and optionally into a tar.gz
+func profileCommand(args []string) int {
+	fs := flag.NewFlagSet("profile", flag.ExitOnError)
+	namespace := fs.String("namespace", "", "namespace of the pods")
+	selector := fs.String("selector", "", "label selector of the pods, e.g. app=api")
+	pods := fs.String("pods", "", "glob of the pod names, e.g. api-*")
+	regex := fs.String("regex", "", "regular expression the pod names must match, instead of -pods")
+	port := fs.Int("port", 0, fmt.Sprintf("pprof port of pods without the %s annotation or a container port named pprof, defaults to %d", k8s.PprofPortAnnotation, k8s.DefaultPprofPort))
+	names := fs.String("profiles", strings.Join(k8s.PprofProfiles, ","), "comma separated profiles to capture: "+strings.Join(k8s.PprofProfiles, ", "))
+	cpu := fs.Duration("cpu", k8s.DefaultCPUProfile, "how long the CPU profile is recorded")
+	file := fs.String("f", "", "also write the profiles into this tar.gz")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl profile -namespace <namespace> [-selector <selector>] [-pods <glob> | -regex <regex>] [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	selected := strings.Split(*names, ",")
+	for _, name := range selected {
+		if !slices.Contains(k8s.PprofProfiles, name) {
+			fmt.Fprintf(os.Stderr, "Unknown profile %q\n", name)
+			fs.Usage()
+			return 2
+		}
+	}
+	if *namespace == "" || *cpu <= 0 {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if k8s.ReadOnly() {
+		fmt.Fprintln(os.Stderr, "profiles can not be captured in read-only mode, they need a port-forward")
+		return 2
+	}
+	kc, err := k8s.NewK8sClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		return 2
+	}
+	targets, err := kc.DebugTargetPods(k8s.DebugTarget{Namespace: *namespace, Selector: *selector, Pods: *pods, Regex: *regex})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(targets) == 0 {
+		fmt.Fprintf(os.Stderr, "No running pod matches in %s\n", *namespace)
+		return 1
+	}
+
+	fmt.Printf("Capturing %s profiles of %d pods\n", strings.Join(selected, ", "), len(targets))
+	captured := time.Now()
+	saved := make([][]profiles.File, len(targets))
+	errs := make([]error, len(targets))
+	k8s.ExecEach(len(targets), func(i int) {
+		pod := targets[i]
+		result, err := kc.CaptureProfiles(pod, *port, selected, *cpu)
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		saved[i], errs[i] = saveProfiles(pod, captured, result, cfg.Checks.Profiles.Keep)
+	})
+	return reportProfiles(targets, saved, errs, *file, captured)
+}
+
+// saveProfiles stores the profiles or diagnostics captured from a pod and prunes its old captures, the
+// errors of single profiles are returned together
+func saveProfiles(pod v1.Pod, captured time.Time, result []k8s.Profile, keep int) ([]profiles.File, error) {
+	saved, err := profiles.Save(pod.Namespace, pod.Name, captured, result)
+	if err != nil {
+		return saved, err
+	}
+	if err := profiles.Prune(pod.Namespace, pod.Name, keep); err != nil {
+		return saved, err
+	}
+	failed := []string{}
+	for _, profile := range result {
+		if profile.Err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", profile.Name, profile.Err))
+		}
+	}
+	if len(failed) > 0 {
+		return saved, fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return saved, nil
+}
+
+// reportProfiles prints the stored files and the error of every pod and writes the files into a tar.gz
+// when file is set. It returns 1 when a pod failed.
+func reportProfiles(pods []v1.Pod, saved [][]profiles.File, errs []error, file string, captured time.Time) int {
+	code := 0
+	all := []profiles.File{}
+	for i, pod := range pods {
+		for _, profile := range saved[i] {
+			fmt.Printf("%-50s %-9s %s\n", pod.Name, profile.Name, profile.Path)
+		}
+		if errs[i] != nil {
+			code = 1
+			fmt.Printf("%-50s failed: %v\n", pod.Name, errs[i])
+		}
+		all = append(all, saved[i]...)
+	}
+	if file != "" && len(all) > 0 {
+		name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(file), ".gz"), ".tar")
+		if err := bundle.WriteProfiles(file, name, captured, all); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing the profiles:", err)
+			return 2
+		}
+		fmt.Printf("%d files written to %s\n", len(all), file)
+	}
+	return code
+}
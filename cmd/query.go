@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/query"
+	"healthctl/pkg/results"
+	"healthctl/pkg/testsuite"
+)
+
+// queryCommand runs a SELECT statement over the collected runs, findings, usage samples and pod startups
+func queryCommand(args []string) int {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	storeDir := fs.String("store", config.StatePath("results"), "results store of healthctl serve")
+	format := fs.String("o", "text", "output format, text, csv or json")
+	listTables := fs.Bool("tables", false, "list the tables and their columns")
+	fs.Parse(args)
+
+	if *listTables {
+		for _, definition := range query.Definitions {
+			fmt.Printf("%s: %s\n  %s\n", definition.Name, definition.Description, strings.Join(definition.Columns, ", "))
+		}
+		return 0
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, `Usage: healthctl query [-o text|csv|json] "SELECT ... FROM runs|findings|usage|startups ..."`)
+		return 2
+	}
+	if *format != "text" && *format != "csv" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown output format %q, use text, csv or json\n", *format)
+		return 2
+	}
+
+	tables, err := query.Load(query.Sources{
+		Store:       results.NewStore(*storeDir),
+		Cluster:     k8s.CurrentCluster(),
+		UsageFile:   testsuite.UsageFile(),
+		StartupFile: testsuite.StartupFile(),
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading collected data:", err)
+		return 2
+	}
+	result, err := query.Run(fs.Arg(0), tables)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	switch *format {
+	case "json":
+		rows := []map[string]query.Value{}
+		for _, row := range result.Rows {
+			object := make(map[string]query.Value)
+			for i, column := range result.Columns {
+				object[column] = row[i]
+			}
+			rows = append(rows, object)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(rows)
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write(result.Columns)
+		for _, row := range result.Rows {
+			w.Write(formatRow(row))
+		}
+		w.Flush()
+		err = w.Error()
+	default:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.ToUpper(strings.Join(result.Columns, "\t")))
+		for _, row := range result.Rows {
+			fmt.Fprintln(w, strings.Join(formatRow(row), "\t"))
+		}
+		err = w.Flush()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
+
+func formatRow(row []query.Value) []string {
+	cells := []string{}
+	for _, value := range row {
+		cells = append(cells, query.Format(value))
+	}
+	return cells
+}
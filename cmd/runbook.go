@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+
+	"healthctl/pkg/audit"
+	"healthctl/pkg/config"
+	"healthctl/pkg/findings"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+	"healthctl/pkg/report"
+)
+
+func runbookCommand(args []string) int {
+	fs := flag.NewFlagSet("runbook", flag.ExitOnError)
+	suites := fs.String("suite", "all", "suites to run to find the finding, ignored with -report")
+	reportFile := fs.String("report", "", "json report to read the finding from instead of running the suites")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl runbook [flags] <finding-id>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	id := fs.Arg(0)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	kc, err := k8s.NewK8sClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		return 2
+	}
+
+	finding, err := findFinding(kc, *suites, *reportFile, id)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	runbook, found := findings.RunbookFor(cfg.Runbooks, finding)
+	if !found {
+		fmt.Fprintf(os.Stderr, "No runbook configured for %s findings of %s\n", finding.Reason, finding.Check)
+		return 2
+	}
+	if k8s.ReadOnly() {
+		// the steps are shell commands and exec sessions the client can not vet, they are shown but not run
+		fmt.Printf("Runbook %s for %s, not run in read-only mode:\n", runbook.Name, finding.Resource)
+		for i, step := range runbook.Steps {
+			fmt.Printf("Step %d/%d: %s\n", i+1, len(runbook.Steps), step.Description)
+		}
+		return 2
+	}
+
+	fmt.Printf("Runbook %s for %s: %s\n", runbook.Name, finding.Resource, finding.Message)
+	in := bufio.NewReader(os.Stdin)
+	for i, step := range runbook.Steps {
+		fmt.Printf("\nStep %d/%d: %s\n", i+1, len(runbook.Steps), step.Description)
+		rendered, err := renderStep(step, finding)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error rendering step:", err)
+			return 2
+		}
+		fmt.Printf("  %s\n", rendered)
+
+		switch confirm(in, "Run this step? [y]es/[s]kip/[q]uit: ", "ysq") {
+		case "s":
+			recordStep(runbook, finding, step, "skipped", "", nil)
+			continue
+		case "q":
+			recordStep(runbook, finding, step, "aborted", "", nil)
+			fmt.Println("Runbook aborted")
+			return 1
+		}
+
+		output, err := rendered.run(kc)
+		fmt.Print(output)
+		recordStep(runbook, finding, step, "run", output, err)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Step failed:", err)
+			if confirm(in, "Continue with the next step? [y]es/[q]uit: ", "yq") != "y" {
+				return 1
+			}
+		}
+	}
+	fmt.Println("\nRunbook completed")
+	return 0
+}
+
+// findFinding looks the finding up in a json report, or runs the suites to get its current state
+func findFinding(kc *k8s.K8sClient, suites, reportFile, id string) (models.Finding, error) {
+	var result []models.Finding
+	if reportFile != "" {
+		file, err := os.Open(reportFile)
+		if err != nil {
+			return models.Finding{}, err
+		}
+		defer file.Close()
+		r, err := report.Decode(file)
+		if err != nil {
+			return models.Finding{}, fmt.Errorf("parsing report %s: %v", reportFile, err)
+		}
+		result = r.Findings
+	} else {
+		var err error
+		_, result, err = collectFindings(kc, suites)
+		if err != nil {
+			return models.Finding{}, err
+		}
+	}
+	for _, finding := range result {
+		if finding.ID == id {
+			return finding, nil
+		}
+	}
+	return models.Finding{}, fmt.Errorf("finding %s is not reported", id)
+}
+
+// renderedStep is a runbook step with the finding filled in, pod is empty for local commands
+type renderedStep struct {
+	namespace string
+	pod       string
+	container string
+	command   string
+}
+
+func (s renderedStep) String() string {
+	if s.pod == "" {
+		return "$ " + s.command
+	}
+	target := s.namespace + "/" + s.pod
+	if s.container != "" {
+		target += "/" + s.container
+	}
+	return fmt.Sprintf("%s$ %s", target, s.command)
+}
+
+func (s renderedStep) run(kc *k8s.K8sClient) (string, error) {
+	if s.pod == "" {
+		output, err := exec.Command("sh", "-c", s.command).CombinedOutput()
+		return string(output), err
+	}
+	stdout, stderr, err := kc.ExecuteRemoteCommand(s.namespace, s.pod, s.container, s.command)
+	return stdout + stderr, err
+}
+
+// renderStep fills the finding into the templates of the step
+func renderStep(step config.RunbookStep, finding models.Finding) (renderedStep, error) {
+	render := func(text string) (string, error) {
+		tmpl, err := template.New("step").Option("missingkey=error").Parse(text)
+		if err != nil {
+			return "", err
+		}
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, finding); err != nil {
+			return "", err
+		}
+		return out.String(), nil
+	}
+
+	if step.Exec == "" {
+		command, err := render(step.Command)
+		return renderedStep{command: command}, err
+	}
+	rendered := renderedStep{namespace: step.Namespace, pod: step.Pod, container: step.Container}
+	if rendered.pod == "" {
+		if finding.Resource.Kind != "Pod" {
+			return rendered, fmt.Errorf("exec step without pod for a %s finding", finding.Resource.Kind)
+		}
+		rendered.namespace, rendered.pod = finding.Resource.Namespace, finding.Resource.Name
+	}
+	if rendered.namespace == "" {
+		rendered.namespace = finding.Resource.Namespace
+	}
+	rendered.command = step.Exec
+	var err error
+	for _, field := range []*string{&rendered.namespace, &rendered.pod, &rendered.container, &rendered.command} {
+		if *field, err = render(*field); err != nil {
+			return rendered, err
+		}
+	}
+	return rendered, nil
+}
+
+func recordStep(runbook config.Runbook, finding models.Finding, step config.RunbookStep, result, output string, err error) {
+	entry := audit.Entry{
+		Action: "runbook " + result,
+		Target: fmt.Sprintf("%s %s", finding.ID, finding.Resource),
+		Detail: fmt.Sprintf("%s: %s", runbook.Name, step.Description),
+		Output: output,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if err := audit.Record(entry); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing audit log:", err)
+	}
+}
+
+// confirm asks until one of the options is answered, end of input quits
+func confirm(in *bufio.Reader, prompt, options string) string {
+	for {
+		fmt.Print(prompt)
+		answer, err := in.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "" && strings.Contains(options, answer[:1]) {
+			return answer[:1]
+		}
+		if err != nil {
+			return "q"
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"healthctl/pkg/auth"
+	"healthctl/pkg/config"
+	"healthctl/pkg/events"
+	"healthctl/pkg/findings"
+	"healthctl/pkg/fleet"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/maintenance"
+	"healthctl/pkg/models"
+	"healthctl/pkg/nodeagent"
+	"healthctl/pkg/notify"
+	"healthctl/pkg/output"
+	"healthctl/pkg/report"
+	"healthctl/pkg/results"
+	"healthctl/pkg/ticket"
+	"healthctl/pkg/tsdb"
+	"healthctl/pkg/web"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// maxWebhookBody limits the size of inbound notifications
+const maxWebhookBody = 1 << 20
+
+// server is the state of healthctl serve, suite runs are serialized
+type server struct {
+	kc       *k8s.K8sClient
+	cfg      *config.Config
+	cluster  string
+	clusters []string
+	clients  map[string]*k8s.K8sClient
+	store    *results.Store
+	trigger  chan string
+	tickets  *ticket.Syncer
+	notifier *notify.DeltaNotifier
+	auth     *auth.Authenticator
+	outputs  []*output.Output
+	// exporters push the metrics of every report to time series databases
+	exporters []*tsdb.Exporter
+	// nodeAgents keeps the reports of the node agents, they are checked with the cluster serve runs in
+	nodeAgents *nodeagent.Server
+	// events records the findings of the clusters serve checks itself as events on their objects
+	events *events.Recorder
+	// agentTimeout is how long an agent may not report before its cluster is stale
+	agentTimeout time.Duration
+	// notifySelector selects the clusters whose reports are notified about
+	notifySelector labels.Selector
+	// maintenance mutes the notifications and tickets of clusters and namespaces in maintenance
+	maintenance *maintenance.Calendar
+	mutex       sync.Mutex
+	// partial are the reports of the shards of agent clusters until every shard reported
+	partial      map[string][]report.Report
+	partialMutex sync.Mutex
+	// reporting serializes processReport per cluster, its ticket and notification files are read and
+	// rewritten by every report
+	reporting      map[string]*sync.Mutex
+	reportingMutex sync.Mutex
+}
+
+func serveCommand(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", "", "address to listen on, overrides serve.listen of the config, defaults to :8080")
+	fs.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if cfg.Serve.Slack != nil && cfg.Serve.Slack.SigningSecret == "" {
+		fmt.Fprintln(os.Stderr, "serve.slack requires the signingSecret of the slack app")
+		return 2
+	}
+	kc, err := k8s.NewK8sClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		return 2
+	}
+	interval := defaultDashboardInterval
+	if cfg.Serve.Interval != "" {
+		if interval, err = time.ParseDuration(cfg.Serve.Interval); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid serve.interval %q: %v\n", cfg.Serve.Interval, err)
+			return 2
+		}
+	}
+	agentTimeout := defaultAgentTimeout
+	if cfg.Serve.AgentTimeout != "" {
+		if agentTimeout, err = time.ParseDuration(cfg.Serve.AgentTimeout); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid serve.agentTimeout %q: %v\n", cfg.Serve.AgentTimeout, err)
+			return 2
+		}
+	}
+	notifySelector, err := labels.Parse(cfg.Serve.NotifySelector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid serve.notifySelector %q: %v\n", cfg.Serve.NotifySelector, err)
+		return 2
+	}
+	s := &server{
+		kc:           kc,
+		cfg:          cfg,
+		cluster:      kc.GetCurrentCluster(),
+		clients:      make(map[string]*k8s.K8sClient),
+		partial:      make(map[string][]report.Report),
+		reporting:    make(map[string]*sync.Mutex),
+		store:        results.NewStore(config.StatePath("results")),
+		trigger:      make(chan string, 16),
+		agentTimeout: agentTimeout,
+
+		notifySelector: notifySelector,
+	}
+	if s.maintenance, err = maintenance.New(cfg.Maintenance); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if s.auth, err = auth.New(cfg.Serve.Auth); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if s.auth == nil {
+		log.Printf("serve.auth is not configured, the API is read-only")
+	}
+	tracker, err := ticket.NewTracker(cfg.Tickets)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if tracker != nil {
+		if s.tickets, err = ticket.NewSyncer(tracker, cfg.Tickets); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		s.tickets.Maintenance = s.maintenance
+	}
+	if cfg.Serve.Notify && cfg.Notifier.Slack != nil {
+		s.notifier = &notify.DeltaNotifier{Slack: cfg.Notifier.Slack, Reminder: notify.DefaultReminder, Maintenance: s.maintenance}
+		if cfg.Serve.NotifyReminder != "" {
+			if s.notifier.Reminder, err = time.ParseDuration(cfg.Serve.NotifyReminder); err != nil {
+				fmt.Fprintf(os.Stderr, "invalid serve.notifyReminder %q: %v\n", cfg.Serve.NotifyReminder, err)
+				return 2
+			}
+		}
+	}
+	if s.outputs, err = output.FromConfig(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	s.exporters = tsdb.FromConfig(cfg)
+	if s.events, err = events.New(cfg.Events); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if s.cluster == "" {
+		// running in the cluster without a kubeconfig
+		s.cluster = "local"
+	}
+	s.clusters = cfg.Serve.Clusters
+	if len(s.clusters) == 0 && !cfg.Serve.DisableChecks {
+		s.clusters = []string{s.cluster}
+	}
+	address := *listen
+	if address == "" {
+		address = cfg.Serve.Listen
+	}
+	if address == "" {
+		address = ":8080"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok\n")) })
+	mux.HandleFunc("POST /webhook/alertmanager", s.auth.Require(auth.RoleOperator, s.handleAlertmanager))
+	if cfg.Serve.Slack != nil {
+		mux.HandleFunc("POST /slack/command", s.handleSlashCommand)
+	}
+	s.registerAPI(mux)
+	mux.Handle("GET /", web.Handler())
+
+	if !cfg.Serve.DisableChecks {
+		go s.runDashboard(interval)
+	}
+	if cfg.Serve.NodeAgents != nil {
+		if s.nodeAgents, err = s.newNodeAgents(cfg.Serve.NodeAgents); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	if cfg.Serve.Operator != nil {
+		controller, err := s.newController(cfg.Serve.Operator)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		go s.runOperator(controller)
+	}
+
+	httpServer := &http.Server{Addr: address, Handler: mux}
+	if cfg.Serve.TLS == nil {
+		log.Printf("healthctl serving on %s", address)
+		err = httpServer.ListenAndServe()
+	} else {
+		if httpServer.TLSConfig, err = fleet.ServerTLSConfig(*cfg.Serve.TLS); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		log.Printf("healthctl serving https on %s", address)
+		err = httpServer.ListenAndServeTLS("", "")
+	}
+	fmt.Fprintln(os.Stderr, err)
+	return 2
+}
+
+// handleAlertmanager accepts an Alertmanager webhook notification. The checks run in the background, so
+// Alertmanager does not time out, and every firing alert is sent on to slack with the related findings.
+func (s *server) handleAlertmanager(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	alerts, err := notify.ParseWebhook(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	if len(alerts) > 0 {
+		go s.checkAlerts(alerts)
+	}
+}
+
+// checkAlerts runs the alert suites once for a notification and sends every alert with its findings to the
+// slack channel of the team owning the alerting namespace
+func (s *server) checkAlerts(alerts []k8s.Alert) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	suites := "k8s"
+	if len(s.cfg.Serve.AlertSuites) > 0 {
+		suites = strings.Join(s.cfg.Serve.AlertSuites, ",")
+	}
+	_, result, err := collectFindings(s.kc, suites)
+	if err != nil {
+		log.Printf("running checks for %d alerts: %v", len(alerts), err)
+		return
+	}
+	namespaceLabels := s.kc.GetNamespaceLabels()
+	result = findings.AssignTeams(result, s.cfg, namespaceLabels)
+	result = findings.Annotate(result, s.cfg.KnowledgeBase)
+
+	cluster := s.cluster
+	for _, alert := range alerts {
+		related := alertFindings(alert, result)
+		log.Printf("alert %s for %s/%s: %d related findings", alert.AlertName, alert.Namespace, alert.PodName, len(related))
+		if s.cfg.Notifier.Slack == nil {
+			continue
+		}
+		team := s.cfg.TeamFor(alert.Namespace, namespaceLabels[alert.Namespace])
+		if err := notify.SendSlack(s.cfg.Notifier.Slack.ChannelFor(team), notify.AlertText(cluster, alert, related)); err != nil {
+			log.Printf("sending alert %s to slack: %v", alert.AlertName, err)
+		}
+	}
+}
+
+// alertFindings returns the findings in the namespace of the alert and on its node, findings about the
+// alerting pod come first
+func alertFindings(alert k8s.Alert, result []models.Finding) []models.Finding {
+	pod, other := []models.Finding{}, []models.Finding{}
+	for _, finding := range result {
+		switch {
+		case alert.PodName != "" && finding.Resource.Namespace == alert.Namespace && finding.Resource.Name == alert.PodName:
+			pod = append(pod, finding)
+		case alert.Namespace != "" && finding.Resource.Namespace == alert.Namespace:
+			other = append(other, finding)
+		case alert.Node != "" && (finding.Resource.Node == alert.Node || (finding.Resource.Kind == "Node" && finding.Resource.Name == alert.Node)):
+			other = append(other, finding)
+		}
+	}
+	return append(pod, other...)
+}
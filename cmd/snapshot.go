@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+	"healthctl/pkg/snapshot"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func snapshotCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl snapshot create [-file snapshot.json] | healthctl snapshot diff [-o text|json] [-fail-on severity] <old> <new>")
+		return 2
+	}
+	switch args[0] {
+	case "create":
+		return snapshotCreateCommand(args[1:])
+	case "diff":
+		return snapshotDiffCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown snapshot command %q\n", args[0])
+		return 2
+	}
+}
+
+// snapshotCreateCommand runs the suites and writes their findings with the usage of every namespace
+func snapshotCreateCommand(args []string) int {
+	fs := flag.NewFlagSet("snapshot create", flag.ExitOnError)
+	opts := addCheckFlags(fs, "")
+	file := fs.String("file", config.StatePath("snapshot.json"), "snapshot file")
+	fs.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	kc, err := k8s.NewK8sClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		return 2
+	}
+	// a snapshot records the cluster as it is now: every check runs and the hysteresis holds nothing back
+	noCache := true
+	opts.noCache, opts.historyFile = &noCache, ""
+	r, err := buildReport(kc, cfg, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	usage, err := kc.GetResourceUsageReport()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "WARNING: the snapshot has no usage:", err)
+	} else if !usage.MetricsAvailable {
+		fmt.Fprintln(os.Stderr, "WARNING: the snapshot has no usage, metrics-server is unavailable")
+	}
+	taken := snapshot.New(r, &usage)
+	if err := taken.Save(*file); err != nil {
+		fmt.Fprintln(os.Stderr, "Error saving snapshot:", err)
+		return 2
+	}
+	fmt.Printf("Snapshot with %d findings of cluster %s written to %s\n", len(taken.Findings), taken.Cluster, *file)
+	return 0
+}
+
+// snapshotDiffCommand prints the changes between two snapshots, json reports or baselines. It exits with 1
+// when findings of at least the -fail-on severity were added or escalated.
+func snapshotDiffCommand(args []string) int {
+	fs := flag.NewFlagSet("snapshot diff", flag.ExitOnError)
+	format := fs.String("o", "text", "output format, text or json")
+	failOn := fs.String("fail-on", "", "exit with 1 when findings of at least this severity (critical, warning, info) were added or escalated")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: healthctl snapshot diff [-o text|json] [-fail-on severity] <old> <new>")
+		return 2
+	}
+	severity := models.Severity(*failOn)
+	switch severity {
+	case "", models.SeverityCritical, models.SeverityWarning, models.SeverityInfo:
+	default:
+		fmt.Fprintf(os.Stderr, "unknown severity %q, use critical, warning or info\n", *failOn)
+		return 2
+	}
+
+	old, err := snapshot.Load(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading snapshot:", err)
+		return 2
+	}
+	current, err := snapshot.Load(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading snapshot:", err)
+		return 2
+	}
+	diff := snapshot.Compare(old, current)
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(diff); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	case "text":
+		printDiff(diff)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown output format %q, use text or json\n", *format)
+		return 2
+	}
+	if severity != "" && diff.Regressions(severity) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func printDiff(diff snapshot.Diff) {
+	fmt.Printf("Changes of cluster %s from %s to %s\n", diff.Cluster, diff.From.Format("2006-01-02 15:04"), diff.To.Format("2006-01-02 15:04"))
+	fmt.Printf("%s findings added, %s removed, %d escalated\n", severityCounts(diff.Summary.Added), severityCounts(diff.Summary.Removed), diff.Summary.Escalated)
+	for _, change := range diff.Changes {
+		if finding, added := change.Value.(models.Finding); added {
+			fmt.Printf("+ [%s] %s %s: %s\n", finding.Severity, finding.Check, finding.Resource, finding.Message)
+			continue
+		}
+		if finding, removed := change.Old.(models.Finding); removed {
+			fmt.Printf("- [%s] %s %s: %s\n", finding.Severity, finding.Check, finding.Resource, finding.Message)
+			continue
+		}
+		if change.Delta != nil {
+			fmt.Printf("~ %s %s\n", change.Path, usageDelta(change.Path, *change.Delta))
+			continue
+		}
+		fmt.Printf("~ %s: %v -> %v\n", change.Path, change.Old, change.Value)
+	}
+}
+
+func severityCounts(counts map[models.Severity]int) string {
+	parts := []string{}
+	for _, severity := range []models.Severity{models.SeverityCritical, models.SeverityWarning, models.SeverityInfo} {
+		if counts[severity] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[severity], severity))
+		}
+	}
+	if len(parts) == 0 {
+		return "no"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// usageDelta prints the change of cpu in cores and of memory in bytes
+func usageDelta(path string, delta int64) string {
+	sign := "+"
+	if delta < 0 {
+		sign, delta = "-", -delta
+	}
+	if strings.HasSuffix(path, "/cpu") {
+		return sign + resource.NewMilliQuantity(delta, resource.DecimalSI).String()
+	}
+	return sign + resource.NewQuantity(delta, resource.BinarySI).String()
+}
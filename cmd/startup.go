@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"healthctl/pkg/lifecycle"
+	"healthctl/pkg/testsuite"
+)
+
+// startupReportCommand prints the startup distribution of the workloads recorded by the Pod Startup check
+func startupReportCommand(args []string) int {
+	fs := flag.NewFlagSet("report startup", flag.ExitOnError)
+	format := fs.String("o", "text", "output format, text or json")
+	degraded := fs.Bool("degraded", false, "only list the workloads whose startup degraded")
+	fs.Parse(args)
+
+	if _, err := loadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading config:", err)
+		return 2
+	}
+	store, err := lifecycle.Load(testsuite.StartupFile())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading startup history:", err)
+		return 2
+	}
+	summaries := []lifecycle.Summary{}
+	for _, summary := range testsuite.StartupSummaries(store, time.Now()) {
+		if summary.Degraded || !*degraded {
+			summaries = append(summaries, summary)
+		}
+	}
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(summaries); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	case "text":
+		if len(store) == 0 {
+			fmt.Println("No pod startups recorded yet, they are recorded by the k8s/Pod Startup check.")
+			return 0
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "WORKLOAD\tPODS\tSCHEDULING p50/p90\tIMAGE PULL p50/p90\tREADINESS p50/p90\tTOTAL p50/p90\tRECENT\tBASELINE\t")
+		for _, summary := range summaries {
+			status := ""
+			if summary.Degraded {
+				status = "DEGRADED"
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", summary.Workload, summary.Pods, percentiles(summary.Scheduling),
+				percentiles(summary.ImagePull), percentiles(summary.Readiness), percentiles(summary.Total),
+				optionalDuration(summary.Recent), optionalDuration(summary.Baseline), status)
+		}
+		w.Flush()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown output format %q, use text or json\n", *format)
+		return 2
+	}
+	for _, summary := range summaries {
+		if summary.Degraded {
+			return 1
+		}
+	}
+	return 0
+}
+
+func percentiles(distribution lifecycle.Distribution) string {
+	return fmt.Sprintf("%s/%s", distribution.P50.Round(time.Second), distribution.P90.Round(time.Second))
+}
+
+// optionalDuration prints - for workloads without enough startups to compare
+func optionalDuration(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/report"
+)
+
+// usageReportCommand writes the resource usage of every container as csv
+func usageReportCommand(args []string) int {
+	fs := flag.NewFlagSet("report usage", flag.ExitOnError)
+	fs.Parse(args)
+
+	kc, err := k8s.NewK8sClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		return 2
+	}
+	usage, err := kc.GetResourceUsageReport()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error fetching resource usage:", err)
+		return 2
+	}
+	for _, finding := range usage.Findings {
+		fmt.Fprintln(os.Stderr, "WARNING:", finding.Message)
+	}
+	if err := report.WriteUsageCSV(os.Stdout, kc.GetCurrentCluster(), time.Now(), usage); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing usage:", err)
+		return 2
+	}
+	return 0
+}
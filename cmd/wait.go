@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"healthctl/pkg/k8s"
+)
+
+const waitPollInterval = 5 * time.Second
+
+func waitCommand(args []string) int {
+	fs := flag.NewFlagSet("wait", flag.ExitOnError)
+	namespace := fs.String("namespace", "default", "namespace of the workloads")
+	selector := fs.String("selector", "", "label selector of the workloads and their pods, e.g. app=foo")
+	condition := fs.String("for", "healthy", "rollout waits for the rollout to complete, healthy also for pods without crash loops and the -http check")
+	timeout := fs.Duration("timeout", 10*time.Minute, "give up and fail after this duration")
+	stable := fs.Duration("stable", 30*time.Second, "how long the condition must hold, so pods crashing shortly after start are caught")
+	httpPath := fs.String("http", "", "path requested from every port of the services selecting the pods, e.g. /healthz")
+	fs.Parse(args)
+	if *condition != "rollout" && *condition != "healthy" {
+		fmt.Fprintf(os.Stderr, "unknown condition %q, use rollout or healthy\n", *condition)
+		return 2
+	}
+
+	if _, err := loadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	kc, err := k8s.NewK8sClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating kubernetes client:", err)
+		return 2
+	}
+
+	deadline := time.Now().Add(*timeout)
+	var healthySince time.Time
+	previous := ""
+	for {
+		problems, err := waitProblems(kc, *namespace, *selector, *condition, *httpPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if current := strings.Join(problems, "\n"); current != previous {
+			if len(problems) > 0 {
+				fmt.Printf("%s waiting for:\n  %s\n", time.Now().Format(time.TimeOnly), strings.Join(problems, "\n  "))
+			}
+			previous = current
+		}
+
+		if len(problems) == 0 {
+			if healthySince.IsZero() {
+				healthySince = time.Now()
+				fmt.Printf("%s %s in %s, checking it holds for %s\n", time.Now().Format(time.TimeOnly), *condition, *namespace, *stable)
+			}
+			if time.Since(healthySince) >= *stable {
+				fmt.Printf("Workloads %s in %s are %s\n", *selector, *namespace, *condition)
+				return 0
+			}
+		} else {
+			healthySince = time.Time{}
+		}
+		if time.Now().After(deadline) {
+			fmt.Fprintf(os.Stderr, "Timed out after %s, workloads %s in %s are not %s\n", *timeout, *selector, *namespace, *condition)
+			return 1
+		}
+		time.Sleep(waitPollInterval)
+	}
+}
+
+// waitProblems returns everything the selected workloads still wait for
+func waitProblems(kc *k8s.K8sClient, namespace, selector, condition, httpPath string) ([]string, error) {
+	problems, workloads, err := kc.RolloutProblems(namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+	if workloads == 0 {
+		problems = append(problems, fmt.Sprintf("no Deployment, StatefulSet or DaemonSet matches %q", selector))
+	}
+	if condition == "rollout" {
+		return problems, nil
+	}
+	pods, err := kc.PodProblems(namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+	problems = append(problems, pods...)
+	if httpPath != "" && len(problems) == 0 {
+		services, err := kc.ServiceProblems(namespace, selector, httpPath)
+		if err != nil {
+			// a failed list is retried on the next poll, the deploy may still become healthy before the timeout
+			services = []string{fmt.Sprintf("listing the services: %v", err)}
+		}
+		problems = append(problems, services...)
+	}
+	return problems, nil
+}
@@ -0,0 +1,149 @@
+// Package auth authenticates the API requests of healthctl serve by static tokens or OIDC ID tokens and
+// authorizes them by role
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"healthctl/pkg/config"
+)
+
+// Role orders what a user may do, every role includes the lower ones
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleOperator
+	RoleAdmin
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleOperator:
+		return "operator"
+	case RoleAdmin:
+		return "admin"
+	}
+	return "none"
+}
+
+// ParseRole returns the role of its name
+func ParseRole(name string) (Role, error) {
+	switch name {
+	case "viewer":
+		return RoleViewer, nil
+	case "operator":
+		return RoleOperator, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return RoleNone, fmt.Errorf("unknown role %q, use viewer, operator or admin", name)
+}
+
+// Identity is an authenticated user
+type Identity struct {
+	Name string
+	Role Role
+}
+
+// errNoToken is returned for requests without a bearer token
+var errNoToken = errors.New("a bearer token is required")
+
+type staticToken struct {
+	name  string
+	token string
+	role  Role
+}
+
+// Authenticator checks the bearer token of requests. A nil authenticator lets everyone in as viewer, so
+// serve mode without authentication is read-only.
+type Authenticator struct {
+	tokens []staticToken
+	oidc   *oidcVerifier
+}
+
+// New returns the authenticator of the configuration, nil when authentication is not configured
+func New(cfg *config.ServeAuth) (*Authenticator, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	a := &Authenticator{}
+	for _, token := range cfg.Tokens {
+		role, err := ParseRole(token.Role)
+		if err != nil {
+			return nil, fmt.Errorf("token %s: %v", token.Name, err)
+		}
+		if token.Token == "" {
+			return nil, fmt.Errorf("token %s is empty", token.Name)
+		}
+		a.tokens = append(a.tokens, staticToken{name: token.Name, token: token.Token, role: role})
+	}
+	if cfg.OIDC != nil {
+		verifier, err := newOIDCVerifier(*cfg.OIDC)
+		if err != nil {
+			return nil, err
+		}
+		a.oidc = verifier
+	}
+	return a, nil
+}
+
+// Authenticate returns the identity of the bearer token of the request
+func (a *Authenticator) Authenticate(r *http.Request) (Identity, error) {
+	if a == nil {
+		return Identity{Name: "anonymous", Role: RoleViewer}, nil
+	}
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || token == "" {
+		return Identity{}, errNoToken
+	}
+	for _, static := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(static.token), []byte(token)) == 1 {
+			return Identity{Name: static.name, Role: static.role}, nil
+		}
+	}
+	if a.oidc != nil && strings.Count(token, ".") == 2 {
+		return a.oidc.verify(r.Context(), token)
+	}
+	return Identity{}, errors.New("invalid token")
+}
+
+type identityKey struct{}
+
+// FromContext returns the identity of an authenticated request
+func FromContext(ctx context.Context) (Identity, bool) {
+	identity, found := ctx.Value(identityKey{}).(Identity)
+	return identity, found
+}
+
+// Require only passes requests with at least the role on to the handler
+func (a *Authenticator) Require(role Role, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, err := a.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="healthctl"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if identity.Role < role && a == nil {
+			log.Printf("denied %s %s, serve.auth is not configured", r.Method, r.URL.Path)
+			http.Error(w, fmt.Sprintf("%s requires the %s role, configure serve.auth to allow it", r.URL.Path, role), http.StatusForbidden)
+			return
+		}
+		if identity.Role < role {
+			log.Printf("denied %s %s to %s with role %s", r.Method, r.URL.Path, identity.Name, identity.Role)
+			http.Error(w, fmt.Sprintf("%s requires the %s role, %s has %s", r.URL.Path, role, identity.Name, identity.Role), http.StatusForbidden)
+			return
+		}
+		handler(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, identity)))
+	}
+}
@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"healthctl/pkg/config"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// keyRefreshInterval limits how often the signing keys are fetched for an unknown key ID
+const keyRefreshInterval = time.Minute
+
+// clockSkew is how far the clock of the issuer may be ahead of ours when checking nbf
+const clockSkew = time.Minute
+
+// oidcVerifier verifies RS256 and ES256 signed ID tokens with the keys published by the issuer
+type oidcVerifier struct {
+	cfg     config.OIDCAuth
+	roles   map[string]Role
+	client  *http.Client
+	refresh singleflight.Group
+	// mutex guards keys and fetched, it is not held while the keys are fetched
+	mutex   sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+func newOIDCVerifier(cfg config.OIDCAuth) (*oidcVerifier, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" {
+		return nil, fmt.Errorf("oidc requires issuer and clientID")
+	}
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = "email"
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	roles := make(map[string]Role)
+	for subject, name := range cfg.Roles {
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, fmt.Errorf("oidc role of %s: %v", subject, err)
+		}
+		roles[subject] = role
+	}
+	return &oidcVerifier{cfg: cfg, roles: roles, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// verify checks the signature, issuer, audience and validity window of an ID token and maps its user and
+// groups to the highest configured role
+func (v *oidcVerifier) verify(ctx context.Context, token string) (Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Identity{}, errors.New("token is not a JWT")
+	}
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Identity{}, fmt.Errorf("invalid token header: %v", err)
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return Identity{}, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid token signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := verifySignature(header.Alg, key, digest[:], signature); err != nil {
+		return Identity{}, err
+	}
+
+	claims := map[string]any{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Identity{}, fmt.Errorf("invalid token claims: %v", err)
+	}
+	if claims["iss"] != v.cfg.Issuer {
+		return Identity{}, fmt.Errorf("token issued by %v", claims["iss"])
+	}
+	if !audience(claims["aud"], v.cfg.ClientID) {
+		return Identity{}, fmt.Errorf("token is not issued for %s", v.cfg.ClientID)
+	}
+	if exp, ok := claims["exp"].(float64); !ok || time.Now().After(time.Unix(int64(exp), 0)) {
+		return Identity{}, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && time.Now().Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return Identity{}, errors.New("token is not valid yet")
+	}
+
+	name, _ := claims[v.cfg.UsernameClaim].(string)
+	if name == "" {
+		return Identity{}, fmt.Errorf("token has no %s claim", v.cfg.UsernameClaim)
+	}
+	identity := Identity{Name: name, Role: v.roles[name]}
+	groups, _ := claims[v.cfg.GroupsClaim].([]any)
+	for _, group := range groups {
+		if name, ok := group.(string); ok {
+			identity.Role = max(identity.Role, v.roles[name])
+		}
+	}
+	return identity, nil
+}
+
+func audience(aud any, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []any:
+		return slices.Contains(aud, any(clientID))
+	}
+	return false
+}
+
+func verifySignature(alg string, key crypto.PublicKey, digest, signature []byte) error {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			break
+		}
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, signature); err != nil {
+			return errors.New("invalid token signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if alg != "ES256" || len(signature) != 64 {
+			break
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("invalid token signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported token algorithm %s", alg)
+}
+
+// key returns the signing key of the issuer, the keys are fetched again for unknown key IDs. Concurrent
+// requests share one fetch, which does not block requests with known keys.
+func (v *oidcVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mutex.Lock()
+	key, found := v.keys[kid]
+	recent := time.Since(v.fetched) < keyRefreshInterval
+	v.mutex.Unlock()
+	if found {
+		return key, nil
+	}
+	if recent {
+		return nil, fmt.Errorf("unknown token key %q", kid)
+	}
+
+	// the fetch outlives a canceled request, the other requests waiting for it still need the keys
+	fetch := v.refresh.DoChan("keys", func() (any, error) {
+		keys, err := v.fetchKeys(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		v.mutex.Lock()
+		v.keys, v.fetched = keys, time.Now()
+		v.mutex.Unlock()
+		return keys, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-fetch:
+		if result.Err != nil {
+			return nil, fmt.Errorf("fetching keys of %s: %v", v.cfg.Issuer, result.Err)
+		}
+		if key, found := result.Val.(map[string]crypto.PublicKey)[kid]; found {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown token key %q", kid)
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (v *oidcVerifier) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	discovery := struct {
+		JWKSURI string `json:"jwks_uri"`
+	}{}
+	if err := v.get(ctx, v.cfg.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	jwks := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err := v.get(ctx, discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch {
+	case jwk.Kty == "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case jwk.Kty == "EC" && jwk.Crv == "P-256":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", jwk.Kty)
+}
+
+func (v *oidcVerifier) get(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func decodeSegment(segment string, out any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"healthctl/pkg/config"
+)
+
+// testIssuer serves the discovery document and the keys of an issuer, while blocking is set the key
+// requests wait for release
+type testIssuer struct {
+	server     *httptest.Server
+	ecKey      *ecdsa.PrivateKey
+	rsaKey     *rsa.PrivateKey
+	keyFetches atomic.Int32
+	blocking   atomic.Bool
+	release    chan struct{}
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &testIssuer{ecKey: ecKey, rsaKey: rsaKey, release: make(chan struct{})}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"jwks_uri": issuer.server.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		issuer.keyFetches.Add(1)
+		if issuer.blocking.Load() {
+			<-issuer.release
+		}
+		encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+		json.NewEncoder(w).Encode(map[string]any{"keys": []jsonWebKey{
+			{Kid: "ec", Kty: "EC", Crv: "P-256", X: encode(ecKey.X.Bytes()), Y: encode(ecKey.Y.Bytes())},
+			{Kid: "rsa", Kty: "RSA", N: encode(rsaKey.N.Bytes()), E: encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+		}})
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (i *testIssuer) verifier(t *testing.T) *oidcVerifier {
+	t.Helper()
+	v, err := newOIDCVerifier(config.OIDCAuth{
+		Issuer:   i.server.URL,
+		ClientID: "healthctl",
+		Roles:    map[string]string{"alice@example.com": "viewer", "sre": "admin"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// token signs the claims with the key of kid, valid claims are used for the ones not given
+func (i *testIssuer) token(t *testing.T, kid string, claims map[string]any) string {
+	t.Helper()
+	all := map[string]any{
+		"iss":   i.server.URL,
+		"aud":   "healthctl",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"email": "alice@example.com",
+	}
+	for name, value := range claims {
+		if value == nil {
+			delete(all, name)
+		} else {
+			all[name] = value
+		}
+	}
+	alg := "ES256"
+	if kid == "rsa" {
+		alg = "RS256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid})
+	payload, _ := json.Marshal(all)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	if kid == "rsa" {
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, i.rsaKey, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		r, s, err := ecdsa.Sign(rand.Reader, i.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerify(t *testing.T) {
+	issuer := newTestIssuer(t)
+	v := issuer.verifier(t)
+	now := time.Now()
+	tamper := func(token string) string {
+		parts := strings.Split(token, ".")
+		claims, _ := json.Marshal(map[string]any{"iss": issuer.server.URL, "aud": "healthctl", "exp": now.Add(time.Hour).Unix(), "email": "mallory@example.com"})
+		return parts[0] + "." + base64.RawURLEncoding.EncodeToString(claims) + "." + parts[2]
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		user  string
+		role  Role
+		err   string
+	}{
+		{name: "ES256", token: issuer.token(t, "ec", nil), user: "alice@example.com", role: RoleViewer},
+		{name: "RS256", token: issuer.token(t, "rsa", nil), user: "alice@example.com", role: RoleViewer},
+		{name: "group role", token: issuer.token(t, "ec", map[string]any{"groups": []string{"dev", "sre"}}), user: "alice@example.com", role: RoleAdmin},
+		{name: "audience list", token: issuer.token(t, "ec", map[string]any{"aud": []string{"other", "healthctl"}}), user: "alice@example.com", role: RoleViewer},
+		{name: "nbf within clock skew", token: issuer.token(t, "ec", map[string]any{"nbf": now.Add(clockSkew / 2).Unix()}), user: "alice@example.com", role: RoleViewer},
+		{name: "nbf in the future", token: issuer.token(t, "ec", map[string]any{"nbf": now.Add(time.Hour).Unix()}), err: "not valid yet"},
+		{name: "expired", token: issuer.token(t, "ec", map[string]any{"exp": now.Add(-time.Minute).Unix()}), err: "expired"},
+		{name: "no expiry", token: issuer.token(t, "ec", map[string]any{"exp": nil}), err: "expired"},
+		{name: "other issuer", token: issuer.token(t, "ec", map[string]any{"iss": "https://evil.example.com"}), err: "issued by"},
+		{name: "other audience", token: issuer.token(t, "ec", map[string]any{"aud": "other"}), err: "not issued for"},
+		{name: "no user", token: issuer.token(t, "ec", map[string]any{"email": nil}), err: "no email claim"},
+		{name: "tampered claims", token: tamper(issuer.token(t, "ec", nil)), err: "invalid token signature"},
+		{name: "unknown key", token: issuer.token(t, "other", nil), err: "unknown token key"},
+		{name: "not a JWT", token: "abc.def", err: "not a JWT"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			identity, err := v.verify(context.Background(), test.token)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if identity.Name != test.user || identity.Role != test.role {
+				t.Errorf("got %s with role %s, want %s with role %s", identity.Name, identity.Role, test.user, test.role)
+			}
+		})
+	}
+}
+
+func TestAlgorithmMustMatchKey(t *testing.T) {
+	issuer := newTestIssuer(t)
+	v := issuer.verifier(t)
+	// an RS256 signature presented as ES256
+	parts := strings.Split(issuer.token(t, "rsa", nil), ".")
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": "rsa"})
+	token := base64.RawURLEncoding.EncodeToString(header) + "." + parts[1] + "." + parts[2]
+	if _, err := v.verify(context.Background(), token); err == nil {
+		t.Fatal("token with an algorithm not matching its key was accepted")
+	}
+}
+
+func TestKeyFetchDoesNotBlockKnownKeys(t *testing.T) {
+	issuer := newTestIssuer(t)
+	v := issuer.verifier(t)
+	known := issuer.token(t, "ec", nil)
+	if _, err := v.verify(context.Background(), known); err != nil {
+		t.Fatal(err)
+	}
+
+	// the next fetch hangs until the test releases it
+	issuer.blocking.Store(true)
+	defer close(issuer.release)
+	v.mutex.Lock()
+	v.fetched = time.Time{}
+	v.mutex.Unlock()
+	unknown := issuer.token(t, "rotated", nil)
+	for range 3 {
+		go v.verify(context.Background(), unknown)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		_, err := v.verify(context.Background(), known)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("a token with a known key waited for the key fetch")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := v.verify(ctx, unknown); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want the deadline of the request", err)
+	}
+	if fetches := issuer.keyFetches.Load(); fetches != 2 {
+		t.Errorf("keys were fetched %d times, want 2", fetches)
+	}
+}
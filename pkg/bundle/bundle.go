@@ -0,0 +1,179 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+	"healthctl/pkg/profiles"
+	"healthctl/pkg/report"
+)
+
+// Options controls what is collected into a support bundle
+type Options struct {
+	EventsSince time.Duration
+	LogLines    int64
+}
+
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Name returns the bundle name for a cluster, safe to use as a file name
+func Name(cluster string, generated time.Time) string {
+	return fmt.Sprintf("healthctl-%s-%s", unsafeName.ReplaceAllString(cluster, "-"), generated.Format("20060102-150405"))
+}
+
+// bundleWriter adds files to a gzipped tar archive
+type bundleWriter struct {
+	tw      *tar.Writer
+	root    string
+	modTime time.Time
+}
+
+func (b *bundleWriter) add(name string, data []byte) error {
+	header := &tar.Header{
+		Name:    path.Join(b.root, name),
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: b.modTime,
+	}
+	if err := b.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := b.tw.Write(data)
+	return err
+}
+
+// Create writes a support bundle with the report, the manifests, logs and recent profiles of failing objects
+// and the recent events into a single tar.gz, so it can be handed over from air-gapped environments.
+// Objects that can not be collected are listed in errors.txt instead of failing the bundle.
+func Create(file string, kc *k8s.K8sClient, r report.Report, opts Options) error {
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	b := &bundleWriter{tw: tw, root: Name(r.Cluster, r.Generated), modTime: r.Generated}
+
+	var reportJSON bytes.Buffer
+	if err := (report.JSONWriter{}).Write(&reportJSON, r); err != nil {
+		return err
+	}
+	if err := b.add("report.json", reportJSON.Bytes()); err != nil {
+		return err
+	}
+
+	var reportText bytes.Buffer
+	if err := (report.TextWriter{}).Write(&reportText, r); err != nil {
+		return err
+	}
+	if err := b.add("report.txt", reportText.Bytes()); err != nil {
+		return err
+	}
+
+	var collectErrors bytes.Buffer
+	for _, ref := range failingObjects(r.Findings) {
+		manifest, err := kc.GetManifest(ref)
+		if err != nil {
+			fmt.Fprintf(&collectErrors, "manifest %s: %v\n", ref, err)
+			continue
+		}
+		if err := b.add(path.Join("manifests", ref.String()+".yaml"), manifest); err != nil {
+			return err
+		}
+
+		if ref.Kind != "Pod" {
+			continue
+		}
+		for _, file := range profiles.Recent(ref.Namespace, ref.Name, r.Generated.Add(-profiles.EvidenceWindow)) {
+			data, err := os.ReadFile(file.Path)
+			if err != nil {
+				fmt.Fprintf(&collectErrors, "profile %s: %v\n", file.Path, err)
+				continue
+			}
+			if err := b.add(path.Join("profiles", ref.Namespace, ref.Name, filepath.Base(file.Path)), data); err != nil {
+				return err
+			}
+		}
+		logs, err := kc.GetPodLogs(ref.Namespace, ref.Name, opts.LogLines)
+		if err != nil {
+			fmt.Fprintf(&collectErrors, "logs %s: %v\n", ref, err)
+			continue
+		}
+		for container, data := range logs {
+			if err := b.add(path.Join("logs", ref.Namespace, ref.Name, container+".log"), []byte(data)); err != nil {
+				return err
+			}
+		}
+	}
+
+	events, err := kc.GetRecentEvents(opts.EventsSince)
+	if err != nil {
+		fmt.Fprintf(&collectErrors, "events: %v\n", err)
+	} else if err := b.add("events.txt", []byte(k8s.FormatEvents(events))); err != nil {
+		return err
+	}
+
+	if collectErrors.Len() > 0 {
+		if err := b.add("errors.txt", collectErrors.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// failingObjects returns the unique kubernetes objects of the failing findings
+func failingObjects(result []models.Finding) []models.ResourceRef {
+	seen := make(map[models.ResourceRef]bool)
+	refs := []models.ResourceRef{}
+	for _, finding := range result {
+		ref := finding.Resource
+		ref.Node = ""
+		if !finding.Failing() || ref.Kind == "Check" || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// WriteProfiles writes stored profiles into a tar.gz as profiles/<namespace>/<pod>/<file>
+func WriteProfiles(file, name string, generated time.Time, files []profiles.File) error {
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	b := &bundleWriter{tw: tw, root: name, modTime: generated}
+	for _, profile := range files {
+		data, err := os.ReadFile(profile.Path)
+		if err != nil {
+			return err
+		}
+		if err := b.add(path.Join("profiles", profile.Pod.Namespace, profile.Pod.Name, filepath.Base(profile.Path)), data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
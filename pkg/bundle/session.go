@@ -0,0 +1,285 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultMetricsInterval is the interval of the usage samples of a debug session
+	DefaultMetricsInterval = 15 * time.Second
+	// logReopenDelay is the pause before the log stream of a restarted container is opened again
+	logReopenDelay = 2 * time.Second
+)
+
+// Session is a debug session: the debug level of the pods was raised, their logs, usage and events were
+// captured and the levels were restored
+type Session struct {
+	Namespace string                 `json:"namespace"`
+	Container string                 `json:"container,omitempty"`
+	Level     string                 `json:"level"`
+	Pods      []string               `json:"pods"`
+	Started   time.Time              `json:"started"`
+	Ended     time.Time              `json:"ended"`
+	Set       []k8s.DebugLevelResult `json:"-"`
+	Restored  []k8s.DebugLevelResult `json:"-"`
+}
+
+// Capture holds the logs, usage samples and events captured during a debug session in a temporary
+// directory until they are written into the bundle
+type Capture struct {
+	dir    string
+	mutex  sync.Mutex
+	errors bytes.Buffer
+}
+
+func (c *Capture) errorf(format string, args ...interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	fmt.Fprintf(&c.errors, format+"\n", args...)
+}
+
+// CaptureSession follows the logs of every container of the pods and samples their usage every interval
+// for the duration, or until the context ends. The events of the namespace are added at the end. Logs of
+// containers that restart are followed into the new container.
+func CaptureSession(ctx context.Context, kc *k8s.K8sClient, pods []v1.Pod, started time.Time, duration, interval time.Duration) (*Capture, error) {
+	dir, err := os.MkdirTemp("", "healthctl-session-")
+	if err != nil {
+		return nil, err
+	}
+	capture := &Capture{dir: dir}
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	if interval <= 0 {
+		interval = DefaultMetricsInterval
+	}
+
+	var wait sync.WaitGroup
+	for _, pod := range pods {
+		for _, container := range pod.Spec.Containers {
+			wait.Add(1)
+			go func() {
+				defer wait.Done()
+				capture.followLogs(ctx, kc, pod, container.Name, started)
+			}()
+		}
+	}
+	wait.Add(1)
+	go func() {
+		defer wait.Done()
+		capture.sampleUsage(ctx, kc, pods, interval)
+	}()
+	wait.Wait()
+
+	if len(pods) > 0 {
+		capture.addEvents(kc, pods[0].Namespace, started)
+	}
+	return capture, nil
+}
+
+// followLogs streams the log of a container into logs/<pod>/<container>.log, reopening the stream when the
+// container restarts until the context ends or the pod is gone
+func (c *Capture) followLogs(ctx context.Context, kc *k8s.K8sClient, pod v1.Pod, container string, since time.Time) {
+	file := filepath.Join(c.dir, "logs", pod.Name, container+".log")
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		c.errorf("logs %s/%s: %v", pod.Name, container, err)
+		return
+	}
+	out, err := os.Create(file)
+	if err != nil {
+		c.errorf("logs %s/%s: %v", pod.Name, container, err)
+		return
+	}
+	defer out.Close()
+	previous := ""
+	for ctx.Err() == nil {
+		err := kc.StreamPodLogs(ctx, pod.Namespace, pod.Name, container, since, out)
+		if ctx.Err() != nil {
+			return
+		}
+		if apierrors.IsNotFound(err) {
+			c.errorf("logs %s/%s: pod is gone since %s", pod.Name, container, time.Now().Format(time.RFC3339))
+			return
+		}
+		// a crash looping container fails the same way until it runs again
+		if err != nil && err.Error() != previous {
+			c.errorf("logs %s/%s at %s: %v", pod.Name, container, time.Now().Format(time.RFC3339), err)
+			previous = err.Error()
+		}
+		since = time.Now()
+		select {
+		case <-ctx.Done():
+		case <-time.After(logReopenDelay):
+			fmt.Fprintf(out, "--- healthctl: log stream ended, reopened at %s\n", time.Now().Format(time.RFC3339))
+		}
+	}
+}
+
+// sampleUsage writes the CPU and memory usage of every container of the pods to usage.csv every interval
+func (c *Capture) sampleUsage(ctx context.Context, kc *k8s.K8sClient, pods []v1.Pod, interval time.Duration) {
+	if kc.MetricsClient == nil || len(pods) == 0 {
+		return
+	}
+	names := make(map[string]bool)
+	for _, pod := range pods {
+		names[pod.Name] = true
+	}
+	out, err := os.Create(filepath.Join(c.dir, "usage.csv"))
+	if err != nil {
+		c.errorf("usage: %v", err)
+		return
+	}
+	defer out.Close()
+	fmt.Fprintln(out, "time,pod,container,cpu_millicores,memory_bytes")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		list, err := kc.MetricsClient.MetricsV1beta1().PodMetricses(pods[0].Namespace).List(ctx, metav1.ListOptions{})
+		if err != nil && ctx.Err() == nil {
+			c.errorf("usage at %s: %v", time.Now().Format(time.RFC3339), err)
+		}
+		if err == nil {
+			for _, metrics := range list.Items {
+				if !names[metrics.Name] {
+					continue
+				}
+				for _, container := range metrics.Containers {
+					fmt.Fprintf(out, "%s,%s,%s,%d,%d\n", metrics.Timestamp.Format(time.RFC3339), metrics.Name, container.Name,
+						container.Usage.Cpu().MilliValue(), container.Usage.Memory().Value())
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// addEvents writes the events of the namespace observed since the start of the session to events.txt
+func (c *Capture) addEvents(kc *k8s.K8sClient, namespace string, since time.Time) {
+	events, err := kc.Client.CoreV1().Events(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		c.errorf("events: %v", err)
+		return
+	}
+	recent := []v1.Event{}
+	for _, event := range events.Items {
+		if !k8s.EventTime(event).Before(since) {
+			recent = append(recent, event)
+		}
+	}
+	sort.Slice(recent, func(i, j int) bool { return k8s.EventTime(recent[i]).Before(k8s.EventTime(recent[j])) })
+	if err := os.WriteFile(filepath.Join(c.dir, "events.txt"), []byte(k8s.FormatEvents(recent)), 0644); err != nil {
+		c.errorf("events: %v", err)
+	}
+}
+
+// WriteSession writes the session summary, the manifests of its pods and the capture into a tar.gz and
+// removes the temporary files of the capture
+func WriteSession(file string, kc *k8s.K8sClient, session Session, capture *Capture) error {
+	defer os.RemoveAll(capture.dir)
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	b := &bundleWriter{tw: tw, root: SessionName(session.Namespace, session.Started), modTime: session.Ended}
+
+	summary, err := json.MarshalIndent(struct {
+		Session
+		Set      []levelResult `json:"set"`
+		Restored []levelResult `json:"restored"`
+	}{session, levelResults(session.Set), levelResults(session.Restored)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := b.add("session.json", summary); err != nil {
+		return err
+	}
+
+	for _, pod := range session.Pods {
+		ref := models.ResourceRef{Kind: "Pod", Namespace: session.Namespace, Name: pod}
+		manifest, err := kc.GetManifest(ref)
+		if err != nil {
+			capture.errorf("manifest %s: %v", ref, err)
+			continue
+		}
+		if err := b.add(path.Join("manifests", ref.String()+".yaml"), manifest); err != nil {
+			return err
+		}
+	}
+
+	err = filepath.WalkDir(capture.dir, func(name string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(capture.dir, name)
+		if err != nil {
+			return err
+		}
+		return b.add(filepath.ToSlash(relative), data)
+	})
+	if err != nil {
+		return err
+	}
+	if capture.errors.Len() > 0 {
+		if err := b.add("errors.txt", capture.errors.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// SessionName returns the bundle name of a debug session, safe to use as a file name
+func SessionName(namespace string, started time.Time) string {
+	return fmt.Sprintf("healthctl-debug-%s-%s", unsafeName.ReplaceAllString(namespace, "-"), started.Format("20060102-150405"))
+}
+
+// levelResult is a debug level result of the session summary
+type levelResult struct {
+	Pod   string `json:"pod"`
+	Port  int    `json:"port"`
+	Error string `json:"error,omitempty"`
+}
+
+func levelResults(results []k8s.DebugLevelResult) []levelResult {
+	summary := []levelResult{}
+	for _, result := range results {
+		entry := levelResult{Pod: result.Pod, Port: result.Port}
+		if result.Err != nil {
+			entry.Error = result.Err.Error()
+		}
+		summary = append(summary, entry)
+	}
+	return summary
+}
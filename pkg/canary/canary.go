@@ -0,0 +1,314 @@
+// Package canary compares the health signals of a canary deployment with its baseline deployment over a
+// window and recommends to promote or abort the canary.
+package canary
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/k8s"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	Promote = "promote"
+	Abort   = "abort"
+
+	defaultMaxIncreasePercent = 20
+)
+
+// Signals are the health signals of one deployment over the window, per pod so deployments of different
+// sizes compare. Pods are all pods of the deployment seen during the window.
+type Signals struct {
+	Deployment    string             `json:"deployment"`
+	Pods          int                `json:"pods"`
+	Restarts      int32              `json:"restarts"`
+	WarningEvents int                `json:"warningEvents"`
+	CPUMillis     int64              `json:"cpuMillisPerPod"`
+	MemoryBytes   int64              `json:"memoryBytesPerPod"`
+	Metrics       map[string]float64 `json:"metrics,omitempty"`
+
+	startRestarts map[string]int32
+	usageSamples  int
+}
+
+// Comparison is one signal of the canary compared with the baseline
+type Comparison struct {
+	Signal   string  `json:"signal"`
+	Baseline float64 `json:"baseline"`
+	Canary   float64 `json:"canary"`
+	Failed   bool    `json:"failed"`
+	Reason   string  `json:"reason,omitempty"`
+}
+
+// Analysis is the result of a canary analysis with the data supporting the recommendation
+type Analysis struct {
+	Namespace      string       `json:"namespace"`
+	Started        time.Time    `json:"started"`
+	Window         string       `json:"window"`
+	Baseline       Signals      `json:"baseline"`
+	Canary         Signals      `json:"canary"`
+	Comparisons    []Comparison `json:"comparisons"`
+	Recommendation string       `json:"recommendation"`
+}
+
+// Analyze samples both deployments every interval during the window and compares them at the end
+func Analyze(kc *k8s.K8sClient, cfg config.Canary, namespace, baseline, canary string, window, interval time.Duration) (Analysis, error) {
+	analysis := Analysis{Namespace: namespace, Started: time.Now(), Window: window.String()}
+	analysis.Baseline = Signals{Deployment: baseline, startRestarts: map[string]int32{}, Metrics: map[string]float64{}}
+	analysis.Canary = Signals{Deployment: canary, startRestarts: map[string]int32{}, Metrics: map[string]float64{}}
+
+	deadline := analysis.Started.Add(window)
+	for {
+		for _, signals := range []*Signals{&analysis.Baseline, &analysis.Canary} {
+			if err := sample(kc, namespace, signals); err != nil {
+				return analysis, err
+			}
+		}
+		if !time.Now().Before(deadline) {
+			break
+		}
+		time.Sleep(min(interval, time.Until(deadline)))
+	}
+
+	for _, signals := range []*Signals{&analysis.Baseline, &analysis.Canary} {
+		if err := finish(kc, cfg, namespace, signals, analysis.Started); err != nil {
+			return analysis, err
+		}
+	}
+	analysis.Comparisons = compare(cfg, analysis.Baseline, analysis.Canary)
+	analysis.Recommendation = Promote
+	for _, comparison := range analysis.Comparisons {
+		if comparison.Failed {
+			analysis.Recommendation = Abort
+		}
+	}
+	return analysis, nil
+}
+
+// deploymentPods returns the pods of the ReplicaSets of a deployment, the baseline and canary often share labels
+func deploymentPods(kc *k8s.K8sClient, namespace, deployment string) ([]v1.Pod, error) {
+	ctx := context.Background()
+	d, err := kc.Client.AppsV1().Deployments(namespace).Get(ctx, deployment, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	selector, err := metav1.LabelSelectorAsSelector(d.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	replicasets, err := kc.Client.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	owned := make(map[string]bool)
+	for _, rs := range replicasets.Items {
+		if metav1.IsControlledBy(&rs, d) {
+			owned[rs.Name] = true
+		}
+	}
+	pods, err := kc.Client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	result := []v1.Pod{}
+	for _, pod := range pods.Items {
+		if owner := metav1.GetControllerOf(&pod); owner != nil && owned[owner.Name] {
+			result = append(result, pod)
+		}
+	}
+	return result, nil
+}
+
+// sample records the restart counts at the start of the window and the resource usage of the pods
+func sample(kc *k8s.K8sClient, namespace string, signals *Signals) error {
+	pods, err := deploymentPods(kc, namespace, signals.Deployment)
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods {
+		restarts := int32(0)
+		for _, status := range pod.Status.ContainerStatuses {
+			restarts += status.RestartCount
+		}
+		if start, seen := signals.startRestarts[pod.Name]; !seen {
+			signals.startRestarts[pod.Name] = restarts
+			signals.Pods++
+		} else if restarts-start > 0 {
+			signals.Restarts += restarts - start
+			signals.startRestarts[pod.Name] = restarts
+		}
+	}
+
+	if kc.MetricsClient == nil || len(pods) == 0 {
+		return nil
+	}
+	cpu, memory := int64(0), int64(0)
+	for _, pod := range pods {
+		metrics, err := kc.MetricsClient.MetricsV1beta1().PodMetricses(namespace).Get(context.Background(), pod.Name, metav1.GetOptions{})
+		if err != nil {
+			continue
+		}
+		for _, container := range metrics.Containers {
+			cpu += container.Usage.Cpu().MilliValue()
+			memory += container.Usage.Memory().Value()
+		}
+	}
+	// running average of the usage per pod
+	n := int64(signals.usageSamples)
+	signals.CPUMillis = (signals.CPUMillis*n + cpu/int64(len(pods))) / (n + 1)
+	signals.MemoryBytes = (signals.MemoryBytes*n + memory/int64(len(pods))) / (n + 1)
+	signals.usageSamples++
+	return nil
+}
+
+// finish counts the warning events of the pods during the window and runs the Prometheus queries
+func finish(kc *k8s.K8sClient, cfg config.Canary, namespace string, signals *Signals, started time.Time) error {
+	pods, err := deploymentPods(kc, namespace, signals.Deployment)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for name := range signals.startRestarts {
+		names[name] = true
+	}
+	podNames := []string{}
+	for _, pod := range pods {
+		names[pod.Name] = true
+		podNames = append(podNames, pod.Name)
+	}
+	// the restarts and events of replaced pods count as well, so do the pods
+	signals.Pods = len(names)
+	events, err := kc.Client.CoreV1().Events(namespace).List(context.Background(), metav1.ListOptions{FieldSelector: "type=Warning,involvedObject.kind=Pod"})
+	if err != nil {
+		return err
+	}
+	for _, event := range events.Items {
+		seen := event.LastTimestamp.Time
+		if seen.IsZero() {
+			seen = event.EventTime.Time
+		}
+		if names[event.InvolvedObject.Name] && !seen.Before(started) {
+			signals.WarningEvents += int(max(event.Count, 1))
+		}
+	}
+
+	for _, query := range cfg.Queries {
+		value, err := prometheusQuery(kc, cfg.Prometheus, query.Query, map[string]string{
+			"Namespace":  namespace,
+			"Deployment": signals.Deployment,
+			"Pods":       strings.Join(podNames, "|"),
+			"Window":     time.Since(started).Round(time.Second).String(),
+		})
+		if err != nil {
+			return fmt.Errorf("query %s: %v", query.Name, err)
+		}
+		if query.PerPod {
+			value /= float64(max(signals.Pods, 1))
+		}
+		signals.Metrics[query.Name] = value
+	}
+	return nil
+}
+
+// prometheusQuery runs an instant query through the API server service proxy and returns the first value.
+// The query is a template over the namespace, deployment, a regex of its pod names and the window.
+func prometheusQuery(kc *k8s.K8sClient, prometheus config.PrometheusService, query string, data map[string]string) (float64, error) {
+	tmpl, err := template.New("query").Option("missingkey=error").Parse(query)
+	if err != nil {
+		return 0, err
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return 0, err
+	}
+	port := prometheus.Port
+	if port == "" {
+		port = "9090"
+	}
+	raw, err := kc.Client.CoreV1().Services(prometheus.Namespace).ProxyGet("http", prometheus.Service, port, "/api/v1/query",
+		map[string]string{"query": rendered.String()}).DoRaw(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	response := struct {
+		Data struct {
+			Result []struct {
+				Value []interface{} `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return 0, err
+	}
+	if len(response.Data.Result) == 0 || len(response.Data.Result[0].Value) != 2 {
+		return 0, nil
+	}
+	text, _ := response.Data.Result[0].Value[1].(string)
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsNaN(value) {
+		return 0, nil
+	}
+	return value, nil
+}
+
+// compare fails the canary when it restarts or logs warnings more often than the baseline, or when its
+// usage or a metric exceeds the baseline by more than the allowed increase
+func compare(cfg config.Canary, baseline, canary Signals) []Comparison {
+	maxIncrease := cfg.MaxIncreasePercent
+	if maxIncrease == 0 {
+		maxIncrease = defaultMaxIncreasePercent
+	}
+	perPod := func(value float64, pods int) float64 {
+		return value / float64(max(pods, 1))
+	}
+	comparisons := []Comparison{}
+	counted := func(signal string, b, c float64) {
+		comparison := Comparison{Signal: signal, Baseline: b, Canary: c}
+		if c > b {
+			comparison.Failed = true
+			comparison.Reason = fmt.Sprintf("the canary has more %s per pod than the baseline", signal)
+		}
+		comparisons = append(comparisons, comparison)
+	}
+	increase := func(signal string, b, c, allowed float64) {
+		comparison := Comparison{Signal: signal, Baseline: b, Canary: c}
+		switch {
+		case b == 0 && c > 0:
+			comparison.Failed = true
+			comparison.Reason = fmt.Sprintf("%s of the canary is %g while the baseline is 0", signal, c)
+		case c > b*(1+allowed/100):
+			comparison.Failed = true
+			comparison.Reason = fmt.Sprintf("%s of the canary is %.0f%% above the baseline, allowed %.0f%%", signal, (c/b-1)*100, allowed)
+		}
+		comparisons = append(comparisons, comparison)
+	}
+
+	if canary.Pods == 0 {
+		comparisons = append(comparisons, Comparison{Signal: "pods", Baseline: float64(baseline.Pods), Failed: true, Reason: "the canary has no pods"})
+	}
+	counted("restarts", perPod(float64(baseline.Restarts), baseline.Pods), perPod(float64(canary.Restarts), canary.Pods))
+	counted("warning events", perPod(float64(baseline.WarningEvents), baseline.Pods), perPod(float64(canary.WarningEvents), canary.Pods))
+	if baseline.usageSamples > 0 && canary.usageSamples > 0 {
+		increase("cpu", float64(baseline.CPUMillis), float64(canary.CPUMillis), maxIncrease)
+		increase("memory", float64(baseline.MemoryBytes), float64(canary.MemoryBytes), maxIncrease)
+	}
+	for _, query := range cfg.Queries {
+		allowed := query.MaxIncreasePercent
+		if allowed == 0 {
+			allowed = maxIncrease
+		}
+		increase(query.Name, baseline.Metrics[query.Name], canary.Metrics[query.Name], allowed)
+	}
+	return comparisons
+}
@@ -0,0 +1,97 @@
+package capacity
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// retention is how long usage samples are kept
+const retention = 30 * 24 * time.Hour
+
+// Sample is the usage of a node pool at one point in time.
+// Cpu is in millicores and memory in bytes, capacity is the allocatable of all nodes in the pool.
+type Sample struct {
+	Time           time.Time `json:"time"`
+	CPUUsed        int64     `json:"cpuUsed"`
+	CPUCapacity    int64     `json:"cpuCapacity"`
+	MemoryUsed     int64     `json:"memoryUsed"`
+	MemoryCapacity int64     `json:"memoryCapacity"`
+}
+
+// Store keeps the usage samples of every node pool between runs
+type Store map[string][]Sample
+
+// Load reads the usage store, a missing file returns an empty store
+func Load(file string) (Store, error) {
+	store := Store{}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Record adds a sample for a pool and drops samples older than the retention
+func (s Store) Record(pool string, sample Sample) {
+	samples := append(s[pool], sample)
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+	cutoff := sample.Time.Add(-retention)
+	for len(samples) > 0 && samples[0].Time.Before(cutoff) {
+		samples = samples[1:]
+	}
+	s[pool] = samples
+}
+
+// Save writes the usage store, creating the state directory when needed
+func (s Store) Save(file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// Forecast projects when the used value reaches the capacity at the current growth rate, by a linear
+// regression over the samples. It returns false when the usage is not growing or there is too little history.
+func Forecast(samples []Sample, used, capacity func(Sample) int64) (time.Duration, bool) {
+	if len(samples) < 3 || samples[len(samples)-1].Time.Sub(samples[0].Time) < time.Hour {
+		return 0, false
+	}
+	start := samples[0].Time
+	var sumX, sumY, sumXY, sumXX float64
+	for _, sample := range samples {
+		x := sample.Time.Sub(start).Hours()
+		y := float64(used(sample))
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(samples))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, false
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator
+	if slope <= 0 {
+		return 0, false
+	}
+	last := samples[len(samples)-1]
+	headroom := float64(capacity(last) - used(last))
+	if headroom <= 0 {
+		return 0, true
+	}
+	return time.Duration(headroom / slope * float64(time.Hour)), true
+}
@@ -0,0 +1,291 @@
+// Package compare puts two clusters side by side: their versions, the workloads and config maps present in
+// only one of them, the settings of the workloads and config maps that differ and the findings of the same
+// suites, e.g. to find why a release works in staging but not in prod
+package compare
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+	"healthctl/pkg/report"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podSuffix matches the random suffix of the pods of Deployments, ReplicaSets, Jobs and DaemonSets, their
+// names are generated from consonants and digits only
+var podSuffix = regexp.MustCompile(`(-[bcdfghjklmnpqrstvwxz2456789]{6,10})?-[bcdfghjklmnpqrstvwxz2456789]{5}$`)
+
+// skippedConfigMaps differ between clusters by design
+var skippedConfigMaps = map[string]bool{"kube-root-ca.crt": true}
+
+// Object is a workload or config map and the settings that are compared, like the image of a container
+type Object struct {
+	Resource models.ResourceRef `json:"resource"`
+	Settings map[string]string  `json:"settings"`
+}
+
+// Cluster is what is compared of a cluster
+type Cluster struct {
+	Context string `json:"context"`
+	// Versions are the kubernetes version, the provider and region and the distinct kubelet, container
+	// runtime and OS versions of the nodes
+	Versions map[string]string `json:"versions"`
+	Objects  map[string]Object `json:"-"`
+	Findings []models.Finding  `json:"-"`
+}
+
+// Collect reads the versions, workloads and config maps of the namespaces matching one of the globs, all
+// namespaces without globs, and takes the findings of the report
+func Collect(kc *k8s.K8sClient, contextName string, r report.Report, namespaces []string) (Cluster, error) {
+	cluster := Cluster{Context: contextName, Versions: kc.DiscoverClusterLabels(), Objects: make(map[string]Object), Findings: r.Findings}
+	nodes, err := kc.Client.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return cluster, fmt.Errorf("listing nodes of %s: %v", contextName, err)
+	}
+	kubelets, runtimes, images := []string{}, []string{}, []string{}
+	for _, node := range nodes.Items {
+		kubelets = append(kubelets, node.Status.NodeInfo.KubeletVersion)
+		runtimes = append(runtimes, node.Status.NodeInfo.ContainerRuntimeVersion)
+		images = append(images, node.Status.NodeInfo.OSImage)
+	}
+	cluster.Versions["kubelet"] = distinct(kubelets)
+	cluster.Versions["containerRuntime"] = distinct(runtimes)
+	cluster.Versions["osImage"] = distinct(images)
+
+	included := func(namespace string) bool {
+		if len(namespaces) == 0 {
+			return true
+		}
+		for _, pattern := range namespaces {
+			if matched, _ := path.Match(pattern, namespace); matched {
+				return true
+			}
+		}
+		return false
+	}
+	add := func(object Object) {
+		if included(object.Resource.Namespace) {
+			cluster.Objects[object.Resource.String()] = object
+		}
+	}
+
+	apps := kc.Client.AppsV1()
+	deployments, err := apps.Deployments("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return cluster, fmt.Errorf("listing deployments of %s: %v", contextName, err)
+	}
+	for _, deployment := range deployments.Items {
+		add(workload("Deployment", deployment.ObjectMeta, deployment.Spec.Replicas, deployment.Spec.Template.Spec))
+	}
+	statefulSets, err := apps.StatefulSets("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return cluster, fmt.Errorf("listing statefulsets of %s: %v", contextName, err)
+	}
+	for _, statefulSet := range statefulSets.Items {
+		add(workload("StatefulSet", statefulSet.ObjectMeta, statefulSet.Spec.Replicas, statefulSet.Spec.Template.Spec))
+	}
+	daemonSets, err := apps.DaemonSets("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return cluster, fmt.Errorf("listing daemonsets of %s: %v", contextName, err)
+	}
+	for _, daemonSet := range daemonSets.Items {
+		add(workload("DaemonSet", daemonSet.ObjectMeta, nil, daemonSet.Spec.Template.Spec))
+	}
+	cronJobs, err := kc.Client.BatchV1().CronJobs("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return cluster, fmt.Errorf("listing cronjobs of %s: %v", contextName, err)
+	}
+	for _, cronJob := range cronJobs.Items {
+		object := workload("CronJob", cronJob.ObjectMeta, nil, cronJob.Spec.JobTemplate.Spec.Template.Spec)
+		object.Settings["schedule"] = cronJob.Spec.Schedule
+		add(object)
+	}
+
+	configMaps, err := kc.Client.CoreV1().ConfigMaps("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return cluster, fmt.Errorf("listing config maps of %s: %v", contextName, err)
+	}
+	for _, configMap := range configMaps.Items {
+		// config maps owned by other objects are generated, like the ones of leader elections
+		if skippedConfigMaps[configMap.Name] || len(configMap.OwnerReferences) > 0 {
+			continue
+		}
+		object := Object{Resource: models.ResourceRef{Kind: "ConfigMap", Namespace: configMap.Namespace, Name: configMap.Name}, Settings: make(map[string]string)}
+		// the values are compared by hash, config maps may hold credentials that must not end up in the output
+		for key, value := range configMap.Data {
+			object.Settings["data "+key] = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(value)))[:19]
+		}
+		for key, value := range configMap.BinaryData {
+			object.Settings["data "+key] = fmt.Sprintf("sha256:%x", sha256.Sum256(value))[:19]
+		}
+		add(object)
+	}
+	return cluster, nil
+}
+
+// workload returns the compared settings of a workload: the replicas, and the image, resources and
+// environment variable names of every container
+func workload(kind string, meta metav1.ObjectMeta, replicas *int32, spec v1.PodSpec) Object {
+	object := Object{Resource: models.ResourceRef{Kind: kind, Namespace: meta.Namespace, Name: meta.Name}, Settings: make(map[string]string)}
+	if replicas != nil {
+		object.Settings["replicas"] = strconv.Itoa(int(*replicas))
+	}
+	for _, container := range append(slices.Clone(spec.InitContainers), spec.Containers...) {
+		object.Settings["image "+container.Name] = container.Image
+		if requests := resources(container.Resources.Requests); requests != "" {
+			object.Settings["requests "+container.Name] = requests
+		}
+		if limits := resources(container.Resources.Limits); limits != "" {
+			object.Settings["limits "+container.Name] = limits
+		}
+		// the values of the variables usually differ between environments, like URLs
+		names := []string{}
+		for _, env := range container.Env {
+			names = append(names, env.Name)
+		}
+		if len(names) > 0 {
+			sort.Strings(names)
+			object.Settings["env "+container.Name] = strings.Join(names, ",")
+		}
+	}
+	return object
+}
+
+func resources(list v1.ResourceList) string {
+	parts := []string{}
+	for _, name := range slices.Sorted(maps.Keys(list)) {
+		quantity := list[name]
+		parts = append(parts, fmt.Sprintf("%s=%s", name, quantity.String()))
+	}
+	return strings.Join(parts, ",")
+}
+
+// distinct returns the distinct values sorted and joined
+func distinct(values []string) string {
+	slices.Sort(values)
+	return strings.Join(slices.Compact(values), ", ")
+}
+
+// Row is a value that is compared, Left and Right are empty when the cluster does not have it
+type Row struct {
+	Resource *models.ResourceRef `json:"resource,omitempty"`
+	Key      string              `json:"key"`
+	Left     string              `json:"left"`
+	Right    string              `json:"right"`
+}
+
+// Findings are the findings of only one of the clusters, Both counts the findings of both clusters
+type Findings struct {
+	OnlyLeft  []models.Finding `json:"onlyLeft"`
+	OnlyRight []models.Finding `json:"onlyRight"`
+	Both      int              `json:"both"`
+}
+
+// Comparison is the side by side comparison of two clusters
+type Comparison struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+	// Versions has a row for every version, equal or not
+	Versions []Row `json:"versions"`
+	// OnlyLeft and OnlyRight are the workloads and config maps present in only one cluster
+	OnlyLeft  []models.ResourceRef `json:"onlyLeft"`
+	OnlyRight []models.ResourceRef `json:"onlyRight"`
+	// Drift are the settings of the objects of both clusters that differ
+	Drift    []Row    `json:"drift"`
+	Findings Findings `json:"findings"`
+}
+
+// Compare compares the left with the right cluster. Suppressed findings are left out, findings of pods are
+// matched by the name of their workload since pod names differ between clusters.
+func Compare(left, right Cluster) Comparison {
+	comparison := Comparison{Left: left.Context, Right: right.Context, Versions: []Row{}, OnlyLeft: []models.ResourceRef{},
+		OnlyRight: []models.ResourceRef{}, Drift: []Row{}, Findings: Findings{OnlyLeft: []models.Finding{}, OnlyRight: []models.Finding{}}}
+
+	for _, key := range union(left.Versions, right.Versions) {
+		comparison.Versions = append(comparison.Versions, Row{Key: key, Left: left.Versions[key], Right: right.Versions[key]})
+	}
+
+	for _, key := range union(left.Objects, right.Objects) {
+		before, inLeft := left.Objects[key]
+		after, inRight := right.Objects[key]
+		switch {
+		case !inRight:
+			comparison.OnlyLeft = append(comparison.OnlyLeft, before.Resource)
+		case !inLeft:
+			comparison.OnlyRight = append(comparison.OnlyRight, after.Resource)
+		default:
+			resource := before.Resource
+			for _, setting := range union(before.Settings, after.Settings) {
+				if before.Settings[setting] != after.Settings[setting] {
+					comparison.Drift = append(comparison.Drift, Row{Resource: &resource, Key: setting, Left: before.Settings[setting], Right: after.Settings[setting]})
+				}
+			}
+		}
+	}
+
+	leftFindings, rightFindings := findingsByKey(left.Findings), findingsByKey(right.Findings)
+	for _, key := range union(leftFindings, rightFindings) {
+		finding, inLeft := leftFindings[key]
+		other, inRight := rightFindings[key]
+		switch {
+		case !inRight:
+			comparison.Findings.OnlyLeft = append(comparison.Findings.OnlyLeft, finding)
+		case !inLeft:
+			comparison.Findings.OnlyRight = append(comparison.Findings.OnlyRight, other)
+		default:
+			comparison.Findings.Both++
+		}
+	}
+	return comparison
+}
+
+// Differs returns true when the clusters differ in anything but findings both have
+func (c Comparison) Differs() bool {
+	for _, row := range c.Versions {
+		if row.Left != row.Right {
+			return true
+		}
+	}
+	return len(c.OnlyLeft)+len(c.OnlyRight)+len(c.Drift)+len(c.Findings.OnlyLeft)+len(c.Findings.OnlyRight) > 0
+}
+
+// findingsByKey keys the findings by check, reason and resource, pods by the name of their workload
+func findingsByKey(list []models.Finding) map[string]models.Finding {
+	byKey := make(map[string]models.Finding)
+	for _, finding := range list {
+		if finding.Suppressed {
+			continue
+		}
+		resource := finding.Resource
+		resource.Node = ""
+		if resource.Kind == "Pod" {
+			resource.Name = podSuffix.ReplaceAllString(resource.Name, "")
+		}
+		byKey[models.FindingID(finding.Check, resource, finding.Reason)] = finding
+	}
+	return byKey
+}
+
+// union returns the keys of both maps sorted
+func union[V any](left, right map[string]V) []string {
+	keys := slices.Collect(maps.Keys(left))
+	for key := range right {
+		if _, found := left[key]; !found {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
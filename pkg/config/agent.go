@@ -0,0 +1,28 @@
+package config
+
+// MaxShards is the most shards the namespaces of a cluster can be split into, the server keeps the
+// report of every shard until all of them reported
+const MaxShards = 64
+
+// Agent configures healthctl agent, which runs inside a cluster and pushes its reports to a fleet server
+type Agent struct {
+	// Server is the URL of the healthctl serve fleet server
+	Server string `json:"server,omitempty"`
+	// Interval between runs, defaults to 5m
+	Interval string `json:"interval,omitempty"`
+	// Suites are the suites run, defaults to all
+	Suites []string `json:"suites,omitempty"`
+	TLS    AgentTLS `json:"tls,omitempty"`
+	// Shards splits the namespaces of the cluster over this many agents, every agent checks one shard and
+	// the server merges their reports. The shard of an agent is the ordinal of its StatefulSet pod.
+	Shards int `json:"shards,omitempty"`
+	// Labels are added to the inventory labels of the cluster, the inventory of the server wins
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// AgentTLS is the client certificate of the agent and the CA of the server certificate
+type AgentTLS struct {
+	Cert string `json:"cert,omitempty"`
+	Key  string `json:"key,omitempty"`
+	CA   string `json:"ca,omitempty"`
+}
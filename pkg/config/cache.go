@@ -0,0 +1,10 @@
+package config
+
+// Cache keeps the results of the checks of healthctl check between runs, so a run shortly after another,
+// like a retried CI job, does not list the whole cluster again
+type Cache struct {
+	// TTL is how long the results of a check are reused, the cache is off without it
+	TTL string `json:"ttl,omitempty"`
+	// Checks overrides the time to live of checks by their name as suite/label, 0 never caches a check
+	Checks map[string]string `json:"checks,omitempty"`
+}
@@ -0,0 +1,31 @@
+package config
+
+// Canary configures the canary analysis
+type Canary struct {
+	// MaxIncreasePercent is how much the cpu, memory and query results of the canary may exceed the
+	// baseline, defaults to 20
+	MaxIncreasePercent float64           `json:"maxIncreasePercent,omitempty"`
+	Prometheus         PrometheusService `json:"prometheus,omitempty"`
+	Queries            []CanaryQuery     `json:"queries,omitempty"`
+}
+
+// PrometheusService is the Prometheus service queried through the API server proxy
+type PrometheusService struct {
+	Namespace string `json:"namespace"`
+	Service   string `json:"service"`
+	// Port defaults to 9090
+	Port string `json:"port,omitempty"`
+}
+
+// CanaryQuery is a Prometheus query run for the baseline and the canary, where higher values are worse.
+// The query is a template over {{.Namespace}}, {{.Deployment}}, {{.Pods}}, a regex of the pod names,
+// and {{.Window}}, the duration of the analysis.
+type CanaryQuery struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	// MaxIncreasePercent overrides the allowed increase for this query
+	MaxIncreasePercent float64 `json:"maxIncreasePercent,omitempty"`
+	// PerPod divides the result by the pods of the deployment, for queries that sum up over the pods like
+	// the number of errors, so a canary with fewer pods does not look healthier
+	PerPod bool `json:"perPod,omitempty"`
+}
@@ -0,0 +1,379 @@
+package config
+
+// Checks holds the settings of the individual checks
+type Checks struct {
+	GPU        GPUCheck        `json:"gpu,omitempty"`
+	Autoscaler AutoscalerCheck `json:"autoscaler,omitempty"`
+	Spot       SpotCheck       `json:"spot,omitempty"`
+	Kafka      KafkaCheck      `json:"kafka,omitempty"`
+	Minio      MinioCheck      `json:"minio,omitempty"`
+	Redis      RedisCheck      `json:"redis,omitempty"`
+
+	SharedFilesystems SharedFilesystemCheck `json:"sharedFilesystems,omitempty"`
+	StorageClasses    StorageClassCheck     `json:"storageClasses,omitempty"`
+	Metrics           MetricsCheck          `json:"metrics,omitempty"`
+	MultiArch         MultiArchCheck        `json:"multiArch,omitempty"`
+	Priority          PriorityCheck         `json:"priority,omitempty"`
+	Capacity          CapacityCheck         `json:"capacity,omitempty"`
+	Evictions         EvictionCheck         `json:"evictions,omitempty"`
+	Network           NetworkCheck          `json:"network,omitempty"`
+	EphemeralStorage  EphemeralStorageCheck `json:"ephemeralStorage,omitempty"`
+	LeaderElection    LeaderElectionCheck   `json:"leaderElection,omitempty"`
+	Admission         AdmissionCheck        `json:"admission,omitempty"`
+	Finalizers        FinalizerCheck        `json:"finalizers,omitempty"`
+	ServiceAccounts   ServiceAccountCheck   `json:"serviceAccounts,omitempty"`
+	Vulnerabilities   VulnerabilityCheck    `json:"vulnerabilities,omitempty"`
+	ImageProvenance   ImageProvenanceCheck  `json:"imageProvenance,omitempty"`
+	DisasterRecovery  DisasterRecoveryCheck `json:"disasterRecovery,omitempty"`
+	Alertmanager      AlertmanagerCheck     `json:"alertmanager,omitempty"`
+	Logging           LoggingCheck          `json:"logging,omitempty"`
+	Probes            ProbeCheck            `json:"probes,omitempty"`
+	Startup           StartupCheck          `json:"startup,omitempty"`
+	Profiles          ProfileCheck          `json:"profiles,omitempty"`
+	Drift             DriftCheck            `json:"drift,omitempty"`
+	// Inventory are the objects the namespaces must contain
+	Inventory []ExpectedNamespace `json:"inventory,omitempty"`
+}
+
+// GPUCheck configures the GPU and extended resource check
+type GPUCheck struct {
+	// Resources are the extended resources to check, defaults to nvidia.com/gpu
+	Resources []string `json:"resources,omitempty"`
+	// NodeSelector selects the nodes that must advertise the resources, in addition to nodes labelled by gpu-feature-discovery
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// ExpectedPerNode is the capacity every selected node must advertise, defaults to the nvidia.com/gpu.count label
+	ExpectedPerNode int64 `json:"expectedPerNode,omitempty"`
+}
+
+// AutoscalerCheck configures where the cluster-autoscaler status is read from
+type AutoscalerCheck struct {
+	Namespace       string `json:"namespace,omitempty"`
+	StatusConfigMap string `json:"statusConfigMap,omitempty"`
+}
+
+// SpotCheck adds node labels and a taint that mark spot nodes, on top of the well known cloud provider labels
+type SpotCheck struct {
+	NodeLabels map[string]string `json:"nodeLabels,omitempty"`
+	Taint      string            `json:"taint,omitempty"`
+}
+
+// KafkaCheck configures the consumer group lag thresholds, the lag is read from a broker pod
+type KafkaCheck struct {
+	Namespace       string          `json:"namespace,omitempty"`
+	PodSelector     string          `json:"podSelector,omitempty"`
+	Container       string          `json:"container,omitempty"`
+	BootstrapServer string          `json:"bootstrapServer,omitempty"`
+	ConsumerGroups  []ConsumerGroup `json:"consumerGroups,omitempty"`
+}
+
+// ConsumerGroup is the lag threshold of the consumer group of an application
+type ConsumerGroup struct {
+	Group       string `json:"group"`
+	Application string `json:"application,omitempty"`
+	MaxLag      int64  `json:"maxLag"`
+}
+
+// RedisCheck configures the expected runtime configuration of the redis nodes
+type RedisCheck struct {
+	// Namespace defaults to fed-redis-cluster
+	Namespace   string `json:"namespace,omitempty"`
+	PodSelector string `json:"podSelector,omitempty"`
+	// Container defaults to redis-node
+	Container string `json:"container,omitempty"`
+	// Expected are CONFIG GET parameters and their expected values, e.g. appendonly: "yes"
+	Expected map[string]string `json:"expected,omitempty"`
+}
+
+// AlertmanagerCheck configures the Alertmanager pod the configuration is read from with amtool
+type AlertmanagerCheck struct {
+	// Namespace defaults to fed-prometheus
+	Namespace string `json:"namespace,omitempty"`
+	// Pod defaults to alertmanager-prometheus-alerts-0
+	Pod string `json:"pod,omitempty"`
+	// Container defaults to alertmanager
+	Container string `json:"container,omitempty"`
+	// URL is the Alertmanager URL inside the pod, defaults to http://localhost:9093
+	URL string `json:"url,omitempty"`
+}
+
+// LoggingCheck configures the logging pipeline check
+type LoggingCheck struct {
+	// Namespace and DaemonSet select the logging agent, by default the first DaemonSet of a known log
+	// shipper in all namespaces
+	Namespace string `json:"namespace,omitempty"`
+	DaemonSet string `json:"daemonSet,omitempty"`
+	// MetricsPort is the Fluent Bit HTTP server port, defaults to 2020
+	MetricsPort string     `json:"metricsPort,omitempty"`
+	Backend     LogBackend `json:"backend,omitempty"`
+	// MaxLatency is how long the canary log line may take to arrive, e.g. 2m, defaults to 1m
+	MaxLatency string `json:"maxLatency,omitempty"`
+}
+
+// LogBackend is the Loki or Elasticsearch service the canary log line is searched in
+type LogBackend struct {
+	// Type is loki or elasticsearch, the canary is skipped when it is empty
+	Type      string `json:"type,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Service   string `json:"service,omitempty"`
+	// Port defaults to 3100 for loki and 9200 for elasticsearch
+	Port string `json:"port,omitempty"`
+	// Selector is the Loki stream selector, defaults to the namespace of the probe pods
+	Selector string `json:"selector,omitempty"`
+	// Index is the Elasticsearch index pattern, defaults to *
+	Index string `json:"index,omitempty"`
+}
+
+// MinioCheck configures where MinIO runs and the bucket used for the canary object
+type MinioCheck struct {
+	Namespace    string `json:"namespace,omitempty"`
+	Service      string `json:"service,omitempty"`
+	Port         string `json:"port,omitempty"`
+	PodSelector  string `json:"podSelector,omitempty"`
+	Container    string `json:"container,omitempty"`
+	CanaryBucket string `json:"canaryBucket,omitempty"`
+	SkipCanary   bool   `json:"skipCanary,omitempty"`
+}
+
+// SharedFilesystemCheck configures the NFS and shared filesystem mount probe
+type SharedFilesystemCheck struct {
+	// Drivers are additional CSI drivers of shared filesystems
+	Drivers []string `json:"drivers,omitempty"`
+	// LaunchProbe starts a probe pod for ReadWriteMany claims that no running pod mounts
+	LaunchProbe bool   `json:"launchProbe,omitempty"`
+	ProbeImage  string `json:"probeImage,omitempty"`
+}
+
+// StorageClassCheck configures the canary claim that proves every storage class can provision volumes
+type StorageClassCheck struct {
+	Canary          bool   `json:"canary,omitempty"`
+	CanaryNamespace string `json:"canaryNamespace,omitempty"`
+	CanarySize      string `json:"canarySize,omitempty"`
+}
+
+// MetricsCheck configures the freshness of the resource metrics pipeline
+type MetricsCheck struct {
+	// MaxAge is how old node metrics may be, e.g. 2m, defaults to 5m
+	MaxAge string `json:"maxAge,omitempty"`
+}
+
+// MultiArchCheck lists architectures images must support in addition to the ones of the current nodes,
+// e.g. arm64 before a node pool migration
+type MultiArchCheck struct {
+	TargetArchitectures []string `json:"targetArchitectures,omitempty"`
+}
+
+// PriorityCheck configures the critical tier used for the priority class and preemption analysis
+type PriorityCheck struct {
+	// CriticalNamespaces are namespace globs, defaults to kube-system
+	CriticalNamespaces []string `json:"criticalNamespaces,omitempty"`
+	// ScaleUpFactor is how much the critical tier scales up in the preemption simulation, defaults to 2
+	ScaleUpFactor float64 `json:"scaleUpFactor,omitempty"`
+}
+
+// CapacityCheck configures the capacity headroom forecast
+type CapacityCheck struct {
+	// Horizon is how far ahead exhausted headroom is reported, e.g. 168h, defaults to 14 days
+	Horizon string `json:"horizon,omitempty"`
+	// PoolLabel is the node label naming the node pool, the labels of the cloud providers are used by default
+	PoolLabel string `json:"poolLabel,omitempty"`
+}
+
+// StartupCheck configures when the startup of a workload counts as degraded
+type StartupCheck struct {
+	// Window is how long startups count as recent, e.g. 6h, defaults to 24h
+	Window string `json:"window,omitempty"`
+	// DegradedFactor is how many times slower the recent median startup of a workload must be than the
+	// median before the window, defaults to 2
+	DegradedFactor float64 `json:"degradedFactor,omitempty"`
+	// MinPods is how many startups within and before the window a workload needs to be compared, defaults to 3
+	MinPods int `json:"minPods,omitempty"`
+}
+
+// ProfileCheck configures the pprof capture of Go services whose memory usage nears its limit
+type ProfileCheck struct {
+	// Targets select the Go services, the check is skipped without targets
+	Targets []ProfileTarget `json:"targets,omitempty"`
+	// MemoryPercent is the memory usage of a container in percent of its limit that triggers a capture,
+	// defaults to 90
+	MemoryPercent int `json:"memoryPercent,omitempty"`
+	// Profiles are the captured profiles, heap, goroutine and cpu by default
+	Profiles []string `json:"profiles,omitempty"`
+	// CPUDuration is how long the CPU profile is recorded, e.g. 10s, defaults to 30s
+	CPUDuration string `json:"cpuDuration,omitempty"`
+	// Cooldown is how long a pod is not captured again, e.g. 30m, defaults to 1h
+	Cooldown string `json:"cooldown,omitempty"`
+	// Keep is how many captures are kept per pod, defaults to 5
+	Keep int `json:"keep,omitempty"`
+}
+
+// ProfileTarget selects the pods of a Go service, Port is the pprof port when the pods do not declare
+// it with the healthctl.io/pprof-port annotation or a container port named pprof, defaults to 6060
+type ProfileTarget struct {
+	Namespace string `json:"namespace"`
+	Selector  string `json:"selector,omitempty"`
+	Port      int    `json:"port,omitempty"`
+}
+
+// DriftCheck configures the comparison of live objects with the manifests in source control, the manifests
+// are read from Directory or from Path of a clone of Repository
+type DriftCheck struct {
+	// Namespaces are namespace globs whose objects are compared, the check is skipped without namespaces
+	Namespaces []string `json:"namespaces,omitempty"`
+	Directory  string   `json:"directory,omitempty"`
+	// Repository is a Git URL, Ref its branch or tag, defaults to the default branch
+	Repository string `json:"repository,omitempty"`
+	Ref        string `json:"ref,omitempty"`
+	Path       string `json:"path,omitempty"`
+	// DefaultNamespace is the namespace of manifests without one, they are skipped without it
+	DefaultNamespace string `json:"defaultNamespace,omitempty"`
+	// Ignore are field paths that may differ, e.g. spec.replicas of workloads scaled by an autoscaler or
+	// spec.template.spec.containers.image of images updated by a controller
+	Ignore []string `json:"ignore,omitempty"`
+}
+
+// ExpectedNamespace is a namespace and the objects it must contain
+type ExpectedNamespace struct {
+	Namespace string           `json:"namespace"`
+	Objects   []ExpectedObject `json:"objects"`
+}
+
+// ExpectedObject is an object that must exist. Kind is a kind like Deployment, or kind.group like
+// Certificate.cert-manager.io when several groups serve the kind. MinReplicas is the replicas a workload
+// must have and be ready.
+type ExpectedObject struct {
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	MinReplicas int    `json:"minReplicas,omitempty"`
+}
+
+// EvictionCheck configures the OOMKill and eviction history
+type EvictionCheck struct {
+	// Window is how far back OOMKills and evictions are counted, e.g. 6h, defaults to 24h
+	Window string `json:"window,omitempty"`
+}
+
+// NetworkCheck configures the probe pods of the network suite
+type NetworkCheck struct {
+	// ProbeNodes is how many nodes are probed, defaults to 3
+	ProbeNodes     int    `json:"probeNodes,omitempty"`
+	ProbeNamespace string `json:"probeNamespace,omitempty"`
+	ProbeImage     string `json:"probeImage,omitempty"`
+	// DNSName is the name looked up by the DNS probe, defaults to kubernetes.default.svc.cluster.local
+	DNSName    string `json:"dnsName,omitempty"`
+	DNSQueries int    `json:"dnsQueries,omitempty"`
+	// MaxDNSLatency is the p95 DNS latency that is reported, e.g. 50ms, defaults to 100ms
+	MaxDNSLatency string `json:"maxDNSLatency,omitempty"`
+	// RouteSamples is how many ClusterIP services are probed besides the kubernetes service, defaults to 5
+	RouteSamples int `json:"routeSamples,omitempty"`
+	// ServiceCIDR is the service IP range, comma separated for dual stack, when it can not be read from the cluster
+	ServiceCIDR string `json:"serviceCIDR,omitempty"`
+}
+
+// EphemeralStorageCheck configures when node filesystems are reported
+type EphemeralStorageCheck struct {
+	// NodeFsPercent is the nodefs and imagefs usage that is reported, defaults to 80
+	NodeFsPercent int `json:"nodeFsPercent,omitempty"`
+}
+
+// LeaderElectionCheck lists the leases of HA controllers and operators to check,
+// the kube-controller-manager, kube-scheduler and cloud-controller-manager leases are checked when they exist
+type LeaderElectionCheck struct {
+	Leases []LeaseRef `json:"leases,omitempty"`
+	// MaxTransitionsPerHour is how often the holder of a lease may change, defaults to 2
+	MaxTransitionsPerHour float64 `json:"maxTransitionsPerHour,omitempty"`
+}
+
+// LeaseRef names a coordination.k8s.io Lease
+type LeaseRef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// AdmissionCheck configures the dry-run canary that measures admission webhook latency
+type AdmissionCheck struct {
+	// Namespaces are probed instead of a sample of all namespaces
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Samples is how many namespaces are probed, defaults to 5
+	Samples int `json:"samples,omitempty"`
+	// MaxLatency is the admission latency that is reported, e.g. 500ms, defaults to 1s
+	MaxLatency string `json:"maxLatency,omitempty"`
+}
+
+// FinalizerCheck configures when terminating objects are reported as stuck
+type FinalizerCheck struct {
+	// StuckAfter is how long an object may be terminating, e.g. 30m, defaults to 10m
+	StuckAfter string `json:"stuckAfter,omitempty"`
+}
+
+// ServiceAccountCheck configures the workload identity validation
+type ServiceAccountCheck struct {
+	// VerifyRoles looks up the cloud roles of workload identity annotations with the aws, gcloud or az CLI,
+	// which must be installed and logged in
+	VerifyRoles bool `json:"verifyRoles,omitempty"`
+}
+
+// VulnerabilityCheck configures the scanner the CVE counts of running images are read from
+type VulnerabilityCheck struct {
+	// Scanner is trivy-operator, harbor or ecr, trivy-operator is used by default when it is installed
+	Scanner string        `json:"scanner,omitempty"`
+	Harbor  HarborScanner `json:"harbor,omitempty"`
+	// MaxCritical and MaxHigh are the CVEs allowed per namespace, defaults to 0 critical and 10 high
+	MaxCritical *int `json:"maxCritical,omitempty"`
+	MaxHigh     *int `json:"maxHigh,omitempty"`
+}
+
+// HarborScanner is the Harbor registry scan results are read from
+type HarborScanner struct {
+	URL      string `json:"url,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// ImageProvenanceCheck configures the registry allow-list and cosign signature verification of running images
+type ImageProvenanceCheck struct {
+	// Namespaces are namespace globs, defaults to all namespaces
+	Namespaces []string `json:"namespaces,omitempty"`
+	// AllowedRegistries are registry globs like *.dkr.ecr.*.amazonaws.com or registry paths like ghcr.io/example
+	AllowedRegistries []string     `json:"allowedRegistries,omitempty"`
+	Cosign            CosignVerify `json:"cosign,omitempty"`
+}
+
+// CosignVerify configures the cosign CLI, images are verified against Key when it is set and keyless
+// against the certificate Identity and Issuer regexps otherwise, keyless verification requires both
+type CosignVerify struct {
+	Key      string `json:"key,omitempty"`
+	Identity string `json:"identity,omitempty"`
+	Issuer   string `json:"issuer,omitempty"`
+}
+
+// ProbeCheck configures the readiness and liveness probe audit
+type ProbeCheck struct {
+	// Namespaces are namespace globs, the audit is skipped without namespaces
+	Namespaces []string `json:"namespaces,omitempty"`
+	// SlowStarting are image globs of applications that take long to start, like *elasticsearch*, their
+	// liveness probes need an initial delay or a startup probe
+	SlowStarting []string `json:"slowStarting,omitempty"`
+	// SkipEndpoints does not call the HTTP endpoints of the probes
+	SkipEndpoints bool `json:"skipEndpoints,omitempty"`
+}
+
+// DisasterRecoveryCheck configures the disaster recovery readiness check
+type DisasterRecoveryCheck struct {
+	// MaxBackupAge is how old the newest cluster backup may be, e.g. 12h, defaults to 24h
+	MaxBackupAge string `json:"maxBackupAge,omitempty"`
+	// VeleroNamespace is where Velero runs, defaults to velero
+	VeleroNamespace string `json:"veleroNamespace,omitempty"`
+	// Registry is the DR registry every running image must be mirrored to, e.g. dr.example.com/mirror
+	Registry string `json:"registry,omitempty"`
+	// Datastores document the RPO of the datastore StatefulSets
+	Datastores []Datastore `json:"datastores,omitempty"`
+}
+
+// Datastore is the documented RPO of a datastore StatefulSet. Its newest backup is the last successful run
+// of CronJob, or the newest Velero backup of its namespace when no CronJob is set.
+type Datastore struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	RPO       string `json:"rpo"`
+	CronJob   string `json:"cronJob,omitempty"`
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"healthctl/pkg/k8s"
+
+	"k8s.io/client-go/util/homedir"
+	"sigs.k8s.io/yaml"
+)
+
+// StatePath returns the path of a file in the healthctl state directory
+func StatePath(name string) string {
+	return filepath.Join(homedir.HomeDir(), ".healthctl", name)
+}
+
+// ClusterStatePath returns the path of a state file of a cluster, so clusters checked from the same machine
+// keep their state apart. Without a cluster name the file is in the state directory itself.
+func ClusterStatePath(cluster, name string) string {
+	if cluster == "" {
+		return StatePath(name)
+	}
+	return filepath.Join(homedir.HomeDir(), ".healthctl", "clusters", url.PathEscape(cluster), name)
+}
+
+// Config is the healthctl configuration file
+type Config struct {
+	Connection k8s.ConnectionOptions `json:"connection,omitempty"`
+	Teams      []Team                `json:"teams,omitempty"`
+	Notifier   Notifier              `json:"notifier,omitempty"`
+	Checks     Checks                `json:"checks,omitempty"`
+	Cost       k8s.CostModel         `json:"cost,omitempty"`
+	Drill      Drill                 `json:"drill,omitempty"`
+	Canary     Canary                `json:"canary,omitempty"`
+	Serve      Serve                 `json:"serve,omitempty"`
+	Tickets    Tickets               `json:"tickets,omitempty"`
+	Agent      Agent                 `json:"agent,omitempty"`
+	NodeAgent  NodeAgent             `json:"nodeAgent,omitempty"`
+	Outputs    []Output              `json:"outputs,omitempty"`
+	Exporters  []Exporter            `json:"exporters,omitempty"`
+	Grafana    Grafana               `json:"grafana,omitempty"`
+	Events     Events                `json:"events,omitempty"`
+	Hysteresis Hysteresis            `json:"hysteresis,omitempty"`
+	Cache      Cache                 `json:"cache,omitempty"`
+	Debug      k8s.DebugPorts        `json:"debug,omitempty"`
+
+	KnowledgeBase KnowledgeBase       `json:"knowledgeBase,omitempty"`
+	Runbooks      []Runbook           `json:"runbooks,omitempty"`
+	Inventory     []ClusterInventory  `json:"inventory,omitempty"`
+	Maintenance   []MaintenanceWindow `json:"maintenance,omitempty"`
+}
+
+// Load reads a yaml or json configuration file, a missing file returns an empty configuration. SOPS
+// encrypted files are decrypted, ${{ }} templates are rendered with the variables of the cluster, and
+// ${env:NAME} and ${file:/path} references in values are replaced.
+func Load(file string, vars TemplateVars) (*Config, error) {
+	cfg := &Config{}
+	data, err := read(file, vars)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %v", file, err)
+	}
+	if err := cfg.resolveLocal(); err != nil {
+		return nil, fmt.Errorf("config file %s: %v", file, err)
+	}
+	return cfg, nil
+}
+
+// read returns the content of a configuration file, decrypted and with its templates rendered
+func read(file string, vars TemplateVars) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if sopsEncrypted(data) {
+		if data, err = sopsDecrypt(file); err != nil {
+			return nil, err
+		}
+	}
+	if hasTemplates(data) {
+		return expandTemplates(file, data, vars)
+	}
+	return data, nil
+}
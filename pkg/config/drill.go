@@ -0,0 +1,15 @@
+package config
+
+// Drill configures the backup and restore drill, Velero is used unless backup and restore commands are set
+type Drill struct {
+	// Namespace is the canary namespace that is backed up and restored
+	Namespace string `json:"namespace,omitempty"`
+	// VeleroNamespace is where Velero runs, defaults to velero
+	VeleroNamespace string `json:"veleroNamespace,omitempty"`
+	// BackupCommand and RestoreCommand replace Velero, they run in a local shell with DRILL_NAME,
+	// DRILL_NAMESPACE and DRILL_TARGET set and must only return when the backup or restore finished
+	BackupCommand  string `json:"backupCommand,omitempty"`
+	RestoreCommand string `json:"restoreCommand,omitempty"`
+	// Timeout is how long every step of the drill may take, e.g. 30m, defaults to 15m
+	Timeout string `json:"timeout,omitempty"`
+}
@@ -0,0 +1,16 @@
+package config
+
+// Events records Kubernetes Events on the objects of findings when they open and resolve, so kubectl
+// describe shows the assessment of healthctl next to the other events of the object. serve records them
+// for the clusters it checks itself, the agent for its cluster.
+type Events struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Severity is the lowest severity events are recorded for, defaults to warning
+	Severity string `json:"severity,omitempty"`
+	// Refresh is how often the event of a finding that stays open is recorded again, defaults to 45m. The
+	// API server drops events after an hour by default.
+	Refresh string `json:"refresh,omitempty"`
+	// ConfigMap is the namespace/name of a config map that holds the score and the open findings of the
+	// last run
+	ConfigMap string `json:"configMap,omitempty"`
+}
@@ -0,0 +1,32 @@
+package config
+
+// Exporter pushes the check statuses and usage metrics of every run to a time series database, for
+// organizations that do not scrape healthctl
+type Exporter struct {
+	Influx      *InfluxExporter      `json:"influx,omitempty"`
+	RemoteWrite *RemoteWriteExporter `json:"remoteWrite,omitempty"`
+	// Labels are added to every series, e.g. the environment
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// InfluxExporter writes InfluxDB line protocol. With a bucket the v2 API is used with the token, with a
+// database the v1 API with the username and password. VictoriaMetrics accepts both.
+type InfluxExporter struct {
+	URL      string `json:"url"`
+	Org      string `json:"org,omitempty"`
+	Bucket   string `json:"bucket,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Database string `json:"database,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// RemoteWriteExporter sends Prometheus remote write requests, e.g. to VictoriaMetrics, Mimir, Thanos or a
+// Prometheus with the remote write receiver enabled
+type RemoteWriteExporter struct {
+	URL         string            `json:"url"`
+	BearerToken string            `json:"bearerToken,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	Password    string            `json:"password,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
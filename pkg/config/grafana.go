@@ -0,0 +1,15 @@
+package config
+
+// Grafana is where healthctl grafana provision creates the dashboards of the exported metrics. Without a
+// username the token is sent as bearer token, as used by service account tokens.
+type Grafana struct {
+	URL      string `json:"url,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Folder is the title of the folder of the dashboards, defaults to healthctl
+	Folder string `json:"folder,omitempty"`
+	// Datasource is the uid of the Prometheus datasource the dashboards query by default, the datasource
+	// the exporters write to
+	Datasource string `json:"datasource,omitempty"`
+}
@@ -0,0 +1,43 @@
+package config
+
+// Hysteresis holds a finding back until it is reported in several consecutive runs and keeps it open until
+// it is absent in several runs, so a single slow or lucky run does not open and close findings
+type Hysteresis struct {
+	// OpenAfter is the number of consecutive runs a finding has to be reported in before it is, defaults to 1
+	OpenAfter int `json:"openAfter,omitempty"`
+	// CloseAfter is the number of consecutive runs a finding has to be absent in before it is resolved,
+	// defaults to 1
+	CloseAfter int `json:"closeAfter,omitempty"`
+	// Checks overrides the run counts of checks by their name as suite/label
+	Checks map[string]CheckHysteresis `json:"checks,omitempty"`
+	// Flapping replaces the findings of a check that keep opening and closing by a single finding
+	Flapping *Flapping `json:"flapping,omitempty"`
+}
+
+// CheckHysteresis are the run counts of a check, unset counts fall back to the hysteresis of all checks
+type CheckHysteresis struct {
+	OpenAfter  int `json:"openAfter,omitempty"`
+	CloseAfter int `json:"closeAfter,omitempty"`
+}
+
+// Flapping detects findings that open or close at least Changes times within Window
+type Flapping struct {
+	// Changes defaults to 4
+	Changes int `json:"changes,omitempty"`
+	// Window defaults to 6h
+	Window string `json:"window,omitempty"`
+}
+
+// Runs returns the number of runs a finding of the check has to be reported in to open and absent in to close
+func (h Hysteresis) Runs(check string) (openAfter, closeAfter int) {
+	openAfter, closeAfter = max(h.OpenAfter, 1), max(h.CloseAfter, 1)
+	if override, found := h.Checks[check]; found {
+		if override.OpenAfter > 0 {
+			openAfter = override.OpenAfter
+		}
+		if override.CloseAfter > 0 {
+			closeAfter = override.CloseAfter
+		}
+	}
+	return openAfter, closeAfter
+}
@@ -0,0 +1,18 @@
+package config
+
+// ClusterInventory labels a cluster of the fleet, e.g. with its environment, region and owner. Configured
+// labels win over the labels healthctl discovers, like version, provider and region.
+type ClusterInventory struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+}
+
+// InventoryLabels returns the configured labels of a cluster
+func (c *Config) InventoryLabels(cluster string) map[string]string {
+	for _, inventory := range c.Inventory {
+		if inventory.Name == cluster {
+			return inventory.Labels
+		}
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package config
+
+// KnowledgeBase links findings to remediation hints and runbooks
+type KnowledgeBase struct {
+	// BaseURL is the default runbook location, findings without a URL link to <baseURL>/<suite>-<check>
+	BaseURL string           `json:"baseURL,omitempty"`
+	Entries []KnowledgeEntry `json:"entries,omitempty"`
+}
+
+// KnowledgeEntry is the remediation of the findings of a check, optionally only for one reason
+type KnowledgeEntry struct {
+	// Check is a glob on the check name, e.g. k8s/Pods or storage/*
+	Check  string `json:"check"`
+	Reason string `json:"reason,omitempty"`
+	Hint   string `json:"hint,omitempty"`
+	URL    string `json:"url,omitempty"`
+}
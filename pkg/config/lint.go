@@ -0,0 +1,531 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"healthctl/pkg/cron"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/schema"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// slackUserID matches the IDs of slack users, U for users of the workspace and W for enterprise grid users
+var slackUserID = regexp.MustCompile(`^[UW][A-Z0-9]+$`)
+
+// Issue is a problem of a configuration file found by Lint, at the path of the key, e.g. serve.suites[1]
+type Issue struct {
+	Path    string
+	Message string
+}
+
+func (i Issue) String() string {
+	if i.Path == "" {
+		return i.Message
+	}
+	return fmt.Sprintf("%s: %s", i.Path, i.Message)
+}
+
+// References are the names config values refer to that are defined outside of the config
+type References struct {
+	Suites []string
+	// Checks are the check names as suite/label
+	Checks     []string
+	Roles      []string
+	Severities []string
+	// Formats are the report output formats
+	Formats []string
+}
+
+// Lint validates a configuration file against the schema of the config and the references, so a typo does
+// not silently turn off a check or a notification. Env, file and secret references are not resolved.
+func Lint(file string, vars TemplateVars, refs References) ([]Issue, error) {
+	data, err := read(file, vars)
+	if err != nil {
+		return nil, err
+	}
+	document := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return []Issue{{Message: fmt.Sprintf("invalid yaml: %v", err)}}, nil
+	}
+	issues := lintKeys("", document, reflect.TypeOf(Config{}))
+
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return append(issues, Issue{Message: fmt.Sprintf("invalid value: %v", err)}), nil
+	}
+	l := &linter{refs: refs}
+	l.lint(cfg)
+	sort.SliceStable(l.issues, func(i, j int) bool { return l.issues[i].Path < l.issues[j].Path })
+	return append(issues, l.issues...), nil
+}
+
+// lintKeys reports unknown and missing required keys of a parsed yaml document of the Go type
+func lintKeys(at string, value interface{}, t reflect.Type) []Issue {
+	issues := []Issue{}
+	t = schema.Indirect(t)
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return issues
+		}
+		known := map[string]schema.Field{}
+		for _, f := range schema.Fields(t) {
+			known[f.Name] = f
+			if _, found := object[f.Name]; !found && f.Required {
+				issues = append(issues, Issue{Path: at, Message: fmt.Sprintf("missing required key %s", f.Name)})
+			}
+		}
+		keys := []string{}
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			f, found := known[key]
+			if !found {
+				issues = append(issues, Issue{Path: join(at, key), Message: unknownKey(key, known)})
+				continue
+			}
+			issues = append(issues, lintKeys(join(at, key), object[key], f.Type)...)
+		}
+	case reflect.Map:
+		if object, ok := value.(map[string]interface{}); ok {
+			for key, item := range object {
+				issues = append(issues, lintKeys(join(at, key), item, t.Elem())...)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if items, ok := value.([]interface{}); ok {
+			for i, item := range items {
+				issues = append(issues, lintKeys(fmt.Sprintf("%s[%d]", at, i), item, t.Elem())...)
+			}
+		}
+	}
+	return issues
+}
+
+func join(at, key string) string {
+	if at == "" {
+		return key
+	}
+	return at + "." + key
+}
+
+// unknownKey describes an unknown key, with the known key it differs from only in case
+func unknownKey(key string, known map[string]schema.Field) string {
+	for name := range known {
+		if strings.EqualFold(name, key) {
+			return fmt.Sprintf("unknown key, did you mean %s?", name)
+		}
+	}
+	return "unknown key"
+}
+
+// linter collects the issues of the values of a parsed config
+type linter struct {
+	refs   References
+	issues []Issue
+}
+
+func (l *linter) add(at, format string, args ...interface{}) {
+	l.issues = append(l.issues, Issue{Path: at, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) lint(c *Config) {
+	teams := map[string]bool{Unassigned: true}
+	for i, team := range c.Teams {
+		at := fmt.Sprintf("teams[%d]", i)
+		if teams[team.Name] {
+			l.add(at, "team %s is defined twice, the second one owns nothing", team.Name)
+		}
+		teams[team.Name] = true
+		if len(team.Namespaces) == 0 && len(team.NamespaceLabels) == 0 {
+			l.add(at, "team %s has no namespaces or namespaceLabels and owns nothing", team.Name)
+		}
+		for j, pattern := range team.Namespaces {
+			l.glob(fmt.Sprintf("%s.namespaces[%d]", at, j), pattern)
+		}
+	}
+	if slack := c.Notifier.Slack; slack != nil {
+		for team := range slack.Teams {
+			if !teams[team] {
+				l.add("notifier.slack.teams."+team, "team %s is not defined in teams", team)
+			}
+		}
+	} else if c.Serve.Notify {
+		l.add("serve.notify", "no notifier is configured, nothing is sent")
+	}
+
+	if slack := c.Serve.Slack; slack != nil {
+		if slack.SigningSecret == "" {
+			l.add("serve.slack.signingSecret", "the signing secret of the slack app is required")
+		}
+		for user := range slack.Users {
+			if user != "*" && !slackUserID.MatchString(user) {
+				l.add("serve.slack.users."+user, "%s is not a slack user ID like U024BE7LH, users are not matched by name", user)
+			}
+		}
+	}
+	l.suites("serve.suites", c.Serve.Suites)
+	l.suites("serve.alertSuites", c.Serve.AlertSuites)
+	l.suites("agent.suites", c.Agent.Suites)
+	if c.Connection.Exec.Protocol != "" {
+		l.oneOf("connection.exec.protocol", c.Connection.Exec.Protocol, []string{k8s.ExecSPDY, k8s.ExecWebSocket, k8s.ExecAuto})
+	}
+	if c.Connection.Exec.PingPeriod != "0" {
+		// 0 turns the pings off
+		l.duration("connection.exec.pingPeriod", c.Connection.Exec.PingPeriod)
+	}
+	l.minimum("connection.exec.maxConcurrent", float64(c.Connection.Exec.MaxConcurrent), 0)
+	l.duration("connection.exec.timeout", c.Connection.Exec.Timeout)
+	l.port("debug.port", c.Debug.Port)
+	for service, port := range c.Debug.Services {
+		l.port("debug.services."+service, port)
+	}
+	if c.Connection.As == "" && len(c.Connection.AsGroups) > 0 {
+		l.add("connection.asGroups", "groups can only be impersonated with a user in connection.as")
+	}
+	l.duration("serve.interval", c.Serve.Interval)
+	l.duration("serve.agentTimeout", c.Serve.AgentTimeout)
+	if c.Serve.NotifyReminder != "0" {
+		// 0 turns the reminders off
+		l.duration("serve.notifyReminder", c.Serve.NotifyReminder)
+	}
+	l.duration("agent.interval", c.Agent.Interval)
+	l.minimum("serve.shards", float64(c.Serve.Shards), 0)
+	l.maximum("serve.shards", float64(c.Serve.Shards), MaxShards)
+	if c.Serve.Operator != nil {
+		l.duration("serve.operator.interval", c.Serve.Operator.Interval)
+		l.duration("serve.operator.minInterval", c.Serve.Operator.MinInterval)
+		l.suites("serve.operator.suites", c.Serve.Operator.Suites)
+	}
+	l.minimum("agent.shards", float64(c.Agent.Shards), 0)
+	l.maximum("agent.shards", float64(c.Agent.Shards), MaxShards)
+	if agents := c.Serve.NodeAgents; agents != nil {
+		l.duration("serve.nodeAgents.staleAfter", agents.StaleAfter)
+		if agents.Token == "" {
+			l.add("serve.nodeAgents.token", "node agents require a token")
+		}
+		if c.Serve.TLS == nil {
+			l.add("serve.nodeAgents", "node agents require the certificate of serve.tls")
+		}
+		l.minimum("serve.nodeAgents.network.retransmits", float64(agents.Network.Retransmits), 0)
+		l.minimum("serve.nodeAgents.network.resets", float64(agents.Network.Resets), 0)
+		l.minimum("serve.nodeAgents.network.dnsFailures", float64(agents.Network.DNSFailures), 0)
+	}
+	if c.NodeAgent.Network != nil {
+		l.duration("nodeAgent.network.window", c.NodeAgent.Network.Window)
+	}
+	l.duration("nodeAgent.interval", c.NodeAgent.Interval)
+	l.minimum("nodeAgent.maxZombies", float64(c.NodeAgent.MaxZombies), 0)
+	if c.Serve.NotifySelector != "" {
+		if _, err := labels.Parse(c.Serve.NotifySelector); err != nil {
+			l.add("serve.notifySelector", "invalid label selector: %v", err)
+		}
+	}
+	if auth := c.Serve.Auth; auth != nil {
+		for i, token := range auth.Tokens {
+			l.oneOf(fmt.Sprintf("serve.auth.tokens[%d].role", i), token.Role, l.refs.Roles)
+		}
+		if auth.OIDC != nil {
+			for subject, role := range auth.OIDC.Roles {
+				l.oneOf("serve.auth.oidc.roles."+subject, role, l.refs.Roles)
+			}
+		}
+	}
+
+	if c.Tickets.Severity != "" {
+		l.oneOf("tickets.severity", c.Tickets.Severity, l.refs.Severities)
+	}
+	l.template("tickets.summary", c.Tickets.Summary)
+	l.template("tickets.description", c.Tickets.Description)
+	l.duration("tickets.updateInterval", c.Tickets.UpdateInterval)
+	l.duration("drill.timeout", c.Drill.Timeout)
+
+	for i, entry := range c.KnowledgeBase.Entries {
+		l.check(fmt.Sprintf("knowledgeBase.entries[%d].check", i), entry.Check)
+	}
+	for i, runbook := range c.Runbooks {
+		at := fmt.Sprintf("runbooks[%d]", i)
+		l.check(at+".check", runbook.Check)
+		for j, step := range runbook.Steps {
+			if step.Command == "" && step.Exec == "" {
+				l.add(fmt.Sprintf("%s.steps[%d]", at, j), "step has neither command nor exec")
+			}
+		}
+	}
+	inventory := map[string]bool{}
+	for i, cluster := range c.Inventory {
+		if inventory[cluster.Name] {
+			l.add(fmt.Sprintf("inventory[%d]", i), "cluster %s is defined twice, the second one is ignored", cluster.Name)
+		}
+		inventory[cluster.Name] = true
+	}
+
+	for i, output := range c.Outputs {
+		at := fmt.Sprintf("outputs[%d]", i)
+		if output.Format != "" {
+			l.oneOf(at+".format", output.Format, l.refs.Formats)
+		}
+		l.template(at+".name", output.Name)
+		stores := 0
+		for _, configured := range []bool{output.S3 != nil, output.GCS != nil, output.Azure != nil, output.HTTP != nil} {
+			if configured {
+				stores++
+			}
+		}
+		if stores != 1 {
+			l.add(at, "an output needs exactly one of s3, gcs, azure or http")
+		}
+	}
+
+	for i, exporter := range c.Exporters {
+		at := fmt.Sprintf("exporters[%d]", i)
+		switch {
+		case (exporter.Influx == nil) == (exporter.RemoteWrite == nil):
+			l.add(at, "an exporter needs exactly one of influx or remoteWrite")
+		case exporter.Influx != nil && (exporter.Influx.Bucket == "") == (exporter.Influx.Database == ""):
+			l.add(at+".influx", "set either bucket for the v2 API or database for the v1 API")
+		}
+	}
+
+	if c.Events.Severity != "" {
+		l.oneOf("events.severity", c.Events.Severity, []string{"warning", "critical"})
+	}
+	l.duration("events.refresh", c.Events.Refresh)
+	if c.Events.ConfigMap != "" && strings.Count(c.Events.ConfigMap, "/") != 1 {
+		l.add("events.configMap", "use namespace/name")
+	}
+	if c.Grafana.Token != "" && c.Grafana.Username != "" {
+		l.add("grafana", "set either token or username and password")
+	}
+
+	l.minimum("hysteresis.openAfter", float64(c.Hysteresis.OpenAfter), 0)
+	l.minimum("hysteresis.closeAfter", float64(c.Hysteresis.CloseAfter), 0)
+	for check, runs := range c.Hysteresis.Checks {
+		l.check("hysteresis.checks."+check, check)
+		l.minimum("hysteresis.checks."+check+".openAfter", float64(runs.OpenAfter), 0)
+		l.minimum("hysteresis.checks."+check+".closeAfter", float64(runs.CloseAfter), 0)
+	}
+	if flapping := c.Hysteresis.Flapping; flapping != nil {
+		if flapping.Changes != 0 && flapping.Changes < 2 {
+			l.add("hysteresis.flapping.changes", "%d change is no flapping, use at least 2", flapping.Changes)
+		}
+		l.duration("hysteresis.flapping.window", flapping.Window)
+	}
+
+	l.duration("cache.ttl", c.Cache.TTL)
+	for check, ttl := range c.Cache.Checks {
+		l.check("cache.checks."+check, check)
+		if ttl != "0" {
+			// 0 never caches the check
+			l.duration("cache.checks."+check, ttl)
+		}
+	}
+
+	for i, window := range c.Maintenance {
+		at := fmt.Sprintf("maintenance[%d]", i)
+		if _, err := cron.Parse(window.Schedule); err != nil {
+			l.add(at+".schedule", "%v", err)
+		}
+		l.duration(at+".duration", window.Duration)
+		if window.Timezone != "" {
+			if _, err := time.LoadLocation(window.Timezone); err != nil {
+				l.add(at+".timezone", "unknown timezone %s", window.Timezone)
+			}
+		}
+		for j, cluster := range window.Clusters {
+			if len(c.Inventory) > 0 && !inventory[cluster] {
+				l.add(fmt.Sprintf("%s.clusters[%d]", at, j), "cluster %s is not in the inventory", cluster)
+			}
+		}
+	}
+
+	l.percent("cost.overprovisionedPercent", c.Cost.OverprovisionedPercent)
+	l.minimum("canary.maxIncreasePercent", c.Canary.MaxIncreasePercent, 0)
+	for i, query := range c.Canary.Queries {
+		l.minimum(fmt.Sprintf("canary.queries[%d].maxIncreasePercent", i), query.MaxIncreasePercent, 0)
+	}
+	l.checks(c.Checks)
+}
+
+func (l *linter) checks(c Checks) {
+	for i, group := range c.Kafka.ConsumerGroups {
+		l.minimum(fmt.Sprintf("checks.kafka.consumerGroups[%d].maxLag", i), float64(group.MaxLag), 0)
+	}
+	if c.Logging.Backend.Type != "" {
+		l.oneOf("checks.logging.backend.type", c.Logging.Backend.Type, []string{"loki", "elasticsearch"})
+	}
+	if c.Vulnerabilities.Scanner != "" {
+		l.oneOf("checks.vulnerabilities.scanner", c.Vulnerabilities.Scanner, []string{"trivy-operator", "harbor", "ecr"})
+	}
+	if c.Vulnerabilities.MaxCritical != nil {
+		l.minimum("checks.vulnerabilities.maxCritical", float64(*c.Vulnerabilities.MaxCritical), 0)
+	}
+	if c.Vulnerabilities.MaxHigh != nil {
+		l.minimum("checks.vulnerabilities.maxHigh", float64(*c.Vulnerabilities.MaxHigh), 0)
+	}
+	l.percent("checks.ephemeralStorage.nodeFsPercent", float64(c.EphemeralStorage.NodeFsPercent))
+	l.minimum("checks.leaderElection.maxTransitionsPerHour", c.LeaderElection.MaxTransitionsPerHour, 0)
+	if c.Priority.ScaleUpFactor != 0 && c.Priority.ScaleUpFactor <= 1 {
+		l.add("checks.priority.scaleUpFactor", "%v does not scale up the critical tier, nothing is ever preempted", c.Priority.ScaleUpFactor)
+	}
+	l.minimum("checks.gpu.expectedPerNode", float64(c.GPU.ExpectedPerNode), 0)
+	l.minimum("checks.network.probeNodes", float64(c.Network.ProbeNodes), 0)
+	l.minimum("checks.network.dnsQueries", float64(c.Network.DNSQueries), 0)
+	l.minimum("checks.network.routeSamples", float64(c.Network.RouteSamples), 0)
+	l.minimum("checks.admission.samples", float64(c.Admission.Samples), 0)
+	l.duration("checks.network.maxDNSLatency", c.Network.MaxDNSLatency)
+	l.duration("checks.admission.maxLatency", c.Admission.MaxLatency)
+	l.duration("checks.logging.maxLatency", c.Logging.MaxLatency)
+	l.duration("checks.metrics.maxAge", c.Metrics.MaxAge)
+	l.duration("checks.capacity.horizon", c.Capacity.Horizon)
+	l.duration("checks.evictions.window", c.Evictions.Window)
+	l.duration("checks.startup.window", c.Startup.Window)
+	if c.Startup.DegradedFactor != 0 && c.Startup.DegradedFactor <= 1 {
+		l.add("checks.startup.degradedFactor", "%v does not flag slower startups, use more than 1", c.Startup.DegradedFactor)
+	}
+	l.minimum("checks.startup.minPods", float64(c.Startup.MinPods), 0)
+	l.duration("checks.finalizers.stuckAfter", c.Finalizers.StuckAfter)
+	l.duration("checks.disasterRecovery.maxBackupAge", c.DisasterRecovery.MaxBackupAge)
+	for i, datastore := range c.DisasterRecovery.Datastores {
+		l.duration(fmt.Sprintf("checks.disasterRecovery.datastores[%d].rpo", i), datastore.RPO)
+	}
+	for i, pattern := range c.Priority.CriticalNamespaces {
+		l.glob(fmt.Sprintf("checks.priority.criticalNamespaces[%d]", i), pattern)
+	}
+	for i, pattern := range c.ImageProvenance.Namespaces {
+		l.glob(fmt.Sprintf("checks.imageProvenance.namespaces[%d]", i), pattern)
+	}
+	if cosign := c.ImageProvenance.Cosign; cosign.Key == "" && (cosign.Identity == "") != (cosign.Issuer == "") {
+		l.add("checks.imageProvenance.cosign", "keyless verification requires both identity and issuer")
+	}
+	for i, pattern := range c.Probes.Namespaces {
+		l.glob(fmt.Sprintf("checks.probes.namespaces[%d]", i), pattern)
+	}
+	for i, pattern := range c.Probes.SlowStarting {
+		l.glob(fmt.Sprintf("checks.probes.slowStarting[%d]", i), pattern)
+	}
+	l.percent("checks.profiles.memoryPercent", float64(c.Profiles.MemoryPercent))
+	l.duration("checks.profiles.cpuDuration", c.Profiles.CPUDuration)
+	l.duration("checks.profiles.cooldown", c.Profiles.Cooldown)
+	l.minimum("checks.profiles.keep", float64(c.Profiles.Keep), 0)
+	for i, profile := range c.Profiles.Profiles {
+		l.oneOf(fmt.Sprintf("checks.profiles.profiles[%d]", i), profile, k8s.PprofProfiles)
+	}
+	for i, target := range c.Profiles.Targets {
+		l.port(fmt.Sprintf("checks.profiles.targets[%d].port", i), target.Port)
+	}
+	for i, pattern := range c.Drift.Namespaces {
+		l.glob(fmt.Sprintf("checks.drift.namespaces[%d]", i), pattern)
+	}
+	if len(c.Drift.Namespaces) > 0 && c.Drift.Directory == "" && c.Drift.Repository == "" {
+		l.add("checks.drift", "namespaces are set without a directory or repository to read the manifests from")
+	}
+	if c.Drift.Directory != "" && c.Drift.Repository != "" {
+		l.add("checks.drift", "set either directory or repository")
+	}
+	for i, namespace := range c.Inventory {
+		for j, object := range namespace.Objects {
+			l.minimum(fmt.Sprintf("checks.inventory[%d].objects[%d].minReplicas", i, j), float64(object.MinReplicas), 0)
+		}
+	}
+}
+
+// unresolved reports whether a value is an env, file or secret reference, which is only known at runtime
+func unresolved(value string) bool {
+	return secretReference.MatchString(value)
+}
+
+func (l *linter) duration(at, value string) {
+	if value == "" || unresolved(value) {
+		return
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		l.add(at, "invalid duration %q, use e.g. 30s, 5m or 24h", value)
+	} else if d <= 0 {
+		l.add(at, "duration %s is not positive", value)
+	}
+}
+
+func (l *linter) suites(at string, names []string) {
+	for i, name := range names {
+		l.oneOf(fmt.Sprintf("%s[%d]", at, i), name, l.refs.Suites)
+	}
+}
+
+func (l *linter) oneOf(at, value string, allowed []string) {
+	if unresolved(value) {
+		return
+	}
+	for _, name := range allowed {
+		if value == name {
+			return
+		}
+	}
+	l.add(at, "unknown value %q, use one of %s", value, strings.Join(allowed, ", "))
+}
+
+// check reports check globs that match no check, so their hints, runbooks or suppressions never apply
+func (l *linter) check(at, pattern string) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		l.add(at, "invalid glob %q: %v", pattern, err)
+		return
+	}
+	for _, name := range l.refs.Checks {
+		if matched, _ := path.Match(pattern, name); matched {
+			return
+		}
+	}
+	l.add(at, "%q matches no check, checks are named suite/check like k8s/Pods", pattern)
+}
+
+func (l *linter) glob(at, pattern string) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		l.add(at, "invalid glob %q: %v", pattern, err)
+	}
+}
+
+func (l *linter) template(at, text string) {
+	if _, err := template.New(at).Parse(text); err != nil {
+		l.add(at, "invalid template: %v", err)
+	}
+}
+
+// percent reports percentages above 100, which are never reached
+func (l *linter) percent(at string, value float64) {
+	l.minimum(at, value, 0)
+	if value > 100 {
+		l.add(at, "%v%% is never reached", value)
+	}
+}
+
+// port reports ports outside 1-65535, 0 is the default
+func (l *linter) port(at string, value int) {
+	if value < 0 || value > 65535 {
+		l.add(at, "port %d is not between 1 and 65535", value)
+	}
+}
+
+func (l *linter) minimum(at string, value, minimum float64) {
+	if value < minimum {
+		l.add(at, "%v is below %v", value, minimum)
+	}
+}
+
+func (l *linter) maximum(at string, value, maximum float64) {
+	if value > maximum {
+		l.add(at, "%v is above %v", value, maximum)
+	}
+}
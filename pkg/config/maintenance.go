@@ -0,0 +1,18 @@
+package config
+
+// MaintenanceWindow is a recurring time, e.g. a patching night, during which findings are still recorded
+// but no one is notified about them
+type MaintenanceWindow struct {
+	Name string `json:"name"`
+	// Schedule is a cron expression with minute, hour, day of month, month and day of week, e.g. 0 22 * * 2
+	// for 22:00 every Tuesday. Every match starts a window.
+	Schedule string `json:"schedule"`
+	// Duration is how long a window lasts, e.g. 4h
+	Duration string `json:"duration"`
+	// Timezone of the schedule, e.g. Europe/Berlin, defaults to UTC
+	Timezone string `json:"timezone,omitempty"`
+	// Clusters limits the window to these clusters, defaults to all
+	Clusters []string `json:"clusters,omitempty"`
+	// Namespaces limits the window to the findings in these namespaces, defaults to the whole cluster
+	Namespaces []string `json:"namespaces,omitempty"`
+}
@@ -0,0 +1,58 @@
+package config
+
+// NodeAgent configures healthctl node-agent, which runs as a DaemonSet on every node and reports the
+// host-level checks the API can not do to healthctl serve over gRPC
+type NodeAgent struct {
+	// Server is the host:port of the node agent listener of healthctl serve
+	Server string `json:"server,omitempty"`
+	// Interval between runs, defaults to 5m
+	Interval string `json:"interval,omitempty"`
+	// Token authenticates the agent, it must match serve.nodeAgents.token
+	Token string `json:"token,omitempty"`
+	// TLS are the CA of the certificate of serve and a client certificate, without it the system CAs are used
+	TLS *AgentTLS `json:"tls,omitempty"`
+	// Units are the systemd units that must be active, defaults to kubelet and containerd
+	Units []string `json:"units,omitempty"`
+	// DNSNames are resolved through the DNS servers of the node, defaults to kubernetes.default.svc.cluster.local
+	DNSNames []string `json:"dnsNames,omitempty"`
+	// DNSServers are the servers asked, e.g. 169.254.20.10 of NodeLocal DNSCache, defaults to the
+	// nameservers of the agent pod, the cluster DNS with dnsPolicy ClusterFirstWithHostNet
+	DNSServers []string `json:"dnsServers,omitempty"`
+	// MaxZombies is the number of zombie processes tolerated on a node, defaults to 20
+	MaxZombies int `json:"maxZombies,omitempty"`
+	// HostRoot is where the root filesystem of the node is mounted, defaults to /host
+	HostRoot string `json:"hostRoot,omitempty"`
+	// Network samples TCP retransmits, connection resets and failed DNS answers with eBPF on every run, with
+	// the bpftrace of the node. Only enable it where loading eBPF programs on the nodes is permitted.
+	Network *NodeAgentNetwork `json:"network,omitempty"`
+}
+
+// NodeAgentNetwork configures the network sampling of the node agent
+type NodeAgentNetwork struct {
+	// Window is how long the network is sampled, defaults to 10s
+	Window string `json:"window,omitempty"`
+}
+
+// ServeNodeAgents accepts the reports of node agents, they are reported as the node suite of the cluster
+// serve runs in
+type ServeNodeAgents struct {
+	// Listen is the address of the gRPC listener, defaults to :9090. It uses the certificate of serve.tls, which
+	// is required.
+	Listen string `json:"listen,omitempty"`
+	// Token is the token node agents must send, it is required
+	Token string `json:"token,omitempty"`
+	// StaleAfter is how long a node agent may not report before its node is reported, defaults to 15m
+	StaleAfter string `json:"staleAfter,omitempty"`
+	// Network are the rates of the network sampling above which a workload is reported
+	Network NetworkLimits `json:"network,omitempty"`
+}
+
+// NetworkLimits are rates per minute of a workload over all nodes
+type NetworkLimits struct {
+	// Retransmits defaults to 60
+	Retransmits int `json:"retransmits,omitempty"`
+	// Resets are the connection resets sent and received, defaults to 30
+	Resets int `json:"resets,omitempty"`
+	// DNSFailures are the SERVFAIL and REFUSED answers, defaults to 10
+	DNSFailures int `json:"dnsFailures,omitempty"`
+}
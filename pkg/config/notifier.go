@@ -0,0 +1,30 @@
+package config
+
+// Notifier configures where check results are sent
+type Notifier struct {
+	Slack *SlackConfig `json:"slack,omitempty"`
+}
+
+// SlackChannel is an incoming webhook and an optional channel override
+type SlackChannel struct {
+	WebhookURL string `json:"webhookURL,omitempty"`
+	Channel    string `json:"channel,omitempty"`
+}
+
+// SlackConfig is the default slack channel and the channel of every team
+type SlackConfig struct {
+	SlackChannel
+	Teams map[string]SlackChannel `json:"teams,omitempty"`
+}
+
+// ChannelFor returns the slack channel of a team, falling back to the default webhook and channel
+func (s *SlackConfig) ChannelFor(team string) SlackChannel {
+	channel, found := s.Teams[team]
+	if !found {
+		return s.SlackChannel
+	}
+	if channel.WebhookURL == "" {
+		channel.WebhookURL = s.WebhookURL
+	}
+	return channel
+}
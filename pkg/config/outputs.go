@@ -0,0 +1,52 @@
+package config
+
+// Output uploads the report of every run to object storage or an HTTP endpoint for long-term archival
+type Output struct {
+	// Format is a report output format, defaults to json
+	Format string `json:"format,omitempty"`
+	// Name is a template of the object name over {{.Cluster}}, {{.Labels}}, {{.Generated}}, the time of the
+	// run, {{.Timestamp}}, its UTC form like 20240131T120000Z, and {{.Extension}} of the format.
+	// Defaults to {{.Cluster}}/{{.Timestamp}}.{{.Extension}}
+	Name  string       `json:"name,omitempty"`
+	S3    *S3Output    `json:"s3,omitempty"`
+	GCS   *GCSOutput   `json:"gcs,omitempty"`
+	Azure *AzureOutput `json:"azure,omitempty"`
+	HTTP  *HTTPOutput  `json:"http,omitempty"`
+}
+
+// S3Output is an S3 bucket or an S3 compatible store like MinIO. Without keys the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables are used.
+type S3Output struct {
+	Bucket string `json:"bucket"`
+	// Region defaults to AWS_REGION, AWS_DEFAULT_REGION and us-east-1
+	Region string `json:"region"`
+	// Endpoint replaces the AWS endpoint, e.g. https://minio.example.com, the bucket is part of the path
+	Endpoint        string `json:"endpoint,omitempty"`
+	Prefix          string `json:"prefix,omitempty"`
+	AccessKeyID     string `json:"accessKeyID,omitempty"`
+	SecretAccessKey string `json:"secretAccessKey,omitempty"`
+	SessionToken    string `json:"sessionToken,omitempty"`
+}
+
+// GCSOutput is a Google Cloud Storage bucket. Without a token the access token of the service account
+// is read from the metadata server, as with workload identity on GKE.
+type GCSOutput struct {
+	Bucket string `json:"bucket"`
+	Prefix string `json:"prefix,omitempty"`
+	Token  string `json:"token,omitempty"`
+}
+
+// AzureOutput is an Azure Blob Storage container written with a SAS token that allows creating blobs
+type AzureOutput struct {
+	Account   string `json:"account"`
+	Container string `json:"container"`
+	Prefix    string `json:"prefix,omitempty"`
+	SASToken  string `json:"sasToken"`
+}
+
+// HTTPOutput PUTs the report to an HTTP endpoint
+type HTTPOutput struct {
+	// URL is the base URL, the object name is appended to it
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
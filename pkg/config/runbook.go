@@ -0,0 +1,22 @@
+package config
+
+// Runbook is a sequence of remediation steps for the findings of a check, optionally only for one reason
+type Runbook struct {
+	Name   string        `json:"name"`
+	Check  string        `json:"check"`
+	Reason string        `json:"reason,omitempty"`
+	Steps  []RunbookStep `json:"steps"`
+}
+
+// RunbookStep is a local shell command or a command executed in a pod.
+// Commands and the pod fields are templates over the finding, e.g. {{.Resource.Namespace}}.
+type RunbookStep struct {
+	Description string `json:"description"`
+	// Command runs in a local shell
+	Command string `json:"command,omitempty"`
+	// Exec runs in a pod, by default the pod of the finding
+	Exec      string `json:"exec,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Pod       string `json:"pod,omitempty"`
+	Container string `json:"container,omitempty"`
+}
@@ -0,0 +1,15 @@
+package config
+
+import (
+	"reflect"
+
+	"healthctl/pkg/schema"
+)
+
+// Schema returns the JSON Schema of the configuration file, e.g. for the yaml language server of editors
+func Schema() map[string]interface{} {
+	s := schema.For(reflect.TypeOf(Config{}))
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["title"] = "healthctl configuration"
+	return s
+}
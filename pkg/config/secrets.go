@@ -0,0 +1,152 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// secretReference matches ${env:NAME}, ${file:/path} and ${secret:namespace/name/key} in config values
+var secretReference = regexp.MustCompile(`\$\{(env|file|secret):([^}]+)\}`)
+
+// SecretGetter returns the value of a key of a kubernetes Secret
+type SecretGetter func(namespace, name, key string) (string, error)
+
+// sopsEncrypted reports whether the file was encrypted with SOPS, which adds its metadata as top level sops key
+func sopsEncrypted(data []byte) bool {
+	return regexp.MustCompile(`(?m)^sops:\s*$`).Match(data) || bytes.Contains(data, []byte(`"sops":`))
+}
+
+// sopsDecrypt decrypts a SOPS encrypted config file with the sops CLI, which finds the keys through its
+// usual configuration like SOPS_AGE_KEY_FILE or the cloud KMS credentials
+func sopsDecrypt(file string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("sops", "--decrypt", file)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("decrypting %s with sops: %v: %s", file, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// resolveLocal replaces the env and file references of every string in the config. Secret references are
+// left for ResolveSecrets, which needs a connection to the cluster.
+func (c *Config) resolveLocal() error {
+	return walkStrings(reflect.ValueOf(c).Elem(), func(value string) (string, error) {
+		return replaceReferences(value, func(kind, ref string) (string, bool, error) {
+			switch kind {
+			case "env":
+				resolved, found := os.LookupEnv(ref)
+				if !found {
+					return "", false, fmt.Errorf("config references unset environment variable %s", ref)
+				}
+				return resolved, true, nil
+			case "file":
+				data, err := os.ReadFile(ref)
+				if err != nil {
+					return "", false, fmt.Errorf("config references unreadable file: %v", err)
+				}
+				return strings.TrimRight(string(data), "\n"), true, nil
+			}
+			return "", false, nil
+		})
+	})
+}
+
+// ResolveSecrets replaces the ${secret:namespace/name/key} references of every string in the config with
+// the value of the key of the kubernetes Secret
+func (c *Config) ResolveSecrets(get SecretGetter) error {
+	return walkStrings(reflect.ValueOf(c).Elem(), func(value string) (string, error) {
+		return replaceReferences(value, func(kind, ref string) (string, bool, error) {
+			if kind != "secret" {
+				return "", false, nil
+			}
+			parts := strings.Split(ref, "/")
+			if len(parts) != 3 {
+				return "", false, fmt.Errorf("invalid secret reference %q, use namespace/name/key", ref)
+			}
+			resolved, err := get(parts[0], parts[1], parts[2])
+			if err != nil {
+				return "", false, fmt.Errorf("reading secret %s: %v", ref, err)
+			}
+			return resolved, true, nil
+		})
+	})
+}
+
+// HasSecretReferences reports whether a config value references a kubernetes Secret
+func (c *Config) HasSecretReferences() bool {
+	found := false
+	walkStrings(reflect.ValueOf(c).Elem(), func(value string) (string, error) {
+		for _, match := range secretReference.FindAllStringSubmatch(value, -1) {
+			found = found || match[1] == "secret"
+		}
+		return value, nil
+	})
+	return found
+}
+
+// replaceReferences replaces the references in a value the resolver handles
+func replaceReferences(value string, resolve func(kind, ref string) (string, bool, error)) (string, error) {
+	var err error
+	replaced := secretReference.ReplaceAllStringFunc(value, func(reference string) string {
+		match := secretReference.FindStringSubmatch(reference)
+		resolved, ok, resolveErr := resolve(match[1], strings.TrimSpace(match[2]))
+		if resolveErr != nil && err == nil {
+			err = resolveErr
+		}
+		if !ok {
+			return reference
+		}
+		return resolved
+	})
+	return replaced, err
+}
+
+// walkStrings replaces every string reachable from the value, in struct fields, pointers, slices and maps
+func walkStrings(v reflect.Value, replace func(string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.String:
+		replaced, err := replace(v.String())
+		if err != nil {
+			return err
+		}
+		if v.CanSet() {
+			v.SetString(replaced)
+		}
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			return walkStrings(v.Elem(), replace)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				if err := walkStrings(v.Field(i), replace); err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := walkStrings(v.Index(i), replace); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			// map values are not addressable, they are copied, walked and stored again
+			value := reflect.New(iter.Value().Type()).Elem()
+			value.Set(iter.Value())
+			if err := walkStrings(value, replace); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), value)
+		}
+	}
+	return nil
+}
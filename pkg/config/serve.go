@@ -0,0 +1,113 @@
+package config
+
+// Serve configures the HTTP server of healthctl serve
+type Serve struct {
+	// Listen is the address the server listens on, defaults to :8080
+	Listen string `json:"listen,omitempty"`
+	// Clusters are the kubeconfig clusters or contexts checked for the dashboard, defaults to the current cluster
+	Clusters []string `json:"clusters,omitempty"`
+	// Suites are the suites run for the dashboard, defaults to all
+	Suites []string `json:"suites,omitempty"`
+	// Interval between dashboard runs, defaults to 5m
+	Interval string `json:"interval,omitempty"`
+	// Shards splits the namespaces of large clusters into shards that are checked in parallel
+	Shards int `json:"shards,omitempty"`
+	// AlertSuites are the suites run for the namespace of an Alertmanager notification, defaults to k8s
+	AlertSuites []string `json:"alertSuites,omitempty"`
+	// Slack enables the /healthctl slash command
+	Slack *ChatOps `json:"slack,omitempty"`
+	// Auth requires a token on the API, without it everyone has the admin role
+	Auth *ServeAuth `json:"auth,omitempty"`
+	// TLS serves https and authenticates fleet agents by their client certificates
+	TLS *ServeTLS `json:"tls,omitempty"`
+	// DisableChecks turns off the dashboard runs, the server only aggregates the reports of agents
+	DisableChecks bool `json:"disableChecks,omitempty"`
+	// AgentTimeout is how long an agent may not report before its cluster is stale, defaults to 15m
+	AgentTimeout string `json:"agentTimeout,omitempty"`
+	// Notify sends every team the changes of its failing findings through the notifier after each run and
+	// agent report: new findings, changed severities and resolved findings
+	Notify bool `json:"notify,omitempty"`
+	// NotifyReminder is how often findings that stay open are notified again, defaults to 24h, 0 turns
+	// reminders off
+	NotifyReminder string `json:"notifyReminder,omitempty"`
+	// NotifySelector is a label selector over the inventory labels of the clusters that are notified about,
+	// e.g. environment=prod, defaults to all clusters
+	NotifySelector string `json:"notifySelector,omitempty"`
+	// Operator runs the checks defined by the HealthCheck resources of the cluster and writes their results
+	// into the status of the resources
+	Operator *ServeOperator `json:"operator,omitempty"`
+	// NodeAgents accepts the reports of the node agent DaemonSet over gRPC
+	NodeAgents *ServeNodeAgents `json:"nodeAgents,omitempty"`
+}
+
+// ServeOperator reconciles the HealthCheck resources of the cluster serve runs in
+type ServeOperator struct {
+	// Namespaces are the namespaces whose HealthChecks are reconciled, defaults to all
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Interval between the runs of HealthChecks without an interval, defaults to 15m
+	Interval string `json:"interval,omitempty"`
+	// MinInterval is the shortest interval of a HealthCheck, a changed spec does not run earlier either,
+	// defaults to 5m
+	MinInterval string `json:"minInterval,omitempty"`
+	// Suites are the suites HealthChecks may run, defaults to k8s
+	Suites []string `json:"suites,omitempty"`
+}
+
+// ServeTLS is the server certificate and the CA of the agent client certificates. The common name of an
+// agent certificate is the name of its cluster.
+type ServeTLS struct {
+	Cert     string `json:"cert"`
+	Key      string `json:"key"`
+	ClientCA string `json:"clientCA,omitempty"`
+}
+
+// ChatOps configures the slack slash command of healthctl serve
+type ChatOps struct {
+	// SigningSecret of the slack app, requests with a different signature are rejected, serve does not start
+	// without it
+	SigningSecret string `json:"signingSecret"`
+	// Users maps slack user IDs like U024BE7LH to the commands they may run, the user * applies to everyone.
+	// Names are not matched, users can change them.
+	Users map[string][]string `json:"users,omitempty"`
+}
+
+// Allowed returns true when the slack user may run the command. help is allowed for everyone.
+func (c *ChatOps) Allowed(userID, command string) bool {
+	if command == "help" {
+		return true
+	}
+	for _, user := range []string{userID, "*"} {
+		for _, allowed := range c.Users[user] {
+			if allowed == command || allowed == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ServeAuth authenticates API requests by static tokens or OIDC ID tokens, sent as bearer tokens. The roles
+// are viewer, who reads reports, operator, who also runs checks and acknowledges findings, and admin, who
+// also suppresses findings. Without it the API is read-only.
+type ServeAuth struct {
+	Tokens []StaticToken `json:"tokens,omitempty"`
+	OIDC   *OIDCAuth     `json:"oidc,omitempty"`
+}
+
+// StaticToken is a fixed bearer token with a role
+type StaticToken struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Role  string `json:"role"`
+}
+
+// OIDCAuth verifies ID tokens of an OpenID Connect issuer
+type OIDCAuth struct {
+	Issuer   string `json:"issuer"`
+	ClientID string `json:"clientID"`
+	// UsernameClaim defaults to email, GroupsClaim to groups
+	UsernameClaim string `json:"usernameClaim,omitempty"`
+	GroupsClaim   string `json:"groupsClaim,omitempty"`
+	// Roles maps user names and groups to roles, the highest role of a user applies
+	Roles map[string]string `json:"roles"`
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"path"
+)
+
+// Unassigned is the team of findings whose namespace is not owned by any team
+const Unassigned = "unassigned"
+
+// Team owns a set of namespaces, selected by name globs or by namespace labels
+type Team struct {
+	Name            string            `json:"name"`
+	Namespaces      []string          `json:"namespaces,omitempty"`
+	NamespaceLabels map[string]string `json:"namespaceLabels,omitempty"`
+}
+
+// owns returns true when the namespace matches one of the team globs or all of the team labels
+func (t Team) owns(namespace string, labels map[string]string) bool {
+	for _, pattern := range t.Namespaces {
+		if matched, err := path.Match(pattern, namespace); err == nil && matched {
+			return true
+		}
+	}
+	if len(t.NamespaceLabels) == 0 {
+		return false
+	}
+	for key, value := range t.NamespaceLabels {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// TeamFor returns the first team owning the namespace, or Unassigned
+func (c *Config) TeamFor(namespace string, labels map[string]string) string {
+	if namespace == "" {
+		return Unassigned
+	}
+	for _, team := range c.Teams {
+		if team.owns(namespace, labels) {
+			return team.Name
+		}
+	}
+	return Unassigned
+}
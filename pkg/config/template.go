@@ -0,0 +1,83 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	sprig "github.com/go-task/slim-sprig/v3"
+	"sigs.k8s.io/yaml"
+)
+
+// TemplateVars are the values a config file can use in ${{ }} templates, so one file serves many clusters
+type TemplateVars struct {
+	Cluster     string
+	Context     string
+	Environment string
+	// Labels are the inventory labels of the cluster, filled from the inventory section of the file
+	Labels map[string]string
+	// Namespaces lists the namespaces of the cluster, it is only called by templates using namespaces
+	Namespaces func() ([]string, error)
+}
+
+// templateDelims keep config templates apart from the {{ }} templates of values like ticket summaries
+var templateDelims = [2]string{"${{", "}}"}
+
+// hasTemplates reports whether the file uses ${{ }} templates
+func hasTemplates(data []byte) bool {
+	return bytes.Contains(data, []byte(templateDelims[0]))
+}
+
+// expandTemplates renders the ${{ }} templates of a config file. The inventory labels of the cluster are
+// only known once the file is parsed, so the file is rendered again when they add to the variables.
+func expandTemplates(file string, data []byte, vars TemplateVars) ([]byte, error) {
+	if list := vars.Namespaces; list != nil {
+		var namespaces []string
+		vars.Namespaces = func() ([]string, error) {
+			if namespaces != nil {
+				return namespaces, nil
+			}
+			var err error
+			namespaces, err = list()
+			return namespaces, err
+		}
+	}
+	rendered, err := renderTemplate(file, data, vars)
+	if err != nil {
+		return nil, err
+	}
+	inventory := struct {
+		Inventory []ClusterInventory `json:"inventory,omitempty"`
+	}{}
+	if err := yaml.Unmarshal(rendered, &inventory); err != nil {
+		return rendered, nil
+	}
+	labels := (&Config{Inventory: inventory.Inventory}).InventoryLabels(vars.Cluster)
+	if len(labels) == 0 {
+		return rendered, nil
+	}
+	vars.Labels = labels
+	if vars.Environment == "" {
+		vars.Environment = labels["environment"]
+	}
+	return renderTemplate(file, data, vars)
+}
+
+func renderTemplate(file string, data []byte, vars TemplateVars) ([]byte, error) {
+	funcs := sprig.TxtFuncMap()
+	funcs["namespaces"] = func() ([]string, error) {
+		if vars.Namespaces == nil {
+			return nil, fmt.Errorf("namespaces are not available")
+		}
+		return vars.Namespaces()
+	}
+	tmpl, err := template.New(file).Delims(templateDelims[0], templateDelims[1]).Funcs(funcs).Option("missingkey=zero").Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parsing templates of config file %s: %v", file, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars); err != nil {
+		return nil, fmt.Errorf("rendering templates of config file %s: %v", file, err)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,37 @@
+package config
+
+// Tickets configures the tickets healthctl serve opens for new findings, in Jira or ServiceNow
+type Tickets struct {
+	Jira       *JiraTracker       `json:"jira,omitempty"`
+	ServiceNow *ServiceNowTracker `json:"serviceNow,omitempty"`
+	// Severity is the lowest severity a ticket is opened for, defaults to critical
+	Severity string `json:"severity,omitempty"`
+	// Summary and Description are templates over {{.Cluster}} and {{.Finding}}
+	Summary     string `json:"summary,omitempty"`
+	Description string `json:"description,omitempty"`
+	// UpdateInterval is how often a ticket is commented while its finding persists, defaults to 24h
+	UpdateInterval string `json:"updateInterval,omitempty"`
+}
+
+// JiraTracker opens Jira issues through the REST API. Without a username the token is sent as bearer
+// token, as used by personal access tokens of Jira data center.
+type JiraTracker struct {
+	URL      string `json:"url"`
+	Project  string `json:"project"`
+	Username string `json:"username,omitempty"`
+	Token    string `json:"token"`
+	// IssueType defaults to Task
+	IssueType string `json:"issueType,omitempty"`
+	// CloseTransition is the workflow transition that closes an issue, defaults to Done
+	CloseTransition string `json:"closeTransition,omitempty"`
+}
+
+// ServiceNowTracker opens records in a ServiceNow table through the table API
+type ServiceNowTracker struct {
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// Table defaults to incident
+	Table           string `json:"table,omitempty"`
+	AssignmentGroup string `json:"assignmentGroup,omitempty"`
+}
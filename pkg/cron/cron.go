@@ -0,0 +1,108 @@
+// Package cron parses the five field cron expressions of schedules in the config
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// anyDay is set when day of month or day of week is *, then both have to match, otherwise either
+	anyDay bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Parse parses a cron expression with the five fields minute, hour, day of month, month and day
+// of week. Fields are *, numbers, ranges like 1-5 and lists of them, with an optional step like */15.
+// Sunday is 0 or 7.
+func Parse(expression string) (Schedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFields) {
+		return Schedule{}, fmt.Errorf("schedule %q needs 5 fields: minute hour day-of-month month day-of-week", expression)
+	}
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		var err error
+		if bits[i], err = parseField(field, cronFields[i]); err != nil {
+			return Schedule{}, fmt.Errorf("schedule %q: %v", expression, err)
+		}
+	}
+	dow := bits[4]
+	if dow&(1<<7) != 0 {
+		dow |= 1
+	}
+	return Schedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    dow,
+		anyDay: fields[2] == "*" || fields[4] == "*",
+	}, nil
+}
+
+func parseField(field string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		values, step, hasStep := strings.Cut(part, "/")
+		increment := 1
+		if hasStep {
+			var err error
+			if increment, err = strconv.Atoi(step); err != nil || increment <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s", step, f.name)
+			}
+		}
+		low, high := f.min, f.max
+		if values != "*" {
+			from, to, isRange := strings.Cut(values, "-")
+			var err error
+			if low, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid %s %q", f.name, part)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid %s %q", f.name, part)
+				}
+			} else if hasStep {
+				high = f.max
+			}
+			if low < f.min || high > f.max || low > high {
+				return 0, fmt.Errorf("%s %q is out of range %d-%d", f.name, part, f.min, f.max)
+			}
+		}
+		for value := low; value <= high; value += increment {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+// Matches returns true when the schedule fires in the minute of t
+func (s Schedule) Matches(t time.Time) bool {
+	if s.minute&(1<<t.Minute()) == 0 || s.hour&(1<<t.Hour()) == 0 || s.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	if s.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
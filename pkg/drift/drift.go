@@ -0,0 +1,336 @@
+// Package drift finds live objects that drifted from the manifests they are deployed from, e.g. after a manual
+// kubectl edit. The manifests are read from a local directory or a Git repository, the changes they would
+// make are computed by the API server with a server side dry-run apply, like kubectl diff does.
+package drift
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"healthctl/pkg/config"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// FieldManager is the field manager of the dry-run applies
+const FieldManager = "healthctl-drift"
+
+// checkoutTimeout bounds the clone or fetch of the manifests, a hanging Git server must not block the check
+const checkoutTimeout = 2 * time.Minute
+
+// manualManagers are the field managers of kubectl commands that change objects by hand
+var manualManagers = map[string]bool{
+	"kubectl-edit":     true,
+	"kubectl-patch":    true,
+	"kubectl-scale":    true,
+	"kubectl-set":      true,
+	"kubectl-label":    true,
+	"kubectl-annotate": true,
+	"kubectl-replace":  true,
+	"kubectl-rollout":  true,
+}
+
+// Manifest is an object declared in a manifest file, File is relative to the manifest directory
+type Manifest struct {
+	Object unstructured.Unstructured
+	File   string
+}
+
+// Checkout clones the branch or tag of a Git repository into ~/.healthctl/drift, or fetches it when it was
+// cloned before, and returns the directory and the short commit. The default branch is used without ref.
+// The clone is locked while it is updated, concurrent runs checking the same repository wait for each other.
+func Checkout(repository, ref string) (string, string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", "", fmt.Errorf("the git CLI is required to read manifests from %s", repository)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), checkoutTimeout)
+	defer cancel()
+	dir := config.StatePath(filepath.Join("drift", fmt.Sprintf("%x", sha256.Sum256([]byte(repository)))[:12]))
+	if err := os.MkdirAll(filepath.Dir(dir), 0700); err != nil {
+		return "", "", err
+	}
+	unlock, err := lock(ctx, dir+".lock")
+	if err != nil {
+		return "", "", fmt.Errorf("locking the clone of %s: %v", repository, err)
+	}
+	defer unlock()
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		fetch := []string{"-C", dir, "fetch", "--depth", "1", "origin"}
+		if ref != "" {
+			fetch = append(fetch, ref)
+		}
+		if _, err := git(ctx, fetch...); err != nil {
+			return "", "", err
+		}
+		if _, err := git(ctx, "-C", dir, "checkout", "--force", "--detach", "FETCH_HEAD"); err != nil {
+			return "", "", err
+		}
+	} else {
+		clone := []string{"clone", "--depth", "1"}
+		if ref != "" {
+			clone = append(clone, "--branch", ref)
+		}
+		if _, err := git(ctx, append(clone, repository, dir)...); err != nil {
+			os.RemoveAll(dir)
+			return "", "", err
+		}
+	}
+	commit, err := git(ctx, "-C", dir, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return "", "", fmt.Errorf("reading the commit of %s: %v", repository, err)
+	}
+	return dir, commit, nil
+}
+
+// lock takes an exclusive lock of the file, it waits for other processes holding it until ctx is done
+func lock(ctx context.Context, path string) (func(), error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return func() {
+				syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+				file.Close()
+			}, nil
+		}
+		if err != syscall.EWOULDBLOCK {
+			file.Close()
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			file.Close()
+			return nil, fmt.Errorf("still locked by another run")
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// git runs a git command and returns its trimmed output, or the last line of its output as error when it fails
+func git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("git: timed out after %s", checkoutTimeout)
+	}
+	if err != nil {
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		return "", fmt.Errorf("git: %s", lines[len(lines)-1])
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Load reads the objects of the .yaml, .yml and .json files below dir, files may hold several documents
+// and lists. Documents without apiVersion and kind, like kustomization.yaml or values files, are skipped.
+func Load(dir string) ([]Manifest, error) {
+	manifests := []Manifest{}
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		file, _ := filepath.Rel(dir, path)
+		decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+		for {
+			document := map[string]interface{}{}
+			if err := decoder.Decode(&document); err == io.EOF {
+				break
+			} else if err != nil {
+				return fmt.Errorf("%s: %v", file, err)
+			}
+			object := unstructured.Unstructured{Object: document}
+			if object.GetAPIVersion() == "" || object.GetKind() == "" {
+				continue
+			}
+			if object.IsList() {
+				list, err := object.ToList()
+				if err != nil {
+					return fmt.Errorf("%s: %v", file, err)
+				}
+				for _, item := range list.Items {
+					manifests = append(manifests, Manifest{Object: item, File: file})
+				}
+				continue
+			}
+			manifests = append(manifests, Manifest{Object: object, File: file})
+		}
+		return nil
+	})
+	return manifests, err
+}
+
+// Diff returns the field paths whose values differ between the live object and the object the dry-run
+// apply returned, like spec.template.spec.containers[0].image. The status and the metadata the API server
+// maintains are not compared, neither are the paths below one of the ignored paths.
+func Diff(live, applied unstructured.Unstructured, ignore []string) []string {
+	paths := []string{}
+	diff("", comparable(live.Object), comparable(applied.Object), &paths)
+	kept := []string{}
+	for _, path := range paths {
+		if !ignored(path, ignore) {
+			kept = append(kept, path)
+		}
+	}
+	sort.Strings(kept)
+	return kept
+}
+
+// comparable drops the status and all metadata but the labels and annotations
+func comparable(object map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(object))
+	for key, value := range object {
+		switch key {
+		case "status":
+		case "metadata":
+			metadata, _ := value.(map[string]interface{})
+			kept := map[string]interface{}{}
+			for _, field := range []string{"labels", "annotations"} {
+				if value, found := metadata[field]; found {
+					kept[field] = value
+				}
+			}
+			if annotations, ok := kept["annotations"].(map[string]interface{}); ok {
+				without := make(map[string]interface{}, len(annotations))
+				for name, value := range annotations {
+					if name != "kubectl.kubernetes.io/last-applied-configuration" {
+						without[name] = value
+					}
+				}
+				kept["annotations"] = without
+			}
+			copied[key] = kept
+		default:
+			copied[key] = value
+		}
+	}
+	return copied
+}
+
+func diff(path string, live, applied interface{}, paths *[]string) {
+	if empty(live) && empty(applied) {
+		return
+	}
+	liveMap, liveIsMap := live.(map[string]interface{})
+	appliedMap, appliedIsMap := applied.(map[string]interface{})
+	if liveIsMap && appliedIsMap {
+		keys := map[string]bool{}
+		for key := range liveMap {
+			keys[key] = true
+		}
+		for key := range appliedMap {
+			keys[key] = true
+		}
+		for key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			diff(child, liveMap[key], appliedMap[key], paths)
+		}
+		return
+	}
+	liveList, liveIsList := live.([]interface{})
+	appliedList, appliedIsList := applied.([]interface{})
+	if liveIsList && appliedIsList && len(liveList) == len(appliedList) {
+		for i := range liveList {
+			diff(fmt.Sprintf("%s[%d]", path, i), liveList[i], appliedList[i], paths)
+		}
+		return
+	}
+	if !reflect.DeepEqual(live, applied) {
+		*paths = append(*paths, path)
+	}
+}
+
+// empty returns true for missing values and empty maps and lists, they are equal
+func empty(value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(value) == 0
+	case []interface{}:
+		return len(value) == 0
+	}
+	return false
+}
+
+// ignored returns true when the path is one of the ignored paths or below one, list indexes are ignored,
+// spec.template.spec.containers.image ignores the image of every container
+func ignored(path string, ignore []string) bool {
+	var withoutIndexes strings.Builder
+	skipping := false
+	for _, c := range path {
+		switch {
+		case c == '[':
+			skipping = true
+		case c == ']':
+			skipping = false
+		case !skipping:
+			withoutIndexes.WriteRune(c)
+		}
+	}
+	for _, prefix := range ignore {
+		for _, candidate := range []string{path, withoutIndexes.String()} {
+			if candidate == prefix || strings.HasPrefix(candidate, prefix+".") || strings.HasPrefix(candidate, prefix+"[") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Edit is a change of an object by a kubectl command
+type Edit struct {
+	Manager string
+	Time    time.Time
+}
+
+// ManualEdits returns the kubectl commands that changed the object by hand, newest first
+func ManualEdits(object unstructured.Unstructured) []Edit {
+	edits := []Edit{}
+	for _, entry := range object.GetManagedFields() {
+		if entry.Operation != metav1.ManagedFieldsOperationUpdate || !manualManagers[entry.Manager] {
+			continue
+		}
+		edit := Edit{Manager: entry.Manager}
+		if entry.Time != nil {
+			edit.Time = entry.Time.Time
+		}
+		edits = append(edits, edit)
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Time.After(edits[j].Time) })
+	return edits
+}
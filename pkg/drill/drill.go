@@ -0,0 +1,212 @@
+// Package drill runs backup and restore drills: a canary namespace is backed up, restored into a scratch
+// namespace, the restored workloads must become ready and everything is torn down again.
+package drill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/k8s"
+)
+
+const (
+	defaultVeleroNamespace = "velero"
+	defaultTimeout         = 15 * time.Minute
+	// keepResults is how many drills are kept in the history
+	keepResults = 50
+)
+
+// HistoryFile keeps the results of the previous drills
+var HistoryFile = config.StatePath("drills.json")
+
+// Step is one step of a drill
+type Step struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	Detail   string        `json:"detail,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Result is the outcome of a drill, it succeeded when no step failed
+type Result struct {
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	Target    string    `json:"target"`
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+	Steps     []Step    `json:"steps"`
+	Success   bool      `json:"success"`
+}
+
+// Options change how a drill runs
+type Options struct {
+	// Keep skips the teardown so the restored namespace can be inspected
+	Keep bool
+	// Progress is called when a step starts
+	Progress func(step string)
+}
+
+// Run runs a drill of the configured canary namespace. The teardown runs even when an earlier step failed.
+func Run(kc *k8s.K8sClient, cfg config.Drill, opts Options) (Result, error) {
+	if cfg.Namespace == "" {
+		return Result{}, fmt.Errorf("no drill namespace configured")
+	}
+	if (cfg.BackupCommand == "") != (cfg.RestoreCommand == "") {
+		return Result{}, fmt.Errorf("backupCommand and restoreCommand must be configured together")
+	}
+	timeout := defaultTimeout
+	if cfg.Timeout != "" {
+		parsed, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return Result{}, fmt.Errorf("invalid drill timeout %q: %v", cfg.Timeout, err)
+		}
+		timeout = parsed
+	}
+	veleroNamespace := cfg.VeleroNamespace
+	if veleroNamespace == "" {
+		veleroNamespace = defaultVeleroNamespace
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("healthctl-drill-%d", now.Unix())
+	target := cfg.Namespace
+	if len(target) > 40 {
+		target = target[:40]
+	}
+	target = fmt.Sprintf("%s-drill-%d", strings.TrimSuffix(target, "-"), now.Unix())
+	result := Result{Name: name, Namespace: cfg.Namespace, Target: target, Started: now}
+
+	step := func(stepName string, run func() (string, error)) bool {
+		if opts.Progress != nil {
+			opts.Progress(stepName)
+		}
+		started := time.Now()
+		detail, err := run()
+		s := Step{Name: stepName, Duration: time.Since(started).Round(time.Second), Detail: detail}
+		if err != nil {
+			s.Error = err.Error()
+		}
+		result.Steps = append(result.Steps, s)
+		return err == nil
+	}
+
+	velero := cfg.BackupCommand == ""
+	env := []string{"DRILL_NAME=" + name, "DRILL_NAMESPACE=" + cfg.Namespace, "DRILL_TARGET=" + target}
+	restored := step("backup", func() (string, error) {
+		if !velero {
+			return runCommand(cfg.BackupCommand, env, timeout)
+		}
+		if err := kc.CreateVeleroBackup(veleroNamespace, name, cfg.Namespace); err != nil {
+			return "", err
+		}
+		return "velero backup " + name, kc.WaitVeleroBackup(veleroNamespace, name, timeout)
+	}) && step("restore", func() (string, error) {
+		if !velero {
+			return runCommand(cfg.RestoreCommand, env, timeout)
+		}
+		if err := kc.CreateVeleroRestore(veleroNamespace, name, name, cfg.Namespace, target); err != nil {
+			return "", err
+		}
+		return "velero restore " + name + " into " + target, kc.WaitVeleroRestore(veleroNamespace, name, timeout)
+	})
+	if restored {
+		step("validate", func() (string, error) {
+			expected, _, err := kc.GetWorkloadReadiness(cfg.Namespace)
+			if err != nil {
+				return "", err
+			}
+			workloads, err := kc.WaitWorkloadsReady(target, timeout)
+			if err != nil {
+				return "", err
+			}
+			if workloads < expected {
+				return "", fmt.Errorf("only %d of the %d workloads of %s were restored", workloads, expected, cfg.Namespace)
+			}
+			return fmt.Sprintf("%d workloads ready", workloads), nil
+		})
+	}
+	result.Success = true
+	for _, s := range result.Steps {
+		if s.Error != "" {
+			result.Success = false
+		}
+	}
+
+	if !opts.Keep {
+		step("teardown", func() (string, error) {
+			nsErr := kc.DeleteNamespace(target, timeout)
+			if velero {
+				if err := kc.DeleteVeleroBackup(veleroNamespace, name, name); err != nil {
+					return "", err
+				}
+			}
+			return "deleted namespace " + target, nsErr
+		})
+	}
+	result.Finished = time.Now()
+	return result, nil
+}
+
+// runCommand runs a configured backup or restore command in a local shell, it is killed after the timeout
+// of the drill
+func runCommand(command string, env []string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	// the shell may leave children holding the output open, stop waiting for them shortly after the kill
+	cmd.WaitDelay = 10 * time.Second
+	output, err := cmd.CombinedOutput()
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	last := lines[len(lines)-1]
+	if ctx.Err() != nil {
+		return last, fmt.Errorf("timed out after %s: %s", timeout, last)
+	}
+	if err != nil {
+		return last, fmt.Errorf("%v: %s", err, last)
+	}
+	return last, nil
+}
+
+// LoadHistory returns the results of the previous drills, oldest first
+func LoadHistory() ([]Result, error) {
+	data, err := os.ReadFile(HistoryFile)
+	if os.IsNotExist(err) {
+		return []Result{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	results := []Result{}
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", HistoryFile, err)
+	}
+	return results, nil
+}
+
+// Record appends a result to the drill history
+func Record(result Result) error {
+	results, err := LoadHistory()
+	if err != nil {
+		return err
+	}
+	results = append(results, result)
+	if len(results) > keepResults {
+		results = results[len(results)-keepResults:]
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(HistoryFile), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(HistoryFile, data, 0600)
+}
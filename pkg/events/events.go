@@ -0,0 +1,195 @@
+// Package events records Kubernetes Events on the objects of findings when they open and resolve, and keeps
+// the open findings of the last run in a config map, so the assessment of healthctl is visible with kubectl
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+	"healthctl/pkg/report"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// DefaultRefresh is how often the event of a finding that stays open is recorded again
+const DefaultRefresh = 45 * time.Minute
+
+// maxConfigMapFindings limits the findings kept in the config map, which may not exceed 1MiB
+const maxConfigMapFindings = 500
+
+// Reasons of the recorded events
+const (
+	ReasonOpened   = "FindingOpened"
+	ReasonOpen     = "FindingOpen"
+	ReasonResolved = "FindingResolved"
+)
+
+// Recorded is a finding an event was recorded for
+type Recorded struct {
+	Check    string             `json:"check"`
+	Resource models.ResourceRef `json:"resource"`
+	Severity models.Severity    `json:"severity"`
+	Message  string             `json:"message"`
+	Recorded time.Time          `json:"recorded"`
+}
+
+// Recorder records the events of the findings of the clusters it is given a client of
+type Recorder struct {
+	Severity models.Severity
+	Refresh  time.Duration
+	// ConfigMapNamespace and ConfigMapName name the config map with the open findings, none without a name
+	ConfigMapNamespace string
+	ConfigMapName      string
+}
+
+// New returns the recorder of the events configuration, nil when events are disabled
+func New(cfg config.Events) (*Recorder, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	recorder := &Recorder{Severity: models.SeverityWarning, Refresh: DefaultRefresh}
+	if cfg.Severity != "" {
+		recorder.Severity = models.Severity(cfg.Severity)
+	}
+	if cfg.Refresh != "" {
+		refresh, err := time.ParseDuration(cfg.Refresh)
+		if err != nil {
+			return nil, fmt.Errorf("invalid events.refresh %q: %v", cfg.Refresh, err)
+		}
+		recorder.Refresh = refresh
+	}
+	if cfg.ConfigMap != "" {
+		namespace, name, found := strings.Cut(cfg.ConfigMap, "/")
+		if !found {
+			return nil, fmt.Errorf("invalid events.configMap %q, use namespace/name", cfg.ConfigMap)
+		}
+		recorder.ConfigMapNamespace, recorder.ConfigMapName = namespace, name
+	}
+	return recorder, nil
+}
+
+// Record records an event for every finding that opened or changed its severity since the last run, again
+// for open findings once per refresh interval, and a normal event for every resolved finding. The recorded
+// findings are kept in the state file, findings of checks that did not run are not resolved.
+func (r *Recorder) Record(client *k8s.K8sClient, rep report.Report, file string) error {
+	recorded, err := load(file)
+	if err != nil {
+		return err
+	}
+	ran := make(map[string]bool)
+	for _, check := range rep.Checks {
+		ran[check.Check] = check.Result == models.ResultPass || check.Result == models.ResultFail
+	}
+
+	errs := []string{}
+	// findings without an object count as recorded, they would fail on every run
+	record := func(ref models.ResourceRef, eventType, reason, message string) bool {
+		err := client.RecordEvent(ref, eventType, reason, message)
+		if err != nil && !errors.Is(err, k8s.ErrNoObject) {
+			if len(errs) < 5 {
+				errs = append(errs, fmt.Sprintf("%s: %v", ref, err))
+			}
+			return false
+		}
+		return true
+	}
+
+	current := make(map[string]bool)
+	for _, finding := range rep.Findings {
+		if !finding.Failing() || finding.Severity.Rank() < r.Severity.Rank() {
+			continue
+		}
+		current[finding.ID] = true
+		previous, found := recorded[finding.ID]
+		reason := ReasonOpened
+		switch {
+		case found && previous.Severity == finding.Severity && rep.Generated.Sub(previous.Recorded) < r.Refresh:
+			continue
+		case found && previous.Severity == finding.Severity:
+			reason = ReasonOpen
+		}
+		if record(finding.Resource, v1.EventTypeWarning, reason, fmt.Sprintf("[%s] %s: %s", finding.Severity, finding.Check, finding.Message)) {
+			recorded[finding.ID] = Recorded{Check: finding.Check, Resource: finding.Resource, Severity: finding.Severity,
+				Message: finding.Message, Recorded: rep.Generated}
+		}
+	}
+	for id, previous := range recorded {
+		if current[id] || !ran[previous.Check] {
+			continue
+		}
+		if record(previous.Resource, v1.EventTypeNormal, ReasonResolved, fmt.Sprintf("%s: %s is resolved", previous.Check, previous.Message)) {
+			delete(recorded, id)
+		}
+	}
+	if err := save(file, recorded); err != nil {
+		return err
+	}
+
+	if r.ConfigMapName != "" {
+		if err := client.ApplyConfigMap(r.ConfigMapNamespace, r.ConfigMapName, configMapData(rep)); err != nil {
+			errs = append(errs, fmt.Sprintf("config map %s/%s: %v", r.ConfigMapNamespace, r.ConfigMapName, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("recording events failed for %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// configMapData is the score, the failing findings by severity and the failing findings of a report
+func configMapData(rep report.Report) map[string]string {
+	open := []models.Finding{}
+	counts := map[models.Severity]int{}
+	for _, finding := range rep.Findings {
+		if finding.Failing() {
+			counts[finding.Severity]++
+			if len(open) < maxConfigMapFindings {
+				open = append(open, finding)
+			}
+		}
+	}
+	data, _ := json.MarshalIndent(open, "", "  ")
+	return map[string]string{
+		"cluster":   rep.Cluster,
+		"generated": rep.Generated.UTC().Format(time.RFC3339),
+		"score":     strconv.Itoa(rep.Scorecard.Cluster.Score),
+		"critical":  strconv.Itoa(counts[models.SeverityCritical]),
+		"warning":   strconv.Itoa(counts[models.SeverityWarning]),
+		"findings":  string(data),
+	}
+}
+
+func load(file string) (map[string]Recorded, error) {
+	recorded := make(map[string]Recorded)
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return recorded, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", file, err)
+	}
+	return recorded, nil
+}
+
+func save(file string, recorded map[string]Recorded) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
@@ -0,0 +1,68 @@
+package findings
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"healthctl/pkg/models"
+)
+
+// Baseline is the set of findings accepted when healthctl was adopted on a cluster.
+// Later runs only report findings that are not part of the baseline.
+type Baseline struct {
+	Created  time.Time                 `json:"created"`
+	Findings map[string]models.Finding `json:"findings"`
+}
+
+// NewBaseline accepts all the given findings
+func NewBaseline(findings []models.Finding, now time.Time) *Baseline {
+	baseline := &Baseline{Created: now, Findings: make(map[string]models.Finding)}
+	for _, finding := range findings {
+		baseline.Findings[finding.ID] = finding
+	}
+	return baseline
+}
+
+// LoadBaseline reads a baseline file, a missing file returns nil
+func LoadBaseline(file string) (*Baseline, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var baseline Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, err
+	}
+	return &baseline, nil
+}
+
+// Save writes the baseline file, creating the state directory when needed
+func (b *Baseline) Save(file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// Regressions returns the findings that are not part of the baseline and how many were hidden
+func (b *Baseline) Regressions(findings []models.Finding) ([]models.Finding, int) {
+	if b == nil {
+		return findings, 0
+	}
+	regressions := []models.Finding{}
+	for _, finding := range findings {
+		if _, accepted := b.Findings[finding.ID]; !accepted {
+			regressions = append(regressions, finding)
+		}
+	}
+	return regressions, len(findings) - len(regressions)
+}
@@ -0,0 +1,236 @@
+package findings
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/models"
+)
+
+const (
+	defaultFlapChanges = 4
+	defaultFlapWindow  = 6 * time.Hour
+)
+
+type seen struct {
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Check     string    `json:"check,omitempty"`
+	// Reported and Missed count the consecutive runs the finding was and was not reported in
+	Reported int `json:"reported,omitempty"`
+	Missed   int `json:"missed,omitempty"`
+	// Pending findings were not reported in enough runs to open yet, closed findings are only kept to
+	// detect flapping. Findings of earlier versions have neither and are open.
+	Pending bool `json:"pending,omitempty"`
+	Closed  bool `json:"closed,omitempty"`
+	// Changes are the times the finding opened or closed within the flapping window
+	Changes []time.Time `json:"changes,omitempty"`
+	// Finding is the last report of an open finding, reported while it is absent for fewer runs than it
+	// takes to close
+	Finding *models.Finding `json:"finding,omitempty"`
+	// Flapping marks the finding that stands for the flapping findings of a check
+	Flapping bool `json:"flapping,omitempty"`
+}
+
+// History remembers when each finding was first and last observed between runs
+type History map[string]seen
+
+// LoadHistory reads the history file, a missing file returns an empty history
+func LoadHistory(file string) (History, error) {
+	history := History{}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// Track stamps first-seen and last-seen on the findings and applies the hysteresis: a finding is only
+// returned once it was reported in enough consecutive runs, and it is returned until it was absent in
+// enough runs. Findings that are resolved are dropped so a recurring issue starts a new first-seen.
+// Findings of checks that did not run or failed to run are left as they are, a failed check is not a
+// resolution, and results reused from the cache do not count as a run. With flap detection the findings
+// of a check that keep opening and closing are replaced by one finding of the check until they settle.
+func (h History) Track(checks []models.CheckResult, findings []models.Finding, now time.Time, hysteresis config.Hysteresis) []models.Finding {
+	flapping := hysteresis.Flapping != nil
+	window := defaultFlapWindow
+	if flapping {
+		if parsed, err := time.ParseDuration(hysteresis.Flapping.Window); err == nil && parsed > 0 {
+			window = parsed
+		}
+	}
+
+	cached := make(map[string]bool)
+	for _, check := range checks {
+		cached[check.Check] = check.Cached
+	}
+	tracked := []models.Finding{}
+	current := make(map[string]bool)
+	for _, finding := range findings {
+		openAfter, closeAfter := hysteresis.Runs(finding.Check)
+		entry, found := h[finding.ID]
+		if found && !entry.Closed && cached[finding.Check] {
+			// results reused from the cache were counted when they were checked
+			finding.FirstSeen, finding.LastSeen = entry.FirstSeen, entry.LastSeen
+			current[finding.ID] = true
+			if !entry.Pending {
+				tracked = append(tracked, finding)
+			}
+			continue
+		}
+		if !found || entry.Closed {
+			entry = seen{FirstSeen: now, Pending: true, Changes: entry.Changes}
+		}
+		entry.LastSeen = now
+		entry.Check = finding.Check
+		entry.Reported++
+		entry.Missed = 0
+		if entry.Pending && entry.Reported >= openAfter {
+			entry.Pending = false
+			if flapping {
+				entry.Changes = append(entry.Changes, now)
+			}
+		}
+		finding.FirstSeen = entry.FirstSeen
+		finding.LastSeen = entry.LastSeen
+		entry.Finding = nil
+		if closeAfter > 1 {
+			entry.Finding = &finding
+		}
+		h[finding.ID] = entry
+		current[finding.ID] = true
+		if !entry.Pending {
+			tracked = append(tracked, finding)
+		}
+	}
+
+	ran := make(map[string]bool)
+	for _, check := range checks {
+		ran[check.Check] = !check.Cached && (check.Result == models.ResultPass || check.Result == models.ResultFail)
+	}
+	for id, entry := range h {
+		if current[id] || entry.Flapping || (entry.Check != "" && !ran[entry.Check]) {
+			continue
+		}
+		_, closeAfter := hysteresis.Runs(entry.Check)
+		entry.Reported = 0
+		entry.Missed++
+		switch {
+		case entry.Pending:
+			// never opened, so it did not change either
+			entry.Pending = false
+			entry.Closed = true
+		case !entry.Closed && entry.Missed < closeAfter && entry.Finding != nil:
+			tracked = append(tracked, *entry.Finding)
+		case !entry.Closed:
+			entry.Closed = true
+			entry.Finding = nil
+			if flapping {
+				entry.Changes = append(entry.Changes, now)
+			}
+		}
+		if entry.Closed && recent(entry.Changes, now, window) == 0 {
+			delete(h, id)
+			continue
+		}
+		h[id] = entry
+	}
+
+	if !flapping {
+		return tracked
+	}
+	return h.escalateFlapping(tracked, now, hysteresis.Flapping, window)
+}
+
+// escalateFlapping replaces the findings of checks that changed too often within the window by one
+// finding per check
+func (h History) escalateFlapping(tracked []models.Finding, now time.Time, settings *config.Flapping, window time.Duration) []models.Finding {
+	changes := settings.Changes
+	if changes <= 0 {
+		changes = defaultFlapChanges
+	}
+	flapping := make(map[string]bool)
+	checks := make(map[string]int)
+	for id, entry := range h {
+		if entry.Flapping {
+			continue
+		}
+		entry.Changes = entry.Changes[len(entry.Changes)-recent(entry.Changes, now, window):]
+		h[id] = entry
+		if len(entry.Changes) >= changes {
+			flapping[id] = true
+			checks[entry.Check]++
+		}
+	}
+
+	result := []models.Finding{}
+	for _, finding := range tracked {
+		if !flapping[finding.ID] {
+			result = append(result, finding)
+		}
+	}
+	current := make(map[string]bool)
+	for _, check := range slices.Sorted(maps.Keys(checks)) {
+		_, label, _ := strings.Cut(check, "/")
+		finding := models.Finding{
+			Check:    check,
+			Resource: models.ResourceRef{Kind: "Check", Name: label},
+			Reason:   "Flapping",
+			Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("%d findings opened and closed at least %d times within %s, they are held back until they settle",
+				checks[check], changes, window),
+		}
+		finding.ID = models.FindingID(finding.Check, finding.Resource, finding.Reason)
+		entry, found := h[finding.ID]
+		if !found {
+			entry = seen{FirstSeen: now, Check: check, Flapping: true}
+		}
+		entry.LastSeen = now
+		h[finding.ID] = entry
+		current[finding.ID] = true
+		finding.FirstSeen = entry.FirstSeen
+		finding.LastSeen = entry.LastSeen
+		result = append(result, finding)
+	}
+	for id, entry := range h {
+		if entry.Flapping && !current[id] {
+			delete(h, id)
+		}
+	}
+	return result
+}
+
+// recent returns the number of changes within the window before now, changes are kept in order
+func recent(changes []time.Time, now time.Time, window time.Duration) int {
+	for i, change := range changes {
+		if now.Sub(change) < window {
+			return len(changes) - i
+		}
+	}
+	return 0
+}
+
+// Save writes the history file, creating the state directory when needed
+func (h History) Save(file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
@@ -0,0 +1,104 @@
+package findings
+
+import (
+	"regexp"
+	"strings"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/models"
+)
+
+// builtinKnowledge are the remediation hints shipped with healthctl, entries in the config file win
+var builtinKnowledge = []config.KnowledgeEntry{
+	{Check: "k8s/Nodes", Hint: "Check kubelet and container runtime logs on the node, cordon and drain it if it does not recover."},
+	{Check: "k8s/Node Problems", Hint: "Look at the node-problem-detector condition message, replace nodes with hardware or kernel problems."},
+	{Check: "k8s/GPUs", Hint: "Restart the device plugin pod on the node and check the driver with nvidia-smi."},
+	{Check: "k8s/Cluster Autoscaler", Hint: "Check cloud provider quotas and the node group limits, the autoscaler events name the failing group."},
+	{Check: "k8s/Cloud Provider", Hint: "Check the IAM role or service principal of the cloud-controller-manager and the cloud quotas for load balancers and volumes."},
+	{Check: "k8s/Spot Risk", Hint: "Spread the workload over regular and spot nodes with a topology spread constraint or a preferred node affinity."},
+	{Check: "k8s/Multi-Arch", Hint: "Build the image for all node architectures with docker buildx, or pin the workload with a kubernetes.io/arch node selector."},
+	{Check: "k8s/Leader Election", Hint: "Check the logs of all replicas of the controller, a flapping lease often means the API server is slow or the pod is CPU throttled."},
+	{Check: "k8s/API Services", Hint: "Fix or delete the backing service of the APIService, kubectl and controllers fail while it is unavailable."},
+	{Check: "k8s/Admission Latency", Hint: "Check the latency and replicas of the listed webhooks, narrow their rules and namespaceSelector to what they need to see."},
+	{Check: "k8s/Metrics Pipeline", Hint: "Check the metrics-server logs and that it can reach the kubelets on port 10250."},
+	{Check: "k8s/Custom Metrics", Hint: "Check the metrics adapter (prometheus-adapter, KEDA) logs and that the metric query returns data."},
+	{Check: "k8s/Pods", Hint: "Describe the pod and check the logs of the previous container run."},
+	{Check: "k8s/Probes", Hint: "Probe a cheap endpoint that only checks the process itself, keep the timeout below the period and add a startup probe for slow starting applications."},
+	{Check: "k8s/Pod Startup", Hint: "Compare the slowest phase with the time before: scheduling points to full nodes or autoscaler delays, image pull to a bigger image or a slow registry, readiness to the application."},
+	{Check: "k8s/Evictions", Hint: "Raise the memory requests and limits of OOMKilled workloads to their peak usage, evictions mean the node is overcommitted."},
+	{Check: "k8s/Ephemeral Storage", Hint: "Move large scratch data to a volume, raise the ephemeral-storage limit or clean up unused images on the node."},
+	{Check: "k8s/Events", Hint: "Warning events point to the failing object, describe it for details."},
+	{Check: "k8s/Stuck Deletions", Hint: "Check that the controller named for the finalizer is running, remove orphaned finalizers with healthctl finalizers remove."},
+	{Check: "k8s/Owner References", Hint: "Check the kube-controller-manager logs for garbage collector errors, often a broken aggregated API blocks it."},
+	{Check: "paas/Kafka Lag", Hint: "Check that the consumers of the group are running and not stuck on a poison message."},
+	{Check: "paas/Redis Config", Hint: "Put the setting into the redis config of the chart or operator, then restart the nodes one by one."},
+	{Check: "paas/Alertmanager Config", Hint: "Test the routing with amtool config routes test and fix the receiver or matcher named in the finding."},
+	{Check: "paas/Logging Pipeline", Hint: "Check the agent logs for output errors and that the backend accepts writes, e.g. not out of disk or rate limited."},
+	{Check: "storage/MinIO", Hint: "Replace offline drives and check the healing status with mc admin heal."},
+	{Check: "storage/Shared Filesystems", Hint: "Stale file handles need the pods using the volume to be restarted, check the NFS server first."},
+	{Check: "storage/Storage Classes", Hint: "Check the CSI controller and node plugin logs of the provisioner."},
+	{Check: "storage/Disaster Recovery", Hint: "Run healthctl drill backup-restore to prove the backups restore, then fix replication and mirroring gaps."},
+	{Check: "network/DNS and Conntrack", Hint: "Scale CoreDNS or enable NodeLocal DNSCache for slow lookups, raise nf_conntrack_max on nodes with a full conntrack table."},
+	{Check: "network/Service Routing", Hint: "Restart the kube-proxy or CNI agent pod on the affected nodes and check its logs for rule sync errors."},
+	{Check: "network/IP Addresses", Hint: "Lower maxPods on nodes with a small pod CIDR, or add a cluster or service CIDR before the range runs out."},
+	{Check: "security/Service Account Tokens", Hint: "Delete unused legacy token secrets and upgrade clients that do not reload projected tokens, fix the role of the workload identity annotation."},
+	{Check: "security/RBAC", Hint: "Replace wildcard rules with the verbs and resources the workload uses, the audit log shows which ones it calls."},
+	{Check: "security/Pod Security", Hint: "Set a securityContext with runAsNonRoot, allowPrivilegeEscalation false, seccompProfile RuntimeDefault and drop ALL capabilities."},
+	{Check: "security/Vulnerabilities", Hint: "Rebuild the worst image on a patched base image and redeploy, the scanner lists the fixed versions."},
+	{Check: "security/Image Provenance", Hint: "Mirror foreign images into an allowed registry and sign them in the pipeline that builds them."},
+	{Check: "security/Policy Reports", Hint: "Fix the resource or add a policy exception, objects created before a policy was enforced are only reported by the audit."},
+}
+
+var anchorPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Annotate sets the remediation hint and documentation link of every finding.
+// An entry for the reason of a finding wins over an entry for the whole check.
+func Annotate(findings []models.Finding, kb config.KnowledgeBase) []models.Finding {
+	entries := append(append([]config.KnowledgeEntry{}, kb.Entries...), builtinKnowledge...)
+	for i := range findings {
+		for _, entry := range []*config.KnowledgeEntry{lookup(entries, findings[i], true), lookup(entries, findings[i], false)} {
+			if entry == nil {
+				continue
+			}
+			if findings[i].Hint == "" {
+				findings[i].Hint = entry.Hint
+			}
+			if findings[i].DocURL == "" {
+				findings[i].DocURL = entry.URL
+			}
+		}
+		if findings[i].DocURL == "" && kb.BaseURL != "" {
+			findings[i].DocURL = strings.TrimSuffix(kb.BaseURL, "/") + "/" + strings.Trim(anchorPattern.ReplaceAllString(strings.ToLower(findings[i].Check), "-"), "-")
+		}
+	}
+	return findings
+}
+
+// lookup returns the first entry matching the check of the finding, with or without a reason
+func lookup(entries []config.KnowledgeEntry, finding models.Finding, withReason bool) *config.KnowledgeEntry {
+	for i, entry := range entries {
+		if withReason != (entry.Reason != "") || !glob(entry.Check, finding.Check) {
+			continue
+		}
+		if withReason && entry.Reason != finding.Reason {
+			continue
+		}
+		return &entries[i]
+	}
+	return nil
+}
+
+// RunbookFor returns the runbook of a finding, a runbook for the reason of the finding wins over one for the whole check
+func RunbookFor(runbooks []config.Runbook, finding models.Finding) (config.Runbook, bool) {
+	for _, withReason := range []bool{true, false} {
+		for _, runbook := range runbooks {
+			if withReason != (runbook.Reason != "") || !glob(runbook.Check, finding.Check) {
+				continue
+			}
+			if !withReason || runbook.Reason == finding.Reason {
+				return runbook, true
+			}
+		}
+	}
+	return config.Runbook{}, false
+}
@@ -0,0 +1,88 @@
+package findings
+
+import (
+	"math"
+	"sort"
+
+	"healthctl/pkg/models"
+)
+
+// severityWeights is the number of points a single finding takes off a score of 100
+var severityWeights = map[models.Severity]int{
+	models.SeverityInfo:     0,
+	models.SeverityWarning:  5,
+	models.SeverityCritical: 20,
+}
+
+// ClusterScope is the scorecard entry for findings that do not belong to a namespace
+const ClusterScope = "(cluster)"
+
+// Score is the health of a namespace or of the whole cluster, 100 means no findings
+type Score struct {
+	Name     string `json:"name"`
+	Score    int    `json:"score"`
+	Critical int    `json:"critical"`
+	Warning  int    `json:"warning"`
+	Info     int    `json:"info"`
+}
+
+// Scorecard holds the cluster score and the score of every namespace
+type Scorecard struct {
+	Cluster    Score   `json:"cluster"`
+	Namespaces []Score `json:"namespaces"`
+}
+
+func (s *Score) add(severity models.Severity) {
+	switch severity {
+	case models.SeverityCritical:
+		s.Critical++
+	case models.SeverityWarning:
+		s.Warning++
+	default:
+		s.Info++
+	}
+	s.Score = max(0, s.Score-severityWeights[severity])
+}
+
+// NewScorecard scores the findings per namespace, suppressed findings are not counted.
+// Every namespace and the cluster scope start at 100 and lose the severity weight of each
+// of their findings. The cluster score is the average of those scores, its counts are the
+// totals of all findings.
+func NewScorecard(findings []models.Finding, namespaces []string) Scorecard {
+	scores := map[string]*Score{ClusterScope: {Name: ClusterScope, Score: 100}}
+	for _, namespace := range namespaces {
+		scores[namespace] = &Score{Name: namespace, Score: 100}
+	}
+
+	cluster := Score{Name: "cluster"}
+	for _, finding := range findings {
+		if finding.Suppressed {
+			continue
+		}
+		scope := finding.Resource.Namespace
+		if scope == "" {
+			scope = ClusterScope
+		}
+		if _, found := scores[scope]; !found {
+			scores[scope] = &Score{Name: scope, Score: 100}
+		}
+		scores[scope].add(finding.Severity)
+		cluster.add(finding.Severity)
+	}
+
+	scorecard := Scorecard{}
+	total := 0
+	for _, score := range scores {
+		scorecard.Namespaces = append(scorecard.Namespaces, *score)
+		total += score.Score
+	}
+	sort.Slice(scorecard.Namespaces, func(i, j int) bool {
+		if scorecard.Namespaces[i].Score != scorecard.Namespaces[j].Score {
+			return scorecard.Namespaces[i].Score < scorecard.Namespaces[j].Score
+		}
+		return scorecard.Namespaces[i].Name < scorecard.Namespaces[j].Name
+	})
+	cluster.Score = int(math.Round(float64(total) / float64(len(scores))))
+	scorecard.Cluster = cluster
+	return scorecard
+}
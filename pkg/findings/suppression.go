@@ -0,0 +1,174 @@
+package findings
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"healthctl/pkg/models"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Suppression accepts a known issue so it no longer fails the suite.
+// A suppression matches either a finding ID or a combination of glob matchers.
+// Finding IDs are the same in every cluster, Cluster limits a suppression to the matching clusters.
+type Suppression struct {
+	ID        string `json:"id,omitempty"`
+	Cluster   string `json:"cluster,omitempty"`
+	Check     string `json:"check,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Expires   string `json:"expires,omitempty"`
+	Reason    string `json:"reason"`
+
+	expiresAt time.Time
+}
+
+// suppressionMutex serializes AddSuppression, concurrent calls would lose all but one of their suppressions
+var suppressionMutex sync.Mutex
+
+type suppressionFile struct {
+	Suppressions []Suppression `json:"suppressions"`
+}
+
+// LoadSuppressions reads a yaml or json suppression file.
+// A missing file is not an error, it just means nothing is suppressed.
+func LoadSuppressions(file string) ([]Suppression, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sf suppressionFile
+	if err := yaml.Unmarshal(data, &sf); err != nil {
+		return nil, fmt.Errorf("parsing suppression file %s: %v", file, err)
+	}
+	for i := range sf.Suppressions {
+		s := &sf.Suppressions[i]
+		if s.Reason == "" {
+			return nil, fmt.Errorf("suppression %d in %s has no reason", i+1, file)
+		}
+		if s.ID == "" && s.Check == "" && s.Kind == "" && s.Namespace == "" && s.Name == "" {
+			return nil, fmt.Errorf("suppression %d in %s has no id or matcher", i+1, file)
+		}
+		if s.Expires != "" {
+			expiresAt, err := parseExpiry(s.Expires)
+			if err != nil {
+				return nil, fmt.Errorf("suppression %d in %s: %v", i+1, file, err)
+			}
+			s.expiresAt = expiresAt
+		}
+	}
+	return sf.Suppressions, nil
+}
+
+// AddSuppression appends a suppression to the suppression file, creating the file when needed.
+// The file is replaced atomically, so comments in it are lost.
+func AddSuppression(file string, s Suppression) error {
+	if s.Reason == "" {
+		return fmt.Errorf("suppression has no reason")
+	}
+	if s.ID == "" && s.Check == "" && s.Kind == "" && s.Namespace == "" && s.Name == "" {
+		return fmt.Errorf("suppression has no id or matcher")
+	}
+	if s.Expires != "" {
+		if _, err := parseExpiry(s.Expires); err != nil {
+			return err
+		}
+	}
+
+	suppressionMutex.Lock()
+	defer suppressionMutex.Unlock()
+	var sf suppressionFile
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := yaml.Unmarshal(data, &sf); err != nil {
+		return fmt.Errorf("parsing suppression file %s: %v", file, err)
+	}
+	sf.Suppressions = append(sf.Suppressions, s)
+	data, err = yaml.Marshal(sf)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+func parseExpiry(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expiry %q, use YYYY-MM-DD or RFC3339", value)
+	}
+	// a date only expiry is valid for the whole day
+	return t.Add(24 * time.Hour), nil
+}
+
+// Expired returns true once the suppression is past its expiry
+func (s Suppression) Expired(now time.Time) bool {
+	return !s.expiresAt.IsZero() && now.After(s.expiresAt)
+}
+
+// Matches returns true when the suppression applies to the finding
+func (s Suppression) Matches(f models.Finding) bool {
+	if s.ID != "" && s.ID != f.ID {
+		return false
+	}
+	return glob(s.Check, f.Check) &&
+		glob(s.Kind, f.Resource.Kind) &&
+		glob(s.Namespace, f.Resource.Namespace) &&
+		glob(s.Name, f.Resource.Name)
+}
+
+// ForCluster returns the suppressions that apply to the cluster
+func ForCluster(suppressions []Suppression, cluster string) []Suppression {
+	matching := []Suppression{}
+	for _, s := range suppressions {
+		if glob(s.Cluster, cluster) {
+			matching = append(matching, s)
+		}
+	}
+	return matching
+}
+
+func glob(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
+
+// Suppress marks every finding matched by an active suppression.
+// Suppressed findings stay in the list so reports can still show them.
+func Suppress(findings []models.Finding, suppressions []Suppression, now time.Time) []models.Finding {
+	for i := range findings {
+		for _, s := range suppressions {
+			if s.Expired(now) || !s.Matches(findings[i]) {
+				continue
+			}
+			findings[i].Suppressed = true
+			findings[i].SuppressionReason = s.Reason
+			break
+		}
+	}
+	return findings
+}
@@ -0,0 +1,69 @@
+package findings
+
+import (
+	"path"
+	"sort"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/models"
+)
+
+// AssignTeams sets the owning team of every finding from the namespace ownership in the config
+func AssignTeams(findings []models.Finding, cfg *config.Config, namespaceLabels map[string]map[string]string) []models.Finding {
+	for i := range findings {
+		namespace := findings[i].Resource.Namespace
+		findings[i].Team = cfg.TeamFor(namespace, namespaceLabels[namespace])
+	}
+	return findings
+}
+
+// ByTeam groups findings by their owning team
+func ByTeam(findings []models.Finding) map[string][]models.Finding {
+	teams := make(map[string][]models.Finding)
+	for _, finding := range findings {
+		teams[finding.Team] = append(teams[finding.Team], finding)
+	}
+	return teams
+}
+
+// ForTeam returns only the findings owned by the team, without a team all findings are returned.
+// A team without findings gets an empty slice, not nil.
+func ForTeam(findings []models.Finding, team string) []models.Finding {
+	if team == "" {
+		return findings
+	}
+	owned := []models.Finding{}
+	for _, finding := range findings {
+		if finding.Team == team {
+			owned = append(owned, finding)
+		}
+	}
+	return owned
+}
+
+// InNamespaces returns the findings in namespaces matching one of the globs, cluster scoped findings are left out
+func InNamespaces(findings []models.Finding, patterns []string) []models.Finding {
+	matching := []models.Finding{}
+	for _, finding := range findings {
+		if finding.Resource.Namespace == "" {
+			continue
+		}
+		for _, pattern := range patterns {
+			if matched, err := path.Match(pattern, finding.Resource.Namespace); err == nil && matched {
+				matching = append(matching, finding)
+				break
+			}
+		}
+	}
+	return matching
+}
+
+// TeamNames returns the sorted names of the teams in the grouped findings
+func TeamNames(teams map[string][]models.Finding) []string {
+	names := []string{}
+	for name := range teams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
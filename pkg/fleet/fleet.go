@@ -0,0 +1,122 @@
+// Package fleet connects healthctl agents running in every cluster with a central healthctl serve. Agents
+// push their reports over mutual TLS, the common name of the agent certificate names the cluster.
+package fleet
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/report"
+)
+
+// ReportPath is where agents push their reports
+const ReportPath = "/api/v1/agent/report"
+
+// ServerTLSConfig loads the server certificate and verifies client certificates against the client CA when
+// one is set. Client certificates are optional on the connection so browsers can reach the dashboard,
+// the agent endpoint checks for a verified certificate itself.
+func ServerTLSConfig(cfg config.ServeTLS) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("loading server certificate: %v", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if cfg.ClientCA != "" {
+		pool, err := loadPool(cfg.ClientCA)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// ClientTLSConfig loads the agent certificate and the CA of the server
+func ClientTLSConfig(cfg config.AgentTLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Cert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+		if err != nil {
+			return nil, fmt.Errorf("loading agent certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.CA != "" {
+		pool, err := loadPool(cfg.CA)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+func loadPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates in %s", file)
+	}
+	return pool, nil
+}
+
+// AgentCluster returns the cluster of the agent from its verified client certificate
+func AgentCluster(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	cluster := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	return cluster, cluster != ""
+}
+
+// Client pushes the reports of an agent to the fleet server
+type Client struct {
+	Server string
+	http   *http.Client
+}
+
+// NewClient returns a client for the fleet server of the agent configuration
+func NewClient(cfg config.Agent) (*Client, error) {
+	if cfg.Server == "" {
+		return nil, fmt.Errorf("agent.server is not configured")
+	}
+	tlsConfig, err := ClientTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		Server: strings.TrimSuffix(cfg.Server, "/"),
+		http:   &http.Client{Timeout: time.Minute, Transport: &http.Transport{TLSClientConfig: tlsConfig}},
+	}, nil
+}
+
+// Push sends a report to the fleet server
+func (c *Client) Push(r report.Report) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Post(c.Server+ReportPath, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("fleet server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
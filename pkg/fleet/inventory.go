@@ -0,0 +1,69 @@
+package fleet
+
+import (
+	"sort"
+
+	"healthctl/pkg/results"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ClusterSummary is a cluster of the fleet with its inventory labels and latest run. The cluster of an
+// agent is stale when the agent did not report in time.
+type ClusterSummary struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Agent  bool              `json:"agent,omitempty"`
+	Stale  bool              `json:"stale,omitempty"`
+	Latest *results.Run      `json:"latest,omitempty"`
+}
+
+// GroupSummary aggregates the clusters sharing the value of an inventory label
+type GroupSummary struct {
+	Value    string   `json:"value"`
+	Clusters []string `json:"clusters"`
+	Stale    int      `json:"stale"`
+	// Score is the lowest score of the clusters in the group
+	Score    int `json:"score"`
+	Critical int `json:"critical"`
+	Warning  int `json:"warning"`
+}
+
+// Filter returns the clusters whose labels match the selector
+func Filter(clusters []ClusterSummary, selector labels.Selector) []ClusterSummary {
+	matched := []ClusterSummary{}
+	for _, cluster := range clusters {
+		if selector.Matches(labels.Set(cluster.Labels)) {
+			matched = append(matched, cluster)
+		}
+	}
+	return matched
+}
+
+// GroupBy aggregates the clusters by the value of a label, clusters without the label form the group ""
+func GroupBy(clusters []ClusterSummary, key string) []GroupSummary {
+	groups := make(map[string]*GroupSummary)
+	for _, cluster := range clusters {
+		value := cluster.Labels[key]
+		group, found := groups[value]
+		if !found {
+			group = &GroupSummary{Value: value, Score: 100}
+			groups[value] = group
+		}
+		group.Clusters = append(group.Clusters, cluster.Name)
+		if cluster.Stale {
+			group.Stale++
+		}
+		if cluster.Latest != nil {
+			group.Score = min(group.Score, cluster.Latest.Score)
+			group.Critical += cluster.Latest.Critical
+			group.Warning += cluster.Latest.Warning
+		}
+	}
+	summaries := []GroupSummary{}
+	for _, group := range groups {
+		summaries = append(summaries, *group)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Value < summaries[j].Value })
+	return summaries
+}
@@ -0,0 +1,191 @@
+// Package grafana provisions the dashboards of the metrics healthctl exports through the Grafana HTTP API.
+// The dashboards are embedded in the binary and query a Prometheus datasource, the remote write receiver
+// or the Prometheus compatible API of VictoriaMetrics the exporters write to.
+package grafana
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"healthctl/pkg/config"
+)
+
+//go:embed dashboards
+var dashboards embed.FS
+
+// DefaultFolder is the folder of the dashboards without a configured folder
+const DefaultFolder = "healthctl"
+
+// Dashboard is an embedded dashboard model
+type Dashboard struct {
+	UID   string
+	Title string
+	Model map[string]any
+}
+
+// Dashboards returns the embedded dashboards. With a datasource uid their datasource variable defaults to it.
+func Dashboards(datasource string) ([]Dashboard, error) {
+	files, err := fs.Glob(dashboards, "dashboards/*.json")
+	if err != nil {
+		return nil, err
+	}
+	result := []Dashboard{}
+	for _, file := range files {
+		data, err := dashboards.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		model := make(map[string]any)
+		if err := json.Unmarshal(data, &model); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		if datasource != "" {
+			setDatasource(model, datasource)
+		}
+		uid, _ := model["uid"].(string)
+		title, _ := model["title"].(string)
+		result = append(result, Dashboard{UID: uid, Title: title, Model: model})
+	}
+	return result, nil
+}
+
+// setDatasource makes the datasource the current value of the datasource variable
+func setDatasource(model map[string]any, datasource string) {
+	templating, _ := model["templating"].(map[string]any)
+	variables, _ := templating["list"].([]any)
+	for _, variable := range variables {
+		if variable, ok := variable.(map[string]any); ok && variable["type"] == "datasource" {
+			variable["current"] = map[string]any{"text": datasource, "value": datasource}
+		}
+	}
+}
+
+// Client calls the Grafana HTTP API
+type Client struct {
+	cfg    config.Grafana
+	client *http.Client
+}
+
+// New returns a client of the configured Grafana
+func New(cfg config.Grafana) (*Client, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("no grafana url configured")
+	}
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+	if cfg.Folder == "" {
+		cfg.Folder = DefaultFolder
+	}
+	return &Client{cfg: cfg, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// Provisioned is a dashboard created or updated in Grafana
+type Provisioned struct {
+	Title   string
+	URL     string
+	Version int
+}
+
+// Provision creates the folder when it does not exist and creates the dashboards in it, replacing the
+// dashboards of an earlier provisioning. Changes made to them in Grafana are overwritten.
+func (c *Client) Provision(dashboards []Dashboard) ([]Provisioned, error) {
+	folder, err := c.ensureFolder()
+	if err != nil {
+		return nil, err
+	}
+	provisioned := []Provisioned{}
+	for _, dashboard := range dashboards {
+		// the id is the one of the Grafana instance, dashboards are matched by uid
+		dashboard.Model["id"] = nil
+		request := map[string]any{
+			"dashboard": dashboard.Model,
+			"folderUid": folder,
+			"overwrite": true,
+			"message":   "provisioned by healthctl grafana provision",
+		}
+		response := struct {
+			URL     string `json:"url"`
+			Version int    `json:"version"`
+		}{}
+		if err := c.call(http.MethodPost, "/api/dashboards/db", request, &response); err != nil {
+			return provisioned, fmt.Errorf("provisioning dashboard %s: %v", dashboard.Title, err)
+		}
+		provisioned = append(provisioned, Provisioned{Title: dashboard.Title, URL: c.cfg.URL + response.URL, Version: response.Version})
+	}
+	return provisioned, nil
+}
+
+var nonUIDCharacters = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// ensureFolder returns the uid of the folder, which is derived from its title, and creates it when missing
+func (c *Client) ensureFolder() (string, error) {
+	uid := strings.Trim(nonUIDCharacters.ReplaceAllString(strings.ToLower(c.cfg.Folder), "-"), "-")
+	if len(uid) > 40 {
+		uid = uid[:40]
+	}
+	err := c.call(http.MethodGet, "/api/folders/"+uid, nil, nil)
+	if err == nil {
+		return uid, nil
+	}
+	if apiErr, ok := err.(*apiError); !ok || apiErr.status != http.StatusNotFound {
+		return "", fmt.Errorf("reading folder %s: %v", c.cfg.Folder, err)
+	}
+	if err := c.call(http.MethodPost, "/api/folders", map[string]string{"uid": uid, "title": c.cfg.Folder}, nil); err != nil {
+		return "", fmt.Errorf("creating folder %s: %v", c.cfg.Folder, err)
+	}
+	return uid, nil
+}
+
+// apiError is a response of the API that is not a success
+type apiError struct {
+	status int
+	text   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("grafana returned %d %s: %s", e.status, http.StatusText(e.status), e.text)
+}
+
+func (c *Client) call(method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.cfg.URL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.cfg.Username != "" {
+		req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+	} else if c.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &apiError{status: resp.StatusCode, text: strings.TrimSpace(string(text))}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
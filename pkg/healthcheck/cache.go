@@ -0,0 +1,173 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/models"
+)
+
+// Cache keeps the results of checks on disk for a time to live, so runs shortly after each other, like a
+// retried CI job, reuse them instead of listing the whole cluster again. Results are kept per API server and
+// user, including the impersonated user and groups, since the results depend on the permissions. Results
+// of checks that failed to run or were skipped are not kept, and checks of embedding programs made with Func
+// are never cached.
+type Cache struct {
+	File string
+	// TTL is how long the results of every check are reused
+	TTL time.Duration
+	// TTLs overrides the time to live of checks by their name as suite/label, 0 turns the cache off for a check
+	TTLs map[string]time.Duration
+}
+
+// NewCache returns the cache of the configuration in the file, nil is returned when no time to live is set
+func NewCache(file string, cfg config.Cache) (*Cache, error) {
+	if cfg.TTL == "" && len(cfg.Checks) == 0 {
+		return nil, nil
+	}
+	cache := &Cache{File: file, TTLs: make(map[string]time.Duration)}
+	if cfg.TTL != "" {
+		ttl, err := time.ParseDuration(cfg.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache.ttl %q: %v", cfg.TTL, err)
+		}
+		cache.TTL = ttl
+	}
+	for check, value := range cfg.Checks {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache.checks.%s %q: %v", check, value, err)
+		}
+		cache.TTLs[check] = ttl
+	}
+	return cache, nil
+}
+
+type cacheEntry struct {
+	Stored  time.Time              `json:"stored"`
+	Results []models.ResourceCheck `json:"results"`
+}
+
+// cacheRun is the cache during a run, a nil cacheRun caches nothing
+type cacheRun struct {
+	cache *Cache
+	// scope is the API server and user the results are kept for
+	scope   string
+	now     time.Time
+	entries map[string]cacheEntry
+	changed bool
+	logf    func(format string, args ...interface{})
+}
+
+// open reads the cache file, the entries are kept by scope and check. Entries older than the longest time
+// to live are dropped.
+func (c *Cache) open(scope string, now time.Time, logf func(format string, args ...interface{})) *cacheRun {
+	if c == nil {
+		return nil
+	}
+	run := &cacheRun{cache: c, scope: scope, now: now, entries: make(map[string]cacheEntry), logf: logf}
+	data, err := os.ReadFile(c.File)
+	if err == nil {
+		err = json.Unmarshal(data, &run.entries)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		logf("reading the result cache, running all checks: %v", err)
+		run.entries = make(map[string]cacheEntry)
+	}
+	longest := c.TTL
+	for _, ttl := range c.TTLs {
+		longest = max(longest, ttl)
+	}
+	for key, entry := range run.entries {
+		if now.Sub(entry.Stored) >= longest {
+			delete(run.entries, key)
+			run.changed = true
+		}
+	}
+	return run
+}
+
+// checkName returns the name of a check in the cache, checks without a unique name are not cached
+func checkName(check Check) (string, bool) {
+	switch c := check.(type) {
+	case builtinCheck:
+		return c.suite + "/" + c.check.Label, true
+	case suiteCheck:
+		return c.suite.Name, true
+	}
+	return "", false
+}
+
+func (c *Cache) ttl(name string) time.Duration {
+	if ttl, found := c.TTLs[name]; found {
+		return ttl
+	}
+	return c.TTL
+}
+
+func (run *cacheRun) key(check Check) (string, time.Duration, bool) {
+	if run == nil {
+		return "", 0, false
+	}
+	name, ok := checkName(check)
+	if !ok {
+		return "", 0, false
+	}
+	ttl := run.cache.ttl(name)
+	return run.scope + "|" + name, ttl, ttl > 0
+}
+
+// get returns the results of the check when they are younger than its time to live
+func (run *cacheRun) get(check Check) ([]models.ResourceCheck, bool) {
+	key, ttl, ok := run.key(check)
+	if !ok {
+		return nil, false
+	}
+	entry, found := run.entries[key]
+	if !found || run.now.Sub(entry.Stored) >= ttl {
+		return nil, false
+	}
+	return entry.Results, true
+}
+
+// put keeps the results of the check unless it failed to run or was skipped, a skipped check may run as soon
+// as what it waits for is there
+func (run *cacheRun) put(check Check, results []models.ResourceCheck) {
+	key, _, ok := run.key(check)
+	if !ok {
+		return
+	}
+	for _, result := range results {
+		if result := result.Result(); result == models.ResultError || result == models.ResultSkipped {
+			delete(run.entries, key)
+			run.changed = true
+			return
+		}
+	}
+	run.entries[key] = cacheEntry{Stored: run.now, Results: results}
+	run.changed = true
+}
+
+// save writes the cache file when results were added, replacing it atomically for concurrent runs
+func (run *cacheRun) save() {
+	if run == nil || !run.changed {
+		return
+	}
+	err := os.MkdirAll(filepath.Dir(run.cache.File), 0755)
+	if err == nil {
+		var data []byte
+		if data, err = json.Marshal(run.entries); err == nil {
+			tmp := run.cache.File + ".tmp"
+			if err = os.WriteFile(tmp, data, 0644); err == nil {
+				err = os.Rename(tmp, run.cache.File)
+			}
+		}
+	}
+	if err != nil {
+		run.logf("saving the result cache: %v", err)
+	}
+}
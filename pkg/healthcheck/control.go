@@ -0,0 +1,61 @@
+package healthcheck
+
+import "sync"
+
+// Control pauses, resumes and stops a run between its checks from another goroutine, like the terminal UI
+type Control struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	paused  bool
+	stopped bool
+}
+
+// NewControl returns the control of a run that is not paused
+func NewControl() *Control {
+	c := &Control{}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+// Pause holds the run before its next check, the running check finishes
+func (c *Control) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = true
+}
+
+// Resume continues a paused run
+func (c *Control) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = false
+	c.changed.Broadcast()
+}
+
+// Stop ends the run before its next check, the checks that did not run have no results
+func (c *Control) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+	c.changed.Broadcast()
+}
+
+// Paused returns true while the run is paused
+func (c *Control) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// wait blocks while the run is paused and returns false when it was stopped, a nil control never pauses
+func (c *Control) wait() bool {
+	if c == nil {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.paused && !c.stopped {
+		c.changed.Wait()
+	}
+	return !c.stopped
+}
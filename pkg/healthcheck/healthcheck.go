@@ -0,0 +1,362 @@
+// Package healthcheck runs the healthctl checks from other programs. It registers no flags and prints
+// nothing: a Runner runs checks into a report, which Reporters write and Notifiers send.
+//
+//	kc, err := k8s.NewK8sClientForConfig(restConfig)
+//	checks, err := healthcheck.Suites("k8s", "storage")
+//	runner := healthcheck.NewRunner(kc, cfg, checks...)
+//	r, err := runner.Run()
+package healthcheck
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"sort"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/findings"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+	"healthctl/pkg/profiles"
+	"healthctl/pkg/report"
+	"healthctl/pkg/testsuite"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Check is a check run by a Runner, its results are reported under its suite as suite/label like the
+// checks of healthctl. The built-in suites and the checks of embedding programs implement it.
+type Check interface {
+	Suite() string
+	Run(clientset *kubernetes.Clientset) []models.ResourceCheck
+}
+
+type funcCheck struct {
+	suite string
+	run   func(clientset *kubernetes.Clientset) []models.ResourceCheck
+}
+
+func (c funcCheck) Suite() string { return c.suite }
+
+func (c funcCheck) Run(clientset *kubernetes.Clientset) []models.ResourceCheck {
+	return testsuite.RunChecks(clientset, []testsuite.Check{{Label: c.suite, Run: c.run}})
+}
+
+// Func turns a function into a Check of a suite, a panic of the function is reported as an error result
+func Func(suite string, run func(clientset *kubernetes.Clientset) []models.ResourceCheck) Check {
+	return funcCheck{suite: suite, run: run}
+}
+
+type suiteCheck struct {
+	suite testsuite.Suite
+}
+
+func (c suiteCheck) Suite() string { return c.suite.Name }
+
+func (c suiteCheck) Run(clientset *kubernetes.Clientset) []models.ResourceCheck {
+	return c.suite.Run(clientset)
+}
+
+// builtinCheck is one check of a built-in suite
+type builtinCheck struct {
+	suite string
+	check testsuite.Check
+}
+
+func (c builtinCheck) Suite() string { return c.suite }
+
+func (c builtinCheck) Run(clientset *kubernetes.Clientset) []models.ResourceCheck {
+	return testsuite.RunChecks(clientset, []testsuite.Check{c.check})
+}
+
+// Suites returns the checks of the built-in suites with the given names, of all suites without names.
+// Every check of a suite is a Check of its own, so its results are available as soon as it finished.
+func Suites(names ...string) ([]Check, error) {
+	suites := testsuite.Suites
+	if len(names) > 0 {
+		suites = nil
+		for _, name := range names {
+			suite, found := testsuite.GetSuite(name)
+			if !found {
+				return nil, fmt.Errorf("unknown suite %q", name)
+			}
+			suites = append(suites, suite)
+		}
+	}
+	checks := []Check{}
+	for _, suite := range suites {
+		if len(suite.Checks) == 0 {
+			checks = append(checks, suiteCheck{suite})
+			continue
+		}
+		for _, check := range suite.Checks {
+			checks = append(checks, builtinCheck{suite: suite.Name, check: check})
+		}
+	}
+	return checks, nil
+}
+
+// Matching keeps the checks whose name, suite/label like in reports or only the label, matches one of the
+// globs. Checks that are not part of a built-in suite match by their suite.
+func Matching(checks []Check, patterns ...string) []Check {
+	matching := []Check{}
+	for _, check := range checks {
+		names := []string{check.Suite()}
+		if builtin, ok := check.(builtinCheck); ok {
+			names = []string{builtin.suite + "/" + builtin.check.Label, builtin.check.Label}
+		}
+		if slices.ContainsFunc(names, func(name string) bool { return matchesAny(patterns, name) }) {
+			matching = append(matching, check)
+		}
+	}
+	return matching
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Configure applies the check settings and API server connection options of the config. The settings are
+// shared by all runners, so Configure is called once before running checks.
+func Configure(cfg *config.Config) {
+	k8s.SetConnectionDefaults(cfg.Connection)
+	testsuite.Configure(cfg.Checks)
+}
+
+// Runner runs checks on a cluster and builds the report, with the finding history, suppressions, team
+// ownership and baseline applied like healthctl check does
+type Runner struct {
+	Client *k8s.K8sClient
+	Config *config.Config
+	Checks []Check
+	// Cluster names the cluster in the report, defaults to the cluster of the current kubeconfig context
+	Cluster string
+	// Suppressions are accepted findings that do not fail the run
+	Suppressions []findings.Suppression
+	// Baseline hides the findings that are part of it
+	Baseline *findings.Baseline
+	// HistoryFile keeps when findings were first seen between runs, without it every run starts over
+	HistoryFile string
+	// Team only reports the findings owned by the team
+	Team string
+	// Namespaces only reports the findings in the namespaces matching one of the globs, without cluster
+	// scoped findings
+	Namespaces []string
+	// Evidence collects the yaml of failing objects, their owners, nodes and events into the report
+	Evidence bool
+	// Cache reuses the results of checks of earlier runs that are younger than their time to live
+	Cache *Cache
+	// Journal records the results of every check as it finished, to resume an interrupted run or retry
+	// the failed checks
+	Journal *Journal
+	// Control pauses, resumes or stops the run between checks
+	Control *Control
+	// Shards splits the namespaces into shards that are checked in parallel, for clusters too large to
+	// list all pods at once. Only checks of namespaced objects run per shard, cluster wide and stateful
+	// checks run once. Up to 1 runs the checks over all namespaces at once.
+	Shards int
+	// Shard only checks one shard of the namespaces, like an agent of a sharded fleet: the shardable checks
+	// run on the namespaces of the shard, the other checks run in shard 0 only and are skipped in the others
+	Shard *report.Shard
+	// Reporters and Notifiers get the report after every run
+	Reporters []Reporter
+	Notifiers []Notifier
+	// Progress gets the results of every check as soon as it finished, its findings with suppressions,
+	// teams, hints and the baseline applied but without the finding history, which needs all findings
+	Progress func(checks []models.CheckResult, result []models.Finding)
+	// Logf receives problems that do not stop a run, like a history file that could not be saved
+	Logf func(format string, args ...interface{})
+}
+
+// NewRunner returns a runner of the checks, the config may be empty
+func NewRunner(client *k8s.K8sClient, cfg *config.Config, checks ...Check) *Runner {
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	return &Runner{Client: client, Config: cfg, Checks: checks}
+}
+
+func (r *Runner) logf(format string, args ...interface{}) {
+	if r.Logf != nil {
+		r.Logf(format, args...)
+	}
+}
+
+// Collect runs the checks and returns the result of every check and their findings as they are. Checks
+// with fresh results in the cache, or results the journal reuses, are not run again. A stopped run
+// returns the results of the checks that ran.
+func (r *Runner) Collect() ([]models.CheckResult, []models.Finding) {
+	now := time.Now()
+	cache := r.Cache.open(r.cluster()+"|"+r.Client.Identity(), now, r.logf)
+	journal := r.Journal.open(r.cluster(), now, r.logf)
+	runs := make([][]models.ResourceCheck, len(r.Checks))
+	cached := make([]bool, len(r.Checks))
+	for i, check := range r.Checks {
+		if runs[i], cached[i] = journal.reuse(check); !cached[i] {
+			runs[i], cached[i] = cache.get(check)
+		}
+	}
+	sharded := make([]bool, len(r.Checks))
+	if r.Shard != nil && r.Control.wait() {
+		if err := r.runShard(runs, cached); err != nil {
+			r.logf("checking shard %d of %d, running unsharded: %v", r.Shard.Index, r.Shard.Count, err)
+		} else {
+			for i := range sharded {
+				sharded[i] = !cached[i]
+			}
+		}
+	} else if r.Shards > 1 && r.Control.wait() {
+		if ran, err := r.runShards(runs, cached); err != nil {
+			r.logf("sharding the namespaces, running unsharded: %v", err)
+		} else {
+			sharded = ran
+		}
+	}
+
+	checks := []models.CheckResult{}
+	result := []models.Finding{}
+	progress := r.progress()
+	stopped := false
+	for i, check := range r.Checks {
+		if !cached[i] {
+			if !sharded[i] {
+				if stopped = !r.Control.wait(); stopped {
+					r.logf("run stopped, %d checks did not run", len(r.Checks)-i)
+					break
+				}
+				runs[i] = check.Run(r.Client.Client)
+			}
+			cache.put(check, runs[i])
+		}
+		journal.record(check, runs[i])
+		checkResults := models.CheckResults(check.Suite(), runs[i])
+		for j := range checkResults {
+			checkResults[j].Cached = cached[i]
+		}
+		checkFindings := models.FindingsFromChecks(check.Suite(), runs[i])
+		progress(checkResults, checkFindings)
+		checks = append(checks, checkResults...)
+		result = append(result, checkFindings...)
+	}
+	cache.save()
+	if !stopped {
+		journal.complete()
+	}
+	return checks, result
+}
+
+// cluster returns the name of the cluster in the report
+func (r *Runner) cluster() string {
+	if r.Cluster != "" {
+		return r.Cluster
+	}
+	return k8s.CurrentCluster()
+}
+
+// progress returns the function that passes the results of a check to Progress
+func (r *Runner) progress() func(checks []models.CheckResult, result []models.Finding) {
+	if r.Progress == nil {
+		return func([]models.CheckResult, []models.Finding) {}
+	}
+	namespaceLabels := r.Client.GetNamespaceLabels()
+	suppressions := findings.ForCluster(r.Suppressions, r.cluster())
+	return func(checks []models.CheckResult, result []models.Finding) {
+		result = slices.Clone(result)
+		result = findings.Suppress(result, suppressions, time.Now())
+		result = findings.AssignTeams(result, r.Config, namespaceLabels)
+		result = findings.Annotate(result, r.Config.KnowledgeBase)
+		result, _ = r.Baseline.Regressions(result)
+		if r.Team != "" {
+			result = findings.ForTeam(result, r.Team)
+		}
+		if len(r.Namespaces) > 0 {
+			result = findings.InNamespaces(result, r.Namespaces)
+		}
+		r.Progress(checks, result)
+	}
+}
+
+// Run runs the checks, builds the report and hands it to the reporters and notifiers. The report is
+// returned with the errors of the reporters and notifiers.
+func (r *Runner) Run() (report.Report, error) {
+	checks, result := r.Collect()
+
+	now := time.Now()
+	history := findings.History{}
+	if r.HistoryFile != "" {
+		var err error
+		history, err = findings.LoadHistory(r.HistoryFile)
+		if err != nil {
+			return report.Report{}, fmt.Errorf("reading finding history: %v", err)
+		}
+	}
+	hysteresis := r.Config.Hysteresis
+	if r.HistoryFile == "" {
+		// every run starts over, findings would never be reported in enough runs
+		hysteresis = config.Hysteresis{}
+	}
+	result = history.Track(checks, result, now, hysteresis)
+	result = findings.Suppress(result, findings.ForCluster(r.Suppressions, r.cluster()), now)
+	result = findings.AssignTeams(result, r.Config, r.Client.GetNamespaceLabels())
+	result = findings.Annotate(result, r.Config.KnowledgeBase)
+	if r.HistoryFile != "" {
+		if err := history.Save(r.HistoryFile); err != nil {
+			r.logf("saving finding history: %v", err)
+		}
+	}
+
+	result, hidden := r.Baseline.Regressions(result)
+	if r.Team != "" {
+		result = findings.ForTeam(result, r.Team)
+	}
+	namespaces := r.Client.GetClusterNamespaces()
+	if len(r.Namespaces) > 0 {
+		result = findings.InNamespaces(result, r.Namespaces)
+		namespaces = slices.DeleteFunc(namespaces, func(namespace string) bool { return !matchesAny(r.Namespaces, namespace) })
+	}
+
+	cluster := r.cluster()
+	labels := r.Client.DiscoverClusterLabels()
+	maps.Copy(labels, r.Config.InventoryLabels(cluster))
+	rep := report.Report{
+		APIVersion: report.APIVersion,
+		Kind:       report.Kind,
+		Cluster:    cluster,
+		Labels:     labels,
+		Generated:  now,
+		Checks:     checks,
+		Findings:   result,
+		Hidden:     hidden,
+		Scorecard:  findings.NewScorecard(result, namespaces),
+	}
+	if len(r.Config.Teams) > 0 {
+		sort.SliceStable(rep.Findings, func(i, j int) bool { return rep.Findings[i].Team < rep.Findings[j].Team })
+		rep.Teams = report.TeamSummaries(rep.Findings)
+	}
+	if r.Evidence {
+		rep.Evidence = r.collectEvidence(rep.Findings)
+	}
+	return rep, r.publish(rep)
+}
+
+// collectEvidence collects the evidence of every failing object once
+func (r *Runner) collectEvidence(result []models.Finding) []models.Evidence {
+	evidence := []models.Evidence{}
+	seen := make(map[models.ResourceRef]bool)
+	for _, finding := range result {
+		if !finding.Failing() || finding.Resource.Kind == "Check" || seen[finding.Resource] {
+			continue
+		}
+		seen[finding.Resource] = true
+		evidence = append(evidence, r.Client.CollectEvidence(finding.Resource)...)
+		evidence = append(evidence, profiles.Evidence(finding.Resource)...)
+	}
+	return evidence
+}
@@ -0,0 +1,134 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"healthctl/pkg/models"
+)
+
+// JournalMode tells which results of the last run in the journal a run reuses
+type JournalMode int
+
+const (
+	// JournalRecord only records the run
+	JournalRecord JournalMode = iota
+	// JournalResume reuses the checks an interrupted run completed and runs the others
+	JournalResume
+	// JournalRetryFailed reuses the checks of the last run that passed and runs the failed ones again
+	JournalRetryFailed
+)
+
+// Journal records the results of every check of a run as soon as it finished, so a run that was
+// interrupted can be resumed and the failed checks of a run can be retried without running all checks.
+// Only the last run of every cluster is kept.
+type Journal struct {
+	File string
+	Mode JournalMode
+}
+
+// journalRun is the journal of one run
+type journalRun struct {
+	Cluster   string                            `json:"cluster"`
+	Started   time.Time                         `json:"started"`
+	Completed bool                              `json:"completed"`
+	Checks    map[string][]models.ResourceCheck `json:"checks"`
+}
+
+// journalWriter records a run, a nil journalWriter records nothing
+type journalWriter struct {
+	journal *Journal
+	runs    map[string]*journalRun
+	run     *journalRun
+	// last is the previous run of the cluster the results are reused from
+	last *journalRun
+	logf func(format string, args ...interface{})
+}
+
+// open reads the journal and starts the journal of a new run of the cluster
+func (j *Journal) open(cluster string, now time.Time, logf func(format string, args ...interface{})) *journalWriter {
+	if j == nil {
+		return nil
+	}
+	w := &journalWriter{journal: j, runs: make(map[string]*journalRun), logf: logf}
+	data, err := os.ReadFile(j.File)
+	if err == nil {
+		err = json.Unmarshal(data, &w.runs)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		logf("reading the run journal: %v", err)
+		w.runs = make(map[string]*journalRun)
+	}
+	w.last = w.runs[cluster]
+	switch {
+	case j.Mode == JournalResume && (w.last == nil || w.last.Completed):
+		logf("no interrupted run of cluster %s to resume, running all checks", cluster)
+		w.last = nil
+	case j.Mode == JournalRetryFailed && w.last == nil:
+		logf("no earlier run of cluster %s to retry, running all checks", cluster)
+	}
+	w.run = &journalRun{Cluster: cluster, Started: now, Checks: make(map[string][]models.ResourceCheck)}
+	w.runs[cluster] = w.run
+	return w
+}
+
+// reuse returns the results of the check in the last run when the mode reuses them
+func (w *journalWriter) reuse(check Check) ([]models.ResourceCheck, bool) {
+	if w == nil || w.last == nil || w.journal.Mode == JournalRecord {
+		return nil, false
+	}
+	name, ok := checkName(check)
+	if !ok {
+		return nil, false
+	}
+	results, found := w.last.Checks[name]
+	if !found {
+		return nil, false
+	}
+	if w.journal.Mode == JournalRetryFailed {
+		for _, result := range results {
+			if outcome := result.Result(); outcome == models.ResultFail || outcome == models.ResultError {
+				return nil, false
+			}
+		}
+	}
+	return results, true
+}
+
+// record keeps the results of a check and writes the journal, so they survive an interrupted run
+func (w *journalWriter) record(check Check, results []models.ResourceCheck) {
+	if w == nil {
+		return
+	}
+	if name, ok := checkName(check); ok {
+		w.run.Checks[name] = results
+		w.save()
+	}
+}
+
+// complete marks the run as completed, it can not be resumed
+func (w *journalWriter) complete() {
+	if w == nil {
+		return
+	}
+	w.run.Completed = true
+	w.save()
+}
+
+func (w *journalWriter) save() {
+	err := os.MkdirAll(filepath.Dir(w.journal.File), 0755)
+	if err == nil {
+		var data []byte
+		if data, err = json.Marshal(w.runs); err == nil {
+			tmp := w.journal.File + ".tmp"
+			if err = os.WriteFile(tmp, data, 0644); err == nil {
+				err = os.Rename(tmp, w.journal.File)
+			}
+		}
+	}
+	if err != nil {
+		w.logf("saving the run journal: %v", err)
+	}
+}
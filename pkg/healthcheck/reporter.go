@@ -0,0 +1,61 @@
+package healthcheck
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/notify"
+	"healthctl/pkg/report"
+)
+
+// Reporter writes or stores the report of a run
+type Reporter interface {
+	Report(r report.Report) error
+}
+
+// ReporterFunc turns a function into a Reporter
+type ReporterFunc func(r report.Report) error
+
+func (f ReporterFunc) Report(r report.Report) error { return f(r) }
+
+// Writer returns a reporter writing the report to out in an output format of healthctl, like text or json
+func Writer(out io.Writer, format string) (Reporter, error) {
+	writer, err := report.GetWriter(format)
+	if err != nil {
+		return nil, err
+	}
+	return ReporterFunc(func(r report.Report) error { return writer.Write(out, r) }), nil
+}
+
+// Notifier sends the findings of a report to the people who act on them
+type Notifier interface {
+	Notify(r report.Report) error
+}
+
+// NotifierFunc turns a function into a Notifier
+type NotifierFunc func(r report.Report) error
+
+func (f NotifierFunc) Notify(r report.Report) error { return f(r) }
+
+// Slack returns a notifier sending every team its failing findings to its slack channel
+func Slack(slack *config.SlackConfig) Notifier {
+	return NotifierFunc(func(r report.Report) error { return notify.NotifyTeams(slack, r.Cluster, r.Findings) })
+}
+
+// publish hands the report to every reporter and notifier, one failing does not stop the others
+func (r *Runner) publish(rep report.Report) error {
+	errs := []error{}
+	for _, reporter := range r.Reporters {
+		if err := reporter.Report(rep); err != nil {
+			errs = append(errs, fmt.Errorf("reporting: %v", err))
+		}
+	}
+	for _, notifier := range r.Notifiers {
+		if err := notifier.Notify(rep); err != nil {
+			errs = append(errs, fmt.Errorf("notifying: %v", err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,155 @@
+package healthcheck
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"healthctl/pkg/models"
+)
+
+// maxParallelShards is how many shards are checked at the same time
+const maxParallelShards = 8
+
+// ShardableCheck is a Check that only lists namespaced objects and keeps no state, so it can run once per
+// shard of the namespaces and its results be merged. Checks that do not implement it run once.
+type ShardableCheck interface {
+	Check
+	Shardable() bool
+}
+
+func (c builtinCheck) Shardable() bool { return c.check.Sharded }
+
+// shardable returns true when the check can run per shard
+func shardable(check Check) bool {
+	s, ok := check.(ShardableCheck)
+	return ok && s.Shardable()
+}
+
+// runShards runs the shardable checks that are not skipped once per shard of the namespaces, up to
+// maxParallelShards shards in parallel, and merges the results of every check. A check holds the objects
+// of its shard only. It returns which checks ran, all others are left to run once over the cluster.
+func (r *Runner) runShards(runs [][]models.ResourceCheck, skip []bool) ([]bool, error) {
+	ran := make([]bool, len(r.Checks))
+	for i, check := range r.Checks {
+		ran[i] = !skip[i] && shardable(check)
+	}
+	if !slices.Contains(ran, true) {
+		return ran, nil
+	}
+	shards := make([][][]models.ResourceCheck, r.Shards)
+	errs := make([]error, r.Shards)
+	slots := make(chan struct{}, maxParallelShards)
+	var wait sync.WaitGroup
+	for index := range r.Shards {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			client, err := r.Client.Shard(index, r.Shards)
+			if err != nil {
+				errs[index] = err
+				return
+			}
+			shards[index] = make([][]models.ResourceCheck, len(r.Checks))
+			for i, check := range r.Checks {
+				if ran[i] {
+					shards[index][i] = check.Run(client.Client)
+				}
+			}
+		}()
+	}
+	wait.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range r.Checks {
+		if !ran[i] {
+			continue
+		}
+		parts := [][]models.ResourceCheck{}
+		for _, shard := range shards {
+			parts = append(parts, shard[i])
+		}
+		runs[i] = mergeShards(parts)
+	}
+	return ran, nil
+}
+
+// runShard runs the checks that are not skipped for the shard of the runner, the shardable checks on the
+// namespaces of the shard and the others in shard 0 only
+func (r *Runner) runShard(runs [][]models.ResourceCheck, skip []bool) error {
+	client, err := r.Client.Shard(r.Shard.Index, r.Shard.Count)
+	if err != nil {
+		return err
+	}
+	for i, check := range r.Checks {
+		switch {
+		case skip[i]:
+		case shardable(check):
+			runs[i] = check.Run(client.Client)
+		case r.Shard.Index == 0:
+			runs[i] = check.Run(r.Client.Client)
+		default:
+			runs[i] = []models.ResourceCheck{{Label: checkLabel(check), Details: "Checked by shard 0.", Status: true, Skipped: "other shard"}}
+		}
+	}
+	return nil
+}
+
+// checkLabel returns the label of the results of a check
+func checkLabel(check Check) string {
+	if builtin, ok := check.(builtinCheck); ok {
+		return builtin.check.Label
+	}
+	return check.Suite()
+}
+
+// mergeShards merges the results of the checks of a suite over the shards: a check passes when it
+// passed in every shard, its findings are the findings of all shards without the cluster scoped ones
+// every shard reports, and distinct details and errors are joined.
+func mergeShards(shards [][]models.ResourceCheck) []models.ResourceCheck {
+	merged := []models.ResourceCheck{}
+	index := make(map[string]int)
+	seen := make(map[string]bool)
+	for _, shard := range shards {
+		for _, check := range shard {
+			i, found := index[check.Label]
+			if !found {
+				index[check.Label] = len(merged)
+				merged = append(merged, models.ResourceCheck{Label: check.Label, Status: true, Skipped: check.Skipped})
+				i = len(merged) - 1
+			}
+			m := &merged[i]
+			m.Status = m.Status && check.Status
+			m.Details = joinDistinct(m.Details, check.Details)
+			m.Error = joinDistinct(m.Error, check.Error)
+			if check.Skipped == "" {
+				// skipped only when skipped in every shard
+				m.Skipped = ""
+			}
+			for _, finding := range check.Findings {
+				key := models.FindingID(check.Label, finding.Resource, finding.Reason)
+				if !seen[key] {
+					seen[key] = true
+					m.Findings = append(m.Findings, finding)
+				}
+			}
+		}
+	}
+	return merged
+}
+
+func joinDistinct(joined, value string) string {
+	if value == "" || slices.Contains(strings.Split(joined, "; "), value) {
+		return joined
+	}
+	if joined == "" {
+		return value
+	}
+	return joined + "; " + value
+}
@@ -0,0 +1,95 @@
+package k8s
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// AlertmanagerConfig is the part of the Alertmanager configuration healthctl validates
+type AlertmanagerConfig struct {
+	Global struct {
+		SMTPSmarthost string `json:"smtp_smarthost"`
+	} `json:"global"`
+	Route        AlertmanagerRoute        `json:"route"`
+	Receivers    []AlertmanagerReceiver   `json:"receivers"`
+	InhibitRules []map[string]interface{} `json:"inhibit_rules"`
+}
+
+// AlertmanagerRoute is a node of the routing tree
+type AlertmanagerRoute struct {
+	Receiver       string              `json:"receiver"`
+	Matchers       []string            `json:"matchers"`
+	Match          map[string]string   `json:"match"`
+	MatchRE        map[string]string   `json:"match_re"`
+	Continue       bool                `json:"continue"`
+	GroupWait      string              `json:"group_wait"`
+	GroupInterval  string              `json:"group_interval"`
+	RepeatInterval string              `json:"repeat_interval"`
+	Routes         []AlertmanagerRoute `json:"routes"`
+}
+
+// AlertmanagerReceiver is a receiver with the integrations healthctl tests for reachability
+type AlertmanagerReceiver struct {
+	Name         string `json:"name"`
+	EmailConfigs []struct {
+		To        string `json:"to"`
+		Smarthost string `json:"smarthost"`
+	} `json:"email_configs"`
+	WebhookConfigs []struct {
+		URL string `json:"url"`
+	} `json:"webhook_configs"`
+	SlackConfigs []struct {
+		APIURL string `json:"api_url"`
+	} `json:"slack_configs"`
+	PagerdutyConfigs []struct {
+		URL string `json:"url"`
+	} `json:"pagerduty_configs"`
+	OpsgenieConfigs []struct {
+		APIURL string `json:"api_url"`
+	} `json:"opsgenie_configs"`
+	MSTeamsConfigs []struct {
+		WebhookURL string `json:"webhook_url"`
+	} `json:"msteams_configs"`
+}
+
+// Integrations returns the number of notification integrations of the receiver, alerts routed to a
+// receiver without integrations are dropped
+func (r AlertmanagerReceiver) Integrations() int {
+	return len(r.EmailConfigs) + len(r.WebhookConfigs) + len(r.SlackConfigs) + len(r.PagerdutyConfigs) + len(r.OpsgenieConfigs) + len(r.MSTeamsConfigs)
+}
+
+// GetAlertmanagerConfig reads the configuration Alertmanager is running with through amtool in its pod.
+// Secrets like webhook URLs are masked as <secret> by Alertmanager.
+func (kc *K8sClient) GetAlertmanagerConfig(namespace, pod, container, url string) (*AlertmanagerConfig, error) {
+	command := fmt.Sprintf("amtool config show --alertmanager.url %s", ShellQuote(url))
+	stdout, stderr, err := kc.ExecuteRemoteCommand(namespace, pod, container, command)
+	if err != nil {
+		return nil, fmt.Errorf("reading alertmanager config: %v %s", err, stderr)
+	}
+	return ParseAlertmanagerConfig(strings.ReplaceAll(stdout, "\r", ""))
+}
+
+// ParseAlertmanagerConfig parses an Alertmanager configuration file
+func ParseAlertmanagerConfig(data string) (*AlertmanagerConfig, error) {
+	config := &AlertmanagerConfig{}
+	if err := yaml.Unmarshal([]byte(data), config); err != nil {
+		return nil, fmt.Errorf("parsing alertmanager config: %v", err)
+	}
+	return config, nil
+}
+
+// CanConnect opens a TCP connection to host:port from inside a pod with nc
+func (kc *K8sClient) CanConnect(namespace, pod, container, hostPort string) error {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return fmt.Errorf("%s has no port", hostPort)
+	}
+	stdout, stderr, err := kc.ExecuteRemoteCommand(namespace, pod, container, fmt.Sprintf("nc -z -w 5 %s %s", ShellQuote(host), ShellQuote(port)))
+	if err != nil {
+		return fmt.Errorf("%s is not reachable: %s", hostPort, strings.TrimSpace(stdout+stderr))
+	}
+	return nil
+}
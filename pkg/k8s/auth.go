@@ -0,0 +1,105 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// AuthResult is the outcome of authenticating against the cluster of one kubeconfig context
+type AuthResult struct {
+	Context   string
+	Cluster   string
+	AuthType  string
+	User      string
+	Refreshed bool
+	Error     error
+}
+
+// authType describes how a kubeconfig user authenticates
+func authType(authInfo *clientcmdapi.AuthInfo) string {
+	switch {
+	case authInfo == nil:
+		return "none"
+	case authInfo.Exec != nil:
+		return fmt.Sprintf("exec (%s)", authInfo.Exec.Command)
+	case authInfo.AuthProvider != nil:
+		return authInfo.AuthProvider.Name
+	case authInfo.Token != "" || authInfo.TokenFile != "":
+		return "token"
+	case authInfo.ClientCertificate != "" || len(authInfo.ClientCertificateData) > 0:
+		return "client certificate"
+	case authInfo.Username != "":
+		return "basic"
+	}
+	return "none"
+}
+
+// CheckAuth authenticates against every context of the kubeconfig and reports who the cluster thinks we are.
+// Token refresh of OIDC auth providers is detected by comparing the id-token before and after the request.
+func CheckAuth() ([]AuthResult, error) {
+	config, err := GetClustersFromKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	contextNames := []string{}
+	for name := range config.Contexts {
+		contextNames = append(contextNames, name)
+	}
+	sort.Strings(contextNames)
+
+	results := []AuthResult{}
+	for _, name := range contextNames {
+		kubeContext := config.Contexts[name]
+		authInfo := config.AuthInfos[kubeContext.AuthInfo]
+		result := AuthResult{Context: name, Cluster: kubeContext.Cluster, AuthType: authType(authInfo)}
+		result.User, result.Error = whoAmI(name)
+		if result.Error != nil {
+			result.Error = fmt.Errorf("%s authentication failed: %v", result.AuthType, result.Error)
+		}
+
+		if authInfo != nil && authInfo.AuthProvider != nil && result.Error == nil {
+			// the oidc provider writes a refreshed id-token back to the kubeconfig
+			if updated, err := GetClustersFromKubeConfig(); err == nil {
+				if updatedAuth, found := updated.AuthInfos[kubeContext.AuthInfo]; found && updatedAuth.AuthProvider != nil {
+					result.Refreshed = updatedAuth.AuthProvider.Config["id-token"] != authInfo.AuthProvider.Config["id-token"]
+				}
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// whoAmI returns the user name the cluster authenticated us as.
+// Clusters without the SelfSubjectReview API fall back to a request that requires authentication.
+func whoAmI(contextName string) (string, error) {
+	config, err := RestConfig(contextName)
+	if err != nil {
+		return "", err
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return "", err
+	}
+
+	review, err := client.AuthenticationV1().SelfSubjectReviews().Create(context.Background(), &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	if err == nil {
+		return review.Status.UserInfo.Username, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return "", err
+	}
+
+	if _, err := client.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{Limit: 1}); err != nil && !apierrors.IsForbidden(err) {
+		return "", err
+	}
+	return "unknown (SelfSubjectReview not supported)", nil
+}
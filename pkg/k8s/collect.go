@@ -0,0 +1,131 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// resourceForKind finds the preferred API resource serving a kind
+func (kc *K8sClient) resourceForKind(kind string) (schema.GroupVersionResource, bool, error) {
+	resources, err := GetAPIResources(kc.Client)
+	if err != nil {
+		return schema.GroupVersionResource{}, false, err
+	}
+	resource, found := resources.ForKind(kind)
+	if !found {
+		return schema.GroupVersionResource{}, false, fmt.Errorf("no API resource found for kind %s", kind)
+	}
+	return resource.GroupVersionResource(), resource.Namespaced, nil
+}
+
+// GetObject returns the object a finding refers to
+func (kc *K8sClient) GetObject(ref models.ResourceRef) (*unstructured.Unstructured, error) {
+	gvr, namespaced, err := kc.resourceForKind(ref.Kind)
+	if err != nil {
+		return nil, err
+	}
+	if namespaced {
+		return kc.DynamicClient.Resource(gvr).Namespace(ref.Namespace).Get(context.Background(), ref.Name, metav1.GetOptions{})
+	}
+	return kc.DynamicClient.Resource(gvr).Get(context.Background(), ref.Name, metav1.GetOptions{})
+}
+
+// GetManifest returns the yaml of the object a finding refers to, without managed fields
+func (kc *K8sClient) GetManifest(ref models.ResourceRef) ([]byte, error) {
+	object, err := kc.GetObject(ref)
+	if err != nil {
+		return nil, err
+	}
+	unstructured.RemoveNestedField(object.Object, "metadata", "managedFields")
+	return yaml.Marshal(object.Object)
+}
+
+// EventTime returns the most recent time an event was observed
+func EventTime(event v1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// GetRecentEvents returns the events of all namespaces observed within the given duration, oldest first
+func (kc *K8sClient) GetRecentEvents(since time.Duration) ([]v1.Event, error) {
+	events, err := kc.Client.CoreV1().Events("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-since)
+	recent := []v1.Event{}
+	for _, event := range events.Items {
+		if EventTime(event).After(cutoff) {
+			recent = append(recent, event)
+		}
+	}
+	sort.Slice(recent, func(i, j int) bool { return EventTime(recent[i]).Before(EventTime(recent[j])) })
+	return recent, nil
+}
+
+// FormatEvents renders events one per line like kubectl get events
+func FormatEvents(events []v1.Event) string {
+	var out strings.Builder
+	for _, event := range events {
+		fmt.Fprintf(&out, "%s  %-8s %-20s %s/%s/%s: %s\n", EventTime(event).Format(time.RFC3339), event.Type, event.Reason,
+			event.InvolvedObject.Kind, event.InvolvedObject.Namespace, event.InvolvedObject.Name, strings.TrimSpace(event.Message))
+	}
+	return out.String()
+}
+
+// StreamPodLogs copies the log lines a container writes from since on to out, with their timestamps, until
+// the context ends or the container stops
+func (kc *K8sClient) StreamPodLogs(ctx context.Context, namespace, podName, container string, since time.Time, out io.Writer) error {
+	sinceTime := metav1.NewTime(since)
+	options := &v1.PodLogOptions{Container: container, Follow: true, Timestamps: true, SinceTime: &sinceTime}
+	stream, err := kc.Client.CoreV1().Pods(namespace).GetLogs(podName, options).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	_, err = io.Copy(out, stream)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// GetPodLogs returns the last lines of every container of a pod keyed by container name.
+// Containers that restarted also get the logs of the previous instance under "<container>.previous".
+func (kc *K8sClient) GetPodLogs(namespace, podName string, tailLines int64) (map[string]string, error) {
+	pod, err := kc.Client.CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	logs := make(map[string]string)
+	for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		options := &v1.PodLogOptions{Container: status.Name, TailLines: &tailLines}
+		if data, err := kc.Client.CoreV1().Pods(namespace).GetLogs(podName, options).DoRaw(context.Background()); err == nil {
+			logs[status.Name] = string(data)
+		}
+		if status.RestartCount > 0 {
+			options.Previous = true
+			if data, err := kc.Client.CoreV1().Pods(namespace).GetLogs(podName, options).DoRaw(context.Background()); err == nil {
+				logs[status.Name+".previous"] = string(data)
+			}
+		}
+	}
+	return logs, nil
+}
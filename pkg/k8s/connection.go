@@ -0,0 +1,117 @@
+package k8s
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"k8s.io/client-go/rest"
+)
+
+// ConnectionOptions configures how the API server is reached from hosts behind a proxy or with a private CA
+type ConnectionOptions struct {
+	Proxy                 string `json:"proxy,omitempty"`
+	CAFile                string `json:"caFile,omitempty"`
+	InsecureSkipTLSVerify bool   `json:"insecureSkipTLSVerify,omitempty"`
+	// Exec configures the sessions of the checks that exec into pods
+	Exec ExecOptions `json:"exec,omitempty"`
+	// As is the user the requests impersonate, e.g. system:serviceaccount:payments:operator, so the checks
+	// see the cluster with the permissions of a tenant
+	As string `json:"as,omitempty"`
+	// AsGroups are the groups the requests impersonate, they need As
+	AsGroups []string `json:"asGroups,omitempty"`
+	// ReadOnly rejects every request that changes the cluster, exec into pods and kubeconfig writes, so
+	// healthctl can be handed to auditors
+	ReadOnly bool `json:"readOnly,omitempty"`
+}
+
+// groupsFlag collects the values of a repeated flag
+type groupsFlag []string
+
+func (g *groupsFlag) String() string { return strings.Join(*g, ",") }
+
+func (g *groupsFlag) Set(value string) error {
+	*g = append(*g, value)
+	return nil
+}
+
+var connection ConnectionOptions
+
+// SetConnectionDefaults fills the connection options that were not given on the command line, e.g. from the config file
+func SetConnectionDefaults(defaults ConnectionOptions) {
+	if connection.Proxy == "" {
+		connection.Proxy = defaults.Proxy
+	}
+	if connection.CAFile == "" {
+		connection.CAFile = defaults.CAFile
+	}
+	if !connection.InsecureSkipTLSVerify {
+		connection.InsecureSkipTLSVerify = defaults.InsecureSkipTLSVerify
+	}
+	if connection.Exec.Protocol == "" {
+		connection.Exec.Protocol = defaults.Exec.Protocol
+	}
+	if connection.Exec.MaxConcurrent == 0 {
+		connection.Exec.MaxConcurrent = defaults.Exec.MaxConcurrent
+	}
+	if connection.Exec.PingPeriod == "" {
+		connection.Exec.PingPeriod = defaults.Exec.PingPeriod
+	}
+	if connection.Exec.Timeout == "" {
+		connection.Exec.Timeout = defaults.Exec.Timeout
+	}
+	if !connection.ReadOnly {
+		connection.ReadOnly = defaults.ReadOnly
+	}
+	if connection.As == "" && len(connection.AsGroups) == 0 {
+		connection.As, connection.AsGroups = defaults.As, defaults.AsGroups
+	}
+}
+
+// Impersonation returns the user and groups the requests impersonate, an empty user when they do not
+func Impersonation() (string, []string) {
+	return connection.As, connection.AsGroups
+}
+
+// InsecureConnection returns true when API server certificates are not verified
+func InsecureConnection() bool {
+	return connection.InsecureSkipTLSVerify
+}
+
+// applyConnectionOptions sets the proxy, TLS, impersonation, gentle and read-only mode options on a client configuration
+func applyConnectionOptions(config *rest.Config) error {
+	if connection.Proxy != "" {
+		proxyURL, err := url.Parse(connection.Proxy)
+		if err != nil {
+			return fmt.Errorf("invalid proxy url %q: %v", connection.Proxy, err)
+		}
+		config.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if connection.InsecureSkipTLSVerify && connection.CAFile != "" {
+		return fmt.Errorf("certificate-authority and insecure-skip-tls-verify can not be used together")
+	}
+	if connection.CAFile != "" {
+		config.TLSClientConfig.CAFile = connection.CAFile
+		config.TLSClientConfig.CAData = nil
+	}
+	if connection.InsecureSkipTLSVerify {
+		config.TLSClientConfig.Insecure = true
+		config.TLSClientConfig.CAFile = ""
+		config.TLSClientConfig.CAData = nil
+	}
+	if connection.As == "" && len(connection.AsGroups) > 0 {
+		return fmt.Errorf("as-group needs as, the user to impersonate")
+	}
+	if connection.As != "" {
+		config.Impersonate = rest.ImpersonationConfig{UserName: connection.As, Groups: connection.AsGroups}
+	}
+	if Gentle() {
+		config.Wrap(newGentleTransport)
+	}
+	if connection.ReadOnly {
+		config.Wrap(newReadOnlyTransport)
+	}
+	return nil
+}
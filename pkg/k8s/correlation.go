@@ -0,0 +1,276 @@
+package k8s
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PodIssue describes a pod that healthctl considers unhealthy
+type PodIssue struct {
+	Namespace string
+	PodName   string
+	Node      string
+	Reason    string
+}
+
+// NodeIssue describes a node that is not ready or reports a pressure condition
+type NodeIssue struct {
+	Name       string
+	Conditions []string
+}
+
+// Incident groups a firing alert with the healthctl findings that relate to it
+type Incident struct {
+	Alert Alert
+	Pods  []PodIssue
+	Nodes []NodeIssue
+}
+
+// GetPodIssues returns all pods that are failing, pending or have containers that are not ready
+func (kc *K8sClient) GetPodIssues() []PodIssue {
+	issues := []PodIssue{}
+	pods, err := kc.Client.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return issues
+	}
+	for _, pod := range pods.Items {
+		if reason := PodIssueReason(pod); reason != "" {
+			issues = append(issues, PodIssue{
+				Namespace: pod.Namespace,
+				PodName:   pod.Name,
+				Node:      pod.Spec.NodeName,
+				Reason:    reason,
+			})
+		}
+	}
+	return issues
+}
+
+// PodIssueReason returns the most specific reason a pod is unhealthy, or an empty string
+func PodIssueReason(pod v1.Pod) string {
+	return DiagnosePod(pod).Reason
+}
+
+// defaultContainerAnnotation names the main container of a pod with sidecars
+const defaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// PodProblem is the reason a pod is unhealthy and the container that causes it
+type PodProblem struct {
+	Reason    string
+	Container string
+	// Kind is ContainerInit for failing init containers, ContainerSidecar for sidecars and ContainerRegular
+	// for the main container, empty when the phase of the pod is the problem
+	Kind string
+}
+
+// DiagnosePod returns why a pod is unhealthy, an empty problem for healthy pods. Failing init containers
+// come first as they keep the pod from starting, with reasons like kubectl shows them, e.g.
+// Init:CrashLoopBackOff. Sidecars are native sidecars, init containers that keep running, and every
+// container besides the main container, which is the default container of the pod or its first container.
+func DiagnosePod(pod v1.Pod) PodProblem {
+	if pod.Status.Phase == v1.PodSucceeded {
+		return PodProblem{}
+	}
+	sidecars := podSidecars(pod)
+	for _, cs := range pod.Status.InitContainerStatuses {
+		if sidecars[cs.Name] {
+			if reason := containerIssue(cs); reason != "" {
+				return PodProblem{Reason: reason, Container: cs.Name, Kind: ContainerSidecar}
+			}
+			continue
+		}
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason != "" && cs.State.Waiting.Reason != "PodInitializing" &&
+			cs.State.Waiting.Reason != "ContainerCreating" {
+			return PodProblem{Reason: "Init:" + cs.State.Waiting.Reason, Container: cs.Name, Kind: ContainerInit}
+		}
+		if cs.State.Terminated != nil && cs.State.Terminated.ExitCode != 0 {
+			reason := cs.State.Terminated.Reason
+			if reason == "" {
+				reason = fmt.Sprintf("ExitCode:%d", cs.State.Terminated.ExitCode)
+			}
+			return PodProblem{Reason: "Init:" + reason, Container: cs.Name, Kind: ContainerInit}
+		}
+	}
+	// the main container first, a failing sidecar is reported only when the main container is fine
+	statuses := slices.Clone(pod.Status.ContainerStatuses)
+	slices.SortStableFunc(statuses, func(a, b v1.ContainerStatus) int {
+		return cmp.Compare(sidecarRank(sidecars[a.Name]), sidecarRank(sidecars[b.Name]))
+	})
+	for _, cs := range statuses {
+		if reason := containerIssue(cs); reason != "" {
+			return PodProblem{Reason: reason, Container: cs.Name, Kind: containerKind(sidecars[cs.Name])}
+		}
+	}
+	if pod.Status.Phase != v1.PodRunning {
+		return PodProblem{Reason: string(pod.Status.Phase)}
+	}
+	for _, cs := range statuses {
+		if !cs.Ready {
+			return PodProblem{Reason: "NotReady", Container: cs.Name, Kind: containerKind(sidecars[cs.Name])}
+		}
+	}
+	for _, cs := range pod.Status.InitContainerStatuses {
+		if sidecars[cs.Name] && !cs.Ready {
+			return PodProblem{Reason: "NotReady", Container: cs.Name, Kind: ContainerSidecar}
+		}
+	}
+	return PodProblem{}
+}
+
+// containerIssue returns the reason a container is waiting or terminated other than while it starts or
+// after it completed
+func containerIssue(cs v1.ContainerStatus) string {
+	if cs.State.Waiting != nil && cs.State.Waiting.Reason != "" && cs.State.Waiting.Reason != "ContainerCreating" {
+		return cs.State.Waiting.Reason
+	}
+	if cs.State.Terminated != nil && cs.State.Terminated.Reason != "" && cs.State.Terminated.Reason != "Completed" {
+		return cs.State.Terminated.Reason
+	}
+	return ""
+}
+
+// podSidecars returns the names of the sidecars of a pod
+func podSidecars(pod v1.Pod) map[string]bool {
+	sidecars := make(map[string]bool)
+	for _, container := range pod.Spec.InitContainers {
+		if container.RestartPolicy != nil && *container.RestartPolicy == v1.ContainerRestartPolicyAlways {
+			sidecars[container.Name] = true
+		}
+	}
+	if len(pod.Spec.Containers) < 2 {
+		return sidecars
+	}
+	main := pod.Annotations[defaultContainerAnnotation]
+	if main == "" {
+		main = pod.Spec.Containers[0].Name
+	}
+	for _, container := range pod.Spec.Containers {
+		if container.Name != main {
+			sidecars[container.Name] = true
+		}
+	}
+	return sidecars
+}
+
+func sidecarRank(sidecar bool) int {
+	if sidecar {
+		return 1
+	}
+	return 0
+}
+
+func containerKind(sidecar bool) string {
+	if sidecar {
+		return ContainerSidecar
+	}
+	return ContainerRegular
+}
+
+// GetNodeIssues returns all nodes that are not ready or flagged with a pressure condition
+func (kc *K8sClient) GetNodeIssues() []NodeIssue {
+	issues := []NodeIssue{}
+	nodes, err := kc.Client.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return issues
+	}
+	for _, node := range nodes.Items {
+		if conditions := NodeIssueConditions(node); len(conditions) > 0 {
+			issues = append(issues, NodeIssue{Name: node.Name, Conditions: conditions})
+		}
+	}
+	return issues
+}
+
+// pressureConditions are the node conditions maintained by the kubelet besides Ready.
+// Conditions added by node agents like node-problem-detector are checked separately.
+var pressureConditions = map[v1.NodeConditionType]bool{
+	v1.NodeMemoryPressure:     true,
+	v1.NodeDiskPressure:       true,
+	v1.NodePIDPressure:        true,
+	v1.NodeNetworkUnavailable: true,
+}
+
+// NodeIssueConditions returns NotReady and every pressure condition currently set on the node
+func NodeIssueConditions(node v1.Node) []string {
+	conditions := []string{}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady && condition.Status != v1.ConditionTrue {
+			conditions = append(conditions, "NotReady")
+		}
+		if pressureConditions[condition.Type] && condition.Status == v1.ConditionTrue {
+			conditions = append(conditions, string(condition.Type))
+		}
+	}
+	return conditions
+}
+
+// IsKubeletCondition returns true for Ready and the pressure conditions maintained by the kubelet
+func IsKubeletCondition(conditionType v1.NodeConditionType) bool {
+	return conditionType == v1.NodeReady || pressureConditions[conditionType]
+}
+
+// CorrelateAlerts matches every alert against pod and node issues on the same pod, namespace or node.
+// Alerts with no matching issue are still returned as an incident so nothing firing is hidden.
+func CorrelateAlerts(alerts []Alert, pods []PodIssue, nodes []NodeIssue) []Incident {
+	nodeIssues := make(map[string]NodeIssue)
+	for _, node := range nodes {
+		nodeIssues[node.Name] = node
+	}
+
+	incidents := []Incident{}
+	for _, alert := range alerts {
+		incident := Incident{Alert: alert}
+		matchedNodes := make(map[string]bool)
+
+		for _, pod := range pods {
+			if !alertMatchesPod(alert, pod) {
+				continue
+			}
+			incident.Pods = append(incident.Pods, pod)
+			if node, found := nodeIssues[pod.Node]; found && !matchedNodes[node.Name] {
+				incident.Nodes = append(incident.Nodes, node)
+				matchedNodes[node.Name] = true
+			}
+		}
+		if node, found := nodeIssues[alert.Node]; found && !matchedNodes[node.Name] {
+			incident.Nodes = append(incident.Nodes, node)
+			matchedNodes[node.Name] = true
+		}
+		incidents = append(incidents, incident)
+	}
+	return incidents
+}
+
+func alertMatchesPod(alert Alert, pod PodIssue) bool {
+	if alert.PodName != "" {
+		return alert.PodName == pod.PodName && (alert.Namespace == "" || alert.Namespace == pod.Namespace)
+	}
+	if alert.Namespace != "" {
+		return alert.Namespace == pod.Namespace
+	}
+	return alert.Node != "" && alert.Node == pod.Node
+}
+
+// Summary returns a single line description of the incident
+func (i Incident) Summary() string {
+	summary := fmt.Sprintf("alert %s", i.Alert.AlertName)
+	if len(i.Pods) == 0 && len(i.Nodes) == 0 {
+		return summary + " has no matching healthctl findings"
+	}
+	for _, pod := range i.Pods {
+		summary += fmt.Sprintf(" corresponds to %s pod %s/%s", pod.Reason, pod.Namespace, pod.PodName)
+		if pod.Node != "" {
+			summary += fmt.Sprintf(" on node %s", pod.Node)
+		}
+		summary += ";"
+	}
+	for _, node := range i.Nodes {
+		summary += fmt.Sprintf(" node %s is flagged %v;", node.Name, node.Conditions)
+	}
+	return summary
+}
@@ -0,0 +1,149 @@
+package k8s
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	hoursPerMonth = 730
+	bytesPerGB    = 1 << 30
+	// defaultOverprovisioned is the usage in percent of the requests below which a workload is overprovisioned
+	defaultOverprovisioned = 20
+)
+
+// CostModel prices the requested resources, requests are what a workload reserves and pays for
+type CostModel struct {
+	CPUHour      float64 `json:"cpuHour,omitempty"`
+	MemoryGBHour float64 `json:"memoryGBHour,omitempty"`
+	Currency     string  `json:"currency,omitempty"`
+	// OverprovisionedPercent is the usage of the requests below which a workload is reported, defaults to 20
+	OverprovisionedPercent float64 `json:"overprovisionedPercent,omitempty"`
+}
+
+// Configured returns true when at least one rate is set
+func (m CostModel) Configured() bool {
+	return m.CPUHour > 0 || m.MemoryGBHour > 0
+}
+
+// CostReport is the estimated monthly cost of the requests per namespace and the overprovisioned workloads
+type CostReport struct {
+	Currency        string                    `json:"currency"`
+	Namespaces      []NamespaceCost           `json:"namespaces"`
+	Overprovisioned []OverprovisionedWorkload `json:"overprovisioned"`
+}
+
+// NamespaceCost is the monthly cost of the requests of a namespace
+type NamespaceCost struct {
+	Namespace   string  `json:"namespace"`
+	CPUCores    float64 `json:"cpuCores"`
+	MemoryGB    float64 `json:"memoryGB"`
+	MonthlyCost float64 `json:"monthlyCost"`
+}
+
+// OverprovisionedWorkload uses only a small part of what it requests
+type OverprovisionedWorkload struct {
+	Namespace     string  `json:"namespace"`
+	Workload      string  `json:"workload"`
+	CPUPercent    float64 `json:"cpuPercent"`
+	MemoryPercent float64 `json:"memoryPercent"`
+	MonthlyCost   float64 `json:"monthlyCost"`
+	MonthlyWaste  float64 `json:"monthlyWaste"`
+}
+
+// resourceTotals sums cores and GB of requests and usage
+type resourceTotals struct {
+	cpuRequest, memoryRequest float64
+	cpuUsed, memoryUsed       float64
+	withMetrics               bool
+}
+
+// monthly returns the cost of cpu cores and GB of memory for a month
+func (m CostModel) monthly(cpu, memory float64) float64 {
+	return (cpu*m.CPUHour + memory*m.MemoryGBHour) * hoursPerMonth
+}
+
+// EstimateCost prices the report with the cost model, overprovisioned workloads need metrics-server
+func (r *ResourceUsageReport) EstimateCost(m CostModel) {
+	namespaces := make(map[string]*resourceTotals)
+	workloads := make(map[string]*resourceTotals)
+	for _, pod := range r.PodsUsage {
+		if namespaces[pod.Namespace] == nil {
+			namespaces[pod.Namespace] = &resourceTotals{}
+		}
+		key := pod.Namespace + "/" + pod.Workload
+		if workloads[key] == nil {
+			workloads[key] = &resourceTotals{withMetrics: true}
+		}
+		for _, container := range pod.ContainerUsages {
+			cpu := float64(container.Requests.Cpu().MilliValue()) / 1000
+			memory := float64(container.Requests.Memory().Value()) / bytesPerGB
+			for _, totals := range []*resourceTotals{namespaces[pod.Namespace], workloads[key]} {
+				totals.cpuRequest += cpu
+				totals.memoryRequest += memory
+				totals.cpuUsed += float64(container.Usage.Cpu().MilliValue()) / 1000
+				totals.memoryUsed += float64(container.Usage.Memory().Value()) / bytesPerGB
+			}
+			if !container.HasMetrics {
+				workloads[key].withMetrics = false
+			}
+		}
+	}
+
+	threshold := m.OverprovisionedPercent
+	if threshold <= 0 {
+		threshold = defaultOverprovisioned
+	}
+	cost := &CostReport{Currency: m.Currency}
+	for namespace, totals := range namespaces {
+		cost.Namespaces = append(cost.Namespaces, NamespaceCost{
+			Namespace:   namespace,
+			CPUCores:    totals.cpuRequest,
+			MemoryGB:    totals.memoryRequest,
+			MonthlyCost: m.monthly(totals.cpuRequest, totals.memoryRequest),
+		})
+	}
+	sort.Slice(cost.Namespaces, func(i, j int) bool { return cost.Namespaces[i].MonthlyCost > cost.Namespaces[j].MonthlyCost })
+
+	for key, totals := range workloads {
+		if !totals.withMetrics || totals.cpuRequest == 0 || totals.memoryRequest == 0 {
+			continue
+		}
+		cpuPercent := totals.cpuUsed / totals.cpuRequest * 100
+		memoryPercent := totals.memoryUsed / totals.memoryRequest * 100
+		if cpuPercent >= threshold || memoryPercent >= threshold {
+			continue
+		}
+		namespace, workload, _ := strings.Cut(key, "/")
+		cost.Overprovisioned = append(cost.Overprovisioned, OverprovisionedWorkload{
+			Namespace:     namespace,
+			Workload:      workload,
+			CPUPercent:    cpuPercent,
+			MemoryPercent: memoryPercent,
+			MonthlyCost:   m.monthly(totals.cpuRequest, totals.memoryRequest),
+			MonthlyWaste:  m.monthly(totals.cpuRequest-totals.cpuUsed, totals.memoryRequest-totals.memoryUsed),
+		})
+	}
+	sort.Slice(cost.Overprovisioned, func(i, j int) bool {
+		return cost.Overprovisioned[i].MonthlyWaste > cost.Overprovisioned[j].MonthlyWaste
+	})
+	r.Cost = cost
+}
+
+// podWorkloadName returns kind/name of the controller of a pod, deployments are derived from the
+// pod-template-hash of their replica sets
+func podWorkloadName(pod v1.Pod) string {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller == nil || !*owner.Controller {
+			continue
+		}
+		if hash := pod.Labels["pod-template-hash"]; owner.Kind == "ReplicaSet" && hash != "" {
+			return "Deployment/" + strings.TrimSuffix(owner.Name, "-"+hash)
+		}
+		return fmt.Sprintf("%s/%s", owner.Kind, owner.Name)
+	}
+	return "Pod/" + pod.Name
+}
@@ -0,0 +1,235 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DebugLevels are the trace levels of the eTrace endpoint of the applications
+var DebugLevels = []string{"DEBUG_1", "DEBUG_2", "DEBUG_3"}
+
+const (
+	// DebugPortAnnotation on a pod sets the port of its eTrace endpoint
+	DebugPortAnnotation = "healthctl.io/debug-port"
+	// defaultDebugPort is the port of the eTrace endpoint of pods that do not declare theirs
+	defaultDebugPort = 9090
+	// defaultDebugPortName is the name of the container port of the eTrace endpoint
+	defaultDebugPortName = "debug"
+)
+
+// DebugPorts configures how the port of the eTrace endpoint of a pod is found. The pod annotation
+// healthctl.io/debug-port wins, then the port of the service of the pod, then a container port named
+// PortName and finally Port.
+type DebugPorts struct {
+	// Port is the port of pods that declare none, defaults to 9090
+	Port int `json:"port,omitempty"`
+	// PortName is the name of the container port of the endpoint, defaults to debug
+	PortName string `json:"portName,omitempty"`
+	// Services are the ports by service, the app.kubernetes.io/name or app label of the pods or the name of
+	// their workload
+	Services map[string]int `json:"services,omitempty"`
+}
+
+var debugPorts DebugPorts
+
+// SetDebugPorts sets how the ports of the eTrace endpoints are found, e.g. from the config file
+func SetDebugPorts(ports DebugPorts) {
+	debugPorts = ports
+}
+
+// DebugPort returns the port of the eTrace endpoint of a container of a pod and where it was found
+func DebugPort(pod v1.Pod, container string) (int, string) {
+	if value, found := pod.Annotations[DebugPortAnnotation]; found {
+		if port, err := strconv.Atoi(value); err == nil && port > 0 {
+			return port, "annotation " + DebugPortAnnotation
+		}
+	}
+	_, workload, _ := strings.Cut(podWorkloadName(pod), "/")
+	for _, service := range []string{pod.Labels["app.kubernetes.io/name"], pod.Labels["app"], workload} {
+		if port, found := debugPorts.Services[service]; found && service != "" {
+			return port, "service " + service
+		}
+	}
+	name := debugPorts.PortName
+	if name == "" {
+		name = defaultDebugPortName
+	}
+	for _, c := range pod.Spec.Containers {
+		if container != "" && c.Name != container {
+			continue
+		}
+		for _, port := range c.Ports {
+			if port.Name == name {
+				return int(port.ContainerPort), "container port " + name
+			}
+		}
+	}
+	if debugPorts.Port > 0 {
+		return debugPorts.Port, "default"
+	}
+	return defaultDebugPort, "default"
+}
+
+// DebugTarget selects the pods of a namespace whose debug level is set or reset
+type DebugTarget struct {
+	Namespace string
+	// Selector is a label selector of the pods, e.g. app=api
+	Selector string
+	// Pods is a glob of the pod names, e.g. api-*, empty matches every pod
+	Pods string
+	// Regex is a regular expression the whole pod name must match, instead of Pods
+	Regex string
+	// Container is the container of the endpoint, empty is the default container of the pod
+	Container string
+}
+
+// DebugLevelResult is the result of setting or resetting the level of one pod
+type DebugLevelResult struct {
+	Pod       string
+	Container string
+	// Port is the port of the eTrace endpoint the request was sent to
+	Port   int
+	Output string
+	Err    error
+}
+
+// DebugTargetPods returns the running pods of the target by name
+func (kc *K8sClient) DebugTargetPods(target DebugTarget) ([]v1.Pod, error) {
+	if target.Pods != "" && target.Regex != "" {
+		return nil, fmt.Errorf("a pod glob and a regular expression can not be used together")
+	}
+	match := func(name string) bool { return true }
+	if target.Pods != "" {
+		if _, err := path.Match(target.Pods, ""); err != nil {
+			return nil, fmt.Errorf("invalid pod glob %q: %v", target.Pods, err)
+		}
+		match = func(name string) bool {
+			matched, _ := path.Match(target.Pods, name)
+			return matched
+		}
+	}
+	if target.Regex != "" {
+		re, err := regexp.Compile("^(?:" + target.Regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid pod regular expression %q: %v", target.Regex, err)
+		}
+		match = re.MatchString
+	}
+	pods, err := kc.Client.CoreV1().Pods(target.Namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: target.Selector,
+		FieldSelector: "status.phase=Running",
+	})
+	if err != nil {
+		return nil, err
+	}
+	matched := []v1.Pod{}
+	for _, pod := range pods.Items {
+		if match(pod.Name) {
+			matched = append(matched, pod)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Name < matched[j].Name })
+	return matched, nil
+}
+
+// SetDebugLevels sets the debug level of every pod of the target concurrently and returns the result of
+// every pod, an error is only returned when the pods can not be selected
+func (kc *K8sClient) SetDebugLevels(target DebugTarget, debugLevel string) ([]DebugLevelResult, error) {
+	pods, err := kc.DebugTargetPods(target)
+	if err != nil {
+		return nil, err
+	}
+	return kc.SetPodDebugLevels(pods, target.Container, debugLevel), nil
+}
+
+// ResetDebugLevels turns the tracing of every pod of the target off again
+func (kc *K8sClient) ResetDebugLevels(target DebugTarget) ([]DebugLevelResult, error) {
+	pods, err := kc.DebugTargetPods(target)
+	if err != nil {
+		return nil, err
+	}
+	return kc.ResetPodDebugLevels(pods, target.Container), nil
+}
+
+// SetPodDebugLevels sets the debug level of the pods concurrently
+func (kc *K8sClient) SetPodDebugLevels(pods []v1.Pod, container, debugLevel string) []DebugLevelResult {
+	return kc.debugLevels(pods, container, fmt.Sprintf("enable?filter=all\\&level=%s", debugLevel))
+}
+
+// ResetPodDebugLevels turns the tracing of the pods off concurrently
+func (kc *K8sClient) ResetPodDebugLevels(pods []v1.Pod, container string) []DebugLevelResult {
+	return kc.debugLevels(pods, container, "disable?filter=all")
+}
+
+// PodDebugLevels reads the level every pod traces at from the status of its eTrace endpoint concurrently. The
+// Output of a result is the level, empty when the pod does not trace.
+func (kc *K8sClient) PodDebugLevels(pods []v1.Pod, container string) []DebugLevelResult {
+	results := kc.debugLevels(pods, container, "status")
+	for i := range results {
+		if results[i].Err == nil {
+			results[i].Output = tracingLevel(results[i].Output)
+		}
+	}
+	return results
+}
+
+// tracingLevel returns the highest debug level named in the status of an eTrace endpoint
+func tracingLevel(status string) string {
+	for i := len(DebugLevels) - 1; i >= 0; i-- {
+		if strings.Contains(status, DebugLevels[i]) {
+			return DebugLevels[i]
+		}
+	}
+	return ""
+}
+
+// RestorePodDebugLevels turns off the level a debug session enabled on pods that did not trace before, the
+// other filters and levels of the pods are left as they are
+func (kc *K8sClient) RestorePodDebugLevels(pods []v1.Pod, container, debugLevel string) []DebugLevelResult {
+	return kc.debugLevels(pods, container, fmt.Sprintf("disable?level=%s", debugLevel))
+}
+
+func (kc *K8sClient) debugLevels(pods []v1.Pod, container, request string) []DebugLevelResult {
+	results := make([]DebugLevelResult, len(pods))
+	ExecEach(len(pods), func(i int) {
+		results[i] = kc.debugRequest(pods[i], container, request)
+	})
+	return results
+}
+
+// debugRequest calls the eTrace endpoint of a pod, curl fails on HTTP errors so they are reported as well
+func (kc *K8sClient) debugRequest(pod v1.Pod, container, request string) DebugLevelResult {
+	port, source := DebugPort(pod, container)
+	command := fmt.Sprintf("curl -sS -f http://127.0.0.1:%d/tenv/eTrace/%s", port, request)
+	stdout, stderr, err := kc.ExecuteRemoteCommand(pod.Namespace, pod.Name, container, command)
+	result := DebugLevelResult{Pod: pod.Name, Container: container, Port: port, Output: strings.TrimSpace(stdout)}
+	if err != nil {
+		result.Err = fmt.Errorf("port %d from the %s: %v %s", port, source, err, strings.TrimSpace(stderr+" "+stdout))
+	}
+	return result
+}
+
+// SetDebugLevel sets the debug level of a single container
+// cmd = 'kubectl -n {} exec -it {} -c {} bash -- curl http://127.0.0.1:{}/tenv/eTrace/enable?filter=all\&level=DEBUG_{}'.format(namespace, pod_name, pod_config['container'], pod_config['port'], debug_level)
+func (kc *K8sClient) SetDebugLevel(namespace, podName, container, debugLevel string) bool {
+	pod, err := kc.Client.CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	result := kc.debugRequest(*pod, container, fmt.Sprintf("enable?filter=all\\&level=%s", debugLevel))
+	if result.Err != nil {
+		fmt.Println(result.Err)
+		return false
+	}
+	fmt.Println(result.Output)
+	return true
+}
@@ -0,0 +1,136 @@
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+)
+
+// discoveryTTL is how long discovery results are reused, the TUI runs for a long time
+const discoveryTTL = 5 * time.Minute
+
+// APIResource is a resource served by the API server
+type APIResource struct {
+	GroupVersion string
+	Name         string
+	Kind         string
+	Namespaced   bool
+	Verbs        []string
+}
+
+// GroupVersionResource returns the resource as used by the dynamic client
+func (r APIResource) GroupVersionResource() schema.GroupVersionResource {
+	gv, _ := schema.ParseGroupVersion(r.GroupVersion)
+	return gv.WithResource(r.Name)
+}
+
+// FailedGroup is a group version whose discovery failed, usually because its aggregated API is down
+type FailedGroup struct {
+	GroupVersion string
+	// APIService is the name of the APIService object serving the group version
+	APIService string
+	Error      string
+}
+
+// APIDiscovery is the result of the API discovery, failed group versions do not fail the whole discovery
+type APIDiscovery struct {
+	Resources    []APIResource
+	FailedGroups []FailedGroup
+	Fetched      time.Time
+}
+
+var (
+	discoveryMutex sync.Mutex
+	discoveryCache = map[*kubernetes.Clientset]*APIDiscovery{}
+)
+
+// GetAPIResources returns the preferred resources of the cluster, cached per client.
+// Broken aggregated APIs are returned as failed groups instead of an error.
+func GetAPIResources(client *kubernetes.Clientset) (*APIDiscovery, error) {
+	discoveryMutex.Lock()
+	defer discoveryMutex.Unlock()
+	if cached, found := discoveryCache[client]; found && time.Since(cached.Fetched) < discoveryTTL {
+		return cached, nil
+	}
+
+	lists, err := client.Discovery().ServerPreferredResources()
+	result := &APIDiscovery{Fetched: time.Now()}
+	if err != nil {
+		failed := &discovery.ErrGroupDiscoveryFailed{}
+		if !errors.As(err, &failed) {
+			return nil, fmt.Errorf("API discovery: %v", err)
+		}
+		for gv, groupErr := range failed.Groups {
+			result.FailedGroups = append(result.FailedGroups, FailedGroup{
+				GroupVersion: gv.String(),
+				APIService:   APIServiceName(gv),
+				Error:        groupErr.Error(),
+			})
+		}
+		sort.Slice(result.FailedGroups, func(i, j int) bool {
+			return result.FailedGroups[i].GroupVersion < result.FailedGroups[j].GroupVersion
+		})
+	}
+	for _, list := range lists {
+		for _, apiResource := range list.APIResources {
+			result.Resources = append(result.Resources, APIResource{
+				GroupVersion: list.GroupVersion,
+				Name:         apiResource.Name,
+				Kind:         apiResource.Kind,
+				Namespaced:   apiResource.Namespaced,
+				Verbs:        apiResource.Verbs,
+			})
+		}
+	}
+	discoveryCache[client] = result
+	return result, nil
+}
+
+// GetAPIGroupResources returns the resources of every group for a REST mapper. Like GetAPIResources a broken
+// aggregated API does not fail it, the kinds of the failed group versions are just not mapped.
+func GetAPIGroupResources(client *kubernetes.Clientset) ([]*restmapper.APIGroupResources, error) {
+	groupResources, err := restmapper.GetAPIGroupResources(client.Discovery())
+	if err != nil {
+		failed := &discovery.ErrGroupDiscoveryFailed{}
+		if !errors.As(err, &failed) {
+			return nil, fmt.Errorf("API discovery: %v", err)
+		}
+	}
+	return groupResources, nil
+}
+
+// APIServiceName returns the name of the APIService object of a group version, e.g. v1beta1.metrics.k8s.io
+func APIServiceName(gv schema.GroupVersion) string {
+	if gv.Group == "" {
+		return gv.Version
+	}
+	return gv.Version + "." + gv.Group
+}
+
+// ForKind returns the resource serving a kind, core kinds win over other groups
+func (d *APIDiscovery) ForKind(kind string) (APIResource, bool) {
+	for _, resource := range d.Resources {
+		if resource.Kind == kind && !strings.Contains(resource.Name, "/") {
+			return resource, true
+		}
+	}
+	return APIResource{}, false
+}
+
+// Serves returns true when the group version is served and its discovery did not fail
+func (d *APIDiscovery) Serves(groupVersion string) bool {
+	for _, resource := range d.Resources {
+		if resource.GroupVersion == groupVersion {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EventComponent is the source of the events healthctl records
+const EventComponent = "healthctl"
+
+// ErrNoObject is returned for findings whose object does not exist, or is not a kubernetes object
+var ErrNoObject = errors.New("no such object")
+
+// maxEventMessage is the longest message the API server accepts for an event
+const maxEventMessage = 1024
+
+// RecordEvent records an event on the object a finding refers to, so kubectl describe shows it with the
+// other events of the object. Events of cluster scoped objects are recorded in the default namespace.
+func (kc *K8sClient) RecordEvent(ref models.ResourceRef, eventType, reason, message string) error {
+	gvr, namespaced, err := kc.resourceForKind(ref.Kind)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNoObject, err)
+	}
+	resource := kc.DynamicClient.Resource(gvr)
+	getter := resource.Get
+	if namespaced {
+		getter = resource.Namespace(ref.Namespace).Get
+	}
+	object, err := getter(context.Background(), ref.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("%w: %v", ErrNoObject, err)
+	}
+	if err != nil {
+		return err
+	}
+	namespace := object.GetNamespace()
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	if len(message) > maxEventMessage {
+		message = message[:maxEventMessage-3] + "..."
+	}
+	instance, _ := os.Hostname()
+	now := time.Now()
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s.%x", object.GetName(), now.UnixNano()), Namespace: namespace},
+		InvolvedObject: v1.ObjectReference{
+			APIVersion:      object.GetAPIVersion(),
+			Kind:            object.GetKind(),
+			Namespace:       object.GetNamespace(),
+			Name:            object.GetName(),
+			UID:             object.GetUID(),
+			ResourceVersion: object.GetResourceVersion(),
+		},
+		Type:                eventType,
+		Reason:              reason,
+		Message:             message,
+		Source:              v1.EventSource{Component: EventComponent},
+		FirstTimestamp:      metav1.Time{Time: now},
+		LastTimestamp:       metav1.Time{Time: now},
+		Count:               1,
+		ReportingController: "healthctl.io/" + EventComponent,
+		ReportingInstance:   instance,
+	}
+	_, err = kc.Client.CoreV1().Events(namespace).Create(context.Background(), event, metav1.CreateOptions{})
+	return err
+}
+
+// ApplyConfigMap creates the config map or replaces the data of an existing one
+func (kc *K8sClient) ApplyConfigMap(namespace, name string, data map[string]string) error {
+	configMaps := kc.Client.CoreV1().ConfigMaps(namespace)
+	existing, err := configMaps.Get(context.Background(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		configMap := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app.kubernetes.io/managed-by": EventComponent}},
+			Data:       data,
+		}
+		_, err = configMaps.Create(context.Background(), configMap, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existing.Data = data
+	_, err = configMaps.Update(context.Background(), existing, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,68 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"healthctl/pkg/models"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// maxOwnerDepth limits how far owner references are followed, e.g. Pod -> ReplicaSet -> Deployment
+const maxOwnerDepth = 3
+
+// CollectEvidence collects the yaml of the object a finding is about, its owning workloads,
+// the node it runs on and its events. Objects that can not be read are skipped.
+func (kc *K8sClient) CollectEvidence(ref models.ResourceRef) []models.Evidence {
+	evidence := []models.Evidence{}
+	object, err := kc.GetObject(ref)
+	if err != nil {
+		return evidence
+	}
+	evidence = append(evidence, manifestEvidence(ref, "self", ref, object))
+
+	owner := object
+	for depth := 0; depth < maxOwnerDepth; depth++ {
+		ownerRefs := owner.GetOwnerReferences()
+		if len(ownerRefs) == 0 {
+			break
+		}
+		ownerRef := models.ResourceRef{Kind: ownerRefs[0].Kind, Namespace: ref.Namespace, Name: ownerRefs[0].Name}
+		owner, err = kc.GetObject(ownerRef)
+		if err != nil {
+			break
+		}
+		evidence = append(evidence, manifestEvidence(ref, "owner", ownerRef, owner))
+	}
+
+	nodeName := ref.Node
+	if ref.Kind == "Pod" {
+		nodeName, _, _ = unstructured.NestedString(object.Object, "spec", "nodeName")
+	}
+	if nodeName != "" && ref.Kind != "Node" {
+		nodeRef := models.ResourceRef{Kind: "Node", Name: nodeName}
+		if node, err := kc.GetObject(nodeRef); err == nil {
+			evidence = append(evidence, manifestEvidence(ref, "node", nodeRef, node))
+		}
+	}
+
+	events, err := kc.Client.CoreV1().Events(ref.Namespace).List(context.Background(), metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=%s,involvedObject.name=%s", ref.Kind, ref.Name),
+	})
+	if err == nil && len(events.Items) > 0 {
+		evidence = append(evidence, models.Evidence{For: ref, Relation: "events", Resource: ref, Content: FormatEvents(events.Items)})
+	}
+	return evidence
+}
+
+func manifestEvidence(forRef models.ResourceRef, relation string, ref models.ResourceRef, object *unstructured.Unstructured) models.Evidence {
+	unstructured.RemoveNestedField(object.Object, "metadata", "managedFields")
+	content, err := yaml.Marshal(object.Object)
+	if err != nil {
+		content = []byte(err.Error())
+	}
+	return models.Evidence{For: forRef, Relation: relation, Resource: ref, Content: string(content)}
+}
@@ -0,0 +1,239 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/httpstream/spdy"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// Exec protocols
+const (
+	ExecSPDY      = "spdy"
+	ExecWebSocket = "websocket"
+	// ExecAuto uses spdy and falls back to websocket when the upgrade to spdy fails, e.g. behind an ingress
+	// proxy that only forwards websockets
+	ExecAuto = "auto"
+)
+
+const (
+	// DefaultExecConcurrency is the number of exec sessions open at the same time
+	DefaultExecConcurrency = 8
+	// defaultExecPingPeriod keeps idle exec connections alive through proxies and load balancers
+	defaultExecPingPeriod = 5 * time.Second
+	// DefaultExecTimeout limits an exec session, including the wait for a free slot
+	DefaultExecTimeout = time.Minute
+	// execSessionCache is the number of TLS sessions kept per cluster for resumed handshakes
+	execSessionCache = 64
+)
+
+// ExecOptions configures the sessions of the checks that exec into pods
+type ExecOptions struct {
+	// Protocol is spdy, websocket or auto, the default, which falls back to websocket when spdy is blocked
+	Protocol string `json:"protocol,omitempty"`
+	// MaxConcurrent is the number of exec sessions open at the same time over all checks, defaults to 8
+	MaxConcurrent int `json:"maxConcurrent,omitempty"`
+	// PingPeriod is how often idle spdy sessions are pinged, defaults to 5s, 0 turns pings off
+	PingPeriod string `json:"pingPeriod,omitempty"`
+	// Timeout limits a session including the wait for a free slot, defaults to 1m
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// execSlots bounds the exec sessions open at the same time, it is sized on first use
+var execSlots chan struct{}
+var execSlotsOnce sync.Once
+
+// acquireExecSlot waits for a free slot until the context is done
+func acquireExecSlot(ctx context.Context) (func(), error) {
+	execSlotsOnce.Do(func() {
+		size := connection.Exec.MaxConcurrent
+		if size <= 0 {
+			size = DefaultExecConcurrency
+		}
+		execSlots = make(chan struct{}, size)
+	})
+	select {
+	case execSlots <- struct{}{}:
+		return func() { <-execSlots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for a free exec session: %w", ctx.Err())
+	}
+}
+
+// execTimeout returns the timeout of an exec session, an invalid timeout is reported by the config lint
+func execTimeout() time.Duration {
+	if timeout, err := time.ParseDuration(connection.Exec.Timeout); err == nil && timeout > 0 {
+		return timeout
+	}
+	return DefaultExecTimeout
+}
+
+// execTransport is shared by the exec sessions of a cluster. An exec is an upgraded request of its own, so a
+// spdy session can not be shared by several commands; the TLS configuration is reused instead, so the
+// handshakes of the sessions resume the TLS session of the first.
+type execTransport struct {
+	tls        *tls.Config
+	proxy      func(*http.Request) (*url.URL, error)
+	pingPeriod time.Duration
+	// websocketOnly is set once spdy was blocked and websocket was not, the sessions after it use websocket
+	// right away
+	websocketOnly atomic.Bool
+}
+
+// execTransports are the exec transports by clientset, like restConfigs
+var execTransports sync.Map
+
+func (kc *K8sClient) execTransport(config *rest.Config) (*execTransport, error) {
+	if transport, found := execTransports.Load(kc.Client); found {
+		return transport.(*execTransport), nil
+	}
+	tlsConfig, err := rest.TLSConfigFor(config)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(execSessionCache)
+	}
+	transport := &execTransport{tls: tlsConfig, proxy: http.ProxyFromEnvironment, pingPeriod: defaultExecPingPeriod}
+	if config.Proxy != nil {
+		transport.proxy = config.Proxy
+	}
+	if connection.Exec.PingPeriod != "" {
+		if transport.pingPeriod, err = time.ParseDuration(connection.Exec.PingPeriod); err != nil {
+			return nil, fmt.Errorf("invalid exec ping period %q: %v", connection.Exec.PingPeriod, err)
+		}
+	}
+	actual, _ := execTransports.LoadOrStore(kc.Client, transport)
+	return actual.(*execTransport), nil
+}
+
+// spdyExecutor returns an executor of a new spdy connection over the shared transport
+func (t *execTransport) spdyExecutor(config *rest.Config, request *rest.Request) (remotecommand.Executor, error) {
+	upgrader, err := spdy.NewRoundTripperWithConfig(spdy.RoundTripperConfig{TLS: t.tls, Proxier: t.proxy, PingPeriod: t.pingPeriod})
+	if err != nil {
+		return nil, err
+	}
+	wrapper, err := rest.HTTPWrappersForConfig(config, upgrader)
+	if err != nil {
+		return nil, err
+	}
+	return remotecommand.NewSPDYExecutorForTransports(wrapper, upgrader, "POST", request.URL())
+}
+
+// spdyBlocked returns true when the upgrade to spdy failed, rather than the command or the authorization
+func spdyBlocked(err error) bool {
+	return err != nil && (httpstream.IsUpgradeFailure(err) || strings.Contains(err.Error(), "unable to upgrade connection"))
+}
+
+// ExecuteRemoteCommand runs a shell command in a container and returns its stdout and stderr. At most
+// connection.exec.maxConcurrent sessions are open at the same time, further commands wait for a free one.
+// The session is closed, and its slot released, when connection.exec.timeout passed.
+func (kc *K8sClient) ExecuteRemoteCommand(namespace, pod, container, command string) (string, string, error) {
+	if connection.ReadOnly {
+		return "", "", fmt.Errorf("exec into %s/%s: %w", namespace, pod, ErrReadOnly)
+	}
+	config, err := kc.restConfig()
+	if err != nil {
+		return "", "", err
+	}
+	transport, err := kc.execTransport(config)
+	if err != nil {
+		return "", "", err
+	}
+	request := kc.Client.CoreV1().RESTClient().
+		Post().
+		Namespace(namespace).
+		Resource("pods").
+		Name(pod).
+		SubResource("exec").
+		Param("container", container).
+		VersionedParams(&v1.PodExecOptions{
+			Command: []string{"/bin/sh", "-c", command},
+			Stdin:   false,
+			Stdout:  true,
+			Stderr:  true,
+			TTY:     true,
+		}, scheme.ParameterCodec)
+
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout())
+	defer cancel()
+	release, err := acquireExecSlot(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("exec into %s/%s: %w", namespace, pod, err)
+	}
+	defer release()
+
+	executor, err := transport.executor(config, request)
+	if err != nil {
+		return "", "", err
+	}
+	buf := &bytes.Buffer{}
+	errBuf := &bytes.Buffer{}
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: buf, Stderr: errBuf})
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("exec into %s/%s: %w", namespace, pod, ctx.Err())
+	}
+	return buf.String(), errBuf.String(), err
+}
+
+// executor returns the executor of the configured protocol. With auto it is spdy falling back to websocket
+// when the upgrade to spdy fails, after such a failure the sessions use websocket right away.
+func (t *execTransport) executor(config *rest.Config, request *rest.Request) (remotecommand.Executor, error) {
+	protocol := connection.Exec.Protocol
+	if protocol == "" {
+		protocol = ExecAuto
+	}
+	if protocol == ExecAuto && t.websocketOnly.Load() {
+		protocol = ExecWebSocket
+	}
+	if protocol == ExecWebSocket {
+		return remotecommand.NewWebSocketExecutor(config, "GET", request.URL().String())
+	}
+	spdyExecutor, err := t.spdyExecutor(config, request)
+	if err != nil || protocol == ExecSPDY {
+		return spdyExecutor, err
+	}
+	websocketExecutor, err := remotecommand.NewWebSocketExecutor(config, "GET", request.URL().String())
+	if err != nil {
+		return nil, err
+	}
+	return remotecommand.NewFallbackExecutor(spdyExecutor, websocketExecutor, func(err error) bool {
+		if !spdyBlocked(err) {
+			return false
+		}
+		t.websocketOnly.Store(true)
+		return true
+	})
+}
+
+// ShellQuote quotes a value as a single argument of the shell commands of ExecuteRemoteCommand
+func ShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// ExecEach calls exec for the indexes 0 to count-1 in parallel and waits for all of them. The exec sessions
+// they open share the bounded pool of ExecuteRemoteCommand, so checks can fan out over many pods.
+func ExecEach(count int, exec func(index int)) {
+	var wait sync.WaitGroup
+	for index := range count {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			exec(index)
+		}()
+	}
+	wait.Wait()
+}
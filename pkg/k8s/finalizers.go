@@ -0,0 +1,219 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"healthctl/pkg/models"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// StuckObject is an object that is being deleted but still has finalizers
+type StuckObject struct {
+	Resource   models.ResourceRef
+	Finalizers []string
+	Deleted    time.Time
+}
+
+// builtinFinalizers are the finalizers of kubernetes itself and the controller that removes them
+var builtinFinalizers = map[string]string{
+	"kubernetes":                                  "namespace controller",
+	"foregroundDeletion":                          "garbage collector",
+	"orphan":                                      "garbage collector",
+	"kubernetes.io/pvc-protection":                "kube-controller-manager pvc-protection controller",
+	"kubernetes.io/pv-protection":                 "kube-controller-manager pv-protection controller",
+	"batch.kubernetes.io/job-tracking":            "kube-controller-manager job controller",
+	"service.kubernetes.io/load-balancer-cleanup": "cloud-controller-manager service controller",
+	"customresourcecleanup.apiextensions.k8s.io":  "kube-apiserver CRD cleanup",
+}
+
+// FinalizerOwner returns the controller expected to remove a finalizer and whether it is part of kubernetes
+func FinalizerOwner(finalizer string) (string, bool) {
+	if owner, found := builtinFinalizers[finalizer]; found {
+		return owner, true
+	}
+	if strings.HasPrefix(finalizer, "external-attacher/") {
+		return "CSI external-attacher of " + strings.TrimPrefix(finalizer, "external-attacher/"), true
+	}
+	if domain, _, found := strings.Cut(finalizer, "/"); found {
+		return "controller of " + domain, false
+	}
+	return "unknown controller", false
+}
+
+// metadataPageSize is the number of objects listed per request
+const metadataPageSize = 500
+
+// acceptMetadata asks the API server for the metadata of the objects only, as the metadata client does
+const acceptMetadata = "application/json;as=PartialObjectMetadataList;g=meta.k8s.io;v=v1,application/json"
+
+// listObjectMetadata lists the metadata of every object of a resource with raw requests, so any resource
+// can be listed without a typed client. Only the metadata is transferred, in pages of metadataPageSize.
+func listObjectMetadata(client *kubernetes.Clientset, resource APIResource) ([]metav1.ObjectMeta, error) {
+	path := "/apis/" + resource.GroupVersion + "/" + resource.Name
+	if resource.GroupVersion == "v1" {
+		path = "/api/v1/" + resource.Name
+	}
+	objects := []metav1.ObjectMeta{}
+	next := ""
+	for {
+		request := client.Discovery().RESTClient().Get().AbsPath(path).
+			SetHeader("Accept", acceptMetadata).
+			Param("limit", strconv.Itoa(metadataPageSize))
+		if next != "" {
+			request = request.Param("continue", next)
+		}
+		data, err := request.DoRaw(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		list := metav1.PartialObjectMetadataList{}
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+		for _, item := range list.Items {
+			objects = append(objects, item.ObjectMeta)
+		}
+		if next = list.Continue; next == "" {
+			return objects, nil
+		}
+	}
+}
+
+// ObjectMetadata is the metadata of an object together with the resource it was listed from
+type ObjectMetadata struct {
+	Resource APIResource
+	metav1.ObjectMeta
+}
+
+// metadataTTL is how long a listing of all objects is reused, so the checks of a run share one
+const metadataTTL = time.Minute
+
+// objectListing is a listing of all objects and the group kinds that were listed
+type objectListing struct {
+	objects []ObjectMetadata
+	listed  map[string]bool
+	fetched time.Time
+}
+
+var (
+	metadataMutex sync.Mutex
+	metadataCache = map[*kubernetes.Clientset]*objectListing{}
+)
+
+// ListAllObjectMetadata lists the metadata of the objects of every listable resource. Events are left out,
+// the group kinds that were listed are returned so callers know what they did not see. The listing is cached
+// per client for metadataTTL, checks of the same run calling it at the same time wait for one listing.
+func ListAllObjectMetadata(client *kubernetes.Clientset) ([]ObjectMetadata, map[string]bool, error) {
+	metadataMutex.Lock()
+	defer metadataMutex.Unlock()
+	if cached, found := metadataCache[client]; found && time.Since(cached.fetched) < metadataTTL {
+		return cached.objects, cached.listed, nil
+	}
+	discovery, err := GetAPIResources(client)
+	if err != nil {
+		return nil, nil, err
+	}
+	objects := []ObjectMetadata{}
+	listed := make(map[string]bool)
+	for _, resource := range discovery.Resources {
+		if strings.Contains(resource.Name, "/") || resource.Kind == "Event" || !hasVerb(resource.Verbs, "list") {
+			continue
+		}
+		items, err := listObjectMetadata(client, resource)
+		if err != nil {
+			continue
+		}
+		listed[GroupKind(resource.GroupVersion, resource.Kind)] = true
+		for _, item := range items {
+			objects = append(objects, ObjectMetadata{Resource: resource, ObjectMeta: item})
+		}
+	}
+	metadataCache[client] = &objectListing{objects: objects, listed: listed, fetched: time.Now()}
+	return objects, listed, nil
+}
+
+// GroupKind returns group/kind of an apiVersion and kind, the core group is empty
+func GroupKind(apiVersion, kind string) string {
+	group, _, found := strings.Cut(apiVersion, "/")
+	if !found {
+		group = ""
+	}
+	return group + "/" + kind
+}
+
+// GetStuckObjects returns all objects whose deletion started before olderThan and that still have finalizers.
+// Namespaces are left out, their status tells more than their finalizers.
+func GetStuckObjects(client *kubernetes.Clientset, olderThan time.Duration) ([]StuckObject, error) {
+	objects, _, err := ListAllObjectMetadata(client)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+	stuck := []StuckObject{}
+	for _, object := range objects {
+		if object.Resource.Kind == "Namespace" || object.DeletionTimestamp == nil || len(object.Finalizers) == 0 || object.DeletionTimestamp.Time.After(cutoff) {
+			continue
+		}
+		stuck = append(stuck, StuckObject{
+			Resource:   models.ResourceRef{Kind: object.Resource.Kind, Namespace: object.Namespace, Name: object.Name},
+			Finalizers: object.Finalizers,
+			Deleted:    object.DeletionTimestamp.Time,
+		})
+	}
+	sort.Slice(stuck, func(i, j int) bool { return stuck[i].Resource.String() < stuck[j].Resource.String() })
+	return stuck, nil
+}
+
+func hasVerb(verbs []string, verb string) bool {
+	for _, v := range verbs {
+		if v == verb {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveFinalizer removes a single finalizer from an object. The patch tests that the finalizer is still
+// at the same position, so a concurrent change by its controller makes the removal fail instead of removing another one.
+func (kc *K8sClient) RemoveFinalizer(ref models.ResourceRef, finalizer string) error {
+	object, err := kc.GetObject(ref)
+	if err != nil {
+		return err
+	}
+	index := -1
+	for i, f := range object.GetFinalizers() {
+		if f == finalizer {
+			index = i
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("%s does not have finalizer %s", ref, finalizer)
+	}
+	patch, err := json.Marshal([]map[string]interface{}{
+		{"op": "test", "path": fmt.Sprintf("/metadata/finalizers/%d", index), "value": finalizer},
+		{"op": "remove", "path": fmt.Sprintf("/metadata/finalizers/%d", index)},
+	})
+	if err != nil {
+		return err
+	}
+	gvr, namespaced, err := kc.resourceForKind(ref.Kind)
+	if err != nil {
+		return err
+	}
+	if namespaced {
+		_, err = kc.DynamicClient.Resource(gvr).Namespace(ref.Namespace).Patch(context.Background(), ref.Name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	} else {
+		_, err = kc.DynamicClient.Resource(gvr).Patch(context.Background(), ref.Name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	}
+	return err
+}
@@ -0,0 +1,139 @@
+package k8s
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// gentlePageSize caps the number of objects the API server returns per list call in gentle mode
+	gentlePageSize = 100
+	// gentleDelay is the pause between two API calls in gentle mode
+	gentleDelay = 200 * time.Millisecond
+)
+
+// gentle is set by the -gentle flag, see AddFlags
+var gentle bool
+
+// Gentle returns true when healthctl runs in gentle mode
+func Gentle() bool {
+	return gentle
+}
+
+// SetGentle turns gentle mode on or off without flags
+func SetGentle(enabled bool) {
+	gentle = enabled
+}
+
+// gentleLimit is shared by the transports of all clients, so parallel suites, shards and clients of
+// several clusters together send one request at a time
+var gentleLimit struct {
+	mutex sync.Mutex
+	last  time.Time
+}
+
+// gentleTransport sends one request at a time with a pause in between, and pages list calls
+type gentleTransport struct {
+	next http.RoundTripper
+}
+
+func newGentleTransport(next http.RoundTripper) http.RoundTripper {
+	return &gentleTransport{next: next}
+}
+
+func (t *gentleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	gentleLimit.mutex.Lock()
+	defer gentleLimit.mutex.Unlock()
+	if wait := gentleDelay - time.Since(gentleLimit.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	defer func() { gentleLimit.last = time.Now() }()
+
+	query := req.URL.Query()
+	if req.Method != http.MethodGet || !isListPath(req.URL.Path) || query.Get("watch") == "true" || query.Get("limit") != "" {
+		return t.next.RoundTrip(req)
+	}
+	return t.paginate(req)
+}
+
+// isListPath returns true for collection paths like /api/v1/pods or /apis/apps/v1/namespaces/default/deployments
+func isListPath(path string) bool {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	switch segments[0] {
+	case "api":
+		return len(segments) == 3 || (len(segments) == 5 && segments[2] == "namespaces")
+	case "apis":
+		return len(segments) == 4 || (len(segments) == 6 && segments[3] == "namespaces")
+	}
+	return false
+}
+
+// paginate fetches a list in pages of gentlePageSize and returns all items in a single response,
+// so the checks do not need to know about paging
+func (t *gentleTransport) paginate(req *http.Request) (*http.Response, error) {
+	var list map[string]json.RawMessage
+	var resp *http.Response
+	items := []json.RawMessage{}
+	continueToken := ""
+	for {
+		page := req.Clone(req.Context())
+		query := page.URL.Query()
+		query.Set("limit", strconv.Itoa(gentlePageSize))
+		if continueToken != "" {
+			query.Set("continue", continueToken)
+		}
+		page.URL.RawQuery = query.Encode()
+
+		pageResp, err := t.next.RoundTrip(page)
+		if err != nil || pageResp.StatusCode != http.StatusOK || !strings.Contains(pageResp.Header.Get("Content-Type"), "json") {
+			return pageResp, err
+		}
+		body, err := io.ReadAll(pageResp.Body)
+		pageResp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		pageList := struct {
+			Metadata struct {
+				Continue string `json:"continue"`
+			} `json:"metadata"`
+			Items []json.RawMessage `json:"items"`
+		}{}
+		if err := json.Unmarshal(body, &pageList); err != nil {
+			return nil, err
+		}
+		if list == nil {
+			resp = pageResp
+			if err := json.Unmarshal(body, &list); err != nil {
+				return nil, err
+			}
+		}
+		items = append(items, pageList.Items...)
+		continueToken = pageList.Metadata.Continue
+		if continueToken == "" {
+			break
+		}
+		time.Sleep(gentleDelay)
+	}
+
+	metadata := map[string]json.RawMessage{}
+	json.Unmarshal(list["metadata"], &metadata)
+	delete(metadata, "continue")
+	delete(metadata, "remainingItemCount")
+	list["metadata"], _ = json.Marshal(metadata)
+	list["items"], _ = json.Marshal(items)
+	body, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
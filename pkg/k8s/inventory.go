@@ -0,0 +1,59 @@
+package k8s
+
+import (
+	"context"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// inventoryNodes is how many nodes are sampled to discover the provider and region of a cluster
+const inventoryNodes = 500
+
+// Labels of the cluster inventory that healthctl discovers itself
+const (
+	LabelVersion  = "version"
+	LabelProvider = "provider"
+	LabelRegion   = "region"
+)
+
+// DiscoverClusterLabels returns the inventory labels that can be read from the cluster: the kubernetes
+// version, the cloud provider from the providerID of the nodes and the most common region of the nodes.
+// Labels that cannot be discovered are left out.
+func (kc *K8sClient) DiscoverClusterLabels() map[string]string {
+	labels := make(map[string]string)
+	if version, err := kc.Client.Discovery().ServerVersion(); err == nil {
+		labels[LabelVersion] = version.GitVersion
+	}
+	nodes, err := kc.Client.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{Limit: inventoryNodes})
+	if err != nil {
+		return labels
+	}
+	providers, regions := make(map[string]int), make(map[string]int)
+	for _, node := range nodes.Items {
+		if provider, _, found := strings.Cut(node.Spec.ProviderID, "://"); found && provider != "" {
+			providers[provider]++
+		}
+		if region := node.Labels["topology.kubernetes.io/region"]; region != "" {
+			regions[region]++
+		}
+	}
+	if provider := mostCommon(providers); provider != "" {
+		labels[LabelProvider] = provider
+	}
+	if region := mostCommon(regions); region != "" {
+		labels[LabelRegion] = region
+	}
+	return labels
+}
+
+// mostCommon returns the value counted most often, the smallest value on a tie
+func mostCommon(counts map[string]int) string {
+	common := ""
+	for value, count := range counts {
+		if count > counts[common] || (count == counts[common] && value < common) {
+			common = value
+		}
+	}
+	return common
+}
@@ -0,0 +1,117 @@
+package k8s
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// JVMDiagnostics are the diagnostics captured from a JVM: the thread dump, the heap summary and the tail
+// of the GC log
+var JVMDiagnostics = []string{"threads", "heapinfo", "gclog"}
+
+// maxGCLogBytes is how much of the end of a GC log is captured
+const maxGCLogBytes = 4 << 20
+
+// jvmGCLogFlag finds the GC log file in the flags of the JVM, -Xlog:gc*:file=<path>:... on Java 9 and
+// later and -Xloggc:<path> before
+var jvmGCLogFlag = regexp.MustCompile(`-Xlog:gc[^:\s]*:(?:file=)?([^:\s]+)|-Xloggc:(\S+)`)
+
+// CaptureJVMDiagnostics runs jcmd in a container of a Java pod to capture a thread dump with the locks
+// held, the heap summary and the end of the GC log. gcLog is the GC log file, it is read from the flags of
+// the JVM when empty. An error is returned when no JVM is found, errors of single diagnostics are in the
+// results.
+func (kc *K8sClient) CaptureJVMDiagnostics(pod v1.Pod, container, gcLog string) ([]Profile, error) {
+	pid, err := kc.jvmPid(pod, container)
+	if err != nil {
+		return nil, err
+	}
+	captured := []Profile{}
+	for _, name := range JVMDiagnostics {
+		var output string
+		var err error
+		switch name {
+		case "threads":
+			output, err = kc.jcmd(pod, container, pid, "Thread.print -l")
+		case "heapinfo":
+			output, err = kc.jcmd(pod, container, pid, "GC.heap_info")
+		case "gclog":
+			output, err = kc.gcLog(pod, container, pid, gcLog)
+		}
+		captured = append(captured, Profile{Name: name, Data: []byte(output), Err: err})
+	}
+	return captured, nil
+}
+
+// jvmPid returns the process id of the JVM in the container, jcmd lists itself as well
+func (kc *K8sClient) jvmPid(pod v1.Pod, container string) (string, error) {
+	stdout, stderr, err := kc.ExecuteRemoteCommand(pod.Namespace, pod.Name, container, "jcmd -l")
+	output := strings.ReplaceAll(stdout+stderr, "\r", "")
+	if err != nil {
+		return "", fmt.Errorf("jcmd -l in %s: %v %s", pod.Name, err, strings.TrimSpace(output))
+	}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.Contains(line, "sun.tools.jcmd.JCmd") {
+			continue
+		}
+		if strings.Trim(fields[0], "0123456789") == "" {
+			return fields[0], nil
+		}
+	}
+	return "", fmt.Errorf("no JVM found by jcmd -l in %s", pod.Name)
+}
+
+func (kc *K8sClient) jcmd(pod v1.Pod, container, pid, command string) (string, error) {
+	stdout, stderr, err := kc.ExecuteRemoteCommand(pod.Namespace, pod.Name, container, fmt.Sprintf("jcmd %s %s", pid, command))
+	output := strings.ReplaceAll(stdout, "\r", "")
+	if err != nil {
+		return "", fmt.Errorf("jcmd %s: %v %s", command, err, strings.TrimSpace(output+" "+stderr))
+	}
+	return output, nil
+}
+
+// gcLog returns the end of the GC log of the JVM. The %p and %t of the file name of -Xlog are expanded to
+// the process id and the newest log of any start time.
+func (kc *K8sClient) gcLog(pod v1.Pod, container, pid, file string) (string, error) {
+	if file == "" {
+		flags, err := kc.jcmd(pod, container, pid, "VM.command_line")
+		if err != nil {
+			return "", err
+		}
+		file = ParseGCLogFile(flags)
+		if file == "" {
+			return "", fmt.Errorf("the JVM does not write a GC log file, -Xlog:gc*:file=<path> is not set")
+		}
+	}
+	file = strings.ReplaceAll(file, "%p", pid)
+	path := ShellQuote(file)
+	if strings.Contains(file, "%t") {
+		// %t is the start time of the JVM, the newest matching log is the one of the running JVM
+		parts := strings.Split(file, "%t")
+		for i := range parts {
+			parts[i] = ShellQuote(parts[i])
+		}
+		path = fmt.Sprintf(`"$(ls -t %s | head -n 1)"`, strings.Join(parts, "*"))
+	}
+	stdout, stderr, err := kc.ExecuteRemoteCommand(pod.Namespace, pod.Name, container, fmt.Sprintf("tail -c %d %s", maxGCLogBytes, path))
+	output := strings.ReplaceAll(stdout, "\r", "")
+	if err != nil {
+		return "", fmt.Errorf("reading the GC log %s: %v %s", file, err, strings.TrimSpace(output+" "+stderr))
+	}
+	return output, nil
+}
+
+// ParseGCLogFile returns the GC log file in the output of jcmd VM.command_line, logs to stdout and stderr
+// are in the container log instead
+func ParseGCLogFile(flags string) string {
+	for _, match := range jvmGCLogFlag.FindAllStringSubmatch(flags, -1) {
+		file := match[1] + match[2]
+		if file != "stdout" && file != "stderr" {
+			return file
+		}
+	}
+	return ""
+}
@@ -2,45 +2,71 @@ package k8s
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
-	"bytes"
+	"healthctl/pkg/models"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/client-go/util/homedir"
 
 	v1 "k8s.io/api/core/v1"
 
-	"k8s.io/client-go/kubernetes/scheme"
-
 	resource "k8s.io/apimachinery/pkg/api/resource"
-	"k8s.io/client-go/tools/remotecommand"
 	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
 	metrics "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
-var kubeconfig *string
-var contextFlag *string
+// kubeconfig and contextFlag are set by the -kubeconfig and -context flags, see AddFlags
+var kubeconfig = defaultKubeconfig()
+var contextFlag string
 
-func init() {
+func defaultKubeconfig() string {
 	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
-		contextFlag = flag.String("context", "", "")
+		return filepath.Join(home, ".kube", "config")
+	}
+	return ""
+}
+
+// AddFlags registers the kubeconfig, connection, gentle and read-only mode flags on a flag set. The package never
+// parses the command line itself, so programs embedding the checks keep their own flags.
+func AddFlags(fs *flag.FlagSet) {
+	if kubeconfig != "" {
+		fs.StringVar(&kubeconfig, "kubeconfig", kubeconfig, "(optional) absolute path to the kubeconfig file")
 	} else {
-		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
-		contextFlag = flag.String("context", "", "")
+		fs.StringVar(&kubeconfig, "kubeconfig", "", "absolute path to the kubeconfig file")
 	}
+	fs.StringVar(&contextFlag, "context", "", "(optional) kubeconfig context to use instead of the current context")
+	fs.StringVar(&connection.Proxy, "proxy", "", "(optional) proxy url for the API server, defaults to HTTPS_PROXY from the environment")
+	fs.StringVar(&connection.CAFile, "certificate-authority", "", "(optional) CA bundle used to verify the API server instead of the one in the kubeconfig")
+	fs.BoolVar(&connection.InsecureSkipTLSVerify, "insecure-skip-tls-verify", false, "(optional) do not verify the API server certificate. Only for testing, the connection is not secure")
+	fs.StringVar(&connection.Exec.Protocol, "exec-protocol", "", "(optional) protocol of the checks that exec into pods: spdy, websocket or auto, which falls back to websocket when a proxy blocks spdy")
+	fs.StringVar(&connection.As, "as", "", "(optional) user to impersonate, e.g. system:serviceaccount:<namespace>:<name>, to run the checks with the permissions of a tenant")
+	fs.Var((*groupsFlag)(&connection.AsGroups), "as-group", "(optional) group to impersonate, can be repeated, needs -as")
+	fs.BoolVar(&connection.ReadOnly, "read-only", false, "reject every change to the cluster: no exec into pods, no flush, no remediation and no kubeconfig writes")
+	fs.BoolVar(&gentle, "gentle", false, "protect an API server under load: one request at a time, paged lists, pauses between calls and no exec based checks")
+}
+
+// SetKubeconfig selects the kubeconfig file and context without flags, an empty file uses the in-cluster
+// configuration and an empty context the current one
+func SetKubeconfig(file, contextName string) {
+	kubeconfig, contextFlag = file, contextName
 }
 
 type K8sClient struct {
@@ -49,59 +75,105 @@ type K8sClient struct {
 	MetricsClient *metrics.Clientset
 }
 
-func GetClustersFromKubeConfig() *clientcmdapi.Config {
-	flag.Parse()
-	config, err := clientcmd.LoadFromFile(*kubeconfig)
+func GetClustersFromKubeConfig() (*clientcmdapi.Config, error) {
+	config, err := clientcmd.LoadFromFile(kubeconfig)
 	if err != nil {
-		panic(err.Error())
+		return nil, fmt.Errorf("loading kubeconfig %s: %v", kubeconfig, err)
 	}
-	return config
+	return config, nil
 }
 
-func CreateK8sClientSet() (*kubernetes.Clientset, error) {
+// RestConfig builds the client configuration for a kubeconfig context.
+// An empty context uses the -context flag, or the current context of the kubeconfig.
+func RestConfig(contextName string) (*rest.Config, error) {
+	var config *rest.Config
+	var err error
+	if kubeconfig == "" {
+		config, err = rest.InClusterConfig()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if contextName == "" {
+			contextName = contextFlag
+		}
+		loadingRules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig}
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: contextName}
+		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("loading kubeconfig %s: %v", kubeconfig, err)
+		}
+	}
+	if err := applyConnectionOptions(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
 
-	flag.Parse()
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+func CreateK8sClientSet() (*kubernetes.Clientset, error) {
+	config, err := RestConfig("")
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 	return kubernetes.NewForConfig(config)
 }
 
 func CreateDynamicClientSet() (dynamic.Interface, error) {
-	flag.Parse()
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	config, err := RestConfig("")
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 	return dynamic.NewForConfig(config)
 }
 
 func CreateMetricsClientSet() (*metrics.Clientset, error) {
-	flag.Parse()
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	config, err := RestConfig("")
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 	return metrics.NewForConfig(config)
 }
 
 func NewK8sClient() (*K8sClient, error) {
-	client, err := CreateK8sClientSet()
+	return NewK8sClientForContext("")
+}
+
+// NewK8sClientForContext creates the clients for a kubeconfig context, an empty context is the current one
+func NewK8sClientForContext(contextName string) (*K8sClient, error) {
+	config, err := RestConfig(contextName)
+	if err != nil {
+		return nil, err
+	}
+	return NewK8sClientForConfig(config)
+}
+
+// restConfigs are the client configurations of the clientsets created by NewK8sClientForConfig, so checks
+// that only get a clientset exec into the pods of the right cluster
+var restConfigs sync.Map
+
+// NewK8sClientForConfig creates the clients for a client configuration, used as is without the connection
+// options of the flags except read-only mode, which applies to every client
+func NewK8sClientForConfig(config *rest.Config) (*K8sClient, error) {
+	if connection.ReadOnly {
+		config = rest.CopyConfig(config)
+		config.Wrap(newReadOnlyTransport)
+	}
+	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
 
-	dynamicClient, err := CreateDynamicClientSet()
+	dynamicClient, err := dynamic.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
 
-	metricsClient, err := CreateMetricsClientSet()
+	metricsClient, err := metrics.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
 
+	restConfigs.Store(client, config)
 	return &K8sClient{
 		Client:        client,
 		DynamicClient: dynamicClient,
@@ -109,6 +181,77 @@ func NewK8sClient() (*K8sClient, error) {
 	}, nil
 }
 
+// restConfig returns the client configuration the clientset was created with
+func (kc *K8sClient) restConfig() (*rest.Config, error) {
+	if config, found := restConfigs.Load(kc.Client); found {
+		return config.(*rest.Config), nil
+	}
+	return RestConfig("")
+}
+
+// Identity returns the API server and the user the clients act as, the impersonated user and groups
+// included. Credentials are only named by a hash, so the identity can be stored.
+func (kc *K8sClient) Identity() string {
+	config, err := kc.restConfig()
+	if err != nil {
+		return ""
+	}
+	user := config.Username
+	if user == "" {
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%s|%s|%s|%x", config.BearerToken, config.BearerTokenFile, config.CertFile, config.CertData)
+		if config.ExecProvider != nil {
+			fmt.Fprintf(hash, "|%s|%v|%v", config.ExecProvider.Command, config.ExecProvider.Args, config.ExecProvider.Env)
+		}
+		if config.AuthProvider != nil {
+			fmt.Fprintf(hash, "|%s|%v", config.AuthProvider.Name, config.AuthProvider.Config)
+		}
+		user = fmt.Sprintf("%x", hash.Sum(nil))[:12]
+	}
+	identity := config.Host + "|" + user
+	if impersonate := config.Impersonate; impersonate.UserName != "" {
+		identity += "|as " + impersonate.UserName
+		if len(impersonate.Groups) > 0 {
+			identity += " " + strings.Join(impersonate.Groups, ",")
+		}
+	}
+	return identity
+}
+
+// NewBaseK8sClient creates the clients of the current context as the user of the kubeconfig, without the
+// impersonation of -as and connection.as, e.g. to read the secrets the configuration refers to
+func NewBaseK8sClient() (*K8sClient, error) {
+	config, err := RestConfig("")
+	if err != nil {
+		return nil, err
+	}
+	config = rest.CopyConfig(config)
+	config.Impersonate = rest.ImpersonationConfig{}
+	return NewK8sClientForConfig(config)
+}
+
+// ContextForCluster returns the kubeconfig context of a cluster, a context name is accepted as well
+func ContextForCluster(cluster string) (string, error) {
+	config, err := GetClustersFromKubeConfig()
+	if err != nil {
+		return "", err
+	}
+	if _, found := config.Contexts[cluster]; found {
+		return cluster, nil
+	}
+	contexts := []string{}
+	for key, kubeContext := range config.Contexts {
+		if kubeContext.Cluster == cluster {
+			contexts = append(contexts, key)
+		}
+	}
+	if len(contexts) > 0 {
+		sort.Strings(contexts)
+		return contexts[0], nil
+	}
+	return "", fmt.Errorf("no kubeconfig context for cluster %s", cluster)
+}
+
 // GetClusterInfo returns the cluster version
 func (kc *K8sClient) GetClusterInfo() (string, error) {
 	clusterVersion, err := kc.Client.Discovery().ServerVersion()
@@ -120,46 +263,62 @@ func (kc *K8sClient) GetClusterInfo() (string, error) {
 }
 
 // Set context for the client
-func (kc *K8sClient) SetContext(config *clientcmdapi.Config, contextToSwitch string) {
+func (kc *K8sClient) SetContext(config *clientcmdapi.Config, contextToSwitch string) error {
 
 	for key, contexts := range config.Contexts {
 		if contexts.Cluster == contextToSwitch {
 			config.CurrentContext = key
 		}
 	}
-	// Write the config back to the kubeconfig file
-	clientcmd.ModifyConfig(clientcmd.NewDefaultPathOptions(), *config, false)
-	//load the client again with config
-	client, err := CreateK8sClientSet()
-	if err != nil {
-		panic(err.Error())
+	// Write the config back to the kubeconfig file, in read-only mode the context is only switched for this process
+	if !connection.ReadOnly {
+		clientcmd.ModifyConfig(clientcmd.NewDefaultPathOptions(), *config, false)
 	}
-	kc.Client = client
-	dclient, err := CreateDynamicClientSet()
+	// the selected cluster wins over a context given on the command line
+	contextFlag = config.CurrentContext
+	//load the client again with config
+	client, err := NewK8sClient()
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
-	kc.DynamicClient = dclient
+	*kc = *client
+	return nil
+}
 
-	metricsClient, err := CreateMetricsClientSet()
-	if err != nil {
-		panic(err.Error())
-	}
-	kc.MetricsClient = metricsClient
+func (kc *K8sClient) GetCurrentContext() string {
+	return CurrentContext()
+}
 
+func (kc *K8sClient) GetCurrentCluster() string {
+	return CurrentCluster()
 }
 
-func (kc *K8sClient) GetCurrentContext() string {
+// CurrentContext returns the kubeconfig context in use, the one given on the command line or the current one
+func CurrentContext() string {
 	//get context from kubeconfig
-	config := GetClustersFromKubeConfig()
+	config, err := GetClustersFromKubeConfig()
+	if err != nil {
+		return ""
+	}
+	if contextFlag != "" {
+		return contextFlag
+	}
 	return config.CurrentContext
 }
 
-func (kc *K8sClient) GetCurrentCluster() string {
+// CurrentCluster returns the cluster of the kubeconfig context in use
+func CurrentCluster() string {
 	cluster := ""
-	config := GetClustersFromKubeConfig()
+	config, err := GetClustersFromKubeConfig()
+	if err != nil {
+		return cluster
+	}
+	currentContext := config.CurrentContext
+	if contextFlag != "" {
+		currentContext = contextFlag
+	}
 	for key, contexts := range config.Contexts {
-		if key == config.CurrentContext {
+		if key == currentContext {
 			cluster = contexts.Cluster
 		}
 	}
@@ -204,140 +363,29 @@ func (kc *K8sClient) GetClusterNamespaces() []string {
 	return namespaceList
 }
 
-func (kc *K8sClient) GetPods(namespace string) []string {
-	var podList []string
-	pods, err := kc.Client.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
+// GetNamespaceLabels returns the labels of every namespace keyed by namespace name
+func (kc *K8sClient) GetNamespaceLabels() map[string]map[string]string {
+	namespaceLabels := make(map[string]map[string]string)
+	namespaces, err := kc.Client.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return nil
+		return namespaceLabels
 	}
-	for _, pod := range pods.Items {
-		podList = append(podList, pod.Name)
+	for _, namespace := range namespaces.Items {
+		namespaceLabels[namespace.Name] = namespace.Labels
 	}
-	return podList
+	return namespaceLabels
 }
 
-func (kc *K8sClient) GetContainers(pod string) []string {
-	var containerList []string
-	pods, err := kc.Client.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{
-		FieldSelector: fmt.Sprintf("metadata.name=%s", pod),
-	})
+func (kc *K8sClient) GetPods(namespace string) []string {
+	var podList []string
+	pods, err := kc.Client.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
 	if err != nil {
 		return nil
 	}
-	for _, container := range pods.Items[0].Spec.Containers {
-		containerList = append(containerList, container.Name)
-	}
-	return containerList
-}
-
-func GetAPIResources(client *kubernetes.Clientset) {
-	// Get all resources in the cluster
-	resources, err := client.Discovery().ServerPreferredResources()
-	if err != nil {
-		panic(err.Error())
-	}
-	for _, resource := range resources {
-		fmt.Printf("Resource: %s\n", resource.GroupVersion)
-		for _, apiResource := range resource.APIResources {
-			fmt.Printf("  Name: %s\n", apiResource.Name)
-			fmt.Printf("  Namespaced: %t\n", apiResource.Namespaced)
-			fmt.Printf("  Kind: %s\n", apiResource.Kind)
-			fmt.Printf("  Verbs: %s\n", apiResource.Verbs)
-		}
-	}
-
-}
-
-type TestStatus struct {
-	Status bool
-	Info   string
-	Error  error
-}
-
-// Check if all nodes are ready
-func (kc *K8sClient) CheckNodes() TestStatus {
-	nodes, err := kc.Client.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
-	if err != nil {
-		return TestStatus{
-			Status: false,
-			Info:   "",
-			Error:  err}
-	}
-	for _, node := range nodes.Items {
-		for _, condition := range node.Status.Conditions {
-			if condition.Type == "Ready" && condition.Status != "True" {
-				return TestStatus{
-					Status: false,
-					Info:   fmt.Sprintf("Node %s is not ready\n", node.Name),
-					Error:  nil}
-			}
-		}
-	}
-	return TestStatus{
-		Status: true,
-		Info:   "All nodes are ready",
-		Error:  nil}
-}
-
-// Check if all pods are running
-func (kc *K8sClient) CheckPods() TestStatus {
-	pods, err := kc.Client.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
-	if err != nil {
-		return TestStatus{
-			Status: false,
-			Info:   "",
-			Error:  err}
-	}
-	notRunningCount := 0
 	for _, pod := range pods.Items {
-
-		if pod.Status.Phase != "Running" {
-			notRunningCount++
-		}
-	}
-	if notRunningCount > 0 {
-		return TestStatus{
-			Status: false,
-			Info:   fmt.Sprintf("%d pods are in not running state\n", notRunningCount),
-			Error:  nil}
-	}
-	return TestStatus{
-		Status: true,
-		Info:   "All pods are running",
-		Error:  nil}
-}
-
-// Check Events on the cluster
-func (kc *K8sClient) CheckEvents() TestStatus {
-	events, err := kc.Client.CoreV1().Events("").List(context.Background(), metav1.ListOptions{})
-	if err != nil {
-		return TestStatus{
-			Status: false,
-			Info:   "",
-			Error:  err}
-	}
-	warningEventsCount := 0
-	errorEventsCount := 0
-
-	for _, event := range events.Items {
-		if event.Type == "Warning" {
-			warningEventsCount++
-		}
-		if event.Type == "Error" {
-			errorEventsCount++
-		}
-	}
-	if warningEventsCount > 0 || errorEventsCount > 0 {
-		return TestStatus{
-			Status: false,
-			Info:   fmt.Sprintf("%d warning events and %d error events found\n", warningEventsCount, errorEventsCount),
-			Error:  nil}
+		podList = append(podList, pod.Name)
 	}
-
-	return TestStatus{
-		Status: true,
-		Info:   "No failed events found",
-		Error:  nil}
+	return podList
 }
 
 type Alert struct {
@@ -345,6 +393,8 @@ type Alert struct {
 	Severity  string
 	StartsAt  string
 	PodName   string
+	Namespace string
+	Node      string
 	Summary   string
 }
 
@@ -380,6 +430,8 @@ func (kc *K8sClient) GetAlerts() []Alert {
 			Severity:  alert.Labels["severity"],
 			StartsAt:  alert.StartsAt,
 			PodName:   alert.Labels["pod"],
+			Namespace: alert.Labels["namespace"],
+			Node:      alert.Labels["node"],
 			Summary:   alert.Annotations["summary"],
 		})
 	}
@@ -387,92 +439,49 @@ func (kc *K8sClient) GetAlerts() []Alert {
 
 }
 
-func (kc *K8sClient) ExecuteRemoteCommand(namespace, pod, container, command string) (string, string, error) {
-	flag.Parse()
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
-	if err != nil {
-		panic(err.Error())
-	}
-	buf := &bytes.Buffer{}
-	errBuf := &bytes.Buffer{}
-	request := kc.Client.CoreV1().RESTClient().
-		Post().
-		Namespace(namespace).
-		Resource("pods").
-		Name(pod).
-		SubResource("exec").
-		Param("container", container).
-		VersionedParams(&v1.PodExecOptions{
-			Command: []string{"/bin/sh", "-c", command},
-			Stdin:   false,
-			Stdout:  true,
-			Stderr:  true,
-			TTY:     true,
-		}, scheme.ParameterCodec)
-	exec, _ := remotecommand.NewSPDYExecutor(config, "POST", request.URL())
-	_ = exec.Stream(remotecommand.StreamOptions{
-		Stdout: buf,
-		Stderr: errBuf,
-	})
-
-	return buf.String(), errBuf.String(), nil
-}
-
 type RedisDbSizeInfo struct {
 	PodName string
 	Output  string
 }
 
+// GetRedisDbSize returns the number of keys of every master of the Redis cluster, read once from a
+// coordinator pod
 func (kc *K8sClient) GetRedisDbSize() []RedisDbSizeInfo {
 	redis_namespace := "fed-redis-cluster"
 	redis_container := "redis-node"
 
-	returnSize := []RedisDbSizeInfo{}
-
-	//get the list of pods from the redis namespace
-	pods, err := kc.Client.CoreV1().Pods(redis_namespace).List(context.Background(), metav1.ListOptions{})
+	replies, err := kc.RedisClusterCall(redis_namespace, redis_container, "dbsize")
 	if err != nil {
+		fmt.Println(err)
 		return nil
 	}
-	for _, pod := range pods.Items {
-		//execute command to get the redis db size
-		command := fmt.Sprintf("redis-cli --cluster call --cluster-only-masters redis-cluster.%s.svc.cluster.local:6379 dbsize", redis_namespace)
-		stdout, stderr, err := kc.ExecuteRemoteCommand(redis_namespace, pod.Name, redis_container, command)
-		if err != nil {
-			fmt.Println(err)
-			fmt.Println(stderr)
+	returnSize := make([]RedisDbSizeInfo, len(replies))
+	for i, reply := range replies {
+		returnSize[i] = RedisDbSizeInfo{
+			PodName: reply.PodName,
+			Output:  reply.Reply,
 		}
-		returnSize = append(returnSize, RedisDbSizeInfo{
-			PodName: pod.Name,
-			Output:  stdout,
-		})
 	}
 	return returnSize
 }
+
+// FlushRedisData flushes every master of the Redis cluster once from a coordinator pod
 func (kc *K8sClient) FlushRedisData() error {
 	redis_namespace := "fed-redis-cluster"
 	redis_container := "redis-node"
 
-	returnSize := []RedisDbSizeInfo{}
-
-	//get the list of pods from the redis namespace
-	pods, err := kc.Client.CoreV1().Pods(redis_namespace).List(context.Background(), metav1.ListOptions{})
+	replies, err := kc.RedisClusterCall(redis_namespace, redis_container, "flushall")
 	if err != nil {
-		return nil
+		return err
 	}
-	for _, pod := range pods.Items {
-		//execute command to flush redis data
-		command := fmt.Sprintf("redis-cli --cluster call --cluster-only-masters redis-cluster.%s.svc.cluster.local:6379 flushall", redis_namespace)
-		stdout, stderr, err := kc.ExecuteRemoteCommand(redis_namespace, pod.Name, redis_container, command)
-		if err != nil {
-			fmt.Println(err)
-			fmt.Println(stderr)
-			return err
+	failed := []string{}
+	for _, reply := range replies {
+		if reply.Reply != "OK" {
+			failed = append(failed, fmt.Sprintf("%s: %s", reply.PodName, reply.Reply))
 		}
-		returnSize = append(returnSize, RedisDbSizeInfo{
-			PodName: pod.Name,
-			Output:  stdout,
-		})
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("flushall failed on %s", strings.Join(failed, "; "))
 	}
 	return nil
 }
@@ -614,24 +623,6 @@ func (kc *K8sClient) GetRedisStatus() RedisStatus {
 
 }
 
-// cmd = 'kubectl -n {} exec -it {} -c {} bash -- curl http://127.0.0.1:{}/tenv/eTrace/enable?filter=all\&level=DEBUG_{}'.format(namespace, pod_name, pod_config['container'], pod_config['port'], debug_level)
-func (kc *K8sClient) SetDebugLevel(namespace, pod, container, debugLevel string) bool {
-	port := "9090"
-	//TODO: Port is hardcoded here. It should be fetched from the local config based on the service name
-	//TODO: Prepare the local config file to fetch the port based on the service name
-	command := fmt.Sprintf("curl http://127.0.0.1:%s/tenv/eTrace/enable?filter=all\\&level=%s", port, debugLevel)
-	fmt.Println(command)
-	stdout, stderr, err := kc.ExecuteRemoteCommand(namespace, pod, container, command)
-	if err != nil {
-		fmt.Println(err)
-		fmt.Println(stderr)
-		return false
-	}
-
-	fmt.Println(stdout)
-	return true
-}
-
 func (kc *K8sClient) GetKargoServiceIP() (string, error) {
 	service, err := kc.Client.CoreV1().Services("fed-paas-helpers").Get(context.Background(), "kargo", metav1.GetOptions{})
 	if err != nil {
@@ -646,18 +637,28 @@ func (kc *K8sClient) GetKargoServiceIP() (string, error) {
 }
 
 type ResourceUsageReport struct {
-	PodsUsage []PodUsage
+	// MetricsAvailable is false when the metrics API can not be reached, usages are then not set
+	MetricsAvailable bool
+	Findings         []models.Finding
+	PodsUsage        []PodUsage
+	// Cost is only set when a cost model is configured
+	Cost *CostReport
 }
 type PodUsage struct {
 	PodName         string
 	Namespace       string
+	Workload        string
 	ContainerUsages []ContainerUsage
 }
 
 type ContainerUsage struct {
 	Name        string
+	HasMetrics  bool
 	CPUUsage    float64
 	MemoryUsage float64
+	Usage       v1.ResourceList
+	Requests    v1.ResourceList
+	Limits      v1.ResourceList
 }
 
 func GetCPUUsagePercentage(usage, request resource.Quantity) float64 {
@@ -675,27 +676,42 @@ func GetMemoryUsagePercentage(usage, request resource.Quantity) float64 {
 	return float64(usage.Value()) / float64(request.Value()) * 100
 }
 
-func (kc *K8sClient) GetResourceUsageReport() ResourceUsageReport {
-	report := ResourceUsageReport{}
-	// Get all pods in all namespaces
-	pods, err := kc.Client.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		log.Fatalf("Error fetching pods: %v", err)
+// MetricsUnavailable is the finding reported when the metrics API of metrics-server can not be used
+func MetricsUnavailable(err error) models.Finding {
+	return models.Finding{
+		Resource: models.ResourceRef{Kind: "APIService", Name: MetricsAPIService},
+		Reason:   "Unavailable",
+		Severity: models.SeverityWarning,
+		Message:  fmt.Sprintf("metrics-server is unavailable, resource usage is not known: %v", err),
 	}
+}
 
-	// Get metrics for all pods in all namespaces
+// MetricsAPIService is the APIService serving the resource metrics API
+const MetricsAPIService = "v1beta1.metrics.k8s.io"
 
-	podMetricsList, err := kc.MetricsClient.MetricsV1beta1().PodMetricses("").List(context.Background(), metav1.ListOptions{})
+// GetResourceUsageReport returns the usage of every container relative to its requests.
+// Without metrics-server only the requests and limits are reported, with a warning finding.
+func (kc *K8sClient) GetResourceUsageReport() (ResourceUsageReport, error) {
+	report := ResourceUsageReport{MetricsAvailable: true}
+	// Get all pods in all namespaces
+	pods, err := kc.Client.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
-		log.Fatalf("Error fetching pod metrics: %v", err)
+		return report, fmt.Errorf("fetching pods: %v", err)
 	}
 
 	// Create a map of pod metrics by name/namespace for easier lookup
 	podMetricsMap := make(map[string]metricsv1beta1.PodMetrics)
-	for _, podMetrics := range podMetricsList.Items {
-
-		key := fmt.Sprintf("%s/%s", podMetrics.Namespace, podMetrics.Name)
-		podMetricsMap[key] = podMetrics
+	if kc.MetricsClient == nil {
+		report.MetricsAvailable = false
+		report.Findings = append(report.Findings, MetricsUnavailable(fmt.Errorf("no metrics client")))
+	} else if podMetricsList, err := kc.MetricsClient.MetricsV1beta1().PodMetricses("").List(context.Background(), metav1.ListOptions{}); err != nil {
+		report.MetricsAvailable = false
+		report.Findings = append(report.Findings, MetricsUnavailable(err))
+	} else {
+		for _, podMetrics := range podMetricsList.Items {
+			key := fmt.Sprintf("%s/%s", podMetrics.Namespace, podMetrics.Name)
+			podMetricsMap[key] = podMetrics
+		}
 	}
 
 	// Iterate over the pods and fetch CPU and memory usage
@@ -703,45 +719,57 @@ func (kc *K8sClient) GetResourceUsageReport() ResourceUsageReport {
 		podusage := PodUsage{
 			PodName:   pod.Name,
 			Namespace: pod.Namespace,
+			Workload:  podWorkloadName(pod),
 		}
 
-		podKey := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
-		if podMetrics, found := podMetricsMap[podKey]; found {
-			//fmt.Printf("Pod: %s/%s\n", pod.Namespace, pod.Name)
-			for _, container := range pod.Spec.Containers {
-				// Find corresponding metrics for the container
-				for _, containerMetrics := range podMetrics.Containers {
-					if container.Name == containerMetrics.Name {
-						// Get container resource requests
-						requestedCPU := container.Resources.Requests[v1.ResourceCPU]
-						requestedMemory := container.Resources.Requests[v1.ResourceMemory]
-
-						// Get container usage
-						usedCPU := containerMetrics.Usage[v1.ResourceCPU]
-						usedMemory := containerMetrics.Usage[v1.ResourceMemory]
-
-						// Calculate percentages
-						cpuPercentage := GetCPUUsagePercentage(usedCPU, requestedCPU)
-						memoryPercentage := GetMemoryUsagePercentage(usedMemory, requestedMemory)
-						containerusage := ContainerUsage{
-							Name:        container.Name,
-							CPUUsage:    cpuPercentage,
-							MemoryUsage: memoryPercentage,
-						}
-						podusage.ContainerUsages = append(podusage.ContainerUsages, containerusage)
-						// // Print the result
-						// fmt.Printf("  Container: %s\n", container.Name)
-						// fmt.Printf("    CPU: %s used, %s requested, %.2f%% of request\n", usedCPU.String(), requestedCPU.String(), cpuPercentage)
-						// fmt.Printf("    Memory: %s used, %s requested, %.2f%% of request\n", usedMemory.String(), requestedMemory.String(), memoryPercentage)
-					}
+		podMetrics := podMetricsMap[fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)]
+		for _, container := range pod.Spec.Containers {
+			containerusage := ContainerUsage{
+				Name:     container.Name,
+				Requests: container.Resources.Requests,
+				Limits:   container.Resources.Limits,
+			}
+			// Find corresponding metrics for the container
+			for _, containerMetrics := range podMetrics.Containers {
+				if container.Name != containerMetrics.Name {
+					continue
 				}
+				containerusage.HasMetrics = true
+				containerusage.Usage = containerMetrics.Usage
+				containerusage.CPUUsage = GetCPUUsagePercentage(containerMetrics.Usage[v1.ResourceCPU], container.Resources.Requests[v1.ResourceCPU])
+				containerusage.MemoryUsage = GetMemoryUsagePercentage(containerMetrics.Usage[v1.ResourceMemory], container.Resources.Requests[v1.ResourceMemory])
 			}
-
-		} else {
-			fmt.Printf("No metrics available for pod: %s/%s\n", pod.Namespace, pod.Name)
+			podusage.ContainerUsages = append(podusage.ContainerUsages, containerusage)
 		}
 		report.PodsUsage = append(report.PodsUsage, podusage)
-
 	}
-	return report
+	return report, nil
+}
+
+// NamespaceUsage is the usage and requests of the containers of a namespace, cpu in millicores and memory
+// in bytes
+type NamespaceUsage struct {
+	CPUUsed         int64
+	CPURequested    int64
+	MemoryUsed      int64
+	MemoryRequested int64
+}
+
+// Namespaces sums the usage and requests of the containers of every namespace, the usage only of the
+// containers with metrics
+func (r ResourceUsageReport) Namespaces() map[string]NamespaceUsage {
+	namespaces := make(map[string]NamespaceUsage)
+	for _, pod := range r.PodsUsage {
+		namespace := namespaces[pod.Namespace]
+		for _, container := range pod.ContainerUsages {
+			namespace.CPURequested += container.Requests.Cpu().MilliValue()
+			namespace.MemoryRequested += container.Requests.Memory().Value()
+			if container.HasMetrics {
+				namespace.CPUUsed += container.Usage.Cpu().MilliValue()
+				namespace.MemoryUsed += container.Usage.Memory().Value()
+			}
+		}
+		namespaces[pod.Namespace] = namespace
+	}
+	return namespaces
 }
@@ -0,0 +1,66 @@
+package k8s
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ConsumerLag is the lag of a consumer group on one topic partition
+type ConsumerLag struct {
+	Group         string
+	Topic         string
+	Partition     int32
+	CurrentOffset int64
+	LogEndOffset  int64
+	Lag           int64
+}
+
+// GetConsumerGroupLag describes a consumer group with kafka-consumer-groups.sh inside a broker pod,
+// the script queries the offsets through the Kafka Admin API
+func (kc *K8sClient) GetConsumerGroupLag(namespace, pod, container, bootstrapServer, group string) ([]ConsumerLag, error) {
+	command := fmt.Sprintf("kafka-consumer-groups.sh --bootstrap-server %s --describe --group %s", ShellQuote(bootstrapServer), ShellQuote(group))
+	stdout, stderr, err := kc.ExecuteRemoteCommand(namespace, pod, container, command)
+	if err != nil {
+		return nil, fmt.Errorf("describing consumer group %s: %v %s", group, err, stderr)
+	}
+	return ParseConsumerGroups(stdout), nil
+}
+
+// ParseConsumerGroups parses the table printed by kafka-consumer-groups.sh --describe.
+// Partitions without a committed offset have the lag "-" and are skipped.
+func ParseConsumerGroups(output string) []ConsumerLag {
+	lags := []ConsumerLag{}
+	columns := map[string]int{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "GROUP" {
+			for i, field := range fields {
+				columns[field] = i
+			}
+			continue
+		}
+		if len(columns) == 0 || len(fields) <= columns["LAG"] {
+			continue
+		}
+		lag, err := strconv.ParseInt(fields[columns["LAG"]], 10, 64)
+		if err != nil {
+			continue
+		}
+		partition, _ := strconv.ParseInt(fields[columns["PARTITION"]], 10, 32)
+		current, _ := strconv.ParseInt(fields[columns["CURRENT-OFFSET"]], 10, 64)
+		end, _ := strconv.ParseInt(fields[columns["LOG-END-OFFSET"]], 10, 64)
+		lags = append(lags, ConsumerLag{
+			Group:         fields[columns["GROUP"]],
+			Topic:         fields[columns["TOPIC"]],
+			Partition:     int32(partition),
+			CurrentOffset: current,
+			LogEndOffset:  end,
+			Lag:           lag,
+		})
+	}
+	return lags
+}
@@ -0,0 +1,95 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// minioAlias is the mc alias healthctl configures inside the MinIO pod, pointing at the local server
+const minioAlias = "healthctl"
+
+// MinioDrive is the state of one drive as reported by the MinIO admin API
+type MinioDrive struct {
+	Endpoint string `json:"endpoint"`
+	Path     string `json:"path"`
+	State    string `json:"state"`
+	Healing  bool   `json:"healing"`
+	Pool     int    `json:"pool_index"`
+	Set      int    `json:"set_index"`
+}
+
+// MinioServer is one MinIO server with its drives
+type MinioServer struct {
+	Endpoint string       `json:"endpoint"`
+	State    string       `json:"state"`
+	Drives   []MinioDrive `json:"drives"`
+}
+
+// MinioInfo is the part of `mc admin info --json` healthctl checks
+type MinioInfo struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Info   struct {
+		Mode    string `json:"mode"`
+		Backend struct {
+			Type             string `json:"backendType"`
+			OnlineDisks      int    `json:"onlineDisks"`
+			OfflineDisks     int    `json:"offlineDisks"`
+			StandardSCParity int    `json:"standardSCParity"`
+		} `json:"backend"`
+		Servers []MinioServer `json:"servers"`
+	} `json:"info"`
+}
+
+// minioAliasCommand configures the healthctl alias with the root credentials of the MinIO pod
+func minioAliasCommand() string {
+	return fmt.Sprintf("mc alias set %s http://localhost:9000 \"${MINIO_ROOT_USER:-$MINIO_ACCESS_KEY}\" \"${MINIO_ROOT_PASSWORD:-$MINIO_SECRET_KEY}\" >/dev/null", minioAlias)
+}
+
+// GetMinioClusterHealth calls the unauthenticated cluster health endpoint through the service proxy,
+// MinIO answers 503 when the erasure sets can not serve writes
+func (kc *K8sClient) GetMinioClusterHealth(namespace, service, port string) error {
+	_, err := kc.Client.CoreV1().Services(namespace).ProxyGet("http", service, port, "/minio/health/cluster", nil).DoRaw(context.Background())
+	return err
+}
+
+// GetMinioInfo runs mc admin info in the MinIO pod and returns the server and drive states
+func (kc *K8sClient) GetMinioInfo(namespace, pod, container string) (*MinioInfo, error) {
+	command := minioAliasCommand() + fmt.Sprintf(" && mc admin info %s --json", minioAlias)
+	stdout, stderr, err := kc.ExecuteRemoteCommand(namespace, pod, container, command)
+	if err != nil {
+		return nil, fmt.Errorf("mc admin info: %v %s", err, strings.TrimSpace(stderr+stdout))
+	}
+	info := &MinioInfo{}
+	if err := json.Unmarshal([]byte(stdout), info); err != nil {
+		return nil, fmt.Errorf("parsing mc admin info: %v", err)
+	}
+	if info.Status == "error" {
+		return nil, fmt.Errorf("mc admin info: %s", info.Error)
+	}
+	return info, nil
+}
+
+// MinioCanary writes, reads back and deletes a small object in the bucket, the bucket is created if needed
+func (kc *K8sClient) MinioCanary(namespace, pod, container, bucket string) error {
+	object := fmt.Sprintf("%s/%s/healthctl-canary-%d", minioAlias, bucket, time.Now().Unix())
+	content := fmt.Sprintf("healthctl canary %s", time.Now().UTC().Format(time.RFC3339))
+	command := strings.Join([]string{
+		minioAliasCommand(),
+		fmt.Sprintf("mc mb --ignore-existing %s/%s >/dev/null", minioAlias, bucket),
+		fmt.Sprintf("echo '%s' | mc pipe %s >/dev/null", content, object),
+		fmt.Sprintf("mc cat %s", object),
+		fmt.Sprintf("mc rm %s >/dev/null", object),
+	}, " && ")
+	stdout, stderr, err := kc.ExecuteRemoteCommand(namespace, pod, container, command)
+	if err != nil {
+		return fmt.Errorf("canary object round trip: %v %s", err, strings.TrimSpace(stderr+stdout))
+	}
+	if strings.TrimSpace(stdout) != content {
+		return fmt.Errorf("canary object read back %q, expected %q", strings.TrimSpace(stdout), content)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Kinds of the containers of a pod
+const (
+	ContainerInit      = "init"
+	ContainerRegular   = "container"
+	ContainerEphemeral = "ephemeral"
+	// ContainerSidecar are native sidecars, init containers that keep running besides the containers.
+	// DiagnosePod also takes the containers besides the main container of a pod for sidecars.
+	ContainerSidecar = "sidecar"
+)
+
+// Container is a container of a pod with its status
+type Container struct {
+	Name  string
+	Kind  string
+	Image string
+	Ready bool
+	// State is Running, Waiting or Terminated with the reason, or Pending before the container has a status
+	State    string
+	Restarts int32
+}
+
+func (c Container) String() string {
+	name := c.Name
+	if c.Kind != ContainerRegular {
+		name = c.Kind + ":" + c.Name
+	}
+	return fmt.Sprintf("%s (%s, %d restarts)", name, c.State, c.Restarts)
+}
+
+// GetContainers returns the init containers, containers and ephemeral containers of a pod with their status
+func (kc *K8sClient) GetContainers(namespace, podName string) ([]Container, error) {
+	pod, err := kc.Client.CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return PodContainers(pod), nil
+}
+
+// PodContainers returns the init containers, containers and ephemeral containers of a pod with their status
+func PodContainers(pod *v1.Pod) []Container {
+	statuses := make(map[string]v1.ContainerStatus)
+	for _, status := range pod.Status.InitContainerStatuses {
+		statuses[ContainerInit+"/"+status.Name] = status
+		statuses[ContainerSidecar+"/"+status.Name] = status
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		statuses[ContainerRegular+"/"+status.Name] = status
+	}
+	for _, status := range pod.Status.EphemeralContainerStatuses {
+		statuses[ContainerEphemeral+"/"+status.Name] = status
+	}
+
+	containers := []Container{}
+	add := func(kind, name, image string) {
+		container := Container{Name: name, Kind: kind, Image: image, State: "Pending"}
+		if status, found := statuses[kind+"/"+name]; found {
+			container.Ready = status.Ready
+			container.Restarts = status.RestartCount
+			container.State = containerState(status.State)
+		}
+		containers = append(containers, container)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if container.RestartPolicy != nil && *container.RestartPolicy == v1.ContainerRestartPolicyAlways {
+			add(ContainerSidecar, container.Name, container.Image)
+		} else {
+			add(ContainerInit, container.Name, container.Image)
+		}
+	}
+	for _, container := range pod.Spec.Containers {
+		add(ContainerRegular, container.Name, container.Image)
+	}
+	for _, container := range pod.Spec.EphemeralContainers {
+		add(ContainerEphemeral, container.Name, container.Image)
+	}
+	return containers
+}
+
+func containerState(state v1.ContainerState) string {
+	switch {
+	case state.Running != nil:
+		return "Running"
+	case state.Waiting != nil && state.Waiting.Reason != "":
+		return "Waiting: " + state.Waiting.Reason
+	case state.Terminated != nil && state.Terminated.Reason != "":
+		return "Terminated: " + state.Terminated.Reason
+	case state.Terminated != nil:
+		return fmt.Sprintf("Terminated: exit code %d", state.Terminated.ExitCode)
+	}
+	return "Waiting"
+}
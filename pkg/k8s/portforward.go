@@ -0,0 +1,53 @@
+package k8s
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// PortForward forwards a random local port to a port of a pod and returns the local port and a function
+// that stops the forwarding
+func (kc *K8sClient) PortForward(namespace, pod string, port int) (int, func(), error) {
+	if connection.ReadOnly {
+		return 0, nil, fmt.Errorf("port-forward to %s/%s: %w", namespace, pod, ErrReadOnly)
+	}
+	config, err := kc.restConfig()
+	if err != nil {
+		return 0, nil, err
+	}
+	transport, upgrader, err := spdy.RoundTripperFor(config)
+	if err != nil {
+		return 0, nil, err
+	}
+	url := kc.Client.CoreV1().RESTClient().Post().Namespace(namespace).Resource("pods").Name(pod).SubResource("portforward").URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+
+	stop, ready := make(chan struct{}), make(chan struct{})
+	errOut := &bytes.Buffer{}
+	forwarder, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"}, []string{fmt.Sprintf("0:%d", port)}, stop, ready, nil, errOut)
+	if err != nil {
+		return 0, nil, err
+	}
+	failed := make(chan error, 1)
+	go func() {
+		failed <- forwarder.ForwardPorts()
+	}()
+	select {
+	case <-ready:
+	case err := <-failed:
+		if err == nil {
+			err = fmt.Errorf("port-forward to %s/%s ended", namespace, pod)
+		}
+		return 0, nil, fmt.Errorf("port-forward to %s/%s:%d: %v %s", namespace, pod, port, err, errOut.String())
+	}
+	ports, err := forwarder.GetPorts()
+	if err != nil || len(ports) == 0 {
+		close(stop)
+		return 0, nil, fmt.Errorf("port-forward to %s/%s:%d has no local port: %v", namespace, pod, port, err)
+	}
+	return int(ports[0].Local), func() { close(stop) }, nil
+}
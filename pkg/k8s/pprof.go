@@ -0,0 +1,98 @@
+package k8s
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// PprofPortAnnotation on a pod sets the port of its net/http/pprof endpoints
+	PprofPortAnnotation = "healthctl.io/pprof-port"
+	// DefaultPprofPort is the port of the pprof endpoints of pods that do not declare theirs
+	DefaultPprofPort = 6060
+	// DefaultCPUProfile is how long the CPU profile is recorded
+	DefaultCPUProfile = 30 * time.Second
+	// pprofPortName is the name of the container port of the pprof endpoints
+	pprofPortName = "pprof"
+)
+
+// PprofProfiles are the profiles that can be captured from a Go service
+var PprofProfiles = []string{"heap", "goroutine", "cpu"}
+
+// Profile is a pprof profile or a JVM diagnostic captured from a pod, Data is the gzipped protobuf go tool
+// pprof reads or the text of the diagnostic
+type Profile struct {
+	Name string
+	Data []byte
+	Err  error
+}
+
+// PprofPort returns the port of the pprof endpoints of a pod and where it was found: the annotation
+// healthctl.io/pprof-port, a container port named pprof or the port given, 6060 when it is 0
+func PprofPort(pod v1.Pod, port int) (int, string) {
+	if value, found := pod.Annotations[PprofPortAnnotation]; found {
+		if port, err := strconv.Atoi(value); err == nil && port > 0 {
+			return port, "annotation " + PprofPortAnnotation
+		}
+	}
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name == pprofPortName {
+				return int(p.ContainerPort), "container port " + pprofPortName
+			}
+		}
+	}
+	if port > 0 {
+		return port, "configured"
+	}
+	return DefaultPprofPort, "default"
+}
+
+// CaptureProfiles fetches the profiles of a pod over a single port-forward, cpu records for the given
+// duration. An error is returned when the port-forward fails, errors of single profiles are in the profiles.
+func (kc *K8sClient) CaptureProfiles(pod v1.Pod, port int, profiles []string, cpu time.Duration) ([]Profile, error) {
+	port, source := PprofPort(pod, port)
+	local, stop, err := kc.PortForward(pod.Namespace, pod.Name, port)
+	if err != nil {
+		return nil, fmt.Errorf("pprof port %d from the %s: %v", port, source, err)
+	}
+	defer stop()
+	if cpu <= 0 {
+		cpu = DefaultCPUProfile
+	}
+	client := &http.Client{Timeout: cpu + time.Minute}
+
+	captured := []Profile{}
+	for _, name := range profiles {
+		path := "/debug/pprof/" + name
+		if name == "cpu" {
+			path = fmt.Sprintf("/debug/pprof/profile?seconds=%d", int(cpu.Seconds()))
+		}
+		profile := Profile{Name: name}
+		profile.Data, profile.Err = fetchProfile(client, fmt.Sprintf("http://127.0.0.1:%d%s", local, path))
+		captured = append(captured, profile)
+	}
+	return captured, nil
+}
+
+func fetchProfile(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s %s", url, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
@@ -0,0 +1,67 @@
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrReadOnly is returned for the requests and actions that change the cluster or the kubeconfig in
+// read-only mode
+var ErrReadOnly = errors.New("not allowed in read-only mode")
+
+// readOnlyReviews are the resources created by checks that only ask the API server about the caller,
+// they do not change the cluster
+var readOnlyReviews = map[string]bool{
+	"selfsubjectreviews":       true,
+	"selfsubjectaccessreviews": true,
+	"selfsubjectrulesreviews":  true,
+}
+
+// readOnlySubresources open sessions into pods even with GET, e.g. exec over websocket
+var readOnlySubresources = map[string]bool{"exec": true, "attach": true, "portforward": true}
+
+// ReadOnly returns true when healthctl runs in read-only mode, see ConnectionOptions.ReadOnly
+func ReadOnly() bool {
+	return connection.ReadOnly
+}
+
+// SetReadOnly turns read-only mode on or off without flags
+func SetReadOnly(enabled bool) {
+	connection.ReadOnly = enabled
+}
+
+// readOnlyTransport rejects every request that could change the cluster before it is sent: writes other than
+// dry runs and self reviews, and sessions into pods
+type readOnlyTransport struct {
+	next http.RoundTripper
+}
+
+func newReadOnlyTransport(next http.RoundTripper) http.RoundTripper {
+	return &readOnlyTransport{next: next}
+}
+
+func (t *readOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !readOnlyRequest(req) {
+		return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL.Path, ErrReadOnly)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// readOnlyRequest returns true for the requests allowed in read-only mode
+func readOnlyRequest(req *http.Request) bool {
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(segments) > 0 && readOnlySubresources[segments[len(segments)-1]] {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		return readOnlyReviews[segments[len(segments)-1]] || req.URL.Query().Get("dryRun") == "All"
+	case http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return req.URL.Query().Get("dryRun") == "All"
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GetRedisConfig returns the runtime configuration of a Redis node read with CONFIG GET inside its pod,
+// which includes changes made with CONFIG SET that are not in the config file
+func (kc *K8sClient) GetRedisConfig(namespace, pod, container string) (map[string]string, error) {
+	// REDISCLI_AUTH is taken from the password variables the common images and charts set
+	command := `REDISCLI_AUTH="${REDISCLI_AUTH:-${REDIS_PASSWORD:-$REDIS_PASS}}" redis-cli --raw CONFIG GET '*'`
+	stdout, stderr, err := kc.ExecuteRemoteCommand(namespace, pod, container, command)
+	if err != nil {
+		return nil, fmt.Errorf("reading redis config of %s/%s: %v %s", namespace, pod, err, stderr)
+	}
+	config := ParseRedisConfig(stdout)
+	if len(config) == 0 {
+		return nil, fmt.Errorf("reading redis config of %s/%s: %s", namespace, pod, strings.TrimSpace(stdout))
+	}
+	return config, nil
+}
+
+// ParseRedisConfig parses the alternating parameter and value lines printed by redis-cli --raw CONFIG GET
+func ParseRedisConfig(output string) map[string]string {
+	lines := strings.Split(strings.ReplaceAll(output, "\r", ""), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	config := make(map[string]string)
+	if len(lines)%2 != 0 {
+		return config
+	}
+	for i := 0; i+1 < len(lines); i += 2 {
+		config[lines[i]] = lines[i+1]
+	}
+	return config
+}
+
+// redisClusterPort is the client port of the Redis cluster nodes
+const redisClusterPort = 6379
+
+// RedisNodeReply is the reply of a Redis node to a command run on the whole cluster
+type RedisNodeReply struct {
+	// PodName is the pod of the node, or its address when no pod has the IP
+	PodName string
+	Address string
+	Reply   string
+}
+
+// clusterCallLine matches the reply lines "ip:port: reply" of redis-cli --cluster call
+var clusterCallLine = regexp.MustCompile(`^([0-9A-Fa-f.:]+):(\d+): ?(.*)$`)
+
+// ansiEscape matches the color codes redis-cli writes when it runs on a TTY
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// redisCoordinators returns the running and ready pods of the namespace by name, the first is the
+// coordinator of the cluster calls and the others are tried when it can not be reached, and the pod of
+// every IP
+func (kc *K8sClient) redisCoordinators(namespace string) ([]v1.Pod, map[string]string, error) {
+	pods, err := kc.Client.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	byIP := make(map[string]string)
+	ready := []v1.Pod{}
+	for _, pod := range pods.Items {
+		if pod.Status.PodIP == "" {
+			continue
+		}
+		byIP[pod.Status.PodIP] = pod.Name
+		if pod.Status.Phase != v1.PodRunning || pod.DeletionTimestamp != nil {
+			continue
+		}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == v1.PodReady && condition.Status == v1.ConditionTrue {
+				ready = append(ready, pod)
+			}
+		}
+	}
+	sort.Slice(ready, func(i, j int) bool { return ready[i].Name < ready[j].Name })
+	return ready, byIP, nil
+}
+
+// RedisClusterCall runs a command once on every master of the Redis cluster with redis-cli --cluster call
+// from a single coordinator pod and returns the reply of every master, attributed to its pod by the address
+// redis-cli prints. Running it from every pod would send the command to every master once per pod.
+func (kc *K8sClient) RedisClusterCall(namespace, container, command string) ([]RedisNodeReply, error) {
+	if connection.ReadOnly {
+		return nil, fmt.Errorf("redis cluster call %q: %w", command, ErrReadOnly)
+	}
+	coordinators, byIP, err := kc.redisCoordinators(namespace)
+	if err != nil {
+		return nil, fmt.Errorf("listing redis pods of %s: %v", namespace, err)
+	}
+	if len(coordinators) == 0 {
+		return nil, fmt.Errorf("no ready redis pod in %s", namespace)
+	}
+	errs := []string{}
+	for _, pod := range coordinators {
+		// the coordinator addresses itself by pod IP, not by the service, so its reply is attributed like
+		// the replies of the other masters
+		call := fmt.Sprintf("redis-cli --cluster call --cluster-only-masters %s:%d %s", pod.Status.PodIP, redisClusterPort, command)
+		stdout, stderr, err := kc.ExecuteRemoteCommand(namespace, pod.Name, container, call)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v %s", pod.Name, err, strings.TrimSpace(stderr)))
+			continue
+		}
+		replies := ParseRedisClusterCall(stdout)
+		if len(replies) == 0 {
+			errs = append(errs, fmt.Sprintf("%s: %s", pod.Name, strings.TrimSpace(ansiEscape.ReplaceAllString(stdout, ""))))
+			continue
+		}
+		for i := range replies {
+			host, _, _ := net.SplitHostPort(replies[i].Address)
+			replies[i].PodName = replies[i].Address
+			if name, found := byIP[host]; found {
+				replies[i].PodName = name
+			}
+		}
+		return replies, nil
+	}
+	return nil, fmt.Errorf("redis cluster call %q failed: %s", command, strings.Join(errs, "; "))
+}
+
+// ParseRedisClusterCall parses the output of redis-cli --cluster call into the reply of every node, the
+// PodName of the replies is not set
+func ParseRedisClusterCall(output string) []RedisNodeReply {
+	replies := []RedisNodeReply{}
+	for _, line := range strings.Split(ansiEscape.ReplaceAllString(output, ""), "\n") {
+		match := clusterCallLine.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		replies = append(replies, RedisNodeReply{Address: net.JoinHostPort(match[1], match[2]), Reply: strings.TrimSpace(match[3])})
+	}
+	return replies
+}
@@ -0,0 +1,158 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// RolloutProblems returns why the Deployments, StatefulSets and DaemonSets matching the selector have not
+// finished rolling out, an empty list when all of them run the latest revision with every replica available
+func (kc *K8sClient) RolloutProblems(namespace, selector string) ([]string, int, error) {
+	ctx := context.Background()
+	opts := metav1.ListOptions{LabelSelector: selector}
+	problems := []string{}
+	deployments, err := kc.Client.AppsV1().Deployments(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, d := range deployments.Items {
+		want := replicas(d.Spec.Replicas)
+		switch {
+		case d.Status.ObservedGeneration < d.Generation:
+			problems = append(problems, fmt.Sprintf("Deployment %s: new spec not observed yet", d.Name))
+		case d.Status.UpdatedReplicas < want:
+			problems = append(problems, fmt.Sprintf("Deployment %s: %d of %d replicas updated", d.Name, d.Status.UpdatedReplicas, want))
+		case d.Status.Replicas > d.Status.UpdatedReplicas:
+			problems = append(problems, fmt.Sprintf("Deployment %s: %d old replicas pending termination", d.Name, d.Status.Replicas-d.Status.UpdatedReplicas))
+		case d.Status.AvailableReplicas < want:
+			problems = append(problems, fmt.Sprintf("Deployment %s: %d of %d replicas available", d.Name, d.Status.AvailableReplicas, want))
+		}
+	}
+	statefulsets, err := kc.Client.AppsV1().StatefulSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, s := range statefulsets.Items {
+		want := replicas(s.Spec.Replicas)
+		switch {
+		case s.Status.ObservedGeneration < s.Generation:
+			problems = append(problems, fmt.Sprintf("StatefulSet %s: new spec not observed yet", s.Name))
+		case s.Status.UpdateRevision != "" && s.Status.CurrentRevision != s.Status.UpdateRevision:
+			problems = append(problems, fmt.Sprintf("StatefulSet %s: %d of %d replicas updated", s.Name, s.Status.UpdatedReplicas, want))
+		case s.Status.ReadyReplicas < want:
+			problems = append(problems, fmt.Sprintf("StatefulSet %s: %d of %d replicas ready", s.Name, s.Status.ReadyReplicas, want))
+		}
+	}
+	daemonsets, err := kc.Client.AppsV1().DaemonSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, d := range daemonsets.Items {
+		switch {
+		case d.Status.ObservedGeneration < d.Generation:
+			problems = append(problems, fmt.Sprintf("DaemonSet %s: new spec not observed yet", d.Name))
+		case d.Status.UpdatedNumberScheduled < d.Status.DesiredNumberScheduled:
+			problems = append(problems, fmt.Sprintf("DaemonSet %s: %d of %d pods updated", d.Name, d.Status.UpdatedNumberScheduled, d.Status.DesiredNumberScheduled))
+		case d.Status.NumberAvailable < d.Status.DesiredNumberScheduled:
+			problems = append(problems, fmt.Sprintf("DaemonSet %s: %d of %d pods available", d.Name, d.Status.NumberAvailable, d.Status.DesiredNumberScheduled))
+		}
+	}
+	return problems, len(deployments.Items) + len(statefulsets.Items) + len(daemonsets.Items), nil
+}
+
+// PodProblems returns the pods matching the selector that crash loop, fail or are not ready
+func (kc *K8sClient) PodProblems(namespace, selector string) ([]string, error) {
+	pods, err := kc.Client.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	problems := []string{}
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if reason := PodIssueReason(pod); reason != "" {
+			problems = append(problems, fmt.Sprintf("Pod %s: %s", pod.Name, reason))
+		}
+	}
+	return problems, nil
+}
+
+// serviceProbeTimeout bounds every GET of ServiceProblems
+const serviceProbeTimeout = 10 * time.Second
+
+// ServiceProblems sends an HTTP GET for path through the API server proxy to every HTTP port of the services
+// selecting the pods matched by the selector, error responses are problems of their port. Only ports named
+// http or https, or prefixed or suffixed by it like http-metrics, or with an HTTP appProtocol are called.
+func (kc *K8sClient) ServiceProblems(namespace, selector, path string) ([]string, error) {
+	ctx := context.Background()
+	pods, err := kc.Client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	services, err := kc.Client.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	problems := []string{}
+	probed := 0
+	for _, service := range services.Items {
+		if !selectsAny(service, pods.Items) {
+			continue
+		}
+		for _, port := range service.Spec.Ports {
+			scheme := httpScheme(port)
+			if scheme == "" || (port.Protocol != "" && port.Protocol != v1.ProtocolTCP) {
+				continue
+			}
+			probed++
+			ctx, cancel := context.WithTimeout(context.Background(), serviceProbeTimeout)
+			_, err := kc.Client.CoreV1().Services(namespace).ProxyGet(scheme, service.Name, strconv.Itoa(int(port.Port)), path, nil).DoRaw(ctx)
+			cancel()
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("Service %s port %s: GET %s failed: %v", service.Name, port.Name, path, err))
+			}
+		}
+	}
+	if probed == 0 {
+		problems = append(problems, fmt.Sprintf("no service port named http selects the pods of %q to GET %s", selector, path))
+	}
+	return problems, nil
+}
+
+// httpScheme returns the scheme of a service port that serves HTTP, empty for other ports
+func httpScheme(port v1.ServicePort) string {
+	protocol := ""
+	if port.AppProtocol != nil {
+		protocol = strings.ToLower(*port.AppProtocol)
+	}
+	for _, scheme := range []string{"https", "http"} {
+		if protocol == scheme || port.Name == scheme || strings.HasPrefix(port.Name, scheme+"-") || strings.HasSuffix(port.Name, "-"+scheme) {
+			return scheme
+		}
+	}
+	if protocol == "kubernetes.io/h2c" {
+		return "http"
+	}
+	return ""
+}
+
+func selectsAny(service v1.Service, pods []v1.Pod) bool {
+	if len(service.Spec.Selector) == 0 {
+		return false
+	}
+	selector := labels.SelectorFromSet(service.Spec.Selector)
+	for _, pod := range pods {
+		if selector.Matches(labels.Set(pod.Labels)) {
+			return true
+		}
+	}
+	return false
+}
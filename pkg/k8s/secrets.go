@@ -0,0 +1,21 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GetSecretValue returns the value of a key of a Secret, used to resolve secret references in the config
+func (kc *K8sClient) GetSecretValue(namespace, name, key string) (string, error) {
+	secret, err := kc.Client.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	value, found := secret.Data[key]
+	if !found {
+		return "", fmt.Errorf("secret %s/%s has no key %s", namespace, name, key)
+	}
+	return string(value), nil
+}
@@ -0,0 +1,167 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// ShardOf returns the shard of a namespace, namespaces are spread over the shards by a hash of their name so
+// every agent of a fleet computes the same shards
+func ShardOf(namespace string, count int) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(namespace))
+	return int(hash.Sum32() % uint32(count))
+}
+
+// Shard returns a client that lists the namespaced resources only in the namespaces of the shard. Checks
+// that list pods or deployments of all namespaces get the objects of the shard, so the memory of a check
+// is bounded by its shard. Cluster scoped resources like nodes are listed as they are.
+func (kc *K8sClient) Shard(index, count int) (*K8sClient, error) {
+	if index < 0 || index >= count {
+		return nil, fmt.Errorf("shard %d is not between 0 and %d", index, count-1)
+	}
+	namespaces, err := kc.Client.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing namespaces: %v", err)
+	}
+	shard := []string{}
+	for _, namespace := range namespaces.Items {
+		if ShardOf(namespace.Name, count) == index {
+			shard = append(shard, namespace.Name)
+		}
+	}
+	// a partial discovery still returns the namespaced resources of the groups that answered
+	resources, err := kc.Client.Discovery().ServerPreferredNamespacedResources()
+	if len(resources) == 0 && err != nil {
+		return nil, fmt.Errorf("discovering namespaced resources: %v", err)
+	}
+	namespaced := make(map[string]bool)
+	for _, list := range resources {
+		prefix := "apis/" + list.GroupVersion
+		if !strings.Contains(list.GroupVersion, "/") {
+			prefix = "api/" + list.GroupVersion
+		}
+		for _, resource := range list.APIResources {
+			namespaced[prefix+"/"+resource.Name] = true
+		}
+	}
+
+	config, err := kc.restConfig()
+	if err != nil {
+		return nil, err
+	}
+	config = rest.CopyConfig(config)
+	config.Wrap(func(next http.RoundTripper) http.RoundTripper {
+		return &shardTransport{next: next, namespaces: shard, namespaced: namespaced}
+	})
+	return NewK8sClientForConfig(config)
+}
+
+// shardTransport turns the list calls of namespaced resources over all namespaces into a list call per
+// namespace of the shard and returns all items in a single response
+type shardTransport struct {
+	next       http.RoundTripper
+	namespaces []string
+	// namespaced are the collection paths of namespaced resources, e.g. api/v1/pods or apis/apps/v1/deployments
+	namespaced map[string]bool
+}
+
+func (t *shardTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := strings.Trim(req.URL.Path, "/")
+	if req.Method != http.MethodGet || !t.namespaced[path] || req.URL.Query().Get("watch") == "true" {
+		return t.next.RoundTrip(req)
+	}
+	// api/v1/pods becomes api/v1/namespaces/<namespace>/pods
+	split := strings.LastIndex(path, "/")
+	prefix, resource := path[:split], path[split+1:]
+
+	var list map[string]json.RawMessage
+	var resp *http.Response
+	items := []json.RawMessage{}
+	for _, namespace := range t.namespaces {
+		namespaced := req.Clone(req.Context())
+		namespaced.URL.Path = fmt.Sprintf("/%s/namespaces/%s/%s", prefix, namespace, resource)
+		namespacedResp, err := t.next.RoundTrip(namespaced)
+		if err != nil {
+			return nil, err
+		}
+		if namespacedResp.StatusCode != http.StatusOK || !strings.Contains(namespacedResp.Header.Get("Content-Type"), "json") {
+			return namespacedResp, nil
+		}
+		body, err := io.ReadAll(namespacedResp.Body)
+		namespacedResp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		namespaceList := struct {
+			Items []json.RawMessage `json:"items"`
+		}{}
+		if err := json.Unmarshal(body, &namespaceList); err != nil {
+			return nil, err
+		}
+		if list == nil {
+			resp = namespacedResp
+			if err := json.Unmarshal(body, &list); err != nil {
+				return nil, err
+			}
+		}
+		items = append(items, namespaceList.Items...)
+	}
+	if resp == nil {
+		// a shard without namespaces lists nothing, the response keeps the kind of the list
+		return t.emptyList(req)
+	}
+
+	list["items"], _ = json.Marshal(items)
+	body, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+// emptyList lists a single object of the resource to get the list of its kind and returns it without items
+func (t *shardTransport) emptyList(req *http.Request) (*http.Response, error) {
+	single := req.Clone(req.Context())
+	query := single.URL.Query()
+	query.Set("limit", "1")
+	single.URL.RawQuery = query.Encode()
+	resp, err := t.next.RoundTrip(single)
+	if err != nil || resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	list := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, err
+	}
+	metadata := map[string]json.RawMessage{}
+	json.Unmarshal(list["metadata"], &metadata)
+	delete(metadata, "continue")
+	delete(metadata, "remainingItemCount")
+	list["metadata"], _ = json.Marshal(metadata)
+	list["items"] = json.RawMessage("[]")
+	if body, err = json.Marshal(list); err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
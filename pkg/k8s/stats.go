@@ -0,0 +1,64 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// StatsSummary is the part of the kubelet /stats/summary response healthctl uses
+type StatsSummary struct {
+	Node NodeStats  `json:"node"`
+	Pods []PodStats `json:"pods"`
+}
+
+// NodeStats holds the root filesystem of the node and the filesystem of the container images
+type NodeStats struct {
+	NodeName string        `json:"nodeName"`
+	Fs       *FsStats      `json:"fs,omitempty"`
+	Runtime  *RuntimeStats `json:"runtime,omitempty"`
+}
+
+type RuntimeStats struct {
+	ImageFs     *FsStats `json:"imageFs,omitempty"`
+	ContainerFs *FsStats `json:"containerFs,omitempty"`
+}
+
+type PodStats struct {
+	PodRef struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+		UID       string `json:"uid"`
+	} `json:"podRef"`
+	// EphemeralStorage is the usage of the container writable layers, logs and emptyDir volumes
+	EphemeralStorage *FsStats `json:"ephemeral-storage,omitempty"`
+}
+
+type FsStats struct {
+	AvailableBytes *uint64 `json:"availableBytes,omitempty"`
+	CapacityBytes  *uint64 `json:"capacityBytes,omitempty"`
+	UsedBytes      *uint64 `json:"usedBytes,omitempty"`
+}
+
+// UsedPercent returns how full the filesystem is, false when the kubelet did not report it
+func (f *FsStats) UsedPercent() (uint64, bool) {
+	if f == nil || f.CapacityBytes == nil || f.AvailableBytes == nil || *f.CapacityBytes == 0 {
+		return 0, false
+	}
+	return (*f.CapacityBytes - *f.AvailableBytes) * 100 / *f.CapacityBytes, true
+}
+
+// GetNodeStatsSummary reads the stats summary of the kubelet through the node proxy of the API server
+func (kc *K8sClient) GetNodeStatsSummary(node string) (*StatsSummary, error) {
+	data, err := kc.Client.CoreV1().RESTClient().Get().
+		Resource("nodes").Name(node).SubResource("proxy", "stats", "summary").
+		DoRaw(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	summary := &StatsSummary{}
+	if err := json.Unmarshal(data, summary); err != nil {
+		return nil, fmt.Errorf("parsing stats summary of node %s: %v", node, err)
+	}
+	return summary, nil
+}
@@ -0,0 +1,243 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	veleroBackups              = schema.GroupVersionResource{Group: "velero.io", Version: "v1", Resource: "backups"}
+	veleroRestores             = schema.GroupVersionResource{Group: "velero.io", Version: "v1", Resource: "restores"}
+	veleroDeleteBackupRequests = schema.GroupVersionResource{Group: "velero.io", Version: "v1", Resource: "deletebackuprequests"}
+	veleroPollInterval         = 5 * time.Second
+	veleroTerminalPhases       = map[string]bool{"Completed": true, "PartiallyFailed": true, "Failed": true, "FailedValidation": true}
+	workloadReadyPollInterval  = 5 * time.Second
+)
+
+// VeleroBackup is a Velero backup as listed by healthctl
+type VeleroBackup struct {
+	Name               string
+	IncludedNamespaces []string
+	Phase              string
+	Completed          time.Time
+	Errors             int64
+}
+
+// CreateVeleroBackup creates a backup of a single namespace
+func (kc *K8sClient) CreateVeleroBackup(veleroNamespace, name, namespace string) error {
+	backup := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "velero.io/v1",
+		"kind":       "Backup",
+		"metadata":   map[string]interface{}{"name": name, "namespace": veleroNamespace, "labels": map[string]interface{}{"app.kubernetes.io/managed-by": "healthctl"}},
+		"spec":       map[string]interface{}{"includedNamespaces": []interface{}{namespace}, "ttl": "24h0m0s"},
+	}}
+	_, err := kc.DynamicClient.Resource(veleroBackups).Namespace(veleroNamespace).Create(context.Background(), backup, metav1.CreateOptions{})
+	return err
+}
+
+// CreateVeleroRestore restores a backup of one namespace into another namespace
+func (kc *K8sClient) CreateVeleroRestore(veleroNamespace, name, backup, from, to string) error {
+	restore := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "velero.io/v1",
+		"kind":       "Restore",
+		"metadata":   map[string]interface{}{"name": name, "namespace": veleroNamespace, "labels": map[string]interface{}{"app.kubernetes.io/managed-by": "healthctl"}},
+		"spec": map[string]interface{}{
+			"backupName":         backup,
+			"includedNamespaces": []interface{}{from},
+			"namespaceMapping":   map[string]interface{}{from: to},
+		},
+	}}
+	_, err := kc.DynamicClient.Resource(veleroRestores).Namespace(veleroNamespace).Create(context.Background(), restore, metav1.CreateOptions{})
+	return err
+}
+
+// WaitVeleroBackup waits until a backup finished and returns an error unless it completed
+func (kc *K8sClient) WaitVeleroBackup(veleroNamespace, name string, timeout time.Duration) error {
+	return kc.waitVelero(veleroBackups, veleroNamespace, name, timeout)
+}
+
+// WaitVeleroRestore waits until a restore finished and returns an error unless it completed
+func (kc *K8sClient) WaitVeleroRestore(veleroNamespace, name string, timeout time.Duration) error {
+	return kc.waitVelero(veleroRestores, veleroNamespace, name, timeout)
+}
+
+func (kc *K8sClient) waitVelero(resource schema.GroupVersionResource, veleroNamespace, name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		object, err := kc.DynamicClient.Resource(resource).Namespace(veleroNamespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		phase, _, _ := unstructured.NestedString(object.Object, "status", "phase")
+		if veleroTerminalPhases[phase] {
+			if phase != "Completed" {
+				errors, _, _ := unstructured.NestedInt64(object.Object, "status", "errors")
+				reason, _, _ := unstructured.NestedString(object.Object, "status", "failureReason")
+				return fmt.Errorf("%s %s finished %s with %d errors %s", resource.Resource, name, phase, errors, reason)
+			}
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s %s is still %q after %s", resource.Resource, name, phase, timeout)
+		}
+		time.Sleep(veleroPollInterval)
+	}
+}
+
+// DeleteVeleroBackup deletes a restore and asks Velero to delete the backup and its data in the object store
+func (kc *K8sClient) DeleteVeleroBackup(veleroNamespace, backup, restore string) error {
+	if restore != "" {
+		err := kc.DynamicClient.Resource(veleroRestores).Namespace(veleroNamespace).Delete(context.Background(), restore, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	request := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "velero.io/v1",
+		"kind":       "DeleteBackupRequest",
+		"metadata":   map[string]interface{}{"generateName": backup + "-", "namespace": veleroNamespace},
+		"spec":       map[string]interface{}{"backupName": backup},
+	}}
+	_, err := kc.DynamicClient.Resource(veleroDeleteBackupRequests).Namespace(veleroNamespace).Create(context.Background(), request, metav1.CreateOptions{})
+	return err
+}
+
+// GetVeleroBackups returns the backups of Velero, newest first
+func GetVeleroBackups(client *kubernetes.Clientset, veleroNamespace string) ([]VeleroBackup, error) {
+	data, err := client.Discovery().RESTClient().Get().AbsPath("/apis/velero.io/v1/namespaces/" + veleroNamespace + "/backups").DoRaw(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	list := struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Spec struct {
+				IncludedNamespaces []string `json:"includedNamespaces"`
+			} `json:"spec"`
+			Status struct {
+				Phase               string    `json:"phase"`
+				CompletionTimestamp time.Time `json:"completionTimestamp"`
+				Errors              int64     `json:"errors"`
+			} `json:"status"`
+		} `json:"items"`
+	}{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	backups := []VeleroBackup{}
+	for _, item := range list.Items {
+		backups = append(backups, VeleroBackup{
+			Name:               item.Metadata.Name,
+			IncludedNamespaces: item.Spec.IncludedNamespaces,
+			Phase:              item.Status.Phase,
+			Completed:          item.Status.CompletionTimestamp,
+			Errors:             item.Status.Errors,
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Completed.After(backups[j].Completed) })
+	return backups, nil
+}
+
+// Includes returns true when the backup contains the namespace
+func (b VeleroBackup) Includes(namespace string) bool {
+	if len(b.IncludedNamespaces) == 0 {
+		return true
+	}
+	for _, included := range b.IncludedNamespaces {
+		if included == "*" || included == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitWorkloadsReady waits until every Deployment, StatefulSet and DaemonSet of a namespace has all replicas
+// ready and returns the number of workloads, or an error naming the workloads that did not become ready
+func (kc *K8sClient) WaitWorkloadsReady(namespace string, timeout time.Duration) (int, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		workloads, notReady, err := kc.GetWorkloadReadiness(namespace)
+		if err != nil {
+			return 0, err
+		}
+		if len(notReady) == 0 {
+			return workloads, nil
+		}
+		if time.Now().After(deadline) {
+			return workloads, fmt.Errorf("%d of %d workloads not ready after %s: %v", len(notReady), workloads, timeout, notReady)
+		}
+		time.Sleep(workloadReadyPollInterval)
+	}
+}
+
+// GetWorkloadReadiness returns the number of Deployments, StatefulSets and DaemonSets of a namespace and
+// the workloads that do not have all replicas ready
+func (kc *K8sClient) GetWorkloadReadiness(namespace string) (int, []string, error) {
+	ctx := context.Background()
+	deployments, err := kc.Client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, nil, err
+	}
+	statefulsets, err := kc.Client.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, nil, err
+	}
+	daemonsets, err := kc.Client.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, nil, err
+	}
+	notReady := []string{}
+	for _, d := range deployments.Items {
+		if d.Status.ReadyReplicas < replicas(d.Spec.Replicas) {
+			notReady = append(notReady, "Deployment/"+d.Name)
+		}
+	}
+	for _, s := range statefulsets.Items {
+		if s.Status.ReadyReplicas < replicas(s.Spec.Replicas) {
+			notReady = append(notReady, "StatefulSet/"+s.Name)
+		}
+	}
+	for _, d := range daemonsets.Items {
+		if d.Status.NumberReady < d.Status.DesiredNumberScheduled {
+			notReady = append(notReady, "DaemonSet/"+d.Name)
+		}
+	}
+	return len(deployments.Items) + len(statefulsets.Items) + len(daemonsets.Items), notReady, nil
+}
+
+func replicas(r *int32) int32 {
+	if r == nil {
+		return 1
+	}
+	return *r
+}
+
+// DeleteNamespace deletes a namespace and waits until it is gone
+func (kc *K8sClient) DeleteNamespace(namespace string, timeout time.Duration) error {
+	err := kc.Client.CoreV1().Namespaces().Delete(context.Background(), namespace, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if _, err := kc.Client.CoreV1().Namespaces().Get(context.Background(), namespace, metav1.GetOptions{}); apierrors.IsNotFound(err) {
+			return nil
+		}
+		time.Sleep(workloadReadyPollInterval)
+	}
+	return fmt.Errorf("namespace %s is still terminating after %s", namespace, timeout)
+}
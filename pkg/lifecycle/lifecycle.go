@@ -0,0 +1,260 @@
+// Package lifecycle measures how long pods take from creation to ready and keeps the startups of every
+// workload between runs, to tell when the startup of a workload degraded
+package lifecycle
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"healthctl/pkg/k8s"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// retention is how long startups are kept
+const retention = 30 * 24 * time.Hour
+
+// minIncrease is how much slower a degraded startup must be at least, so startups of a few seconds do not
+// count as degraded when they vary by a second
+const minIncrease = 10 * time.Second
+
+// maxStartups bounds the startups kept per workload, the newest are kept
+const maxStartups = 500
+
+// Startup is how long a pod took to become ready, split into its phases: waiting to be scheduled,
+// pulling the images and from the start of the containers until the readiness probe passed
+type Startup struct {
+	Pod        string        `json:"pod"`
+	UID        string        `json:"uid"`
+	Created    time.Time     `json:"created"`
+	Scheduling time.Duration `json:"scheduling"`
+	ImagePull  time.Duration `json:"imagePull"`
+	// PullUnknown is set when the events of the pod expired before it was measured, ImagePull is 0 then
+	PullUnknown bool          `json:"pullUnknown,omitempty"`
+	Readiness   time.Duration `json:"readiness"`
+	Total       time.Duration `json:"total"`
+}
+
+// Measure returns the startup of a ready pod from its conditions, the start of its containers and the
+// Pulling and Pulled events of its images. Pods whose containers restarted are left out, their Ready
+// condition is from the last restart.
+func Measure(pod v1.Pod, events []v1.Event) (Startup, bool) {
+	scheduled, ready := condition(pod, v1.PodScheduled), condition(pod, v1.PodReady)
+	if scheduled == nil || ready == nil || ready.Status != v1.ConditionTrue {
+		return Startup{}, false
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.RestartCount > 0 {
+			return Startup{}, false
+		}
+	}
+	created := pod.CreationTimestamp.Time
+	pull, known := imagePull(pod, events)
+	startup := Startup{
+		Pod:         pod.Name,
+		UID:         string(pod.UID),
+		Created:     created,
+		Scheduling:  nonNegative(scheduled.LastTransitionTime.Sub(created)),
+		ImagePull:   pull,
+		PullUnknown: !known,
+		Total:       nonNegative(ready.LastTransitionTime.Sub(created)),
+	}
+	if started, ok := containersStarted(pod); ok {
+		startup.Readiness = nonNegative(ready.LastTransitionTime.Sub(started))
+	} else {
+		startup.Readiness = nonNegative(startup.Total - startup.Scheduling - startup.ImagePull)
+	}
+	return startup, true
+}
+
+// containersStarted returns when the last container of the pod started running
+func containersStarted(pod v1.Pod) (time.Time, bool) {
+	var started time.Time
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Running == nil {
+			return time.Time{}, false
+		}
+		if at := status.State.Running.StartedAt.Time; at.After(started) {
+			started = at
+		}
+	}
+	return started, !started.IsZero()
+}
+
+func condition(pod v1.Pod, conditionType v1.PodConditionType) *v1.PodCondition {
+	for i := range pod.Status.Conditions {
+		if pod.Status.Conditions[i].Type == conditionType {
+			return &pod.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// imagePull returns the time from the first Pulling to the last Pulled event of the pod, 0 when the
+// images were present on the node. The kubelet records a Pulled event for present images as well, without
+// one the events expired and the pull time is unknown.
+func imagePull(pod v1.Pod, events []v1.Event) (time.Duration, bool) {
+	var pulling, pulled time.Time
+	for _, event := range events {
+		if event.InvolvedObject.UID != pod.UID {
+			continue
+		}
+		at := k8s.EventTime(event)
+		switch event.Reason {
+		case "Pulling":
+			if pulling.IsZero() || at.Before(pulling) {
+				pulling = at
+			}
+		case "Pulled":
+			if at.After(pulled) {
+				pulled = at
+			}
+		}
+	}
+	if pulled.IsZero() {
+		return 0, false
+	}
+	if pulling.IsZero() {
+		return 0, true
+	}
+	return nonNegative(pulled.Sub(pulling)), true
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	return max(d, 0)
+}
+
+// Store keeps the startups of every workload between runs, keyed by workload like Deployment/payments/api
+type Store map[string][]Startup
+
+// Load reads the startup store, a missing file returns an empty store
+func Load(file string) (Store, error) {
+	store := Store{}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Record adds the startup of a pod of the workload, a pod is only recorded once. Startups older than the
+// retention are dropped.
+func (s Store) Record(workload string, startup Startup, now time.Time) bool {
+	for _, recorded := range s[workload] {
+		if recorded.UID == startup.UID {
+			return false
+		}
+	}
+	startups := append(s[workload], startup)
+	sort.Slice(startups, func(i, j int) bool { return startups[i].Created.Before(startups[j].Created) })
+	cutoff := now.Add(-retention)
+	for len(startups) > 0 && startups[0].Created.Before(cutoff) {
+		startups = startups[1:]
+	}
+	if len(startups) > maxStartups {
+		startups = startups[len(startups)-maxStartups:]
+	}
+	s[workload] = startups
+	return true
+}
+
+// Save writes the startup store, creating the state directory when needed
+func (s Store) Save(file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// Distribution are percentiles of a startup phase
+type Distribution struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	Max time.Duration `json:"max"`
+}
+
+// Distribute returns the percentiles of a phase of the startups
+func Distribute(startups []Startup, phase func(Startup) time.Duration) Distribution {
+	if len(startups) == 0 {
+		return Distribution{}
+	}
+	values := make([]time.Duration, len(startups))
+	for i, startup := range startups {
+		values[i] = phase(startup)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	percentile := func(p int) time.Duration {
+		return values[(len(values)-1)*p/100]
+	}
+	return Distribution{P50: percentile(50), P90: percentile(90), Max: values[len(values)-1]}
+}
+
+// Summary is the startup distribution of a workload, the recent startups compared with the ones before
+type Summary struct {
+	Workload   string       `json:"workload"`
+	Pods       int          `json:"pods"`
+	Scheduling Distribution `json:"scheduling"`
+	ImagePull  Distribution `json:"imagePull"`
+	Readiness  Distribution `json:"readiness"`
+	Total      Distribution `json:"total"`
+	// Recent and Baseline are the median total startup of the pods created since the start of the recent
+	// window and before it
+	Recent   time.Duration `json:"recent,omitempty"`
+	Baseline time.Duration `json:"baseline,omitempty"`
+	Degraded bool          `json:"degraded,omitempty"`
+}
+
+// pullsKnown returns the startups whose image pull time is known
+func pullsKnown(startups []Startup) []Startup {
+	known := []Startup{}
+	for _, startup := range startups {
+		if !startup.PullUnknown {
+			known = append(known, startup)
+		}
+	}
+	return known
+}
+
+// Summarize returns the startup distribution of a workload. It is degraded when the median startup of the
+// pods created since recent is factor times the median before and at least 10s slower, both need at least
+// minPods startups.
+func Summarize(workload string, startups []Startup, recent time.Time, factor float64, minPods int) Summary {
+	summary := Summary{
+		Workload:   workload,
+		Pods:       len(startups),
+		Scheduling: Distribute(startups, func(s Startup) time.Duration { return s.Scheduling }),
+		ImagePull:  Distribute(pullsKnown(startups), func(s Startup) time.Duration { return s.ImagePull }),
+		Readiness:  Distribute(startups, func(s Startup) time.Duration { return s.Readiness }),
+		Total:      Distribute(startups, func(s Startup) time.Duration { return s.Total }),
+	}
+	before, since := []Startup{}, []Startup{}
+	for _, startup := range startups {
+		if startup.Created.Before(recent) {
+			before = append(before, startup)
+		} else {
+			since = append(since, startup)
+		}
+	}
+	if len(before) < minPods || len(since) < minPods {
+		return summary
+	}
+	total := func(s Startup) time.Duration { return s.Total }
+	summary.Recent = Distribute(since, total).P50
+	summary.Baseline = Distribute(before, total).P50
+	summary.Degraded = summary.Baseline > 0 && float64(summary.Recent) >= factor*float64(summary.Baseline) &&
+		summary.Recent-summary.Baseline >= minIncrease
+	return summary
+}
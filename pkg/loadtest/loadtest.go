@@ -0,0 +1,257 @@
+// Package loadtest drives HTTP load against ingresses and services in steps while the check suites run,
+// to tell at which load level the cluster starts to degrade.
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"healthctl/pkg/models"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// maxInFlight limits the concurrent requests, a target slower than the rate does not pile up goroutines
+const maxInFlight = 1000
+
+// requestTimeout is the timeout of every request, requests that are not sent because too many are in
+// flight count with it as latency
+const requestTimeout = 10 * time.Second
+
+// Target is a URL the load is sent to
+type Target struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// client sends the requests of targets that need the credentials of the kubeconfig, like services
+	// reached through the API server proxy
+	client *http.Client
+}
+
+// Plan ramps the rate linearly up to RPS in Steps steps of StepDuration, the checks run every CheckInterval
+type Plan struct {
+	RPS           int
+	Steps         int
+	StepDuration  time.Duration
+	CheckInterval time.Duration
+}
+
+// Step is the load and the health of the cluster during one step of the plan
+type Step struct {
+	RPS         int           `json:"rps"`
+	AchievedRPS float64       `json:"achievedRPS"`
+	Requests    int           `json:"requests"`
+	Errors      int           `json:"errors"`
+	P50         time.Duration `json:"p50"`
+	P95         time.Duration `json:"p95"`
+	P99         time.Duration `json:"p99"`
+	// NewFailures are the checks that failed during the step but passed before the load started
+	NewFailures []string `json:"newFailures,omitempty"`
+}
+
+// ErrorPercent returns the share of failed requests
+func (s Step) ErrorPercent() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Errors) * 100 / float64(s.Requests)
+}
+
+// Report is the result of a load test
+type Report struct {
+	Targets []Target  `json:"targets"`
+	Started time.Time `json:"started"`
+	// FailingBefore are the checks that already failed before the load started
+	FailingBefore []string `json:"failingBefore,omitempty"`
+	Steps         []Step   `json:"steps"`
+	// DegradedAt is the rate of the first step with new check failures or more than 1% errors, 0 if none
+	DegradedAt int `json:"degradedAt"`
+}
+
+// ResolveTarget turns a URL, ingress/<namespace>/<name> or service/<namespace>/<name>:<port> into a target.
+// Ingresses are addressed by the host of their first rule. Services are reached through the API server
+// proxy, their cluster DNS name does not resolve where healthctl runs; the proxy picks an endpoint per
+// request like the service does, but the API server carries the load as well.
+func ResolveTarget(clientset *kubernetes.Clientset, spec string) (Target, error) {
+	if strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://") {
+		return Target{Name: spec, URL: spec}, nil
+	}
+	kind, object, _ := strings.Cut(spec, "/")
+	namespace, name, found := strings.Cut(object, "/")
+	if !found {
+		return Target{}, fmt.Errorf("invalid target %q, use a URL, ingress/<namespace>/<name> or service/<namespace>/<name>:<port>", spec)
+	}
+	switch kind {
+	case "ingress":
+		ingress, err := clientset.NetworkingV1().Ingresses(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return Target{}, err
+		}
+		if len(ingress.Spec.Rules) == 0 || ingress.Spec.Rules[0].Host == "" {
+			return Target{}, fmt.Errorf("ingress %s/%s has no host", namespace, name)
+		}
+		rule := ingress.Spec.Rules[0]
+		scheme := "http"
+		if len(ingress.Spec.TLS) > 0 {
+			scheme = "https"
+		}
+		path := "/"
+		if rule.HTTP != nil && len(rule.HTTP.Paths) > 0 && rule.HTTP.Paths[0].Path != "" {
+			path = rule.HTTP.Paths[0].Path
+		}
+		return Target{Name: spec, URL: fmt.Sprintf("%s://%s%s", scheme, rule.Host, path)}, nil
+	case "service":
+		service, port, found := strings.Cut(name, ":")
+		if !found {
+			return Target{}, fmt.Errorf("service target %q needs a port", spec)
+		}
+		restClient, ok := clientset.CoreV1().RESTClient().(*rest.RESTClient)
+		if !ok || restClient.Client == nil {
+			return Target{}, fmt.Errorf("service target %q needs the API server proxy", spec)
+		}
+		if _, err := clientset.CoreV1().Services(namespace).Get(context.Background(), service, metav1.GetOptions{}); err != nil {
+			return Target{}, err
+		}
+		client := *restClient.Client
+		client.Timeout = requestTimeout
+		proxy := restClient.Get().Namespace(namespace).Resource("services").Name(service + ":" + port).SubResource("proxy").URL()
+		return Target{Name: spec, URL: proxy.String() + "/", client: &client}, nil
+	}
+	return Target{}, fmt.Errorf("unknown target kind %q", kind)
+}
+
+// Run ramps up the load step by step and runs check during every step, the checks that newly fail are
+// recorded with the load level they started failing at
+func Run(targets []Target, plan Plan, check func() []models.CheckResult) Report {
+	report := Report{Targets: targets, Started: time.Now()}
+	failingBefore := failing(check())
+	for checkName := range failingBefore {
+		report.FailingBefore = append(report.FailingBefore, checkName)
+	}
+	sort.Strings(report.FailingBefore)
+
+	for i := 1; i <= plan.Steps; i++ {
+		rps := max(plan.RPS*i/plan.Steps, 1)
+		ctx, cancel := context.WithTimeout(context.Background(), plan.StepDuration)
+		loadDone := make(chan Step)
+		go func() { loadDone <- generate(ctx, targets, rps) }()
+
+		newFailures := make(map[string]bool)
+		ticker := time.NewTicker(plan.CheckInterval)
+	checks:
+		for {
+			for checkName := range failing(check()) {
+				if !failingBefore[checkName] {
+					newFailures[checkName] = true
+				}
+			}
+			select {
+			case <-ctx.Done():
+				break checks
+			case <-ticker.C:
+			}
+		}
+		ticker.Stop()
+		cancel()
+
+		step := <-loadDone
+		step.RPS = rps
+		for checkName := range newFailures {
+			step.NewFailures = append(step.NewFailures, checkName)
+		}
+		sort.Strings(step.NewFailures)
+		if report.DegradedAt == 0 && (len(step.NewFailures) > 0 || step.ErrorPercent() > 1) {
+			report.DegradedAt = rps
+		}
+		report.Steps = append(report.Steps, step)
+	}
+	return report
+}
+
+func failing(results []models.CheckResult) map[string]bool {
+	names := make(map[string]bool)
+	for _, result := range results {
+		if result.Result == models.ResultFail || result.Result == models.ResultError {
+			names[result.Check] = true
+		}
+	}
+	return names
+}
+
+// generate sends rps requests per second round robin over the targets until the context is done
+func generate(ctx context.Context, targets []Target, rps int) Step {
+	client := &http.Client{Timeout: requestTimeout}
+	var (
+		mutex     sync.Mutex
+		latencies []time.Duration
+		errors    int
+		wg        sync.WaitGroup
+	)
+	inFlight := make(chan struct{}, maxInFlight)
+	ticker := time.NewTicker(time.Second / time.Duration(rps))
+	defer ticker.Stop()
+	started := time.Now()
+	for i := 0; ; i++ {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			elapsed := time.Since(started).Seconds()
+			sort.Slice(latencies, func(a, b int) bool { return latencies[a] < latencies[b] })
+			return Step{
+				AchievedRPS: float64(len(latencies)) / elapsed,
+				Requests:    len(latencies),
+				Errors:      errors,
+				P50:         percentile(latencies, 50),
+				P95:         percentile(latencies, 95),
+				P99:         percentile(latencies, 99),
+			}
+		case <-ticker.C:
+		}
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			// the targets can not keep up, the request is counted as failed
+			mutex.Lock()
+			latencies = append(latencies, requestTimeout)
+			errors++
+			mutex.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(target Target) {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+			start := time.Now()
+			failed := true
+			targetClient := client
+			if target.client != nil {
+				targetClient = target.client
+			}
+			if resp, err := targetClient.Get(target.URL); err == nil {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				failed = resp.StatusCode >= 500
+			}
+			mutex.Lock()
+			latencies = append(latencies, time.Since(start))
+			if failed {
+				errors++
+			}
+			mutex.Unlock()
+		}(targets[i%len(targets)])
+	}
+}
+
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[(len(sorted)-1)*p/100]
+}
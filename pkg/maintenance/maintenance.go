@@ -0,0 +1,121 @@
+// Package maintenance tells whether a cluster or namespace is in one of the configured maintenance windows,
+// during which findings are recorded but not notified about
+package maintenance
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/cron"
+	"healthctl/pkg/models"
+)
+
+// maxDuration bounds how far back the start of a window is searched
+const maxDuration = 7 * 24 * time.Hour
+
+// Window is a parsed maintenance window
+type Window struct {
+	Name       string
+	Schedule   cron.Schedule
+	Duration   time.Duration
+	Location   *time.Location
+	Clusters   []string
+	Namespaces []string
+}
+
+// Calendar holds the maintenance windows of the configuration, a nil calendar has no windows
+type Calendar struct {
+	Windows []Window
+}
+
+// New parses the maintenance windows, nil is returned without windows
+func New(windows []config.MaintenanceWindow) (*Calendar, error) {
+	if len(windows) == 0 {
+		return nil, nil
+	}
+	calendar := &Calendar{}
+	for i, cfg := range windows {
+		window, err := parseWindow(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance[%d] %s: %v", i, cfg.Name, err)
+		}
+		calendar.Windows = append(calendar.Windows, window)
+	}
+	return calendar, nil
+}
+
+func parseWindow(cfg config.MaintenanceWindow) (Window, error) {
+	window := Window{Name: cfg.Name, Location: time.UTC, Clusters: cfg.Clusters, Namespaces: cfg.Namespaces}
+	var err error
+	if window.Schedule, err = cron.Parse(cfg.Schedule); err != nil {
+		return Window{}, err
+	}
+	if window.Duration, err = time.ParseDuration(cfg.Duration); err != nil {
+		return Window{}, fmt.Errorf("invalid duration %q: %v", cfg.Duration, err)
+	}
+	if window.Duration <= 0 || window.Duration > maxDuration {
+		return Window{}, fmt.Errorf("duration %s is not between 1m and %s", cfg.Duration, maxDuration)
+	}
+	if cfg.Timezone != "" {
+		if window.Location, err = time.LoadLocation(cfg.Timezone); err != nil {
+			return Window{}, fmt.Errorf("invalid timezone %q: %v", cfg.Timezone, err)
+		}
+	}
+	return window, nil
+}
+
+// Open returns true when a window started less than its duration before t
+func (w Window) Open(t time.Time) bool {
+	start := t.In(w.Location).Truncate(time.Minute)
+	for elapsed := time.Duration(0); elapsed < w.Duration; elapsed += time.Minute {
+		if w.Schedule.Matches(start.Add(-elapsed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// covers returns true when the window applies to the namespace of the cluster, cluster scoped findings
+// are only covered by windows of the whole cluster
+func (w Window) covers(cluster, namespace string) bool {
+	if len(w.Clusters) > 0 && !slices.Contains(w.Clusters, cluster) {
+		return false
+	}
+	return len(w.Namespaces) == 0 || slices.Contains(w.Namespaces, namespace)
+}
+
+// Active returns the open window of the namespace of the cluster at t, an empty namespace stands for the
+// whole cluster
+func (c *Calendar) Active(cluster, namespace string, t time.Time) (Window, bool) {
+	if c == nil {
+		return Window{}, false
+	}
+	for _, window := range c.Windows {
+		if window.covers(cluster, namespace) && window.Open(t) {
+			return window, true
+		}
+	}
+	return Window{}, false
+}
+
+// Muted returns true when the finding is in an open maintenance window of the cluster at t
+func (c *Calendar) Muted(cluster string, finding models.Finding, t time.Time) bool {
+	_, muted := c.Active(cluster, finding.Resource.Namespace, t)
+	return muted
+}
+
+// Unmuted returns the findings that are not in an open maintenance window of the cluster at t
+func (c *Calendar) Unmuted(cluster string, result []models.Finding, t time.Time) []models.Finding {
+	if c == nil {
+		return result
+	}
+	unmuted := []models.Finding{}
+	for _, finding := range result {
+		if !c.Muted(cluster, finding, t) {
+			unmuted = append(unmuted, finding)
+		}
+	}
+	return unmuted
+}
@@ -0,0 +1,421 @@
+// Package migration inventories a namespace before it is moved to another cluster and verifies the target
+// cluster has what the namespace needs: its storage classes, the secrets it references, its priority classes
+// and enough quota. Nothing is created in either cluster.
+package migration
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Check names the findings of the verification
+const Check = "migrate/plan"
+
+// defaultClassAnnotation marks the default storage class, claims without a class get it
+const defaultClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+
+var (
+	// urlEndpoint finds the host and port of URLs, including jdbc:postgresql:// and credentials before the host
+	urlEndpoint = regexp.MustCompile(`(?i)[a-z][a-z0-9+.-]*://(?:[^@/\s]*@)?([a-z0-9.-]+)(:\d+)?`)
+	// hostPort is a value like db.example.com:5432
+	hostPort = regexp.MustCompile(`(?i)^([a-z0-9-]+(?:\.[a-z0-9-]+)+):(\d+)$`)
+)
+
+// quotaResources are the quota resources the namespace is measured against, the usage of the running pods
+// and the claims
+var quotaResources = []v1.ResourceName{
+	v1.ResourcePods, v1.ResourceRequestsCPU, v1.ResourceRequestsMemory, v1.ResourceLimitsCPU, v1.ResourceLimitsMemory,
+	v1.ResourceCPU, v1.ResourceMemory, v1.ResourcePersistentVolumeClaims, v1.ResourceRequestsStorage,
+}
+
+// Workload is a workload of the namespace
+type Workload struct {
+	Resource models.ResourceRef `json:"resource"`
+	Replicas *int32             `json:"replicas,omitempty"`
+	Images   []string           `json:"images"`
+}
+
+// Claim is a persistent volume claim of the namespace
+type Claim struct {
+	Name         string   `json:"name"`
+	StorageClass string   `json:"storageClass,omitempty"`
+	Size         string   `json:"size"`
+	AccessModes  []string `json:"accessModes"`
+}
+
+// Dependency is an endpoint outside the cluster found in the environment variables of a container, the
+// target cluster must reach it as well
+type Dependency struct {
+	Workload  models.ResourceRef `json:"workload"`
+	Container string             `json:"container"`
+	Variable  string             `json:"variable"`
+	Endpoint  string             `json:"endpoint"`
+}
+
+// Inventory is what a namespace consists of and needs
+type Inventory struct {
+	Namespace    string       `json:"namespace"`
+	Workloads    []Workload   `json:"workloads"`
+	ConfigMaps   []string     `json:"configMaps"`
+	Secrets      []string     `json:"secrets"`
+	Claims       []Claim      `json:"claims"`
+	Dependencies []Dependency `json:"dependencies"`
+	// Usage is the usage of the quota resources by the running pods and the claims
+	Usage map[v1.ResourceName]string `json:"usage"`
+
+	// SecretKeys are the secrets the workloads reference and the keys they read, nil when the whole secret
+	// is used. ConfigMapKeys are the same for config maps.
+	SecretKeys      map[string][]string `json:"-"`
+	ConfigMapKeys   map[string][]string `json:"-"`
+	PriorityClasses []string            `json:"priorityClasses,omitempty"`
+
+	usage v1.ResourceList
+}
+
+// Collect inventories a namespace
+func Collect(kc *k8s.K8sClient, namespace string) (Inventory, error) {
+	inventory := Inventory{Namespace: namespace, Workloads: []Workload{}, ConfigMaps: []string{}, Secrets: []string{}, Claims: []Claim{},
+		Dependencies: []Dependency{}, SecretKeys: make(map[string][]string), ConfigMapKeys: make(map[string][]string), usage: v1.ResourceList{}}
+	ctx := context.Background()
+	if _, err := kc.Client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{}); err != nil {
+		return inventory, err
+	}
+
+	apps := kc.Client.AppsV1()
+	deployments, err := apps.Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return inventory, err
+	}
+	for _, deployment := range deployments.Items {
+		inventory.addWorkload("Deployment", deployment.Name, deployment.Spec.Replicas, deployment.Spec.Template.Spec)
+	}
+	statefulSets, err := apps.StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return inventory, err
+	}
+	for _, statefulSet := range statefulSets.Items {
+		inventory.addWorkload("StatefulSet", statefulSet.Name, statefulSet.Spec.Replicas, statefulSet.Spec.Template.Spec)
+	}
+	daemonSets, err := apps.DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return inventory, err
+	}
+	for _, daemonSet := range daemonSets.Items {
+		inventory.addWorkload("DaemonSet", daemonSet.Name, nil, daemonSet.Spec.Template.Spec)
+	}
+	cronJobs, err := kc.Client.BatchV1().CronJobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return inventory, err
+	}
+	for _, cronJob := range cronJobs.Items {
+		inventory.addWorkload("CronJob", cronJob.Name, nil, cronJob.Spec.JobTemplate.Spec.Template.Spec)
+	}
+
+	configMaps, err := kc.Client.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return inventory, err
+	}
+	for _, configMap := range configMaps.Items {
+		inventory.ConfigMaps = append(inventory.ConfigMaps, configMap.Name)
+	}
+	secrets, err := kc.Client.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return inventory, err
+	}
+	for _, secret := range secrets.Items {
+		// service account tokens are created by the target cluster itself
+		if secret.Type != v1.SecretTypeServiceAccountToken {
+			inventory.Secrets = append(inventory.Secrets, secret.Name)
+		}
+	}
+
+	claims, err := kc.Client.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return inventory, err
+	}
+	for _, pvc := range claims.Items {
+		claim := Claim{Name: pvc.Name, AccessModes: []string{}}
+		if pvc.Spec.StorageClassName != nil {
+			claim.StorageClass = *pvc.Spec.StorageClassName
+		}
+		size := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+		claim.Size = size.String()
+		for _, mode := range pvc.Spec.AccessModes {
+			claim.AccessModes = append(claim.AccessModes, string(mode))
+		}
+		inventory.Claims = append(inventory.Claims, claim)
+		add(inventory.usage, v1.ResourcePersistentVolumeClaims, *resource.NewQuantity(1, resource.DecimalSI))
+		add(inventory.usage, v1.ResourceRequestsStorage, size)
+	}
+
+	pods, err := kc.Client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return inventory, err
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		add(inventory.usage, v1.ResourcePods, *resource.NewQuantity(1, resource.DecimalSI))
+		for _, container := range pod.Spec.Containers {
+			for name, quantity := range container.Resources.Requests {
+				add(inventory.usage, "requests."+name, quantity)
+				if name == v1.ResourceCPU || name == v1.ResourceMemory {
+					add(inventory.usage, name, quantity)
+				}
+			}
+			for name, quantity := range container.Resources.Limits {
+				add(inventory.usage, "limits."+name, quantity)
+			}
+		}
+	}
+	inventory.Usage = make(map[v1.ResourceName]string)
+	for name, quantity := range inventory.usage {
+		inventory.Usage[name] = quantity.String()
+	}
+	slices.Sort(inventory.PriorityClasses)
+	inventory.PriorityClasses = slices.Compact(inventory.PriorityClasses)
+	return inventory, nil
+}
+
+func add(list v1.ResourceList, name v1.ResourceName, quantity resource.Quantity) {
+	sum := list[name]
+	sum.Add(quantity)
+	list[name] = sum
+}
+
+// addWorkload adds a workload with the secrets, config maps, priority class and external endpoints of its
+// pod template
+func (inventory *Inventory) addWorkload(kind, name string, replicas *int32, spec v1.PodSpec) {
+	ref := models.ResourceRef{Kind: kind, Namespace: inventory.Namespace, Name: name}
+	workload := Workload{Resource: ref, Replicas: replicas, Images: []string{}}
+	for _, secret := range spec.ImagePullSecrets {
+		inventory.reference(inventory.SecretKeys, secret.Name, "", false)
+	}
+	if spec.PriorityClassName != "" {
+		inventory.PriorityClasses = append(inventory.PriorityClasses, spec.PriorityClassName)
+	}
+	for _, volume := range spec.Volumes {
+		switch {
+		case volume.Secret != nil:
+			inventory.reference(inventory.SecretKeys, volume.Secret.SecretName, "", isTrue(volume.Secret.Optional))
+		case volume.ConfigMap != nil:
+			inventory.reference(inventory.ConfigMapKeys, volume.ConfigMap.Name, "", isTrue(volume.ConfigMap.Optional))
+		case volume.Projected != nil:
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil {
+					inventory.reference(inventory.SecretKeys, source.Secret.Name, "", isTrue(source.Secret.Optional))
+				}
+				if source.ConfigMap != nil {
+					inventory.reference(inventory.ConfigMapKeys, source.ConfigMap.Name, "", isTrue(source.ConfigMap.Optional))
+				}
+			}
+		}
+	}
+	for _, container := range append(slices.Clone(spec.InitContainers), spec.Containers...) {
+		workload.Images = append(workload.Images, container.Image)
+		for _, from := range container.EnvFrom {
+			if from.SecretRef != nil {
+				inventory.reference(inventory.SecretKeys, from.SecretRef.Name, "", isTrue(from.SecretRef.Optional))
+			}
+			if from.ConfigMapRef != nil {
+				inventory.reference(inventory.ConfigMapKeys, from.ConfigMapRef.Name, "", isTrue(from.ConfigMapRef.Optional))
+			}
+		}
+		for _, env := range container.Env {
+			if from := env.ValueFrom; from != nil {
+				if from.SecretKeyRef != nil {
+					inventory.reference(inventory.SecretKeys, from.SecretKeyRef.Name, from.SecretKeyRef.Key, isTrue(from.SecretKeyRef.Optional))
+				}
+				if from.ConfigMapKeyRef != nil {
+					inventory.reference(inventory.ConfigMapKeys, from.ConfigMapKeyRef.Name, from.ConfigMapKeyRef.Key, isTrue(from.ConfigMapKeyRef.Optional))
+				}
+				continue
+			}
+			if endpoint := ExternalEndpoint(env.Value); endpoint != "" {
+				inventory.Dependencies = append(inventory.Dependencies, Dependency{Workload: ref, Container: container.Name, Variable: env.Name, Endpoint: endpoint})
+			}
+		}
+	}
+	inventory.Workloads = append(inventory.Workloads, workload)
+}
+
+// reference records that a secret or config map, or one of its keys, is used. Optional references are
+// skipped, the workload starts without them.
+func (inventory *Inventory) reference(refs map[string][]string, name, key string, optional bool) {
+	if optional || name == "" {
+		return
+	}
+	keys, found := refs[name]
+	switch {
+	case found && keys == nil:
+		// the whole object is used already
+	case key == "":
+		refs[name] = nil
+	case !slices.Contains(keys, key):
+		refs[name] = append(keys, key)
+	}
+}
+
+func isTrue(value *bool) bool {
+	return value != nil && *value
+}
+
+// ExternalEndpoint returns the host and port of a URL or host:port value pointing outside the cluster, an
+// empty string for other values and in-cluster services. Credentials in URLs are left out.
+func ExternalEndpoint(value string) string {
+	host, port := "", ""
+	if match := urlEndpoint.FindStringSubmatch(value); match != nil {
+		host, port = match[1], match[2]
+	} else if match := hostPort.FindStringSubmatch(strings.TrimSpace(value)); match != nil {
+		host, port = match[1], ":"+match[2]
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" || !strings.Contains(host, ".") || host == "127.0.0.1" || strings.HasSuffix(host, ".svc") ||
+		strings.Contains(host, ".svc.") || strings.HasSuffix(host, ".cluster.local") {
+		return ""
+	}
+	return host + port
+}
+
+// Verify checks the target namespace of the target cluster against the inventory and returns the blockers
+// as critical findings, the config maps to bring along as warnings and the external dependencies as info
+func Verify(target *k8s.K8sClient, inventory Inventory, namespace string) ([]models.Finding, error) {
+	ctx := context.Background()
+	findings := []models.Finding{}
+	finding := func(kind, name, reason string, severity models.Severity, format string, args ...interface{}) {
+		ref := models.ResourceRef{Kind: kind, Namespace: namespace, Name: name}
+		if kind == "StorageClass" || kind == "PriorityClass" {
+			ref.Namespace = ""
+		}
+		f := models.Finding{Check: Check, Resource: ref, Reason: reason, Severity: severity, Message: fmt.Sprintf(format, args...)}
+		f.ID = models.FindingID(f.Check, f.Resource, f.Reason)
+		findings = append(findings, f)
+	}
+
+	exists := true
+	if _, err := target.Client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{}); apierrors.IsNotFound(err) {
+		exists = false
+		finding("Namespace", namespace, "NamespaceMissing", models.SeverityInfo, "namespace %s does not exist in the target cluster yet, it has no quota to check", namespace)
+	} else if err != nil {
+		return nil, err
+	}
+
+	classes, err := target.Client.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	available, defaultClass := make(map[string]bool), ""
+	for _, class := range classes.Items {
+		available[class.Name] = true
+		if class.Annotations[defaultClassAnnotation] == "true" {
+			defaultClass = class.Name
+		}
+	}
+	for _, claim := range inventory.Claims {
+		switch {
+		case claim.StorageClass == "" && defaultClass == "":
+			finding("PersistentVolumeClaim", claim.Name, "NoDefaultStorageClass", models.SeverityCritical,
+				"claim %s (%s) has no storage class and the target cluster has no default storage class", claim.Name, claim.Size)
+		case claim.StorageClass != "" && !available[claim.StorageClass]:
+			finding("StorageClass", claim.StorageClass, "StorageClassMissing", models.SeverityCritical,
+				"storage class %s of claim %s (%s) does not exist in the target cluster", claim.StorageClass, claim.Name, claim.Size)
+		}
+	}
+
+	for _, name := range inventory.PriorityClasses {
+		if _, err := target.Client.SchedulingV1().PriorityClasses().Get(ctx, name, metav1.GetOptions{}); apierrors.IsNotFound(err) {
+			finding("PriorityClass", name, "PriorityClassMissing", models.SeverityCritical, "priority class %s of the workloads does not exist in the target cluster", name)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(inventory.SecretKeys)) {
+		if !exists {
+			finding("Secret", name, "SecretMissing", models.SeverityCritical, "secret %s must be created in the target namespace", name)
+			continue
+		}
+		secret, err := target.Client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			finding("Secret", name, "SecretMissing", models.SeverityCritical, "secret %s must be created in the target namespace", name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		missing := []string{}
+		for _, key := range inventory.SecretKeys[name] {
+			if _, found := secret.Data[key]; !found {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) > 0 {
+			finding("Secret", name, "SecretKeyMissing", models.SeverityCritical, "secret %s of the target namespace lacks the keys %s", name, strings.Join(missing, ", "))
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(inventory.ConfigMapKeys)) {
+		if exists {
+			_, err := target.Client.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err == nil {
+				continue
+			}
+			if !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+		}
+		finding("ConfigMap", name, "ConfigMapMissing", models.SeverityWarning, "config map %s must be migrated with the workloads", name)
+	}
+
+	if exists {
+		quotas, err := target.Client.CoreV1().ResourceQuotas(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, quota := range quotas.Items {
+			for _, name := range quotaResources {
+				hard, limited := quota.Status.Hard[name]
+				need, used := inventory.usage[name], quota.Status.Used[name]
+				if !limited || need.IsZero() {
+					continue
+				}
+				free := hard.DeepCopy()
+				free.Sub(used)
+				if need.Cmp(free) > 0 {
+					finding("ResourceQuota", quota.Name, "QuotaExceeded/"+string(name), models.SeverityCritical,
+						"the namespace needs %s %s, quota %s has %s of %s left", need.String(), name, quota.Name, free.String(), hard.String())
+				}
+			}
+		}
+	}
+
+	for _, dependency := range inventory.Dependencies {
+		finding(dependency.Workload.Kind, dependency.Workload.Name, "ExternalDependency/"+dependency.Variable, models.SeverityInfo,
+			"container %s reaches %s through %s, the target cluster must reach it as well", dependency.Container, dependency.Endpoint, dependency.Variable)
+	}
+	return findings, nil
+}
+
+// Blockers counts the critical findings
+func Blockers(findings []models.Finding) int {
+	blockers := 0
+	for _, finding := range findings {
+		if finding.Severity == models.SeverityCritical {
+			blockers++
+		}
+	}
+	return blockers
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// Rank orders severities, critical ranks highest
+func (s Severity) Rank() int {
+	switch s {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+// ResourceRef identifies the kubernetes object a finding is about
+type ResourceRef struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Node      string `json:"node,omitempty"`
+}
+
+func (r ResourceRef) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s/%s", r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+// Finding is a single problem reported by a check against a single resource
+type Finding struct {
+	ID                string      `json:"id"`
+	Check             string      `json:"check"`
+	Resource          ResourceRef `json:"resource"`
+	Reason            string      `json:"reason,omitempty"`
+	Severity          Severity    `json:"severity"`
+	Message           string      `json:"message"`
+	Team              string      `json:"team,omitempty"`
+	FirstSeen         time.Time   `json:"firstSeen"`
+	LastSeen          time.Time   `json:"lastSeen"`
+	Suppressed        bool        `json:"suppressed,omitempty"`
+	SuppressionReason string      `json:"suppressionReason,omitempty"`
+	Hint              string      `json:"hint,omitempty"`
+	DocURL            string      `json:"docURL,omitempty"`
+
+	State           FindingState     `json:"state,omitempty"`
+	Acknowledgement *Acknowledgement `json:"acknowledgement,omitempty"`
+}
+
+// FindingState is the lifecycle state of a finding tracked between runs
+type FindingState string
+
+const (
+	StateOpen         FindingState = "open"
+	StateAcknowledged FindingState = "acknowledged"
+	StateResolved     FindingState = "resolved"
+	StateSuppressed   FindingState = "suppressed"
+)
+
+// Acknowledgement records who is handling a finding
+type Acknowledgement struct {
+	By       string    `json:"by"`
+	Assignee string    `json:"assignee,omitempty"`
+	Comment  string    `json:"comment,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Failing returns true when the finding should fail the suite
+func (f Finding) Failing() bool {
+	return f.Severity != SeverityInfo && !f.Suppressed
+}
+
+// FindingID returns a stable identifier for a check, resource and reason.
+// The reason tells apart several problems a check finds on the same resource.
+// The message is left out on purpose so counts in the message do not change the ID between runs.
+func FindingID(check string, resource ResourceRef, reason string) string {
+	key := strings.Join([]string{check, resource.Kind, resource.Namespace, resource.Name}, "|")
+	if reason != "" {
+		key += "|" + reason
+	}
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// FindingsFromChecks converts the result of a test suite into findings.
+// Checks that report per resource findings keep them, other failed checks become a single finding.
+func FindingsFromChecks(suite string, checks []ResourceCheck) []Finding {
+	findings := []Finding{}
+	for _, check := range checks {
+		checkName := fmt.Sprintf("%s/%s", suite, check.Label)
+		if len(check.Findings) > 0 {
+			for _, finding := range check.Findings {
+				finding.Check = checkName
+				finding.ID = FindingID(finding.Check, finding.Resource, finding.Reason)
+				findings = append(findings, finding)
+			}
+			continue
+		}
+		// checks that could not run have no findings, they are reported in the check results
+		if check.Status || check.Result() == ResultError {
+			continue
+		}
+		finding := Finding{
+			Check:    checkName,
+			Resource: ResourceRef{Kind: "Check", Name: check.Label},
+			Severity: SeverityWarning,
+			Message:  check.Details,
+		}
+		finding.ID = FindingID(finding.Check, finding.Resource, finding.Reason)
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// Evidence is a raw object collected for a failing finding, so a report is enough to debug
+// without access to the cluster. For is the resource of the finding the evidence belongs to.
+type Evidence struct {
+	For      ResourceRef `json:"for"`
+	Relation string      `json:"relation"`
+	Resource ResourceRef `json:"resource"`
+	Content  string      `json:"content"`
+}
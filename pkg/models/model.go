@@ -1,7 +1,65 @@
 package models
 
+import "fmt"
+
+// Results of a check
+const (
+	ResultPass    = "pass"
+	ResultFail    = "fail"
+	ResultError   = "error"
+	ResultSkipped = "skipped"
+)
+
 type ResourceCheck struct {
-	Label   string
-	Details string
-	Status  bool
+	Label    string
+	Details  string
+	Status   bool
+	Findings []Finding
+	// Error is set when the check could not run to completion
+	Error string
+	// Skipped is the reason a check was not run
+	Skipped string
+}
+
+// Result returns pass, fail, error or skipped
+func (c ResourceCheck) Result() string {
+	switch {
+	case c.Error != "":
+		return ResultError
+	case c.Skipped != "":
+		return ResultSkipped
+	case c.Status:
+		return ResultPass
+	}
+	return ResultFail
+}
+
+// CheckResult is the outcome of one check in a report, every check that was configured has a result
+type CheckResult struct {
+	Check   string `json:"check"`
+	Result  string `json:"result"`
+	Details string `json:"details,omitempty"`
+	Cause   string `json:"cause,omitempty"`
+	// Cached is set when the result was reused from an earlier run
+	Cached bool `json:"cached,omitempty"`
+}
+
+// CheckResults returns the result of every check of a suite
+func CheckResults(suite string, checks []ResourceCheck) []CheckResult {
+	results := []CheckResult{}
+	for _, check := range checks {
+		result := CheckResult{
+			Check:   fmt.Sprintf("%s/%s", suite, check.Label),
+			Result:  check.Result(),
+			Details: check.Details,
+		}
+		switch result.Result {
+		case ResultError:
+			result.Cause = check.Error
+		case ResultSkipped:
+			result.Cause = check.Skipped
+		}
+		results = append(results, result)
+	}
+	return results
 }
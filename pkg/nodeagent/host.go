@@ -0,0 +1,340 @@
+package nodeagent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/models"
+	"healthctl/pkg/testsuite"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Labels of the host checks
+const (
+	LabelSMART   = "SMART Disk Health"
+	LabelUnits   = "Systemd Units"
+	LabelZombies = "Zombie Processes"
+	LabelDNS     = "Node DNS"
+)
+
+const (
+	// DefaultMaxZombies is the number of zombie processes tolerated on a node
+	DefaultMaxZombies = 20
+	// maxUnitRestarts is the number of restarts since boot after which a unit is reported
+	maxUnitRestarts = 5
+	// slowDNS is the time after which a DNS answer is reported as slow
+	slowDNS = time.Second
+	// commandTimeout limits the commands run on the host
+	commandTimeout = 30 * time.Second
+)
+
+// Host runs the checks of the node the agent runs on. The agent runs with the PID namespace of the host, so
+// /proc shows the processes of the node and commands run in the mount namespace of its init process.
+type Host struct {
+	Node string
+	// HostRoot is where the root filesystem of the node is mounted
+	HostRoot   string
+	Units      []string
+	DNSNames   []string
+	DNSServers []string
+	MaxZombies int
+	// NetworkWindow is how long the network is sampled, it is not sampled when 0
+	NetworkWindow time.Duration
+}
+
+// NewHost returns the host checks of the node with the defaults of the configuration applied
+func NewHost(node string, cfg config.NodeAgent) (Host, error) {
+	host := Host{Node: node, HostRoot: cfg.HostRoot, Units: cfg.Units, DNSNames: cfg.DNSNames, DNSServers: cfg.DNSServers,
+		MaxZombies: cfg.MaxZombies}
+	if host.HostRoot == "" {
+		host.HostRoot = "/host"
+	}
+	if len(host.Units) == 0 {
+		host.Units = []string{"kubelet", "containerd"}
+	}
+	if len(host.DNSNames) == 0 {
+		host.DNSNames = []string{"kubernetes.default.svc.cluster.local"}
+	}
+	if host.MaxZombies == 0 {
+		host.MaxZombies = DefaultMaxZombies
+	}
+	if cfg.Network != nil {
+		host.NetworkWindow = DefaultNetworkWindow
+		if cfg.Network.Window != "" {
+			window, err := time.ParseDuration(cfg.Network.Window)
+			if err != nil || window <= 0 {
+				return host, fmt.Errorf("invalid nodeAgent.network.window %q", cfg.Network.Window)
+			}
+			host.NetworkWindow = window
+		}
+	}
+	return host, nil
+}
+
+// Run runs the host checks, a panic of a check is reported as its error
+func (h Host) Run() []models.ResourceCheck {
+	return testsuite.RunChecks(nil, []testsuite.Check{
+		{Label: LabelSMART, Run: func(*kubernetes.Clientset) []models.ResourceCheck { return []models.ResourceCheck{h.smart()} }},
+		{Label: LabelUnits, Run: func(*kubernetes.Clientset) []models.ResourceCheck { return []models.ResourceCheck{h.units()} }},
+		{Label: LabelZombies, Run: func(*kubernetes.Clientset) []models.ResourceCheck { return []models.ResourceCheck{h.zombies()} }},
+		{Label: LabelDNS, Run: func(*kubernetes.Clientset) []models.ResourceCheck { return []models.ResourceCheck{h.dns()} }},
+	})
+}
+
+func (h Host) node() models.ResourceRef {
+	return models.ResourceRef{Kind: "Node", Name: h.Node}
+}
+
+// onHost runs a command of the node in the mount namespace of its init process
+func onHost(timeout time.Duration, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return exec.CommandContext(ctx, "nsenter", append([]string{"-t", "1", "-m", "--"}, args...)...).Output()
+}
+
+// installed returns true when the node has the command
+func (h Host) installed(command string) bool {
+	for _, dir := range []string{"usr/sbin", "sbin", "usr/bin", "usr/local/sbin", "usr/local/bin"} {
+		if _, err := os.Stat(filepath.Join(h.HostRoot, dir, command)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// smart checks the SMART health self-assessment of the disks of the node with the smartctl of the node
+func (h Host) smart() models.ResourceCheck {
+	result := models.ResourceCheck{Label: LabelSMART}
+	if !h.installed("smartctl") {
+		result.Skipped = "smartctl is not installed on the node"
+		return result
+	}
+
+	output, err := onHost(commandTimeout, "smartctl", "--scan", "-j")
+	scan := struct {
+		Devices []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		} `json:"devices"`
+	}{}
+	if jsonErr := json.Unmarshal(output, &scan); jsonErr != nil {
+		result.Error = fmt.Sprintf("scanning disks: %v", firstError(err, jsonErr))
+		return result
+	}
+
+	checked, failed := 0, 0
+	for _, device := range scan.Devices {
+		// smartctl exits with a bit mask of the problems it found, the json output is there regardless
+		output, err := onHost(commandTimeout, "smartctl", "-H", "-j", "-d", device.Type, device.Name)
+		health := struct {
+			SmartStatus *struct {
+				Passed bool `json:"passed"`
+			} `json:"smart_status"`
+		}{}
+		if jsonErr := json.Unmarshal(output, &health); jsonErr != nil {
+			result.Findings = append(result.Findings, models.Finding{Resource: h.node(), Reason: device.Name,
+				Severity: models.SeverityWarning, Message: fmt.Sprintf("reading SMART health of %s failed: %v", device.Name, firstError(err, jsonErr))})
+			continue
+		}
+		if health.SmartStatus == nil {
+			// virtual disks and controllers without SMART support
+			continue
+		}
+		checked++
+		if !health.SmartStatus.Passed {
+			failed++
+			result.Findings = append(result.Findings, models.Finding{Resource: h.node(), Reason: device.Name,
+				Severity: models.SeverityCritical, Message: fmt.Sprintf("disk %s fails its SMART health self-assessment, replace it", device.Name)})
+		}
+	}
+	if checked == 0 && len(result.Findings) == 0 {
+		result.Skipped = "no disk of the node supports SMART"
+		return result
+	}
+	result.Status = len(result.Findings) == 0
+	result.Details = fmt.Sprintf("%d of %d disks failing", failed, checked)
+	return result
+}
+
+// units checks that the systemd units are active and do not restart repeatedly. Units that do not exist on
+// the node, like containerd on nodes running CRI-O, are left out.
+func (h Host) units() models.ResourceCheck {
+	result := models.ResourceCheck{Label: LabelUnits}
+	active := []string{}
+	for _, unit := range h.Units {
+		output, err := onHost(commandTimeout, "systemctl", "show", unit, "--property=LoadState,ActiveState,SubState,NRestarts")
+		if err != nil {
+			result.Error = fmt.Sprintf("systemctl show %s: %v", unit, firstError(err, nil))
+			return result
+		}
+		properties := make(map[string]string)
+		for _, line := range strings.Split(string(output), "\n") {
+			if key, value, found := strings.Cut(line, "="); found {
+				properties[key] = strings.TrimSpace(value)
+			}
+		}
+		if properties["LoadState"] == "not-found" {
+			continue
+		}
+		if properties["ActiveState"] != "active" {
+			result.Findings = append(result.Findings, models.Finding{Resource: h.node(), Reason: unit, Severity: models.SeverityCritical,
+				Message: fmt.Sprintf("unit %s is %s (%s)", unit, properties["ActiveState"], properties["SubState"])})
+			continue
+		}
+		if restarts, _ := strconv.Atoi(properties["NRestarts"]); restarts >= maxUnitRestarts {
+			result.Findings = append(result.Findings, models.Finding{Resource: h.node(), Reason: unit + "/restarts", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("unit %s restarted %d times since the node booted", unit, restarts)})
+		}
+		active = append(active, unit)
+	}
+	result.Status = len(result.Findings) == 0
+	result.Details = fmt.Sprintf("active: %s", strings.Join(active, ", "))
+	return result
+}
+
+// zombies counts the zombie processes of the node by their parent, a parent that does not reap its children
+// fills the process table
+func (h Host) zombies() models.ResourceCheck {
+	result := models.ResourceCheck{Label: LabelZombies}
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	parents := make(map[string]int)
+	total := 0
+	for _, file := range stats {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			// the process exited
+			continue
+		}
+		// pid (comm) state ppid ..., the command may contain spaces and parentheses
+		end := strings.LastIndexByte(string(data), ')')
+		if end < 0 {
+			continue
+		}
+		fields := strings.Fields(string(data[end+1:]))
+		if len(fields) < 2 || fields[0] != "Z" {
+			continue
+		}
+		total++
+		comm, _ := os.ReadFile(filepath.Join("/proc", fields[1], "comm"))
+		parents[fmt.Sprintf("%s (%s)", strings.TrimSpace(string(comm)), fields[1])]++
+	}
+	result.Details = fmt.Sprintf("%d zombie processes", total)
+	result.Status = total <= h.MaxZombies
+	if !result.Status {
+		result.Findings = append(result.Findings, models.Finding{Resource: h.node(), Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("%d zombie processes, more than %d, most are children of %s", total, h.MaxZombies, topParents(parents, 3))})
+	}
+	return result
+}
+
+// topParents lists the parents with the most zombies
+func topParents(parents map[string]int, n int) string {
+	names := make([]string, 0, len(parents))
+	for name := range parents {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if parents[names[i]] != parents[names[j]] {
+			return parents[names[i]] > parents[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	listed := []string{}
+	for _, name := range names[:min(n, len(names))] {
+		listed = append(listed, fmt.Sprintf("%s: %d", name, parents[name]))
+	}
+	return strings.Join(listed, ", ")
+}
+
+// dns resolves the names through every DNS server, unanswered queries are critical and slow answers warnings
+func (h Host) dns() models.ResourceCheck {
+	result := models.ResourceCheck{Label: LabelDNS}
+	servers := h.DNSServers
+	if len(servers) == 0 {
+		var err error
+		if servers, err = nameservers("/etc/resolv.conf"); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+	if len(servers) == 0 {
+		result.Error = "no nameserver in /etc/resolv.conf, configure nodeAgent.dnsServers"
+		return result
+	}
+
+	slowest := time.Duration(0)
+	for _, server := range servers {
+		address := net.JoinHostPort(server, "53")
+		if _, _, err := net.SplitHostPort(server); err == nil {
+			address = server
+		}
+		resolver := &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, address)
+		}}
+		for _, name := range h.DNSNames {
+			fqdn := strings.TrimSuffix(name, ".") + "."
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			start := time.Now()
+			_, err := resolver.LookupHost(ctx, fqdn)
+			took := time.Since(start)
+			cancel()
+			switch {
+			case err != nil:
+				result.Findings = append(result.Findings, models.Finding{Resource: h.node(), Reason: server + "/" + name,
+					Severity: models.SeverityCritical, Message: fmt.Sprintf("DNS server %s does not resolve %s: %v", server, name, err)})
+			case took > slowDNS:
+				result.Findings = append(result.Findings, models.Finding{Resource: h.node(), Reason: server + "/" + name,
+					Severity: models.SeverityWarning, Message: fmt.Sprintf("DNS server %s took %s to resolve %s", server, took.Round(time.Millisecond), name)})
+			}
+			slowest = max(slowest, took)
+		}
+	}
+	result.Status = len(result.Findings) == 0
+	result.Details = fmt.Sprintf("%d names through %s, slowest answer %s", len(h.DNSNames), strings.Join(servers, ", "), slowest.Round(time.Millisecond))
+	return result
+}
+
+// nameservers returns the nameservers of a resolv.conf
+func nameservers(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	servers := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers, scanner.Err()
+}
+
+// firstError returns the error of a command before the error parsing its output, which follows from it
+func firstError(commandErr, parseErr error) error {
+	if exitErr, ok := commandErr.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%v: %s", exitErr, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	if commandErr != nil {
+		return commandErr
+	}
+	return parseErr
+}
@@ -0,0 +1,325 @@
+package nodeagent
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// LabelNetwork is the check of the network errors sampled by the node agents
+const LabelNetwork = "Network Errors"
+
+// DefaultNetworkWindow is how long the network is sampled on every run
+const DefaultNetworkWindow = 10 * time.Second
+
+// Kinds of sampled network errors
+const (
+	Retransmits    = "retransmits"
+	ResetsSent     = "resets_sent"
+	ResetsReceived = "resets_received"
+	DNSFailures    = "dns_failures"
+	// resets are the resets sent and received, they are reported together
+	resets = "resets"
+)
+
+// Default rates per minute of a workload above which it is reported
+const (
+	DefaultMaxRetransmits = 60
+	DefaultMaxResets      = 30
+	DefaultMaxDNSFailures = 10
+)
+
+// NetworkSample counts the network errors of the sockets of a node during a window
+type NetworkSample struct {
+	Window time.Duration `json:"window"`
+	Flows  []Flow        `json:"flows,omitempty"`
+	// Error is set when the node could not be sampled
+	Error string `json:"error,omitempty"`
+}
+
+// Flow counts the errors of a kind between the local address of a socket and a remote address and port
+type Flow struct {
+	Kind   string `json:"kind"`
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+	Port   int    `json:"port"`
+	Count  int    `json:"count"`
+}
+
+// bpftraceProgram counts the errors by local address, remote address and remote port. The TCP tracepoints
+// carry the addresses of the socket. DNS answers are counted when a socket reads them, so every answer is
+// counted once on the node of the client and not again on the nodes it passes; only IPv4 answers are
+// counted. The window is filled in with fmt.
+const bpftraceProgram = `
+tracepoint:tcp:tcp_retransmit_skb {
+	if (args->family == 2) { @retransmits[ntop(args->saddr), ntop(args->daddr), args->dport] = count(); }
+	else { @retransmits[ntop(args->saddr_v6), ntop(args->daddr_v6), args->dport] = count(); }
+}
+tracepoint:tcp:tcp_send_reset {
+	if (args->family == 2) { @resets_sent[ntop(args->saddr), ntop(args->daddr), args->dport] = count(); }
+	else { @resets_sent[ntop(args->saddr_v6), ntop(args->daddr_v6), args->dport] = count(); }
+}
+tracepoint:tcp:tcp_receive_reset {
+	if (args->family == 2) { @resets_received[ntop(args->saddr), ntop(args->daddr), args->dport] = count(); }
+	else { @resets_received[ntop(args->saddr_v6), ntop(args->daddr_v6), args->dport] = count(); }
+}
+kprobe:skb_consume_udp {
+	$skb = (struct sk_buff *)arg1;
+	$udp = $skb->head + $skb->transport_header;
+	$ip = $skb->head + $skb->network_header;
+	$sport = (*(uint8 *)$udp << 8) | *(uint8 *)($udp + 1);
+	$rcode = *(uint8 *)($udp + 11) & 0x0f;
+	// SERVFAIL and REFUSED, NXDOMAIN is the usual answer for the search domains of pods
+	if ($sport == 53 && ($rcode == 2 || $rcode == 5) && (*(uint8 *)$ip >> 4) == 4) {
+		@dns_failures[ntop(*(uint32 *)($ip + 16)), ntop(*(uint32 *)($ip + 12)), 53] = count();
+	}
+}
+interval:ms:%d { exit(); }
+`
+
+// mapEntry is a line of the maps bpftrace prints when it exits, e.g. @retransmits[10.0.0.1, 10.0.0.2, 443]: 3
+var mapEntry = regexp.MustCompile(`^@(\w+)\[(.+), (.+), (\d+)\]: (\d+)$`)
+
+// SampleNetwork samples the network errors of the node with its bpftrace, nil when sampling is disabled
+func (h Host) SampleNetwork() *NetworkSample {
+	if h.NetworkWindow <= 0 {
+		return nil
+	}
+	sample := &NetworkSample{Window: h.NetworkWindow}
+	if !h.installed("bpftrace") {
+		sample.Error = "bpftrace is not installed on the node"
+		return sample
+	}
+	program := fmt.Sprintf(bpftraceProgram, h.NetworkWindow.Milliseconds())
+	output, err := onHost(h.NetworkWindow+commandTimeout, "bpftrace", "-e", program)
+	if err != nil {
+		sample.Error = fmt.Sprintf("bpftrace: %v", firstError(err, nil))
+		return sample
+	}
+	sample.Flows = parseFlows(string(output))
+	return sample
+}
+
+func parseFlows(output string) []Flow {
+	flows := []Flow{}
+	for _, line := range strings.Split(output, "\n") {
+		match := mapEntry.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		port, _ := strconv.Atoi(match[4])
+		count, _ := strconv.Atoi(match[5])
+		flows = append(flows, Flow{Kind: match[1], Local: match[2], Remote: match[3], Port: port, Count: count})
+	}
+	return flows
+}
+
+// networkLimits returns the rates per minute above which workloads are reported, with the defaults applied
+func networkLimits(cfg config.NetworkLimits) map[string]float64 {
+	limits := map[string]float64{Retransmits: DefaultMaxRetransmits, resets: DefaultMaxResets, DNSFailures: DefaultMaxDNSFailures}
+	if cfg.Retransmits > 0 {
+		limits[Retransmits] = float64(cfg.Retransmits)
+	}
+	if cfg.Resets > 0 {
+		limits[resets] = float64(cfg.Resets)
+	}
+	if cfg.DNSFailures > 0 {
+		limits[DNSFailures] = float64(cfg.DNSFailures)
+	}
+	return limits
+}
+
+// networkErrors describes the reported kinds
+var networkErrors = map[string]string{
+	Retransmits: "TCP retransmits",
+	resets:      "connection resets",
+	DNSFailures: "failed DNS answers",
+}
+
+// endpoints maps the IPs of the pods to their workloads and the cluster IPs to their services. Pods on the
+// network of the host share the IP of their node and are not mapped.
+type endpoints map[string]models.ResourceRef
+
+func listEndpoints(clientset *kubernetes.Clientset) (endpoints, error) {
+	result := make(endpoints)
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing pods: %v", err)
+	}
+	for _, pod := range pods.Items {
+		if pod.Spec.HostNetwork || pod.Status.Phase != v1.PodRunning {
+			continue
+		}
+		for _, ip := range pod.Status.PodIPs {
+			result[ip.IP] = podWorkload(pod)
+		}
+	}
+	services, err := clientset.CoreV1().Services("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing services: %v", err)
+	}
+	for _, service := range services.Items {
+		for _, ip := range service.Spec.ClusterIPs {
+			if ip != v1.ClusterIPNone {
+				result[ip] = models.ResourceRef{Kind: "Service", Namespace: service.Namespace, Name: service.Name}
+			}
+		}
+	}
+	return result, nil
+}
+
+// podWorkload returns the controller of a pod, deployments are derived from the pod-template-hash of their
+// replica sets
+func podWorkload(pod v1.Pod) models.ResourceRef {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller == nil || !*owner.Controller {
+			continue
+		}
+		if hash := pod.Labels["pod-template-hash"]; owner.Kind == "ReplicaSet" && hash != "" {
+			return models.ResourceRef{Kind: "Deployment", Namespace: pod.Namespace, Name: strings.TrimSuffix(owner.Name, "-"+hash)}
+		}
+		return models.ResourceRef{Kind: owner.Kind, Namespace: pod.Namespace, Name: owner.Name}
+	}
+	return models.ResourceRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+}
+
+// describe returns the workload or service of an address, or the address itself
+func (e endpoints) describe(address string, port int) string {
+	if ref, found := e[address]; found {
+		return fmt.Sprintf("%s:%d", ref, port)
+	}
+	return fmt.Sprintf("%s:%d", address, port)
+}
+
+// errorRate is the errors of a kind of a workload per minute, by the remote endpoints
+type errorRate struct {
+	workload models.ResourceRef
+	kind     string
+	rate     float64
+	remotes  map[string]float64
+}
+
+// networkCheck reports the workloads whose sampled network errors exceed the limits
+func networkCheck(clientset *kubernetes.Clientset, samples map[string]*NetworkSample, cfg config.NetworkLimits) models.ResourceCheck {
+	result := models.ResourceCheck{Label: LabelNetwork}
+	sampled, errs := 0, []string{}
+	nodes := make([]string, 0, len(samples))
+	for node := range samples {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		if samples[node].Error != "" {
+			errs = append(errs, fmt.Sprintf("%s: %s", node, samples[node].Error))
+		} else {
+			sampled++
+		}
+	}
+	if sampled == 0 {
+		result.Error = strings.Join(errs[:min(len(errs), maxNodeDetails)], "; ")
+		return result
+	}
+	if clientset == nil {
+		result.Error = "no cluster to attribute the network errors to"
+		return result
+	}
+	addresses, err := listEndpoints(clientset)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	findings, namespaces := attribute(addresses, samples, networkLimits(cfg))
+	result.Findings = findings
+	result.Status = len(result.Findings) == 0
+	result.Details = fmt.Sprintf("sampled %d of %d nodes", sampled, len(samples))
+	if len(namespaces) > 0 {
+		result.Details += ", errors per minute by namespace: " + top(namespaces, 5)
+	}
+	if len(errs) > 0 {
+		result.Details += ", not sampled: " + strings.Join(errs[:min(len(errs), maxNodeDetails)], "; ")
+	}
+	return result
+}
+
+// attribute attributes the sampled errors to the workloads of the local addresses of the sockets, sockets of
+// the network of the host to their node, and returns the findings of the workloads whose rates exceed the
+// limits and the rates by namespace
+func attribute(addresses endpoints, samples map[string]*NetworkSample, limits map[string]float64) ([]models.Finding, map[string]float64) {
+	rates := make(map[string]*errorRate)
+	namespaces := make(map[string]float64)
+	for node, sample := range samples {
+		if sample.Error != "" || sample.Window <= 0 {
+			continue
+		}
+		minutes := sample.Window.Minutes()
+		for _, flow := range sample.Flows {
+			kind := flow.Kind
+			if kind == ResetsSent || kind == ResetsReceived {
+				kind = resets
+			}
+			workload, found := addresses[flow.Local]
+			if !found || workload.Kind == "Service" {
+				workload = models.ResourceRef{Kind: "Node", Name: node}
+			}
+			key := workload.String() + "|" + kind
+			rate, found := rates[key]
+			if !found {
+				rate = &errorRate{workload: workload, kind: kind, remotes: make(map[string]float64)}
+				rates[key] = rate
+			}
+			perMinute := float64(flow.Count) / minutes
+			rate.rate += perMinute
+			rate.remotes[addresses.describe(flow.Remote, flow.Port)] += perMinute
+			if workload.Namespace != "" {
+				namespaces[workload.Namespace] += perMinute
+			}
+		}
+	}
+
+	findings := []models.Finding{}
+	keys := make([]string, 0, len(rates))
+	for key := range rates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		rate := rates[key]
+		if rate.rate <= limits[rate.kind] {
+			continue
+		}
+		findings = append(findings, models.Finding{Resource: rate.workload, Reason: rate.kind, Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("%.0f %s per minute, above %.0f, mostly with %s", rate.rate, networkErrors[rate.kind], limits[rate.kind], top(rate.remotes, 3))})
+	}
+	return findings, namespaces
+}
+
+// top lists the keys with the highest values
+func top(values map[string]float64, n int) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if values[keys[i]] != values[keys[j]] {
+			return values[keys[i]] > values[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	listed := []string{}
+	for _, key := range keys[:min(n, len(keys))] {
+		listed = append(listed, fmt.Sprintf("%s %.0f", key, values[key]))
+	}
+	return strings.Join(listed, ", ")
+}
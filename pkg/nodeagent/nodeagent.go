@@ -0,0 +1,124 @@
+// Package nodeagent runs the host-level checks the API can not do, disk SMART status, systemd units, zombie
+// processes and the DNS of the node, and optionally samples network errors with eBPF, in a DaemonSet agent on every node. The agents report their results to
+// healthctl serve over gRPC, which reports them as the node suite of its cluster.
+//
+// The service is defined by hand with a JSON codec instead of generated protobuf code, the messages are the
+// models of the reports:
+//
+//	service NodeAgent { rpc Report(NodeReport) returns (Ack); }
+package nodeagent
+
+import (
+	"context"
+	"crypto/tls"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"healthctl/pkg/models"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+)
+
+// serviceName is the gRPC service of the node agent reports
+const serviceName = "healthctl.nodeagent.v1.NodeAgent"
+
+// NodeReport is the result of the host checks of a node
+type NodeReport struct {
+	Node   string                 `json:"node"`
+	Time   time.Time              `json:"time"`
+	Checks []models.ResourceCheck `json:"checks"`
+	// Network is the network sampling of the node, when enabled
+	Network *NetworkSample `json:"network,omitempty"`
+}
+
+// Ack acknowledges a report
+type Ack struct{}
+
+// codec encodes the messages as JSON, the content subtype of the calls is json
+type codec struct{}
+
+func (codec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (codec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (codec) Name() string                       { return "json" }
+
+func init() {
+	encoding.RegisterCodec(codec{})
+}
+
+// reporter is the handler type of the service
+type reporter interface {
+	Report(ctx context.Context, report *NodeReport) (*Ack, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*reporter)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Report",
+		Handler: func(srv any, ctx context.Context, decode func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			report := &NodeReport{}
+			if err := decode(report); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return srv.(reporter).Report(ctx, report)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/Report"}
+			return interceptor(ctx, report, info, func(ctx context.Context, req any) (any, error) {
+				return srv.(reporter).Report(ctx, req.(*NodeReport))
+			})
+		},
+	}},
+	Metadata: "nodeagent.go",
+}
+
+// Client sends the reports of a node agent
+type Client struct {
+	conn  *grpc.ClientConn
+	token string
+}
+
+// Dial returns a client of the TLS listener of serve at host:port
+func Dial(server, token string, tlsConfig *tls.Config) (*Client, error) {
+	if token == "" {
+		return nil, fmt.Errorf("no node agent token configured, set nodeAgent.token or HEALTHCTL_NODE_AGENT_TOKEN")
+	}
+	conn, err := grpc.NewClient(server, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(codec{}.Name())))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %v", server, err)
+	}
+	return &Client{conn: conn, token: token}, nil
+}
+
+// Report sends a report
+func (c *Client) Report(ctx context.Context, report NodeReport) error {
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.token)
+	return c.conn.Invoke(ctx, "/"+serviceName+"/Report", &report, &Ack{})
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+//go:embed daemonset.yaml
+var daemonSet string
+
+// Manifest returns the DaemonSet of the node agent reporting to the server
+func Manifest(namespace, image, server string) (string, error) {
+	tmpl, err := template.New("daemonset").Parse(daemonSet)
+	if err != nil {
+		return "", err
+	}
+	out := &strings.Builder{}
+	err = tmpl.Execute(out, struct{ Namespace, Image, Server string }{namespace, image, server})
+	return out.String(), err
+}
@@ -0,0 +1,242 @@
+package nodeagent
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/healthcheck"
+	"healthctl/pkg/models"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Suite is the suite the results of the node agents are reported under
+const Suite = "node"
+
+// LabelAgents is the check of the node agents that stopped reporting
+const LabelAgents = "Node Agents"
+
+// DefaultStaleAfter is how long a node agent may not report before its node is reported
+const DefaultStaleAfter = 15 * time.Minute
+
+// maxNodeDetails limits the nodes named in the details of a check
+const maxNodeDetails = 5
+
+// Server keeps the latest report of every node agent. Agents must send the token, and since they run on the
+// network of the host, a report is only accepted from an address of the node it is about.
+type Server struct {
+	// Token is the bearer token agents must send
+	Token string
+	// StaleAfter is how long an agent may not report before its node is reported
+	StaleAfter time.Duration
+	// Network are the limits of the network errors of workloads sampled by the agents
+	Network config.NetworkLimits
+
+	clientset kubernetes.Interface
+	started   time.Time
+	mutex     sync.Mutex
+	reports   map[string]NodeReport
+}
+
+// NewServer returns a server without reports, the clientset looks up the addresses of the nodes
+func NewServer(token string, staleAfter time.Duration, clientset kubernetes.Interface) (*Server, error) {
+	if token == "" {
+		return nil, fmt.Errorf("node agents require a token")
+	}
+	if staleAfter <= 0 {
+		staleAfter = DefaultStaleAfter
+	}
+	return &Server{Token: token, StaleAfter: staleAfter, clientset: clientset, started: time.Now(), reports: make(map[string]NodeReport)}, nil
+}
+
+// Serve accepts reports on the listener with TLS until it fails
+func (s *Server) Serve(listener net.Listener, tlsConfig *tls.Config) error {
+	if tlsConfig == nil {
+		return fmt.Errorf("node agents require TLS")
+	}
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)))
+	server.RegisterService(&serviceDesc, s)
+	return server.Serve(listener)
+}
+
+// Report keeps the report of a node, replacing its previous one
+func (s *Server) Report(ctx context.Context, report *NodeReport) (*Ack, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	authorization := md.Get("authorization")
+	if len(authorization) == 0 || subtle.ConstantTimeCompare([]byte(authorization[0]), []byte("Bearer "+s.Token)) != 1 {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	if report.Node == "" {
+		return nil, status.Error(codes.InvalidArgument, "report has no node")
+	}
+	if err := s.fromNode(ctx, report.Node); err != nil {
+		return nil, err
+	}
+	if report.Time.IsZero() {
+		report.Time = time.Now()
+	}
+	s.mutex.Lock()
+	s.reports[report.Node] = *report
+	s.mutex.Unlock()
+	return &Ack{}, nil
+}
+
+// fromNode verifies the report was sent from one of the addresses of the node, so an agent can not report
+// for other nodes
+func (s *Server) fromNode(ctx context.Context, name string) error {
+	remote, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.PermissionDenied, "unknown peer")
+	}
+	host, _, err := net.SplitHostPort(remote.Addr.String())
+	if err != nil {
+		return status.Errorf(codes.PermissionDenied, "unknown peer %s", remote.Addr)
+	}
+	node, err := s.clientset.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return status.Errorf(codes.PermissionDenied, "node %s does not exist", name)
+	}
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "looking up node %s: %v", name, err)
+	}
+	for _, address := range node.Status.Addresses {
+		if ip := net.ParseIP(address.Address); ip != nil && ip.Equal(net.ParseIP(host)) {
+			return nil
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, "%s is not an address of node %s", host, name)
+}
+
+// Check returns the check reporting the results of the node agents
+func (s *Server) Check() healthcheck.Check {
+	return healthcheck.Func(Suite, s.results)
+}
+
+// results merges the latest reports of the nodes into one result per host check, attributes the sampled
+// network errors to workloads and reports the nodes whose agent stopped reporting or never reported. Reports of nodes that were removed from the cluster are dropped.
+func (s *Server) results(clientset *kubernetes.Clientset) []models.ResourceCheck {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var client kubernetes.Interface = s.clientset
+	if clientset != nil {
+		client = clientset
+	}
+	list, err := client.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return []models.ResourceCheck{{Label: LabelAgents, Details: "Error listing the nodes", Error: err.Error()}}
+	}
+	nodes := make([]string, 0, len(list.Items))
+	exists := make(map[string]bool)
+	for _, node := range list.Items {
+		nodes = append(nodes, node.Name)
+		exists[node.Name] = true
+	}
+	for node := range s.reports {
+		if !exists[node] {
+			delete(s.reports, node)
+		}
+	}
+	sort.Strings(nodes)
+
+	agents := models.ResourceCheck{Label: LabelAgents}
+	now := time.Now()
+	byLabel := make(map[string][]nodeResult)
+	labels := []string{}
+	samples := make(map[string]*NetworkSample)
+	reporting, pending := 0, 0
+	for _, node := range nodes {
+		report, found := s.reports[node]
+		switch {
+		case !found && now.Sub(s.started) <= s.StaleAfter:
+			pending++
+			continue
+		case !found:
+			agents.Findings = append(agents.Findings, models.Finding{Resource: models.ResourceRef{Kind: "Node", Name: node},
+				Severity: models.SeverityWarning, Message: fmt.Sprintf("no node agent reported in the %s since healthctl started", now.Sub(s.started).Round(time.Minute))})
+			continue
+		case now.Sub(report.Time) > s.StaleAfter:
+			agents.Findings = append(agents.Findings, models.Finding{Resource: models.ResourceRef{Kind: "Node", Name: node},
+				Severity: models.SeverityWarning, Message: fmt.Sprintf("the node agent has not reported for %s", now.Sub(report.Time).Round(time.Minute))})
+			continue
+		}
+		reporting++
+		for _, check := range report.Checks {
+			if _, found := byLabel[check.Label]; !found {
+				labels = append(labels, check.Label)
+			}
+			byLabel[check.Label] = append(byLabel[check.Label], nodeResult{node: node, check: check})
+		}
+		if report.Network != nil {
+			samples[node] = report.Network
+		}
+	}
+	agents.Status = len(agents.Findings) == 0
+	agents.Details = fmt.Sprintf("%d of %d node agents reporting", reporting, len(nodes))
+	if pending > 0 {
+		agents.Details += fmt.Sprintf(", %d not reported since healthctl started", pending)
+	}
+
+	results := []models.ResourceCheck{agents}
+	for _, label := range labels {
+		results = append(results, merge(label, byLabel[label]))
+	}
+	if len(samples) > 0 {
+		results = append(results, networkCheck(clientset, samples, s.Network))
+	}
+	return results
+}
+
+type nodeResult struct {
+	node  string
+	check models.ResourceCheck
+}
+
+// merge merges the results of a check on the nodes: it passes when it passed on every node it ran on, has an
+// error when it could not run on a node and is skipped when it was skipped on every node
+func merge(label string, results []nodeResult) models.ResourceCheck {
+	merged := models.ResourceCheck{Label: label, Status: true}
+	failing, errs, skipped := []string{}, []string{}, 0
+	for _, result := range results {
+		check := result.check
+		merged.Findings = append(merged.Findings, check.Findings...)
+		switch check.Result() {
+		case models.ResultError:
+			errs = append(errs, fmt.Sprintf("%s: %s", result.node, check.Error))
+		case models.ResultSkipped:
+			skipped++
+		case models.ResultFail:
+			merged.Status = false
+			failing = append(failing, fmt.Sprintf("%s: %s", result.node, check.Details))
+		}
+	}
+	if skipped == len(results) {
+		merged.Skipped = results[0].check.Skipped
+		return merged
+	}
+	if len(errs) > 0 {
+		merged.Error = strings.Join(errs[:min(len(errs), maxNodeDetails)], "; ")
+	}
+	ran := len(results) - skipped - len(errs)
+	merged.Details = fmt.Sprintf("passed on %d of %d nodes", ran-len(failing), ran)
+	if len(failing) > 0 {
+		merged.Details += ", " + strings.Join(failing[:min(len(failing), maxNodeDetails)], "; ")
+	}
+	return merged
+}
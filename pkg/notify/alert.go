@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+)
+
+// webhookPayload is the body Alertmanager posts to a webhook receiver
+type webhookPayload struct {
+	Status string `json:"status"`
+	Alerts []struct {
+		Status      string            `json:"status"`
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+		StartsAt    string            `json:"startsAt"`
+	} `json:"alerts"`
+}
+
+// ParseWebhook returns the firing alerts of an Alertmanager webhook notification, resolved alerts are skipped
+func ParseWebhook(body []byte) ([]k8s.Alert, error) {
+	payload := webhookPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("parsing alertmanager notification: %v", err)
+	}
+	alerts := []k8s.Alert{}
+	for _, alert := range payload.Alerts {
+		if alert.Status != "firing" {
+			continue
+		}
+		alerts = append(alerts, k8s.Alert{
+			AlertName: alert.Labels["alertname"],
+			Severity:  alert.Labels["severity"],
+			StartsAt:  alert.StartsAt,
+			PodName:   alert.Labels["pod"],
+			Namespace: alert.Labels["namespace"],
+			Node:      alert.Labels["node"],
+			Summary:   alert.Annotations["summary"],
+		})
+	}
+	return alerts, nil
+}
+
+// AlertText is the slack message of a firing alert with the findings of the checks run for it
+func AlertText(cluster string, alert k8s.Alert, result []models.Finding) string {
+	var text strings.Builder
+	target := alert.Namespace
+	if alert.PodName != "" {
+		target += "/" + alert.PodName
+	}
+	fmt.Fprintf(&text, "*%s* [%s] firing for *%s* on cluster *%s*", alert.AlertName, alert.Severity, target, cluster)
+	if alert.Summary != "" {
+		fmt.Fprintf(&text, ": %s", alert.Summary)
+	}
+	text.WriteString("\n")
+
+	failing := failingFindings(result)
+	if len(failing) == 0 {
+		text.WriteString("healthctl found no failing checks for it\n")
+		return text.String()
+	}
+	fmt.Fprintf(&text, "healthctl found %d failing findings:\n", len(failing))
+	writeFindings(&text, failing)
+	return text.String()
+}
@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"healthctl/pkg/models"
+)
+
+// maxRequestAge rejects replayed slash command requests
+const maxRequestAge = 5 * time.Minute
+
+// SlashCommand is a slack slash command invocation
+type SlashCommand struct {
+	UserID      string
+	UserName    string
+	ChannelID   string
+	Command     string
+	Text        string
+	ResponseURL string
+}
+
+// VerifySlackRequest checks the signature slack computes over the request body with the signing secret of the app
+func VerifySlackRequest(secret, timestamp, signature string, body []byte) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid request timestamp %q", timestamp)
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > maxRequestAge || age < -maxRequestAge {
+		return fmt.Errorf("request timestamp is %s off", age.Round(time.Second))
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("invalid request signature")
+	}
+	return nil
+}
+
+// ParseSlashCommand parses the form slack posts for a slash command
+func ParseSlashCommand(body []byte) (SlashCommand, error) {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return SlashCommand{}, fmt.Errorf("parsing slash command: %v", err)
+	}
+	return SlashCommand{
+		UserID:      form.Get("user_id"),
+		UserName:    form.Get("user_name"),
+		ChannelID:   form.Get("channel_id"),
+		Command:     form.Get("command"),
+		Text:        strings.TrimSpace(form.Get("text")),
+		ResponseURL: form.Get("response_url"),
+	}, nil
+}
+
+type slashResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// SlashResponse is the body of a direct reply to a slash command, only visible to the user unless inChannel is set
+func SlashResponse(inChannel bool, text string) []byte {
+	response := slashResponse{ResponseType: "ephemeral", Text: text}
+	if inChannel {
+		response.ResponseType = "in_channel"
+	}
+	data, _ := json.Marshal(response)
+	return data
+}
+
+// Respond posts a delayed reply of a slash command to its response URL
+func Respond(responseURL string, inChannel bool, text string) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(responseURL, "application/json", bytes.NewBuffer(SlashResponse(inChannel, text)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack returned %s", resp.Status)
+	}
+	return nil
+}
+
+// ResultText is the slack message of a check run, a count of the check results followed by the failing findings
+func ResultText(title string, checks []models.CheckResult, result []models.Finding) string {
+	counts := make(map[string]int)
+	for _, check := range checks {
+		counts[check.Result]++
+	}
+	var text strings.Builder
+	fmt.Fprintf(&text, "*%s*: %d checks", title, len(checks))
+	for _, outcome := range []string{models.ResultPass, models.ResultFail, models.ResultError, models.ResultSkipped} {
+		if counts[outcome] > 0 {
+			fmt.Fprintf(&text, ", %d %s", counts[outcome], outcome)
+		}
+	}
+	text.WriteString("\n")
+	for _, check := range checks {
+		if check.Result == models.ResultError {
+			fmt.Fprintf(&text, "• %s could not run: %s\n", check.Check, check.Cause)
+		}
+	}
+	writeFindings(&text, failingFindings(result))
+	return text.String()
+}
+
+// failingFindings returns the findings that fail the suite
+func failingFindings(result []models.Finding) []models.Finding {
+	failing := []models.Finding{}
+	for _, finding := range result {
+		if finding.Failing() {
+			failing = append(failing, finding)
+		}
+	}
+	return failing
+}
+
+// writeFindings lists findings with their hints, at most maxSlackFindings of them
+func writeFindings(text *strings.Builder, result []models.Finding) {
+	for i, finding := range result {
+		if i == maxSlackFindings {
+			fmt.Fprintf(text, "... and %d more\n", len(result)-maxSlackFindings)
+			break
+		}
+		fmt.Fprintf(text, "• [%s] %s: %s\n", finding.Severity, finding.Resource, finding.Message)
+		if finding.Hint != "" {
+			fmt.Fprintf(text, "    _%s_\n", finding.Hint)
+		}
+	}
+}
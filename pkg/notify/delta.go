@@ -0,0 +1,203 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/maintenance"
+	"healthctl/pkg/models"
+)
+
+// DefaultReminder is how often a team is reminded of findings that stay open
+const DefaultReminder = 24 * time.Hour
+
+// Change is how a finding changed since its team was last notified
+type Change string
+
+const (
+	ChangeNew      Change = "new"
+	ChangeSeverity Change = "severity"
+	ChangeReminder Change = "reminder"
+	ChangeResolved Change = "resolved"
+)
+
+// Notified is a failing finding its team was told about
+type Notified struct {
+	Check    string             `json:"check"`
+	Team     string             `json:"team,omitempty"`
+	Resource models.ResourceRef `json:"resource"`
+	Severity models.Severity    `json:"severity"`
+	Message  string             `json:"message"`
+	Notified time.Time          `json:"notified"`
+}
+
+type delta struct {
+	change   Change
+	finding  models.Finding
+	previous models.Severity
+}
+
+// DeltaNotifier notifies teams only about the changes of their findings between runs: findings that
+// appear, change their severity or are resolved. Findings that stay open are repeated once per reminder
+// interval, a reminder interval of 0 turns reminders off. Findings in an open maintenance window are
+// left alone until it closes.
+type DeltaNotifier struct {
+	Slack       *config.SlackConfig
+	Reminder    time.Duration
+	Maintenance *maintenance.Calendar
+}
+
+// Notify sends every team the changes of its findings since the last run. The notified findings are
+// remembered in the state file, a team whose notification failed is notified again on the next run.
+// Findings of checks that did not run are not resolved, a failed check is not a resolution.
+func (d *DeltaNotifier) Notify(cluster string, checks []models.CheckResult, result []models.Finding, file string, now time.Time) error {
+	notified, err := loadNotified(file)
+	if err != nil {
+		return err
+	}
+	ran := make(map[string]bool)
+	for _, check := range checks {
+		ran[check.Check] = check.Result == models.ResultPass || check.Result == models.ResultFail
+	}
+
+	teams := make(map[string][]delta)
+	current := make(map[string]bool)
+	for _, finding := range result {
+		if !finding.Failing() {
+			continue
+		}
+		current[finding.ID] = true
+		if d.Maintenance.Muted(cluster, finding, now) {
+			continue
+		}
+		previous, found := notified[finding.ID]
+		switch {
+		case !found:
+			teams[finding.Team] = append(teams[finding.Team], delta{change: ChangeNew, finding: finding})
+		case previous.Severity != finding.Severity:
+			teams[finding.Team] = append(teams[finding.Team], delta{change: ChangeSeverity, finding: finding, previous: previous.Severity})
+		case d.Reminder > 0 && now.Sub(previous.Notified) >= d.Reminder:
+			teams[finding.Team] = append(teams[finding.Team], delta{change: ChangeReminder, finding: finding})
+		}
+	}
+	for id, previous := range notified {
+		if current[id] || !ran[previous.Check] {
+			continue
+		}
+		finding := models.Finding{ID: id, Check: previous.Check, Team: previous.Team, Resource: previous.Resource,
+			Severity: previous.Severity, Message: previous.Message}
+		if d.Maintenance.Muted(cluster, finding, now) {
+			continue
+		}
+		teams[previous.Team] = append(teams[previous.Team], delta{change: ChangeResolved, finding: finding})
+	}
+
+	errs := []string{}
+	for _, team := range sortedTeams(teams) {
+		changes := teams[team]
+		if err := SendSlack(d.Slack.ChannelFor(team), deltaText(cluster, team, changes)); err != nil {
+			errs = append(errs, fmt.Sprintf("team %s: %v", team, err))
+			continue
+		}
+		for _, change := range changes {
+			if change.change == ChangeResolved {
+				delete(notified, change.finding.ID)
+				continue
+			}
+			notified[change.finding.ID] = Notified{Check: change.finding.Check, Team: team, Resource: change.finding.Resource,
+				Severity: change.finding.Severity, Message: change.finding.Message, Notified: now}
+		}
+	}
+
+	if err := saveNotified(file, notified); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("slack notification failed for %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func sortedTeams(teams map[string][]delta) []string {
+	names := []string{}
+	for name := range teams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func deltaText(cluster, team string, changes []delta) string {
+	sections := []struct {
+		change Change
+		title  string
+	}{
+		{ChangeNew, "New"},
+		{ChangeSeverity, "Severity changed"},
+		{ChangeReminder, "Still open"},
+		{ChangeResolved, "Resolved"},
+	}
+	var text strings.Builder
+	fmt.Fprintf(&text, "*healthctl*: %d changed findings for team *%s* on cluster *%s*\n", len(changes), team, cluster)
+	for _, section := range sections {
+		listed := []delta{}
+		for _, change := range changes {
+			if change.change == section.change {
+				listed = append(listed, change)
+			}
+		}
+		if len(listed) == 0 {
+			continue
+		}
+		fmt.Fprintf(&text, "*%s*\n", section.title)
+		for i, change := range listed {
+			if i == maxSlackFindings {
+				fmt.Fprintf(&text, "... and %d more\n", len(listed)-maxSlackFindings)
+				break
+			}
+			finding := change.finding
+			if change.change == ChangeSeverity {
+				fmt.Fprintf(&text, "• [%s, was %s] %s: %s", finding.Severity, change.previous, finding.Resource, finding.Message)
+			} else {
+				fmt.Fprintf(&text, "• [%s] %s: %s", finding.Severity, finding.Resource, finding.Message)
+			}
+			if finding.DocURL != "" && change.change != ChangeResolved {
+				fmt.Fprintf(&text, " <%s|runbook>", finding.DocURL)
+			}
+			text.WriteString("\n")
+		}
+	}
+	return text.String()
+}
+
+func loadNotified(file string) (map[string]Notified, error) {
+	notified := make(map[string]Notified)
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return notified, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &notified); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", file, err)
+	}
+	return notified, nil
+}
+
+func saveNotified(file string, notified map[string]Notified) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(notified, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
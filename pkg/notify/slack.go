@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/findings"
+	"healthctl/pkg/models"
+)
+
+// maxSlackFindings limits the number of findings listed in a single message
+const maxSlackFindings = 20
+
+type slackMessage struct {
+	Channel string `json:"channel,omitempty"`
+	Text    string `json:"text"`
+}
+
+// SendSlack posts a message to a slack incoming webhook
+func SendSlack(channel config.SlackChannel, text string) error {
+	if channel.WebhookURL == "" {
+		return fmt.Errorf("no slack webhook configured")
+	}
+	data, err := json.Marshal(slackMessage{Channel: channel.Channel, Text: text})
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(channel.WebhookURL, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack returned %s", resp.Status)
+	}
+	return nil
+}
+
+// NotifyTeams sends every team the failing findings it owns to its own slack channel.
+// Teams without failing findings are not notified.
+func NotifyTeams(slack *config.SlackConfig, cluster string, result []models.Finding) error {
+	teams := findings.ByTeam(result)
+	errs := []string{}
+	for _, team := range findings.TeamNames(teams) {
+		text := slackText(cluster, team, teams[team])
+		if text == "" {
+			continue
+		}
+		if err := SendSlack(slack.ChannelFor(team), text); err != nil {
+			errs = append(errs, fmt.Sprintf("team %s: %v", team, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("slack notification failed for %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func slackText(cluster, team string, result []models.Finding) string {
+	failing := []models.Finding{}
+	for _, finding := range result {
+		if finding.Failing() {
+			failing = append(failing, finding)
+		}
+	}
+	if len(failing) == 0 {
+		return ""
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "*healthctl*: %d failing findings for team *%s* on cluster *%s*\n", len(failing), team, cluster)
+	for i, finding := range failing {
+		if i == maxSlackFindings {
+			fmt.Fprintf(&text, "... and %d more\n", len(failing)-maxSlackFindings)
+			break
+		}
+		fmt.Fprintf(&text, "• [%s] %s: %s", finding.Severity, finding.Resource, finding.Message)
+		if finding.DocURL != "" {
+			fmt.Fprintf(&text, " <%s|runbook>", finding.DocURL)
+		}
+		text.WriteString("\n")
+		if finding.Hint != "" {
+			fmt.Fprintf(&text, "    _%s_\n", finding.Hint)
+		}
+	}
+	return text.String()
+}
@@ -0,0 +1,345 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/healthcheck"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/maintenance"
+	"healthctl/pkg/models"
+	"healthctl/pkg/notify"
+	"healthctl/pkg/report"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// DefaultInterval is the time between the runs of HealthChecks without an interval
+const DefaultInterval = 15 * time.Minute
+
+// DefaultMinInterval is the shortest time between two runs of a HealthCheck
+const DefaultMinInterval = 5 * time.Minute
+
+// DefaultSuites are the suites HealthChecks may run when the operator does not configure them
+var DefaultSuites = []string{"k8s"}
+
+// maxFailedChecks limits the failed checks listed in a status
+const maxFailedChecks = 20
+
+// Controller runs the HealthChecks of a cluster when they are due and writes their results into their status
+type Controller struct {
+	Client  *k8s.K8sClient
+	Config  *config.Config
+	Cluster string
+	// Namespaces are the namespaces whose HealthChecks are reconciled, all namespaces when empty
+	Namespaces []string
+	// Interval is the interval of HealthChecks without one, defaults to DefaultInterval
+	Interval time.Duration
+	// MinInterval is the shortest time between two runs of a HealthCheck, also after a change of its spec,
+	// defaults to DefaultMinInterval
+	MinInterval time.Duration
+	// Suites are the suites HealthChecks may run, defaults to DefaultSuites
+	Suites []string
+	// StateDir keeps the finding history and the notified findings of every HealthCheck
+	StateDir    string
+	Maintenance *maintenance.Calendar
+	// Logf receives problems that do not stop a reconciliation
+	Logf func(format string, args ...interface{})
+}
+
+func (c *Controller) logf(format string, args ...interface{}) {
+	if c.Logf != nil {
+		c.Logf(format, args...)
+	}
+}
+
+// Due returns the HealthChecks whose spec changed since their last run or whose interval passed, the ones
+// that waited longest first. A changed spec runs no earlier than the minimum interval after the last run.
+func (c *Controller) Due(now time.Time) ([]HealthCheck, error) {
+	namespaces := c.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	due := []HealthCheck{}
+	for _, namespace := range namespaces {
+		list, err := c.Client.DynamicClient.Resource(Resource).Namespace(namespace).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("listing healthchecks: %v", err)
+		}
+		for _, item := range list.Items {
+			hc := HealthCheck{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &hc); err != nil {
+				c.logf("healthcheck %s/%s: %v", item.GetNamespace(), item.GetName(), err)
+				continue
+			}
+			if c.due(hc, now) {
+				due = append(due, hc)
+			}
+		}
+	}
+	slices.SortStableFunc(due, func(a, b HealthCheck) int {
+		return lastRun(a).Compare(lastRun(b))
+	})
+	return due, nil
+}
+
+func (c *Controller) due(hc HealthCheck, now time.Time) bool {
+	status := hc.Status
+	switch {
+	case status.LastRun == nil:
+		return true
+	case now.Before(status.LastRun.Add(c.minInterval())):
+		return false
+	case status.ObservedGeneration != hc.Generation:
+		return true
+	case hc.Spec.Suspend:
+		return status.Phase != PhaseSuspended
+	}
+	return !now.Before(status.LastRun.Add(c.interval(hc)))
+}
+
+// lastRun returns the time of the last run of a HealthCheck, the zero time when it never ran
+func lastRun(hc HealthCheck) time.Time {
+	if hc.Status.LastRun == nil {
+		return time.Time{}
+	}
+	return hc.Status.LastRun.Time
+}
+
+// interval returns the interval of a HealthCheck, at least the minimum interval. An invalid interval is
+// reported by Reconcile.
+func (c *Controller) interval(hc HealthCheck) time.Duration {
+	interval, err := time.ParseDuration(hc.Spec.Interval)
+	if err != nil || interval <= 0 {
+		interval = DefaultInterval
+		if c.Interval > 0 {
+			interval = c.Interval
+		}
+	}
+	return max(interval, c.minInterval())
+}
+
+func (c *Controller) minInterval() time.Duration {
+	if c.MinInterval > 0 {
+		return c.MinInterval
+	}
+	return DefaultMinInterval
+}
+
+// Reconcile runs the checks of a HealthCheck, notifies its route and writes the result into its status. A
+// HealthCheck that changed since it was listed is left for the next reconciliation.
+func (c *Controller) Reconcile(hc HealthCheck) error {
+	now := time.Now()
+	status := hc.Status
+	status.ObservedGeneration = hc.Generation
+	status.LastRun = &metav1.Time{Time: now}
+
+	if hc.Spec.Suspend {
+		status.Phase, status.Message = PhaseSuspended, "runs are suspended"
+		c.setHealthy(&status, metav1.ConditionUnknown, "Suspended", status.Message)
+	} else if checks, err := c.checks(hc.Spec); err != nil {
+		status.Phase, status.Message = PhaseFailed, err.Error()
+		c.setHealthy(&status, metav1.ConditionUnknown, "InvalidSpec", status.Message)
+	} else {
+		c.run(hc, checks, &status)
+	}
+
+	hc.Status = status
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&hc)
+	if err != nil {
+		return err
+	}
+	_, err = c.Client.DynamicClient.Resource(Resource).Namespace(hc.Namespace).UpdateStatus(context.Background(),
+		&unstructured.Unstructured{Object: object}, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("updating status of healthcheck %s/%s: %v", hc.Namespace, hc.Name, err)
+	}
+	return nil
+}
+
+// checks validates the spec and returns the checks it selects
+func (c *Controller) checks(spec HealthCheckSpec) ([]healthcheck.Check, error) {
+	if spec.Interval != "" {
+		if interval, err := time.ParseDuration(spec.Interval); err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid interval %q, use e.g. 5m or 1h", spec.Interval)
+		} else if interval < c.minInterval() {
+			return nil, fmt.Errorf("interval %s is below the minimum %s of the operator", spec.Interval, c.minInterval())
+		}
+	}
+	if notify := spec.Notify; notify != nil {
+		if notify.Severity != "" && notify.Severity != string(models.SeverityWarning) && notify.Severity != string(models.SeverityCritical) {
+			return nil, fmt.Errorf("invalid notify.severity %q, use warning or critical", notify.Severity)
+		}
+		if notify.Slack == nil {
+			return nil, fmt.Errorf("notify has no slack route")
+		}
+	}
+	allowed := c.Suites
+	if len(allowed) == 0 {
+		allowed = DefaultSuites
+	}
+	suites := spec.Suites
+	if len(suites) == 0 {
+		suites = allowed
+	}
+	for _, suite := range suites {
+		if !slices.Contains(allowed, suite) {
+			return nil, fmt.Errorf("suite %s is not allowed, the operator runs %s", suite, strings.Join(allowed, ", "))
+		}
+	}
+	checks, err := healthcheck.Suites(suites...)
+	if err != nil {
+		return nil, err
+	}
+	if len(spec.Checks) > 0 {
+		if checks = healthcheck.Matching(checks, spec.Checks...); len(checks) == 0 {
+			return nil, fmt.Errorf("no check matches %s", strings.Join(spec.Checks, ", "))
+		}
+	}
+	return checks, nil
+}
+
+// run runs the checks and sets the status from the report
+func (c *Controller) run(hc HealthCheck, checks []healthcheck.Check, status *HealthCheckStatus) {
+	runner := healthcheck.NewRunner(c.Client, c.Config, checks...)
+	runner.Cluster = c.Cluster
+	runner.Namespaces = hc.Spec.Namespaces
+	runner.HistoryFile = c.stateFile(hc, "history.json")
+	runner.Logf = func(format string, args ...interface{}) {
+		c.logf("healthcheck %s/%s: %s", hc.Namespace, hc.Name, fmt.Sprintf(format, args...))
+	}
+	r, err := runner.Run()
+	if err != nil {
+		status.Phase, status.Message = PhaseFailed, err.Error()
+		c.setHealthy(status, metav1.ConditionUnknown, "RunFailed", status.Message)
+		return
+	}
+
+	status.Score = r.Scorecard.Cluster.Score
+	status.Checks, status.Findings, status.FailedChecks = CheckCounts{}, FindingCounts{}, nil
+	for _, check := range r.Checks {
+		status.Checks.Total++
+		switch check.Result {
+		case models.ResultPass:
+			status.Checks.Passed++
+		case models.ResultFail:
+			status.Checks.Failed++
+			status.FailedChecks = append(status.FailedChecks, check.Check)
+		case models.ResultError:
+			status.Checks.Errors++
+			status.FailedChecks = append(status.FailedChecks, check.Check)
+		case models.ResultSkipped:
+			status.Checks.Skipped++
+		}
+	}
+	if len(status.FailedChecks) > maxFailedChecks {
+		status.FailedChecks = status.FailedChecks[:maxFailedChecks]
+	}
+	for _, finding := range r.Findings {
+		if finding.Suppressed {
+			continue
+		}
+		switch finding.Severity {
+		case models.SeverityCritical:
+			status.Findings.Critical++
+		case models.SeverityWarning:
+			status.Findings.Warning++
+		case models.SeverityInfo:
+			status.Findings.Info++
+		}
+	}
+
+	if exceeded := hc.Spec.Thresholds.exceeded(*status); len(exceeded) > 0 {
+		status.Phase, status.Message = PhaseUnhealthy, strings.Join(exceeded, ", ")
+		c.setHealthy(status, metav1.ConditionFalse, "ThresholdsExceeded", status.Message)
+	} else {
+		status.Phase, status.Message = PhaseHealthy, fmt.Sprintf("%d checks passed, %d failed", status.Checks.Passed, status.Checks.Failed+status.Checks.Errors)
+		c.setHealthy(status, metav1.ConditionTrue, "WithinThresholds", status.Message)
+	}
+
+	if hc.Spec.Notify != nil {
+		// the error may hold the webhook, it is only logged
+		if err := c.notify(hc, r); err != nil {
+			c.logf("healthcheck %s/%s: %v", hc.Namespace, hc.Name, err)
+			status.Message += "; notifying slack failed, see the log of healthctl serve"
+		}
+	}
+}
+
+// exceeded returns the thresholds the result of a run exceeds
+func (t Thresholds) exceeded(status HealthCheckStatus) []string {
+	maxCritical := 0
+	if t.MaxCritical != nil {
+		maxCritical = *t.MaxCritical
+	}
+	exceeded := []string{}
+	if t.MinScore != nil && status.Score < *t.MinScore {
+		exceeded = append(exceeded, fmt.Sprintf("score %d is below %d", status.Score, *t.MinScore))
+	}
+	if status.Findings.Critical > maxCritical {
+		exceeded = append(exceeded, fmt.Sprintf("%d critical findings, at most %d allowed", status.Findings.Critical, maxCritical))
+	}
+	if t.MaxWarning != nil && status.Findings.Warning > *t.MaxWarning {
+		exceeded = append(exceeded, fmt.Sprintf("%d warning findings, at most %d allowed", status.Findings.Warning, *t.MaxWarning))
+	}
+	if t.MaxErrors != nil && status.Checks.Errors > *t.MaxErrors {
+		exceeded = append(exceeded, fmt.Sprintf("%d checks could not run, at most %d allowed", status.Checks.Errors, *t.MaxErrors))
+	}
+	return exceeded
+}
+
+func (c *Controller) setHealthy(status *HealthCheckStatus, healthy metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: ConditionHealthy, Status: healthy, Reason: reason,
+		Message: message, ObservedGeneration: status.ObservedGeneration})
+}
+
+// notify sends the changes of the failing findings of the route severity to the slack channel of the route
+func (c *Controller) notify(hc HealthCheck, r report.Report) error {
+	route := hc.Spec.Notify
+	channel := config.SlackChannel{Channel: route.Slack.Channel}
+	if ref := route.Slack.WebhookSecretRef; ref != nil {
+		// the secret is always read in the namespace of the HealthCheck, a name can not point elsewhere
+		if errs := validation.IsDNS1123Subdomain(ref.Name); len(errs) > 0 {
+			return fmt.Errorf("invalid webhookSecretRef name %q", ref.Name)
+		}
+		webhook, err := c.Client.GetSecretValue(hc.Namespace, ref.Name, ref.Key)
+		if err != nil {
+			return fmt.Errorf("reading slack webhook from secret %s: %v", ref.Name, err)
+		}
+		channel.WebhookURL = strings.TrimSpace(webhook)
+	} else if c.Config.Notifier.Slack != nil {
+		channel.WebhookURL = c.Config.Notifier.Slack.WebhookURL
+	}
+	if channel.WebhookURL == "" {
+		return fmt.Errorf("no slack webhook, set notify.slack.webhookSecretRef or notifier.slack of the config")
+	}
+
+	lowest := models.SeverityWarning
+	if route.Severity != "" {
+		lowest = models.Severity(route.Severity)
+	}
+	result := slices.DeleteFunc(slices.Clone(r.Findings), func(finding models.Finding) bool {
+		return finding.Severity.Rank() < lowest.Rank()
+	})
+	notifier := notify.DeltaNotifier{Slack: &config.SlackConfig{SlackChannel: channel}, Reminder: notify.DefaultReminder, Maintenance: c.Maintenance}
+	return notifier.Notify(r.Cluster, r.Checks, result, c.stateFile(hc, "notified.json"), r.Generated)
+}
+
+// stateFile returns a state file of a HealthCheck, the directory is created when missing
+func (c *Controller) stateFile(hc HealthCheck, name string) string {
+	dir := filepath.Join(c.StateDir, hc.Namespace, hc.Name)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		c.logf("creating state directory of healthcheck %s/%s: %v", hc.Namespace, hc.Name, err)
+	}
+	return filepath.Join(dir, name)
+}
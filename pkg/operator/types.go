@@ -0,0 +1,117 @@
+// Package operator reconciles HealthCheck resources: teams define checks, thresholds and notification routes
+// as cluster resources managed by GitOps, and the operator in healthctl serve runs them and writes the
+// results into their status.
+package operator
+
+import (
+	_ "embed"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// CRD is the CustomResourceDefinition of HealthCheck
+//
+//go:embed healthcheck-crd.yaml
+var CRD string
+
+// Resource is the group, version and resource of HealthChecks
+var Resource = schema.GroupVersionResource{Group: "healthctl.io", Version: "v1alpha1", Resource: "healthchecks"}
+
+// Phases of a HealthCheck
+const (
+	PhaseHealthy   = "Healthy"
+	PhaseUnhealthy = "Unhealthy"
+	PhaseFailed    = "Failed"
+	PhaseSuspended = "Suspended"
+)
+
+// ConditionHealthy is the condition that is true while the findings stay within the thresholds
+const ConditionHealthy = "Healthy"
+
+// HealthCheck runs built-in checks on a schedule and reports their result in its status
+type HealthCheck struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HealthCheckSpec   `json:"spec"`
+	Status HealthCheckStatus `json:"status,omitempty"`
+}
+
+// HealthCheckSpec selects the checks, the namespaces they report on, when the check is healthy and who is
+// notified
+type HealthCheckSpec struct {
+	// Suites are the built-in suites to run, all suites when neither suites nor checks are set
+	Suites []string `json:"suites,omitempty"`
+	// Checks are globs over the check names, suite/label or label, only matching checks run
+	Checks []string `json:"checks,omitempty"`
+	// Namespaces are globs over the namespaces whose findings are reported, all namespaces and cluster
+	// scoped findings when empty
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Interval between runs, defaults to the interval of the operator
+	Interval string `json:"interval,omitempty"`
+	// Suspend stops the runs until it is unset
+	Suspend    bool       `json:"suspend,omitempty"`
+	Thresholds Thresholds `json:"thresholds,omitempty"`
+	Notify     *Route     `json:"notify,omitempty"`
+}
+
+// Thresholds are the limits of a healthy check, unset limits are not checked
+type Thresholds struct {
+	MinScore *int `json:"minScore,omitempty"`
+	// MaxCritical defaults to 0
+	MaxCritical *int `json:"maxCritical,omitempty"`
+	MaxWarning  *int `json:"maxWarning,omitempty"`
+	// MaxErrors is the number of checks that may not be able to run
+	MaxErrors *int `json:"maxErrors,omitempty"`
+}
+
+// Route sends the changes of the failing findings to slack after every run
+type Route struct {
+	// Severity is the lowest severity notified, defaults to warning
+	Severity string      `json:"severity,omitempty"`
+	Slack    *SlackRoute `json:"slack,omitempty"`
+}
+
+// SlackRoute is a slack channel, without a webhook secret the webhook of the notifier of the config is used
+type SlackRoute struct {
+	Channel          string        `json:"channel,omitempty"`
+	WebhookSecretRef *SecretKeyRef `json:"webhookSecretRef,omitempty"`
+}
+
+// SecretKeyRef is a key of a secret in the namespace of the HealthCheck
+type SecretKeyRef struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// HealthCheckStatus is the result of the last run
+type HealthCheckStatus struct {
+	ObservedGeneration int64        `json:"observedGeneration,omitempty"`
+	Phase              string       `json:"phase,omitempty"`
+	Message            string       `json:"message,omitempty"`
+	LastRun            *metav1.Time `json:"lastRun,omitempty"`
+	// Score is the health score of the reported findings
+	Score        int           `json:"score"`
+	Checks       CheckCounts   `json:"checks"`
+	Findings     FindingCounts `json:"findings"`
+	FailedChecks []string      `json:"failedChecks,omitempty"`
+
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// CheckCounts counts the checks of a run by their result
+type CheckCounts struct {
+	Total   int `json:"total"`
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Errors  int `json:"errors"`
+	Skipped int `json:"skipped"`
+}
+
+// FindingCounts counts the findings of a run by severity, suppressed findings are not counted
+type FindingCounts struct {
+	Critical int `json:"critical"`
+	Warning  int `json:"warning"`
+	Info     int `json:"info"`
+}
@@ -0,0 +1,32 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"healthctl/pkg/config"
+)
+
+// azureStore creates block blobs in an Azure Blob Storage container with a SAS token
+type azureStore struct {
+	cfg config.AzureOutput
+}
+
+func newAzure(cfg config.AzureOutput) *azureStore {
+	return &azureStore{cfg: cfg}
+}
+
+func (a *azureStore) put(name, contentType string, data []byte) error {
+	url := fmt.Sprintf("https://%s.blob.core.windows.net/%s/%s?%s", a.cfg.Account, a.cfg.Container,
+		escapePath(joinPrefix(a.cfg.Prefix, name)), strings.TrimPrefix(a.cfg.SASToken, "?"))
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	req.Header.Set("x-ms-version", "2021-08-06")
+	return send(req)
+}
@@ -0,0 +1,80 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"healthctl/pkg/config"
+)
+
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// gcsStore uploads objects into a Google Cloud Storage bucket with the JSON API
+type gcsStore struct {
+	cfg config.GCSOutput
+
+	mutex   sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newGCS(cfg config.GCSOutput) *gcsStore {
+	return &gcsStore{cfg: cfg}
+}
+
+func (g *gcsStore) put(name, contentType string, data []byte) error {
+	token, err := g.accessToken()
+	if err != nil {
+		return fmt.Errorf("gcs bucket %s: %v", g.cfg.Bucket, err)
+	}
+	uploadURL := fmt.Sprintf("https://storage.googleapis.com/upload/storage/v1/b/%s/o?uploadType=media&name=%s",
+		url.PathEscape(g.cfg.Bucket), url.QueryEscape(joinPrefix(g.cfg.Prefix, name)))
+	req, err := http.NewRequest(http.MethodPost, uploadURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return send(req)
+}
+
+// accessToken returns the configured token or the token of the service account from the metadata server
+func (g *gcsStore) accessToken() (string, error) {
+	if g.cfg.Token != "" {
+		return g.cfg.Token, nil
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.token != "" && time.Now().Before(g.expires) {
+		return g.token, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, metadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("no token configured and the metadata server is not reachable: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned %s", resp.Status)
+	}
+	token := struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	// refresh a minute early so a token does not expire during an upload
+	g.token, g.expires = token.AccessToken, time.Now().Add(time.Duration(token.ExpiresIn)*time.Second-time.Minute)
+	return g.token, nil
+}
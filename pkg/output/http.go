@@ -0,0 +1,30 @@
+package output
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"healthctl/pkg/config"
+)
+
+// httpStore PUTs reports below a base URL
+type httpStore struct {
+	cfg config.HTTPOutput
+}
+
+func newHTTP(cfg config.HTTPOutput) *httpStore {
+	return &httpStore{cfg: cfg}
+}
+
+func (h *httpStore) put(name, contentType string, data []byte) error {
+	req, err := http.NewRequest(http.MethodPut, strings.TrimSuffix(h.cfg.URL, "/")+"/"+escapePath(name), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range h.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	return send(req)
+}
@@ -0,0 +1,163 @@
+// Package output uploads reports to S3, Google Cloud Storage, Azure Blob Storage or an HTTP endpoint after
+// every run, for long-term archival
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/report"
+)
+
+const defaultName = "{{.Cluster}}/{{.Timestamp}}.{{.Extension}}"
+
+// extensions are the file extensions and content types of the report formats
+var extensions = map[string][2]string{
+	"json":   {"json", "application/json"},
+	"html":   {"html", "text/html; charset=utf-8"},
+	"text":   {"txt", "text/plain; charset=utf-8"},
+	"ndjson": {"ndjson", "application/x-ndjson"},
+	"csv":    {"csv", "text/csv; charset=utf-8"},
+}
+
+// store is a place reports are uploaded to under a name
+type store interface {
+	put(name, contentType string, data []byte) error
+}
+
+// Output renders the report of every run in its format and uploads it under its name template
+type Output struct {
+	format string
+	writer report.Writer
+	name   *template.Template
+	store  store
+}
+
+// nameData are the values of the name template
+type nameData struct {
+	Cluster   string
+	Labels    map[string]string
+	Generated time.Time
+	Timestamp string
+	Extension string
+}
+
+// New returns the output of the configuration
+func New(cfg config.Output) (*Output, error) {
+	format := cfg.Format
+	if format == "" {
+		format = "json"
+	}
+	writer, err := report.GetWriter(format)
+	if err != nil {
+		return nil, err
+	}
+	nameTemplate := cfg.Name
+	if nameTemplate == "" {
+		nameTemplate = defaultName
+	}
+	name, err := template.New("name").Option("missingkey=zero").Parse(nameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output name %q: %v", nameTemplate, err)
+	}
+
+	var s store
+	configured := 0
+	if cfg.S3 != nil {
+		s, configured = newS3(*cfg.S3), configured+1
+	}
+	if cfg.GCS != nil {
+		s, configured = newGCS(*cfg.GCS), configured+1
+	}
+	if cfg.Azure != nil {
+		s, configured = newAzure(*cfg.Azure), configured+1
+	}
+	if cfg.HTTP != nil {
+		s, configured = newHTTP(*cfg.HTTP), configured+1
+	}
+	if configured != 1 {
+		return nil, fmt.Errorf("an output needs exactly one of s3, gcs, azure or http")
+	}
+	return &Output{format: format, writer: writer, name: name, store: s}, nil
+}
+
+// FromConfig returns the outputs of the configuration
+func FromConfig(cfg *config.Config) ([]*Output, error) {
+	outputs := []*Output{}
+	for i, outputConfig := range cfg.Outputs {
+		output, err := New(outputConfig)
+		if err != nil {
+			return nil, fmt.Errorf("outputs[%d]: %v", i, err)
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs, nil
+}
+
+// Report renders and uploads the report, it implements the Reporter of pkg/healthcheck
+func (o *Output) Report(r report.Report) error {
+	extension, contentType := o.format, "application/octet-stream"
+	if known, found := extensions[o.format]; found {
+		extension, contentType = known[0], known[1]
+	}
+	var name strings.Builder
+	err := o.name.Execute(&name, nameData{
+		Cluster:   r.Cluster,
+		Labels:    r.Labels,
+		Generated: r.Generated,
+		Timestamp: r.Generated.UTC().Format("20060102T150405Z"),
+		Extension: extension,
+	})
+	if err != nil {
+		return fmt.Errorf("naming report: %v", err)
+	}
+	var data bytes.Buffer
+	if err := o.writer.Write(&data, r); err != nil {
+		return err
+	}
+	return o.store.put(strings.TrimPrefix(name.String(), "/"), contentType, data.Bytes())
+}
+
+// Upload hands the report to every output and returns the errors of all outputs that failed
+func Upload(outputs []*Output, r report.Report) error {
+	failed := []string{}
+	for _, output := range outputs {
+		if err := output.Report(r); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("uploading report: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// joinPrefix puts the object name below the prefix of a bucket
+func joinPrefix(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + name
+}
+
+// send sends an upload request and returns an error with the response body when it is not accepted
+func send(req *http.Request) error {
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		// the query is left out, it holds the SAS token of azure
+		return fmt.Errorf("%s %s://%s%s returned %s: %s", req.Method, req.URL.Scheme, req.URL.Host, req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package profiles
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+)
+
+const (
+	// timeFormat is the capture time in the file names, profiles of one capture share it
+	timeFormat = "20060102-150405"
+	// DefaultKeep is how many captures are kept per pod
+	DefaultKeep = 5
+	// EvidenceWindow is how old profiles of a failing pod may be to be added to its evidence
+	EvidenceWindow = 24 * time.Hour
+)
+
+// Dir is where captured profiles are stored as <namespace>/<pod>/<time>-<profile>.pb.gz, JVM diagnostics
+// as <time>-<diagnostic>.txt
+var Dir = config.StatePath("profiles")
+
+// File is a stored profile
+type File struct {
+	Pod      models.ResourceRef
+	Name     string
+	Captured time.Time
+	Path     string
+	Size     int64
+}
+
+func podDir(namespace, pod string) string {
+	return filepath.Join(Dir, namespace, pod)
+}
+
+// extension returns the file extension of a profile, pprof profiles are gzipped protobuf
+func extension(name string) string {
+	if slices.Contains(k8s.PprofProfiles, name) {
+		return ".pb.gz"
+	}
+	return ".txt"
+}
+
+// Save stores the profiles or JVM diagnostics captured from a pod, those that failed are skipped
+func Save(namespace, pod string, captured time.Time, profiles []k8s.Profile) ([]File, error) {
+	dir := podDir(namespace, pod)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	saved := []File{}
+	for _, profile := range profiles {
+		if profile.Err != nil {
+			continue
+		}
+		file := File{
+			Pod:      models.ResourceRef{Kind: "Pod", Namespace: namespace, Name: pod},
+			Name:     profile.Name,
+			Captured: captured,
+			Path:     filepath.Join(dir, captured.Format(timeFormat)+"-"+profile.Name+extension(profile.Name)),
+			Size:     int64(len(profile.Data)),
+		}
+		if err := os.WriteFile(file.Path, profile.Data, 0600); err != nil {
+			return saved, err
+		}
+		saved = append(saved, file)
+	}
+	return saved, nil
+}
+
+// List returns the stored profiles of a pod, the oldest first
+func List(namespace, pod string) ([]File, error) {
+	entries, err := os.ReadDir(podDir(namespace, pod))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	files := []File{}
+	for _, entry := range entries {
+		base := strings.TrimSuffix(strings.TrimSuffix(entry.Name(), ".pb.gz"), ".txt")
+		if entry.IsDir() || len(base) < len(timeFormat)+2 || base[len(timeFormat)] != '-' {
+			continue
+		}
+		captured, err := time.ParseInLocation(timeFormat, base[:len(timeFormat)], time.Local)
+		if err != nil {
+			continue
+		}
+		name := base[len(timeFormat)+1:]
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, File{
+			Pod:      models.ResourceRef{Kind: "Pod", Namespace: namespace, Name: pod},
+			Name:     name,
+			Captured: captured,
+			Path:     filepath.Join(podDir(namespace, pod), entry.Name()),
+			Size:     info.Size(),
+		})
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Captured.Before(files[j].Captured) })
+	return files, nil
+}
+
+// LastCapture returns when profiles of a pod were captured last, the zero time when never
+func LastCapture(namespace, pod string) time.Time {
+	files, err := List(namespace, pod)
+	if err != nil || len(files) == 0 {
+		return time.Time{}
+	}
+	return files[len(files)-1].Captured
+}
+
+// Prune removes all but the newest keep captures of a pod
+func Prune(namespace, pod string, keep int) error {
+	if keep <= 0 {
+		keep = DefaultKeep
+	}
+	files, err := List(namespace, pod)
+	if err != nil {
+		return err
+	}
+	captures := []time.Time{}
+	for _, file := range files {
+		if len(captures) == 0 || !captures[len(captures)-1].Equal(file.Captured) {
+			captures = append(captures, file.Captured)
+		}
+	}
+	if len(captures) <= keep {
+		return nil
+	}
+	oldest := captures[len(captures)-keep]
+	for _, file := range files {
+		if file.Captured.Before(oldest) {
+			if err := os.Remove(file.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Recent returns the profiles of a pod captured since the given time
+func Recent(namespace, pod string, since time.Time) []File {
+	files, _ := List(namespace, pod)
+	recent := []File{}
+	for _, file := range files {
+		if !file.Captured.Before(since) {
+			recent = append(recent, file)
+		}
+	}
+	return recent
+}
+
+// Evidence lists the profiles and JVM diagnostics of a pod captured within the EvidenceWindow, the files
+// themselves are only added to support bundles
+func Evidence(ref models.ResourceRef) []models.Evidence {
+	if ref.Kind != "Pod" {
+		return nil
+	}
+	files := Recent(ref.Namespace, ref.Name, time.Now().Add(-EvidenceWindow))
+	if len(files) == 0 {
+		return nil
+	}
+	var content strings.Builder
+	pprof := false
+	for _, file := range files {
+		fmt.Fprintf(&content, "%s %-9s %8d bytes  %s\n", file.Captured.Format(time.RFC3339), file.Name, file.Size, file.Path)
+		pprof = pprof || extension(file.Name) == ".pb.gz"
+	}
+	if pprof {
+		content.WriteString("Open a profile with: go tool pprof <file>\n")
+	}
+	return []models.Evidence{{For: ref, Relation: "profiles", Resource: ref, Content: content.String()}}
+}
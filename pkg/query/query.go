@@ -0,0 +1,144 @@
+// Package query runs read-only SQL SELECT statements over the data healthctl collects: the runs and
+// findings of the results store, the node pool usage samples and the pod startups. The tables are loaded
+// into an in-memory SQLite database, so every SQLite feature including joins and subqueries is available.
+package query
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	// pure Go SQLite, registers the sqlite driver
+	_ "modernc.org/sqlite"
+)
+
+// Value is a cell of a table: nil, a float64 or a string. Times are RFC 3339 strings in UTC, so they
+// compare and sort in time order.
+type Value interface{}
+
+// Table is the rows of a table, every row has a value per column
+type Table struct {
+	Name    string
+	Columns []string
+	Rows    [][]Value
+}
+
+// Result is the output of a query
+type Result struct {
+	Columns []string
+	Rows    [][]Value
+}
+
+// Run loads the tables into an in-memory SQLite database and runs the SELECT statement on it. The
+// database is read-only while the statement runs and is discarded afterwards.
+func Run(statement string, tables map[string]*Table) (Result, error) {
+	keyword := ""
+	if fields := strings.Fields(statement); len(fields) > 0 {
+		keyword = strings.ToUpper(fields[0])
+	}
+	if keyword != "SELECT" && keyword != "WITH" {
+		return Result{}, fmt.Errorf("only SELECT statements are supported")
+	}
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return Result{}, err
+	}
+	defer db.Close()
+	// every connection to :memory: is a database of its own
+	db.SetMaxOpenConns(1)
+	for _, table := range tables {
+		if err := create(db, table); err != nil {
+			return Result{}, fmt.Errorf("loading table %s: %v", table.Name, err)
+		}
+	}
+	if _, err := db.Exec("PRAGMA query_only = ON"); err != nil {
+		return Result{}, err
+	}
+
+	rows, err := db.Query(statement)
+	if err != nil {
+		return Result{}, err
+	}
+	defer rows.Close()
+	result := Result{Rows: [][]Value{}}
+	if result.Columns, err = rows.Columns(); err != nil {
+		return Result{}, err
+	}
+	for rows.Next() {
+		row := make([]Value, len(result.Columns))
+		pointers := make([]interface{}, len(row))
+		for i := range row {
+			pointers[i] = &row[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return Result{}, err
+		}
+		for i, value := range row {
+			row[i] = normalize(value)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, rows.Err()
+}
+
+// create creates the table and inserts its rows in one transaction
+func create(db *sql.DB, table *Table) error {
+	columns := []string{}
+	placeholders := []string{}
+	for _, column := range table.Columns {
+		columns = append(columns, strconv.Quote(column))
+		placeholders = append(placeholders, "?")
+	}
+	if _, err := db.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", strconv.Quote(table.Name), strings.Join(columns, ", "))); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	insert, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s VALUES (%s)", strconv.Quote(table.Name), strings.Join(placeholders, ", ")))
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for _, row := range table.Rows {
+		args := make([]interface{}, len(row))
+		for i, value := range row {
+			args[i] = value
+		}
+		if _, err := insert.Exec(args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// normalize converts the values SQLite returns to the values of a table
+func normalize(value interface{}) Value {
+	switch value := value.(type) {
+	case int64:
+		return float64(value)
+	case []byte:
+		return string(value)
+	case bool:
+		if value {
+			return 1.0
+		}
+		return 0.0
+	}
+	return value
+}
+
+// Format renders a value for text and csv output, NULL is empty
+func Format(value Value) string {
+	if value == nil {
+		return ""
+	}
+	if value, isNumber := value.(float64); isNumber {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
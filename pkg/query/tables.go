@@ -0,0 +1,111 @@
+package query
+
+import (
+	"maps"
+	"slices"
+	"time"
+
+	"healthctl/pkg/capacity"
+	"healthctl/pkg/lifecycle"
+	"healthctl/pkg/results"
+)
+
+// Definition names a table and its columns
+type Definition struct {
+	Name        string
+	Description string
+	Columns     []string
+}
+
+// Definitions are the tables a query can select from
+var Definitions = []Definition{
+	{"runs", "a row per check run of every cluster in the results store of serve",
+		[]string{"cluster", "generated", "score", "checks", "failed", "errors", "critical", "warning", "suppressed"}},
+	{"findings", "the lifecycle of every finding of the results store, resolved findings are kept for 30 days",
+		[]string{"cluster", "id", "check_name", "kind", "namespace", "name", "severity", "state", "message", "opened", "resolved", "assignee"}},
+	{"usage", "the usage samples of the node pools recorded by the capacity check, cpu in millicores and memory in bytes",
+		[]string{"cluster", "pool", "time", "cpu_used", "cpu_capacity", "memory_used", "memory_capacity"}},
+	{"startups", "the pod startups recorded by the Pod Startup check, durations in seconds",
+		[]string{"cluster", "workload", "pod", "created", "scheduling", "image_pull", "readiness", "total"}},
+}
+
+// Sources are where the tables are read from, the usage and startup files are those of Cluster
+type Sources struct {
+	Store       *results.Store
+	Cluster     string
+	UsageFile   string
+	StartupFile string
+}
+
+// Load reads every table, missing files are empty tables
+func Load(sources Sources) (map[string]*Table, error) {
+	tables := make(map[string]*Table)
+	for _, definition := range Definitions {
+		tables[definition.Name] = &Table{Name: definition.Name, Columns: definition.Columns, Rows: [][]Value{}}
+	}
+
+	clusters, err := sources.Store.Clusters()
+	if err != nil {
+		return nil, err
+	}
+	for _, cluster := range clusters {
+		runs, err := sources.Store.Runs(cluster)
+		if err != nil {
+			return nil, err
+		}
+		for _, run := range runs {
+			tables["runs"].Rows = append(tables["runs"].Rows, []Value{cluster, timeValue(run.Generated), float64(run.Score),
+				float64(run.Checks), float64(run.Failed), float64(run.Errors), float64(run.Critical), float64(run.Warning), float64(run.Suppressed)})
+		}
+		records, err := sources.Store.Findings(cluster, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			var resolved, assignee Value
+			if record.Resolved != nil {
+				resolved = timeValue(*record.Resolved)
+			}
+			if record.Acknowledgement != nil {
+				assignee = record.Acknowledgement.Assignee
+			}
+			tables["findings"].Rows = append(tables["findings"].Rows, []Value{cluster, record.ID, record.Check, record.Resource.Kind,
+				record.Resource.Namespace, record.Resource.Name, string(record.Severity), string(record.State), record.Message,
+				timeValue(record.Opened), resolved, assignee})
+		}
+	}
+
+	usage, err := capacity.Load(sources.UsageFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, pool := range slices.Sorted(maps.Keys(usage)) {
+		for _, sample := range usage[pool] {
+			tables["usage"].Rows = append(tables["usage"].Rows, []Value{sources.Cluster, pool, timeValue(sample.Time), float64(sample.CPUUsed),
+				float64(sample.CPUCapacity), float64(sample.MemoryUsed), float64(sample.MemoryCapacity)})
+		}
+	}
+
+	startups, err := lifecycle.Load(sources.StartupFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, workload := range slices.Sorted(maps.Keys(startups)) {
+		for _, startup := range startups[workload] {
+			var pull Value = startup.ImagePull.Seconds()
+			if startup.PullUnknown {
+				pull = nil
+			}
+			tables["startups"].Rows = append(tables["startups"].Rows, []Value{sources.Cluster, workload, startup.Pod, timeValue(startup.Created),
+				startup.Scheduling.Seconds(), pull, startup.Readiness.Seconds(), startup.Total.Seconds()})
+		}
+	}
+	return tables, nil
+}
+
+func timeValue(t time.Time) Value {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339)
+}
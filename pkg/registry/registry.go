@@ -0,0 +1,304 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dockerHub       = "registry-1.docker.io"
+	mediaTypeList   = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeIndex  = "application/vnd.oci.image.index.v1+json"
+	mediaTypeV2     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeOCI    = "application/vnd.oci.image.manifest.v1+json"
+	acceptManifests = mediaTypeList + "," + mediaTypeIndex + "," + mediaTypeV2 + "," + mediaTypeOCI
+)
+
+// ErrNotFound is returned when the registry does not have the image
+var ErrNotFound = errors.New("not found")
+
+// Credentials are the basic auth credentials of a registry, e.g. from an image pull secret
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Reference is a parsed image reference
+type Reference struct {
+	Registry   string
+	Repository string
+	// Reference is the tag or digest
+	Reference string
+}
+
+// ParseReference parses an image like nginx, quay.io/prometheus/node-exporter:v1.8.0 or repo@sha256:...
+func ParseReference(image string) Reference {
+	ref := Reference{Registry: dockerHub, Reference: "latest"}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.Reference = name[i+1:]
+		name = name[:i]
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.Reference = name[i+1:]
+		name = name[:i]
+	}
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.Registry = NormalizeRegistry(parts[0])
+		name = parts[1]
+	}
+	if ref.Registry == dockerHub && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	ref.Repository = name
+	return ref
+}
+
+// NormalizeRegistry returns the host of Docker Hub for its aliases docker.io and index.docker.io, other
+// registries are returned unchanged
+func NormalizeRegistry(registry string) string {
+	switch registry {
+	case "docker.io", "index.docker.io":
+		return dockerHub
+	}
+	return registry
+}
+
+// Name returns the image name without tag or digest, images of Docker Hub are named docker.io/... like
+// the container runtimes and cosign name them
+func (r Reference) Name() string {
+	if r.Registry == dockerHub {
+		return "docker.io/" + r.Repository
+	}
+	return r.Registry + "/" + r.Repository
+}
+
+// Client reads image manifests from registries, results are cached per image
+type Client struct {
+	// Credentials are looked up by registry host
+	Credentials map[string]Credentials
+
+	http  *http.Client
+	mutex sync.Mutex
+	cache map[string][]string
+}
+
+// NewClient returns a registry client with the given credentials per registry
+func NewClient(credentials map[string]Credentials) *Client {
+	return &Client{
+		Credentials: credentials,
+		http:        &http.Client{Timeout: 10 * time.Second},
+		cache:       map[string][]string{},
+	}
+}
+
+// Architectures returns the architectures an image is built for
+func (c *Client) Architectures(image string) ([]string, error) {
+	c.mutex.Lock()
+	cached, found := c.cache[image]
+	c.mutex.Unlock()
+	if found {
+		return cached, nil
+	}
+
+	ref := ParseReference(image)
+	manifest := struct {
+		MediaType string `json:"mediaType"`
+		Manifests []struct {
+			Platform struct {
+				Architecture string `json:"architecture"`
+				Variant      string `json:"variant"`
+			} `json:"platform"`
+		} `json:"manifests"`
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+	}{}
+	if err := c.get(ref, "/manifests/"+ref.Reference, acceptManifests, &manifest); err != nil {
+		return nil, err
+	}
+
+	architectures := []string{}
+	if len(manifest.Manifests) > 0 {
+		seen := map[string]bool{}
+		for _, m := range manifest.Manifests {
+			arch := m.Platform.Architecture
+			if arch == "" || arch == "unknown" || seen[arch] {
+				continue
+			}
+			seen[arch] = true
+			architectures = append(architectures, arch)
+		}
+	} else {
+		// a single platform image, the architecture is in the image config
+		config := struct {
+			Architecture string `json:"architecture"`
+		}{}
+		if err := c.get(ref, "/blobs/"+manifest.Config.Digest, "", &config); err != nil {
+			return nil, err
+		}
+		architectures = append(architectures, config.Architecture)
+	}
+
+	c.mutex.Lock()
+	c.cache[image] = architectures
+	c.mutex.Unlock()
+	return architectures, nil
+}
+
+// Exists returns true when the registry has a manifest for the image
+func (c *Client) Exists(image string) (bool, error) {
+	ref := ParseReference(image)
+	manifest := struct {
+		MediaType string `json:"mediaType"`
+	}{}
+	err := c.get(ref, "/manifests/"+ref.Reference, acceptManifests, &manifest)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// get fetches a registry path as json, answering a bearer token challenge when the registry asks for one
+func (c *Client) get(ref Reference, path, accept string, into interface{}) error {
+	url := fmt.Sprintf("https://%s/v2/%s%s", ref.Registry, ref.Repository, path)
+	resp, err := c.do(url, accept, "")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		authorization, err := c.authorize(ref, challenge)
+		if err != nil {
+			return err
+		}
+		if resp, err = c.do(url, accept, authorization); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s %s: %w", ref.Registry, ref.Repository, ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", ref.Registry, ref.Repository, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(into)
+}
+
+func (c *Client) do(url, accept, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return c.http.Do(req)
+}
+
+// authorize answers a Basic or Bearer challenge with the registry credentials, or anonymously
+func (c *Client) authorize(ref Reference, challenge string) (string, error) {
+	credentials, hasCredentials := c.Credentials[ref.Registry]
+	if ref.Registry == dockerHub && !hasCredentials {
+		credentials, hasCredentials = c.Credentials["docker.io"]
+	}
+	basic := ""
+	if hasCredentials {
+		basic = "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials.Username+":"+credentials.Password))
+	}
+	if strings.HasPrefix(challenge, "Basic") {
+		if basic == "" {
+			return "", fmt.Errorf("%s requires credentials", ref.Registry)
+		}
+		return basic, nil
+	}
+
+	params := parseChallenge(strings.TrimPrefix(challenge, "Bearer "))
+	if params["realm"] == "" {
+		return "", fmt.Errorf("%s: unsupported authentication challenge %q", ref.Registry, challenge)
+	}
+	req, err := http.NewRequest(http.MethodGet, params["realm"], nil)
+	if err != nil {
+		return "", err
+	}
+	query := req.URL.Query()
+	query.Set("service", params["service"])
+	query.Set("scope", fmt.Sprintf("repository:%s:pull", ref.Repository))
+	req.URL.RawQuery = query.Encode()
+	if basic != "" {
+		req.Header.Set("Authorization", basic)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s token: %s", ref.Registry, resp.Status)
+	}
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// parseChallenge parses realm="...",service="..." into a map
+func parseChallenge(challenge string) map[string]string {
+	params := map[string]string{}
+	for _, part := range strings.Split(challenge, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if found {
+			params[key] = strings.Trim(value, `"`)
+		}
+	}
+	return params
+}
+
+// DockerConfigCredentials parses the auths of a .dockerconfigjson pull secret
+func DockerConfigCredentials(data []byte) map[string]Credentials {
+	credentials := map[string]Credentials{}
+	config := struct {
+		Auths map[string]struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Auth     string `json:"auth"`
+		} `json:"auths"`
+	}{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return credentials
+	}
+	for server, auth := range config.Auths {
+		host := strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+		host = strings.SplitN(host, "/", 2)[0]
+		c := Credentials{Username: auth.Username, Password: auth.Password}
+		if auth.Auth != "" {
+			if decoded, err := base64.StdEncoding.DecodeString(auth.Auth); err == nil {
+				c.Username, c.Password, _ = strings.Cut(string(decoded), ":")
+			}
+		}
+		if host == "index.docker.io" {
+			host = "docker.io"
+		}
+		credentials[host] = c
+	}
+	return credentials
+}
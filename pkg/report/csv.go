@@ -0,0 +1,79 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"healthctl/pkg/k8s"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// CSVWriter writes a row per finding, for spreadsheets and BI tools. Every row repeats the cluster and
+// the time of the report, so the files of several runs can be combined into one table.
+type CSVWriter struct{}
+
+func (CSVWriter) Write(out io.Writer, r Report) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"cluster", "generated", "id", "check", "severity", "kind", "namespace", "name", "reason",
+		"message", "team", "firstSeen", "lastSeen", "suppressed"})
+	generated := r.Generated.UTC().Format(time.RFC3339)
+	for _, finding := range r.Findings {
+		w.Write([]string{r.Cluster, generated, finding.ID, finding.Check, string(finding.Severity), finding.Resource.Kind,
+			finding.Resource.Namespace, finding.Resource.Name, finding.Reason, finding.Message, finding.Team,
+			csvTime(finding.FirstSeen), csvTime(finding.LastSeen), strconv.FormatBool(finding.Suppressed)})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// WriteUsageCSV writes a row per container of the resource usage report. Cpu is in millicores and memory
+// in bytes, usage columns are empty for containers without metrics and requests or limits that are not set.
+func WriteUsageCSV(out io.Writer, cluster string, generated time.Time, usage k8s.ResourceUsageReport) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"cluster", "generated", "namespace", "workload", "pod", "container", "cpuUsage", "cpuRequest",
+		"cpuLimit", "cpuPercentOfRequest", "memoryUsage", "memoryRequest", "memoryLimit", "memoryPercentOfRequest"})
+	timestamp := generated.UTC().Format(time.RFC3339)
+	for _, pod := range usage.PodsUsage {
+		for _, container := range pod.ContainerUsages {
+			cpuUsage, memoryUsage, cpuPercent, memoryPercent := "", "", "", ""
+			if container.HasMetrics {
+				cpuUsage = csvQuantity(container.Usage, v1.ResourceCPU)
+				memoryUsage = csvQuantity(container.Usage, v1.ResourceMemory)
+				if _, found := container.Requests[v1.ResourceCPU]; found {
+					cpuPercent = strconv.FormatFloat(container.CPUUsage, 'f', 1, 64)
+				}
+				if _, found := container.Requests[v1.ResourceMemory]; found {
+					memoryPercent = strconv.FormatFloat(container.MemoryUsage, 'f', 1, 64)
+				}
+			}
+			w.Write([]string{cluster, timestamp, pod.Namespace, pod.Workload, pod.PodName, container.Name,
+				cpuUsage, csvQuantity(container.Requests, v1.ResourceCPU), csvQuantity(container.Limits, v1.ResourceCPU), cpuPercent,
+				memoryUsage, csvQuantity(container.Requests, v1.ResourceMemory), csvQuantity(container.Limits, v1.ResourceMemory), memoryPercent})
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// csvQuantity returns cpu in millicores and other resources in their base unit, empty when not set
+func csvQuantity(resources v1.ResourceList, name v1.ResourceName) string {
+	quantity, found := resources[name]
+	if !found {
+		return ""
+	}
+	if name == v1.ResourceCPU {
+		return fmt.Sprint(quantity.MilliValue())
+	}
+	return fmt.Sprint(quantity.Value())
+}
+
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,80 @@
+package report
+
+import (
+	"html/template"
+	"io"
+	"time"
+
+	"healthctl/pkg/models"
+)
+
+// HTMLWriter renders the report as a self-contained html page, e.g. for archives that are opened in a browser
+type HTMLWriter struct{}
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"time":   func(t time.Time) string { return t.Format(time.RFC3339) },
+	"labels": FormatLabels,
+	"status": func(f models.Finding) string {
+		switch {
+		case f.Suppressed:
+			return "suppressed"
+		case f.Failing():
+			return "fail"
+		}
+		return "info"
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>healthctl report {{.Cluster}} {{time .Generated}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+.fail, .critical { color: #b00020; }
+.warning, .error { color: #b36b00; }
+.suppressed, .skipped { color: #777; }
+.pass { color: #1b7a2f; }
+</style>
+</head>
+<body>
+<h1>{{.Cluster}}: {{.Scorecard.Cluster.Score}}/100</h1>
+<p>Generated {{time .Generated}}{{if .Labels}}, cluster labels {{labels .Labels}}{{end}}{{if .Hidden}}, {{.Hidden}} known findings hidden by the baseline{{end}}</p>
+
+<h2>Findings</h2>
+<table>
+<tr><th>ID</th><th>Status</th><th>Severity</th><th>Team</th><th>Check</th><th>Resource</th><th>First seen</th><th>Message</th></tr>
+{{range .Findings}}<tr class="{{status .}}"><td>{{.ID}}</td><td>{{status .}}</td><td class="{{.Severity}}">{{.Severity}}</td><td>{{.Team}}</td><td>{{.Check}}</td><td>{{.Resource}}</td><td>{{time .FirstSeen}}</td><td>{{.Message}}{{if .Suppressed}} (suppressed: {{.SuppressionReason}}){{end}}{{if .Hint}}<br><small>{{.Hint}}{{if .DocURL}} <a href="{{.DocURL}}">runbook</a>{{end}}</small>{{end}}</td></tr>
+{{end}}</table>
+
+<h2>Checks</h2>
+<table>
+<tr><th>Check</th><th>Result</th><th>Details</th></tr>
+{{range .Checks}}<tr><td>{{.Check}}</td><td class="{{.Result}}">{{.Result}}</td><td>{{.Details}}{{if .Cause}}<br><small>{{.Cause}}</small>{{end}}</td></tr>
+{{end}}</table>
+{{if .Teams}}
+<h2>Teams</h2>
+<table>
+<tr><th>Team</th><th>Findings</th><th>Failing</th><th>Suppressed</th></tr>
+{{range .Teams}}<tr><td>{{.Team}}</td><td>{{.Total}}</td><td>{{.Failing}}</td><td>{{.Suppressed}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Namespaces</h2>
+<table>
+<tr><th>Namespace</th><th>Score</th><th>Critical</th><th>Warning</th><th>Info</th></tr>
+{{range .Scorecard.Namespaces}}<tr><td>{{.Name}}</td><td>{{.Score}}</td><td>{{.Critical}}</td><td>{{.Warning}}</td><td>{{.Info}}</td></tr>
+{{end}}</table>
+{{if .Evidence}}
+<h2>Evidence</h2>
+{{range .Evidence}}<h3>{{.Relation}} of {{.For}}: {{.Resource}}</h3>
+<pre>{{.Content}}</pre>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+func (HTMLWriter) Write(out io.Writer, r Report) error {
+	return htmlReport.Execute(out, r)
+}
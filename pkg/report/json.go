@@ -0,0 +1,15 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSONWriter renders the report as indented json
+type JSONWriter struct{}
+
+func (JSONWriter) Write(out io.Writer, r Report) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
@@ -0,0 +1,81 @@
+package report
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"healthctl/pkg/findings"
+	"healthctl/pkg/models"
+)
+
+// Shard is the part of the namespaces of a cluster a report covers, reports of agents that each check
+// one shard are merged into the report of the cluster
+type Shard struct {
+	Index int `json:"index"`
+	Count int `json:"count"`
+}
+
+// resultRank orders check results, the worst result of a check over the shards wins
+var resultRank = map[string]int{models.ResultSkipped: 0, models.ResultPass: 1, models.ResultFail: 2, models.ResultError: 3}
+
+// Merge merges the reports of the shards of a cluster. A check has its worst result over the shards with
+// the distinct details joined, findings every shard reports, like those of nodes, are kept once, and the
+// scorecard is computed over the merged findings.
+func Merge(shards []Report) Report {
+	merged := Report{APIVersion: APIVersion, Kind: Kind, Labels: map[string]string{}}
+	checks := make(map[string]int)
+	seen := make(map[string]bool)
+	namespaces := make(map[string]bool)
+	teams := false
+	for _, shard := range shards {
+		merged.Cluster = shard.Cluster
+		maps.Copy(merged.Labels, shard.Labels)
+		if shard.Generated.After(merged.Generated) {
+			merged.Generated = shard.Generated
+		}
+		for _, check := range shard.Checks {
+			i, found := checks[check.Check]
+			if !found {
+				checks[check.Check] = len(merged.Checks)
+				merged.Checks = append(merged.Checks, check)
+				continue
+			}
+			m := &merged.Checks[i]
+			if resultRank[check.Result] > resultRank[m.Result] {
+				m.Result = check.Result
+			}
+			m.Details = joinDistinct(m.Details, check.Details)
+			m.Cause = joinDistinct(m.Cause, check.Cause)
+		}
+		for _, finding := range shard.Findings {
+			if !seen[finding.ID] {
+				seen[finding.ID] = true
+				merged.Findings = append(merged.Findings, finding)
+			}
+		}
+		for _, score := range shard.Scorecard.Namespaces {
+			if score.Name != findings.ClusterScope {
+				namespaces[score.Name] = true
+			}
+		}
+		merged.Hidden += shard.Hidden
+		merged.Evidence = append(merged.Evidence, shard.Evidence...)
+		teams = teams || len(shard.Teams) > 0
+	}
+	merged.Scorecard = findings.NewScorecard(merged.Findings, slices.Sorted(maps.Keys(namespaces)))
+	if teams {
+		merged.Teams = TeamSummaries(merged.Findings)
+	}
+	return merged
+}
+
+func joinDistinct(joined, value string) string {
+	if value == "" || slices.Contains(strings.Split(joined, "; "), value) {
+		return joined
+	}
+	if joined == "" {
+		return value
+	}
+	return joined + "; " + value
+}
@@ -0,0 +1,73 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"healthctl/pkg/models"
+)
+
+// Kinds of the ndjson records besides the report itself
+const (
+	KindCheckResult = "CheckResult"
+	KindFinding     = "Finding"
+)
+
+// Record is a line of the ndjson output: a check result, a finding or the complete report as last line.
+// The kind tells which of the fields is set.
+type Record struct {
+	APIVersion string              `json:"apiVersion"`
+	Kind       string              `json:"kind"`
+	Check      *models.CheckResult `json:"check,omitempty"`
+	Finding    *models.Finding     `json:"finding,omitempty"`
+	Report     *Report             `json:"report,omitempty"`
+}
+
+// NDJSONWriter writes a json record per line, the check results and findings followed by the report
+type NDJSONWriter struct{}
+
+func (NDJSONWriter) Write(out io.Writer, r Report) error {
+	stream := NewStream(out)
+	if err := stream.Checks(r.Checks, r.Findings); err != nil {
+		return err
+	}
+	return stream.Report(r)
+}
+
+// Stream writes the ndjson records of the checks while they run, so tools can act on the findings of a
+// long run before it ends. The last record is the report, whose findings have the finding history
+// applied and may differ from the streamed ones, e.g. when a finding needs several runs to open.
+type Stream struct {
+	encoder *json.Encoder
+	mutex   sync.Mutex
+}
+
+// NewStream returns a stream writing to out
+func NewStream(out io.Writer) *Stream {
+	return &Stream{encoder: json.NewEncoder(out)}
+}
+
+// Checks writes a record for every check result and finding
+func (s *Stream) Checks(checks []models.CheckResult, result []models.Finding) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range checks {
+		if err := s.encoder.Encode(Record{APIVersion: APIVersion, Kind: KindCheckResult, Check: &checks[i]}); err != nil {
+			return err
+		}
+	}
+	for i := range result {
+		if err := s.encoder.Encode(Record{APIVersion: APIVersion, Kind: KindFinding, Finding: &result[i]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Report writes the report as last record
+func (s *Stream) Report(r Report) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.encoder.Encode(Record{APIVersion: APIVersion, Kind: Kind, Report: &r})
+}
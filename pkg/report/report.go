@@ -0,0 +1,166 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"healthctl/pkg/findings"
+	"healthctl/pkg/models"
+	"healthctl/pkg/schema"
+)
+
+// APIVersion and Kind identify the schema of json reports. Within a major version the schema only grows:
+// fields are added, but never removed, renamed or changed in meaning, so automation built on a v1 report
+// keeps working with every later v1 report. A breaking change gets a new major version.
+const (
+	APIVersion = "healthctl/v1"
+	Kind       = "Report"
+)
+
+// Report is the result of a healthctl check run
+type Report struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	Cluster   string               `json:"cluster"`
+	Labels    map[string]string    `json:"labels,omitempty"`
+	Generated time.Time            `json:"generated"`
+	Checks    []models.CheckResult `json:"checks"`
+	Findings  []models.Finding     `json:"findings"`
+	Hidden    int                  `json:"hiddenByBaseline,omitempty"`
+	Scorecard findings.Scorecard   `json:"scorecard"`
+	Teams     []TeamSummary        `json:"teams,omitempty"`
+	Evidence  []models.Evidence    `json:"evidence,omitempty"`
+	// Shard is set on the partial reports of agents that check a shard of the namespaces of a cluster
+	Shard *Shard `json:"shard,omitempty"`
+}
+
+// Decode reads a json report. Reports written before the schema was versioned are read as v1, reports of
+// another major version are rejected because their fields may have changed meaning.
+func Decode(in io.Reader) (Report, error) {
+	r := Report{}
+	if err := json.NewDecoder(in).Decode(&r); err != nil {
+		return r, err
+	}
+	return r, r.CheckVersion()
+}
+
+// CheckVersion verifies the report has the current schema version, an unversioned report is upgraded to it
+func (r *Report) CheckVersion() error {
+	if r.APIVersion == "" && r.Kind == "" {
+		r.APIVersion, r.Kind = APIVersion, Kind
+	}
+	if r.Kind != Kind {
+		return fmt.Errorf("unsupported kind %q, expected %s", r.Kind, Kind)
+	}
+	if r.APIVersion != APIVersion {
+		return fmt.Errorf("unsupported report apiVersion %q, this healthctl reads %s", r.APIVersion, APIVersion)
+	}
+	return nil
+}
+
+// Schema returns the JSON Schema of the json report
+func Schema() map[string]interface{} {
+	s := schema.For(reflect.TypeOf(Report{}))
+	// later reports of the same major version add fields, which must not fail validation
+	schema.Extensible(s)
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["title"] = "healthctl report " + APIVersion
+	properties := s["properties"].(map[string]interface{})
+	properties["apiVersion"] = map[string]interface{}{"const": APIVersion}
+	properties["kind"] = map[string]interface{}{"const": Kind}
+	return s
+}
+
+// TeamSummary counts the findings owned by a team
+type TeamSummary struct {
+	Team       string `json:"team"`
+	Total      int    `json:"total"`
+	Failing    int    `json:"failing"`
+	Suppressed int    `json:"suppressed"`
+}
+
+// TeamSummaries counts the findings of every team
+func TeamSummaries(result []models.Finding) []TeamSummary {
+	teams := findings.ByTeam(result)
+	summaries := []TeamSummary{}
+	for _, team := range findings.TeamNames(teams) {
+		summary := TeamSummary{Team: team, Total: len(teams[team])}
+		for _, finding := range teams[team] {
+			if finding.Suppressed {
+				summary.Suppressed++
+			} else if finding.Failing() {
+				summary.Failing++
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// Writer renders a report in one output format
+type Writer interface {
+	Write(out io.Writer, r Report) error
+}
+
+var writers = map[string]Writer{
+	"text":   TextWriter{},
+	"json":   JSONWriter{},
+	"html":   HTMLWriter{},
+	"ndjson": NDJSONWriter{},
+	"csv":    CSVWriter{},
+}
+
+// GetWriter returns the writer for an output format
+func GetWriter(format string) (Writer, error) {
+	writer, found := writers[format]
+	if !found {
+		return nil, fmt.Errorf("unknown output format %q, supported formats are %v", format, Formats())
+	}
+	return writer, nil
+}
+
+// Formats returns the supported output formats
+func Formats() []string {
+	formats := []string{}
+	for format := range writers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// FormatLabels renders labels sorted by key as key=value pairs
+func FormatLabels(labels map[string]string) string {
+	pairs := []string{}
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// Failing returns true when at least one finding fails the suite
+func (r Report) Failing() bool {
+	for _, finding := range r.Findings {
+		if finding.Failing() {
+			return true
+		}
+	}
+	return false
+}
+
+// Incomplete returns true when at least one check could not run
+func (r Report) Incomplete() bool {
+	for _, check := range r.Checks {
+		if check.Result == models.ResultError {
+			return true
+		}
+	}
+	return false
+}
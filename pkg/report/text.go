@@ -0,0 +1,117 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"healthctl/pkg/models"
+)
+
+// TextWriter renders the report as terminal friendly tables
+type TextWriter struct{}
+
+func (TextWriter) Write(out io.Writer, r Report) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tSEVERITY\tTEAM\tCHECK\tRESOURCE\tFIRST SEEN\tMESSAGE")
+	failing, suppressed := 0, 0
+	for _, finding := range r.Findings {
+		status := "FAIL"
+		message := finding.Message
+		switch {
+		case finding.Suppressed:
+			status = "SUPPRESSED"
+			message = fmt.Sprintf("%s (suppressed: %s)", message, finding.SuppressionReason)
+			suppressed++
+		case finding.Failing():
+			failing++
+		default:
+			status = "INFO"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", finding.ID, status, finding.Severity, finding.Team, finding.Check, finding.Resource, finding.FirstSeen.Format(time.RFC3339), message)
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\n%d findings, %d failing, %d suppressed\n", len(r.Findings), failing, suppressed)
+	if r.Hidden > 0 {
+		fmt.Fprintf(out, "%d known findings hidden by the baseline\n", r.Hidden)
+	}
+
+	writeRemediation(out, r.Findings)
+
+	counts := make(map[string]int)
+	cached := 0
+	for _, check := range r.Checks {
+		counts[check.Result]++
+		if check.Cached {
+			cached++
+		}
+	}
+	fmt.Fprintf(out, "%d checks, %d passed, %d failed, %d errors, %d skipped\n", len(r.Checks),
+		counts[models.ResultPass], counts[models.ResultFail], counts[models.ResultError], counts[models.ResultSkipped])
+	if cached > 0 {
+		fmt.Fprintf(out, "%d check results reused from earlier runs\n", cached)
+	}
+	for _, check := range r.Checks {
+		if check.Cause != "" {
+			fmt.Fprintf(out, "  %s %s: %s\n", check.Check, check.Result, check.Cause)
+		}
+	}
+
+	if len(r.Teams) > 0 {
+		fmt.Fprintln(out)
+		w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TEAM\tFINDINGS\tFAILING\tSUPPRESSED")
+		for _, team := range r.Teams {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", team.Team, team.Total, team.Failing, team.Suppressed)
+		}
+		w.Flush()
+	}
+
+	fmt.Fprintf(out, "\nScorecard for %s: %d/100\n", r.Cluster, r.Scorecard.Cluster.Score)
+	if len(r.Labels) > 0 {
+		fmt.Fprintf(out, "Cluster labels: %s\n", FormatLabels(r.Labels))
+	}
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tSCORE\tCRITICAL\tWARNING\tINFO")
+	for _, score := range r.Scorecard.Namespaces {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\n", score.Name, score.Score, score.Critical, score.Warning, score.Info)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.Evidence) > 0 {
+		fmt.Fprintf(out, "\nEvidence\n")
+		for _, evidence := range r.Evidence {
+			fmt.Fprintf(out, "\n--- %s of %s: %s\n%s", evidence.Relation, evidence.For, evidence.Resource, evidence.Content)
+		}
+	}
+	return nil
+}
+
+// writeRemediation lists the hint and documentation link of every check with failing findings once
+func writeRemediation(out io.Writer, result []models.Finding) {
+	seen := make(map[string]bool)
+	for _, finding := range result {
+		if !finding.Failing() || (finding.Hint == "" && finding.DocURL == "") {
+			continue
+		}
+		key := finding.Check + "/" + finding.Hint + finding.DocURL
+		if seen[key] {
+			continue
+		}
+		if len(seen) == 0 {
+			fmt.Fprintf(out, "\nRemediation\n")
+		}
+		seen[key] = true
+		line := fmt.Sprintf("  %s: %s", finding.Check, finding.Hint)
+		if finding.DocURL != "" {
+			line += " " + finding.DocURL
+		}
+		fmt.Fprintln(out, line)
+	}
+	if len(seen) > 0 {
+		fmt.Fprintln(out)
+	}
+}
@@ -0,0 +1,355 @@
+// Package results stores the reports of healthctl serve: the latest report, a summary of every run for the
+// history charts and the lifecycle of the findings of each cluster
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"healthctl/pkg/models"
+	"healthctl/pkg/report"
+)
+
+// DefaultMaxRuns is the number of run summaries kept per cluster
+const DefaultMaxRuns = 2000
+
+// resolvedRetention is how long resolved findings are kept in the lifecycle
+const resolvedRetention = 30 * 24 * time.Hour
+
+// Record is the lifecycle of a finding on a cluster. A finding is open when first reported, acknowledged
+// once someone handles it, suppressed while a suppression matches, and resolved when no longer reported.
+// A resolved finding that is reported again is open again.
+type Record struct {
+	ID              string                  `json:"id"`
+	Check           string                  `json:"check"`
+	Resource        models.ResourceRef      `json:"resource"`
+	Severity        models.Severity         `json:"severity"`
+	Message         string                  `json:"message"`
+	State           models.FindingState     `json:"state"`
+	Acknowledgement *models.Acknowledgement `json:"acknowledgement,omitempty"`
+	Opened          time.Time               `json:"opened"`
+	Resolved        *time.Time              `json:"resolved,omitempty"`
+}
+
+// Run summarizes one check run of a cluster for the history charts
+type Run struct {
+	Generated  time.Time `json:"generated"`
+	Score      int       `json:"score"`
+	Checks     int       `json:"checks"`
+	Failed     int       `json:"failed"`
+	Errors     int       `json:"errors"`
+	Critical   int       `json:"critical"`
+	Warning    int       `json:"warning"`
+	Suppressed int       `json:"suppressed"`
+	// Labels are the inventory labels of the cluster at the time of the run
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Summarize counts the check results and findings of a report
+func Summarize(r report.Report) Run {
+	run := Run{Generated: r.Generated, Score: r.Scorecard.Cluster.Score, Checks: len(r.Checks), Labels: r.Labels}
+	for _, check := range r.Checks {
+		switch check.Result {
+		case models.ResultFail:
+			run.Failed++
+		case models.ResultError:
+			run.Errors++
+		}
+	}
+	for _, finding := range r.Findings {
+		switch {
+		case finding.Suppressed:
+			run.Suppressed++
+		case finding.Severity == models.SeverityCritical:
+			run.Critical++
+		case finding.Severity == models.SeverityWarning:
+			run.Warning++
+		}
+	}
+	return run
+}
+
+// Store keeps the latest report, the run history and the finding lifecycle of every cluster in a directory
+// per cluster
+type Store struct {
+	Dir     string
+	MaxRuns int
+	mutex   sync.Mutex
+}
+
+// NewStore returns a store in the directory, it is created on the first save
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir, MaxRuns: DefaultMaxRuns}
+}
+
+func (s *Store) dir(cluster string) string {
+	return filepath.Join(s.Dir, url.PathEscape(cluster))
+}
+
+func (s *Store) file(cluster, name string) string {
+	return filepath.Join(s.dir(cluster), name)
+}
+
+// HistoryFile is the finding history of a cluster, kept next to its reports
+func (s *Store) HistoryFile(cluster string) string {
+	return s.file(cluster, "history.json")
+}
+
+// TicketFile holds the tickets opened for the findings of a cluster
+func (s *Store) TicketFile(cluster string) string {
+	return s.file(cluster, "tickets.json")
+}
+
+// NotificationFile remembers which findings of a cluster the teams were notified about
+func (s *Store) NotificationFile(cluster string) string {
+	return s.file(cluster, "notifications.json")
+}
+
+// EventFile remembers which findings of a cluster events were recorded for
+func (s *Store) EventFile(cluster string) string {
+	return s.file(cluster, "events.json")
+}
+
+// Save stores the report as the latest of its cluster, appends it to the run history and tracks the
+// lifecycle of its findings
+func (s *Store) Save(r report.Report) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := os.MkdirAll(s.dir(r.Cluster), 0755); err != nil {
+		return err
+	}
+	if err := writeJSON(s.file(r.Cluster, "latest.json"), r); err != nil {
+		return err
+	}
+
+	runs := []Run{}
+	if err := readJSON(s.file(r.Cluster, "runs.json"), &runs); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	runs = append(runs, Summarize(r))
+	if len(runs) > s.MaxRuns {
+		runs = runs[len(runs)-s.MaxRuns:]
+	}
+	if err := writeJSON(s.file(r.Cluster, "runs.json"), runs); err != nil {
+		return err
+	}
+
+	lifecycle, err := s.lifecycle(r.Cluster)
+	if err != nil {
+		return err
+	}
+	track(lifecycle, r.Findings, r.Generated)
+	return writeJSON(s.file(r.Cluster, "lifecycle.json"), lifecycle)
+}
+
+// track moves the records of a cluster to the state of the reported findings
+func track(lifecycle map[string]*Record, result []models.Finding, now time.Time) {
+	reported := make(map[string]bool)
+	for _, finding := range result {
+		reported[finding.ID] = true
+		record, found := lifecycle[finding.ID]
+		if !found || record.State == models.StateResolved {
+			record = &Record{ID: finding.ID, State: models.StateOpen, Opened: now}
+			lifecycle[finding.ID] = record
+		}
+		record.Check, record.Resource, record.Severity, record.Message = finding.Check, finding.Resource, finding.Severity, finding.Message
+		switch {
+		case finding.Suppressed:
+			record.State = models.StateSuppressed
+		case record.Acknowledgement != nil:
+			record.State = models.StateAcknowledged
+		default:
+			record.State = models.StateOpen
+		}
+	}
+	for id, record := range lifecycle {
+		if reported[id] {
+			continue
+		}
+		if record.State != models.StateResolved {
+			resolved := now
+			record.State, record.Resolved = models.StateResolved, &resolved
+		}
+		if now.Sub(*record.Resolved) > resolvedRetention {
+			delete(lifecycle, id)
+		}
+	}
+}
+
+// Replace overwrites the latest report of its cluster without adding a run, for changes like a new
+// suppression that should show before the next run
+func (s *Store) Replace(r report.Report) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := writeJSON(s.file(r.Cluster, "latest.json"), r); err != nil {
+		return err
+	}
+	lifecycle, err := s.lifecycle(r.Cluster)
+	if err != nil {
+		return err
+	}
+	track(lifecycle, r.Findings, r.Generated)
+	return writeJSON(s.file(r.Cluster, "lifecycle.json"), lifecycle)
+}
+
+// Latest returns the latest report of a cluster with the state and acknowledgement of its findings
+func (s *Store) Latest(cluster string) (report.Report, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	r := report.Report{}
+	if err := readJSON(s.file(cluster, "latest.json"), &r); err != nil {
+		return r, err
+	}
+	if err := r.CheckVersion(); err != nil {
+		return r, err
+	}
+	lifecycle, err := s.lifecycle(cluster)
+	if err != nil {
+		return r, err
+	}
+	for i := range r.Findings {
+		if record, found := lifecycle[r.Findings[i].ID]; found {
+			r.Findings[i].State = record.State
+			r.Findings[i].Acknowledgement = record.Acknowledgement
+		}
+	}
+	return r, nil
+}
+
+// Findings returns the lifecycle records of a cluster in a state, or all records for an empty state,
+// the most recently opened first
+func (s *Store) Findings(cluster string, state models.FindingState) ([]Record, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	lifecycle, err := s.lifecycle(cluster)
+	if err != nil {
+		return nil, err
+	}
+	records := []Record{}
+	for _, record := range lifecycle {
+		if state == "" || record.State == state {
+			records = append(records, *record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].Opened.Equal(records[j].Opened) {
+			return records[i].Opened.After(records[j].Opened)
+		}
+		return records[i].ID < records[j].ID
+	})
+	return records, nil
+}
+
+// Runs returns the run history of a cluster, oldest first
+func (s *Store) Runs(cluster string) ([]Run, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	runs := []Run{}
+	if err := readJSON(s.file(cluster, "runs.json"), &runs); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// Clusters returns the clusters with a stored report
+func (s *Store) Clusters() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	clusters := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if cluster, err := url.PathUnescape(entry.Name()); err == nil {
+			clusters = append(clusters, cluster)
+		}
+	}
+	sort.Strings(clusters)
+	return clusters, nil
+}
+
+// Acknowledge records who handles a reported finding of a cluster. Acknowledging again replaces the
+// assignee and comment, a suppressed finding stays suppressed.
+func (s *Store) Acknowledge(cluster, id string, ack models.Acknowledgement) error {
+	return s.update(cluster, id, func(record *Record) error {
+		if record.State == models.StateResolved {
+			return fmt.Errorf("finding %s is resolved", id)
+		}
+		record.Acknowledgement = &ack
+		if record.State == models.StateOpen {
+			record.State = models.StateAcknowledged
+		}
+		return nil
+	})
+}
+
+// Reopen removes the acknowledgement of a finding
+func (s *Store) Reopen(cluster, id string) error {
+	return s.update(cluster, id, func(record *Record) error {
+		record.Acknowledgement = nil
+		if record.State == models.StateAcknowledged {
+			record.State = models.StateOpen
+		}
+		return nil
+	})
+}
+
+func (s *Store) update(cluster, id string, update func(record *Record) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	lifecycle, err := s.lifecycle(cluster)
+	if err != nil {
+		return err
+	}
+	record, found := lifecycle[id]
+	if !found {
+		return fmt.Errorf("finding %s is not known on cluster %s", id, cluster)
+	}
+	if err := update(record); err != nil {
+		return err
+	}
+	return writeJSON(s.file(cluster, "lifecycle.json"), lifecycle)
+}
+
+func (s *Store) lifecycle(cluster string) (map[string]*Record, error) {
+	lifecycle := make(map[string]*Record)
+	if err := readJSON(s.file(cluster, "lifecycle.json"), &lifecycle); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return lifecycle, nil
+}
+
+func readJSON(file string, v any) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parsing %s: %v", file, err)
+	}
+	return nil
+}
+
+// writeJSON replaces the file atomically so readers never see a partial file
+func writeJSON(file string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
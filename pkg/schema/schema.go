@@ -0,0 +1,114 @@
+// Package schema generates JSON Schemas of Go types from their json tags, for the configuration file and
+// the report
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Field is a key of a json object with the Go type of its value
+type Field struct {
+	Name     string
+	Type     reflect.Type
+	Required bool
+}
+
+// Fields returns the keys of a struct by their json tags, embedded structs without a tag are inlined.
+// Keys without omitempty are required, except booleans whose zero value is meaningful.
+func Fields(t reflect.Type) []Field {
+	result := []Field{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			result = append(result, Fields(Indirect(f.Type))...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		result = append(result, Field{
+			Name:     name,
+			Type:     f.Type,
+			Required: !strings.Contains(options, "omitempty") && f.Type.Kind() != reflect.Bool,
+		})
+	}
+	return result
+}
+
+// Indirect returns the type pointers point to
+func Indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// For returns the JSON Schema of a Go type, objects do not allow additional properties
+func For(t reflect.Type) map[string]interface{} {
+	t = Indirect(t)
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": For(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": For(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []string{}
+		for _, f := range Fields(t) {
+			properties[f.Name] = For(f.Type)
+			if f.Required {
+				required = append(required, f.Name)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties, "additionalProperties": false}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]interface{}{}
+}
+
+// Extensible allows additional properties in all objects of a schema, so documents of later versions that
+// add fields still validate
+func Extensible(schema map[string]interface{}) {
+	if schema["additionalProperties"] == false {
+		delete(schema, "additionalProperties")
+	}
+	for _, key := range []string{"items", "additionalProperties"} {
+		if nested, ok := schema[key].(map[string]interface{}); ok {
+			Extensible(nested)
+		}
+	}
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		for _, property := range properties {
+			if nested, ok := property.(map[string]interface{}); ok {
+				Extensible(nested)
+			}
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Package snapshot keeps the findings and resource usage of a cluster at one point in time and diffs two
+// snapshots, e.g. before and after a deploy
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"healthctl/pkg/findings"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+	"healthctl/pkg/report"
+)
+
+// Kind is the kind of snapshot files
+const Kind = "HealthSnapshot"
+
+// Usage is the resource usage of a namespace, cpu in millicores and memory in bytes
+type Usage struct {
+	CPU    int64 `json:"cpu"`
+	Memory int64 `json:"memory"`
+}
+
+// Snapshot is the findings of a cluster and the usage of its namespaces at one point in time
+type Snapshot struct {
+	Kind     string           `json:"kind"`
+	Cluster  string           `json:"cluster"`
+	Taken    time.Time        `json:"taken"`
+	Findings []models.Finding `json:"findings"`
+	// Usage is the usage of every namespace, it is not set without metrics-server
+	Usage map[string]Usage `json:"usage,omitempty"`
+}
+
+// New takes a snapshot of the findings of the report and the usage of the resource usage report
+func New(r report.Report, usage *k8s.ResourceUsageReport) Snapshot {
+	snapshot := Snapshot{Kind: Kind, Cluster: r.Cluster, Taken: r.Generated, Findings: r.Findings}
+	if usage == nil || !usage.MetricsAvailable {
+		return snapshot
+	}
+	snapshot.Usage = make(map[string]Usage)
+	for namespace, used := range usage.Namespaces() {
+		snapshot.Usage[namespace] = Usage{CPU: used.CPUUsed, Memory: used.MemoryUsed}
+	}
+	return snapshot
+}
+
+// Load reads a snapshot file. A json report of healthctl check and a baseline file are read as snapshots
+// of their findings without usage.
+func Load(file string) (Snapshot, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	probe := struct {
+		Kind     string          `json:"kind"`
+		Findings json.RawMessage `json:"findings"`
+	}{}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return Snapshot{}, fmt.Errorf("parsing %s: %v", file, err)
+	}
+	switch {
+	case probe.Kind == Kind:
+		snapshot := Snapshot{}
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return Snapshot{}, fmt.Errorf("parsing %s: %v", file, err)
+		}
+		return snapshot, nil
+	case probe.Kind == report.Kind:
+		r, err := report.Decode(strings.NewReader(string(data)))
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("parsing %s: %v", file, err)
+		}
+		return New(r, nil), nil
+	case probe.Kind == "" && strings.HasPrefix(strings.TrimSpace(string(probe.Findings)), "{"):
+		baseline := findings.Baseline{}
+		if err := json.Unmarshal(data, &baseline); err != nil {
+			return Snapshot{}, fmt.Errorf("parsing %s: %v", file, err)
+		}
+		snapshot := Snapshot{Kind: Kind, Taken: baseline.Created}
+		for _, id := range slices.Sorted(maps.Keys(baseline.Findings)) {
+			snapshot.Findings = append(snapshot.Findings, baseline.Findings[id])
+		}
+		return snapshot, nil
+	}
+	return Snapshot{}, fmt.Errorf("%s is neither a snapshot, a json report nor a baseline", file)
+}
+
+// Save writes the snapshot file, creating its directory when needed
+func (s Snapshot) Save(file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// Op is the operation of a change, named like the operations of a JSON patch
+type Op string
+
+const (
+	OpAdd     Op = "add"
+	OpRemove  Op = "remove"
+	OpReplace Op = "replace"
+)
+
+// Change is one difference from the old to the new snapshot, like an operation of a JSON patch. The path
+// is /findings/<id> for added and removed findings, /findings/<id>/severity or /findings/<id>/message for
+// changed ones and /usage/<namespace>/cpu or /usage/<namespace>/memory for usage.
+type Change struct {
+	Op   Op     `json:"op"`
+	Path string `json:"path"`
+	// Value is the value in the new snapshot and Old the value in the old one
+	Value interface{} `json:"value,omitempty"`
+	Old   interface{} `json:"old,omitempty"`
+	// Delta is the new minus the old usage, cpu in millicores and memory in bytes
+	Delta *int64 `json:"delta,omitempty"`
+}
+
+// Summary counts the added and removed findings by severity, Escalated counts the findings whose
+// severity rose
+type Summary struct {
+	Added     map[models.Severity]int `json:"added"`
+	Removed   map[models.Severity]int `json:"removed"`
+	Escalated int                     `json:"escalated"`
+}
+
+// Diff is the difference between two snapshots
+type Diff struct {
+	Cluster string    `json:"cluster"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Summary Summary   `json:"summary"`
+	Changes []Change  `json:"changes"`
+}
+
+// Compare returns the changes from the old to the new snapshot. Suppressed findings are left out, they
+// were accepted. Usage is only compared when both snapshots have it.
+func Compare(old, new Snapshot) Diff {
+	diff := Diff{Cluster: new.Cluster, From: old.Taken, To: new.Taken, Changes: []Change{},
+		Summary: Summary{Added: make(map[models.Severity]int), Removed: make(map[models.Severity]int)}}
+	before := findingsByID(old.Findings)
+	after := findingsByID(new.Findings)
+
+	for _, id := range slices.Sorted(maps.Keys(after)) {
+		finding := after[id]
+		previous, found := before[id]
+		if !found {
+			diff.Summary.Added[finding.Severity]++
+			diff.Changes = append(diff.Changes, Change{Op: OpAdd, Path: findingPath(id), Value: finding})
+			continue
+		}
+		if previous.Severity != finding.Severity {
+			if finding.Severity.Rank() > previous.Severity.Rank() {
+				diff.Summary.Escalated++
+			}
+			diff.Changes = append(diff.Changes, Change{Op: OpReplace, Path: findingPath(id) + "/severity", Value: finding.Severity, Old: previous.Severity})
+		}
+		if previous.Message != finding.Message {
+			diff.Changes = append(diff.Changes, Change{Op: OpReplace, Path: findingPath(id) + "/message", Value: finding.Message, Old: previous.Message})
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(before)) {
+		if _, found := after[id]; !found {
+			diff.Summary.Removed[before[id].Severity]++
+			diff.Changes = append(diff.Changes, Change{Op: OpRemove, Path: findingPath(id), Old: before[id]})
+		}
+	}
+
+	if old.Usage == nil || new.Usage == nil {
+		return diff
+	}
+	namespaces := slices.Collect(maps.Keys(old.Usage))
+	for namespace := range new.Usage {
+		if _, found := old.Usage[namespace]; !found {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	slices.Sort(namespaces)
+	for _, namespace := range namespaces {
+		previous, hadUsage := old.Usage[namespace]
+		current, hasUsage := new.Usage[namespace]
+		for _, resource := range []struct {
+			name     string
+			old, new int64
+		}{{"cpu", previous.CPU, current.CPU}, {"memory", previous.Memory, current.Memory}} {
+			change := Change{Op: OpReplace, Path: fmt.Sprintf("/usage/%s/%s", escape(namespace), resource.name), Value: resource.new, Old: resource.old}
+			switch {
+			case !hadUsage:
+				change.Op, change.Old = OpAdd, nil
+			case !hasUsage:
+				change.Op, change.Value = OpRemove, nil
+			case resource.old == resource.new:
+				continue
+			}
+			delta := resource.new - resource.old
+			change.Delta = &delta
+			diff.Changes = append(diff.Changes, change)
+		}
+	}
+	return diff
+}
+
+// Regressions counts the findings added with at least the severity and the findings whose severity rose
+// to at least it, e.g. the new critical findings after a deploy
+func (d Diff) Regressions(severity models.Severity) int {
+	regressions := 0
+	for _, change := range d.Changes {
+		switch {
+		case change.Op == OpAdd && strings.HasPrefix(change.Path, "/findings/"):
+			if change.Value.(models.Finding).Severity.Rank() >= severity.Rank() {
+				regressions++
+			}
+		case change.Op == OpReplace && strings.HasSuffix(change.Path, "/severity"):
+			value, old := change.Value.(models.Severity), change.Old.(models.Severity)
+			if value.Rank() > old.Rank() && value.Rank() >= severity.Rank() {
+				regressions++
+			}
+		}
+	}
+	return regressions
+}
+
+func findingsByID(list []models.Finding) map[string]models.Finding {
+	byID := make(map[string]models.Finding)
+	for _, finding := range list {
+		if !finding.Suppressed {
+			byID[finding.ID] = finding
+		}
+	}
+	return byID
+}
+
+func findingPath(id string) string {
+	return "/findings/" + escape(id)
+}
+
+// escape escapes a JSON pointer token
+func escape(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,174 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultAdmissionSamples    = 5
+	defaultMaxAdmissionLatency = time.Second
+)
+
+// checkAdmissionLatency creates a ConfigMap with server side dry-run in a sample of namespaces. The dry-run
+// passes every mutating and validating webhook without storing anything, so the time it takes is the admission
+// latency every kubectl apply pays. Slow namespaces list the webhooks that intercept them.
+func checkAdmissionLatency(clientset *kubernetes.Clientset) models.ResourceCheck {
+	if k8s.Gentle() {
+		return skippedInMode("Admission Latency", "gentle mode")
+	}
+	namespaces, err := admissionNamespaces(clientset)
+	if err != nil {
+		return models.ResourceCheck{Label: "Admission Latency", Details: "Error fetching namespaces", Error: err.Error()}
+	}
+	maxLatency := defaultMaxAdmissionLatency
+	if parsed, err := time.ParseDuration(settings.Admission.MaxLatency); err == nil {
+		maxLatency = parsed
+	}
+
+	findings := []models.Finding{}
+	latencies := []time.Duration{}
+	for _, namespace := range namespaces {
+		ref := models.ResourceRef{Kind: "Namespace", Name: namespace.Name}
+		canary := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "healthctl-admission-canary-",
+				Labels:       map[string]string{"app.kubernetes.io/managed-by": "healthctl"},
+			},
+			Data: map[string]string{"canary": "true"},
+		}
+		start := time.Now()
+		_, err := clientset.CoreV1().ConfigMaps(namespace.Name).Create(context.Background(), canary, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+		latency := time.Since(start)
+		latencies = append(latencies, latency)
+		if err != nil {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Rejected", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("dry-run create of a ConfigMap in namespace %s failed after %s: %v", namespace.Name, latency.Round(time.Millisecond), err)})
+			continue
+		}
+		if latency > maxLatency {
+			message := fmt.Sprintf("dry-run create of a ConfigMap in namespace %s took %s, above %s", namespace.Name, latency.Round(time.Millisecond), maxLatency)
+			if webhooks := interceptingWebhooks(clientset, namespace); len(webhooks) > 0 {
+				message += fmt.Sprintf(", intercepted by webhooks %s", strings.Join(webhooks, ", "))
+			}
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Latency", Severity: models.SeverityWarning, Message: message})
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	details := fmt.Sprintf("Admission of dry-run creates in %d namespaces p50 %s, max %s.", len(namespaces),
+		percentile(latencies, 50).Round(time.Millisecond), percentile(latencies, 100).Round(time.Millisecond))
+	if len(findings) > 0 {
+		details += fmt.Sprintf(" %d namespaces with slow or failing admission.", len(findings))
+	}
+	return models.ResourceCheck{Label: "Admission Latency", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// admissionNamespaces returns the configured namespaces, or default and a sample of active namespaces spread over the list
+func admissionNamespaces(clientset *kubernetes.Clientset) ([]v1.Namespace, error) {
+	list, err := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	active := []v1.Namespace{}
+	for _, namespace := range list.Items {
+		if namespace.Status.Phase != v1.NamespaceTerminating {
+			active = append(active, namespace)
+		}
+	}
+	if len(settings.Admission.Namespaces) > 0 {
+		configured := []v1.Namespace{}
+		for _, namespace := range active {
+			for _, name := range settings.Admission.Namespaces {
+				if namespace.Name == name {
+					configured = append(configured, namespace)
+				}
+			}
+		}
+		return configured, nil
+	}
+	count := valueOr(settings.Admission.Samples, defaultAdmissionSamples)
+	if len(active) <= count {
+		return active, nil
+	}
+	sampled := []v1.Namespace{}
+	for i := 0; i < count; i++ {
+		sampled = append(sampled, active[i*len(active)/count])
+	}
+	return sampled, nil
+}
+
+// interceptingWebhooks returns the webhooks whose rules match creating ConfigMaps in the namespace, with their timeout
+func interceptingWebhooks(clientset *kubernetes.Clientset, namespace v1.Namespace) []string {
+	webhooks := []string{}
+	matches := func(selector *metav1.LabelSelector, rules []admissionregistrationv1.RuleWithOperations) bool {
+		if selector != nil {
+			parsed, err := metav1.LabelSelectorAsSelector(selector)
+			if err != nil || !parsed.Matches(labels.Set(namespace.Labels)) {
+				return false
+			}
+		}
+		for _, rule := range rules {
+			if ruleContains(rule.APIGroups, "") && ruleContains(rule.Resources, "configmaps") && containsOperation(rule.Operations, admissionregistrationv1.Create) {
+				return true
+			}
+		}
+		return false
+	}
+	describe := func(name string, timeout *int32) string {
+		if timeout == nil {
+			return name
+		}
+		return fmt.Sprintf("%s (timeout %ds)", name, *timeout)
+	}
+	if mutating, err := clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().List(context.Background(), metav1.ListOptions{}); err == nil {
+		for _, configuration := range mutating.Items {
+			for _, webhook := range configuration.Webhooks {
+				if matches(webhook.NamespaceSelector, webhook.Rules) {
+					webhooks = append(webhooks, describe(webhook.Name, webhook.TimeoutSeconds))
+				}
+			}
+		}
+	}
+	if validating, err := clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(context.Background(), metav1.ListOptions{}); err == nil {
+		for _, configuration := range validating.Items {
+			for _, webhook := range configuration.Webhooks {
+				if matches(webhook.NamespaceSelector, webhook.Rules) {
+					webhooks = append(webhooks, describe(webhook.Name, webhook.TimeoutSeconds))
+				}
+			}
+		}
+	}
+	return webhooks
+}
+
+// ruleContains returns true when the values of an admission rule contain the value or the * wildcard
+func ruleContains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value || v == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func containsOperation(operations []admissionregistrationv1.OperationType, operation admissionregistrationv1.OperationType) bool {
+	for _, o := range operations {
+		if o == operation || o == admissionregistrationv1.OperationAll {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,334 @@
+package testsuite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultAlertmanagerNamespace = "fed-prometheus"
+	defaultAlertmanagerPod       = "alertmanager-prometheus-alerts-0"
+	defaultAlertmanagerContainer = "alertmanager"
+	defaultAlertmanagerURL       = "http://localhost:9093"
+	prometheusRulesGroup         = "monitoring.coreos.com/v1"
+	maskedSecret                 = "<secret>"
+)
+
+// promDuration is a Prometheus duration like 1h30m or 7d as used by the routing intervals
+var promDuration = regexp.MustCompile(`^((\d+)(y|w|d|h|m|s|ms))+$`)
+
+// matcherPattern parses a route matcher like severity=~"critical|warning"
+var matcherPattern = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*(.*?)\s*$`)
+
+// routeMatcher is a label matcher of a route
+type routeMatcher struct {
+	Name  string
+	Op    string
+	Value string
+	re    *regexp.Regexp
+}
+
+// matches returns true when the labels match, matchers on labels that are not known may match
+func (m routeMatcher) matches(labels map[string]string) bool {
+	value, known := labels[m.Name]
+	if !known {
+		return true
+	}
+	switch m.Op {
+	case "=":
+		return value == m.Value
+	case "!=":
+		return value != m.Value
+	case "=~":
+		return m.re.MatchString(value)
+	}
+	return !m.re.MatchString(value)
+}
+
+// parseMatchers parses the matchers, match and match_re of a route
+func parseMatchers(route k8s.AlertmanagerRoute) ([]routeMatcher, error) {
+	matchers := []routeMatcher{}
+	for name, value := range route.Match {
+		matchers = append(matchers, routeMatcher{Name: name, Op: "=", Value: value})
+	}
+	for name, value := range route.MatchRE {
+		matchers = append(matchers, routeMatcher{Name: name, Op: "=~", Value: value})
+	}
+	for _, text := range route.Matchers {
+		for _, part := range splitMatchers(strings.Trim(strings.TrimSpace(text), "{}")) {
+			parsed := matcherPattern.FindStringSubmatch(part)
+			if parsed == nil {
+				return nil, fmt.Errorf("invalid matcher %q", part)
+			}
+			value := parsed[3]
+			if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+				value = strings.ReplaceAll(value[1:len(value)-1], `\"`, `"`)
+			}
+			matchers = append(matchers, routeMatcher{Name: parsed[1], Op: parsed[2], Value: value})
+		}
+	}
+	for i, matcher := range matchers {
+		if matcher.Op == "=~" || matcher.Op == "!~" {
+			re, err := regexp.Compile("^(?:" + matcher.Value + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex in matcher %s%s%q: %v", matcher.Name, matcher.Op, matcher.Value, err)
+			}
+			matchers[i].re = re
+		}
+	}
+	return matchers, nil
+}
+
+// splitMatchers splits a comma separated matcher list, commas in quoted values are kept
+func splitMatchers(text string) []string {
+	parts := []string{}
+	quoted, start := false, 0
+	for i, c := range text {
+		switch {
+		case c == '"' && (i == 0 || text[i-1] != '\\'):
+			quoted = !quoted
+		case c == ',' && !quoted:
+			parts = append(parts, text[start:i])
+			start = i + 1
+		}
+	}
+	if strings.TrimSpace(text[start:]) != "" {
+		parts = append(parts, text[start:])
+	}
+	return parts
+}
+
+// alertRule is the alertname and static labels of a Prometheus alerting rule
+type alertRule map[string]string
+
+// prometheusAlertRules returns the alerting rules of all PrometheusRules, nil when they are not served
+func prometheusAlertRules(clientset *kubernetes.Clientset) ([]alertRule, error) {
+	discovery, err := k8s.GetAPIResources(clientset)
+	if err != nil || !discovery.Serves(prometheusRulesGroup) {
+		return nil, err
+	}
+	data, err := clientset.Discovery().RESTClient().Get().AbsPath("/apis/" + prometheusRulesGroup + "/prometheusrules").DoRaw(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	list := struct {
+		Items []struct {
+			Spec struct {
+				Groups []struct {
+					Rules []struct {
+						Alert  string            `json:"alert"`
+						Labels map[string]string `json:"labels"`
+					} `json:"rules"`
+				} `json:"groups"`
+			} `json:"spec"`
+		} `json:"items"`
+	}{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	rules := []alertRule{}
+	for _, item := range list.Items {
+		for _, group := range item.Spec.Groups {
+			for _, rule := range group.Rules {
+				if rule.Alert == "" {
+					continue
+				}
+				labels := alertRule{"alertname": rule.Alert}
+				for name, value := range rule.Labels {
+					labels[name] = value
+				}
+				rules = append(rules, labels)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// routeWalk validates every route of the routing tree, path describes the route in findings
+type routeWalk struct {
+	receivers map[string]bool
+	used      map[string]bool
+	rules     []alertRule
+	findings  []models.Finding
+	ref       models.ResourceRef
+}
+
+func (w *routeWalk) walk(route k8s.AlertmanagerRoute, path string, receiver string, parents []routeMatcher) {
+	if route.Receiver != "" {
+		receiver = route.Receiver
+	}
+	w.used[receiver] = true
+	if !w.receivers[receiver] {
+		w.findings = append(w.findings, models.Finding{Resource: w.ref, Reason: "Receiver " + path, Severity: models.SeverityCritical,
+			Message: fmt.Sprintf("%s sends to receiver %q which is not defined, Alertmanager rejects the config", path, receiver)})
+	}
+	for _, interval := range []string{route.GroupWait, route.GroupInterval, route.RepeatInterval} {
+		if interval != "" && !promDuration.MatchString(interval) {
+			w.findings = append(w.findings, models.Finding{Resource: w.ref, Reason: "Interval " + path, Severity: models.SeverityCritical,
+				Message: fmt.Sprintf("%s has the invalid duration %q", path, interval)})
+		}
+	}
+	matchers, err := parseMatchers(route)
+	if err != nil {
+		w.findings = append(w.findings, models.Finding{Resource: w.ref, Reason: "Matcher " + path, Severity: models.SeverityCritical,
+			Message: fmt.Sprintf("%s: %v", path, err)})
+		return
+	}
+	matchers = append(append([]routeMatcher{}, parents...), matchers...)
+	if w.rules != nil && len(matchers) > len(parents) && !w.matchesAnyRule(matchers) {
+		w.findings = append(w.findings, models.Finding{Resource: w.ref, Reason: "Unmatched " + path, Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("%s to receiver %s matches none of the %d alerting rules, check its matchers for typos", path, receiver, len(w.rules))})
+	}
+	for i, child := range route.Routes {
+		w.walk(child, fmt.Sprintf("%s.routes[%d]", path, i), receiver, matchers)
+	}
+}
+
+func (w *routeWalk) matchesAnyRule(matchers []routeMatcher) bool {
+	for _, rule := range w.rules {
+		all := true
+		for _, matcher := range matchers {
+			if !matcher.matches(rule) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+// receiverEndpoints returns the host:port of every integration of a receiver, masked secrets are skipped
+func receiverEndpoints(receiver k8s.AlertmanagerReceiver, smtpSmarthost string) []string {
+	urls := []string{}
+	for _, c := range receiver.WebhookConfigs {
+		urls = append(urls, c.URL)
+	}
+	for _, c := range receiver.SlackConfigs {
+		urls = append(urls, c.APIURL)
+	}
+	for _, c := range receiver.PagerdutyConfigs {
+		urls = append(urls, c.URL)
+	}
+	for _, c := range receiver.OpsgenieConfigs {
+		urls = append(urls, c.APIURL)
+	}
+	for _, c := range receiver.MSTeamsConfigs {
+		urls = append(urls, c.WebhookURL)
+	}
+	endpoints := []string{}
+	for _, raw := range urls {
+		parsed, err := url.Parse(raw)
+		if raw == "" || raw == maskedSecret || err != nil || parsed.Hostname() == "" {
+			continue
+		}
+		port := parsed.Port()
+		if port == "" {
+			port = "443"
+			if parsed.Scheme == "http" {
+				port = "80"
+			}
+		}
+		endpoints = append(endpoints, parsed.Hostname()+":"+port)
+	}
+	for _, c := range receiver.EmailConfigs {
+		if smarthost := valueOr(c.Smarthost, smtpSmarthost); smarthost != "" && smarthost != maskedSecret {
+			endpoints = append(endpoints, smarthost)
+		}
+	}
+	return endpoints
+}
+
+// checkAlertmanagerConfig validates the configuration Alertmanager runs with like amtool check-config,
+// tests that the receivers are reachable from the Alertmanager pod and reports routes that match none of
+// the alerting rules, which silently break the routing of alerts
+func checkAlertmanagerConfig(clientset *kubernetes.Clientset) models.ResourceCheck {
+	if mode := probesDisabled(); mode != "" {
+		return skippedInMode("Alertmanager Config", mode)
+	}
+	cfg := settings.Alertmanager
+	namespace := valueOr(cfg.Namespace, defaultAlertmanagerNamespace)
+	pod := valueOr(cfg.Pod, defaultAlertmanagerPod)
+	container := valueOr(cfg.Container, defaultAlertmanagerContainer)
+	kc := &k8s.K8sClient{Client: clientset}
+	amConfig, err := kc.GetAlertmanagerConfig(namespace, pod, container, valueOr(cfg.URL, defaultAlertmanagerURL))
+	if err != nil {
+		return models.ResourceCheck{Label: "Alertmanager Config", Details: "Error reading the alertmanager config", Error: err.Error()}
+	}
+
+	ref := models.ResourceRef{Kind: "Pod", Namespace: namespace, Name: pod}
+	walk := &routeWalk{receivers: make(map[string]bool), used: make(map[string]bool), ref: ref}
+	for _, receiver := range amConfig.Receivers {
+		if walk.receivers[receiver.Name] {
+			walk.findings = append(walk.findings, models.Finding{Resource: ref, Reason: "Duplicate " + receiver.Name, Severity: models.SeverityCritical,
+				Message: fmt.Sprintf("receiver %q is defined more than once", receiver.Name)})
+		}
+		walk.receivers[receiver.Name] = true
+	}
+	rules, err := prometheusAlertRules(clientset)
+	if err == nil {
+		walk.rules = rules
+	}
+	if amConfig.Route.Receiver == "" {
+		walk.findings = append(walk.findings, models.Finding{Resource: ref, Reason: "Root", Severity: models.SeverityCritical,
+			Message: "the root route has no receiver"})
+	}
+	walk.walk(amConfig.Route, "route", amConfig.Route.Receiver, nil)
+	findings := walk.findings
+
+	reachable := make(map[string]error)
+	for _, receiver := range amConfig.Receivers {
+		if !walk.used[receiver.Name] {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Unused " + receiver.Name, Severity: models.SeverityInfo,
+				Message: fmt.Sprintf("receiver %s is not used by any route", receiver.Name)})
+			continue
+		}
+		if receiver.Integrations() == 0 {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Empty " + receiver.Name, Severity: models.SeverityInfo,
+				Message: fmt.Sprintf("receiver %s has no integrations, alerts routed to it are dropped", receiver.Name)})
+		}
+		for _, endpoint := range receiverEndpoints(receiver, amConfig.Global.SMTPSmarthost) {
+			if _, tested := reachable[endpoint]; !tested {
+				reachable[endpoint] = kc.CanConnect(namespace, pod, container, endpoint)
+			}
+			if err := reachable[endpoint]; err != nil {
+				findings = append(findings, models.Finding{Resource: ref, Reason: "Unreachable " + receiver.Name, Severity: models.SeverityCritical,
+					Message: fmt.Sprintf("receiver %s can not deliver notifications: %v", receiver.Name, err)})
+			}
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Severity.Rank() > findings[j].Severity.Rank() })
+
+	failing := 0
+	for _, finding := range findings {
+		if finding.Failing() {
+			failing++
+		}
+	}
+	connected := 0
+	for _, err := range reachable {
+		if err == nil {
+			connected++
+		}
+	}
+	details := fmt.Sprintf("Config valid, %d receivers with %d of %d endpoints reachable.", len(amConfig.Receivers), connected, len(reachable))
+	if walk.rules == nil {
+		details += " PrometheusRules are not readable, routes were not matched against the alerting rules."
+	}
+	if failing > 0 {
+		details = fmt.Sprintf("%d alert routing problems.", failing)
+	}
+	return models.ResourceCheck{Label: "Alertmanager Config", Details: details, Status: failing == 0, Findings: findings}
+}
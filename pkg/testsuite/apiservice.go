@@ -0,0 +1,119 @@
+package testsuite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// apiService is the part of an apiregistration.k8s.io/v1 APIService that is checked
+type apiService struct {
+	Metadata metav1.ObjectMeta `json:"metadata"`
+	Spec     struct {
+		Service *struct {
+			Namespace string `json:"namespace"`
+			Name      string `json:"name"`
+		} `json:"service"`
+	} `json:"spec"`
+	Status struct {
+		Conditions []struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+func checkAPIServices(clientset *kubernetes.Clientset) models.ResourceCheck {
+	data, err := clientset.Discovery().RESTClient().Get().AbsPath("/apis/apiregistration.k8s.io/v1/apiservices").DoRaw(context.Background())
+	if err != nil {
+		return models.ResourceCheck{Label: "API Services", Details: "Error fetching API services", Error: err.Error()}
+	}
+	list := struct {
+		Items []apiService `json:"items"`
+	}{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return models.ResourceCheck{Label: "API Services", Details: "Error parsing API services", Error: err.Error()}
+	}
+
+	findings := []models.Finding{}
+	reported := make(map[string]bool)
+	for _, service := range list.Items {
+		for _, condition := range service.Status.Conditions {
+			if condition.Type != "Available" || condition.Status == "True" {
+				continue
+			}
+			message := fmt.Sprintf("APIService %s is not available (%s): %s", service.Metadata.Name, condition.Reason, condition.Message)
+			if service.Spec.Service != nil {
+				message += "; " + backendStatus(clientset, service.Spec.Service.Namespace, service.Spec.Service.Name)
+			}
+			findings = append(findings, models.Finding{
+				Resource: models.ResourceRef{Kind: "APIService", Name: service.Metadata.Name},
+				Reason:   "Available",
+				Severity: models.SeverityCritical,
+				Message:  message,
+			})
+			reported[service.Metadata.Name] = true
+		}
+	}
+
+	// group versions can fail discovery while their APIService still reports available
+	if discovery, err := k8s.GetAPIResources(clientset); err == nil {
+		for _, failed := range discovery.FailedGroups {
+			if reported[failed.APIService] {
+				continue
+			}
+			findings = append(findings, models.Finding{
+				Resource: models.ResourceRef{Kind: "APIService", Name: failed.APIService},
+				Reason:   "Discovery",
+				Severity: models.SeverityCritical,
+				Message:  fmt.Sprintf("discovery of %s failed: %s", failed.GroupVersion, failed.Error),
+			})
+		}
+	}
+
+	details := fmt.Sprintf("All %d API services are available.", len(list.Items))
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d of %d API services are unavailable, kubectl and controllers using them fail.", len(findings), len(list.Items))
+	}
+	return models.ResourceCheck{Label: "API Services", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// backendStatus describes the service behind an aggregated API and the pods it selects
+func backendStatus(clientset *kubernetes.Clientset, namespace, name string) string {
+	service, err := clientset.CoreV1().Services(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Sprintf("backing service %s/%s: %v", namespace, name, err)
+	}
+	if len(service.Spec.Selector) == 0 {
+		return fmt.Sprintf("backing service %s/%s has no selector", namespace, name)
+	}
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(service.Spec.Selector).String(),
+	})
+	if err != nil {
+		return fmt.Sprintf("backing service %s/%s: error fetching pods", namespace, name)
+	}
+	if len(pods.Items) == 0 {
+		return fmt.Sprintf("backing service %s/%s selects no pods", namespace, name)
+	}
+	states := []string{}
+	for _, pod := range pods.Items {
+		state := k8s.PodIssueReason(pod)
+		if state == "" {
+			state = string(v1.PodRunning)
+		}
+		states = append(states, fmt.Sprintf("%s %s", pod.Name, state))
+	}
+	return fmt.Sprintf("backing service %s/%s pods: %s", namespace, name, strings.Join(states, ", "))
+}
@@ -0,0 +1,199 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"healthctl/pkg/models"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultAutoscalerNamespace = "kube-system"
+	defaultAutoscalerStatus    = "cluster-autoscaler-status"
+)
+
+// nodeGroupStatus is the part of the cluster-autoscaler status of one node group that is checked
+type nodeGroupStatus struct {
+	Name             string
+	Health           string
+	ScaleUp          string
+	Target           int
+	MaxSize          int
+	LongUnregistered int
+}
+
+func checkClusterAutoscaler(clientset *kubernetes.Clientset) models.ResourceCheck {
+	namespace := settings.Autoscaler.Namespace
+	if namespace == "" {
+		namespace = defaultAutoscalerNamespace
+	}
+	name := settings.Autoscaler.StatusConfigMap
+	if name == "" {
+		name = defaultAutoscalerStatus
+	}
+
+	cm, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return models.ResourceCheck{Label: "Cluster Autoscaler", Details: "cluster-autoscaler is not deployed.", Status: true}
+	}
+	if err != nil {
+		return models.ResourceCheck{Label: "Cluster Autoscaler", Details: "Error fetching cluster-autoscaler status", Error: err.Error()}
+	}
+
+	clusterHealth, groups := parseAutoscalerStatus(cm.Data["status"])
+	findings := []models.Finding{}
+	if clusterHealth != "" && clusterHealth != "Healthy" {
+		findings = append(findings, models.Finding{
+			Resource: models.ResourceRef{Kind: "ConfigMap", Namespace: namespace, Name: name},
+			Severity: models.SeverityCritical,
+			Message:  fmt.Sprintf("cluster-autoscaler reports cluster health %s", clusterHealth),
+		})
+	}
+	for _, group := range groups {
+		ref := models.ResourceRef{Kind: "NodeGroup", Name: group.Name}
+		if group.Health != "" && group.Health != "Healthy" {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Health", Severity: models.SeverityCritical,
+				Message: fmt.Sprintf("Node group %s is %s", group.Name, group.Health)})
+		}
+		if group.ScaleUp == "Backoff" {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "ScaleUp", Severity: models.SeverityCritical,
+				Message: fmt.Sprintf("Scale-up of node group %s failed and is backing off", group.Name)})
+		}
+		if group.LongUnregistered > 0 {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Unregistered", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("Node group %s has %d nodes that never registered with the cluster", group.Name, group.LongUnregistered)})
+		}
+		if group.MaxSize > 0 && group.Target >= group.MaxSize {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "MaxSize", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("Node group %s is at its max size of %d nodes and can not scale up", group.Name, group.MaxSize)})
+		}
+	}
+	findings = append(findings, autoscalerEvents(clientset)...)
+
+	details := fmt.Sprintf("cluster-autoscaler manages %d node groups, no problems found.", len(groups))
+	if len(findings) > 0 {
+		details = fmt.Sprintf("cluster-autoscaler manages %d node groups, %d problems found.", len(groups), len(findings))
+	}
+	return models.ResourceCheck{Label: "Cluster Autoscaler", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// autoscalerStatusYAML is the structured status format written by cluster-autoscaler 1.30 and later
+type autoscalerStatusYAML struct {
+	ClusterWide struct {
+		Health struct {
+			Status string `json:"status"`
+		} `json:"health"`
+	} `json:"clusterWide"`
+	NodeGroups []struct {
+		Name   string `json:"name"`
+		Health struct {
+			Status              string `json:"status"`
+			CloudProviderTarget int    `json:"cloudProviderTarget"`
+			MaxSize             int    `json:"maxSize"`
+			NodeCounts          struct {
+				LongUnregistered int `json:"longUnregistered"`
+			} `json:"nodeCounts"`
+		} `json:"health"`
+		ScaleUp struct {
+			Status string `json:"status"`
+		} `json:"scaleUp"`
+	} `json:"nodeGroups"`
+}
+
+var (
+	statusNameRegex         = regexp.MustCompile(`^\s*Name:\s+(\S+)`)
+	statusHealthRegex       = regexp.MustCompile(`^\s*Health:\s+(\w+)`)
+	statusScaleUpRegex      = regexp.MustCompile(`^\s*ScaleUp:\s+(\w+)`)
+	statusTargetRegex       = regexp.MustCompile(`cloudProviderTarget=(\d+) \(minSize=\d+, maxSize=(\d+)\)`)
+	statusUnregisteredRegex = regexp.MustCompile(`longUnregistered=(\d+)`)
+)
+
+// parseAutoscalerStatus parses both the yaml and the older plain text status format
+func parseAutoscalerStatus(status string) (string, []nodeGroupStatus) {
+	var structured autoscalerStatusYAML
+	if err := yaml.Unmarshal([]byte(status), &structured); err == nil && structured.ClusterWide.Health.Status != "" {
+		groups := []nodeGroupStatus{}
+		for _, group := range structured.NodeGroups {
+			groups = append(groups, nodeGroupStatus{
+				Name:             group.Name,
+				Health:           group.Health.Status,
+				ScaleUp:          group.ScaleUp.Status,
+				Target:           group.Health.CloudProviderTarget,
+				MaxSize:          group.Health.MaxSize,
+				LongUnregistered: group.Health.NodeCounts.LongUnregistered,
+			})
+		}
+		return structured.ClusterWide.Health.Status, groups
+	}
+
+	clusterHealth := ""
+	groups := []nodeGroupStatus{}
+	var current *nodeGroupStatus
+	for _, line := range strings.Split(status, "\n") {
+		if match := statusNameRegex.FindStringSubmatch(line); match != nil {
+			groups = append(groups, nodeGroupStatus{Name: match[1]})
+			current = &groups[len(groups)-1]
+			continue
+		}
+		if match := statusHealthRegex.FindStringSubmatch(line); match != nil {
+			longUnregistered := 0
+			if m := statusUnregisteredRegex.FindStringSubmatch(line); m != nil {
+				longUnregistered, _ = strconv.Atoi(m[1])
+			}
+			if current == nil {
+				clusterHealth = match[1]
+				continue
+			}
+			current.Health = match[1]
+			current.LongUnregistered = longUnregistered
+			if m := statusTargetRegex.FindStringSubmatch(line); m != nil {
+				current.Target, _ = strconv.Atoi(m[1])
+				current.MaxSize, _ = strconv.Atoi(m[2])
+			}
+			continue
+		}
+		if match := statusScaleUpRegex.FindStringSubmatch(line); match != nil && current != nil {
+			current.ScaleUp = match[1]
+		}
+	}
+	return clusterHealth, groups
+}
+
+// autoscalerEvents reports pending pods the autoscaler can not help and failed scale-ups
+func autoscalerEvents(clientset *kubernetes.Clientset) []models.Finding {
+	findings := []models.Finding{}
+	events, err := clientset.CoreV1().Events("").List(context.Background(), metav1.ListOptions{
+		FieldSelector: "source=cluster-autoscaler",
+	})
+	if err != nil {
+		return findings
+	}
+	seen := make(map[string]bool)
+	for _, event := range events.Items {
+		ref := models.ResourceRef{Kind: event.InvolvedObject.Kind, Namespace: event.InvolvedObject.Namespace, Name: event.InvolvedObject.Name}
+		key := ref.String() + "|" + event.Reason
+		if seen[key] {
+			continue
+		}
+		switch event.Reason {
+		case "NotTriggerScaleUp":
+			findings = append(findings, models.Finding{Resource: ref, Reason: event.Reason, Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("Pending pod %s/%s can not be satisfied by autoscaling: %s", ref.Namespace, ref.Name, event.Message)})
+		case "FailedToScaleUpGroup", "ScaleUpFailed":
+			findings = append(findings, models.Finding{Resource: ref, Reason: event.Reason, Severity: models.SeverityCritical,
+				Message: fmt.Sprintf("Scale-up failed: %s", event.Message)})
+		default:
+			continue
+		}
+		seen[key] = true
+	}
+	return findings
+}
@@ -0,0 +1,161 @@
+package testsuite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"healthctl/pkg/capacity"
+	"healthctl/pkg/config"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+const defaultCapacityHorizon = 14 * 24 * time.Hour
+
+// nodePoolLabels are the labels cloud providers and karpenter put on the nodes of a pool
+var nodePoolLabels = []string{
+	"eks.amazonaws.com/nodegroup",
+	"cloud.google.com/gke-nodepool",
+	"kubernetes.azure.com/agentpool",
+	"agentpool",
+	"karpenter.sh/nodepool",
+}
+
+// UsageFile is the usage history of the node pools of the current cluster, the Capacity Forecast check
+// records a sample every run
+func UsageFile() string {
+	return config.ClusterStatePath(k8s.CurrentCluster(), "usage.json")
+}
+
+func checkCapacityForecast(clientset *kubernetes.Clientset) models.ResourceCheck {
+	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Capacity Forecast", Details: "Error fetching nodes", Error: err.Error()}
+	}
+	usage, source := nodeUsage(clientset)
+
+	now := time.Now()
+	pools := make(map[string]*capacity.Sample)
+	for _, node := range nodes.Items {
+		pool := nodePool(node)
+		if pools[pool] == nil {
+			pools[pool] = &capacity.Sample{Time: now}
+		}
+		sample := pools[pool]
+		sample.CPUCapacity += node.Status.Allocatable.Cpu().MilliValue()
+		sample.MemoryCapacity += node.Status.Allocatable.Memory().Value()
+		sample.CPUUsed += usage[node.Name].cpu
+		sample.MemoryUsed += usage[node.Name].memory
+	}
+
+	file := UsageFile()
+	store, err := capacity.Load(file)
+	if err != nil {
+		return models.ResourceCheck{Label: "Capacity Forecast", Details: fmt.Sprintf("Error reading usage history: %v", err), Error: err.Error()}
+	}
+	names := []string{}
+	for pool, sample := range pools {
+		store.Record(pool, *sample)
+		names = append(names, pool)
+	}
+	sort.Strings(names)
+	if err := store.Save(file); err != nil {
+		return models.ResourceCheck{Label: "Capacity Forecast", Details: fmt.Sprintf("Error saving usage history: %v", err), Error: err.Error()}
+	}
+
+	horizon := defaultCapacityHorizon
+	if parsed, err := time.ParseDuration(settings.Capacity.Horizon); err == nil {
+		horizon = parsed
+	}
+	resources := []struct {
+		name     string
+		used     func(capacity.Sample) int64
+		capacity func(capacity.Sample) int64
+	}{
+		{"cpu", func(s capacity.Sample) int64 { return s.CPUUsed }, func(s capacity.Sample) int64 { return s.CPUCapacity }},
+		{"memory", func(s capacity.Sample) int64 { return s.MemoryUsed }, func(s capacity.Sample) int64 { return s.MemoryCapacity }},
+	}
+
+	findings := []models.Finding{}
+	forecasts := 0
+	for _, pool := range names {
+		for _, resource := range resources {
+			exhausted, ok := capacity.Forecast(store[pool], resource.used, resource.capacity)
+			if !ok {
+				continue
+			}
+			forecasts++
+			if exhausted > horizon {
+				continue
+			}
+			findings = append(findings, models.Finding{
+				Resource: models.ResourceRef{Kind: "NodePool", Name: pool},
+				Reason:   resource.name,
+				Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("%s headroom of node pool %s is exhausted in %s at the current growth rate (by %s)",
+					resource.name, pool, exhausted.Round(time.Hour), source),
+			})
+		}
+	}
+
+	details := fmt.Sprintf("%d node pools, no headroom exhausted within %s.", len(names), horizon)
+	if forecasts == 0 {
+		details = fmt.Sprintf("%d node pools, not enough usage history for a forecast yet.", len(names))
+	}
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d node pool resources run out of headroom within %s.", len(findings), horizon)
+	}
+	return models.ResourceCheck{Label: "Capacity Forecast", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+func nodePool(node v1.Node) string {
+	if settings.Capacity.PoolLabel != "" {
+		if pool := node.Labels[settings.Capacity.PoolLabel]; pool != "" {
+			return pool
+		}
+	}
+	for _, label := range nodePoolLabels {
+		if pool := node.Labels[label]; pool != "" {
+			return pool
+		}
+	}
+	return "default"
+}
+
+// nodeUsage returns the usage of every node from metrics-server, or the requests of its pods without metrics
+func nodeUsage(clientset *kubernetes.Clientset) (map[string]podRequests, string) {
+	usage := make(map[string]podRequests)
+	data, err := clientset.Discovery().RESTClient().Get().AbsPath("/apis/metrics.k8s.io/v1beta1/nodes").DoRaw(context.Background())
+	if err == nil {
+		nodeMetrics := metricsv1beta1.NodeMetricsList{}
+		if err := json.Unmarshal(data, &nodeMetrics); err == nil {
+			for _, metrics := range nodeMetrics.Items {
+				usage[metrics.Name] = podRequests{
+					cpu:    metrics.Usage.Cpu().MilliValue(),
+					memory: metrics.Usage.Memory().Value(),
+				}
+			}
+			return usage, "usage"
+		}
+	}
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{FieldSelector: "status.phase=Running"})
+	if err != nil {
+		return usage, "requests"
+	}
+	for _, pod := range pods.Items {
+		requests := requestsOf(pod)
+		node := usage[pod.Spec.NodeName]
+		node.cpu += requests.cpu
+		node.memory += requests.memory
+		usage[pod.Spec.NodeName] = node
+	}
+	return usage, "requests"
+}
@@ -0,0 +1,173 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// cloudLogLines is how many lines of the cloud-controller-manager logs are searched for credential errors
+const cloudLogLines = 500
+
+// uninitializedTaint is set on new nodes until the cloud-controller-manager initialized them
+const uninitializedTaint = "node.cloudprovider.kubernetes.io/uninitialized"
+
+// cloudProvider is detected from the providerID of the nodes
+type cloudProvider struct {
+	Name       string
+	IDPrefix   string
+	AuthErrors *regexp.Regexp
+}
+
+var cloudProviders = []cloudProvider{
+	{Name: "AWS", IDPrefix: "aws://", AuthErrors: regexp.MustCompile(`UnauthorizedOperation|AccessDenied|InvalidClientTokenId|ExpiredToken|WebIdentityErr|NoCredentialProviders`)},
+	{Name: "Azure", IDPrefix: "azure://", AuthErrors: regexp.MustCompile(`AuthorizationFailed|InvalidAuthenticationToken|AADSTS\d+|LinkedAuthorizationFailed`)},
+	{Name: "GCP", IDPrefix: "gce://", AuthErrors: regexp.MustCompile(`PERMISSION_DENIED|googleapi: Error 403|invalid_grant|Request had insufficient authentication scopes`)},
+}
+
+// cloudEventReasons are the events of the cloud integration that mean provisioning failed
+var cloudEventReasons = map[string]string{
+	"SyncLoadBalancerFailed":     "Service",
+	"DeletingLoadBalancerFailed": "Service",
+	"FailedAttachVolume":         "Pod",
+	"FailedDetachVolume":         "Pod",
+	"FailedToCreateRoute":        "Node",
+}
+
+// checkCloudProvider verifies the integration with the cloud of the nodes: the cloud-controller-manager,
+// load balancers, volume attachments and credential errors in the controller logs
+func checkCloudProvider(clientset *kubernetes.Clientset) models.ResourceCheck {
+	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Cloud Provider", Details: "Error fetching nodes", Error: err.Error()}
+	}
+	var provider *cloudProvider
+	for _, node := range nodes.Items {
+		for i := range cloudProviders {
+			if strings.HasPrefix(node.Spec.ProviderID, cloudProviders[i].IDPrefix) {
+				provider = &cloudProviders[i]
+			}
+		}
+	}
+	if provider == nil {
+		return models.ResourceCheck{Label: "Cloud Provider", Details: "No AWS, Azure or GCP nodes found.", Status: true}
+	}
+
+	findings := []models.Finding{}
+	for _, node := range nodes.Items {
+		for _, taint := range node.Spec.Taints {
+			if taint.Key == uninitializedTaint {
+				findings = append(findings, models.Finding{Resource: models.ResourceRef{Kind: "Node", Name: node.Name, Node: node.Name}, Reason: "Uninitialized", Severity: models.SeverityCritical,
+					Message: fmt.Sprintf("Node %s was not initialized by the cloud-controller-manager, no pods are scheduled on it", node.Name)})
+			}
+		}
+	}
+
+	managed := true
+	pods, err := clientset.CoreV1().Pods("kube-system").List(context.Background(), metav1.ListOptions{})
+	if err == nil {
+		for _, pod := range pods.Items {
+			if !strings.Contains(pod.Name, "cloud-controller-manager") && pod.Labels["component"] != "cloud-controller-manager" {
+				continue
+			}
+			managed = false
+			findings = append(findings, cloudControllerFindings(clientset, *provider, pod)...)
+		}
+	}
+	findings = append(findings, loadBalancerFindings(clientset)...)
+	findings = append(findings, cloudEventFindings(clientset)...)
+
+	details := fmt.Sprintf("%s integration is healthy.", provider.Name)
+	if managed {
+		details = fmt.Sprintf("%s integration is healthy, the cloud-controller-manager is managed by the provider.", provider.Name)
+	}
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%s integration, %d problems found.", provider.Name, len(findings))
+	}
+	return models.ResourceCheck{Label: "Cloud Provider", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// cloudControllerFindings reports a cloud-controller-manager pod that is not ready and credential errors in its logs
+func cloudControllerFindings(clientset *kubernetes.Clientset, provider cloudProvider, pod v1.Pod) []models.Finding {
+	findings := []models.Finding{}
+	ref := models.ResourceRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name, Node: pod.Spec.NodeName}
+	if reason := k8s.PodIssueReason(pod); reason != "" {
+		findings = append(findings, models.Finding{Resource: ref, Reason: "NotReady", Severity: models.SeverityCritical,
+			Message: fmt.Sprintf("cloud-controller-manager pod %s is %s", pod.Name, reason)})
+	}
+	kc := &k8s.K8sClient{Client: clientset}
+	logs, err := kc.GetPodLogs(pod.Namespace, pod.Name, cloudLogLines)
+	if err != nil {
+		return findings
+	}
+	authErrors := make(map[string]int)
+	for _, log := range logs {
+		for _, match := range provider.AuthErrors.FindAllString(log, -1) {
+			authErrors[match]++
+		}
+	}
+	if len(authErrors) > 0 {
+		found := []string{}
+		for match, count := range authErrors {
+			found = append(found, fmt.Sprintf("%s (x%d)", match, count))
+		}
+		sort.Strings(found)
+		findings = append(findings, models.Finding{Resource: ref, Reason: "Credentials", Severity: models.SeverityCritical,
+			Message: fmt.Sprintf("cloud-controller-manager logs %s credential or permission errors: %s", provider.Name, strings.Join(found, ", "))})
+	}
+	return findings
+}
+
+// loadBalancerFindings reports LoadBalancer services that did not get an address
+func loadBalancerFindings(clientset *kubernetes.Clientset) []models.Finding {
+	findings := []models.Finding{}
+	services, err := clientset.CoreV1().Services("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return findings
+	}
+	for _, service := range services.Items {
+		if service.Spec.Type != v1.ServiceTypeLoadBalancer || len(service.Status.LoadBalancer.Ingress) > 0 {
+			continue
+		}
+		findings = append(findings, models.Finding{
+			Resource: models.ResourceRef{Kind: "Service", Namespace: service.Namespace, Name: service.Name},
+			Reason:   "Pending",
+			Severity: models.SeverityWarning,
+			Message:  fmt.Sprintf("LoadBalancer service %s/%s has no external address", service.Namespace, service.Name),
+		})
+	}
+	return findings
+}
+
+// cloudEventFindings reports failed load balancer, volume attachment and route events, once per object and reason
+func cloudEventFindings(clientset *kubernetes.Clientset) []models.Finding {
+	findings := []models.Finding{}
+	events, err := clientset.CoreV1().Events("").List(context.Background(), metav1.ListOptions{FieldSelector: "type=Warning"})
+	if err != nil {
+		return findings
+	}
+	seen := make(map[string]bool)
+	for _, event := range events.Items {
+		kind, found := cloudEventReasons[event.Reason]
+		if !found || kind != event.InvolvedObject.Kind {
+			continue
+		}
+		ref := models.ResourceRef{Kind: kind, Namespace: event.InvolvedObject.Namespace, Name: event.InvolvedObject.Name}
+		if seen[ref.String()+"|"+event.Reason] {
+			continue
+		}
+		seen[ref.String()+"|"+event.Reason] = true
+		findings = append(findings, models.Finding{Resource: ref, Reason: event.Reason, Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("%s %s: %s", event.Reason, ref, event.Message)})
+	}
+	return findings
+}
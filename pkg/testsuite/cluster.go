@@ -3,28 +3,54 @@ package testsuite
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 
+	"healthctl/pkg/k8s"
 	"healthctl/pkg/models"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
-func CheckK8s(clientset *kubernetes.Clientset) []models.ResourceCheck {
+// k8sChecks are the checks of the k8s suite
+var k8sChecks = []Check{
+	single("Nodes", checkNodes),
+	single("Node Problems", checkNodeProblems),
+	single("GPUs", checkGPUs),
+	single("Cluster Autoscaler", checkClusterAutoscaler),
+	single("Cloud Provider", checkCloudProvider),
+	single("Spot Risk", checkSpotRisk),
+	single("Multi-Arch", checkMultiArch),
+	single("Priority Classes", checkPriorityClasses),
+	single("Capacity Forecast", checkCapacityForecast),
+	single("Leader Election", checkLeaderElection),
+	single("API Services", checkAPIServices),
+	single("Admission Latency", checkAdmissionLatency),
+	single("Metrics Pipeline", checkMetricsPipeline),
+	single("Custom Metrics", checkCustomMetrics),
+	sharded("Pods", checkPods),
+	single("Probes", checkProbes),
+	single("Pod Startup", checkPodStartup),
+	single("Evictions", checkEvictions),
+	single("Ephemeral Storage", checkEphemeralStorage),
+	single("Persistent Volumes", checkPVs),
+	sharded("Persistent Volume Claims", checkPVCs),
+	sharded("Services", checkServices),
+	sharded("Deployments", checkDeployments),
+	sharded("Replica Sets", checkReplicaSets),
+	sharded("Events", checkEvents),
+	single("Stuck Deletions", checkStuckDeletions),
+	single("Owner References", checkOwnerReferences),
+	single("Manifest Drift", checkManifestDrift),
+	single("Expected Inventory", checkExpectedInventory),
+	sharded("Ingresses", checkIngresses),
+	sharded("Daemon Sets", checkDaemonSets),
+	sharded("Stateful Sets", checkStatefulSets),
+}
 
-	checks := []models.ResourceCheck{
-		checkNodes(clientset),
-		checkPods(clientset),
-		checkPVs(clientset),
-		checkPVCs(clientset),
-		checkServices(clientset),
-		checkDeployments(clientset),
-		checkReplicaSets(clientset),
-		checkEvents(clientset),
-		checkIngresses(clientset),
-		checkDaemonSets(clientset),
-		checkStatefulSets(clientset),
-	}
+func CheckK8s(clientset *kubernetes.Clientset) []models.ResourceCheck {
+	checks := RunChecks(clientset, k8sChecks)
 
 	return checks
 }
@@ -33,34 +59,74 @@ func CheckK8s(clientset *kubernetes.Clientset) []models.ResourceCheck {
 func checkNodes(clientset *kubernetes.Clientset) models.ResourceCheck {
 	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{Label: "Nodes", Details: "Error fetching nodes", Status: false}
+		return models.ResourceCheck{Label: "Nodes", Details: "Error fetching nodes", Error: err.Error()}
 	}
 
 	nodeNames := make([]string, len(nodes.Items))
+	findings := []models.Finding{}
 	for i, node := range nodes.Items {
 		nodeNames[i] = node.Name
+		conditions := k8s.NodeIssueConditions(node)
+		if len(conditions) == 0 {
+			continue
+		}
+		// pressure conditions may come before Ready, a node that is not ready is critical either way
+		severity := models.SeverityWarning
+		if !nodeReady(node) {
+			severity = models.SeverityCritical
+		}
+		findings = append(findings, models.Finding{
+			Resource: models.ResourceRef{Kind: "Node", Name: node.Name, Node: node.Name},
+			Severity: severity,
+			Message:  fmt.Sprintf("Node %s is flagged %s", node.Name, strings.Join(conditions, ", ")),
+		})
 	}
 
-	return models.ResourceCheck{Label: "Nodes", Details: fmt.Sprint("Number of nodes : ", len(nodes.Items)), Status: true}
+	return models.ResourceCheck{Label: "Nodes", Details: fmt.Sprint("Number of nodes : ", len(nodes.Items)), Status: len(findings) == 0, Findings: findings}
 }
 
 func checkPods(clientset *kubernetes.Clientset) models.ResourceCheck {
 	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{Label: "Pods", Details: "Error fetching pods", Status: false}
+		return models.ResourceCheck{Label: "Pods", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	totalPods := len(pods.Items)
-	healthyPods := 0
+	healthyPods, initFailures, sidecarFailures := 0, 0, 0
+	findings := []models.Finding{}
 
 	for _, pod := range pods.Items {
 		if pod.Status.Phase == "Running" || pod.Status.Phase == "Succeeded" {
 			healthyPods++
 		}
+		problem := k8s.DiagnosePod(pod)
+		if problem.Reason == "" {
+			continue
+		}
+		finding := models.Finding{
+			Resource: models.ResourceRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name, Node: pod.Spec.NodeName},
+			Severity: models.SeverityWarning,
+			Message:  fmt.Sprintf("Pod %s/%s is %s", pod.Namespace, pod.Name, problem.Reason),
+		}
+		// init containers and sidecars get findings of their own, they are often owned by another team
+		switch problem.Kind {
+		case k8s.ContainerInit:
+			initFailures++
+			finding.Reason = "Init Container"
+			finding.Message = fmt.Sprintf("Pod %s/%s is %s, init container %s fails", pod.Namespace, pod.Name, problem.Reason, problem.Container)
+		case k8s.ContainerSidecar:
+			sidecarFailures++
+			finding.Reason = "Sidecar"
+			finding.Message = fmt.Sprintf("Sidecar %s of pod %s/%s is %s", problem.Container, pod.Namespace, pod.Name, problem.Reason)
+		}
+		findings = append(findings, finding)
 	}
 	details := fmt.Sprintf("Total: %d, Healthy: %d. Status: %s", totalPods, healthyPods,
 		getPodsHealthMessage(totalPods, healthyPods))
-	return models.ResourceCheck{Label: "Pods", Details: details, Status: healthyPods == totalPods}
+	if initFailures > 0 || sidecarFailures > 0 {
+		details += fmt.Sprintf(" Failing init containers: %d, failing sidecars: %d.", initFailures, sidecarFailures)
+	}
+	return models.ResourceCheck{Label: "Pods", Details: details, Status: healthyPods == totalPods, Findings: findings}
 }
 
 func getPodsHealthMessage(total int, healthy int) string {
@@ -76,7 +142,7 @@ func getPodsHealthMessage(total int, healthy int) string {
 func checkPVs(clientset *kubernetes.Clientset) models.ResourceCheck {
 	pvs, err := clientset.CoreV1().PersistentVolumes().List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{Label: "Persistent Volumes", Details: "Error fetching persistent volumes", Status: false}
+		return models.ResourceCheck{Label: "Persistent Volumes", Details: "Error fetching persistent volumes", Error: err.Error()}
 	}
 
 	count := len(pvs.Items)
@@ -106,7 +172,7 @@ func checkPVs(clientset *kubernetes.Clientset) models.ResourceCheck {
 func checkPVCs(clientset *kubernetes.Clientset) models.ResourceCheck {
 	pvcs, err := clientset.CoreV1().PersistentVolumeClaims("").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{Label: "Persistent Volume Claims", Details: "Error fetching persistent volume claims", Status: false}
+		return models.ResourceCheck{Label: "Persistent Volume Claims", Details: "Error fetching persistent volume claims", Error: err.Error()}
 	}
 
 	count := len(pvcs.Items)
@@ -122,7 +188,7 @@ func checkPVCs(clientset *kubernetes.Clientset) models.ResourceCheck {
 func checkServices(clientset *kubernetes.Clientset) models.ResourceCheck {
 	services, err := clientset.CoreV1().Services("").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{Label: "Services", Details: "Error fetching services", Status: false}
+		return models.ResourceCheck{Label: "Services", Details: "Error fetching services", Error: err.Error()}
 	}
 	count := len(services.Items)
 	details := fmt.Sprintf("Count of services: %d", count)
@@ -135,7 +201,7 @@ func checkServices(clientset *kubernetes.Clientset) models.ResourceCheck {
 func checkDeployments(clientset *kubernetes.Clientset) models.ResourceCheck {
 	deployments, err := clientset.AppsV1().Deployments("").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{Label: "Deployments", Details: "Error fetching deployments", Status: false}
+		return models.ResourceCheck{Label: "Deployments", Details: "Error fetching deployments", Error: err.Error()}
 	}
 
 	if len(deployments.Items) == 0 {
@@ -162,7 +228,7 @@ func checkDeployments(clientset *kubernetes.Clientset) models.ResourceCheck {
 func checkReplicaSets(clientset *kubernetes.Clientset) models.ResourceCheck {
 	replicasets, err := clientset.AppsV1().ReplicaSets("").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{Label: "Replica Sets", Details: "Error fetching replica sets", Status: false}
+		return models.ResourceCheck{Label: "Replica Sets", Details: "Error fetching replica sets", Error: err.Error()}
 	}
 
 	count := len(replicasets.Items)
@@ -192,33 +258,53 @@ func checkReplicaSets(clientset *kubernetes.Clientset) models.ResourceCheck {
 func checkEvents(clientset *kubernetes.Clientset) models.ResourceCheck {
 	events, err := clientset.CoreV1().Events("").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{Label: "Events", Details: "Error fetching events", Status: false}
+		return models.ResourceCheck{Label: "Events", Details: "Error fetching events", Error: err.Error()}
 	}
 
 	count := len(events.Items)
 	details := fmt.Sprintf("Count of Events: %d", count)
+	errorEvents := []string{}
+	findings := []models.Finding{}
 	if count == 0 {
 		details = "No errors found in events."
 	} else {
-		errorEvents := []string{}
+		// group warning events by the object they are about so a noisy object is a single finding
+		reasons := make(map[models.ResourceRef][]string)
+		counts := make(map[models.ResourceRef]int)
+		objects := []models.ResourceRef{}
 		for _, event := range events.Items {
 			if event.Type == "Warning" {
 				errorEvents = append(errorEvents, event.Reason)
+				ref := models.ResourceRef{Kind: event.InvolvedObject.Kind, Namespace: event.InvolvedObject.Namespace, Name: event.InvolvedObject.Name}
+				if _, found := counts[ref]; !found {
+					objects = append(objects, ref)
+				}
+				counts[ref]++
+				if !slices.Contains(reasons[ref], event.Reason) {
+					reasons[ref] = append(reasons[ref], event.Reason)
+				}
 			}
 		}
+		for _, ref := range objects {
+			findings = append(findings, models.Finding{
+				Resource: ref,
+				Severity: models.SeverityWarning,
+				Message:  fmt.Sprintf("%d warning events for %s: %s", counts[ref], ref, strings.Join(reasons[ref], ", ")),
+			})
+		}
 		if len(errorEvents) > 0 {
 			details = fmt.Sprintf("Warning events found: %d", len(errorEvents))
 		} else {
 			details = "No critical issues found in events."
 		}
 	}
-	return models.ResourceCheck{Label: "Events", Details: details, Status: count == 0}
+	return models.ResourceCheck{Label: "Events", Details: details, Status: len(errorEvents) == 0, Findings: findings}
 }
 
 func checkIngresses(clientset *kubernetes.Clientset) models.ResourceCheck {
 	ingresses, err := clientset.NetworkingV1().Ingresses("").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{Label: "Ingresses", Details: "Error fetching ingresses", Status: false}
+		return models.ResourceCheck{Label: "Ingresses", Details: "Error fetching ingresses", Error: err.Error()}
 	}
 
 	count := len(ingresses.Items)
@@ -232,7 +318,7 @@ func checkIngresses(clientset *kubernetes.Clientset) models.ResourceCheck {
 func checkDaemonSets(clientset *kubernetes.Clientset) models.ResourceCheck {
 	daemonsets, err := clientset.AppsV1().DaemonSets("").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{Label: "Daemon Sets", Details: "Error fetching daemon sets", Status: false}
+		return models.ResourceCheck{Label: "Daemon Sets", Details: "Error fetching daemon sets", Error: err.Error()}
 	}
 
 	count := len(daemonsets.Items)
@@ -262,7 +348,7 @@ func checkDaemonSets(clientset *kubernetes.Clientset) models.ResourceCheck {
 func checkStatefulSets(clientset *kubernetes.Clientset) models.ResourceCheck {
 	statefulsets, err := clientset.AppsV1().StatefulSets("").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{Label: "Stateful Sets", Details: "Error fetching stateful sets", Status: false}
+		return models.ResourceCheck{Label: "Stateful Sets", Details: "Error fetching stateful sets", Error: err.Error()}
 	}
 
 	count := len(statefulsets.Items)
@@ -0,0 +1,294 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultCanaryNamespace = "default"
+	defaultCanarySize      = "1Gi"
+	// stuckAttachment is how long a VolumeAttachment may stay unattached before it is reported
+	stuckAttachment = 5 * time.Minute
+	// noProvisioner is the provisioner of storage classes of statically created local volumes
+	noProvisioner = "kubernetes.io/no-provisioner"
+)
+
+// csiSidecars are the sidecar containers that identify CSI controller and node plugin pods
+var csiSidecars = map[string]bool{
+	"csi-provisioner":       true,
+	"csi-attacher":          true,
+	"csi-resizer":           true,
+	"csi-snapshotter":       true,
+	"node-driver-registrar": true,
+	"liveness-probe":        true,
+}
+
+// leaseName is how the leader election of the csi sidecars turns a driver name into a lease name
+var leaseName = regexp.MustCompile(`[^a-zA-Z0-9-]`)
+
+func CheckStorageClasses(clientset *kubernetes.Clientset) models.ResourceCheck {
+	storageClasses, err := clientset.StorageV1().StorageClasses().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Storage Classes", Details: "Error fetching storage classes", Error: err.Error()}
+	}
+	if len(storageClasses.Items) == 0 {
+		return models.ResourceCheck{Label: "Storage Classes", Details: "No storage classes found.", Status: true}
+	}
+
+	registered, nodes := csiNodeRegistrations(clientset)
+	drivers := make(map[string]bool)
+	if csiDrivers, err := clientset.StorageV1().CSIDrivers().List(context.Background(), metav1.ListOptions{}); err == nil {
+		for _, driver := range csiDrivers.Items {
+			drivers[driver.Name] = true
+		}
+	}
+
+	findings := []models.Finding{}
+	checkedDrivers := make(map[string]bool)
+	for _, sc := range storageClasses.Items {
+		driver := sc.Provisioner
+		if strings.HasPrefix(driver, "kubernetes.io/") || checkedDrivers[driver] {
+			continue
+		}
+		checkedDrivers[driver] = true
+		ref := models.ResourceRef{Kind: "StorageClass", Name: sc.Name}
+		if !drivers[driver] && len(registered[driver]) == 0 {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Driver", Severity: models.SeverityCritical,
+				Message: fmt.Sprintf("provisioner %s of storage class %s is not installed or not registered on any node", driver, sc.Name)})
+			continue
+		}
+		if missing := missingNodes(nodes, registered[driver]); len(missing) > 0 && len(registered[driver]) > 0 {
+			findings = append(findings, models.Finding{Resource: models.ResourceRef{Kind: "CSIDriver", Name: driver}, Reason: "NodePlugin", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("node plugin of %s is not registered on %d nodes: %s", driver, len(missing), strings.Join(missing, ", "))})
+		}
+		findings = append(findings, checkCSIControllerLease(clientset, driver)...)
+	}
+	findings = append(findings, checkCSIPods(clientset)...)
+	findings = append(findings, checkVolumeAttachments(clientset)...)
+
+	if settings.StorageClasses.Canary && probesDisabled() == "" {
+		for _, sc := range storageClasses.Items {
+			if sc.Provisioner == noProvisioner {
+				// local volumes are created by hand, a claim of such a class only binds to an existing volume
+				continue
+			}
+			if err := provisionCanary(clientset, sc); err != nil {
+				findings = append(findings, models.Finding{Resource: models.ResourceRef{Kind: "StorageClass", Name: sc.Name}, Reason: "Canary", Severity: models.SeverityCritical,
+					Message: fmt.Sprintf("canary claim of storage class %s failed: %v", sc.Name, err)})
+			}
+		}
+	}
+
+	details := fmt.Sprintf("%d storage classes, all CSI drivers are healthy.", len(storageClasses.Items))
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d storage classes, %d storage problems found.", len(storageClasses.Items), len(findings))
+	}
+	return models.ResourceCheck{Label: "Storage Classes", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// csiNodeRegistrations returns the nodes every driver is registered on and the names of all schedulable nodes
+func csiNodeRegistrations(clientset *kubernetes.Clientset) (map[string][]string, []string) {
+	registered := make(map[string][]string)
+	nodes := []string{}
+	if nodeList, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{}); err == nil {
+		for _, node := range nodeList.Items {
+			if !node.Spec.Unschedulable {
+				nodes = append(nodes, node.Name)
+			}
+		}
+	}
+	csiNodes, err := clientset.StorageV1().CSINodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return registered, nodes
+	}
+	for _, csiNode := range csiNodes.Items {
+		for _, driver := range csiNode.Spec.Drivers {
+			registered[driver.Name] = append(registered[driver.Name], csiNode.Name)
+		}
+	}
+	return registered, nodes
+}
+
+func missingNodes(nodes, registered []string) []string {
+	found := make(map[string]bool)
+	for _, node := range registered {
+		found[node] = true
+	}
+	missing := []string{}
+	for _, node := range nodes {
+		if !found[node] {
+			missing = append(missing, node)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// checkCSIControllerLease reports a controller whose csi-provisioner stopped renewing its leader lease
+func checkCSIControllerLease(clientset *kubernetes.Clientset, driver string) []models.Finding {
+	leases, err := clientset.CoordinationV1().Leases("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil
+	}
+	name := leaseName.ReplaceAllString(driver, "-")
+	for _, lease := range leases.Items {
+		if lease.Name != name || lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+			continue
+		}
+		expired := time.Since(lease.Spec.RenewTime.Time) > 2*time.Duration(*lease.Spec.LeaseDurationSeconds)*time.Second
+		if expired {
+			return []models.Finding{{
+				Resource: models.ResourceRef{Kind: "Lease", Namespace: lease.Namespace, Name: lease.Name},
+				Reason:   "Controller",
+				Severity: models.SeverityCritical,
+				Message:  fmt.Sprintf("controller of %s last renewed its lease %s ago, provisioning is stalled", driver, time.Since(lease.Spec.RenewTime.Time).Round(time.Second)),
+			}}
+		}
+	}
+	return nil
+}
+
+// checkCSIPods reports CSI controller and node plugin pods that are not ready
+func checkCSIPods(clientset *kubernetes.Clientset) []models.Finding {
+	findings := []models.Finding{}
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return findings
+	}
+	for _, pod := range pods.Items {
+		if !isCSIPod(pod) {
+			continue
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if !cs.Ready {
+				findings = append(findings, models.Finding{
+					Resource: models.ResourceRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name, Node: pod.Spec.NodeName},
+					Reason:   "CSIPod",
+					Severity: models.SeverityCritical,
+					Message:  fmt.Sprintf("CSI pod %s/%s container %s is not ready", pod.Namespace, pod.Name, cs.Name),
+				})
+				break
+			}
+		}
+	}
+	return findings
+}
+
+func isCSIPod(pod v1.Pod) bool {
+	for _, container := range pod.Spec.Containers {
+		if csiSidecars[container.Name] {
+			return true
+		}
+	}
+	return false
+}
+
+// checkVolumeAttachments reports attachments with errors or that did not attach within a few minutes
+func checkVolumeAttachments(clientset *kubernetes.Clientset) []models.Finding {
+	findings := []models.Finding{}
+	attachments, err := clientset.StorageV1().VolumeAttachments().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return findings
+	}
+	for _, va := range attachments.Items {
+		ref := models.ResourceRef{Kind: "VolumeAttachment", Name: va.Name, Node: va.Spec.NodeName}
+		pv := ""
+		if va.Spec.Source.PersistentVolumeName != nil {
+			pv = *va.Spec.Source.PersistentVolumeName
+		}
+		switch {
+		case va.Status.AttachError != nil:
+			findings = append(findings, models.Finding{Resource: ref, Reason: "AttachError", Severity: models.SeverityCritical,
+				Message: fmt.Sprintf("volume %s can not be attached to node %s: %s", pv, va.Spec.NodeName, va.Status.AttachError.Message)})
+		case va.Status.DetachError != nil:
+			findings = append(findings, models.Finding{Resource: ref, Reason: "DetachError", Severity: models.SeverityCritical,
+				Message: fmt.Sprintf("volume %s can not be detached from node %s: %s", pv, va.Spec.NodeName, va.Status.DetachError.Message)})
+		case !va.Status.Attached && va.DeletionTimestamp == nil && time.Since(va.CreationTimestamp.Time) > stuckAttachment:
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Stuck", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("volume %s is not attached to node %s after %s", pv, va.Spec.NodeName, time.Since(va.CreationTimestamp.Time).Round(time.Minute))})
+		case va.DeletionTimestamp != nil && time.Since(va.DeletionTimestamp.Time) > stuckAttachment:
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Stuck", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("volume %s is not detached from node %s after %s", pv, va.Spec.NodeName, time.Since(va.DeletionTimestamp.Time).Round(time.Minute))})
+		}
+	}
+	return findings
+}
+
+// provisionCanary creates a claim of the storage class, waits until it is bound and deletes it again.
+// Storage classes that wait for the first consumer get a probe pod, so the volume is really provisioned.
+func provisionCanary(clientset *kubernetes.Clientset, sc storagev1.StorageClass) error {
+	size, err := resource.ParseQuantity(valueOr(settings.StorageClasses.CanarySize, defaultCanarySize))
+	if err != nil {
+		return err
+	}
+	namespace := valueOr(settings.StorageClasses.CanaryNamespace, defaultCanaryNamespace)
+	claims := clientset.CoreV1().PersistentVolumeClaims(namespace)
+	claim, err := claims.Create(context.Background(), &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "healthctl-canary-",
+			Labels:       map[string]string{"app.kubernetes.io/managed-by": "healthctl"},
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &sc.Name,
+			AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			Resources:        v1.VolumeResourceRequirements{Requests: v1.ResourceList{v1.ResourceStorage: size}},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	defer claims.Delete(context.Background(), claim.Name, metav1.DeleteOptions{})
+
+	if sc.VolumeBindingMode != nil && *sc.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer {
+		pods := clientset.CoreV1().Pods(namespace)
+		pod, err := pods.Create(context.Background(), &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "healthctl-canary-",
+				Labels:       map[string]string{"app.kubernetes.io/managed-by": "healthctl"},
+			},
+			Spec: v1.PodSpec{
+				RestartPolicy: v1.RestartPolicyNever,
+				Containers: []v1.Container{{
+					Name:         "canary",
+					Image:        valueOr(settings.SharedFilesystems.ProbeImage, defaultProbeImage),
+					Command:      []string{"true"},
+					VolumeMounts: []v1.VolumeMount{{Name: "volume", MountPath: "/canary"}},
+				}},
+				Volumes: []v1.Volume{{
+					Name:         "volume",
+					VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claim.Name}},
+				}},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		defer pods.Delete(context.Background(), pod.Name, metav1.DeleteOptions{})
+	}
+
+	err = wait.PollUntilContextTimeout(context.Background(), 2*time.Second, 2*time.Minute, true, func(ctx context.Context) (bool, error) {
+		current, err := claims.Get(ctx, claim.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return current.Status.Phase == v1.ClaimBound, nil
+	})
+	if err != nil {
+		return fmt.Errorf("claim %s/%s was not bound within 2m: %v", namespace, claim.Name, err)
+	}
+	return nil
+}
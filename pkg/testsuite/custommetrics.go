@@ -0,0 +1,140 @@
+package testsuite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// metricsAPIs are the metrics APIs served by adapters like prometheus-adapter or KEDA
+var metricsAPIs = map[autoscalingv2.MetricSourceType]string{
+	autoscalingv2.PodsMetricSourceType:     "custom.metrics.k8s.io",
+	autoscalingv2.ObjectMetricSourceType:   "custom.metrics.k8s.io",
+	autoscalingv2.ExternalMetricSourceType: "external.metrics.k8s.io",
+}
+
+func checkCustomMetrics(clientset *kubernetes.Clientset) models.ResourceCheck {
+	hpas, err := clientset.AutoscalingV2().HorizontalPodAutoscalers("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Custom Metrics", Details: "Error fetching horizontal pod autoscalers", Error: err.Error()}
+	}
+	discovery, err := k8s.GetAPIResources(clientset)
+	if err != nil {
+		return models.ResourceCheck{Label: "Custom Metrics", Details: "Error discovering metrics APIs", Error: err.Error()}
+	}
+	versions := map[string]string{}
+	for _, group := range []string{"custom.metrics.k8s.io", "external.metrics.k8s.io"} {
+		versions[group] = metricsAPIVersion(discovery, group)
+	}
+
+	findings := []models.Finding{}
+	used := 0
+	for _, hpa := range hpas.Items {
+		ref := models.ResourceRef{Kind: "HorizontalPodAutoscaler", Namespace: hpa.Namespace, Name: hpa.Name}
+		for _, metric := range hpa.Spec.Metrics {
+			group, found := metricsAPIs[metric.Type]
+			if !found {
+				continue
+			}
+			used++
+			name := metricName(metric)
+			if versions[group] == "" {
+				findings = append(findings, models.Finding{Resource: ref, Reason: name, Severity: models.SeverityCritical,
+					Message: fmt.Sprintf("HPA %s/%s uses %s metric %s but %s is not served, autoscaling is stopped", hpa.Namespace, hpa.Name, metric.Type, name, group)})
+				continue
+			}
+			if err := resolveMetric(clientset, discovery, group+"/"+versions[group], hpa.Namespace, metric); err != nil {
+				findings = append(findings, models.Finding{Resource: ref, Reason: name, Severity: models.SeverityCritical,
+					Message: fmt.Sprintf("HPA %s/%s can not resolve %s metric %s: %v", hpa.Namespace, hpa.Name, metric.Type, name, err)})
+			}
+		}
+		for _, condition := range hpa.Status.Conditions {
+			if condition.Type == autoscalingv2.ScalingActive && condition.Status == v1.ConditionFalse && condition.Reason != "ScalingDisabled" {
+				findings = append(findings, models.Finding{Resource: ref, Reason: condition.Reason, Severity: models.SeverityWarning,
+					Message: fmt.Sprintf("HPA %s/%s is not scaling (%s): %s", hpa.Namespace, hpa.Name, condition.Reason, condition.Message)})
+			}
+		}
+	}
+
+	if used == 0 && len(findings) == 0 {
+		return models.ResourceCheck{Label: "Custom Metrics", Details: "No HPAs use custom or external metrics.", Status: true}
+	}
+	details := fmt.Sprintf("%d custom and external HPA metrics resolve data points.", used)
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d custom and external metrics problems found.", len(findings))
+	}
+	return models.ResourceCheck{Label: "Custom Metrics", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// metricsAPIVersion returns the newest served version of a metrics API group, or an empty string
+func metricsAPIVersion(discovery *k8s.APIDiscovery, group string) string {
+	for _, version := range []string{"v1beta2", "v1beta1"} {
+		if discovery.Serves(group + "/" + version) {
+			return version
+		}
+	}
+	return ""
+}
+
+func metricName(metric autoscalingv2.MetricSpec) string {
+	switch metric.Type {
+	case autoscalingv2.PodsMetricSourceType:
+		return metric.Pods.Metric.Name
+	case autoscalingv2.ObjectMetricSourceType:
+		return metric.Object.Metric.Name
+	case autoscalingv2.ExternalMetricSourceType:
+		return metric.External.Metric.Name
+	}
+	return string(metric.Type)
+}
+
+// resolveMetric queries the metrics API like the HPA controller does and fails when no data point is returned.
+// Pods metrics need the selector of the scale target and are covered by the ScalingActive condition.
+func resolveMetric(clientset *kubernetes.Clientset, discovery *k8s.APIDiscovery, groupVersion, namespace string, metric autoscalingv2.MetricSpec) error {
+	var path string
+	var selector *metav1.LabelSelector
+	switch metric.Type {
+	case autoscalingv2.ObjectMetricSourceType:
+		resource, found := discovery.ForKind(metric.Object.DescribedObject.Kind)
+		if !found {
+			return fmt.Errorf("unknown kind %s", metric.Object.DescribedObject.Kind)
+		}
+		path = fmt.Sprintf("/apis/%s/namespaces/%s/%s/%s/%s", groupVersion, namespace, resource.Name, metric.Object.DescribedObject.Name, metric.Object.Metric.Name)
+		selector = metric.Object.Metric.Selector
+	case autoscalingv2.ExternalMetricSourceType:
+		path = fmt.Sprintf("/apis/%s/namespaces/%s/%s", groupVersion, namespace, metric.External.Metric.Name)
+		selector = metric.External.Metric.Selector
+	default:
+		return nil
+	}
+	request := clientset.Discovery().RESTClient().Get().AbsPath(path)
+	if selector != nil {
+		labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			return err
+		}
+		request = request.Param("metricLabelSelector", labelSelector.String())
+	}
+	data, err := request.DoRaw(context.Background())
+	if err != nil {
+		return err
+	}
+	values := struct {
+		Items []json.RawMessage `json:"items"`
+	}{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	if len(values.Items) == 0 {
+		return fmt.Errorf("the metrics API returned no data points")
+	}
+	return nil
+}
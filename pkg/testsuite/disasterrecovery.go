@@ -0,0 +1,278 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/drill"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+	"healthctl/pkg/registry"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultMaxBackupAge = 24 * time.Hour
+	zoneLabel           = "topology.kubernetes.io/zone"
+)
+
+// datastoreImages are image names of datastores whose data must be replicated and backed up
+var datastoreImages = []string{"redis", "valkey", "postgres", "mysql", "mariadb", "mongo", "etcd", "cassandra", "elasticsearch", "opensearch", "kafka", "zookeeper", "rabbitmq", "minio"}
+
+// datastoreImage returns the datastore an image runs, or an empty string
+func datastoreImage(image string) string {
+	repository := registry.ParseReference(image).Repository
+	name := repository[strings.LastIndex(repository, "/")+1:]
+	for _, datastore := range datastoreImages {
+		if strings.Contains(name, datastore) {
+			return datastore
+		}
+	}
+	return ""
+}
+
+// clusterBackup is the newest successful backup of the cluster, from Velero or an etcd snapshot CronJob
+type clusterBackup struct {
+	Source    string
+	Completed time.Time
+}
+
+// clusterBackups returns the newest successful Velero backups and etcd snapshot CronJob runs
+func clusterBackups(clientset *kubernetes.Clientset) ([]k8s.VeleroBackup, []clusterBackup) {
+	backups := []clusterBackup{}
+	velero := []k8s.VeleroBackup{}
+	if discovery, err := k8s.GetAPIResources(clientset); err == nil && discovery.Serves("velero.io/v1") {
+		all, _ := k8s.GetVeleroBackups(clientset, valueOr(settings.DisasterRecovery.VeleroNamespace, "velero"))
+		for _, backup := range all {
+			if backup.Phase == "Completed" {
+				velero = append(velero, backup)
+			}
+		}
+		if len(velero) > 0 {
+			backups = append(backups, clusterBackup{Source: "velero backup " + velero[0].Name, Completed: velero[0].Completed})
+		}
+	}
+	cronjobs, err := clientset.BatchV1().CronJobs("").List(context.Background(), metav1.ListOptions{})
+	if err == nil {
+		for _, cronjob := range cronjobs.Items {
+			name := strings.ToLower(cronjob.Name)
+			if !strings.Contains(name, "etcd") || (!strings.Contains(name, "backup") && !strings.Contains(name, "snapshot")) {
+				continue
+			}
+			if cronjob.Status.LastSuccessfulTime != nil {
+				backups = append(backups, clusterBackup{Source: "CronJob " + cronjob.Namespace + "/" + cronjob.Name, Completed: cronjob.Status.LastSuccessfulTime.Time})
+			}
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Completed.After(backups[j].Completed) })
+	return velero, backups
+}
+
+// backupFindings reports a cluster without a recent backup and a failing backup and restore drill
+func backupFindings(backups []clusterBackup) []models.Finding {
+	maxAge := defaultMaxBackupAge
+	if parsed, err := time.ParseDuration(settings.DisasterRecovery.MaxBackupAge); err == nil {
+		maxAge = parsed
+	}
+	ref := models.ResourceRef{Kind: "Check", Name: "Disaster Recovery"}
+	findings := []models.Finding{}
+	if len(backups) == 0 {
+		findings = append(findings, models.Finding{Resource: ref, Reason: "NoBackup", Severity: models.SeverityCritical,
+			Message: "no successful Velero backup or etcd snapshot CronJob found, the cluster can not be restored"})
+	} else if age := time.Since(backups[0].Completed); age > maxAge {
+		findings = append(findings, models.Finding{Resource: ref, Reason: "BackupAge", Severity: models.SeverityCritical,
+			Message: fmt.Sprintf("the newest cluster backup, %s, completed %s ago, longer than %s", backups[0].Source, age.Round(time.Minute), maxAge)})
+	}
+	if drills, err := drill.LoadHistory(); err == nil && len(drills) > 0 {
+		if last := drills[len(drills)-1]; !last.Success {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Drill", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("the last backup and restore drill %s of namespace %s at %s failed", last.Name, last.Namespace, last.Started.Format(time.RFC3339))})
+		}
+	}
+	return findings
+}
+
+// replicationFindings reports datastores with a single replica or with all replicas in one zone
+func replicationFindings(statefulset appsv1.StatefulSet, datastore string, pods []v1.Pod, nodeZones map[string]string, zones int) []models.Finding {
+	ref := models.ResourceRef{Kind: "StatefulSet", Namespace: statefulset.Namespace, Name: statefulset.Name}
+	if replicas(statefulset.Spec.Replicas) < 2 {
+		return []models.Finding{{Resource: ref, Reason: "SingleReplica", Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("%s %s/%s runs a single replica, its data is lost with the volume", datastore, statefulset.Namespace, statefulset.Name)}}
+	}
+	if zones < 2 {
+		return nil
+	}
+	used := make(map[string]bool)
+	for _, pod := range pods {
+		if pod.Namespace == statefulset.Namespace && metav1.IsControlledBy(&pod, &statefulset) && nodeZones[pod.Spec.NodeName] != "" {
+			used[nodeZones[pod.Spec.NodeName]] = true
+		}
+	}
+	if len(used) == 1 {
+		return []models.Finding{{Resource: ref, Reason: "SingleZone", Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("all replicas of %s %s/%s run in one zone, a zone outage takes down every copy of its data", datastore, statefulset.Namespace, statefulset.Name)}}
+	}
+	return nil
+}
+
+func replicas(r *int32) int32 {
+	if r == nil {
+		return 1
+	}
+	return *r
+}
+
+// rpoFindings reports datastores without a documented RPO and those whose newest backup is older than it
+func rpoFindings(clientset *kubernetes.Clientset, statefulset appsv1.StatefulSet, datastore string, documented map[string]config.Datastore, velero []k8s.VeleroBackup) []models.Finding {
+	ref := models.ResourceRef{Kind: "StatefulSet", Namespace: statefulset.Namespace, Name: statefulset.Name}
+	entry, found := documented[statefulset.Namespace+"/"+statefulset.Name]
+	if !found {
+		return []models.Finding{{Resource: ref, Reason: "RPO", Severity: models.SeverityInfo,
+			Message: fmt.Sprintf("%s %s/%s has no documented RPO", datastore, statefulset.Namespace, statefulset.Name)}}
+	}
+	rpo, err := time.ParseDuration(entry.RPO)
+	if err != nil {
+		return []models.Finding{{Resource: ref, Reason: "RPO", Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("documented RPO %q of %s/%s is not a duration", entry.RPO, statefulset.Namespace, statefulset.Name)}}
+	}
+
+	source, last := "", time.Time{}
+	if entry.CronJob != "" {
+		cronjob, err := clientset.BatchV1().CronJobs(statefulset.Namespace).Get(context.Background(), entry.CronJob, metav1.GetOptions{})
+		if err == nil && cronjob.Status.LastSuccessfulTime != nil {
+			source, last = "backup CronJob "+entry.CronJob, cronjob.Status.LastSuccessfulTime.Time
+		}
+	} else {
+		for _, backup := range velero {
+			if backup.Includes(statefulset.Namespace) {
+				source, last = "velero backup "+backup.Name, backup.Completed
+				break
+			}
+		}
+	}
+	if last.IsZero() {
+		return []models.Finding{{Resource: ref, Reason: "RPO", Severity: models.SeverityCritical,
+			Message: fmt.Sprintf("%s %s/%s has an RPO of %s but no successful backup", datastore, statefulset.Namespace, statefulset.Name, rpo)}}
+	}
+	if age := time.Since(last); age > rpo {
+		return []models.Finding{{Resource: ref, Reason: "RPO", Severity: models.SeverityCritical,
+			Message: fmt.Sprintf("the newest backup of %s %s/%s, %s, is %s old, its RPO of %s is violated", datastore, statefulset.Namespace, statefulset.Name, source, age.Round(time.Minute), rpo)}}
+	}
+	return nil
+}
+
+// mirrorFindings reports running images missing in the DR registry, which is expected to hold every image
+// under its repository path, e.g. dr.example.com/mirror/library/nginx:1.27
+func mirrorFindings(clientset *kubernetes.Clientset, pods []v1.Pod) []models.Finding {
+	mirror := strings.TrimSuffix(settings.DisasterRecovery.Registry, "/")
+	if mirror == "" {
+		return nil
+	}
+	clients := make(map[string]*registry.Client)
+	owners := workloadOwners(clientset)
+	checked := make(map[string]bool)
+	findings := []models.Finding{}
+	for _, pod := range pods {
+		client := podRegistryClient(clientset, pod, clients)
+		for _, container := range append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+			if checked[container.Image] || strings.HasPrefix(container.Image, mirror+"/") {
+				continue
+			}
+			checked[container.Image] = true
+			ref := registry.ParseReference(container.Image)
+			separator := ":"
+			if strings.Contains(ref.Reference, ":") {
+				separator = "@"
+			}
+			mirrored := mirror + "/" + ref.Repository + separator + ref.Reference
+			exists, err := client.Exists(mirrored)
+			if exists {
+				continue
+			}
+			workload, found := podWorkload(pod, owners)
+			if !found {
+				workload = models.ResourceRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+			}
+			message := fmt.Sprintf("image %s of container %s is not mirrored to %s", container.Image, container.Name, mirrored)
+			if err != nil {
+				message = fmt.Sprintf("image %s of container %s could not be looked up in the DR registry: %v", container.Image, container.Name, err)
+			}
+			findings = append(findings, models.Finding{Resource: workload, Reason: "Mirror " + container.Name, Severity: models.SeverityWarning, Message: message})
+		}
+	}
+	return findings
+}
+
+// checkDisasterRecovery combines the disaster recovery readiness of the cluster: a recent cluster backup,
+// replicated datastores spread over zones, images mirrored to the DR registry and datastore backups
+// within their documented RPO
+func checkDisasterRecovery(clientset *kubernetes.Clientset) models.ResourceCheck {
+	statefulsets, err := clientset.AppsV1().StatefulSets("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Disaster Recovery", Details: "Error fetching statefulsets", Error: err.Error()}
+	}
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{FieldSelector: "status.phase=Running"})
+	if err != nil {
+		return models.ResourceCheck{Label: "Disaster Recovery", Details: "Error fetching pods", Error: err.Error()}
+	}
+	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Disaster Recovery", Details: "Error fetching nodes", Error: err.Error()}
+	}
+	nodeZones := make(map[string]string)
+	zones := make(map[string]bool)
+	for _, node := range nodes.Items {
+		if zone := node.Labels[zoneLabel]; zone != "" {
+			nodeZones[node.Name] = zone
+			zones[zone] = true
+		}
+	}
+	documented := make(map[string]config.Datastore)
+	for _, datastore := range settings.DisasterRecovery.Datastores {
+		documented[datastore.Namespace+"/"+datastore.Name] = datastore
+	}
+
+	velero, backups := clusterBackups(clientset)
+	findings := backupFindings(backups)
+	datastores := 0
+	for _, statefulset := range statefulsets.Items {
+		datastore := ""
+		for _, container := range statefulset.Spec.Template.Spec.Containers {
+			if datastore = datastoreImage(container.Image); datastore != "" {
+				break
+			}
+		}
+		if datastore == "" {
+			continue
+		}
+		datastores++
+		findings = append(findings, replicationFindings(statefulset, datastore, pods.Items, nodeZones, len(zones))...)
+		findings = append(findings, rpoFindings(clientset, statefulset, datastore, documented, velero)...)
+	}
+	findings = append(findings, mirrorFindings(clientset, pods.Items)...)
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Severity.Rank() > findings[j].Severity.Rank() })
+
+	failing := 0
+	for _, finding := range findings {
+		if finding.Failing() {
+			failing++
+		}
+	}
+	last := "no cluster backup"
+	if len(backups) > 0 {
+		last = fmt.Sprintf("newest cluster backup %s ago", time.Since(backups[0].Completed).Round(time.Minute))
+	}
+	details := fmt.Sprintf("DR ready: %s, %d datastores replicated and within their RPO.", last, datastores)
+	if failing > 0 {
+		details = fmt.Sprintf("%d DR gaps: %s, %d datastores.", failing, last, datastores)
+	}
+	return models.ResourceCheck{Label: "Disaster Recovery", Details: details, Status: failing == 0, Findings: findings}
+}
@@ -0,0 +1,142 @@
+package testsuite
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"healthctl/pkg/models"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultDNSName       = "kubernetes.default.svc.cluster.local"
+	defaultDNSQueries    = 20
+	defaultMaxDNSLatency = 100 * time.Millisecond
+	// conntrack usage in percent of nf_conntrack_max that is reported
+	conntrackWarning  = 80
+	conntrackCritical = 95
+)
+
+// dnsProbeScript times every lookup in milliseconds and prints the conntrack count and max of the node
+func dnsProbeScript(name string, queries int) string {
+	return fmt.Sprintf(`for i in $(seq 1 %d); do
+  start=$(date +%%s%%N)
+  if nslookup %s >/dev/null 2>&1; then result=ok; else result=fail; fi
+  end=$(date +%%s%%N)
+  echo "dns $result $(( (end - start) / 1000000 ))"
+done
+echo "conntrack $(cat /proc/sys/net/netfilter/nf_conntrack_count 2>/dev/null || echo -) $(cat /proc/sys/net/netfilter/nf_conntrack_max 2>/dev/null || echo -)"
+`, queries, name)
+}
+
+// dnsProbeResult is the parsed output of the probe on one node
+type dnsProbeResult struct {
+	latencies      []time.Duration
+	failures       int
+	conntrackCount int64
+	conntrackMax   int64
+}
+
+func parseDNSProbe(output string) dnsProbeResult {
+	result := dnsProbeResult{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 3 && fields[0] == "dns":
+			if fields[1] != "ok" {
+				result.failures++
+				continue
+			}
+			if ms, err := strconv.Atoi(fields[2]); err == nil {
+				result.latencies = append(result.latencies, time.Duration(ms)*time.Millisecond)
+			}
+		case len(fields) == 3 && fields[0] == "conntrack":
+			result.conntrackCount, _ = strconv.ParseInt(fields[1], 10, 64)
+			result.conntrackMax, _ = strconv.ParseInt(fields[2], 10, 64)
+		}
+	}
+	sort.Slice(result.latencies, func(i, j int) bool { return result.latencies[i] < result.latencies[j] })
+	return result
+}
+
+// percentile returns the p-th percentile of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[(len(sorted)-1)*p/100]
+}
+
+// checkDNSAndConntrack measures the DNS latency from pods on a sample of nodes and reads the
+// conntrack table usage of those nodes, a full conntrack table drops new connections silently
+func checkDNSAndConntrack(clientset *kubernetes.Clientset) models.ResourceCheck {
+	if mode := probesDisabled(); mode != "" {
+		return skippedInMode("DNS and Conntrack", mode)
+	}
+	nodes, err := sampleNodes(clientset, valueOr(settings.Network.ProbeNodes, defaultProbeNodes))
+	if err != nil {
+		return models.ResourceCheck{Label: "DNS and Conntrack", Details: "Error fetching nodes", Error: err.Error()}
+	}
+	if len(nodes) == 0 {
+		return models.ResourceCheck{Label: "DNS and Conntrack", Details: "No ready nodes to probe from.", Status: false}
+	}
+
+	maxLatency := defaultMaxDNSLatency
+	if parsed, err := time.ParseDuration(settings.Network.MaxDNSLatency); err == nil {
+		maxLatency = parsed
+	}
+	name := valueOr(settings.Network.DNSName, defaultDNSName)
+	// host network shows the conntrack table of the node, the privileged container can read it on every runtime
+	probe := nodeProbe{Name: "dns-probe", Script: dnsProbeScript(name, valueOr(settings.Network.DNSQueries, defaultDNSQueries)), HostNetwork: true, Privileged: true}
+	outputs, errs := runNodeProbes(clientset, probe, nodes)
+
+	findings := []models.Finding{}
+	all := []time.Duration{}
+	for _, node := range nodes {
+		ref := models.ResourceRef{Kind: "Node", Name: node, Node: node}
+		if err, failed := errs[node]; failed {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Probe", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("network probe on node %s failed: %v", node, err)})
+			continue
+		}
+		result := parseDNSProbe(outputs[node])
+		all = append(all, result.latencies...)
+		queries := result.failures + len(result.latencies)
+		if result.failures > 0 {
+			severity := models.SeverityWarning
+			if len(result.latencies) == 0 {
+				severity = models.SeverityCritical
+			}
+			findings = append(findings, models.Finding{Resource: ref, Reason: "DNSFailures", Severity: severity,
+				Message: fmt.Sprintf("%d of %d DNS lookups of %s from node %s failed", result.failures, queries, name, node)})
+		}
+		if p95 := percentile(result.latencies, 95); p95 > maxLatency {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "DNSLatency", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("DNS latency from node %s is p50 %s, p95 %s, max %s, above %s", node,
+					percentile(result.latencies, 50), p95, percentile(result.latencies, 100), maxLatency)})
+		}
+		if result.conntrackMax > 0 {
+			usage := result.conntrackCount * 100 / result.conntrackMax
+			if usage >= conntrackWarning {
+				severity := models.SeverityWarning
+				if usage >= conntrackCritical {
+					severity = models.SeverityCritical
+				}
+				findings = append(findings, models.Finding{Resource: ref, Reason: "Conntrack", Severity: severity,
+					Message: fmt.Sprintf("conntrack table of node %s is %d%% full (%d of %d entries), new connections are dropped when it is full",
+						node, usage, result.conntrackCount, result.conntrackMax)})
+			}
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+
+	details := fmt.Sprintf("DNS from %d nodes p50 %s, p95 %s, p99 %s.", len(nodes), percentile(all, 50), percentile(all, 95), percentile(all, 99))
+	if len(findings) > 0 {
+		details += fmt.Sprintf(" %d DNS or conntrack problems found.", len(findings))
+	}
+	return models.ResourceCheck{Label: "DNS and Conntrack", Details: details, Status: len(findings) == 0, Findings: findings}
+}
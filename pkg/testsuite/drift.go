@@ -0,0 +1,156 @@
+package testsuite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"healthctl/pkg/drift"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+)
+
+// maxDriftPaths is how many drifted fields a finding lists
+const maxDriftPaths = 5
+
+// checkManifestDrift compares the live objects of the configured namespaces with the manifests in source
+// control. Every manifest is applied with a server side dry-run, the fields the apply would change drifted,
+// usually by a manual kubectl edit. Objects that are declared but do not exist are reported as well.
+func checkManifestDrift(clientset *kubernetes.Clientset) models.ResourceCheck {
+	cfg := settings.Drift
+	if len(cfg.Namespaces) == 0 {
+		return models.ResourceCheck{Label: "Manifest Drift", Details: "No namespaces configured in checks.drift.", Status: true, Skipped: "not configured"}
+	}
+	if mode := probesDisabled(); mode == "gentle mode" {
+		return skippedInMode("Manifest Drift", mode)
+	}
+
+	dir, source := cfg.Directory, cfg.Directory
+	if cfg.Repository != "" {
+		clone, commit, err := drift.Checkout(cfg.Repository, cfg.Ref)
+		if err != nil {
+			return models.ResourceCheck{Label: "Manifest Drift", Details: "Error reading the manifests of " + cfg.Repository, Error: err.Error()}
+		}
+		dir, source = filepath.Join(clone, cfg.Path), cfg.Repository+"@"+commit
+	}
+	manifests, err := drift.Load(dir)
+	if err != nil {
+		return models.ResourceCheck{Label: "Manifest Drift", Details: "Error reading the manifests of " + source, Error: err.Error()}
+	}
+	groupResources, err := k8s.GetAPIGroupResources(clientset)
+	if err != nil {
+		return models.ResourceCheck{Label: "Manifest Drift", Details: "Error discovering the API resources", Error: err.Error()}
+	}
+	mapper := restmapper.NewDiscoveryRESTMapper(groupResources)
+
+	findings := []models.Finding{}
+	compared := 0
+	for _, manifest := range manifests {
+		object := manifest.Object
+		if object.GetNamespace() == "" {
+			object.SetNamespace(cfg.DefaultNamespace)
+		}
+		if object.GetNamespace() == "" || !matchesAny(cfg.Namespaces, object.GetNamespace()) {
+			continue
+		}
+		ref := models.ResourceRef{Kind: object.GetKind(), Namespace: object.GetNamespace(), Name: object.GetName()}
+		gvk := object.GroupVersionKind()
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			compared++
+			findings = append(findings, models.Finding{Resource: ref, Reason: "UnknownKind", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("%s of %s is not served by the cluster", gvk.GroupVersion().WithKind(gvk.Kind), manifest.File)})
+			continue
+		}
+		// cluster scoped objects do not belong to the namespaces
+		if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			continue
+		}
+		compared++
+		finding, drifted := compareManifest(clientset, mapping, object, manifest.File)
+		if drifted {
+			finding.Resource = ref
+			findings = append(findings, finding)
+		}
+	}
+
+	details := fmt.Sprintf("%d objects match %s.", compared, source)
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d of %d objects differ from %s.", len(findings), compared, source)
+	}
+	return models.ResourceCheck{Label: "Manifest Drift", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// compareManifest reads the live object and applies the manifest with a dry-run, it returns a finding when
+// the object is missing, the apply fails or changes fields
+func compareManifest(clientset *kubernetes.Clientset, mapping *meta.RESTMapping, object unstructured.Unstructured, file string) (models.Finding, bool) {
+	path := objectPath(mapping, object.GetNamespace(), object.GetName())
+	rest := clientset.Discovery().RESTClient()
+	data, err := rest.Get().AbsPath(path).DoRaw(context.Background())
+	if apierrors.IsNotFound(err) {
+		return models.Finding{Reason: "NotDeployed", Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("%s %s is declared in %s but does not exist", object.GetKind(), object.GetName(), file)}, true
+	}
+	if err != nil {
+		return models.Finding{Reason: "ReadFailed", Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("reading %s %s failed: %v", object.GetKind(), object.GetName(), err)}, true
+	}
+	live := unstructured.Unstructured{}
+	if err := json.Unmarshal(data, &live.Object); err != nil {
+		return models.Finding{Reason: "ReadFailed", Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("reading %s %s failed: %v", object.GetKind(), object.GetName(), err)}, true
+	}
+
+	manifest, err := json.Marshal(object.Object)
+	if err != nil {
+		return models.Finding{Reason: "DryRunFailed", Severity: models.SeverityWarning, Message: err.Error()}, true
+	}
+	data, err = rest.Patch(types.ApplyPatchType).AbsPath(path).
+		Param("dryRun", "All").
+		Param("fieldManager", drift.FieldManager).
+		Param("force", "true").
+		Body(manifest).
+		DoRaw(context.Background())
+	applied := unstructured.Unstructured{}
+	if err == nil {
+		err = json.Unmarshal(data, &applied.Object)
+	}
+	if err != nil {
+		return models.Finding{Reason: "DryRunFailed", Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("dry-run apply of %s failed: %v", file, err)}, true
+	}
+
+	paths := drift.Diff(live, applied, settings.Drift.Ignore)
+	if len(paths) == 0 {
+		return models.Finding{}, false
+	}
+	listed := paths
+	if len(listed) > maxDriftPaths {
+		listed = append(listed[:maxDriftPaths:maxDriftPaths], fmt.Sprintf("%d more", len(paths)-maxDriftPaths))
+	}
+	message := fmt.Sprintf("%s %s differs from %s in %s", object.GetKind(), object.GetName(), file, strings.Join(listed, ", "))
+	if edits := drift.ManualEdits(live); len(edits) > 0 {
+		message += fmt.Sprintf(", last changed by hand with %s at %s", edits[0].Manager, edits[0].Time.Format(time.RFC3339))
+	}
+	return models.Finding{Reason: "Drifted", Severity: models.SeverityWarning, Message: message}, true
+}
+
+// objectPath returns the API path of a namespaced object
+func objectPath(mapping *meta.RESTMapping, namespace, name string) string {
+	resource := mapping.Resource
+	path := "/apis/" + resource.Group + "/" + resource.Version
+	if resource.Group == "" {
+		path = "/api/" + resource.Version
+	}
+	return path + "/namespaces/" + namespace + "/" + resource.Resource + "/" + name
+}
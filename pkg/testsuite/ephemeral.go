@@ -0,0 +1,117 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ephemeralWarning is the usage of its ephemeral-storage limit in percent at which a pod is reported,
+	// the kubelet evicts the pod when it reaches the limit
+	ephemeralWarning  = 80
+	ephemeralCritical = 95
+	// defaultNodeFsPercent is the node and image filesystem usage that is reported, the kubelet
+	// starts evicting pods at 85% nodefs and imagefs usage by default
+	defaultNodeFsPercent = 80
+)
+
+// checkEphemeralStorage reads the kubelet stats summary of every node and reports pods close to their
+// ephemeral-storage limit and nodes whose root or image filesystem is close to the eviction threshold
+func checkEphemeralStorage(clientset *kubernetes.Clientset) models.ResourceCheck {
+	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Ephemeral Storage", Details: "Error fetching nodes", Error: err.Error()}
+	}
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{FieldSelector: "status.phase=Running"})
+	if err != nil {
+		return models.ResourceCheck{Label: "Ephemeral Storage", Details: "Error fetching pods", Error: err.Error()}
+	}
+	limits := make(map[string]resource.Quantity)
+	for _, pod := range pods.Items {
+		if limit, found := ephemeralLimit(pod); found {
+			limits[pod.Namespace+"/"+pod.Name] = limit
+		}
+	}
+
+	nodeFsPercent := uint64(valueOr(settings.EphemeralStorage.NodeFsPercent, defaultNodeFsPercent))
+	kc := &k8s.K8sClient{Client: clientset}
+	findings := []models.Finding{}
+	unreachable := 0
+	for _, node := range nodes.Items {
+		if !nodeReady(node) {
+			continue
+		}
+		summary, err := kc.GetNodeStatsSummary(node.Name)
+		if err != nil {
+			unreachable++
+			continue
+		}
+		ref := models.ResourceRef{Kind: "Node", Name: node.Name, Node: node.Name}
+		filesystems := map[string]*k8s.FsStats{"nodefs": summary.Node.Fs}
+		if summary.Node.Runtime != nil {
+			filesystems["imagefs"] = summary.Node.Runtime.ImageFs
+		}
+		for name, fs := range filesystems {
+			if used, found := fs.UsedPercent(); found && used >= nodeFsPercent {
+				findings = append(findings, models.Finding{Resource: ref, Reason: name, Severity: models.SeverityWarning,
+					Message: fmt.Sprintf("%s of node %s is %d%% full, above the threshold of %d%%", name, node.Name, used, nodeFsPercent)})
+			}
+		}
+
+		for _, pod := range summary.Pods {
+			key := pod.PodRef.Namespace + "/" + pod.PodRef.Name
+			limit, found := limits[key]
+			if !found || pod.EphemeralStorage == nil || pod.EphemeralStorage.UsedBytes == nil || limit.Value() == 0 {
+				continue
+			}
+			used := int64(*pod.EphemeralStorage.UsedBytes)
+			percent := used * 100 / limit.Value()
+			if percent < ephemeralWarning {
+				continue
+			}
+			severity := models.SeverityWarning
+			if percent >= ephemeralCritical {
+				severity = models.SeverityCritical
+			}
+			findings = append(findings, models.Finding{
+				Resource: models.ResourceRef{Kind: "Pod", Namespace: pod.PodRef.Namespace, Name: pod.PodRef.Name, Node: node.Name},
+				Reason:   "EphemeralLimit",
+				Severity: severity,
+				Message: fmt.Sprintf("Pod %s uses %d%% of its ephemeral-storage limit (%s of %s), it is evicted at the limit",
+					key, percent, resource.NewQuantity(used, resource.BinarySI), limit.String()),
+			})
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool { return findings[i].Resource.String() < findings[j].Resource.String() })
+
+	details := fmt.Sprintf("%d nodes, %d pods with ephemeral-storage limits, no pod or node close to eviction.", len(nodes.Items), len(limits))
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d pods or node filesystems close to ephemeral-storage eviction.", len(findings))
+	}
+	if unreachable > 0 {
+		details += fmt.Sprintf(" Stats of %d nodes could not be read.", unreachable)
+	}
+	return models.ResourceCheck{Label: "Ephemeral Storage", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// ephemeralLimit returns the sum of the ephemeral-storage limits of the containers, when all of them have one
+func ephemeralLimit(pod v1.Pod) (resource.Quantity, bool) {
+	total := resource.Quantity{}
+	for _, container := range pod.Spec.Containers {
+		limit, found := container.Resources.Limits[v1.ResourceEphemeralStorage]
+		if !found {
+			return total, false
+		}
+		total.Add(limit)
+	}
+	return total, len(pod.Spec.Containers) > 0
+}
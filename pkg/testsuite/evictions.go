@@ -0,0 +1,221 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// defaultEvictionWindow is how far back OOMKills and evictions are counted
+const defaultEvictionWindow = 24 * time.Hour
+
+// evictedResource extracts the resource from the kubelet eviction message "The node was low on resource: memory."
+var evictedResource = regexp.MustCompile(`low on resource: (\w[\w-]*)`)
+
+// memoryHistory counts the OOMKills and evictions of one workload or node
+type memoryHistory struct {
+	oomKills  int
+	evictions map[string]int
+	systemOOM int
+	related   map[string]bool
+}
+
+func (h *memoryHistory) evicted(resource string) {
+	if h.evictions == nil {
+		h.evictions = make(map[string]int)
+	}
+	h.evictions[resource]++
+}
+
+func (h *memoryHistory) relate(name string) {
+	if h.related == nil {
+		h.related = make(map[string]bool)
+	}
+	if name != "" {
+		h.related[name] = true
+	}
+}
+
+// summary returns e.g. "3 OOMKills, 2 evictions (memory 2)"
+func (h *memoryHistory) summary() string {
+	parts := []string{}
+	if h.oomKills > 0 {
+		parts = append(parts, fmt.Sprintf("%d OOMKills", h.oomKills))
+	}
+	if h.systemOOM > 0 {
+		parts = append(parts, fmt.Sprintf("%d system OOMs", h.systemOOM))
+	}
+	if len(h.evictions) > 0 {
+		total := 0
+		resources := []string{}
+		for resource, count := range h.evictions {
+			total += count
+			resources = append(resources, fmt.Sprintf("%s %d", resource, count))
+		}
+		sort.Strings(resources)
+		parts = append(parts, fmt.Sprintf("%d evictions (%s)", total, strings.Join(resources, ", ")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (h *memoryHistory) relatedNames() string {
+	names := []string{}
+	for name := range h.related {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// checkEvictions aggregates OOMKilled containers, evicted pods and node pressure evictions
+// per workload and per node, so memory sizing problems show up as one finding each
+func checkEvictions(clientset *kubernetes.Clientset) models.ResourceCheck {
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Evictions", Details: "Error fetching pods", Error: err.Error()}
+	}
+
+	window := defaultEvictionWindow
+	if parsed, err := time.ParseDuration(settings.Evictions.Window); err == nil {
+		window = parsed
+	}
+	cutoff := time.Now().Add(-window)
+
+	owners := workloadOwners(clientset)
+	workloads := make(map[models.ResourceRef]*memoryHistory)
+	nodes := make(map[string]*memoryHistory)
+	historyOf := func(workload models.ResourceRef) *memoryHistory {
+		if workloads[workload] == nil {
+			workloads[workload] = &memoryHistory{}
+		}
+		return workloads[workload]
+	}
+	nodeHistory := func(node string) *memoryHistory {
+		if nodes[node] == nil {
+			nodes[node] = &memoryHistory{}
+		}
+		return nodes[node]
+	}
+
+	// evictions and OOMKills are keyed by pod UID, every one is counted once however often it is reported
+	evictedPods := make(map[types.UID]bool)
+	oomKills := make(map[string]bool)
+	for _, pod := range pods.Items {
+		workload, found := podWorkload(pod, owners)
+		if !found {
+			workload = models.ResourceRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+		}
+		for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			for _, terminated := range []*v1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+				if terminated == nil || terminated.Reason != "OOMKilled" || terminated.FinishedAt.Time.Before(cutoff) {
+					continue
+				}
+				kill := fmt.Sprintf("%s/%s/%d", pod.UID, status.Name, terminated.FinishedAt.Unix())
+				if oomKills[kill] {
+					continue
+				}
+				oomKills[kill] = true
+				historyOf(workload).oomKills++
+				historyOf(workload).relate(pod.Spec.NodeName)
+				nodeHistory(pod.Spec.NodeName).relate(workload.String())
+			}
+		}
+		if pod.Status.Reason == "Evicted" && !evictedAt(pod).Before(cutoff) {
+			resource := "unknown"
+			if match := evictedResource.FindStringSubmatch(pod.Status.Message); match != nil {
+				resource = match[1]
+			}
+			evictedPods[pod.UID] = true
+			historyOf(workload).evicted(resource)
+			historyOf(workload).relate(pod.Spec.NodeName)
+			if pod.Spec.NodeName != "" {
+				nodeHistory(pod.Spec.NodeName).evicted(resource)
+				nodeHistory(pod.Spec.NodeName).relate(workload.String())
+			}
+		}
+	}
+	evictionEvents(clientset, cutoff, evictedPods, nodeHistory)
+
+	findings := []models.Finding{}
+	for workload, history := range workloads {
+		findings = append(findings, models.Finding{
+			Resource: workload,
+			Reason:   "MemoryHistory",
+			Severity: models.SeverityWarning,
+			Message:  fmt.Sprintf("%s had %s in the last %s on nodes %s", workload, history.summary(), window, history.relatedNames()),
+		})
+	}
+	for node, history := range nodes {
+		message := fmt.Sprintf("Node %s had %s in the last %s", node, history.summary(), window)
+		if len(history.related) > 0 {
+			message += fmt.Sprintf(", affected workloads: %s", history.relatedNames())
+		}
+		findings = append(findings, models.Finding{
+			Resource: models.ResourceRef{Kind: "Node", Name: node, Node: node},
+			Reason:   "MemoryHistory",
+			Severity: models.SeverityWarning,
+			Message:  message,
+		})
+	}
+	sort.Slice(findings, func(i, j int) bool { return findings[i].Resource.String() < findings[j].Resource.String() })
+
+	details := fmt.Sprintf("No OOMKills or evictions in the last %s.", window)
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d workloads and %d nodes had OOMKills or evictions in the last %s.", len(workloads), len(nodes), window)
+	}
+	return models.ResourceCheck{Label: "Evictions", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// evictedAt returns when the pod was evicted, the DisruptionTarget condition is only set by recent kubelets
+func evictedAt(pod v1.Pod) time.Time {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.DisruptionTarget && condition.Status == v1.ConditionTrue {
+			return condition.LastTransitionTime.Time
+		}
+	}
+	if pod.Status.StartTime != nil {
+		return pod.Status.StartTime.Time
+	}
+	return pod.CreationTimestamp.Time
+}
+
+// evictionEvents adds the evictions of pods that were already deleted and the system OOMs of nodes.
+// A pod evicted several times in the events is counted once.
+func evictionEvents(clientset *kubernetes.Clientset, cutoff time.Time, evictedPods map[types.UID]bool, nodeHistory func(string) *memoryHistory) {
+	events, err := clientset.CoreV1().Events("").List(context.Background(), metav1.ListOptions{
+		FieldSelector: "type=Warning",
+	})
+	if err != nil {
+		return
+	}
+	for _, event := range events.Items {
+		if event.LastTimestamp.Time.Before(cutoff) && event.EventTime.Time.Before(cutoff) {
+			continue
+		}
+		switch {
+		case event.Reason == "SystemOOM" && event.InvolvedObject.Kind == "Node":
+			nodeHistory(event.InvolvedObject.Name).systemOOM += int(max(event.Count, 1))
+		case event.Reason == "Evicted" && event.InvolvedObject.Kind == "Pod":
+			if evictedPods[event.InvolvedObject.UID] || event.Source.Host == "" {
+				continue
+			}
+			evictedPods[event.InvolvedObject.UID] = true
+			resource := "unknown"
+			if match := evictedResource.FindStringSubmatch(event.Message); match != nil {
+				resource = match[1]
+			}
+			nodeHistory(event.Source.Host).evicted(resource)
+			nodeHistory(event.Source.Host).relate(event.InvolvedObject.Namespace + "/" + event.InvolvedObject.Name)
+		}
+	}
+}
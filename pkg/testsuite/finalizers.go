@@ -0,0 +1,88 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// defaultStuckAfter is how long an object may be terminating before it is reported
+const defaultStuckAfter = 10 * time.Minute
+
+// namespaceDeletionConditions are the namespace conditions that explain why its deletion does not finish
+var namespaceDeletionConditions = map[v1.NamespaceConditionType]bool{
+	v1.NamespaceDeletionDiscoveryFailure: true,
+	v1.NamespaceDeletionContentFailure:   true,
+	v1.NamespaceDeletionGVParsingFailure: true,
+	v1.NamespaceContentRemaining:         true,
+	v1.NamespaceFinalizersRemaining:      true,
+}
+
+// StuckAfter returns how long an object may be terminating before it counts as stuck
+func StuckAfter() time.Duration {
+	if parsed, err := time.ParseDuration(settings.Finalizers.StuckAfter); err == nil {
+		return parsed
+	}
+	return defaultStuckAfter
+}
+
+// checkStuckDeletions finds namespaces and objects stuck in Terminating, with the finalizers holding them
+// and the controller that should remove them
+func checkStuckDeletions(clientset *kubernetes.Clientset) models.ResourceCheck {
+	stuckAfter := StuckAfter()
+	namespaces, err := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Stuck Deletions", Details: "Error fetching namespaces", Error: err.Error()}
+	}
+
+	findings := []models.Finding{}
+	for _, namespace := range namespaces.Items {
+		if namespace.DeletionTimestamp == nil || time.Since(namespace.DeletionTimestamp.Time) < stuckAfter {
+			continue
+		}
+		reasons := []string{}
+		for _, condition := range namespace.Status.Conditions {
+			if namespaceDeletionConditions[condition.Type] && condition.Status == v1.ConditionTrue {
+				reasons = append(reasons, condition.Message)
+			}
+		}
+		message := fmt.Sprintf("Namespace %s is terminating for %s", namespace.Name, time.Since(namespace.DeletionTimestamp.Time).Round(time.Minute))
+		if len(reasons) > 0 {
+			message += ": " + strings.Join(reasons, "; ")
+		}
+		findings = append(findings, models.Finding{Resource: models.ResourceRef{Kind: "Namespace", Name: namespace.Name}, Reason: "Terminating", Severity: models.SeverityWarning, Message: message})
+	}
+
+	stuck, err := k8s.GetStuckObjects(clientset, stuckAfter)
+	if err != nil {
+		return models.ResourceCheck{Label: "Stuck Deletions", Details: fmt.Sprintf("Error listing objects: %v", err), Error: err.Error()}
+	}
+	for _, object := range stuck {
+		holders := []string{}
+		for _, finalizer := range object.Finalizers {
+			owner, _ := k8s.FinalizerOwner(finalizer)
+			holders = append(holders, fmt.Sprintf("%s (%s)", finalizer, owner))
+		}
+		findings = append(findings, models.Finding{
+			Resource: object.Resource,
+			Reason:   "Finalizers",
+			Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("%s is terminating for %s, held by finalizers %s", object.Resource,
+				time.Since(object.Deleted).Round(time.Minute), strings.Join(holders, ", ")),
+		})
+	}
+
+	details := "No namespaces or objects stuck in Terminating."
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d namespaces and objects stuck in Terminating for more than %s.", len(findings), stuckAfter)
+	}
+	return models.ResourceCheck{Label: "Stuck Deletions", Details: details, Status: len(findings) == 0, Findings: findings}
+}
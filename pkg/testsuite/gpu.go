@@ -0,0 +1,171 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultGPUResource = "nvidia.com/gpu"
+	// labels set by NVIDIA gpu-feature-discovery
+	gpuPresentLabel = "nvidia.com/gpu.present"
+	gpuCountLabel   = "nvidia.com/gpu.count"
+)
+
+func checkGPUs(clientset *kubernetes.Clientset) models.ResourceCheck {
+	resources := settings.GPU.Resources
+	if len(resources) == 0 {
+		resources = []string{defaultGPUResource}
+	}
+
+	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "GPUs", Details: "Error fetching nodes", Error: err.Error()}
+	}
+
+	findings := checkDevicePlugins(clientset)
+	gpuNodes := 0
+	for _, node := range nodes.Items {
+		if !isGPUNode(node, resources) {
+			continue
+		}
+		gpuNodes++
+		findings = append(findings, checkGPUNode(node, resources)...)
+	}
+	if gpuNodes == 0 && len(findings) == 0 {
+		return models.ResourceCheck{Label: "GPUs", Details: "No GPU nodes found.", Status: true}
+	}
+
+	findings = append(findings, checkGPUPendingPods(clientset, resources)...)
+
+	details := fmt.Sprintf("%d GPU nodes, all devices are advertised and allocatable.", gpuNodes)
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d GPU nodes, %d GPU problems found.", gpuNodes, len(findings))
+	}
+	return models.ResourceCheck{Label: "GPUs", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// isGPUNode returns true for nodes that should advertise GPUs: labelled by gpu-feature-discovery,
+// selected by the configured node selector, or already advertising capacity
+func isGPUNode(node v1.Node, resources []string) bool {
+	if node.Labels[gpuPresentLabel] == "true" {
+		return true
+	}
+	if len(settings.GPU.NodeSelector) > 0 {
+		selected := true
+		for key, value := range settings.GPU.NodeSelector {
+			if node.Labels[key] != value {
+				selected = false
+			}
+		}
+		if selected {
+			return true
+		}
+	}
+	for _, resource := range resources {
+		if capacity, found := node.Status.Capacity[v1.ResourceName(resource)]; found && !capacity.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+func checkGPUNode(node v1.Node, resources []string) []models.Finding {
+	findings := []models.Finding{}
+	ref := models.ResourceRef{Kind: "Node", Name: node.Name, Node: node.Name}
+
+	expected := settings.GPU.ExpectedPerNode
+	if count, err := strconv.ParseInt(node.Labels[gpuCountLabel], 10, 64); err == nil && expected == 0 {
+		expected = count
+	}
+
+	for _, resource := range resources {
+		capacity := node.Status.Capacity[v1.ResourceName(resource)]
+		allocatable := node.Status.Allocatable[v1.ResourceName(resource)]
+		switch {
+		case capacity.Value() == 0:
+			findings = append(findings, models.Finding{
+				Resource: ref,
+				Reason:   resource + "/capacity",
+				Severity: models.SeverityCritical,
+				Message:  fmt.Sprintf("Node %s does not advertise %s, the device plugin is not registered", node.Name, resource),
+			})
+			continue
+		case expected > 0 && capacity.Value() < expected:
+			findings = append(findings, models.Finding{
+				Resource: ref,
+				Reason:   resource + "/capacity",
+				Severity: models.SeverityWarning,
+				Message:  fmt.Sprintf("Node %s advertises %d %s, expected %d", node.Name, capacity.Value(), resource, expected),
+			})
+		}
+		if allocatable.Value() < capacity.Value() {
+			findings = append(findings, models.Finding{
+				Resource: ref,
+				Reason:   resource + "/allocatable",
+				Severity: models.SeverityWarning,
+				Message:  fmt.Sprintf("Node %s has %d of %d %s allocatable, some devices are unhealthy", node.Name, allocatable.Value(), capacity.Value(), resource),
+			})
+		}
+	}
+	return findings
+}
+
+// checkDevicePlugins checks that every device plugin DaemonSet is ready on all of its nodes
+func checkDevicePlugins(clientset *kubernetes.Clientset) []models.Finding {
+	findings := []models.Finding{}
+	daemonsets, err := clientset.AppsV1().DaemonSets("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return findings
+	}
+	for _, ds := range daemonsets.Items {
+		if !strings.Contains(ds.Name, "device-plugin") {
+			continue
+		}
+		if ds.Status.NumberReady < ds.Status.DesiredNumberScheduled {
+			findings = append(findings, models.Finding{
+				Resource: models.ResourceRef{Kind: "DaemonSet", Namespace: ds.Namespace, Name: ds.Name},
+				Severity: models.SeverityCritical,
+				Message:  fmt.Sprintf("Device plugin %s/%s is ready on %d of %d nodes", ds.Namespace, ds.Name, ds.Status.NumberReady, ds.Status.DesiredNumberScheduled),
+			})
+		}
+	}
+	return findings
+}
+
+// checkGPUPendingPods finds pods that can not be scheduled because all devices are in use
+func checkGPUPendingPods(clientset *kubernetes.Clientset, resources []string) []models.Finding {
+	findings := []models.Finding{}
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{
+		FieldSelector: "status.phase=Pending",
+	})
+	if err != nil {
+		return findings
+	}
+	for _, pod := range pods.Items {
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type != v1.PodScheduled || condition.Status != v1.ConditionFalse {
+				continue
+			}
+			for _, resource := range resources {
+				if strings.Contains(condition.Message, "Insufficient "+resource) {
+					findings = append(findings, models.Finding{
+						Resource: models.ResourceRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name},
+						Reason:   resource,
+						Severity: models.SeverityWarning,
+						Message:  fmt.Sprintf("Pod %s/%s is pending, no %s available: %s", pod.Namespace, pod.Name, resource, condition.Message),
+					})
+				}
+			}
+		}
+	}
+	return findings
+}
@@ -9,14 +9,17 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
+// infraChecks are the checks of the infra suite
+var infraChecks = []Check{
+	single("OPA", CheckOPA),
+	single("MetalLB", CheckMetallb),
+	single("KubeAddons", CheckKubeAddons),
+	single("FedRbac", CheckFedRbac),
+	single("FedCRD", CheckFedCRD),
+}
+
 func CheckINFRA(clientset *kubernetes.Clientset) []models.ResourceCheck {
-	checks := []models.ResourceCheck{
-		CheckOPA(clientset),
-		CheckMetallb(clientset),
-		CheckKubeAddons(clientset),
-		CheckFedRbac(clientset),
-		CheckFedCRD(clientset),
-	}
+	checks := RunChecks(clientset, infraChecks)
 	return checks
 }
 
@@ -26,24 +29,24 @@ func CheckOPA(clientset *kubernetes.Clientset) models.ResourceCheck {
 	// Check if OPA pod is running in fed-opa namespace
 	pods, err := clientset.CoreV1().Pods("fed-opa").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"OPA", "Error fetching pods", false}
+		return models.ResourceCheck{Label: "OPA", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	if len(pods.Items) == 0 {
-		return models.ResourceCheck{"OPA", "No OPA pods found", false}
+		return models.ResourceCheck{Label: "OPA", Details: "No OPA pods found", Status: false}
 	}
 
 	// Check if OPA service is up
 	services, err := clientset.CoreV1().Services("fed-opa").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"OPA", "Error fetching services", false}
+		return models.ResourceCheck{Label: "OPA", Details: "Error fetching services", Error: err.Error()}
 	}
 
 	if len(services.Items) == 0 {
-		return models.ResourceCheck{"OPA", "No OPA services found", false}
+		return models.ResourceCheck{Label: "OPA", Details: "No OPA services found", Status: false}
 	}
 
-	return models.ResourceCheck{"OPA", "OPA is Up", true}
+	return models.ResourceCheck{Label: "OPA", Details: "OPA is Up", Status: true}
 }
 
 func CheckMetallb(clientset *kubernetes.Clientset) models.ResourceCheck {
@@ -51,24 +54,24 @@ func CheckMetallb(clientset *kubernetes.Clientset) models.ResourceCheck {
 	// Check if MetalLB pod is running in fed-metallb-system namespace
 	pods, err := clientset.CoreV1().Pods("fed-metallb-system").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"MetalLB", "Error fetching pods", false}
+		return models.ResourceCheck{Label: "MetalLB", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	if len(pods.Items) == 0 {
-		return models.ResourceCheck{"metallb", "MetalLB is down", false}
+		return models.ResourceCheck{Label: "metallb", Details: "MetalLB is down", Status: false}
 	}
 
 	// Check if MetalLB service is up
 	services, err := clientset.CoreV1().Services("fed-metallb").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"MetalLB", "Error fetching services", false}
+		return models.ResourceCheck{Label: "MetalLB", Details: "Error fetching services", Error: err.Error()}
 	}
 
 	if len(services.Items) == 0 {
-		return models.ResourceCheck{"MetalLB", "No MetalLB services found", false}
+		return models.ResourceCheck{Label: "MetalLB", Details: "No MetalLB services found", Status: false}
 	}
 
-	return models.ResourceCheck{"MetalLB", "MetalLB is Up", true}
+	return models.ResourceCheck{Label: "MetalLB", Details: "MetalLB is Up", Status: true}
 }
 
 func CheckKubeAddons(clientset *kubernetes.Clientset) models.ResourceCheck {
@@ -76,24 +79,24 @@ func CheckKubeAddons(clientset *kubernetes.Clientset) models.ResourceCheck {
 	// Check if kube-addons pod is running in fed-kube-addons namespace
 	pods, err := clientset.CoreV1().Pods("fed-kube-addons").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"KubeAddons", "Error fetching pods", false}
+		return models.ResourceCheck{Label: "KubeAddons", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	if len(pods.Items) == 0 {
-		return models.ResourceCheck{"KubeAddons", "No KubeAddons pods found", false}
+		return models.ResourceCheck{Label: "KubeAddons", Details: "No KubeAddons pods found", Status: false}
 	}
 
 	// Check if kube-addons service is up
 	services, err := clientset.CoreV1().Services("fed-kube-addons").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"KubeAddons", "Error fetching services", false}
+		return models.ResourceCheck{Label: "KubeAddons", Details: "Error fetching services", Error: err.Error()}
 	}
 
 	if len(services.Items) == 0 {
-		return models.ResourceCheck{"KubeAddons", "No KubeAddons services found", false}
+		return models.ResourceCheck{Label: "KubeAddons", Details: "No KubeAddons services found", Status: false}
 	}
 
-	return models.ResourceCheck{"KubeAddons", "KubeAddons is Up", true}
+	return models.ResourceCheck{Label: "KubeAddons", Details: "KubeAddons is Up", Status: true}
 }
 
 func CheckFedRbac(clientset *kubernetes.Clientset) models.ResourceCheck {
@@ -101,17 +104,17 @@ func CheckFedRbac(clientset *kubernetes.Clientset) models.ResourceCheck {
 	// Check if fed-rbac pod is running in fed-rbac namespace
 	pods, err := clientset.CoreV1().Pods("fed-rbac").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"FedRbac", "Error fetching pods", false}
+		return models.ResourceCheck{Label: "FedRbac", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	if len(pods.Items) == 0 {
-		return models.ResourceCheck{"FedRbac", "No Rbac pods found", false}
+		return models.ResourceCheck{Label: "FedRbac", Details: "No Rbac pods found", Status: false}
 	}
 
-	return models.ResourceCheck{"FedRbac", "FedRbac is Up", true}
+	return models.ResourceCheck{Label: "FedRbac", Details: "FedRbac is Up", Status: true}
 }
 
 func CheckFedCRD(clientset *kubernetes.Clientset) models.ResourceCheck {
 
-	return models.ResourceCheck{"FedCRD", "FedCRD is Up", true}
+	return models.ResourceCheck{Label: "FedCRD", Details: "FedCRD is Up", Status: true}
 }
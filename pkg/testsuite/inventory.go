@@ -0,0 +1,134 @@
+package testsuite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+)
+
+// checkExpectedInventory asserts the objects declared in checks.inventory exist, are not being deleted and
+// workloads have their minimum replicas ready. It catches components deleted by accident, which no other
+// check notices since nothing is left to check.
+func checkExpectedInventory(clientset *kubernetes.Clientset) models.ResourceCheck {
+	if len(settings.Inventory) == 0 {
+		return models.ResourceCheck{Label: "Expected Inventory", Details: "No expected objects configured in checks.inventory.", Status: true, Skipped: "not configured"}
+	}
+	groupResources, err := k8s.GetAPIGroupResources(clientset)
+	if err != nil {
+		return models.ResourceCheck{Label: "Expected Inventory", Details: "Error discovering the API resources", Error: err.Error()}
+	}
+	mapper := restmapper.NewDiscoveryRESTMapper(groupResources)
+
+	findings := []models.Finding{}
+	expected := 0
+	for _, namespace := range settings.Inventory {
+		expected += len(namespace.Objects)
+		_, err := clientset.CoreV1().Namespaces().Get(context.Background(), namespace.Namespace, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			findings = append(findings, models.Finding{
+				Resource: models.ResourceRef{Kind: "Namespace", Name: namespace.Namespace},
+				Reason:   "Missing",
+				Severity: models.SeverityCritical,
+				Message:  fmt.Sprintf("namespace %s with %d expected objects does not exist", namespace.Namespace, len(namespace.Objects)),
+			})
+			continue
+		}
+		if err != nil {
+			return models.ResourceCheck{Label: "Expected Inventory", Details: "Error fetching namespace " + namespace.Namespace, Error: err.Error()}
+		}
+		for _, object := range namespace.Objects {
+			ref := models.ResourceRef{Kind: object.Kind, Namespace: namespace.Namespace, Name: object.Name}
+			mapping, err := inventoryMapping(mapper, groupResources, object.Kind)
+			if err != nil {
+				findings = append(findings, models.Finding{Resource: ref, Reason: "UnknownKind", Severity: models.SeverityWarning,
+					Message: fmt.Sprintf("kind %s of the expected %s is not served by the cluster", object.Kind, object.Name)})
+				continue
+			}
+			ref.Kind = mapping.GroupVersionKind.Kind
+			data, err := clientset.Discovery().RESTClient().Get().AbsPath(objectPath(mapping, namespace.Namespace, object.Name)).DoRaw(context.Background())
+			if apierrors.IsNotFound(err) {
+				findings = append(findings, models.Finding{Resource: ref, Reason: "Missing", Severity: models.SeverityCritical,
+					Message: fmt.Sprintf("expected %s %s does not exist in namespace %s", ref.Kind, object.Name, namespace.Namespace)})
+				continue
+			}
+			live := unstructured.Unstructured{}
+			if err == nil {
+				err = json.Unmarshal(data, &live.Object)
+			}
+			if err != nil {
+				findings = append(findings, models.Finding{Resource: ref, Reason: "ReadFailed", Severity: models.SeverityWarning,
+					Message: fmt.Sprintf("reading the expected %s %s failed: %v", ref.Kind, object.Name, err)})
+				continue
+			}
+			if deleted := live.GetDeletionTimestamp(); deleted != nil {
+				findings = append(findings, models.Finding{Resource: ref, Reason: "Terminating", Severity: models.SeverityCritical,
+					Message: fmt.Sprintf("expected %s %s is being deleted since %s", ref.Kind, object.Name, deleted.Format(time.RFC3339))})
+				continue
+			}
+			if object.MinReplicas > 0 {
+				if finding, short := replicasBelow(live, ref, object.MinReplicas); short {
+					findings = append(findings, finding)
+				}
+			}
+		}
+	}
+
+	details := fmt.Sprintf("%d expected objects exist in %d namespaces.", expected, len(settings.Inventory))
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d of %d expected objects are missing or degraded.", len(findings), expected)
+	}
+	return models.ResourceCheck{Label: "Expected Inventory", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// inventoryMapping resolves a kind like Deployment or kind.group like Certificate.cert-manager.io. Kinds
+// without group are looked up in the core group first, then in the other groups in discovery order.
+func inventoryMapping(mapper meta.RESTMapper, groupResources []*restmapper.APIGroupResources, kind string) (*meta.RESTMapping, error) {
+	groupKind := schema.ParseGroupKind(kind)
+	if groupKind.Group != "" {
+		return mapper.RESTMapping(groupKind)
+	}
+	for _, group := range groupResources {
+		for _, resource := range group.VersionedResources[group.Group.PreferredVersion.Version] {
+			if !strings.Contains(resource.Name, "/") && strings.EqualFold(resource.Kind, kind) {
+				return mapper.RESTMapping(schema.GroupKind{Group: group.Group.Name, Kind: resource.Kind})
+			}
+		}
+	}
+	return nil, fmt.Errorf("kind %s is not served", kind)
+}
+
+// replicasBelow compares the replicas of a workload with the minimum, the desired and ready pods of a
+// DaemonSet count as its replicas
+func replicasBelow(live unstructured.Unstructured, ref models.ResourceRef, minimum int) (models.Finding, bool) {
+	replicas, found, _ := unstructured.NestedInt64(live.Object, "spec", "replicas")
+	ready, _, _ := unstructured.NestedInt64(live.Object, "status", "readyReplicas")
+	if !found {
+		replicas, found, _ = unstructured.NestedInt64(live.Object, "status", "desiredNumberScheduled")
+		ready, _, _ = unstructured.NestedInt64(live.Object, "status", "numberReady")
+	}
+	switch {
+	case !found:
+		return models.Finding{Resource: ref, Reason: "NoReplicas", Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("%s %s has no replicas to compare with the expected %d", ref.Kind, ref.Name, minimum)}, true
+	case replicas < int64(minimum):
+		return models.Finding{Resource: ref, Reason: "TooFewReplicas", Severity: models.SeverityCritical,
+			Message: fmt.Sprintf("%s %s has %d replicas, at least %d are expected", ref.Kind, ref.Name, replicas, minimum)}, true
+	case ready < int64(minimum):
+		return models.Finding{Resource: ref, Reason: "TooFewReady", Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("%s %s has %d of %d replicas ready, at least %d are expected", ref.Kind, ref.Name, ready, replicas, minimum)}, true
+	}
+	return models.Finding{}, false
+}
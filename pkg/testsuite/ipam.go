@@ -0,0 +1,210 @@
+package testsuite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// IP utilization in percent that is reported
+const (
+	ipWarning  = 80
+	ipCritical = 95
+)
+
+// ipRange counts the used addresses of a CIDR
+type ipRange struct {
+	network *net.IPNet
+	used    int64
+}
+
+// usable returns the number of addresses in the range without the network and broadcast address
+func (r ipRange) usable() int64 {
+	ones, bits := r.network.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	if !size.IsInt64() {
+		// IPv6 ranges larger than an int64 do not run out
+		return 1 << 62
+	}
+	return max(size.Int64()-2, 1)
+}
+
+func (r ipRange) percent() int64 {
+	return r.used * 100 / r.usable()
+}
+
+func ipSeverity(percent int64) (models.Severity, bool) {
+	switch {
+	case percent >= ipCritical:
+		return models.SeverityCritical, true
+	case percent >= ipWarning:
+		return models.SeverityWarning, true
+	}
+	return "", false
+}
+
+// checkIPAddresses computes the pod IP utilization of every node CIDR and the utilization of the service
+// IP range, running out of either only shows up as pods stuck in ContainerCreating or failing service creation
+func checkIPAddresses(clientset *kubernetes.Clientset) models.ResourceCheck {
+	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "IP Addresses", Details: "Error fetching nodes", Error: err.Error()}
+	}
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "IP Addresses", Details: "Error fetching pods", Error: err.Error()}
+	}
+
+	podsOnNode := make(map[string]int64)
+	for _, pod := range pods.Items {
+		if pod.Spec.HostNetwork || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		podsOnNode[pod.Spec.NodeName]++
+	}
+
+	findings := []models.Finding{}
+	withCIDR := 0
+	var used, usable int64
+	for _, node := range nodes.Items {
+		ref := models.ResourceRef{Kind: "Node", Name: node.Name, Node: node.Name}
+		cidrs := node.Spec.PodCIDRs
+		if len(cidrs) == 0 && node.Spec.PodCIDR != "" {
+			cidrs = []string{node.Spec.PodCIDR}
+		}
+		for _, cidr := range cidrs {
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			withCIDR++
+			r := ipRange{network: network, used: podsOnNode[node.Name]}
+			used += r.used
+			usable += r.usable()
+			if severity, found := ipSeverity(r.percent()); found {
+				findings = append(findings, models.Finding{Resource: ref, Reason: "PodCIDR " + cidr, Severity: severity,
+					Message: fmt.Sprintf("Pod CIDR %s of node %s is %d%% used (%d of %d addresses), new pods on the node can not get an IP", cidr, node.Name, r.percent(), r.used, r.usable())})
+			}
+		}
+	}
+
+	details := fmt.Sprintf("%d of %d pod IPs used on %d node CIDRs.", used, usable, withCIDR)
+	if withCIDR == 0 {
+		details = "Nodes have no pod CIDR, the CNI assigns pod IPs from its own pool."
+	}
+
+	ranges, source := serviceRanges(clientset)
+	if len(ranges) > 0 {
+		countServiceIPs(clientset, ranges)
+		for _, r := range ranges {
+			details += fmt.Sprintf(" Service range %s %d%% used.", r.network, r.percent())
+			if severity, found := ipSeverity(r.percent()); found {
+				findings = append(findings, models.Finding{Resource: models.ResourceRef{Kind: "Cluster", Name: "service-cidr"}, Reason: r.network.String(), Severity: severity,
+					Message: fmt.Sprintf("Service IP range %s (%s) is %d%% used (%d of %d addresses), new services can not get a ClusterIP", r.network, source, r.percent(), r.used, r.usable())})
+			}
+		}
+	} else {
+		details += " Service IP range is unknown, set checks.network.serviceCIDR."
+	}
+	sort.Slice(findings, func(i, j int) bool { return findings[i].Resource.String() < findings[j].Resource.String() })
+
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d node or service IP ranges are close to exhaustion. %s", len(findings), details)
+	}
+	return models.ResourceCheck{Label: "IP Addresses", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// serviceRanges returns the service IP ranges from the config file, the ServiceCIDR API
+// or the --service-cluster-ip-range flag of the kube-apiserver pods, and where they came from
+func serviceRanges(clientset *kubernetes.Clientset) ([]*ipRange, string) {
+	if settings.Network.ServiceCIDR != "" {
+		return parseRanges(strings.Split(settings.Network.ServiceCIDR, ",")), "config"
+	}
+	if found := serviceCIDRs(clientset); len(found) > 0 {
+		return parseRanges(found), "ServiceCIDR"
+	}
+	pods, err := clientset.CoreV1().Pods("kube-system").List(context.Background(), metav1.ListOptions{LabelSelector: "component=kube-apiserver"})
+	if err != nil {
+		return nil, ""
+	}
+	for _, pod := range pods.Items {
+		for _, container := range pod.Spec.Containers {
+			for _, arg := range append(container.Command, container.Args...) {
+				if value, found := strings.CutPrefix(arg, "--service-cluster-ip-range="); found {
+					return parseRanges(strings.Split(value, ",")), "kube-apiserver"
+				}
+			}
+		}
+	}
+	return nil, ""
+}
+
+// serviceCIDRs returns the ranges of the ServiceCIDR objects, from the GA API or the v1beta1 API
+// of clusters before 1.33. The client has no typed GA client, both versions have the same schema.
+func serviceCIDRs(clientset *kubernetes.Clientset) []string {
+	discovery, err := k8s.GetAPIResources(clientset)
+	if err != nil {
+		return nil
+	}
+	resource, served := discovery.ForKind("ServiceCIDR")
+	if !served {
+		return nil
+	}
+	cidrs := &networkingv1beta1.ServiceCIDRList{}
+	switch resource.GroupVersion {
+	case "networking.k8s.io/v1":
+		data, err := clientset.Discovery().RESTClient().Get().AbsPath("/apis/networking.k8s.io/v1/servicecidrs").DoRaw(context.Background())
+		if err != nil || json.Unmarshal(data, cidrs) != nil {
+			return nil
+		}
+	case "networking.k8s.io/v1beta1":
+		if cidrs, err = clientset.NetworkingV1beta1().ServiceCIDRs().List(context.Background(), metav1.ListOptions{}); err != nil {
+			return nil
+		}
+	}
+	found := []string{}
+	for _, cidr := range cidrs.Items {
+		found = append(found, cidr.Spec.CIDRs...)
+	}
+	return found
+}
+
+func parseRanges(cidrs []string) []*ipRange {
+	ranges := []*ipRange{}
+	for _, cidr := range cidrs {
+		if _, network, err := net.ParseCIDR(strings.TrimSpace(cidr)); err == nil {
+			ranges = append(ranges, &ipRange{network: network})
+		}
+	}
+	return ranges
+}
+
+// countServiceIPs counts the ClusterIPs of all services in the range they belong to
+func countServiceIPs(clientset *kubernetes.Clientset, ranges []*ipRange) {
+	services, err := clientset.CoreV1().Services("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return
+	}
+	for _, service := range services.Items {
+		for _, ip := range service.Spec.ClusterIPs {
+			parsed := net.ParseIP(ip)
+			for _, r := range ranges {
+				if parsed != nil && r.network.Contains(parsed) {
+					r.used++
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultKafkaNamespace       = "fed-kafka"
+	defaultKafkaPodSelector     = "app.kubernetes.io/name=kafka"
+	defaultKafkaBootstrapServer = "localhost:9092"
+)
+
+func CheckKafkaLag(clientset *kubernetes.Clientset) models.ResourceCheck {
+	if mode := probesDisabled(); mode != "" {
+		return skippedInMode("Kafka Lag", mode)
+	}
+	cfg := settings.Kafka
+	if len(cfg.ConsumerGroups) == 0 {
+		return models.ResourceCheck{Label: "Kafka Lag", Details: "No consumer group lag thresholds configured.", Status: true}
+	}
+	namespace := valueOr(cfg.Namespace, defaultKafkaNamespace)
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: valueOr(cfg.PodSelector, defaultKafkaPodSelector),
+		FieldSelector: "status.phase=Running",
+	})
+	if err != nil {
+		return models.ResourceCheck{Label: "Kafka Lag", Details: "Error fetching kafka pods", Error: err.Error()}
+	}
+	if len(pods.Items) == 0 {
+		return models.ResourceCheck{Label: "Kafka Lag", Details: "No running kafka broker pods found", Status: false}
+	}
+	broker := pods.Items[0]
+	container := cfg.Container
+	if container == "" {
+		container = broker.Spec.Containers[0].Name
+	}
+
+	kc := &k8s.K8sClient{Client: clientset}
+	bootstrapServer := valueOr(cfg.BootstrapServer, defaultKafkaBootstrapServer)
+	findings := []models.Finding{}
+	for _, group := range cfg.ConsumerGroups {
+		ref := models.ResourceRef{Kind: "ConsumerGroup", Namespace: namespace, Name: group.Group}
+		lags, err := kc.GetConsumerGroupLag(namespace, broker.Name, container, bootstrapServer, group.Group)
+		if err != nil {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Describe", Severity: models.SeverityWarning, Message: err.Error()})
+			continue
+		}
+		if len(lags) == 0 {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Offsets", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("consumer group %s has no committed offsets", group.Group)})
+			continue
+		}
+		for _, lag := range lags {
+			if lag.Lag <= group.MaxLag {
+				continue
+			}
+			findings = append(findings, models.Finding{
+				Resource: ref,
+				Reason:   fmt.Sprintf("%s/%d", lag.Topic, lag.Partition),
+				Severity: models.SeverityCritical,
+				Message: fmt.Sprintf("%s lags %d messages behind on %s partition %d (offset %d of %d), threshold is %d",
+					groupName(group.Group, group.Application), lag.Lag, lag.Topic, lag.Partition, lag.CurrentOffset, lag.LogEndOffset, group.MaxLag),
+			})
+		}
+	}
+
+	details := fmt.Sprintf("%d consumer groups within their lag threshold.", len(cfg.ConsumerGroups))
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d partitions over their lag threshold or not readable.", len(findings))
+	}
+	return models.ResourceCheck{Label: "Kafka Lag", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+func groupName(group, application string) string {
+	if application == "" {
+		return "consumer group " + group
+	}
+	return fmt.Sprintf("%s (consumer group %s)", application, group)
+}
+
+// valueOr returns value, or fallback when value is empty
+func valueOr[T comparable](value, fallback T) T {
+	var zero T
+	if value == zero {
+		return fallback
+	}
+	return value
+}
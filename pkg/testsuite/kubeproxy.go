@@ -0,0 +1,237 @@
+package testsuite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultRouteSamples = 5
+	// staleProxySync is how long ago kube-proxy may have last synced its iptables or ipvs rules
+	staleProxySync = 2 * time.Minute
+)
+
+// dataplane is a DaemonSet that programs service routing on every node
+type dataplane struct {
+	Name     string
+	Selector string
+	// HealthPort serves /healthz on the node, kube-proxy reports the time of the last rule sync there
+	HealthPort string
+}
+
+var dataplanes = []dataplane{
+	{Name: "kube-proxy", Selector: "k8s-app=kube-proxy", HealthPort: "10256"},
+	// the health endpoint of cilium listens on localhost only, its readiness probe calls it for us
+	{Name: "cilium", Selector: "k8s-app=cilium"},
+	{Name: "kube-router", Selector: "k8s-app=kube-router", HealthPort: "20244"},
+	{Name: "antrea-agent", Selector: "component=antrea-agent"},
+}
+
+// proxyHealth is the /healthz response of kube-proxy
+type proxyHealth struct {
+	LastUpdated time.Time `json:"lastUpdated"`
+	CurrentTime time.Time `json:"currentTime"`
+}
+
+// checkServiceRouting verifies the dataplane pods on every node, that kube-proxy keeps its rules in sync
+// and that a sample of ClusterIP services can be reached from pods on several nodes
+func checkServiceRouting(clientset *kubernetes.Clientset) models.ResourceCheck {
+	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Service Routing", Details: "Error fetching nodes", Error: err.Error()}
+	}
+
+	findings := []models.Finding{}
+	found := []string{}
+	for _, plane := range dataplanes {
+		pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{LabelSelector: plane.Selector})
+		if err != nil || len(pods.Items) == 0 {
+			continue
+		}
+		found = append(found, plane.Name)
+		findings = append(findings, dataplaneFindings(clientset, plane, nodes.Items, pods.Items)...)
+	}
+	if len(found) == 0 {
+		findings = append(findings, models.Finding{Resource: models.ResourceRef{Kind: "Cluster", Name: "dataplane"}, Reason: "Missing", Severity: models.SeverityWarning,
+			Message: "No kube-proxy, cilium, kube-router or antrea pods found, service routing can not be verified"})
+	}
+
+	details := fmt.Sprintf("Dataplane %s is healthy on %d nodes.", strings.Join(found, ", "), len(nodes.Items))
+	if mode := probesDisabled(); mode != "" {
+		details += " Route probes are skipped in " + mode + "."
+	} else {
+		routes, sampled := routeFindings(clientset)
+		findings = append(findings, routes...)
+		details = fmt.Sprintf("Dataplane %s is healthy on %d nodes, %d services route from every probed node.", strings.Join(found, ", "), len(nodes.Items), sampled)
+	}
+	if len(findings) > 0 {
+		details = fmt.Sprintf("Dataplane %s, %d service routing problems found.", strings.Join(found, ", "), len(findings))
+	}
+	return models.ResourceCheck{Label: "Service Routing", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// dataplaneFindings reports nodes without a ready dataplane pod and pods that report unhealthy or stale rules
+func dataplaneFindings(clientset *kubernetes.Clientset, plane dataplane, nodes []v1.Node, pods []v1.Pod) []models.Finding {
+	findings := []models.Finding{}
+	podOnNode := make(map[string]v1.Pod)
+	for _, pod := range pods {
+		podOnNode[pod.Spec.NodeName] = pod
+	}
+	for _, node := range nodes {
+		ref := models.ResourceRef{Kind: "Node", Name: node.Name, Node: node.Name}
+		pod, found := podOnNode[node.Name]
+		if !found {
+			if nodeReady(node) && node.Labels["kubernetes.io/os"] != "windows" {
+				findings = append(findings, models.Finding{Resource: ref, Reason: plane.Name, Severity: models.SeverityCritical,
+					Message: fmt.Sprintf("No %s pod runs on node %s, services do not route from its pods", plane.Name, node.Name)})
+			}
+			continue
+		}
+		if k8s.PodIssueReason(pod) != "" {
+			findings = append(findings, models.Finding{Resource: ref, Reason: plane.Name, Severity: models.SeverityCritical,
+				Message: fmt.Sprintf("%s pod %s on node %s is %s", plane.Name, pod.Name, node.Name, k8s.PodIssueReason(pod))})
+			continue
+		}
+		if plane.HealthPort == "" {
+			continue
+		}
+		data, err := clientset.CoreV1().Pods(pod.Namespace).ProxyGet("http", pod.Name, plane.HealthPort, "/healthz", nil).DoRaw(context.Background())
+		if err != nil {
+			findings = append(findings, models.Finding{Resource: ref, Reason: plane.Name, Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("%s health endpoint on node %s failed: %v", plane.Name, node.Name, err)})
+			continue
+		}
+		health := proxyHealth{}
+		if json.Unmarshal(data, &health) != nil || health.LastUpdated.IsZero() {
+			continue
+		}
+		if age := health.CurrentTime.Sub(health.LastUpdated); age > staleProxySync {
+			findings = append(findings, models.Finding{Resource: ref, Reason: plane.Name, Severity: models.SeverityCritical,
+				Message: fmt.Sprintf("%s on node %s last synced its rules %s ago, new services and endpoints do not route", plane.Name, node.Name, age.Round(time.Second))})
+		}
+	}
+	return findings
+}
+
+// routeTarget is a ClusterIP service port with ready endpoints
+type routeTarget struct {
+	Service models.ResourceRef
+	Address string
+}
+
+// sampleRoutes returns the kubernetes service and up to count other ClusterIP services with ready endpoints
+func sampleRoutes(clientset *kubernetes.Clientset, count int) ([]routeTarget, error) {
+	services, err := clientset.CoreV1().Services("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	slices, err := clientset.DiscoveryV1().EndpointSlices("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	ready := make(map[string]bool)
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				ready[slice.Namespace+"/"+slice.Labels[discoveryv1.LabelServiceName]] = true
+			}
+		}
+	}
+
+	sort.Slice(services.Items, func(i, j int) bool {
+		return services.Items[i].Namespace+"/"+services.Items[i].Name < services.Items[j].Namespace+"/"+services.Items[j].Name
+	})
+	targets := []routeTarget{}
+	sampled := 0
+	for _, service := range services.Items {
+		isAPIServer := service.Namespace == "default" && service.Name == "kubernetes"
+		if service.Spec.Type != v1.ServiceTypeClusterIP || service.Spec.ClusterIP == "" || service.Spec.ClusterIP == v1.ClusterIPNone {
+			continue
+		}
+		if !isAPIServer && (sampled >= count || !ready[service.Namespace+"/"+service.Name]) {
+			continue
+		}
+		for _, port := range service.Spec.Ports {
+			if port.Protocol != v1.ProtocolTCP {
+				continue
+			}
+			targets = append(targets, routeTarget{
+				Service: models.ResourceRef{Kind: "Service", Namespace: service.Namespace, Name: service.Name},
+				Address: fmt.Sprintf("%s %d", service.Spec.ClusterIP, port.Port),
+			})
+			if !isAPIServer {
+				sampled++
+			}
+			break
+		}
+	}
+	return targets, nil
+}
+
+// routeProbeScript connects to every target and prints whether it succeeded
+func routeProbeScript(targets []routeTarget) string {
+	script := ""
+	for i, target := range targets {
+		script += fmt.Sprintf("if nc -z -w 3 %s; then echo \"route %d ok\"; else echo \"route %d fail\"; fi\n", target.Address, i, i)
+	}
+	return script
+}
+
+// routeFindings connects to the sampled services from pods on several nodes. A service that fails from
+// every node is likely broken itself, one that fails from some nodes points to the dataplane of those nodes.
+func routeFindings(clientset *kubernetes.Clientset) ([]models.Finding, int) {
+	findings := []models.Finding{}
+	targets, err := sampleRoutes(clientset, valueOr(settings.Network.RouteSamples, defaultRouteSamples))
+	if err != nil || len(targets) == 0 {
+		return findings, 0
+	}
+	nodes, err := sampleNodes(clientset, valueOr(settings.Network.ProbeNodes, defaultProbeNodes))
+	if err != nil {
+		return findings, 0
+	}
+	outputs, errs := runNodeProbes(clientset, nodeProbe{Name: "route-probe", Script: routeProbeScript(targets)}, nodes)
+	for node, err := range errs {
+		findings = append(findings, models.Finding{Resource: models.ResourceRef{Kind: "Node", Name: node, Node: node}, Reason: "RouteProbe", Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("route probe on node %s failed: %v", node, err)})
+	}
+
+	failedFrom := make([][]string, len(targets))
+	for node, output := range outputs {
+		for _, line := range strings.Split(output, "\n") {
+			var index int
+			var result string
+			if _, err := fmt.Sscanf(line, "route %d %s", &index, &result); err != nil || index >= len(targets) {
+				continue
+			}
+			if result != "ok" {
+				failedFrom[index] = append(failedFrom[index], node)
+			}
+		}
+	}
+	for i, failed := range failedFrom {
+		if len(failed) == 0 {
+			continue
+		}
+		sort.Strings(failed)
+		severity := models.SeverityWarning
+		message := fmt.Sprintf("service %s (%s) does not route from nodes %s, check the dataplane on those nodes", targets[i].Service, targets[i].Address, strings.Join(failed, ", "))
+		if len(failed) == len(outputs) {
+			severity = models.SeverityCritical
+			message = fmt.Sprintf("service %s (%s) does not route from any of the %d probed nodes", targets[i].Service, targets[i].Address, len(outputs))
+		}
+		findings = append(findings, models.Finding{Resource: targets[i].Service, Reason: "Routing", Severity: severity, Message: message})
+	}
+	return findings, len(targets)
+}
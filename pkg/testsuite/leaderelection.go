@@ -0,0 +1,130 @@
+package testsuite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// defaultMaxTransitionsPerHour is how often leadership may change hands before it is reported as flapping
+const defaultMaxTransitionsPerHour = 2
+
+// defaultLeases are the control plane leases checked when they exist, managed clusters hide them
+var defaultLeases = []config.LeaseRef{
+	{Namespace: "kube-system", Name: "kube-controller-manager"},
+	{Namespace: "kube-system", Name: "kube-scheduler"},
+	{Namespace: "kube-system", Name: "cloud-controller-manager"},
+}
+
+// leaseObservation is the state of a lease at the previous run, to tell how often the holder changed since
+type leaseObservation struct {
+	Transitions int32     `json:"transitions"`
+	Holder      string    `json:"holder"`
+	Seen        time.Time `json:"seen"`
+}
+
+// checkLeaderElection reports leases of HA controllers that were not renewed within their duration,
+// the leader is dead and no standby took over, and leases whose holder changes too often
+func checkLeaderElection(clientset *kubernetes.Clientset) models.ResourceCheck {
+	file := config.ClusterStatePath(k8s.CurrentCluster(), "leases.json")
+	previous := make(map[string]leaseObservation)
+	if data, err := os.ReadFile(file); err == nil {
+		json.Unmarshal(data, &previous)
+	}
+	maxTransitions := valueOr(settings.LeaderElection.MaxTransitionsPerHour, defaultMaxTransitionsPerHour)
+
+	now := time.Now()
+	findings := []models.Finding{}
+	observed := make(map[string]leaseObservation)
+	checked := 0
+	for _, ref := range append(append([]config.LeaseRef{}, settings.LeaderElection.Leases...), defaultLeases...) {
+		key := ref.Namespace + "/" + ref.Name
+		if _, done := observed[key]; done {
+			continue
+		}
+		resource := models.ResourceRef{Kind: "Lease", Namespace: ref.Namespace, Name: ref.Name}
+		lease, err := clientset.CoordinationV1().Leases(ref.Namespace).Get(context.Background(), ref.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) && !isDefaultLease(ref) {
+			findings = append(findings, models.Finding{Resource: resource, Reason: "Missing", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("Lease %s does not exist, the controller never elected a leader", key)})
+		}
+		if err != nil {
+			continue
+		}
+		checked++
+		observation := leaseObservation{Transitions: leaseTransitions(lease), Holder: holderOf(lease), Seen: now}
+		observed[key] = observation
+
+		if lease.Spec.RenewTime != nil && lease.Spec.LeaseDurationSeconds != nil {
+			duration := time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+			if age := now.Sub(lease.Spec.RenewTime.Time); age > duration {
+				findings = append(findings, models.Finding{Resource: resource, Reason: "Expired", Severity: models.SeverityCritical,
+					Message: fmt.Sprintf("Lease %s held by %s was last renewed %s ago, longer than its duration of %s, the leader is dead and no standby took over",
+						key, observation.Holder, age.Round(time.Second), duration)})
+			}
+		}
+
+		last, found := previous[key]
+		if !found || observation.Transitions <= last.Transitions {
+			continue
+		}
+		hours := now.Sub(last.Seen).Hours()
+		changes := observation.Transitions - last.Transitions
+		if rate := float64(changes) / max(hours, 1); rate > maxTransitions {
+			findings = append(findings, models.Finding{Resource: resource, Reason: "Flapping", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("Leadership of %s changed %d times in the last %s (now %s), the controller keeps losing its lease",
+					key, changes, now.Sub(last.Seen).Round(time.Minute), observation.Holder)})
+		}
+	}
+
+	// keep the observations of leases that were not found this time so a transient error does not reset the rate
+	for key, observation := range previous {
+		if _, found := observed[key]; !found {
+			observed[key] = observation
+		}
+	}
+	if data, err := json.Marshal(observed); err == nil && os.MkdirAll(filepath.Dir(file), 0755) == nil {
+		os.WriteFile(file, data, 0644)
+	}
+
+	details := fmt.Sprintf("%d leader election leases are renewed and stable.", checked)
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d leader election problems found in %d leases.", len(findings), checked)
+	}
+	return models.ResourceCheck{Label: "Leader Election", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+func isDefaultLease(ref config.LeaseRef) bool {
+	for _, lease := range defaultLeases {
+		if lease == ref {
+			return true
+		}
+	}
+	return false
+}
+
+func leaseTransitions(lease *coordinationv1.Lease) int32 {
+	if lease.Spec.LeaseTransitions == nil {
+		return 0
+	}
+	return *lease.Spec.LeaseTransitions
+}
+
+func holderOf(lease *coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" {
+		return "nobody"
+	}
+	return *lease.Spec.HolderIdentity
+}
@@ -0,0 +1,354 @@
+package testsuite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultFluentBitMetricsPort = "2020"
+	defaultLogLatency           = 60 * time.Second
+	logQueryInterval            = 5 * time.Second
+)
+
+// loggingAgents are name fragments of the DaemonSets of common log shippers
+var loggingAgents = []string{"fluent-bit", "fluentbit", "fluentd", "vector", "promtail", "alloy", "filebeat"}
+
+// fluentBitCounters are the output counters of one Fluent Bit output at the previous run
+type fluentBitCounters struct {
+	Errors        int64 `json:"errors"`
+	RetriesFailed int64 `json:"retriesFailed"`
+}
+
+// loggingDaemonSet returns the configured logging DaemonSet or the first one of a known log shipper
+func loggingDaemonSet(clientset *kubernetes.Clientset) (*appsv1.DaemonSet, error) {
+	cfg := settings.Logging
+	if cfg.DaemonSet != "" {
+		return clientset.AppsV1().DaemonSets(cfg.Namespace).Get(context.Background(), cfg.DaemonSet, metav1.GetOptions{})
+	}
+	daemonsets, err := clientset.AppsV1().DaemonSets(cfg.Namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, agent := range loggingAgents {
+		for i, daemonset := range daemonsets.Items {
+			if strings.Contains(daemonset.Name, agent) {
+				return &daemonsets.Items[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// uncoveredNodes returns the ready nodes the logging DaemonSet runs on without a ready pod of it, their logs
+// are lost. Nodes the DaemonSet does not tolerate or select are not expected to run its pod.
+func uncoveredNodes(clientset *kubernetes.Clientset, daemonset *appsv1.DaemonSet, pods []v1.Pod) ([]string, error) {
+	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	covered := make(map[string]bool)
+	for _, pod := range pods {
+		if k8s.PodIssueReason(pod) == "" {
+			covered[pod.Spec.NodeName] = true
+		}
+	}
+	uncovered := []string{}
+	for _, node := range nodes.Items {
+		if nodeReady(node) && !covered[node.Name] && runsOn(daemonset.Spec.Template.Spec, node) {
+			uncovered = append(uncovered, node.Name)
+		}
+	}
+	return uncovered, nil
+}
+
+// nodeSelectorOperators maps the operators of node affinity terms to label selector operators
+var nodeSelectorOperators = map[v1.NodeSelectorOperator]selection.Operator{
+	v1.NodeSelectorOpIn:           selection.In,
+	v1.NodeSelectorOpNotIn:        selection.NotIn,
+	v1.NodeSelectorOpExists:       selection.Exists,
+	v1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+	v1.NodeSelectorOpGt:           selection.GreaterThan,
+	v1.NodeSelectorOpLt:           selection.LessThan,
+}
+
+// runsOn returns true when the pods of the spec are scheduled to the node: it matches their nodeSelector and
+// required node affinity, and they tolerate its NoSchedule and NoExecute taints
+func runsOn(spec v1.PodSpec, node v1.Node) bool {
+	if !labels.SelectorFromSet(spec.NodeSelector).Matches(labels.Set(node.Labels)) {
+		return false
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == v1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for _, toleration := range spec.Tolerations {
+			if toleration.ToleratesTaint(&taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	if spec.Affinity == nil || spec.Affinity.NodeAffinity == nil || spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true
+	}
+	// the terms are ORed, the expressions of a term ANDed
+	for _, term := range spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		if matchesTerm(term, node) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesTerm returns true when the node matches every expression and field of a node selector term
+func matchesTerm(term v1.NodeSelectorTerm, node v1.Node) bool {
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return false
+	}
+	selector := labels.NewSelector()
+	for _, expression := range term.MatchExpressions {
+		requirement, err := labels.NewRequirement(expression.Key, nodeSelectorOperators[expression.Operator], expression.Values)
+		if err != nil {
+			return false
+		}
+		selector = selector.Add(*requirement)
+	}
+	for _, field := range term.MatchFields {
+		// metadata.name is the only supported field
+		if field.Key != "metadata.name" || len(field.Values) != 1 {
+			return false
+		}
+		if (field.Operator == v1.NodeSelectorOpIn) != (field.Values[0] == node.Name) {
+			return false
+		}
+	}
+	return selector.Matches(labels.Set(node.Labels))
+}
+
+// fluentBitFindings reads the output counters of every Fluent Bit pod and reports outputs whose errors or
+// failed retries increased since the previous run, failed retries are dropped log records
+func fluentBitFindings(clientset *kubernetes.Clientset, pods []v1.Pod) []models.Finding {
+	file := config.ClusterStatePath(k8s.CurrentCluster(), "logging.json")
+	previous := make(map[string]fluentBitCounters)
+	if data, err := os.ReadFile(file); err == nil {
+		json.Unmarshal(data, &previous)
+	}
+	current := make(map[string]fluentBitCounters)
+	findings := []models.Finding{}
+	port := valueOr(settings.Logging.MetricsPort, defaultFluentBitMetricsPort)
+	for _, pod := range pods {
+		if pod.Status.Phase != v1.PodRunning {
+			continue
+		}
+		ref := models.ResourceRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+		data, err := clientset.CoreV1().Pods(pod.Namespace).ProxyGet("http", pod.Name, port, "/api/v1/metrics", nil).DoRaw(context.Background())
+		if err != nil {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Metrics", Severity: models.SeverityInfo,
+				Message: fmt.Sprintf("Fluent Bit metrics of %s are not readable on port %s: %v", pod.Name, port, err)})
+			continue
+		}
+		metrics := struct {
+			Output map[string]struct {
+				Errors        int64 `json:"errors"`
+				RetriesFailed int64 `json:"retries_failed"`
+			} `json:"output"`
+		}{}
+		if err := json.Unmarshal(data, &metrics); err != nil {
+			continue
+		}
+		for output, counters := range metrics.Output {
+			key := pod.Name + "/" + output
+			now := fluentBitCounters{Errors: counters.Errors, RetriesFailed: counters.RetriesFailed}
+			current[key] = now
+			before, seen := previous[key]
+			// counters start at zero when the pod restarts
+			if !seen || now.Errors < before.Errors || now.RetriesFailed < before.RetriesFailed {
+				continue
+			}
+			if dropped := now.RetriesFailed - before.RetriesFailed; dropped > 0 {
+				findings = append(findings, models.Finding{Resource: ref, Reason: "Dropped " + output, Severity: models.SeverityCritical,
+					Message: fmt.Sprintf("output %s of %s gave up on %d chunks since the last run, their logs are lost", output, pod.Name, dropped)})
+			} else if errors := now.Errors - before.Errors; errors > 0 {
+				findings = append(findings, models.Finding{Resource: ref, Reason: "Errors " + output, Severity: models.SeverityWarning,
+					Message: fmt.Sprintf("output %s of %s had %d errors since the last run, the backend rejects or does not accept the logs", output, pod.Name, errors)})
+			}
+		}
+	}
+	if data, err := json.Marshal(current); err == nil && os.MkdirAll(filepath.Dir(file), 0755) == nil {
+		os.WriteFile(file, data, 0600)
+	}
+	return findings
+}
+
+// logArrived queries the log backend through the API server service proxy for the canary line
+func logArrived(clientset *kubernetes.Clientset, backend config.LogBackend, namespace, line string, since time.Time) (bool, error) {
+	services := clientset.CoreV1().Services(backend.Namespace)
+	switch backend.Type {
+	case "loki":
+		selector := valueOr(backend.Selector, fmt.Sprintf(`{namespace=%q}`, namespace))
+		data, err := services.ProxyGet("http", backend.Service, valueOr(backend.Port, "3100"), "/loki/api/v1/query_range", map[string]string{
+			"query": fmt.Sprintf("%s |= %q", selector, line),
+			"start": fmt.Sprint(since.Add(-time.Minute).UnixNano()),
+			"limit": "1",
+		}).DoRaw(context.Background())
+		if err != nil {
+			return false, err
+		}
+		response := struct {
+			Data struct {
+				Result []json.RawMessage `json:"result"`
+			} `json:"data"`
+		}{}
+		if err := json.Unmarshal(data, &response); err != nil {
+			return false, err
+		}
+		return len(response.Data.Result) > 0, nil
+	case "elasticsearch":
+		index := valueOr(backend.Index, "*")
+		data, err := services.ProxyGet("http", backend.Service, valueOr(backend.Port, "9200"), "/"+index+"/_search", map[string]string{
+			"q":    fmt.Sprintf("%q", line),
+			"size": "0",
+		}).DoRaw(context.Background())
+		if err != nil {
+			return false, err
+		}
+		response := struct {
+			Hits struct {
+				Total json.RawMessage `json:"total"`
+			} `json:"hits"`
+		}{}
+		if err := json.Unmarshal(data, &response); err != nil {
+			return false, err
+		}
+		// the total is a number before Elasticsearch 7 and an object with a value since
+		total := struct {
+			Value int64 `json:"value"`
+		}{}
+		if err := json.Unmarshal(response.Hits.Total, &total.Value); err != nil {
+			json.Unmarshal(response.Hits.Total, &total)
+		}
+		return total.Value > 0, nil
+	}
+	return false, fmt.Errorf("unknown log backend %q, use loki or elasticsearch", backend.Type)
+}
+
+// canaryLogFindings writes a unique log line from a probe pod and waits until it can be found in the log
+// backend, the whole pipeline from the node agent to the backend index is tested
+func canaryLogFindings(clientset *kubernetes.Clientset) ([]models.Finding, time.Duration, error) {
+	backend := settings.Logging.Backend
+	nodes, err := sampleNodes(clientset, 1)
+	if err != nil || len(nodes) == 0 {
+		return nil, 0, fmt.Errorf("no ready node for the canary log line: %v", err)
+	}
+	line := fmt.Sprintf("healthctl-log-canary-%d", time.Now().UnixNano())
+	started := time.Now()
+	// the pod lives on after the line so the agent can read its log file before it is removed
+	if _, err := runNodeProbe(clientset, nodeProbe{Name: "log-canary", Script: fmt.Sprintf("echo %s; sleep 15", line)}, nodes[0]); err != nil {
+		return nil, 0, err
+	}
+
+	maxLatency := defaultLogLatency
+	if parsed, err := time.ParseDuration(settings.Logging.MaxLatency); err == nil {
+		maxLatency = parsed
+	}
+	namespace := valueOr(settings.Network.ProbeNamespace, defaultProbeNamespace)
+	ref := models.ResourceRef{Kind: "Service", Namespace: backend.Namespace, Name: backend.Service}
+	for {
+		found, err := logArrived(clientset, backend, namespace, line, started)
+		if err != nil {
+			return nil, 0, err
+		}
+		latency := time.Since(started)
+		if found {
+			return nil, latency, nil
+		}
+		if latency > maxLatency {
+			return []models.Finding{{Resource: ref, Reason: "Canary", Severity: models.SeverityCritical,
+				Message: fmt.Sprintf("the canary log line %s written on node %s did not arrive in %s within %s", line, nodes[0], backend.Type, maxLatency)}}, latency, nil
+		}
+		time.Sleep(logQueryInterval)
+	}
+}
+
+// checkLoggingPipeline verifies the logging DaemonSet runs on every node, that the Fluent Bit outputs do
+// not accumulate errors and, with a backend configured, that a canary log line arrives in Loki or Elasticsearch
+func checkLoggingPipeline(clientset *kubernetes.Clientset) models.ResourceCheck {
+	daemonset, err := loggingDaemonSet(clientset)
+	if err != nil {
+		return models.ResourceCheck{Label: "Logging Pipeline", Details: "Error fetching the logging DaemonSet", Error: err.Error()}
+	}
+	if daemonset == nil {
+		return models.ResourceCheck{Label: "Logging Pipeline", Details: "No logging DaemonSet found.", Status: true, Skipped: "no logging agent"}
+	}
+	selector, err := metav1.LabelSelectorAsSelector(daemonset.Spec.Selector)
+	if err != nil {
+		return models.ResourceCheck{Label: "Logging Pipeline", Details: "Invalid selector of the logging DaemonSet", Error: err.Error()}
+	}
+	pods, err := clientset.CoreV1().Pods(daemonset.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return models.ResourceCheck{Label: "Logging Pipeline", Details: "Error fetching logging pods", Error: err.Error()}
+	}
+
+	ref := models.ResourceRef{Kind: "DaemonSet", Namespace: daemonset.Namespace, Name: daemonset.Name}
+	findings := []models.Finding{}
+	uncovered, err := uncoveredNodes(clientset, daemonset, pods.Items)
+	if err != nil {
+		return models.ResourceCheck{Label: "Logging Pipeline", Details: "Error fetching nodes", Error: err.Error()}
+	}
+	if len(uncovered) > 0 {
+		sort.Strings(uncovered)
+		findings = append(findings, models.Finding{Resource: ref, Reason: "Nodes", Severity: models.SeverityCritical,
+			Message: fmt.Sprintf("%d ready nodes have no ready %s pod, their logs are not shipped: %s", len(uncovered), daemonset.Name, strings.Join(uncovered, ", "))})
+	}
+	if strings.Contains(daemonset.Name, "fluent-bit") || strings.Contains(daemonset.Name, "fluentbit") || settings.Logging.MetricsPort != "" {
+		findings = append(findings, fluentBitFindings(clientset, pods.Items)...)
+	}
+
+	canary := ""
+	if settings.Logging.Backend.Type != "" && probesDisabled() == "" {
+		canaryFindings, latency, err := canaryLogFindings(clientset)
+		switch {
+		case err != nil:
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Canary", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("the canary log line could not be checked: %v", err)})
+		case len(canaryFindings) > 0:
+			findings = append(findings, canaryFindings...)
+		default:
+			canary = fmt.Sprintf(", canary log line arrived in %s after %s", settings.Logging.Backend.Type, latency.Round(time.Second))
+		}
+	}
+
+	failing := 0
+	for _, finding := range findings {
+		if finding.Failing() {
+			failing++
+		}
+	}
+	details := fmt.Sprintf("%s ships logs from %d nodes%s.", daemonset.Name, daemonset.Status.NumberReady, canary)
+	if failing > 0 {
+		details = fmt.Sprintf("%d logging pipeline problems in %s.", failing, daemonset.Name)
+	}
+	return models.ResourceCheck{Label: "Logging Pipeline", Details: details, Status: failing == 0, Findings: findings}
+}
@@ -0,0 +1,88 @@
+package testsuite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+// defaultMetricsMaxAge is how old node metrics may be, metrics-server scrapes every 15 to 60 seconds
+const defaultMetricsMaxAge = 5 * time.Minute
+
+func checkMetricsPipeline(clientset *kubernetes.Clientset) models.ResourceCheck {
+	data, err := clientset.Discovery().RESTClient().Get().AbsPath("/apis/metrics.k8s.io/v1beta1/nodes").DoRaw(context.Background())
+	if err != nil {
+		return models.ResourceCheck{Label: "Metrics Pipeline", Details: "metrics-server is unavailable.", Status: false,
+			Findings: []models.Finding{k8s.MetricsUnavailable(err)}}
+	}
+	nodeMetrics := metricsv1beta1.NodeMetricsList{}
+	if err := json.Unmarshal(data, &nodeMetrics); err != nil {
+		return models.ResourceCheck{Label: "Metrics Pipeline", Details: "Error parsing node metrics", Error: err.Error()}
+	}
+	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Metrics Pipeline", Details: "Error fetching nodes", Error: err.Error()}
+	}
+
+	maxAge := defaultMetricsMaxAge
+	if settings.Metrics.MaxAge != "" {
+		if parsed, err := time.ParseDuration(settings.Metrics.MaxAge); err == nil {
+			maxAge = parsed
+		}
+	}
+
+	findings := []models.Finding{}
+	reported := make(map[string]bool)
+	for _, metrics := range nodeMetrics.Items {
+		reported[metrics.Name] = true
+		if age := time.Since(metrics.Timestamp.Time); age > maxAge {
+			findings = append(findings, models.Finding{
+				Resource: models.ResourceRef{Kind: "Node", Name: metrics.Name},
+				Reason:   "StaleMetrics",
+				Severity: models.SeverityWarning,
+				Message:  fmt.Sprintf("metrics of node %s are %s old, the kubelet is not scraped", metrics.Name, age.Round(time.Second)),
+			})
+		}
+	}
+	missing := []string{}
+	for _, node := range nodes.Items {
+		if !reported[node.Name] && nodeReady(node) {
+			missing = append(missing, node.Name)
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		findings = append(findings, models.Finding{
+			Resource: models.ResourceRef{Kind: "APIService", Name: k8s.MetricsAPIService},
+			Reason:   "MissingNodes",
+			Severity: models.SeverityWarning,
+			Message:  fmt.Sprintf("metrics-server has no metrics for %d ready nodes: %s", len(missing), strings.Join(missing, ", ")),
+		})
+	}
+
+	details := fmt.Sprintf("Metrics of all %d nodes are fresh.", len(nodeMetrics.Items))
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d metrics pipeline problems found.", len(findings))
+	}
+	return models.ResourceCheck{Label: "Metrics Pipeline", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+func nodeReady(node v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultMinioNamespace    = "fed-minio"
+	defaultMinioService      = "minio"
+	defaultMinioPort         = "9000"
+	defaultMinioPodSelector  = "app=minio"
+	defaultMinioCanaryBucket = "healthctl-canary"
+)
+
+func CheckMinio(clientset *kubernetes.Clientset) models.ResourceCheck {
+	if mode := probesDisabled(); mode != "" {
+		return skippedInMode("MinIO", mode)
+	}
+	cfg := settings.Minio
+	namespace := valueOr(cfg.Namespace, defaultMinioNamespace)
+	service := valueOr(cfg.Service, defaultMinioService)
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: valueOr(cfg.PodSelector, defaultMinioPodSelector),
+		FieldSelector: "status.phase=Running",
+	})
+	if err != nil {
+		return models.ResourceCheck{Label: "MinIO", Details: "Error fetching MinIO pods", Error: err.Error()}
+	}
+	if len(pods.Items) == 0 {
+		return models.ResourceCheck{Label: "MinIO", Details: "No running MinIO pods found.", Status: true}
+	}
+	pod := pods.Items[0]
+	container := cfg.Container
+	if container == "" {
+		container = pod.Spec.Containers[0].Name
+	}
+
+	kc := &k8s.K8sClient{Client: clientset}
+	serviceRef := models.ResourceRef{Kind: "Service", Namespace: namespace, Name: service}
+	findings := []models.Finding{}
+	if err := kc.GetMinioClusterHealth(namespace, service, valueOr(cfg.Port, defaultMinioPort)); err != nil {
+		findings = append(findings, models.Finding{Resource: serviceRef, Reason: "ClusterHealth", Severity: models.SeverityCritical,
+			Message: fmt.Sprintf("/minio/health/cluster failed, the cluster can not serve writes: %v", err)})
+	}
+
+	info, err := kc.GetMinioInfo(namespace, pod.Name, container)
+	if err != nil {
+		findings = append(findings, models.Finding{Resource: serviceRef, Reason: "AdminInfo", Severity: models.SeverityWarning, Message: err.Error()})
+	} else {
+		findings = append(findings, minioDriveFindings(namespace, info)...)
+	}
+
+	if !cfg.SkipCanary {
+		if err := kc.MinioCanary(namespace, pod.Name, container, valueOr(cfg.CanaryBucket, defaultMinioCanaryBucket)); err != nil {
+			findings = append(findings, models.Finding{Resource: serviceRef, Reason: "Canary", Severity: models.SeverityCritical, Message: err.Error()})
+		}
+	}
+
+	details := "MinIO cluster is healthy, all drives are online."
+	if info != nil {
+		details = fmt.Sprintf("MinIO cluster is healthy, %d drives online.", info.Info.Backend.OnlineDisks)
+	}
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d MinIO problems found.", len(findings))
+	}
+	return models.ResourceCheck{Label: "MinIO", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// minioDriveFindings reports offline servers, and drives that are offline or healing per erasure set
+func minioDriveFindings(namespace string, info *k8s.MinioInfo) []models.Finding {
+	findings := []models.Finding{}
+	for _, server := range info.Info.Servers {
+		ref := models.ResourceRef{Kind: "MinioServer", Namespace: namespace, Name: server.Endpoint}
+		if server.State != "" && server.State != "online" {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Server", Severity: models.SeverityCritical,
+				Message: fmt.Sprintf("MinIO server %s is %s", server.Endpoint, server.State)})
+			continue
+		}
+		for _, drive := range server.Drives {
+			reason := fmt.Sprintf("pool%d/set%d/%s", drive.Pool, drive.Set, drive.Path)
+			if drive.State != "ok" {
+				findings = append(findings, models.Finding{Resource: ref, Reason: reason, Severity: models.SeverityCritical,
+					Message: fmt.Sprintf("drive %s in pool %d erasure set %d is %s", drive.Path, drive.Pool, drive.Set, drive.State)})
+			} else if drive.Healing {
+				findings = append(findings, models.Finding{Resource: ref, Reason: reason, Severity: models.SeverityWarning,
+					Message: fmt.Sprintf("drive %s in pool %d erasure set %d is healing", drive.Path, drive.Pool, drive.Set)})
+			}
+		}
+	}
+	return findings
+}
@@ -0,0 +1,153 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"healthctl/pkg/models"
+	"healthctl/pkg/registry"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const archLabel = "kubernetes.io/arch"
+
+func checkMultiArch(clientset *kubernetes.Clientset) models.ResourceCheck {
+	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Multi-Arch", Details: "Error fetching nodes", Error: err.Error()}
+	}
+	nodeArchs := make(map[string]int)
+	for _, node := range nodes.Items {
+		arch := node.Labels[archLabel]
+		if arch == "" {
+			arch = node.Status.NodeInfo.Architecture
+		}
+		nodeArchs[arch]++
+	}
+	targets := []string{}
+	for arch := range nodeArchs {
+		targets = append(targets, arch)
+	}
+	for _, arch := range settings.MultiArch.TargetArchitectures {
+		if !slices.Contains(targets, arch) {
+			targets = append(targets, arch)
+		}
+	}
+	sort.Strings(targets)
+	if len(targets) <= 1 {
+		return models.ResourceCheck{Label: "Multi-Arch", Details: fmt.Sprintf("All nodes are %s.", strings.Join(targets, "")), Status: true}
+	}
+
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Multi-Arch", Details: "Error fetching pods", Error: err.Error()}
+	}
+	owners := workloadOwners(clientset)
+	// clients holds a registry client per namespace, service account and pull secrets, images are only read
+	// with the credentials their pod may use
+	clients := make(map[string]*registry.Client)
+
+	findings := []models.Finding{}
+	unreadable := []string{}
+	checked := make(map[models.ResourceRef]bool)
+	for _, pod := range pods.Items {
+		workload, found := podWorkload(pod, owners)
+		if !found {
+			workload = models.ResourceRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+		}
+		// workloads pinned to one architecture are meant to run there
+		if checked[workload] || pod.Spec.NodeSelector[archLabel] != "" {
+			continue
+		}
+		checked[workload] = true
+		client := podRegistryClient(clientset, pod, clients)
+
+		containers := append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+		for _, container := range containers {
+			supported, err := client.Architectures(container.Image)
+			if err != nil {
+				if !slices.Contains(unreadable, container.Image) {
+					unreadable = append(unreadable, container.Image)
+				}
+				continue
+			}
+			missing := []string{}
+			for _, arch := range targets {
+				if !slices.Contains(supported, arch) {
+					missing = append(missing, fmt.Sprintf("%s (%d nodes)", arch, nodeArchs[arch]))
+				}
+			}
+			if len(missing) == 0 {
+				continue
+			}
+			findings = append(findings, models.Finding{
+				Resource: workload,
+				Reason:   container.Name,
+				Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("image %s of container %s is only built for %s and can not run on %s",
+					container.Image, container.Name, strings.Join(supported, ", "), strings.Join(missing, ", ")),
+			})
+		}
+	}
+
+	if len(unreadable) > 0 {
+		sort.Strings(unreadable)
+		findings = append(findings, models.Finding{
+			Resource: models.ResourceRef{Kind: "Check", Name: "Multi-Arch"},
+			Reason:   "Registry",
+			Severity: models.SeverityInfo,
+			Message:  fmt.Sprintf("architectures of %d images could not be read from their registry: %s", len(unreadable), strings.Join(unreadable, ", ")),
+		})
+	}
+
+	failing := 0
+	for _, finding := range findings {
+		if finding.Failing() {
+			failing++
+		}
+	}
+	details := fmt.Sprintf("All images of %d workloads support %s.", len(checked), strings.Join(targets, ", "))
+	if failing > 0 {
+		details = fmt.Sprintf("%d containers can not run on all of %s.", failing, strings.Join(targets, ", "))
+	}
+	return models.ResourceCheck{Label: "Multi-Arch", Details: details, Status: failing == 0, Findings: findings}
+}
+
+// podRegistryClient returns the registry client with the credentials of the image pull secrets of the pod and
+// its service account, clients are shared by the pods of a namespace using the same secrets
+func podRegistryClient(clientset *kubernetes.Clientset, pod v1.Pod, clients map[string]*registry.Client) *registry.Client {
+	names := []string{}
+	for _, ref := range pod.Spec.ImagePullSecrets {
+		names = append(names, ref.Name)
+	}
+	serviceAccount := valueOr(pod.Spec.ServiceAccountName, "default")
+	key := pod.Namespace + "/" + serviceAccount + "|" + strings.Join(names, ",")
+	if client, found := clients[key]; found {
+		return client
+	}
+	if account, err := clientset.CoreV1().ServiceAccounts(pod.Namespace).Get(context.Background(), serviceAccount, metav1.GetOptions{}); err == nil {
+		for _, ref := range account.ImagePullSecrets {
+			if !slices.Contains(names, ref.Name) {
+				names = append(names, ref.Name)
+			}
+		}
+	}
+	client := registry.NewClient(map[string]registry.Credentials{})
+	for _, name := range names {
+		secret, err := clientset.CoreV1().Secrets(pod.Namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil || secret.Type != v1.SecretTypeDockerConfigJson {
+			continue
+		}
+		for host, credentials := range registry.DockerConfigCredentials(secret.Data[v1.DockerConfigJsonKey]) {
+			client.Credentials[host] = credentials
+		}
+	}
+	clients[key] = client
+	return client
+}
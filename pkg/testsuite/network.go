@@ -0,0 +1,138 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultProbeNodes     = 3
+	defaultProbeNamespace = "default"
+	// nodeProbeTimeout is how long a node probe pod may take to be scheduled and finish its script
+	nodeProbeTimeout = 90 * time.Second
+)
+
+// networkChecks are the checks of the network suite
+var networkChecks = []Check{
+	single("DNS and Conntrack", checkDNSAndConntrack),
+	single("Service Routing", checkServiceRouting),
+	single("IP Addresses", checkIPAddresses),
+}
+
+func CheckNetwork(clientset *kubernetes.Clientset) []models.ResourceCheck {
+	checks := RunChecks(clientset, networkChecks)
+	return checks
+}
+
+// nodeProbe is a short lived pod pinned to a node that runs a script and exits, its logs are the result
+type nodeProbe struct {
+	Name        string
+	Script      string
+	HostNetwork bool
+	Privileged  bool
+}
+
+// sampleNodes returns up to count ready nodes spread evenly over the sorted node list
+func sampleNodes(clientset *kubernetes.Clientset, count int) ([]string, error) {
+	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	ready := []string{}
+	for _, node := range nodes.Items {
+		if nodeReady(node) && !node.Spec.Unschedulable {
+			ready = append(ready, node.Name)
+		}
+	}
+	sort.Strings(ready)
+	if count <= 0 || len(ready) <= count {
+		return ready, nil
+	}
+	sampled := make([]string, count)
+	for i := range sampled {
+		sampled[i] = ready[i*len(ready)/count]
+	}
+	return sampled, nil
+}
+
+// runNodeProbes runs the probe on every node in parallel and returns the output per node
+func runNodeProbes(clientset *kubernetes.Clientset, probe nodeProbe, nodes []string) (map[string]string, map[string]error) {
+	outputs := make(map[string]string)
+	errs := make(map[string]error)
+	var lock sync.Mutex
+	var group sync.WaitGroup
+	for _, node := range nodes {
+		group.Add(1)
+		go func(node string) {
+			defer group.Done()
+			output, err := runNodeProbe(clientset, probe, node)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				errs[node] = err
+				return
+			}
+			outputs[node] = output
+		}(node)
+	}
+	group.Wait()
+	return outputs, errs
+}
+
+func runNodeProbe(clientset *kubernetes.Clientset, probe nodeProbe, node string) (string, error) {
+	dnsPolicy := v1.DNSClusterFirst
+	if probe.HostNetwork {
+		dnsPolicy = v1.DNSClusterFirstWithHostNet
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "healthctl-" + probe.Name + "-",
+			Labels:       map[string]string{"app.kubernetes.io/managed-by": "healthctl"},
+		},
+		Spec: v1.PodSpec{
+			RestartPolicy: v1.RestartPolicyNever,
+			NodeName:      node,
+			HostNetwork:   probe.HostNetwork,
+			DNSPolicy:     dnsPolicy,
+			Tolerations:   []v1.Toleration{{Operator: v1.TolerationOpExists}},
+			Containers: []v1.Container{{
+				Name:            "probe",
+				Image:           valueOr(settings.Network.ProbeImage, defaultProbeImage),
+				Command:         []string{"sh", "-c", probe.Script},
+				SecurityContext: &v1.SecurityContext{Privileged: &probe.Privileged},
+			}},
+		},
+	}
+	pods := clientset.CoreV1().Pods(valueOr(settings.Network.ProbeNamespace, defaultProbeNamespace))
+	created, err := pods.Create(context.Background(), pod, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+	defer pods.Delete(context.Background(), created.Name, metav1.DeleteOptions{})
+
+	err = wait.PollUntilContextTimeout(context.Background(), 2*time.Second, nodeProbeTimeout, true, func(ctx context.Context) (bool, error) {
+		current, err := pods.Get(ctx, created.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return current.Status.Phase == v1.PodSucceeded || current.Status.Phase == v1.PodFailed, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("probe pod did not finish within %s: %v", nodeProbeTimeout, err)
+	}
+	logs, err := pods.GetLogs(created.Name, &v1.PodLogOptions{Container: "probe"}).DoRaw(context.Background())
+	if err != nil {
+		return "", fmt.Errorf("reading probe output: %v", err)
+	}
+	return string(logs), nil
+}
@@ -0,0 +1,112 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// npdConditions are the node conditions set by node-problem-detector that the Ready condition hides
+var npdConditions = map[string]models.Severity{
+	"KernelDeadlock":              models.SeverityCritical,
+	"ReadonlyFilesystem":          models.SeverityCritical,
+	"CorruptDockerOverlay2":       models.SeverityCritical,
+	"FrequentKubeletRestart":      models.SeverityWarning,
+	"FrequentDockerRestart":       models.SeverityWarning,
+	"FrequentContainerdRestart":   models.SeverityWarning,
+	"FrequentUnregisterNetDevice": models.SeverityWarning,
+	"KubeletProblem":              models.SeverityWarning,
+	"ContainerRuntimeProblem":     models.SeverityWarning,
+}
+
+// npdEventWindow is how far back node events are considered recent
+const npdEventWindow = time.Hour
+
+func checkNodeProblems(clientset *kubernetes.Clientset) models.ResourceCheck {
+	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Node Problems", Details: "Error fetching nodes", Error: err.Error()}
+	}
+
+	findings := []models.Finding{}
+	npdRunning := false
+	for _, node := range nodes.Items {
+		for _, condition := range node.Status.Conditions {
+			if k8s.IsKubeletCondition(condition.Type) {
+				continue
+			}
+			severity, known := npdConditions[string(condition.Type)]
+			if known {
+				npdRunning = true
+			}
+			if condition.Status != v1.ConditionTrue {
+				continue
+			}
+			if !known {
+				// conditions from other node agents are reported without knowing how bad they are
+				severity = models.SeverityWarning
+			}
+			findings = append(findings, models.Finding{
+				Resource: models.ResourceRef{Kind: "Node", Name: node.Name, Node: node.Name},
+				Reason:   string(condition.Type),
+				Severity: severity,
+				Message:  fmt.Sprintf("Node %s has condition %s: %s %s", node.Name, condition.Type, condition.Reason, strings.TrimSpace(condition.Message)),
+			})
+		}
+	}
+
+	findings = append(findings, nodeProblemEvents(clientset)...)
+
+	details := fmt.Sprintf("%d node problems found on %d nodes", len(findings), len(nodes.Items))
+	if len(findings) == 0 {
+		details = "No node problems reported."
+	}
+	if !npdRunning {
+		details += " node-problem-detector conditions are not present, it does not seem to be running."
+	}
+	return models.ResourceCheck{Label: "Node Problems", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// nodeProblemEvents groups the recent warning events of every node, e.g. KernelOops or TaskHung
+func nodeProblemEvents(clientset *kubernetes.Clientset) []models.Finding {
+	events, err := clientset.CoreV1().Events("").List(context.Background(), metav1.ListOptions{
+		FieldSelector: "involvedObject.kind=Node,type=Warning",
+	})
+	if err != nil {
+		return nil
+	}
+
+	cutoff := time.Now().Add(-npdEventWindow)
+	reasons := make(map[string][]string)
+	nodes := []string{}
+	for _, event := range events.Items {
+		if event.LastTimestamp.Time.Before(cutoff) && event.EventTime.Time.Before(cutoff) {
+			continue
+		}
+		node := event.InvolvedObject.Name
+		if _, found := reasons[node]; !found {
+			nodes = append(nodes, node)
+		}
+		reason := fmt.Sprintf("%s (x%d)", event.Reason, max(event.Count, 1))
+		reasons[node] = append(reasons[node], reason)
+	}
+
+	findings := []models.Finding{}
+	for _, node := range nodes {
+		findings = append(findings, models.Finding{
+			Resource: models.ResourceRef{Kind: "Node", Name: node, Node: node},
+			Reason:   "Events",
+			Severity: models.SeverityWarning,
+			Message:  fmt.Sprintf("Node %s had warning events in the last %s: %s", node, npdEventWindow, strings.Join(reasons[node], ", ")),
+		})
+	}
+	return findings
+}
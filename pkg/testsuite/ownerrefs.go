@@ -0,0 +1,81 @@
+package testsuite
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// gcGrace is how long the garbage collector gets to delete the dependents of a deleted owner
+const gcGrace = 5 * time.Minute
+
+// checkOwnerReferences finds ownerReferences whose owner no longer exists. Objects whose owners are all
+// gone should have been garbage collected, when they persist the garbage collector is blocked or broken.
+func checkOwnerReferences(clientset *kubernetes.Clientset) models.ResourceCheck {
+	objects, listed, err := k8s.ListAllObjectMetadata(clientset)
+	if err != nil {
+		return models.ResourceCheck{Label: "Owner References", Details: fmt.Sprintf("Error listing objects: %v", err), Error: err.Error()}
+	}
+	byUID := make(map[types.UID]k8s.ObjectMetadata)
+	byName := make(map[string]types.UID)
+	for _, object := range objects {
+		byUID[object.UID] = object
+		byName[k8s.GroupKind(object.Resource.GroupVersion, object.Resource.Kind)+"/"+object.Namespace+"/"+object.Name] = object.UID
+	}
+
+	findings := []models.Finding{}
+	orphans := 0
+	cutoff := time.Now().Add(-gcGrace)
+	for _, object := range objects {
+		if len(object.OwnerReferences) == 0 || object.DeletionTimestamp != nil || object.CreationTimestamp.Time.After(cutoff) {
+			continue
+		}
+		dangling := []string{}
+		known := 0
+		for _, owner := range object.OwnerReferences {
+			groupKind := k8s.GroupKind(owner.APIVersion, owner.Kind)
+			if !listed[groupKind] {
+				// owners of resources that could not be listed are not known to be missing
+				continue
+			}
+			known++
+			found, exists := byUID[owner.UID]
+			switch {
+			case !exists:
+				reason := "does not exist"
+				if _, recreated := byName[groupKind+"/"+object.Namespace+"/"+owner.Name]; recreated {
+					reason = "was recreated with another UID"
+				}
+				dangling = append(dangling, fmt.Sprintf("%s %s %s", owner.Kind, owner.Name, reason))
+			case found.Resource.Namespaced && (!object.Resource.Namespaced || found.Namespace != object.Namespace):
+				dangling = append(dangling, fmt.Sprintf("%s %s is in namespace %s, owners must be in the same namespace", owner.Kind, owner.Name, found.Namespace))
+			}
+		}
+		if len(dangling) == 0 {
+			continue
+		}
+		ref := models.ResourceRef{Kind: object.Resource.Kind, Namespace: object.Namespace, Name: object.Name}
+		if len(dangling) == known && known == len(object.OwnerReferences) {
+			orphans++
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Orphaned", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("%s should have been garbage collected, all its owners are gone: %s", ref, strings.Join(dangling, "; "))})
+			continue
+		}
+		findings = append(findings, models.Finding{Resource: ref, Reason: "DanglingOwner", Severity: models.SeverityInfo,
+			Message: fmt.Sprintf("%s has dangling owner references: %s", ref, strings.Join(dangling, "; "))})
+	}
+	sort.Slice(findings, func(i, j int) bool { return findings[i].Resource.String() < findings[j].Resource.String() })
+
+	details := fmt.Sprintf("%d objects checked, all owner references resolve.", len(objects))
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d objects with dangling owner references, %d of them should have been garbage collected.", len(findings), orphans)
+	}
+	return models.ResourceCheck{Label: "Owner References", Details: details, Status: orphans == 0, Findings: findings}
+}
@@ -9,22 +9,30 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
+// paasChecks are the checks of the paas suite
+var paasChecks = []Check{
+	single("Grafana", CheckGrafana),
+	single("Kibana", CheckKibana),
+	single("Prometheus", CheckPrometheus),
+	single("Etcd", CheckDbEtcd),
+	single("Istio", CheckIstio),
+	single("KubeProm", CheckKubeProm),
+	single("Alertmanager Config", checkAlertmanagerConfig),
+	single("RedisOperator", CheckRedisOperator),
+	single("RedisCluster", CheckRedisCluster),
+	single("Redis Config", checkRedisConfig),
+	single("Go Profiles", checkGoProfiles),
+	single("Yaeger", CheckJaeger),
+	single("Elastic", CheckElastic),
+	single("Logging Pipeline", checkLoggingPipeline),
+	single("ElastAlert", CheckElastAlert),
+	single("Alerta", CheckAlerta),
+	single("Kiali", CheckKiali),
+	single("Kafka Lag", CheckKafkaLag),
+}
+
 func CheckPAAS(clientset *kubernetes.Clientset) []models.ResourceCheck {
-	checks := []models.ResourceCheck{
-		CheckGrafana(clientset),
-		CheckKibana(clientset),
-		CheckPrometheus(clientset),
-		CheckDbEtcd(clientset),
-		CheckIstio(clientset),
-		CheckKubeProm(clientset),
-		CheckRedisOperator(clientset),
-		CheckRedisCluster(clientset),
-		CheckJaeger(clientset),
-		CheckElastic(clientset),
-		CheckElastAlert(clientset),
-		CheckAlerta(clientset),
-		CheckKiali(clientset),
-	}
+	checks := RunChecks(clientset, paasChecks)
 	return checks
 }
 
@@ -34,24 +42,24 @@ func CheckGrafana(clientset *kubernetes.Clientset) models.ResourceCheck {
 	// Check if Grafana pod is running in fed-grafana namespace
 	pods, err := clientset.CoreV1().Pods("fed-grafana").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Grafana", "Error fetching pods", false}
+		return models.ResourceCheck{Label: "Grafana", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	if len(pods.Items) == 0 {
-		return models.ResourceCheck{"Grafana", "No Grafana pods found", false}
+		return models.ResourceCheck{Label: "Grafana", Details: "No Grafana pods found", Status: false}
 	}
 
 	// Check if Grafana service is up
 	services, err := clientset.CoreV1().Services("fed-grafana").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Grafana", "Error fetching services", false}
+		return models.ResourceCheck{Label: "Grafana", Details: "Error fetching services", Error: err.Error()}
 	}
 
 	if len(services.Items) == 0 {
-		return models.ResourceCheck{"Grafana", "No Grafana services found", false}
+		return models.ResourceCheck{Label: "Grafana", Details: "No Grafana services found", Status: false}
 	}
 
-	return models.ResourceCheck{"Grafana", "Grafana is Up", true}
+	return models.ResourceCheck{Label: "Grafana", Details: "Grafana is Up", Status: true}
 }
 
 func CheckKibana(clientset *kubernetes.Clientset) models.ResourceCheck {
@@ -59,24 +67,24 @@ func CheckKibana(clientset *kubernetes.Clientset) models.ResourceCheck {
 	// Check if Kibana pod is running in fed-kibana namespace
 	pods, err := clientset.CoreV1().Pods("fed-kibana").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Kibana", "Error fetching pods", false}
+		return models.ResourceCheck{Label: "Kibana", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	if len(pods.Items) == 0 {
-		return models.ResourceCheck{"Kibana", "No Kibana pods found", false}
+		return models.ResourceCheck{Label: "Kibana", Details: "No Kibana pods found", Status: false}
 	}
 
 	// Check if Kibana service is up
 	services, err := clientset.CoreV1().Services("fed-kibana").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Kibana", "Error fetching services", false}
+		return models.ResourceCheck{Label: "Kibana", Details: "Error fetching services", Error: err.Error()}
 	}
 
 	if len(services.Items) == 0 {
-		return models.ResourceCheck{"Kibana", "No Kibana services found", false}
+		return models.ResourceCheck{Label: "Kibana", Details: "No Kibana services found", Status: false}
 	}
 
-	return models.ResourceCheck{"Kibana", "Kibana is Up", true}
+	return models.ResourceCheck{Label: "Kibana", Details: "Kibana is Up", Status: true}
 }
 
 func CheckPrometheus(clientset *kubernetes.Clientset) models.ResourceCheck {
@@ -84,24 +92,24 @@ func CheckPrometheus(clientset *kubernetes.Clientset) models.ResourceCheck {
 	// Check if Prometheus pod is running in fed-prometheus namespace
 	pods, err := clientset.CoreV1().Pods("fed-prometheus").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Prometheus", "Error fetching pods", false}
+		return models.ResourceCheck{Label: "Prometheus", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	if len(pods.Items) == 0 {
-		return models.ResourceCheck{"Prometheus", "No Prometheus pods found", false}
+		return models.ResourceCheck{Label: "Prometheus", Details: "No Prometheus pods found", Status: false}
 	}
 
 	// Check if Prometheus service is up
 	services, err := clientset.CoreV1().Services("fed-prometheus").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Prometheus", "Error fetching services", false}
+		return models.ResourceCheck{Label: "Prometheus", Details: "Error fetching services", Error: err.Error()}
 	}
 
 	if len(services.Items) == 0 {
-		return models.ResourceCheck{"Prometheus", "No Prometheus services found", false}
+		return models.ResourceCheck{Label: "Prometheus", Details: "No Prometheus services found", Status: false}
 	}
 
-	return models.ResourceCheck{"Prometheus", "Prometheus is Up", true}
+	return models.ResourceCheck{Label: "Prometheus", Details: "Prometheus is Up", Status: true}
 }
 
 func CheckDbEtcd(clientset *kubernetes.Clientset) models.ResourceCheck {
@@ -109,24 +117,24 @@ func CheckDbEtcd(clientset *kubernetes.Clientset) models.ResourceCheck {
 	// Check if etcd pod is running in fed-etcd namespace
 	pods, err := clientset.CoreV1().Pods("fed-etcd").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Etcd", "Error fetching pods", false}
+		return models.ResourceCheck{Label: "Etcd", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	if len(pods.Items) == 0 {
-		return models.ResourceCheck{"Etcd", "No Etcd pods found", false}
+		return models.ResourceCheck{Label: "Etcd", Details: "No Etcd pods found", Status: false}
 	}
 
 	// Check if etcd service is up
 	services, err := clientset.CoreV1().Services("fed-etcd").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Etcd", "Error fetching services", false}
+		return models.ResourceCheck{Label: "Etcd", Details: "Error fetching services", Error: err.Error()}
 	}
 
 	if len(services.Items) == 0 {
-		return models.ResourceCheck{"Etcd", "No Etcd services found", false}
+		return models.ResourceCheck{Label: "Etcd", Details: "No Etcd services found", Status: false}
 	}
 
-	return models.ResourceCheck{"Etcd", "Etcd is Up", true}
+	return models.ResourceCheck{Label: "Etcd", Details: "Etcd is Up", Status: true}
 }
 
 func CheckIstio(clientset *kubernetes.Clientset) models.ResourceCheck {
@@ -134,24 +142,24 @@ func CheckIstio(clientset *kubernetes.Clientset) models.ResourceCheck {
 	// Check if Istio pod is running in fed-istio-system namespace
 	pods, err := clientset.CoreV1().Pods("fed-istio-system").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Istio", "Error fetching pods", false}
+		return models.ResourceCheck{Label: "Istio", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	if len(pods.Items) == 0 {
-		return models.ResourceCheck{"Istio", "No Istio pods found", false}
+		return models.ResourceCheck{Label: "Istio", Details: "No Istio pods found", Status: false}
 	}
 
 	// Check if Istio service is up
 	services, err := clientset.CoreV1().Services("fed-istio-system").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Istio", "Error fetching services", false}
+		return models.ResourceCheck{Label: "Istio", Details: "Error fetching services", Error: err.Error()}
 	}
 
 	if len(services.Items) == 0 {
-		return models.ResourceCheck{"Istio", "No Istio services found", false}
+		return models.ResourceCheck{Label: "Istio", Details: "No Istio services found", Status: false}
 	}
 
-	return models.ResourceCheck{"Istio", "Istio is Up", true}
+	return models.ResourceCheck{Label: "Istio", Details: "Istio is Up", Status: true}
 }
 
 func CheckKubeProm(clientset *kubernetes.Clientset) models.ResourceCheck {
@@ -159,24 +167,24 @@ func CheckKubeProm(clientset *kubernetes.Clientset) models.ResourceCheck {
 	// Check if KubeProm pod is running in fed-kube-prom namespace
 	pods, err := clientset.CoreV1().Pods("fed-kube-prom").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"KubeProm", "Error fetching pods", false}
+		return models.ResourceCheck{Label: "KubeProm", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	if len(pods.Items) == 0 {
-		return models.ResourceCheck{"KubeProm", "No KubeProm pods found", false}
+		return models.ResourceCheck{Label: "KubeProm", Details: "No KubeProm pods found", Status: false}
 	}
 
 	// Check if KubeProm service is up
 	services, err := clientset.CoreV1().Services("fed-kube-prom").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"KubeProm", "Error fetching services", false}
+		return models.ResourceCheck{Label: "KubeProm", Details: "Error fetching services", Error: err.Error()}
 	}
 
 	if len(services.Items) == 0 {
-		return models.ResourceCheck{"KubeProm", "No KubeProm services found", false}
+		return models.ResourceCheck{Label: "KubeProm", Details: "No KubeProm services found", Status: false}
 	}
 
-	return models.ResourceCheck{"KubeProm", "KubeProm is Up", true}
+	return models.ResourceCheck{Label: "KubeProm", Details: "KubeProm is Up", Status: true}
 }
 
 func CheckRedisOperator(clientset *kubernetes.Clientset) models.ResourceCheck {
@@ -184,24 +192,24 @@ func CheckRedisOperator(clientset *kubernetes.Clientset) models.ResourceCheck {
 	// Check if RedisOperator pod is running in fed-redis-operator namespace
 	pods, err := clientset.CoreV1().Pods("fed-redis-operator").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"RedisOperator", "Error fetching pods", false}
+		return models.ResourceCheck{Label: "RedisOperator", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	if len(pods.Items) == 0 {
-		return models.ResourceCheck{"RedisOperator", "No RedisOperator pods found", false}
+		return models.ResourceCheck{Label: "RedisOperator", Details: "No RedisOperator pods found", Status: false}
 	}
 
 	// Check if RedisOperator service is up
 	services, err := clientset.CoreV1().Services("fed-redis-operator").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"RedisOperator", "Error fetching services", false}
+		return models.ResourceCheck{Label: "RedisOperator", Details: "Error fetching services", Error: err.Error()}
 	}
 
 	if len(services.Items) == 0 {
-		return models.ResourceCheck{"RedisOperator", "No RedisOperator services found", false}
+		return models.ResourceCheck{Label: "RedisOperator", Details: "No RedisOperator services found", Status: false}
 	}
 
-	return models.ResourceCheck{"RedisOperator", "RedisOperator is Up", true}
+	return models.ResourceCheck{Label: "RedisOperator", Details: "RedisOperator is Up", Status: true}
 }
 
 func CheckRedisCluster(clientset *kubernetes.Clientset) models.ResourceCheck {
@@ -209,24 +217,24 @@ func CheckRedisCluster(clientset *kubernetes.Clientset) models.ResourceCheck {
 	// Check if RedisCluster pod is running in fed-redis-cluster namespace
 	pods, err := clientset.CoreV1().Pods("fed-redis-cluster").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"RedisCluster", "Error fetching pods", false}
+		return models.ResourceCheck{Label: "RedisCluster", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	if len(pods.Items) == 0 {
-		return models.ResourceCheck{"RedisCluster", "No RedisCluster pods found", false}
+		return models.ResourceCheck{Label: "RedisCluster", Details: "No RedisCluster pods found", Status: false}
 	}
 
 	// Check if RedisCluster service is up
 	services, err := clientset.CoreV1().Services("fed-redis-cluster").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"RedisCluster", "Error fetching services", false}
+		return models.ResourceCheck{Label: "RedisCluster", Details: "Error fetching services", Error: err.Error()}
 	}
 
 	if len(services.Items) == 0 {
-		return models.ResourceCheck{"RedisCluster", "No RedisCluster services found", false}
+		return models.ResourceCheck{Label: "RedisCluster", Details: "No RedisCluster services found", Status: false}
 	}
 
-	return models.ResourceCheck{"RedisCluster", "RedisCluster is Up", true}
+	return models.ResourceCheck{Label: "RedisCluster", Details: "RedisCluster is Up", Status: true}
 }
 
 func CheckJaeger(clientset *kubernetes.Clientset) models.ResourceCheck {
@@ -234,24 +242,24 @@ func CheckJaeger(clientset *kubernetes.Clientset) models.ResourceCheck {
 	// Check if Yaeger pod is running in fed-yaeger namespace
 	pods, err := clientset.CoreV1().Pods("fed-yaeger").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Yaeger", "Error fetching pods", false}
+		return models.ResourceCheck{Label: "Yaeger", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	if len(pods.Items) == 0 {
-		return models.ResourceCheck{"Yaeger", "No Yaeger pods found", false}
+		return models.ResourceCheck{Label: "Yaeger", Details: "No Yaeger pods found", Status: false}
 	}
 
 	// Check if Yaeger service is up
 	services, err := clientset.CoreV1().Services("fed-yaeger").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Yaeger", "Error fetching services", false}
+		return models.ResourceCheck{Label: "Yaeger", Details: "Error fetching services", Error: err.Error()}
 	}
 
 	if len(services.Items) == 0 {
-		return models.ResourceCheck{"Yaeger", "No Yaeger services found", false}
+		return models.ResourceCheck{Label: "Yaeger", Details: "No Yaeger services found", Status: false}
 	}
 
-	return models.ResourceCheck{"Yaeger", "Yaeger is Up", true}
+	return models.ResourceCheck{Label: "Yaeger", Details: "Yaeger is Up", Status: true}
 }
 
 func CheckElastic(clientset *kubernetes.Clientset) models.ResourceCheck {
@@ -259,24 +267,24 @@ func CheckElastic(clientset *kubernetes.Clientset) models.ResourceCheck {
 	// Check if Elastic pod is running in fed-elastic namespace
 	pods, err := clientset.CoreV1().Pods("fed-elastic").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Elastic", "Error fetching pods", false}
+		return models.ResourceCheck{Label: "Elastic", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	if len(pods.Items) == 0 {
-		return models.ResourceCheck{"Elastic", "No Elastic pods found", false}
+		return models.ResourceCheck{Label: "Elastic", Details: "No Elastic pods found", Status: false}
 	}
 
 	// Check if Elastic service is up
 	services, err := clientset.CoreV1().Services("fed-elastic").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Elastic", "Error fetching services", false}
+		return models.ResourceCheck{Label: "Elastic", Details: "Error fetching services", Error: err.Error()}
 	}
 
 	if len(services.Items) == 0 {
-		return models.ResourceCheck{"Elastic", "No Elastic services found", false}
+		return models.ResourceCheck{Label: "Elastic", Details: "No Elastic services found", Status: false}
 	}
 
-	return models.ResourceCheck{"Elastic", "Elastic is Up", true}
+	return models.ResourceCheck{Label: "Elastic", Details: "Elastic is Up", Status: true}
 }
 
 func CheckElastAlert(clientset *kubernetes.Clientset) models.ResourceCheck {
@@ -284,24 +292,24 @@ func CheckElastAlert(clientset *kubernetes.Clientset) models.ResourceCheck {
 	// Check if ElastAlert pod is running in fed-elastalert namespace
 	pods, err := clientset.CoreV1().Pods("fed-elastalert").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"ElastAlert", "Error fetching pods", false}
+		return models.ResourceCheck{Label: "ElastAlert", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	if len(pods.Items) == 0 {
-		return models.ResourceCheck{"ElastAlert", "No ElastAlert pods found", false}
+		return models.ResourceCheck{Label: "ElastAlert", Details: "No ElastAlert pods found", Status: false}
 	}
 
 	// Check if ElastAlert service is up
 	services, err := clientset.CoreV1().Services("fed-elastalert").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"ElastAlert", "Error fetching services", false}
+		return models.ResourceCheck{Label: "ElastAlert", Details: "Error fetching services", Error: err.Error()}
 	}
 
 	if len(services.Items) == 0 {
-		return models.ResourceCheck{"ElastAlert", "No ElastAlert services found", false}
+		return models.ResourceCheck{Label: "ElastAlert", Details: "No ElastAlert services found", Status: false}
 	}
 
-	return models.ResourceCheck{"ElastAlert", "ElastAlert is Up", true}
+	return models.ResourceCheck{Label: "ElastAlert", Details: "ElastAlert is Up", Status: true}
 }
 
 func CheckAlerta(clientset *kubernetes.Clientset) models.ResourceCheck {
@@ -309,24 +317,24 @@ func CheckAlerta(clientset *kubernetes.Clientset) models.ResourceCheck {
 	// Check if Alerta pod is running in fed-alerta namespace
 	pods, err := clientset.CoreV1().Pods("fed-alerta").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Alerta", "Error fetching pods", false}
+		return models.ResourceCheck{Label: "Alerta", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	if len(pods.Items) == 0 {
-		return models.ResourceCheck{"Alerta", "No Alerta pods found", false}
+		return models.ResourceCheck{Label: "Alerta", Details: "No Alerta pods found", Status: false}
 	}
 
 	// Check if Alerta service is up
 	services, err := clientset.CoreV1().Services("fed-alerta").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Alerta", "Error fetching services", false}
+		return models.ResourceCheck{Label: "Alerta", Details: "Error fetching services", Error: err.Error()}
 	}
 
 	if len(services.Items) == 0 {
-		return models.ResourceCheck{"Alerta", "No Alerta services found", false}
+		return models.ResourceCheck{Label: "Alerta", Details: "No Alerta services found", Status: false}
 	}
 
-	return models.ResourceCheck{"Alerta", "Alerta is Up", true}
+	return models.ResourceCheck{Label: "Alerta", Details: "Alerta is Up", Status: true}
 }
 
 func CheckKiali(clientset *kubernetes.Clientset) models.ResourceCheck {
@@ -334,22 +342,22 @@ func CheckKiali(clientset *kubernetes.Clientset) models.ResourceCheck {
 	// Check if Kiali pod is running in fed-kiali namespace
 	pods, err := clientset.CoreV1().Pods("fed-kiali").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Kiali", "Error fetching pods", false}
+		return models.ResourceCheck{Label: "Kiali", Details: "Error fetching pods", Error: err.Error()}
 	}
 
 	if len(pods.Items) == 0 {
-		return models.ResourceCheck{"Kiali", "No Kiali pods found", false}
+		return models.ResourceCheck{Label: "Kiali", Details: "No Kiali pods found", Status: false}
 	}
 
 	// Check if Kiali service is up
 	services, err := clientset.CoreV1().Services("fed-kiali").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return models.ResourceCheck{"Kiali", "Error fetching services", false}
+		return models.ResourceCheck{Label: "Kiali", Details: "Error fetching services", Error: err.Error()}
 	}
 
 	if len(services.Items) == 0 {
-		return models.ResourceCheck{"Kiali", "No Kiali services found", false}
+		return models.ResourceCheck{Label: "Kiali", Details: "No Kiali services found", Status: false}
 	}
 
-	return models.ResourceCheck{"Kiali", "Kiali is Up", true}
+	return models.ResourceCheck{Label: "Kiali", Details: "Kiali is Up", Status: true}
 }
@@ -0,0 +1,243 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Pod Security Standards levels, from least to most restrictive
+const (
+	pssPrivileged = "privileged"
+	pssBaseline   = "baseline"
+	pssRestricted = "restricted"
+)
+
+var pssRank = map[string]int{pssPrivileged: 0, pssBaseline: 1, pssRestricted: 2}
+
+// pssModes are the pod security admission labels of a namespace, enforce rejects pods, audit and warn only report them
+var pssModes = []string{"enforce", "audit", "warn"}
+
+// baselineCapabilities may be added under the baseline profile
+var baselineCapabilities = map[v1.Capability]bool{
+	"AUDIT_WRITE": true, "CHOWN": true, "DAC_OVERRIDE": true, "FOWNER": true, "FSETID": true, "KILL": true, "MKNOD": true,
+	"NET_BIND_SERVICE": true, "SETFCAP": true, "SETGID": true, "SETPCAP": true, "SETUID": true, "SYS_CHROOT": true,
+}
+
+// safeSysctls may be set under the baseline profile
+var safeSysctls = map[string]bool{
+	"kernel.shm_rmid_forced": true, "net.ipv4.ip_local_port_range": true, "net.ipv4.ip_unprivileged_port_start": true,
+	"net.ipv4.tcp_syncookies": true, "net.ipv4.ping_group_range": true, "net.ipv4.ip_local_reserved_ports": true,
+	"net.ipv4.tcp_keepalive_time": true, "net.ipv4.tcp_fin_timeout": true, "net.ipv4.tcp_keepalive_intvl": true,
+	"net.ipv4.tcp_keepalive_probes": true,
+}
+
+// checkPodSecurity evaluates the running workloads against the baseline and restricted Pod Security Standards.
+// Workloads violating a level their namespace enforces, audits or warns about are reported as warnings, the
+// readiness of namespaces without labels for the levels is reported as info before enforcing them.
+func checkPodSecurity(clientset *kubernetes.Clientset) models.ResourceCheck {
+	namespaces, err := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Pod Security", Details: "Error fetching namespaces", Error: err.Error()}
+	}
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Pod Security", Details: "Error fetching pods", Error: err.Error()}
+	}
+
+	owners := workloadOwners(clientset)
+	workloads := make(map[string]map[models.ResourceRef]v1.Pod)
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		workload, found := podWorkload(pod, owners)
+		if !found {
+			workload = models.ResourceRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+		}
+		if workloads[pod.Namespace] == nil {
+			workloads[pod.Namespace] = make(map[models.ResourceRef]v1.Pod)
+		}
+		workloads[pod.Namespace][workload] = pod
+	}
+
+	findings := []models.Finding{}
+	unlabelled := 0
+	for _, namespace := range namespaces.Items {
+		level, mode := namespaceLevel(namespace)
+		meets := map[string]int{}
+		total := 0
+		for workload, pod := range workloads[namespace.Name] {
+			total++
+			baseline := pssViolations(pod, pssBaseline)
+			restricted := append(append([]string{}, baseline...), pssViolations(pod, pssRestricted)...)
+			if len(baseline) == 0 {
+				meets[pssBaseline]++
+			}
+			if len(restricted) == 0 {
+				meets[pssRestricted]++
+			}
+			violations := restricted
+			if level == pssBaseline {
+				violations = baseline
+			}
+			if pssRank[level] > 0 && len(violations) > 0 {
+				findings = append(findings, models.Finding{Resource: workload, Reason: "PodSecurity", Severity: models.SeverityWarning,
+					Message: fmt.Sprintf("%s violates the %s profile namespace %s %ss: %s", workload, level, namespace.Name, mode, strings.Join(violations, "; "))})
+			}
+		}
+		if pssRank[level] == pssRank[pssRestricted] || total == 0 {
+			continue
+		}
+		unlabelled++
+		message := fmt.Sprintf("Namespace %s is at pod security level %s, %d of %d workloads meet baseline and %d restricted",
+			namespace.Name, level, meets[pssBaseline], total, meets[pssRestricted])
+		if meets[pssRestricted] == total {
+			message += ", restricted can be enforced"
+		} else if meets[pssBaseline] == total {
+			message += ", baseline can be enforced"
+		}
+		findings = append(findings, models.Finding{Resource: models.ResourceRef{Kind: "Namespace", Name: namespace.Name}, Reason: "Readiness", Severity: models.SeverityInfo, Message: message})
+	}
+	sort.Slice(findings, func(i, j int) bool { return findings[i].Resource.String() < findings[j].Resource.String() })
+
+	violating := 0
+	for _, finding := range findings {
+		if finding.Failing() {
+			violating++
+		}
+	}
+	details := fmt.Sprintf("All workloads meet the pod security level of their namespace, %d namespaces are below restricted.", unlabelled)
+	if violating > 0 {
+		details = fmt.Sprintf("%d workloads violate the pod security level of their namespace, %d namespaces are below restricted.", violating, unlabelled)
+	}
+	return models.ResourceCheck{Label: "Pod Security", Details: details, Status: violating == 0, Findings: findings}
+}
+
+// namespaceLevel returns the strictest pod security level set on the namespace in any mode, and the mode
+func namespaceLevel(namespace v1.Namespace) (string, string) {
+	level, mode := pssPrivileged, "enforce"
+	for _, m := range pssModes {
+		value := namespace.Labels["pod-security.kubernetes.io/"+m]
+		if rank, known := pssRank[value]; known && rank > pssRank[level] {
+			level, mode = value, m
+		}
+	}
+	return level, mode
+}
+
+// pssViolations returns what the pod violates of the controls a level adds, restricted only checks the
+// controls on top of baseline
+func pssViolations(pod v1.Pod, level string) []string {
+	violations := []string{}
+	spec := pod.Spec
+	containers := append(append([]v1.Container{}, spec.InitContainers...), spec.Containers...)
+	podContext := spec.SecurityContext
+	if podContext == nil {
+		podContext = &v1.PodSecurityContext{}
+	}
+
+	if level == pssBaseline {
+		if spec.HostNetwork || spec.HostPID || spec.HostIPC {
+			violations = append(violations, "host namespaces")
+		}
+		for _, volume := range spec.Volumes {
+			if volume.HostPath != nil {
+				violations = append(violations, "hostPath volume "+volume.Name)
+			}
+		}
+		for _, sysctl := range podContext.Sysctls {
+			if !safeSysctls[sysctl.Name] {
+				violations = append(violations, "unsafe sysctl "+sysctl.Name)
+			}
+		}
+		if podContext.SeccompProfile != nil && podContext.SeccompProfile.Type == v1.SeccompProfileTypeUnconfined {
+			violations = append(violations, "seccomp Unconfined")
+		}
+		for _, container := range containers {
+			sc := container.SecurityContext
+			for _, port := range container.Ports {
+				if port.HostPort != 0 {
+					violations = append(violations, fmt.Sprintf("container %s hostPort %d", container.Name, port.HostPort))
+				}
+			}
+			if sc == nil {
+				continue
+			}
+			if sc.Privileged != nil && *sc.Privileged {
+				violations = append(violations, "container "+container.Name+" privileged")
+			}
+			if sc.Capabilities != nil {
+				for _, capability := range sc.Capabilities.Add {
+					if !baselineCapabilities[capability] {
+						violations = append(violations, fmt.Sprintf("container %s adds capability %s", container.Name, capability))
+					}
+				}
+			}
+			if sc.ProcMount != nil && *sc.ProcMount == v1.UnmaskedProcMount {
+				violations = append(violations, "container "+container.Name+" unmasked /proc")
+			}
+			if sc.SeccompProfile != nil && sc.SeccompProfile.Type == v1.SeccompProfileTypeUnconfined {
+				violations = append(violations, "container "+container.Name+" seccomp Unconfined")
+			}
+		}
+		return violations
+	}
+
+	for _, volume := range spec.Volumes {
+		source := volume.VolumeSource
+		if source.ConfigMap == nil && source.CSI == nil && source.DownwardAPI == nil && source.EmptyDir == nil && source.Ephemeral == nil &&
+			source.PersistentVolumeClaim == nil && source.Projected == nil && source.Secret == nil && source.HostPath == nil {
+			violations = append(violations, "restricted volume type "+volume.Name)
+		}
+	}
+	podSeccomp := podContext.SeccompProfile != nil
+	podNonRoot := podContext.RunAsNonRoot != nil && *podContext.RunAsNonRoot
+	if podContext.RunAsUser != nil && *podContext.RunAsUser == 0 {
+		violations = append(violations, "runAsUser 0")
+	}
+	for _, container := range containers {
+		sc := container.SecurityContext
+		if sc == nil {
+			sc = &v1.SecurityContext{}
+		}
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			violations = append(violations, "container "+container.Name+" allowPrivilegeEscalation not false")
+		}
+		if !podNonRoot && (sc.RunAsNonRoot == nil || !*sc.RunAsNonRoot) {
+			violations = append(violations, "container "+container.Name+" runAsNonRoot not true")
+		}
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			violations = append(violations, "container "+container.Name+" runAsUser 0")
+		}
+		if !podSeccomp && sc.SeccompProfile == nil {
+			violations = append(violations, "container "+container.Name+" seccomp profile not set")
+		}
+		if sc.Capabilities == nil || !hasCapability(sc.Capabilities.Drop, "ALL") {
+			violations = append(violations, "container "+container.Name+" does not drop ALL capabilities")
+		} else {
+			for _, capability := range sc.Capabilities.Add {
+				if capability != "NET_BIND_SERVICE" {
+					violations = append(violations, fmt.Sprintf("container %s adds capability %s", container.Name, capability))
+				}
+			}
+		}
+	}
+	return violations
+}
+
+func hasCapability(capabilities []v1.Capability, capability v1.Capability) bool {
+	for _, c := range capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
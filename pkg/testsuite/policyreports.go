@@ -0,0 +1,231 @@
+package testsuite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	policyReportGroup = "wgpolicyk8s.io/v1alpha2"
+	gatekeeperGroup   = "constraints.gatekeeper.sh/v1beta1"
+)
+
+// policyObject is an object a policy report or one of its results is about
+type policyObject struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// policyReportList is the part of a PolicyReport or ClusterPolicyReport list healthctl reads. Reports of a
+// single object name it in scope and leave the resources of their results empty.
+type policyReportList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Scope   *policyObject `json:"scope"`
+		Results []struct {
+			Policy    string         `json:"policy"`
+			Rule      string         `json:"rule"`
+			Result    string         `json:"result"`
+			Severity  string         `json:"severity"`
+			Message   string         `json:"message"`
+			Resources []policyObject `json:"resources"`
+		} `json:"results"`
+	} `json:"items"`
+}
+
+// constraintList is the part of a Gatekeeper constraint list healthctl reads, the audit controller writes the
+// violations of existing objects to the status
+type constraintList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Status struct {
+			Violations []struct {
+				Kind              string `json:"kind"`
+				Namespace         string `json:"namespace"`
+				Name              string `json:"name"`
+				Message           string `json:"message"`
+				EnforcementAction string `json:"enforcementAction"`
+			} `json:"violations"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// policyReportSeverity maps the result and severity of a policy report result, failed results of high
+// severity policies are critical
+func policyReportSeverity(result, severity string) models.Severity {
+	if result == "warn" {
+		return models.SeverityWarning
+	}
+	switch severity {
+	case "critical", "high":
+		return models.SeverityCritical
+	case "low", "info":
+		return models.SeverityInfo
+	}
+	return models.SeverityWarning
+}
+
+// gatekeeperSeverity maps the enforcement action of a constraint, deny constraints are violated by objects
+// created before the constraint or while the webhook was failing open
+func gatekeeperSeverity(action string) models.Severity {
+	switch action {
+	case "deny":
+		return models.SeverityCritical
+	case "dryrun":
+		return models.SeverityInfo
+	}
+	return models.SeverityWarning
+}
+
+// policyReportFindings returns the failed results of the PolicyReports and ClusterPolicyReports written by
+// Kyverno, Gatekeeper or another policy engine
+func policyReportFindings(clientset *kubernetes.Clientset) ([]models.Finding, error) {
+	findings := []models.Finding{}
+	for _, resource := range []struct{ name, kind string }{{"policyreports", "PolicyReport"}, {"clusterpolicyreports", "ClusterPolicyReport"}} {
+		data, err := clientset.Discovery().RESTClient().Get().AbsPath("/apis/" + policyReportGroup + "/" + resource.name).DoRaw(context.Background())
+		if err != nil {
+			return findings, fmt.Errorf("listing %s: %v", resource.name, err)
+		}
+		var list policyReportList
+		if err := json.Unmarshal(data, &list); err != nil {
+			return findings, fmt.Errorf("parsing %s: %v", resource.name, err)
+		}
+		for _, report := range list.Items {
+			for _, result := range report.Results {
+				if result.Result != "fail" && result.Result != "warn" && result.Result != "error" {
+					continue
+				}
+				objects := result.Resources
+				if len(objects) == 0 && report.Scope != nil {
+					objects = []policyObject{*report.Scope}
+				}
+				if len(objects) == 0 {
+					// a result about neither an object nor a scope is reported on the report itself
+					objects = []policyObject{{Kind: resource.kind, Namespace: report.Metadata.Namespace, Name: report.Metadata.Name}}
+				}
+				for _, object := range objects {
+					if object.Namespace == "" {
+						// the objects of a namespaced report are in its namespace
+						object.Namespace = report.Metadata.Namespace
+					}
+					findings = append(findings, models.Finding{
+						Resource: models.ResourceRef{Kind: object.Kind, Namespace: object.Namespace, Name: object.Name},
+						Reason:   result.Policy,
+						Severity: policyReportSeverity(result.Result, result.Severity),
+						Message:  fmt.Sprintf("%s violates policy %s rule %s: %s", object.Name, result.Policy, result.Rule, result.Message),
+					})
+				}
+			}
+		}
+	}
+	return findings, nil
+}
+
+// gatekeeperFindings returns the audit violations of all Gatekeeper constraints
+func gatekeeperFindings(clientset *kubernetes.Clientset, discovery *k8s.APIDiscovery) ([]models.Finding, error) {
+	findings := []models.Finding{}
+	for _, resource := range discovery.Resources {
+		if resource.GroupVersion != gatekeeperGroup || strings.Contains(resource.Name, "/") {
+			continue
+		}
+		data, err := clientset.Discovery().RESTClient().Get().AbsPath("/apis/" + gatekeeperGroup + "/" + resource.Name).DoRaw(context.Background())
+		if err != nil {
+			return findings, fmt.Errorf("listing %s constraints: %v", resource.Kind, err)
+		}
+		var list constraintList
+		if err := json.Unmarshal(data, &list); err != nil {
+			return findings, fmt.Errorf("parsing %s constraints: %v", resource.Kind, err)
+		}
+		for _, constraint := range list.Items {
+			policy := resource.Kind + "/" + constraint.Metadata.Name
+			for _, violation := range constraint.Status.Violations {
+				findings = append(findings, models.Finding{
+					Resource: models.ResourceRef{Kind: violation.Kind, Namespace: violation.Namespace, Name: violation.Name},
+					Reason:   policy,
+					Severity: gatekeeperSeverity(violation.EnforcementAction),
+					Message:  fmt.Sprintf("%s violates constraint %s: %s", violation.Name, policy, violation.Message),
+				})
+			}
+		}
+	}
+	return findings, nil
+}
+
+// checkPolicyReports folds the violations reported by Kyverno, Gatekeeper and other policy engines into
+// healthctl findings, one finding per resource and policy
+func checkPolicyReports(clientset *kubernetes.Clientset) models.ResourceCheck {
+	discovery, err := k8s.GetAPIResources(clientset)
+	if err != nil {
+		return models.ResourceCheck{Label: "Policy Reports", Details: "Error discovering API resources", Error: err.Error()}
+	}
+	reports, gatekeeper := discovery.Serves(policyReportGroup), discovery.Serves(gatekeeperGroup)
+	if !reports && !gatekeeper {
+		return models.ResourceCheck{Label: "Policy Reports", Details: "No PolicyReports or Gatekeeper constraints are served.", Status: true, Skipped: "no policy engine"}
+	}
+
+	all := []models.Finding{}
+	engines := []string{}
+	if reports {
+		findings, err := policyReportFindings(clientset)
+		if err != nil {
+			return models.ResourceCheck{Label: "Policy Reports", Details: "Error fetching policy reports", Error: err.Error()}
+		}
+		all = append(all, findings...)
+		engines = append(engines, "PolicyReports")
+	}
+	if gatekeeper {
+		findings, err := gatekeeperFindings(clientset, discovery)
+		if err != nil {
+			return models.ResourceCheck{Label: "Policy Reports", Details: "Error fetching Gatekeeper constraints", Error: err.Error()}
+		}
+		all = append(all, findings...)
+		engines = append(engines, "Gatekeeper constraints")
+	}
+
+	// rules of the same policy are folded into the most severe finding of the resource
+	byKey := make(map[string]int)
+	findings := []models.Finding{}
+	for _, finding := range all {
+		key := finding.Resource.String() + "|" + finding.Reason
+		if i, found := byKey[key]; found {
+			if finding.Severity.Rank() > findings[i].Severity.Rank() {
+				findings[i] = finding
+			}
+			continue
+		}
+		byKey[key] = len(findings)
+		findings = append(findings, finding)
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity.Rank() > findings[j].Severity.Rank()
+		}
+		return findings[i].Resource.String() < findings[j].Resource.String()
+	})
+
+	failing := 0
+	for _, finding := range findings {
+		if finding.Failing() {
+			failing++
+		}
+	}
+	details := fmt.Sprintf("No policy violations in the %s.", strings.Join(engines, " and "))
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d policy violations in the %s.", len(findings), strings.Join(engines, " and "))
+	}
+	return models.ResourceCheck{Label: "Policy Reports", Details: details, Status: failing == 0, Findings: findings}
+}
@@ -0,0 +1,237 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const defaultScaleUpFactor = 2.0
+
+var defaultCriticalNamespaces = []string{"kube-system"}
+
+// podRequests is the cpu in millicores and memory in bytes a pod requests
+type podRequests struct {
+	cpu    int64
+	memory int64
+}
+
+// nodeCapacity tracks the free resources of a node and the pods that could be preempted from it
+type nodeCapacity struct {
+	name    string
+	free    podRequests
+	victims []v1.Pod
+}
+
+func checkPriorityClasses(clientset *kubernetes.Clientset) models.ResourceCheck {
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{FieldSelector: "status.phase=Running"})
+	if err != nil {
+		return models.ResourceCheck{Label: "Priority Classes", Details: "Error fetching pods", Error: err.Error()}
+	}
+	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Priority Classes", Details: "Error fetching nodes", Error: err.Error()}
+	}
+	owners := workloadOwners(clientset)
+
+	findings := []models.Finding{}
+	critical := make(map[models.ResourceRef][]v1.Pod)
+	seen := make(map[models.ResourceRef]bool)
+	for _, pod := range pods.Items {
+		if !isCriticalNamespace(pod.Namespace) {
+			continue
+		}
+		workload, found := podWorkload(pod, owners)
+		if !found {
+			workload = models.ResourceRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+		}
+		if !seen[workload] && pod.Spec.PriorityClassName == "" {
+			findings = append(findings, models.Finding{
+				Resource: workload,
+				Reason:   "PriorityClass",
+				Severity: models.SeverityWarning,
+				Message:  fmt.Sprintf("%s in critical namespace %s has no priorityClassName and can be preempted by any other workload", workload, pod.Namespace),
+			})
+		}
+		seen[workload] = true
+		if workload.Kind != "DaemonSet" {
+			critical[workload] = append(critical[workload], pod)
+		}
+	}
+	findings = append(findings, simulatePreemption(nodes.Items, pods.Items, critical, owners)...)
+
+	failing := 0
+	for _, finding := range findings {
+		if finding.Failing() {
+			failing++
+		}
+	}
+	details := fmt.Sprintf("All workloads in critical namespaces have a priority class, %d critical workloads.", len(critical))
+	if failing > 0 {
+		details = fmt.Sprintf("%d priority and preemption problems found.", failing)
+	}
+	return models.ResourceCheck{Label: "Priority Classes", Details: details, Status: failing == 0, Findings: findings}
+}
+
+func isCriticalNamespace(namespace string) bool {
+	patterns := settings.Priority.CriticalNamespaces
+	if len(patterns) == 0 {
+		patterns = defaultCriticalNamespaces
+	}
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, namespace); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+func priorityOf(pod v1.Pod) int32 {
+	if pod.Spec.Priority == nil {
+		return 0
+	}
+	return *pod.Spec.Priority
+}
+
+func requestsOf(pod v1.Pod) podRequests {
+	requests := podRequests{}
+	for _, container := range pod.Spec.Containers {
+		requests.cpu += container.Resources.Requests.Cpu().MilliValue()
+		requests.memory += container.Resources.Requests.Memory().Value()
+	}
+	return requests
+}
+
+// simulatePreemption adds replicas to every critical workload by the scale up factor and places them like
+// the scheduler would by requests only: on free capacity first, then by preempting lower priority pods.
+// Taints, affinities and pod disruption budgets are not considered.
+func simulatePreemption(nodes []v1.Node, pods []v1.Pod, critical map[models.ResourceRef][]v1.Pod, owners map[string]string) []models.Finding {
+	factor := settings.Priority.ScaleUpFactor
+	if factor <= 1 {
+		factor = defaultScaleUpFactor
+	}
+
+	capacity := make(map[string]*nodeCapacity)
+	names := []string{}
+	for _, node := range nodes {
+		if node.Spec.Unschedulable || !nodeReady(node) {
+			continue
+		}
+		capacity[node.Name] = &nodeCapacity{name: node.Name, free: podRequests{
+			cpu:    node.Status.Allocatable.Cpu().MilliValue(),
+			memory: node.Status.Allocatable.Memory().Value(),
+		}}
+		names = append(names, node.Name)
+	}
+	sort.Strings(names)
+	for _, pod := range pods {
+		node, found := capacity[pod.Spec.NodeName]
+		if !found {
+			continue
+		}
+		requests := requestsOf(pod)
+		node.free.cpu -= requests.cpu
+		node.free.memory -= requests.memory
+		node.victims = append(node.victims, pod)
+	}
+	for _, node := range capacity {
+		sort.SliceStable(node.victims, func(i, j int) bool { return priorityOf(node.victims[i]) < priorityOf(node.victims[j]) })
+	}
+
+	workloads := []models.ResourceRef{}
+	for workload := range critical {
+		workloads = append(workloads, workload)
+	}
+	sort.Slice(workloads, func(i, j int) bool { return workloads[i].String() < workloads[j].String() })
+
+	preempted := make(map[models.ResourceRef]int)
+	unschedulable := []models.Finding{}
+	for _, workload := range workloads {
+		template := critical[workload][0]
+		extra := int(math.Ceil(float64(len(critical[workload])) * (factor - 1)))
+		failed := 0
+		for i := 0; i < extra; i++ {
+			if !placeReplica(capacity, names, template, owners, preempted) {
+				failed++
+			}
+		}
+		if failed > 0 {
+			unschedulable = append(unschedulable, models.Finding{
+				Resource: workload,
+				Reason:   "ScaleUp",
+				Severity: models.SeverityWarning,
+				Message:  fmt.Sprintf("%d of %d additional replicas of %s do not fit even after preempting lower priority pods", failed, extra, workload),
+			})
+		}
+	}
+
+	findings := []models.Finding{}
+	victims := []models.ResourceRef{}
+	for workload := range preempted {
+		victims = append(victims, workload)
+	}
+	sort.Slice(victims, func(i, j int) bool { return victims[i].String() < victims[j].String() })
+	for _, workload := range victims {
+		findings = append(findings, models.Finding{
+			Resource: workload,
+			Reason:   "Preemption",
+			Severity: models.SeverityInfo,
+			Message:  fmt.Sprintf("%d pods of %s would be preempted if the critical tier scaled up %.1fx", preempted[workload], workload, factor),
+		})
+	}
+	return append(findings, unschedulable...)
+}
+
+// placeReplica places a copy of the pod on the first node with free capacity, or preempts lower priority pods
+func placeReplica(capacity map[string]*nodeCapacity, names []string, pod v1.Pod, owners map[string]string, preempted map[models.ResourceRef]int) bool {
+	requests := requestsOf(pod)
+	priority := priorityOf(pod)
+	for _, name := range names {
+		node := capacity[name]
+		if node.free.cpu >= requests.cpu && node.free.memory >= requests.memory {
+			node.free.cpu -= requests.cpu
+			node.free.memory -= requests.memory
+			return true
+		}
+	}
+	for _, name := range names {
+		node := capacity[name]
+		free := node.free
+		count := 0
+		for _, victim := range node.victims {
+			if free.cpu >= requests.cpu && free.memory >= requests.memory {
+				break
+			}
+			if priorityOf(victim) >= priority {
+				break
+			}
+			victimRequests := requestsOf(victim)
+			free.cpu += victimRequests.cpu
+			free.memory += victimRequests.memory
+			count++
+		}
+		if free.cpu < requests.cpu || free.memory < requests.memory {
+			continue
+		}
+		for _, victim := range node.victims[:count] {
+			workload, found := podWorkload(victim, owners)
+			if !found {
+				workload = models.ResourceRef{Kind: "Pod", Namespace: victim.Namespace, Name: victim.Name}
+			}
+			preempted[workload]++
+		}
+		node.victims = node.victims[count:]
+		node.free.cpu = free.cpu - requests.cpu
+		node.free.memory = free.memory - requests.memory
+		return true
+	}
+	return false
+}
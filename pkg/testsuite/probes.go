@@ -0,0 +1,219 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/kubernetes"
+)
+
+// probeProxyOverhead is added to the timeout of a probe for the round trip through the API server proxy
+const probeProxyOverhead = 5 * time.Second
+
+// matchesAny returns true when the value matches one of the globs
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, value); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// checkProbes audits the readiness and liveness probes of the workloads in the configured namespaces:
+// containers without probes, probes whose timeout is not shorter than their period, liveness probes of
+// slow starting applications that start probing right away, and HTTP probes that fail right now
+func checkProbes(clientset *kubernetes.Clientset) models.ResourceCheck {
+	config := settings.Probes
+	if len(config.Namespaces) == 0 {
+		return models.ResourceCheck{Label: "Probes", Details: "No namespaces configured.", Status: true, Skipped: "not configured"}
+	}
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{FieldSelector: "status.phase=Running"})
+	if err != nil {
+		return models.ResourceCheck{Label: "Probes", Details: "Error fetching pods", Error: err.Error()}
+	}
+
+	owners := workloadOwners(clientset)
+	probeEndpoints := !config.SkipEndpoints && !k8s.Gentle()
+	audited := make(map[string]bool)
+	findings := []models.Finding{}
+	unreachable := []string{}
+	containers := 0
+	for _, pod := range pods.Items {
+		if !matchesAny(config.Namespaces, pod.Namespace) {
+			continue
+		}
+		workload, found := podWorkload(pod, owners)
+		if !found {
+			workload = models.ResourceRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+		}
+		if workload.Kind == "Job" {
+			// jobs run to completion and are not behind a service
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			key := workload.String() + "|" + container.Name
+			if audited[key] {
+				continue
+			}
+			audited[key] = true
+			containers++
+			findings = append(findings, probeFindings(workload, container)...)
+			if probeEndpoints && containerReady(pod, container.Name) {
+				called, errs := endpointFindings(clientset, pod, workload, container)
+				findings = append(findings, called...)
+				unreachable = append(unreachable, errs...)
+			}
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Severity.Rank() > findings[j].Severity.Rank() })
+
+	failing := 0
+	for _, finding := range findings {
+		if finding.Failing() {
+			failing++
+		}
+	}
+	details := fmt.Sprintf("The probes of %d containers are sound.", containers)
+	if failing > 0 {
+		details = fmt.Sprintf("%d probe problems found in %d containers.", failing, containers)
+	}
+	if !probeEndpoints {
+		details += " Probe endpoints are not called."
+	}
+	if len(unreachable) > 0 {
+		// the API server could not call the endpoints, which says nothing about the probes of the kubelet
+		return models.ResourceCheck{Label: "Probes", Details: details + fmt.Sprintf(" %d probe endpoints could not be called through the API server.", len(unreachable)),
+			Error: strings.Join(unreachable, "; "), Findings: findings}
+	}
+	return models.ResourceCheck{Label: "Probes", Details: details, Status: failing == 0, Findings: findings}
+}
+
+// probeFindings reports missing probes and dangerous probe settings of a container
+func probeFindings(workload models.ResourceRef, container v1.Container) []models.Finding {
+	findings := []models.Finding{}
+	add := func(reason string, severity models.Severity, format string, args ...interface{}) {
+		findings = append(findings, models.Finding{Resource: workload, Reason: reason + " " + container.Name, Severity: severity,
+			Message: fmt.Sprintf("container %s ", container.Name) + fmt.Sprintf(format, args...)})
+	}
+	if container.ReadinessProbe == nil {
+		add("NoReadinessProbe", models.SeverityWarning, "has no readiness probe, it gets traffic before it is ready")
+	}
+	if container.LivenessProbe == nil {
+		add("NoLivenessProbe", models.SeverityInfo, "has no liveness probe, it is not restarted when it hangs")
+	}
+	for _, probe := range []struct {
+		kind  string
+		probe *v1.Probe
+	}{{"readiness", container.ReadinessProbe}, {"liveness", container.LivenessProbe}, {"startup", container.StartupProbe}} {
+		if probe.probe == nil {
+			continue
+		}
+		timeout, period := probeSeconds(probe.probe.TimeoutSeconds, 1), probeSeconds(probe.probe.PeriodSeconds, 10)
+		if timeout >= period {
+			add("ProbeTimeout", models.SeverityWarning, "has a %s probe timeout of %ds, not shorter than its period of %ds, so probes overlap",
+				probe.kind, timeout, period)
+		}
+	}
+	if container.LivenessProbe != nil && container.StartupProbe == nil && container.LivenessProbe.InitialDelaySeconds == 0 &&
+		matchesAny(settings.Probes.SlowStarting, container.Image) {
+		add("NoStartDelay", models.SeverityWarning, "runs the slow starting %s with a liveness probe from the first second and no startup probe, it is killed before it started",
+			container.Image)
+	}
+	return findings
+}
+
+// probeSeconds returns a probe setting or its Kubernetes default when it is unset
+func probeSeconds(value, defaultValue int32) int32 {
+	if value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func containerReady(pod v1.Pod, name string) bool {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == name {
+			return status.Ready
+		}
+	}
+	return false
+}
+
+// endpointFindings calls the HTTP readiness and liveness probes of a container through the API server
+// proxy with their headers and timeout, and reports the probes that fail like the kubelet sees them, with a
+// status outside 200-399. Calls the proxy could not make are returned as errors.
+func endpointFindings(clientset *kubernetes.Clientset, pod v1.Pod, workload models.ResourceRef, container v1.Container) ([]models.Finding, []string) {
+	findings := []models.Finding{}
+	errs := []string{}
+	for _, probe := range []struct {
+		kind  string
+		probe *v1.Probe
+	}{{"readiness", container.ReadinessProbe}, {"liveness", container.LivenessProbe}} {
+		if probe.probe == nil || probe.probe.HTTPGet == nil {
+			continue
+		}
+		get := probe.probe.HTTPGet
+		port, ok := probePort(container, get.Port)
+		if !ok {
+			findings = append(findings, models.Finding{Resource: workload, Reason: "ProbePort " + container.Name, Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("container %s has a %s probe on port %s, which it does not declare", container.Name, probe.kind, get.Port.String())})
+			continue
+		}
+		scheme := strings.ToLower(string(get.Scheme))
+		if scheme == "" {
+			scheme = "http"
+		}
+		timeout := time.Duration(probeSeconds(probe.probe.TimeoutSeconds, 1))*time.Second + probeProxyOverhead
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		request := clientset.CoreV1().RESTClient().Get().Namespace(pod.Namespace).Resource("pods").
+			Name(utilnet.JoinSchemeNamePort(scheme, pod.Name, port)).SubResource("proxy").Suffix(valueOr(get.Path, "/"))
+		for _, header := range get.HTTPHeaders {
+			request.SetHeader(header.Name, header.Value)
+		}
+		_, err := request.DoRaw(ctx)
+		cancel()
+		if err == nil {
+			continue
+		}
+		status, ok := err.(apierrors.APIStatus)
+		if !ok || status.Status().Code == 0 || strings.Contains(status.Status().Message, "error trying to reach") {
+			errs = append(errs, fmt.Sprintf("%s probe of container %s on pod %s/%s: %v", probe.kind, container.Name, pod.Namespace, pod.Name, err))
+			continue
+		}
+		code := status.Status().Code
+		if code >= 200 && code < 400 {
+			continue
+		}
+		findings = append(findings, models.Finding{Resource: workload, Reason: "ProbeFailing " + container.Name, Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("container %s answers its %s probe %s://%s%s on pod %s with status %d", container.Name, probe.kind, scheme, port,
+				valueOr(get.Path, "/"), pod.Name, code)})
+	}
+	return findings, errs
+}
+
+// probePort resolves a named probe port to the port number of the container
+func probePort(container v1.Container, port intstr.IntOrString) (string, bool) {
+	if port.Type == intstr.Int {
+		return strconv.Itoa(port.IntValue()), true
+	}
+	for _, containerPort := range container.Ports {
+		if containerPort.Name == port.StrVal {
+			return strconv.Itoa(int(containerPort.ContainerPort)), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,194 @@
+package testsuite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+	"healthctl/pkg/profiles"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+const (
+	// defaultProfileMemoryPercent is the memory usage in percent of the limit that triggers a capture
+	defaultProfileMemoryPercent = 90
+	// defaultProfileCooldown keeps a pod that stays near its limit from being captured on every run
+	defaultProfileCooldown = time.Hour
+)
+
+// profileCandidate is a pod whose memory usage crossed the threshold
+type profileCandidate struct {
+	pod     v1.Pod
+	port    int
+	finding models.Finding
+}
+
+// checkGoProfiles captures heap, goroutine and CPU profiles of Go services whose memory usage crosses a
+// percentage of their limit, so the cause of a leak is known before the OOMKill. The profiles are stored
+// under ~/.healthctl/profiles and added to the evidence and support bundles of the pod.
+func checkGoProfiles(clientset *kubernetes.Clientset) models.ResourceCheck {
+	cfg := settings.Profiles
+	if len(cfg.Targets) == 0 {
+		return models.ResourceCheck{Label: "Go Profiles", Details: "No Go services configured in checks.profiles.", Status: true, Skipped: "no targets"}
+	}
+	threshold := valueOr(cfg.MemoryPercent, defaultProfileMemoryPercent)
+	cooldown := defaultProfileCooldown
+	if parsed, err := time.ParseDuration(cfg.Cooldown); err == nil && parsed > 0 {
+		cooldown = parsed
+	}
+
+	findings := []models.Finding{}
+	candidates := []profileCandidate{}
+	checked := 0
+	for _, target := range cfg.Targets {
+		pods, err := clientset.CoreV1().Pods(target.Namespace).List(context.Background(), metav1.ListOptions{
+			LabelSelector: target.Selector,
+			FieldSelector: "status.phase=Running",
+		})
+		if err != nil {
+			return models.ResourceCheck{Label: "Go Profiles", Details: "Error fetching pods in " + target.Namespace, Error: err.Error()}
+		}
+		if len(pods.Items) == 0 {
+			continue
+		}
+		usage, err := podMemoryUsage(clientset, target.Namespace, target.Selector)
+		if err != nil {
+			return models.ResourceCheck{Label: "Go Profiles", Details: "metrics-server is unavailable.", Status: false,
+				Findings: []models.Finding{k8s.MetricsUnavailable(err)}}
+		}
+		for _, pod := range pods.Items {
+			checked++
+			container, percent, limit := memoryNearLimit(pod, usage[pod.Name])
+			if percent < threshold {
+				continue
+			}
+			candidates = append(candidates, profileCandidate{pod: pod, port: target.Port, finding: models.Finding{
+				Resource: models.ResourceRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name},
+				Reason:   "MemoryNearLimit",
+				Severity: models.SeverityWarning,
+				Message:  fmt.Sprintf("container %s of %s uses %d%% of its %s memory limit", container, pod.Name, percent, limit),
+			}})
+		}
+	}
+
+	mode := probesDisabled()
+	due := []profileCandidate{}
+	for _, candidate := range candidates {
+		last := profiles.LastCapture(candidate.pod.Namespace, candidate.pod.Name)
+		switch {
+		case mode != "":
+			candidate.finding.Message += ", profiles are not captured in " + mode
+		case time.Since(last) < cooldown:
+			candidate.finding.Message += fmt.Sprintf(", profiles were captured at %s", last.Format(time.RFC3339))
+		default:
+			due = append(due, candidate)
+			continue
+		}
+		findings = append(findings, candidate.finding)
+	}
+	findings = append(findings, captureProfiles(clientset, due)...)
+
+	details := fmt.Sprintf("%d Go service pods use less than %d%% of their memory limit.", checked, threshold)
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d of %d Go service pods near their memory limit, %d profiled.", len(findings), checked, len(due))
+	}
+	return models.ResourceCheck{Label: "Go Profiles", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// captureProfiles captures the profiles of the pods concurrently and adds the result to their findings
+func captureProfiles(clientset *kubernetes.Clientset, candidates []profileCandidate) []models.Finding {
+	cfg := settings.Profiles
+	names := cfg.Profiles
+	if len(names) == 0 {
+		names = k8s.PprofProfiles
+	}
+	cpu := k8s.DefaultCPUProfile
+	if parsed, err := time.ParseDuration(cfg.CPUDuration); err == nil && parsed > 0 {
+		cpu = parsed
+	}
+
+	kc := &k8s.K8sClient{Client: clientset}
+	findings := make([]models.Finding, len(candidates))
+	k8s.ExecEach(len(candidates), func(i int) {
+		candidate := candidates[i]
+		findings[i] = candidate.finding
+		captured := time.Now()
+		result, err := kc.CaptureProfiles(candidate.pod, candidate.port, names, cpu)
+		if err != nil {
+			findings[i].Message += ", capturing profiles failed: " + err.Error()
+			return
+		}
+		saved, err := profiles.Save(candidate.pod.Namespace, candidate.pod.Name, captured, result)
+		if err == nil {
+			err = profiles.Prune(candidate.pod.Namespace, candidate.pod.Name, cfg.Keep)
+		}
+		failed := []string{}
+		for _, profile := range result {
+			if profile.Err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", profile.Name, profile.Err))
+			}
+		}
+		if len(saved) > 0 {
+			kinds := []string{}
+			for _, file := range saved {
+				kinds = append(kinds, file.Name)
+			}
+			findings[i].Message += fmt.Sprintf(", %s profiles captured", strings.Join(kinds, ", "))
+		}
+		if len(failed) > 0 {
+			findings[i].Message += ", failed: " + strings.Join(failed, "; ")
+		}
+		if err != nil {
+			findings[i].Message += ", storing the profiles failed: " + err.Error()
+		}
+	})
+	return findings
+}
+
+// podMemoryUsage returns the memory usage of the containers of the pods by pod and container
+func podMemoryUsage(clientset *kubernetes.Clientset, namespace, selector string) (map[string]map[string]int64, error) {
+	request := clientset.Discovery().RESTClient().Get().AbsPath("/apis/metrics.k8s.io/v1beta1/namespaces/" + namespace + "/pods")
+	if selector != "" {
+		request = request.Param("labelSelector", selector)
+	}
+	data, err := request.DoRaw(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	podMetrics := metricsv1beta1.PodMetricsList{}
+	if err := json.Unmarshal(data, &podMetrics); err != nil {
+		return nil, err
+	}
+	usage := make(map[string]map[string]int64)
+	for _, metrics := range podMetrics.Items {
+		usage[metrics.Name] = make(map[string]int64)
+		for _, container := range metrics.Containers {
+			usage[metrics.Name][container.Name] = container.Usage.Memory().Value()
+		}
+	}
+	return usage, nil
+}
+
+// memoryNearLimit returns the container of a pod using the largest share of its memory limit, the share
+// in percent and the limit. Containers without a limit are ignored.
+func memoryNearLimit(pod v1.Pod, usage map[string]int64) (string, int, string) {
+	name, percent, limit := "", 0, ""
+	for _, container := range pod.Spec.Containers {
+		memory, found := container.Resources.Limits[v1.ResourceMemory]
+		if !found || memory.Value() == 0 {
+			continue
+		}
+		if share := int(usage[container.Name] * 100 / memory.Value()); share >= percent {
+			name, percent, limit = container.Name, share, memory.String()
+		}
+	}
+	return name, percent, limit
+}
@@ -0,0 +1,166 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	"healthctl/pkg/models"
+	"healthctl/pkg/registry"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// provenanceNamespace returns true when the namespace matches one of the configured globs, all namespaces
+// are checked when none are configured
+func provenanceNamespace(namespace string) bool {
+	if len(settings.ImageProvenance.Namespaces) == 0 {
+		return true
+	}
+	for _, pattern := range settings.ImageProvenance.Namespaces {
+		if matched, err := path.Match(pattern, namespace); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedRegistry returns true when the image is pulled from a registry glob like *.dkr.ecr.*.amazonaws.com
+// or from below a registry path like ghcr.io/example. docker.io and index.docker.io both name Docker Hub.
+func allowedRegistry(ref registry.Reference) bool {
+	name := ref.Registry + "/" + ref.Repository
+	for _, allowed := range settings.ImageProvenance.AllowedRegistries {
+		host, rest, _ := strings.Cut(allowed, "/")
+		if host = registry.NormalizeRegistry(host); rest != "" {
+			allowed = host + "/" + rest
+		} else {
+			allowed = host
+		}
+		if name == allowed || strings.HasPrefix(name, strings.TrimSuffix(allowed, "/")+"/") {
+			return true
+		}
+		if matched, err := path.Match(allowed, ref.Registry); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// imageDigest returns the image pinned to the digest the container is running, so the signature of the
+// running image is verified even if the tag moved
+func imageDigest(pod v1.Pod, container string, image string) string {
+	for _, status := range append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		if status.Name != container {
+			continue
+		}
+		if i := strings.Index(status.ImageID, "@sha256:"); i >= 0 {
+			return registry.ParseReference(image).Name() + status.ImageID[i:]
+		}
+	}
+	return image
+}
+
+// cosignVerify verifies the signature of an image with the cosign CLI, either against the configured public
+// key or keyless against the certificate identity and OIDC issuer, which are both required then
+func cosignVerify(image string) error {
+	cosign := settings.ImageProvenance.Cosign
+	args := []string{"verify", "--output", "json"}
+	if cosign.Key != "" {
+		args = append(args, "--key", cosign.Key)
+	} else {
+		args = append(args, "--certificate-identity-regexp", cosign.Identity, "--certificate-oidc-issuer-regexp", cosign.Issuer)
+	}
+	output, err := exec.Command("cosign", append(args, image)...).CombinedOutput()
+	if err != nil {
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		return fmt.Errorf("%s", lines[len(lines)-1])
+	}
+	return nil
+}
+
+// checkImageProvenance reports images in the configured namespaces that are pulled from a registry outside
+// the allow-list or whose cosign signature can not be verified
+func checkImageProvenance(clientset *kubernetes.Clientset) models.ResourceCheck {
+	config := settings.ImageProvenance
+	verifySignatures := config.Cosign.Key != "" || config.Cosign.Identity != "" || config.Cosign.Issuer != ""
+	if len(config.AllowedRegistries) == 0 && !verifySignatures {
+		return models.ResourceCheck{Label: "Image Provenance", Details: "No allowed registries or cosign verification configured.", Status: true, Skipped: "not configured"}
+	}
+	if verifySignatures && config.Cosign.Key == "" && (config.Cosign.Identity == "" || config.Cosign.Issuer == "") {
+		return models.ResourceCheck{Label: "Image Provenance", Details: "Keyless cosign verification requires both cosign.identity and cosign.issuer",
+			Error: "cosign.identity or cosign.issuer is not configured"}
+	}
+	if verifySignatures {
+		if _, err := exec.LookPath("cosign"); err != nil {
+			return models.ResourceCheck{Label: "Image Provenance", Details: "The cosign CLI is required to verify image signatures", Error: err.Error()}
+		}
+	}
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{FieldSelector: "status.phase=Running"})
+	if err != nil {
+		return models.ResourceCheck{Label: "Image Provenance", Details: "Error fetching pods", Error: err.Error()}
+	}
+
+	owners := workloadOwners(clientset)
+	verified := make(map[string]error)
+	reported := make(map[string]bool)
+	images := make(map[string]bool)
+	findings := []models.Finding{}
+	for _, pod := range pods.Items {
+		if !provenanceNamespace(pod.Namespace) {
+			continue
+		}
+		workload, found := podWorkload(pod, owners)
+		if !found {
+			workload = models.ResourceRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+		}
+		for _, container := range append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+			key := workload.String() + "|" + container.Image
+			if reported[key] {
+				continue
+			}
+			reported[key] = true
+			images[container.Image] = true
+
+			ref := registry.ParseReference(container.Image)
+			if len(config.AllowedRegistries) > 0 && !allowedRegistry(ref) {
+				findings = append(findings, models.Finding{Resource: workload, Reason: "ForeignRegistry", Severity: models.SeverityCritical,
+					Message: fmt.Sprintf("container %s runs %s from registry %s, which is not allowed", container.Name, container.Image, ref.Registry)})
+				continue
+			}
+			if !verifySignatures {
+				continue
+			}
+			image := imageDigest(pod, container.Name, container.Image)
+			if _, found := verified[image]; !found {
+				verified[image] = cosignVerify(image)
+			}
+			if err := verified[image]; err != nil {
+				findings = append(findings, models.Finding{Resource: workload, Reason: "Unsigned", Severity: models.SeverityWarning,
+					Message: fmt.Sprintf("container %s runs %s without a valid signature: %s", container.Name, image, err)})
+			}
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity.Rank() > findings[j].Severity.Rank()
+		}
+		return findings[i].Resource.String() < findings[j].Resource.String()
+	})
+
+	details := fmt.Sprintf("All %d images come from allowed registries", len(images))
+	if verifySignatures {
+		details = fmt.Sprintf("All %d images come from allowed registries and are signed", len(images))
+	}
+	if len(config.AllowedRegistries) == 0 {
+		details = fmt.Sprintf("All %d images are signed", len(images))
+	}
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d workload containers run unsigned images or images from foreign registries, %d images checked", len(findings), len(images))
+	}
+	return models.ResourceCheck{Label: "Image Provenance", Details: details + ".", Status: len(findings) == 0, Findings: findings}
+}
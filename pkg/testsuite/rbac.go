@@ -0,0 +1,206 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"healthctl/pkg/models"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// escalationVerbs let a subject grant itself more permissions than it has
+var escalationVerbs = []string{"escalate", "bind", "impersonate"}
+
+// broadGroups are groups every client or every authenticated client belongs to
+var broadGroups = map[string]bool{
+	"system:authenticated":   true,
+	"system:unauthenticated": true,
+	"system:serviceaccounts": true,
+}
+
+// roleBinding is a RoleBinding or ClusterRoleBinding with the rules of the role it grants
+type roleBinding struct {
+	ref       models.ResourceRef
+	namespace string
+	roleName  string
+	rules     []rbacv1.PolicyRule
+	subjects  []rbacv1.Subject
+}
+
+// checkRBAC scans all bindings for wildcard permissions, cluster-admin granted to service accounts or broad groups,
+// escalation verbs, cluster wide secret access and subjects that no longer exist. The findings are ordered
+// by severity so the worst grants come first. Bindings and roles of kubernetes itself (system:) are skipped.
+func checkRBAC(clientset *kubernetes.Clientset) models.ResourceCheck {
+	bindings, err := roleBindings(clientset)
+	if err != nil {
+		return models.ResourceCheck{Label: "RBAC", Details: fmt.Sprintf("Error fetching RBAC objects: %v", err), Error: err.Error()}
+	}
+	serviceAccounts := make(map[string]bool)
+	if list, err := clientset.CoreV1().ServiceAccounts("").List(context.Background(), metav1.ListOptions{}); err == nil {
+		for _, sa := range list.Items {
+			serviceAccounts[sa.Namespace+"/"+sa.Name] = true
+		}
+	}
+
+	findings := []models.Finding{}
+	for _, binding := range bindings {
+		if strings.HasPrefix(binding.ref.Name, "system:") || strings.HasPrefix(binding.roleName, "system:") {
+			continue
+		}
+		scope := "cluster wide"
+		if binding.namespace != "" {
+			scope = "in namespace " + binding.namespace
+		}
+		for _, subject := range binding.subjects {
+			name := subjectName(subject, binding.namespace)
+			if subject.Kind == rbacv1.ServiceAccountKind && !serviceAccounts[name] {
+				findings = append(findings, models.Finding{Resource: binding.ref, Reason: "MissingSubject " + name, Severity: models.SeverityInfo,
+					Message: fmt.Sprintf("%s grants %s to service account %s that does not exist, a service account created later with that name gets it", binding.ref, binding.roleName, name)})
+				continue
+			}
+			privileged := subject.Kind == rbacv1.ServiceAccountKind || (subject.Kind == rbacv1.GroupKind && broadGroups[subject.Name])
+			if !privileged {
+				continue
+			}
+			if problem, severity := rulesProblem(binding.rules, binding.namespace == ""); problem != "" {
+				if subject.Kind == rbacv1.GroupKind {
+					severity = models.SeverityCritical
+				}
+				findings = append(findings, models.Finding{Resource: binding.ref, Reason: "OverPermission " + name, Severity: severity,
+					Message: fmt.Sprintf("%s %s is granted %s %s by %s: %s", strings.ToLower(subject.Kind), name, binding.roleName, scope, binding.ref, problem)})
+			}
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity.Rank() > findings[j].Severity.Rank()
+		}
+		return findings[i].Resource.String() < findings[j].Resource.String()
+	})
+
+	failing := 0
+	for _, finding := range findings {
+		if finding.Failing() {
+			failing++
+		}
+	}
+	details := fmt.Sprintf("%d bindings checked, no over-permissive grants found.", len(bindings))
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d bindings checked, %d over-permissive grants and %d stale subjects.", len(bindings), failing, len(findings)-failing)
+	}
+	return models.ResourceCheck{Label: "RBAC", Details: details, Status: failing == 0, Findings: findings}
+}
+
+// rulesProblem describes the most dangerous permission in the rules of a role, with its severity
+func rulesProblem(rules []rbacv1.PolicyRule, clusterWide bool) (string, models.Severity) {
+	problems := []string{}
+	for _, rule := range rules {
+		allVerbs, allResources := hasValue(rule.Verbs, "*"), hasValue(rule.Resources, "*")
+		switch {
+		case allVerbs && allResources && hasValue(rule.APIGroups, "*"):
+			if clusterWide {
+				return "full cluster-admin access to every resource", models.SeverityCritical
+			}
+			problems = append(problems, "every verb on every resource of the namespace")
+		case allVerbs || allResources:
+			problems = append(problems, fmt.Sprintf("wildcard %s on %s", strings.Join(rule.Verbs, ","), strings.Join(rule.Resources, ",")))
+		}
+		// a wildcard verb already reported above covers the escalation verbs
+		for _, verb := range escalationVerbs {
+			if !allVerbs && hasValue(rule.Verbs, verb) {
+				problems = append(problems, fmt.Sprintf("%s on %s", verb, strings.Join(rule.Resources, ",")))
+			}
+		}
+		if clusterWide && hasValue(rule.APIGroups, "") && hasValue(rule.Resources, "secrets") && (hasValue(rule.Verbs, "get") || hasValue(rule.Verbs, "list")) {
+			problems = append(problems, "reads secrets of every namespace")
+		}
+	}
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, problem := range problems {
+		if !seen[problem] {
+			seen[problem] = true
+			unique = append(unique, problem)
+		}
+	}
+	return strings.Join(unique, "; "), models.SeverityWarning
+}
+
+// hasValue returns true when the values contain the value, or * which matches everything
+func hasValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value || v == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func subjectName(subject rbacv1.Subject, bindingNamespace string) string {
+	if subject.Kind != rbacv1.ServiceAccountKind {
+		return subject.Name
+	}
+	namespace := subject.Namespace
+	if namespace == "" {
+		namespace = bindingNamespace
+	}
+	return namespace + "/" + subject.Name
+}
+
+// roleBindings returns all RoleBindings and ClusterRoleBindings with the rules of the role they reference
+func roleBindings(clientset *kubernetes.Clientset) ([]roleBinding, error) {
+	rbac := clientset.RbacV1()
+	clusterRoles, err := rbac.ClusterRoles().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	roles, err := rbac.Roles("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	clusterBindings, err := rbac.ClusterRoleBindings().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	namespacedBindings, err := rbac.RoleBindings("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make(map[string][]rbacv1.PolicyRule)
+	for _, role := range clusterRoles.Items {
+		rules["ClusterRole/"+role.Name] = role.Rules
+	}
+	for _, role := range roles.Items {
+		rules["Role/"+role.Namespace+"/"+role.Name] = role.Rules
+	}
+
+	bindings := []roleBinding{}
+	for _, binding := range clusterBindings.Items {
+		bindings = append(bindings, roleBinding{
+			ref:      models.ResourceRef{Kind: "ClusterRoleBinding", Name: binding.Name},
+			roleName: binding.RoleRef.Name,
+			rules:    rules["ClusterRole/"+binding.RoleRef.Name],
+			subjects: binding.Subjects,
+		})
+	}
+	for _, binding := range namespacedBindings.Items {
+		key := "ClusterRole/" + binding.RoleRef.Name
+		if binding.RoleRef.Kind == "Role" {
+			key = "Role/" + binding.Namespace + "/" + binding.RoleRef.Name
+		}
+		bindings = append(bindings, roleBinding{
+			ref:       models.ResourceRef{Kind: "RoleBinding", Namespace: binding.Namespace, Name: binding.Name},
+			namespace: binding.Namespace,
+			roleName:  binding.RoleRef.Name,
+			rules:     rules[key],
+			subjects:  binding.Subjects,
+		})
+	}
+	return bindings, nil
+}
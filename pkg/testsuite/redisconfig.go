@@ -0,0 +1,128 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultRedisNamespace = "fed-redis-cluster"
+	defaultRedisContainer = "redis-node"
+)
+
+// redisConsistentParameters must be the same on every node even when the profile does not set them,
+// a replica promoted with other persistence settings silently changes the durability
+var redisConsistentParameters = []string{"appendonly", "appendfsync", "save", "maxmemory", "maxmemory-policy", "repl-backlog-size", "repl-backlog-ttl"}
+
+// redisUnits are the memory units of the redis config file
+var redisUnits = map[string]int64{"k": 1000, "kb": 1024, "m": 1000 * 1000, "mb": 1024 * 1024, "g": 1000 * 1000 * 1000, "gb": 1024 * 1024 * 1024}
+
+// normalizeRedisValue makes values comparable: memory sizes like 1mb become bytes, yes/no and lists
+// are compared case and whitespace insensitive
+func normalizeRedisValue(value string) string {
+	value = strings.ToLower(strings.Join(strings.Fields(value), " "))
+	for unit, factor := range redisUnits {
+		if number, found := strings.CutSuffix(value, unit); found {
+			if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+				return strconv.FormatInt(n*factor, 10)
+			}
+		}
+	}
+	return value
+}
+
+// checkRedisConfig compares the runtime configuration of every Redis node with the expected profile and
+// with the other nodes. Changes made with CONFIG SET inside a pod are lost when the pod restarts.
+func checkRedisConfig(clientset *kubernetes.Clientset) models.ResourceCheck {
+	if mode := probesDisabled(); mode != "" {
+		return skippedInMode("Redis Config", mode)
+	}
+	cfg := settings.Redis
+	namespace := valueOr(cfg.Namespace, defaultRedisNamespace)
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: cfg.PodSelector,
+		FieldSelector: "status.phase=Running",
+	})
+	if err != nil {
+		return models.ResourceCheck{Label: "Redis Config", Details: "Error fetching redis pods", Error: err.Error()}
+	}
+	if len(pods.Items) == 0 {
+		return models.ResourceCheck{Label: "Redis Config", Details: fmt.Sprintf("No running redis pods found in %s.", namespace), Status: true, Skipped: "no redis"}
+	}
+
+	kc := &k8s.K8sClient{Client: clientset}
+	container := valueOr(cfg.Container, defaultRedisContainer)
+	findings := []models.Finding{}
+	// values[parameter][value] are the pods running with that value
+	values := make(map[string]map[string][]string)
+	read := 0
+	runtimes, errs := make([]map[string]string, len(pods.Items)), make([]error, len(pods.Items))
+	k8s.ExecEach(len(pods.Items), func(i int) {
+		runtimes[i], errs[i] = kc.GetRedisConfig(pods.Items[i].Namespace, pods.Items[i].Name, container)
+	})
+	for i, pod := range pods.Items {
+		ref := models.ResourceRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+		runtime, err := runtimes[i], errs[i]
+		if err != nil {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "ConfigGet", Severity: models.SeverityWarning, Message: err.Error()})
+			continue
+		}
+		read++
+		for parameter, expected := range cfg.Expected {
+			actual, found := runtime[parameter]
+			if !found {
+				findings = append(findings, models.Finding{Resource: ref, Reason: parameter, Severity: models.SeverityWarning,
+					Message: fmt.Sprintf("redis %s does not know the parameter %s of the expected profile", pod.Name, parameter)})
+				continue
+			}
+			if normalizeRedisValue(actual) != normalizeRedisValue(expected) {
+				findings = append(findings, models.Finding{Resource: ref, Reason: parameter, Severity: models.SeverityWarning,
+					Message: fmt.Sprintf("redis %s runs with %s %q, the profile expects %q. A CONFIG SET change is lost on restart, put it into the redis config", pod.Name, parameter, actual, expected)})
+			}
+		}
+		for _, parameter := range redisConsistentParameters {
+			if actual, found := runtime[parameter]; found {
+				if values[parameter] == nil {
+					values[parameter] = make(map[string][]string)
+				}
+				value := normalizeRedisValue(actual)
+				values[parameter][value] = append(values[parameter][value], pod.Name)
+			}
+		}
+	}
+
+	for _, parameter := range redisConsistentParameters {
+		if _, inProfile := cfg.Expected[parameter]; inProfile || len(values[parameter]) < 2 {
+			continue
+		}
+		variants := []string{}
+		for value, podNames := range values[parameter] {
+			variants = append(variants, fmt.Sprintf("%q on %s", value, strings.Join(podNames, ", ")))
+		}
+		sort.Strings(variants)
+		findings = append(findings, models.Finding{
+			Resource: models.ResourceRef{Kind: "Namespace", Name: namespace},
+			Reason:   parameter,
+			Severity: models.SeverityWarning,
+			Message:  fmt.Sprintf("redis nodes run with different %s: %s", parameter, strings.Join(variants, "; ")),
+		})
+	}
+
+	details := fmt.Sprintf("The runtime config of %d redis nodes matches the profile and is consistent.", read)
+	if len(cfg.Expected) == 0 {
+		details = fmt.Sprintf("The persistence and replication config of %d redis nodes is consistent, no expected profile configured.", read)
+	}
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d redis config drifts on %d nodes.", len(findings), len(pods.Items))
+	}
+	return models.ResourceCheck{Label: "Redis Config", Details: details, Status: len(findings) == 0, Findings: findings}
+}
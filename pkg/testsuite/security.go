@@ -0,0 +1,22 @@
+package testsuite
+
+import (
+	"healthctl/pkg/models"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// securityChecks are the checks of the security suite
+var securityChecks = []Check{
+	single("Service Account Tokens", checkServiceAccountTokens),
+	single("RBAC", checkRBAC),
+	single("Pod Security", checkPodSecurity),
+	single("Vulnerabilities", checkVulnerabilities),
+	single("Image Provenance", checkImageProvenance),
+	single("Policy Reports", checkPolicyReports),
+}
+
+func CheckSecurity(clientset *kubernetes.Clientset) []models.ResourceCheck {
+	checks := RunChecks(clientset, securityChecks)
+	return checks
+}
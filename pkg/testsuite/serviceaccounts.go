@@ -0,0 +1,214 @@
+package testsuite
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// legacyTokenLastUsed and legacyTokenInvalidSince are set on legacy token secrets by the kube-apiserver
+const (
+	legacyTokenLastUsed     = "kubernetes.io/legacy-token-last-used"
+	legacyTokenInvalidSince = "kubernetes.io/legacy-token-invalid-since"
+	// verifyRoleTimeout bounds the cloud CLI call verifying a role, a CLI waiting for a login must not block the suite
+	verifyRoleTimeout = 30 * time.Second
+)
+
+// workloadIdentity is a cloud workload identity bound to a service account by an annotation
+type workloadIdentity struct {
+	Provider   string
+	Annotation string
+	Format     *regexp.Regexp
+	// Env is injected into the pods of the service account by the identity webhook
+	Env string
+	// Verify is the cloud CLI command that fails when the role does not exist
+	Verify func(value string) []string
+}
+
+var workloadIdentities = []workloadIdentity{
+	{
+		Provider:   "AWS",
+		Annotation: "eks.amazonaws.com/role-arn",
+		Format:     regexp.MustCompile(`^arn:aws[\w-]*:iam::\d{12}:role/[\w+=,.@/-]+$`),
+		Env:        "AWS_ROLE_ARN",
+		Verify: func(arn string) []string {
+			return []string{"aws", "iam", "get-role", "--role-name", arn[strings.LastIndex(arn, "/")+1:]}
+		},
+	},
+	{
+		Provider:   "GCP",
+		Annotation: "iam.gke.io/gcp-service-account",
+		Format:     regexp.MustCompile(`^[a-z][a-z0-9-]{4,28}[a-z0-9]@[a-z0-9-]+\.iam\.gserviceaccount\.com$`),
+		Verify: func(email string) []string {
+			return []string{"gcloud", "iam", "service-accounts", "describe", email}
+		},
+	},
+	{
+		Provider:   "Azure",
+		Annotation: "azure.workload.identity/client-id",
+		Format:     regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`),
+		Env:        "AZURE_CLIENT_ID",
+		Verify: func(clientID string) []string {
+			return []string{"az", "ad", "sp", "show", "--id", clientID}
+		},
+	},
+}
+
+// checkServiceAccountTokens reports bound tokens used after they expired, workload identity annotations
+// that do not map to a valid cloud role and long-lived legacy token secrets
+func checkServiceAccountTokens(clientset *kubernetes.Clientset) models.ResourceCheck {
+	serviceAccounts, err := clientset.CoreV1().ServiceAccounts("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Service Account Tokens", Details: "Error fetching service accounts", Error: err.Error()}
+	}
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{FieldSelector: "status.phase=Running"})
+	if err != nil {
+		return models.ResourceCheck{Label: "Service Account Tokens", Details: "Error fetching pods", Error: err.Error()}
+	}
+
+	findings := staleTokenFindings(clientset)
+	identities := 0
+	for _, sa := range serviceAccounts.Items {
+		for _, identity := range workloadIdentities {
+			value, found := sa.Annotations[identity.Annotation]
+			if !found {
+				continue
+			}
+			identities++
+			findings = append(findings, identityFindings(sa, identity, value, pods.Items)...)
+		}
+	}
+	findings = append(findings, legacyTokenFindings(clientset, pods.Items)...)
+	sort.Slice(findings, func(i, j int) bool { return findings[i].Resource.String() < findings[j].Resource.String() })
+
+	details := fmt.Sprintf("%d service accounts, %d with workload identity, no token problems found.", len(serviceAccounts.Items), identities)
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d service accounts, %d token and identity problems found.", len(serviceAccounts.Items), len(findings))
+	}
+	return models.ResourceCheck{Label: "Service Account Tokens", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// staleTokenFindings reads serviceaccount_stale_tokens_total of the kube-apiserver. Bound tokens are valid for a year
+// when the API server extends their expiry, the counter counts the requests with tokens past their real expiry:
+// clients that read the projected token once and never pick up the refreshed one.
+func staleTokenFindings(clientset *kubernetes.Clientset) []models.Finding {
+	data, err := clientset.Discovery().RESTClient().Get().AbsPath("/metrics").DoRaw(context.Background())
+	if err != nil {
+		return nil
+	}
+	var stale float64
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "serviceaccount_stale_tokens_total") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if value, err := strconv.ParseFloat(fields[len(fields)-1], 64); err == nil {
+			stale += value
+		}
+	}
+	if stale == 0 {
+		return nil
+	}
+	return []models.Finding{{
+		Resource: models.ResourceRef{Kind: "Cluster", Name: "kube-apiserver"},
+		Reason:   "StaleTokens",
+		Severity: models.SeverityWarning,
+		Message:  fmt.Sprintf("kube-apiserver accepted %.0f requests with expired bound service account tokens, some clients do not reload the refreshed token and fail once extended expiry is turned off; find them in the audit log by the authentication.k8s.io/stale-token annotation", stale),
+	}}
+}
+
+// identityFindings validates the workload identity annotation of a service account and that the identity
+// webhook injected it into the running pods of the service account
+func identityFindings(sa v1.ServiceAccount, identity workloadIdentity, value string, pods []v1.Pod) []models.Finding {
+	ref := models.ResourceRef{Kind: "ServiceAccount", Namespace: sa.Namespace, Name: sa.Name}
+	if !identity.Format.MatchString(value) {
+		return []models.Finding{{Resource: ref, Reason: identity.Provider + "Identity", Severity: models.SeverityCritical,
+			Message: fmt.Sprintf("%s annotation %s=%q of service account %s/%s is not a valid %s identity", identity.Provider, identity.Annotation, value, sa.Namespace, sa.Name, identity.Provider)}}
+	}
+
+	findings := []models.Finding{}
+	if settings.ServiceAccounts.VerifyRoles {
+		command := identity.Verify(value)
+		ctx, cancel := context.WithTimeout(context.Background(), verifyRoleTimeout)
+		output, err := exec.CommandContext(ctx, command[0], command[1:]...).CombinedOutput()
+		switch {
+		case ctx.Err() != nil:
+			findings = append(findings, models.Finding{Resource: ref, Reason: identity.Provider + "Role", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("%s identity %s of service account %s/%s could not be verified, %s did not answer within %s", identity.Provider, value, sa.Namespace, sa.Name, command[0], verifyRoleTimeout)})
+		case err != nil:
+			findings = append(findings, models.Finding{Resource: ref, Reason: identity.Provider + "Role", Severity: models.SeverityCritical,
+				Message: fmt.Sprintf("%s identity %s of service account %s/%s can not be found: %s", identity.Provider, value, sa.Namespace, sa.Name, strings.TrimSpace(string(output)))})
+		}
+		cancel()
+	}
+	if identity.Env == "" {
+		return findings
+	}
+	for _, pod := range pods {
+		if pod.Namespace != sa.Namespace || pod.Spec.ServiceAccountName != sa.Name || hasEnv(pod, identity.Env) {
+			continue
+		}
+		findings = append(findings, models.Finding{Resource: ref, Reason: identity.Provider + "Webhook", Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("pod %s/%s of service account %s has no %s, the %s identity webhook did not inject the identity, restart the pod", pod.Namespace, pod.Name, sa.Name, identity.Env, identity.Provider)})
+		break
+	}
+	return findings
+}
+
+func hasEnv(pod v1.Pod, name string) bool {
+	for _, container := range pod.Spec.Containers {
+		for _, env := range container.Env {
+			if env.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// legacyTokenFindings reports secrets holding long-lived service account tokens, they never expire
+func legacyTokenFindings(clientset *kubernetes.Clientset, pods []v1.Pod) []models.Finding {
+	findings := []models.Finding{}
+	secrets, err := clientset.CoreV1().Secrets("").List(context.Background(), metav1.ListOptions{FieldSelector: "type=" + string(v1.SecretTypeServiceAccountToken)})
+	if err != nil {
+		return findings
+	}
+	mounted := make(map[string]bool)
+	for _, pod := range pods {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.Secret != nil {
+				mounted[pod.Namespace+"/"+volume.Secret.SecretName] = true
+			}
+		}
+	}
+	for _, secret := range secrets.Items {
+		if _, invalid := secret.Labels[legacyTokenInvalidSince]; invalid {
+			continue
+		}
+		message := fmt.Sprintf("secret %s/%s holds a long-lived token of service account %s that never expires", secret.Namespace, secret.Name, secret.Annotations[v1.ServiceAccountNameKey])
+		if lastUsed, found := secret.Labels[legacyTokenLastUsed]; found {
+			message += fmt.Sprintf(", last used %s", lastUsed)
+		}
+		if mounted[secret.Namespace+"/"+secret.Name] {
+			message += ", it is mounted by running pods"
+		}
+		findings = append(findings, models.Finding{Resource: models.ResourceRef{Kind: "Secret", Namespace: secret.Namespace, Name: secret.Name},
+			Reason: "LegacyToken", Severity: models.SeverityWarning, Message: message + ", use TokenRequest or projected tokens instead"})
+	}
+	return findings
+}
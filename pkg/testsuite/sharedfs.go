@@ -0,0 +1,198 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultProbeImage = "busybox:1.36"
+	// probeTimeout bounds every mount probe, a stale NFS handle blocks the probe instead of failing it
+	probeTimeout = 20
+)
+
+// sharedFilesystemDrivers are the CSI drivers of shared filesystems that are probed like NFS volumes
+var sharedFilesystemDrivers = []string{
+	"nfs.csi.k8s.io",
+	"efs.csi.aws.com",
+	"filestore.csi.storage.gke.io",
+	"file.csi.azure.com",
+	"cephfs.csi.ceph.com",
+}
+
+// volumeMount is a running pod that mounts a claim and can be used to probe it
+type volumeMount struct {
+	Pod       v1.Pod
+	Container string
+	Path      string
+	ReadOnly  bool
+}
+
+func CheckSharedFilesystems(clientset *kubernetes.Clientset) models.ResourceCheck {
+	if mode := probesDisabled(); mode != "" {
+		return skippedInMode("Shared Filesystems", mode)
+	}
+	pvs, err := clientset.CoreV1().PersistentVolumes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Shared Filesystems", Details: "Error fetching persistent volumes", Error: err.Error()}
+	}
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{FieldSelector: "status.phase=Running"})
+	if err != nil {
+		return models.ResourceCheck{Label: "Shared Filesystems", Details: "Error fetching pods", Error: err.Error()}
+	}
+	mounts := claimMounts(pods.Items)
+
+	kc := &k8s.K8sClient{Client: clientset}
+	findings := []models.Finding{}
+	probed := 0
+	for _, pv := range pvs.Items {
+		if !isSharedFilesystem(pv) || pv.Status.Phase != v1.VolumeBound || pv.Spec.ClaimRef == nil {
+			continue
+		}
+		ref := models.ResourceRef{Kind: "PersistentVolume", Name: pv.Name}
+		claim := pv.Spec.ClaimRef.Namespace + "/" + pv.Spec.ClaimRef.Name
+		mount, found := mounts[claim]
+		if !found {
+			if !settings.SharedFilesystems.LaunchProbe || !hasAccessMode(pv, v1.ReadWriteMany) {
+				continue
+			}
+			probed++
+			if err := launchMountProbe(kc, pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name); err != nil {
+				findings = append(findings, models.Finding{Resource: ref, Reason: "Mount", Severity: models.SeverityCritical,
+					Message: fmt.Sprintf("probe pod for claim %s failed: %v", claim, err)})
+			}
+			continue
+		}
+		probed++
+		if err := probeMount(kc, mount); err != nil {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Mount", Severity: models.SeverityCritical,
+				Message: fmt.Sprintf("claim %s is not usable at %s in pod %s/%s: %v", claim, mount.Path, mount.Pod.Namespace, mount.Pod.Name, err)})
+		}
+	}
+
+	if probed == 0 {
+		return models.ResourceCheck{Label: "Shared Filesystems", Details: "No mounted NFS or shared filesystem volumes found.", Status: true}
+	}
+	details := fmt.Sprintf("%d shared filesystem volumes are mountable and writable.", probed)
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d of %d shared filesystem volumes failed the mount probe.", len(findings), probed)
+	}
+	return models.ResourceCheck{Label: "Shared Filesystems", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+func isSharedFilesystem(pv v1.PersistentVolume) bool {
+	if pv.Spec.NFS != nil {
+		return true
+	}
+	if pv.Spec.CSI == nil {
+		return false
+	}
+	for _, driver := range append(sharedFilesystemDrivers, settings.SharedFilesystems.Drivers...) {
+		if pv.Spec.CSI.Driver == driver {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAccessMode(pv v1.PersistentVolume, mode v1.PersistentVolumeAccessMode) bool {
+	for _, accessMode := range pv.Spec.AccessModes {
+		if accessMode == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// claimMounts maps namespace/claim to a running pod that mounts it, writable mounts are preferred
+func claimMounts(pods []v1.Pod) map[string]volumeMount {
+	mounts := make(map[string]volumeMount)
+	for _, pod := range pods {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim == nil {
+				continue
+			}
+			claim := pod.Namespace + "/" + volume.PersistentVolumeClaim.ClaimName
+			for _, container := range pod.Spec.Containers {
+				for _, vm := range container.VolumeMounts {
+					if vm.Name != volume.Name {
+						continue
+					}
+					existing, found := mounts[claim]
+					if !found || (existing.ReadOnly && !vm.ReadOnly) {
+						mounts[claim] = volumeMount{Pod: pod, Container: container.Name, Path: vm.MountPath, ReadOnly: vm.ReadOnly}
+					}
+				}
+			}
+		}
+	}
+	return mounts
+}
+
+// probeScript touches, stats and deletes a file in the directory, or only lists it when it is mounted read only
+func probeScript(path string, readOnly bool) string {
+	if readOnly {
+		return fmt.Sprintf("timeout %d ls %s >/dev/null", probeTimeout, path)
+	}
+	file := fmt.Sprintf("%s/.healthctl-probe-%d", strings.TrimSuffix(path, "/"), time.Now().UnixNano())
+	return fmt.Sprintf("timeout %d sh -c 'touch %s && stat %s >/dev/null && rm %s'", probeTimeout, file, file, file)
+}
+
+func probeMount(kc *k8s.K8sClient, mount volumeMount) error {
+	stdout, stderr, err := kc.ExecuteRemoteCommand(mount.Pod.Namespace, mount.Pod.Name, mount.Container, probeScript(mount.Path, mount.ReadOnly))
+	if err != nil {
+		return fmt.Errorf("%v %s", err, strings.TrimSpace(stderr+stdout))
+	}
+	return nil
+}
+
+// launchMountProbe starts a short lived pod that mounts the claim, probes it and deletes the pod again
+func launchMountProbe(kc *k8s.K8sClient, namespace, claim string) error {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "healthctl-mount-probe-",
+			Labels:       map[string]string{"app.kubernetes.io/managed-by": "healthctl"},
+		},
+		Spec: v1.PodSpec{
+			RestartPolicy: v1.RestartPolicyNever,
+			Containers: []v1.Container{{
+				Name:         "probe",
+				Image:        valueOr(settings.SharedFilesystems.ProbeImage, defaultProbeImage),
+				Command:      []string{"sleep", "300"},
+				VolumeMounts: []v1.VolumeMount{{Name: "volume", MountPath: "/probe"}},
+			}},
+			Volumes: []v1.Volume{{
+				Name:         "volume",
+				VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claim}},
+			}},
+		},
+	}
+	pods := kc.Client.CoreV1().Pods(namespace)
+	created, err := pods.Create(context.Background(), pod, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	defer pods.Delete(context.Background(), created.Name, metav1.DeleteOptions{})
+
+	err = wait.PollUntilContextTimeout(context.Background(), 2*time.Second, 60*time.Second, true, func(ctx context.Context) (bool, error) {
+		current, err := pods.Get(ctx, created.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return current.Status.Phase == v1.PodRunning, nil
+	})
+	if err != nil {
+		return fmt.Errorf("volume was not mounted within 60s: %v", err)
+	}
+	return probeMount(kc, volumeMount{Pod: *created, Container: "probe", Path: "/probe"})
+}
@@ -11,10 +11,14 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
+// smfChecks are the checks of the smf suite
+var smfChecks = []Check{
+	{Label: "Pods", Run: CheckPods},
+	{Label: "SMF Monitor", Run: CheckSMFMonitor},
+}
+
 func CheckSMF(clientset *kubernetes.Clientset) []models.ResourceCheck {
-	checks := []models.ResourceCheck{}
-	checks = append(checks, CheckPods(clientset)...)
-	checks = append(checks, CheckSMFMonitor(clientset)...)
+	checks := RunChecks(clientset, smfChecks)
 	return checks
 }
 
@@ -26,7 +30,7 @@ func CheckPods(clientset *kubernetes.Clientset) []models.ResourceCheck {
 		return append([]models.ResourceCheck{}, models.ResourceCheck{
 			Label:   "Pods",
 			Details: "Failed to list pods in SMF namespace",
-			Status:  false,
+			Error:   err.Error(),
 		})
 	}
 
@@ -72,7 +76,14 @@ func CheckSMFMonitor(clientset *kubernetes.Clientset) []models.ResourceCheck {
 	pods, err := clientset.CoreV1().Pods("fed-smf").List(ctx, metav1.ListOptions{
 		LabelSelector: "app=smfmonitor-app",
 	})
-	if err != nil || len(pods.Items) == 0 {
+	if err != nil {
+		return append([]models.ResourceCheck{}, models.ResourceCheck{
+			Label:   "SMF Monitor",
+			Details: "Failed to find smf-monitor pod",
+			Error:   err.Error(),
+		})
+	}
+	if len(pods.Items) == 0 {
 		return append([]models.ResourceCheck{}, models.ResourceCheck{
 			Label:   "SMF Monitor",
 			Details: "Failed to find smf-monitor pod",
@@ -0,0 +1,169 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// spotNodeLabels are the labels cloud providers and karpenter put on spot and preemptible nodes
+var spotNodeLabels = map[string]string{
+	"eks.amazonaws.com/capacityType":        "SPOT",
+	"karpenter.sh/capacity-type":            "spot",
+	"cloud.google.com/gke-spot":             "true",
+	"cloud.google.com/gke-preemptible":      "true",
+	"kubernetes.azure.com/scalesetpriority": "spot",
+}
+
+func checkSpotRisk(clientset *kubernetes.Clientset) models.ResourceCheck {
+	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return models.ResourceCheck{Label: "Spot Risk", Details: "Error fetching nodes", Error: err.Error()}
+	}
+	spotNodes := make(map[string]bool)
+	for _, node := range nodes.Items {
+		if isSpotNode(node) {
+			spotNodes[node.Name] = true
+		}
+	}
+	if len(spotNodes) == 0 {
+		return models.ResourceCheck{Label: "Spot Risk", Details: "No spot or preemptible nodes found.", Status: true}
+	}
+
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{
+		FieldSelector: "status.phase=Running",
+	})
+	if err != nil {
+		return models.ResourceCheck{Label: "Spot Risk", Details: "Error fetching pods", Error: err.Error()}
+	}
+
+	// count the running pods of every workload and how many of them run on spot nodes
+	owners := workloadOwners(clientset)
+	total := make(map[models.ResourceRef]int)
+	onSpot := make(map[models.ResourceRef]int)
+	for _, pod := range pods.Items {
+		workload, found := podWorkload(pod, owners)
+		if !found || workload.Kind == "DaemonSet" {
+			continue
+		}
+		total[workload]++
+		if spotNodes[pod.Spec.NodeName] {
+			onSpot[workload]++
+		}
+	}
+
+	findings := []models.Finding{}
+	for workload, count := range total {
+		if onSpot[workload] == count {
+			findings = append(findings, models.Finding{
+				Resource: workload,
+				Severity: models.SeverityWarning,
+				Message:  fmt.Sprintf("All %d replicas of %s run on spot nodes, it goes fully down on a mass preemption", count, workload),
+			})
+		}
+	}
+	findings = append(findings, spotOnlyServices(clientset, pods.Items, spotNodes)...)
+	sort.Slice(findings, func(i, j int) bool { return findings[i].Resource.String() < findings[j].Resource.String() })
+
+	details := fmt.Sprintf("%d spot nodes, every workload has replicas on regular nodes.", len(spotNodes))
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d spot nodes, %d workloads and services run only on spot nodes.", len(spotNodes), len(findings))
+	}
+	return models.ResourceCheck{Label: "Spot Risk", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+func isSpotNode(node v1.Node) bool {
+	for key, value := range spotNodeLabels {
+		if strings.EqualFold(node.Labels[key], value) {
+			return true
+		}
+	}
+	for key, value := range settings.Spot.NodeLabels {
+		if node.Labels[key] == value {
+			return true
+		}
+	}
+	for _, taint := range node.Spec.Taints {
+		if _, found := spotNodeLabels[taint.Key]; found {
+			return true
+		}
+		if taint.Key == settings.Spot.Taint && settings.Spot.Taint != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// workloadOwners maps every ReplicaSet to the Deployment that owns it
+func workloadOwners(clientset *kubernetes.Clientset) map[string]string {
+	owners := make(map[string]string)
+	replicasets, err := clientset.AppsV1().ReplicaSets("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return owners
+	}
+	for _, rs := range replicasets.Items {
+		for _, owner := range rs.OwnerReferences {
+			if owner.Kind == "Deployment" {
+				owners[rs.Namespace+"/"+rs.Name] = owner.Name
+			}
+		}
+	}
+	return owners
+}
+
+// podWorkload returns the Deployment, StatefulSet, DaemonSet or other controller that owns the pod
+func podWorkload(pod v1.Pod, deployments map[string]string) (models.ResourceRef, bool) {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller == nil || !*owner.Controller {
+			continue
+		}
+		if owner.Kind == "ReplicaSet" {
+			if deployment, found := deployments[pod.Namespace+"/"+owner.Name]; found {
+				return models.ResourceRef{Kind: "Deployment", Namespace: pod.Namespace, Name: deployment}, true
+			}
+		}
+		return models.ResourceRef{Kind: owner.Kind, Namespace: pod.Namespace, Name: owner.Name}, true
+	}
+	return models.ResourceRef{}, false
+}
+
+// spotOnlyServices returns the services whose running backends are all on spot nodes
+func spotOnlyServices(clientset *kubernetes.Clientset, pods []v1.Pod, spotNodes map[string]bool) []models.Finding {
+	findings := []models.Finding{}
+	services, err := clientset.CoreV1().Services("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return findings
+	}
+	for _, service := range services.Items {
+		if len(service.Spec.Selector) == 0 {
+			continue
+		}
+		selector := labels.SelectorFromSet(service.Spec.Selector)
+		backends, spot := 0, 0
+		for _, pod := range pods {
+			if pod.Namespace != service.Namespace || !selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			backends++
+			if spotNodes[pod.Spec.NodeName] {
+				spot++
+			}
+		}
+		if backends > 0 && spot == backends {
+			findings = append(findings, models.Finding{
+				Resource: models.ResourceRef{Kind: "Service", Namespace: service.Namespace, Name: service.Name},
+				Severity: models.SeverityWarning,
+				Message:  fmt.Sprintf("All %d backends of service %s/%s run on spot nodes, the service goes down on a mass preemption", backends, service.Namespace, service.Name),
+			})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,126 @@
+package testsuite
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/lifecycle"
+	"healthctl/pkg/models"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultStartupWindow  = 24 * time.Hour
+	defaultDegradedFactor = 2
+	defaultStartupMinPods = 3
+)
+
+// StartupFile is the startup history of the workloads of the current cluster, the Pod Startup check records
+// every ready pod
+func StartupFile() string {
+	return config.ClusterStatePath(k8s.CurrentCluster(), "startup.json")
+}
+
+// StartupSummaries returns the startup distribution of every workload in the store, a workload is
+// degraded when it starts slower within the configured window than before it
+func StartupSummaries(store lifecycle.Store, now time.Time) []lifecycle.Summary {
+	window := defaultStartupWindow
+	if parsed, err := time.ParseDuration(settings.Startup.Window); err == nil && parsed > 0 {
+		window = parsed
+	}
+	factor := settings.Startup.DegradedFactor
+	if factor <= 1 {
+		factor = defaultDegradedFactor
+	}
+	minPods := settings.Startup.MinPods
+	if minPods <= 0 {
+		minPods = defaultStartupMinPods
+	}
+	summaries := []lifecycle.Summary{}
+	for workload, startups := range store {
+		summaries = append(summaries, lifecycle.Summarize(workload, startups, now.Add(-window), factor, minPods))
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Workload < summaries[j].Workload })
+	return summaries
+}
+
+// checkPodStartup records how long the ready pods of every workload took to start, from their conditions
+// and image pull events, and reports workloads whose startup degraded compared with their history
+func checkPodStartup(clientset *kubernetes.Clientset) models.ResourceCheck {
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{FieldSelector: "status.phase=Running"})
+	if err != nil {
+		return models.ResourceCheck{Label: "Pod Startup", Details: "Error fetching pods", Error: err.Error()}
+	}
+	events := []v1.Event{}
+	for _, reason := range []string{"Pulling", "Pulled"} {
+		list, err := clientset.CoreV1().Events("").List(context.Background(), metav1.ListOptions{
+			FieldSelector: "involvedObject.kind=Pod,reason=" + reason,
+		})
+		if err != nil {
+			return models.ResourceCheck{Label: "Pod Startup", Details: "Error fetching events", Error: err.Error()}
+		}
+		events = append(events, list.Items...)
+	}
+
+	file := StartupFile()
+	store, err := lifecycle.Load(file)
+	if err != nil {
+		return models.ResourceCheck{Label: "Pod Startup", Details: fmt.Sprintf("Error reading startup history: %v", err), Error: err.Error()}
+	}
+	now := time.Now()
+	owners := workloadOwners(clientset)
+	recorded := 0
+	for _, pod := range pods.Items {
+		workload, found := podWorkload(pod, owners)
+		if !found {
+			// a bare pod has no history to compare with
+			continue
+		}
+		if startup, ok := lifecycle.Measure(pod, events); ok && store.Record(workload.String(), startup, now) {
+			recorded++
+		}
+	}
+	if err := store.Save(file); err != nil {
+		return models.ResourceCheck{Label: "Pod Startup", Details: fmt.Sprintf("Error saving startup history: %v", err), Error: err.Error()}
+	}
+
+	findings := []models.Finding{}
+	for _, summary := range StartupSummaries(store, now) {
+		if !summary.Degraded {
+			continue
+		}
+		kind, namespace, name := splitWorkload(summary.Workload)
+		findings = append(findings, models.Finding{
+			Resource: models.ResourceRef{Kind: kind, Namespace: namespace, Name: name},
+			Reason:   "Degraded",
+			Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("pods of %s take %s to become ready, %.1fx the %s before (p50 scheduling %s, image pull %s, readiness %s)",
+				summary.Workload, summary.Recent.Round(time.Second), float64(summary.Recent)/float64(summary.Baseline),
+				summary.Baseline.Round(time.Second), summary.Scheduling.P50.Round(time.Second), summary.ImagePull.P50.Round(time.Second),
+				summary.Readiness.P50.Round(time.Second)),
+		})
+	}
+
+	details := fmt.Sprintf("%d new pod startups recorded for %d workloads, no startup degraded.", recorded, len(store))
+	if len(findings) > 0 {
+		details = fmt.Sprintf("The startup of %d workloads degraded.", len(findings))
+	}
+	return models.ResourceCheck{Label: "Pod Startup", Details: details, Status: len(findings) == 0, Findings: findings}
+}
+
+// splitWorkload splits a workload key like Deployment/payments/api
+func splitWorkload(workload string) (kind, namespace, name string) {
+	parts := strings.SplitN(workload, "/", 3)
+	if len(parts) != 3 {
+		return "Workload", "", workload
+	}
+	return parts[0], parts[1], parts[2]
+}
@@ -6,8 +6,16 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
+// storageChecks are the checks of the storage suite
+var storageChecks = []Check{
+	single("MinIO", CheckMinio),
+	single("Shared Filesystems", CheckSharedFilesystems),
+	single("Storage Classes", CheckStorageClasses),
+	single("Disaster Recovery", checkDisasterRecovery),
+}
+
 func CheckStorage(clientset *kubernetes.Clientset) []models.ResourceCheck {
-	checks := []models.ResourceCheck{}
+	checks := RunChecks(clientset, storageChecks)
 	// checks = append(checks, CheckPods(clientset)...)
 	// checks = append(checks, CheckSMFMonitor(clientset)...)
 	return checks
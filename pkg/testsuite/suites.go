@@ -0,0 +1,123 @@
+package testsuite
+
+import (
+	"fmt"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Suite is a named group of checks that can be run from the TUI or the command line
+type Suite struct {
+	Name string
+	Run  func(clientset *kubernetes.Clientset) []models.ResourceCheck
+	// Checks are the checks Run runs
+	Checks []Check
+}
+
+// settings are the check settings from the config file
+var settings config.Checks
+
+// Configure sets the check settings from the config file, it must be called before running suites
+func Configure(checks config.Checks) {
+	settings = checks
+}
+
+// Check is one check of a suite. A check can report several results, like one per deployment.
+type Check struct {
+	Label string
+	Run   func(clientset *kubernetes.Clientset) []models.ResourceCheck
+	// Sharded checks only list namespaced objects and keep no state, they can run once per shard of the
+	// namespaces and their results be merged. All other checks run once over the whole cluster.
+	Sharded bool
+}
+
+// single turns a check with one result into a Check
+func single(label string, run func(clientset *kubernetes.Clientset) models.ResourceCheck) Check {
+	return Check{Label: label, Run: func(clientset *kubernetes.Clientset) []models.ResourceCheck {
+		return []models.ResourceCheck{run(clientset)}
+	}}
+}
+
+// sharded turns a check of namespaced objects with one result into a Check that can run per shard
+func sharded(label string, run func(clientset *kubernetes.Clientset) models.ResourceCheck) Check {
+	check := single(label, run)
+	check.Sharded = true
+	return check
+}
+
+// RunChecks runs every check on its own, a check that panics is reported as an error
+// and does not stop the checks after it
+func RunChecks(clientset *kubernetes.Clientset, checks []Check) []models.ResourceCheck {
+	results := []models.ResourceCheck{}
+	for _, check := range checks {
+		results = append(results, runCheck(clientset, check)...)
+	}
+	return results
+}
+
+func runCheck(clientset *kubernetes.Clientset, check Check) (results []models.ResourceCheck) {
+	defer func() {
+		if r := recover(); r != nil {
+			results = []models.ResourceCheck{{
+				Label:   check.Label,
+				Details: fmt.Sprintf("%s check failed to run", check.Label),
+				Error:   fmt.Sprintf("panic: %v", r),
+			}}
+		}
+	}()
+	return check.Run(clientset)
+}
+
+// probesDisabled returns the mode that keeps checks from exec'ing into pods and starting probe pods, gentle or
+// read-only mode, or an empty string when they may
+func probesDisabled() string {
+	switch {
+	case k8s.Gentle():
+		return "gentle mode"
+	case k8s.ReadOnly():
+		return "read-only mode"
+	}
+	return ""
+}
+
+// skippedInMode is the result of checks that exec into pods or create objects, they do not run in gentle and
+// read-only mode
+func skippedInMode(label, mode string) models.ResourceCheck {
+	return models.ResourceCheck{Label: label, Details: fmt.Sprintf("Skipped in %s.", mode), Status: true, Skipped: mode}
+}
+
+var Suites = []Suite{
+	{Name: "k8s", Run: CheckK8s, Checks: k8sChecks},
+	{Name: "infra", Run: CheckINFRA, Checks: infraChecks},
+	{Name: "paas", Run: CheckPAAS, Checks: paasChecks},
+	{Name: "smf", Run: CheckSMF, Checks: smfChecks},
+	{Name: "upf", Run: CheckUPF},
+	{Name: "storage", Run: CheckStorage, Checks: storageChecks},
+	{Name: "network", Run: CheckNetwork, Checks: networkChecks},
+	{Name: "security", Run: CheckSecurity, Checks: securityChecks},
+}
+
+// CheckNames returns the names of all checks as suite/label, like the check of a finding
+func CheckNames() []string {
+	names := []string{}
+	for _, suite := range Suites {
+		for _, check := range suite.Checks {
+			names = append(names, suite.Name+"/"+check.Label)
+		}
+	}
+	return names
+}
+
+// GetSuite returns the suite with the given name
+func GetSuite(name string) (Suite, bool) {
+	for _, suite := range Suites {
+		if suite.Name == name {
+			return suite, true
+		}
+	}
+	return Suite{}, false
+}
@@ -0,0 +1,140 @@
+package testsuite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+	"healthctl/pkg/registry"
+	"healthctl/pkg/vulnerability"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	trivyReportsGroup  = "aquasecurity.github.io/v1alpha1"
+	defaultMaxCritical = 0
+	defaultMaxHigh     = 10
+)
+
+// vulnerabilityScanner returns the configured scanner, trivy-operator is used when its reports are served
+func vulnerabilityScanner(clientset *kubernetes.Clientset) (vulnerability.Scanner, string, error) {
+	config := settings.Vulnerabilities
+	switch config.Scanner {
+	case "harbor":
+		scanner, err := vulnerability.NewHarbor(config.Harbor.URL, config.Harbor.Username, config.Harbor.Password)
+		return scanner, "harbor", err
+	case "ecr":
+		return vulnerability.NewECR(), "ecr", nil
+	case "", "trivy-operator":
+		discovery, err := k8s.GetAPIResources(clientset)
+		if err != nil || !discovery.Serves(trivyReportsGroup) {
+			if config.Scanner == "" {
+				return nil, "", nil
+			}
+			return nil, "", fmt.Errorf("trivy-operator VulnerabilityReports are not served")
+		}
+		data, err := clientset.Discovery().RESTClient().Get().AbsPath("/apis/" + trivyReportsGroup + "/vulnerabilityreports").DoRaw(context.Background())
+		if err != nil {
+			return nil, "", err
+		}
+		scanner, err := vulnerability.NewTrivyOperator(data)
+		return scanner, "trivy-operator", err
+	}
+	return nil, "", fmt.Errorf("unknown vulnerability scanner %q, use trivy-operator, harbor or ecr", config.Scanner)
+}
+
+// checkVulnerabilities sums the critical and high CVEs of the images running in every namespace from an
+// existing scanner and reports namespaces above the thresholds
+func checkVulnerabilities(clientset *kubernetes.Clientset) models.ResourceCheck {
+	scanner, name, err := vulnerabilityScanner(clientset)
+	if err != nil {
+		return models.ResourceCheck{Label: "Vulnerabilities", Details: "Error reading the vulnerability scanner", Error: err.Error()}
+	}
+	if scanner == nil {
+		return models.ResourceCheck{Label: "Vulnerabilities", Details: "No vulnerability scanner configured and trivy-operator is not installed.", Status: true, Skipped: "no scanner"}
+	}
+	scanner = vulnerability.Cached(scanner)
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{FieldSelector: "status.phase=Running"})
+	if err != nil {
+		return models.ResourceCheck{Label: "Vulnerabilities", Details: "Error fetching pods", Error: err.Error()}
+	}
+
+	images := make(map[string]map[string]bool)
+	for _, pod := range pods.Items {
+		if images[pod.Namespace] == nil {
+			images[pod.Namespace] = make(map[string]bool)
+		}
+		for _, container := range append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+			images[pod.Namespace][container.Image] = true
+		}
+	}
+
+	maxCritical := defaultMaxCritical
+	if settings.Vulnerabilities.MaxCritical != nil {
+		maxCritical = *settings.Vulnerabilities.MaxCritical
+	}
+	maxHigh := defaultMaxHigh
+	if settings.Vulnerabilities.MaxHigh != nil {
+		maxHigh = *settings.Vulnerabilities.MaxHigh
+	}
+
+	findings := []models.Finding{}
+	scanned, unscanned, failed := 0, 0, 0
+	// scanErrors is the first error of every registry, one registry that is down must not hide the others
+	scanErrors := make(map[string]error)
+	total := vulnerability.Counts{}
+	for namespace, namespaceImages := range images {
+		counts := vulnerability.Counts{}
+		worst, worstCounts := "", vulnerability.Counts{}
+		for image := range namespaceImages {
+			imageCounts, err := scanner.Scan(image)
+			if errors.Is(err, vulnerability.ErrNotScanned) {
+				unscanned++
+				continue
+			}
+			if err != nil {
+				failed++
+				if host := registry.ParseReference(image).Registry; scanErrors[host] == nil {
+					scanErrors[host] = err
+				}
+				continue
+			}
+			scanned++
+			counts.Add(imageCounts)
+			if imageCounts.Critical > worstCounts.Critical || (imageCounts.Critical == worstCounts.Critical && imageCounts.High > worstCounts.High) {
+				worst, worstCounts = image, imageCounts
+			}
+		}
+		total.Add(counts)
+		ref := models.ResourceRef{Kind: "Namespace", Name: namespace}
+		worstImage := fmt.Sprintf("worst image %s with %d critical and %d high", worst, worstCounts.Critical, worstCounts.High)
+		if counts.Critical > maxCritical {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "Critical", Severity: models.SeverityCritical,
+				Message: fmt.Sprintf("Images in namespace %s have %d critical CVEs (allowed %d), %s", namespace, counts.Critical, maxCritical, worstImage)})
+		}
+		if counts.High > maxHigh {
+			findings = append(findings, models.Finding{Resource: ref, Reason: "High", Severity: models.SeverityWarning,
+				Message: fmt.Sprintf("Images in namespace %s have %d high CVEs (allowed %d), %s", namespace, counts.High, maxHigh, worstImage)})
+		}
+	}
+	for host, err := range scanErrors {
+		findings = append(findings, models.Finding{Resource: models.ResourceRef{Kind: "Registry", Name: host}, Reason: "ScanResults", Severity: models.SeverityWarning,
+			Message: fmt.Sprintf("scan results of the images of %s could not be read from %s: %v", host, name, err)})
+	}
+	sort.Slice(findings, func(i, j int) bool { return findings[i].Resource.String() < findings[j].Resource.String() })
+	if scanned == 0 && unscanned == 0 && failed > 0 {
+		return models.ResourceCheck{Label: "Vulnerabilities", Details: fmt.Sprintf("Error reading scan results from %s", name), Error: findings[0].Message, Findings: findings}
+	}
+
+	details := fmt.Sprintf("%s: %d critical and %d high CVEs in %d scanned images, %d images not scanned.", name, total.Critical, total.High, scanned, unscanned+failed)
+	if len(findings) > 0 {
+		details = fmt.Sprintf("%d namespaces above the CVE thresholds. %s", len(findings), details)
+	}
+	return models.ResourceCheck{Label: "Vulnerabilities", Details: details, Status: len(findings) == 0, Findings: findings}
+}
@@ -0,0 +1,79 @@
+package ticket
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"healthctl/pkg/config"
+)
+
+// jira opens issues through the Jira REST API v2, which cloud and data center share
+type jira struct {
+	cfg config.JiraTracker
+}
+
+func newJira(cfg config.JiraTracker) *jira {
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+	if cfg.IssueType == "" {
+		cfg.IssueType = "Task"
+	}
+	if cfg.CloseTransition == "" {
+		cfg.CloseTransition = "Done"
+	}
+	return &jira{cfg: cfg}
+}
+
+func (j *jira) auth(req *http.Request) {
+	if j.cfg.Username == "" {
+		req.Header.Set("Authorization", "Bearer "+j.cfg.Token)
+		return
+	}
+	req.SetBasicAuth(j.cfg.Username, j.cfg.Token)
+}
+
+func (j *jira) Open(summary, description string) (string, error) {
+	issue := map[string]any{
+		"fields": map[string]any{
+			"project":     map[string]string{"key": j.cfg.Project},
+			"issuetype":   map[string]string{"name": j.cfg.IssueType},
+			"summary":     summary,
+			"description": description,
+			"labels":      []string{"healthctl"},
+		},
+	}
+	created := struct {
+		Key string `json:"key"`
+	}{}
+	if err := request(http.MethodPost, j.cfg.URL+"/rest/api/2/issue", j.auth, issue, &created); err != nil {
+		return "", err
+	}
+	return created.Key, nil
+}
+
+func (j *jira) Comment(key, text string) error {
+	return request(http.MethodPost, fmt.Sprintf("%s/rest/api/2/issue/%s/comment", j.cfg.URL, key), j.auth, map[string]string{"body": text}, nil)
+}
+
+// Close comments the issue and moves it through the close transition of its workflow
+func (j *jira) Close(key, text string) error {
+	if err := j.Comment(key, text); err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/rest/api/2/issue/%s/transitions", j.cfg.URL, key)
+	transitions := struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"transitions"`
+	}{}
+	if err := request(http.MethodGet, url, j.auth, nil, &transitions); err != nil {
+		return err
+	}
+	for _, transition := range transitions.Transitions {
+		if strings.EqualFold(transition.Name, j.cfg.CloseTransition) {
+			return request(http.MethodPost, url, j.auth, map[string]any{"transition": map[string]string{"id": transition.ID}}, nil)
+		}
+	}
+	return fmt.Errorf("issue %s has no transition %q", key, j.cfg.CloseTransition)
+}
@@ -0,0 +1,64 @@
+package ticket
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"healthctl/pkg/config"
+)
+
+// serviceNowResolved is the resolved state of incidents
+const serviceNowResolved = "6"
+
+// serviceNow opens records through the ServiceNow table API, the key of a ticket is its sys_id
+type serviceNow struct {
+	cfg config.ServiceNowTracker
+}
+
+func newServiceNow(cfg config.ServiceNowTracker) *serviceNow {
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+	if cfg.Table == "" {
+		cfg.Table = "incident"
+	}
+	return &serviceNow{cfg: cfg}
+}
+
+func (s *serviceNow) auth(req *http.Request) {
+	req.SetBasicAuth(s.cfg.Username, s.cfg.Password)
+}
+
+func (s *serviceNow) Open(summary, description string) (string, error) {
+	record := map[string]string{
+		"short_description": summary,
+		"description":       description,
+	}
+	if s.cfg.AssignmentGroup != "" {
+		record["assignment_group"] = s.cfg.AssignmentGroup
+	}
+	created := struct {
+		Result struct {
+			SysID string `json:"sys_id"`
+		} `json:"result"`
+	}{}
+	if err := request(http.MethodPost, fmt.Sprintf("%s/api/now/table/%s", s.cfg.URL, s.cfg.Table), s.auth, record, &created); err != nil {
+		return "", err
+	}
+	return created.Result.SysID, nil
+}
+
+func (s *serviceNow) Comment(key, text string) error {
+	return s.patch(key, map[string]string{"work_notes": text})
+}
+
+func (s *serviceNow) Close(key, text string) error {
+	return s.patch(key, map[string]string{
+		"state":       serviceNowResolved,
+		"close_code":  "Resolved by caller",
+		"close_notes": text,
+	})
+}
+
+func (s *serviceNow) patch(key string, fields map[string]string) error {
+	return request(http.MethodPatch, fmt.Sprintf("%s/api/now/table/%s/%s", s.cfg.URL, s.cfg.Table, key), s.auth, fields, nil)
+}
@@ -0,0 +1,261 @@
+// Package ticket opens a ticket in Jira or ServiceNow for every new finding of healthctl serve, comments
+// it while the finding persists and closes it once the finding is resolved
+package ticket
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/maintenance"
+	"healthctl/pkg/models"
+)
+
+const (
+	defaultSummary     = "healthctl: {{.Finding.Message}} ({{.Finding.Resource}} on {{.Cluster}})"
+	defaultDescription = `healthctl reports a {{.Finding.Severity}} finding on cluster {{.Cluster}}.
+
+Check: {{.Finding.Check}}
+Resource: {{.Finding.Resource}}
+Message: {{.Finding.Message}}
+{{if .Finding.Hint}}Hint: {{.Finding.Hint}}
+{{end}}{{if .Finding.DocURL}}Runbook: {{.Finding.DocURL}}
+{{end}}Finding ID: {{.Finding.ID}}
+
+The ticket is closed by healthctl once the finding is no longer reported.`
+
+	defaultUpdateInterval = 24 * time.Hour
+)
+
+// Tracker is a ticket system
+type Tracker interface {
+	// Open creates a ticket and returns its key
+	Open(summary, description string) (string, error)
+	Comment(key, text string) error
+	Close(key, text string) error
+}
+
+// NewTracker returns the configured tracker, nil when tickets are not configured
+func NewTracker(cfg config.Tickets) (Tracker, error) {
+	switch {
+	case cfg.Jira != nil && cfg.ServiceNow != nil:
+		return nil, fmt.Errorf("tickets can be opened in either jira or serviceNow")
+	case cfg.Jira != nil:
+		return newJira(*cfg.Jira), nil
+	case cfg.ServiceNow != nil:
+		return newServiceNow(*cfg.ServiceNow), nil
+	}
+	return nil, nil
+}
+
+// Ticket is an open ticket of a finding
+type Ticket struct {
+	Key   string `json:"key"`
+	Check string `json:"check"`
+	// Resource is the object of the finding, tickets opened by earlier versions do not have it
+	Resource models.ResourceRef `json:"resource"`
+	Opened   time.Time          `json:"opened"`
+	Updated  time.Time          `json:"updated"`
+}
+
+// Syncer keeps the tickets of a cluster in line with its findings
+type Syncer struct {
+	Tracker        Tracker
+	Severity       models.Severity
+	UpdateInterval time.Duration
+	// Maintenance holds back opening, updating and closing the tickets of findings in an open window
+	Maintenance *maintenance.Calendar
+	summary     *template.Template
+	description *template.Template
+}
+
+// NewSyncer parses the templates and intervals of the ticket configuration
+func NewSyncer(tracker Tracker, cfg config.Tickets) (*Syncer, error) {
+	s := &Syncer{Tracker: tracker, Severity: models.SeverityCritical, UpdateInterval: defaultUpdateInterval}
+	if cfg.Severity != "" {
+		s.Severity = models.Severity(cfg.Severity)
+		if s.Severity != models.SeverityWarning && s.Severity != models.SeverityCritical {
+			return nil, fmt.Errorf("invalid tickets.severity %q, use warning or critical", cfg.Severity)
+		}
+	}
+	if cfg.UpdateInterval != "" {
+		interval, err := time.ParseDuration(cfg.UpdateInterval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tickets.updateInterval %q: %v", cfg.UpdateInterval, err)
+		}
+		s.UpdateInterval = interval
+	}
+	var err error
+	if s.summary, err = parseTemplate("summary", cfg.Summary, defaultSummary); err != nil {
+		return nil, err
+	}
+	if s.description, err = parseTemplate("description", cfg.Description, defaultDescription); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func parseTemplate(name, text, fallback string) (*template.Template, error) {
+	if text == "" {
+		text = fallback
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tickets.%s template: %v", name, err)
+	}
+	return tmpl, nil
+}
+
+// Sync opens a ticket for every new failing finding at or above the severity, comments the tickets of
+// findings that persist once per update interval and closes the tickets of findings that are no longer
+// reported. Tickets of checks that did not run are left alone, a failed check is not a resolution.
+// The tickets are remembered in the state file, so every finding gets one ticket.
+func (s *Syncer) Sync(cluster string, checks []models.CheckResult, result []models.Finding, file string, now time.Time) error {
+	tickets, err := load(file)
+	if err != nil {
+		return err
+	}
+	ran := make(map[string]bool)
+	for _, check := range checks {
+		ran[check.Check] = check.Result == models.ResultPass || check.Result == models.ResultFail
+	}
+
+	errs := []error{}
+	current := make(map[string]bool)
+	for _, finding := range result {
+		if !finding.Failing() || finding.Severity.Rank() < s.Severity.Rank() {
+			continue
+		}
+		current[finding.ID] = true
+		if s.Maintenance.Muted(cluster, finding, now) {
+			continue
+		}
+		ticket, found := tickets[finding.ID]
+		if !found {
+			key, err := s.open(cluster, finding)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("opening ticket for %s: %v", finding.ID, err))
+				continue
+			}
+			tickets[finding.ID] = Ticket{Key: key, Check: finding.Check, Resource: finding.Resource, Opened: now, Updated: now}
+			continue
+		}
+		if now.Sub(ticket.Updated) < s.UpdateInterval {
+			continue
+		}
+		text := fmt.Sprintf("healthctl still reports this finding on cluster %s, opened %s: %s",
+			cluster, ticket.Opened.Format(time.DateTime), finding.Message)
+		if err := s.Tracker.Comment(ticket.Key, text); err != nil {
+			errs = append(errs, fmt.Errorf("updating ticket %s: %v", ticket.Key, err))
+			continue
+		}
+		ticket.Updated = now
+		tickets[finding.ID] = ticket
+	}
+
+	for id, ticket := range tickets {
+		if current[id] || !ran[ticket.Check] {
+			continue
+		}
+		if s.Maintenance.Muted(cluster, models.Finding{ID: id, Check: ticket.Check, Resource: ticket.Resource}, now) {
+			continue
+		}
+		text := fmt.Sprintf("healthctl no longer reports this finding on cluster %s, it is resolved or suppressed.", cluster)
+		if err := s.Tracker.Close(ticket.Key, text); err != nil {
+			errs = append(errs, fmt.Errorf("closing ticket %s: %v", ticket.Key, err))
+			continue
+		}
+		delete(tickets, id)
+	}
+
+	if err := save(file, tickets); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Syncer) open(cluster string, finding models.Finding) (string, error) {
+	data := struct {
+		Cluster string
+		Finding models.Finding
+	}{cluster, finding}
+	var summary, description strings.Builder
+	if err := s.summary.Execute(&summary, data); err != nil {
+		return "", err
+	}
+	if err := s.description.Execute(&description, data); err != nil {
+		return "", err
+	}
+	return s.Tracker.Open(summary.String(), description.String())
+}
+
+func load(file string) (map[string]Ticket, error) {
+	tickets := make(map[string]Ticket)
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return tickets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &tickets); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", file, err)
+	}
+	return tickets, nil
+}
+
+func save(file string, tickets map[string]Ticket) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(tickets, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// request sends a json request to a ticket system and decodes the json response into out when set
+func request(method, url string, auth func(req *http.Request), body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	auth(req)
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s returned %s: %s", method, url, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,62 @@
+package tsdb
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// lineProtocol writes a line per point with the labels as tags, the value as the field value and the
+// time in nanoseconds
+func lineProtocol(points []Point) []byte {
+	buf := bytes.Buffer{}
+	for _, point := range points {
+		buf.WriteString(measurementEscaper.Replace(point.Name))
+		for _, key := range slices.Sorted(maps.Keys(point.Labels)) {
+			// influx rejects empty tag values
+			if point.Labels[key] == "" {
+				continue
+			}
+			fmt.Fprintf(&buf, ",%s=%s", tagEscaper.Replace(key), tagEscaper.Replace(point.Labels[key]))
+		}
+		fmt.Fprintf(&buf, " value=%s %d\n", strconv.FormatFloat(point.Value, 'g', -1, 64), point.Time.UnixNano())
+	}
+	return buf.Bytes()
+}
+
+// pushInflux writes the points to the v2 write API when a bucket is configured and to the v1 write API
+// of a database otherwise
+func (e *Exporter) pushInflux(points []Point) error {
+	cfg := e.cfg.Influx
+	query := url.Values{"precision": {"ns"}}
+	path := "/write"
+	if cfg.Bucket != "" {
+		path = "/api/v2/write"
+		query.Set("org", cfg.Org)
+		query.Set("bucket", cfg.Bucket)
+	} else {
+		query.Set("db", cfg.Database)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(cfg.URL, "/")+path+"?"+query.Encode(), bytes.NewReader(lineProtocol(points)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if cfg.Token != "" {
+		req.Header.Set("Authorization", "Token "+cfg.Token)
+	} else if cfg.Username != "" {
+		req.SetBasicAuth(cfg.Username, cfg.Password)
+	}
+	return e.send(req)
+}
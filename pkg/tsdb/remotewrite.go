@@ -0,0 +1,97 @@
+package tsdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// writeRequest encodes the points as a prometheus.WriteRequest with a time series per point:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+//
+// Labels are sorted by name as remote write receivers require.
+func writeRequest(points []Point) []byte {
+	request := []byte{}
+	for _, point := range points {
+		labels := [][2]string{{"__name__", point.Name}}
+		for name, value := range point.Labels {
+			if value != "" {
+				labels = append(labels, [2]string{name, value})
+			}
+		}
+		slices.SortFunc(labels, func(a, b [2]string) int { return strings.Compare(a[0], b[0]) })
+
+		series := []byte{}
+		for _, label := range labels {
+			encoded := protowire.AppendTag(nil, 1, protowire.BytesType)
+			encoded = protowire.AppendString(encoded, label[0])
+			encoded = protowire.AppendTag(encoded, 2, protowire.BytesType)
+			encoded = protowire.AppendString(encoded, label[1])
+			series = protowire.AppendTag(series, 1, protowire.BytesType)
+			series = protowire.AppendBytes(series, encoded)
+		}
+		sample := protowire.AppendTag(nil, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(point.Value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(point.Time.UnixMilli()))
+		series = protowire.AppendTag(series, 2, protowire.BytesType)
+		series = protowire.AppendBytes(series, sample)
+
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, series)
+	}
+	return request
+}
+
+// snappyBlock compresses nothing: it frames the data as literals of the snappy block format, which every
+// snappy decoder reads. Write requests are a few kilobytes, not worth a compression dependency.
+func snappyBlock(data []byte) []byte {
+	block := binary.AppendUvarint(nil, uint64(len(data)))
+	for len(data) > 0 {
+		chunk := data[:min(len(data), 1<<16)]
+		data = data[len(chunk):]
+		// literal tags store the length minus one, in the tag for up to 60 bytes and in one or two bytes after it
+		n := len(chunk) - 1
+		switch {
+		case n < 60:
+			block = append(block, byte(n)<<2)
+		case n < 1<<8:
+			block = append(block, 60<<2, byte(n))
+		default:
+			block = append(block, 61<<2, byte(n), byte(n>>8))
+		}
+		block = append(block, chunk...)
+	}
+	return block
+}
+
+// pushRemoteWrite sends the points to a Prometheus remote write receiver such as VictoriaMetrics,
+// Mimir, Thanos or Prometheus itself
+func (e *Exporter) pushRemoteWrite(points []Point) error {
+	cfg := e.cfg.RemoteWrite
+	req, err := http.NewRequest(http.MethodPost, cfg.URL, bytes.NewReader(snappyBlock(writeRequest(points))))
+	if err != nil {
+		return err
+	}
+	for key, value := range cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if cfg.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.BearerToken)
+	} else if cfg.Username != "" {
+		req.SetBasicAuth(cfg.Username, cfg.Password)
+	}
+	return e.send(req)
+}
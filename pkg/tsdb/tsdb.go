@@ -0,0 +1,157 @@
+// Package tsdb pushes the check statuses and usage metrics of every run to time series databases, as
+// InfluxDB line protocol or Prometheus remote write, for organizations that do not scrape healthctl
+package tsdb
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"strings"
+	"time"
+
+	"healthctl/pkg/config"
+	"healthctl/pkg/k8s"
+	"healthctl/pkg/models"
+	"healthctl/pkg/report"
+)
+
+// Point is a sample of a series
+type Point struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+	Time   time.Time
+}
+
+// Points returns the series of a report at the time it was generated: the health score of the cluster
+// and its namespaces, whether every check passed or could not run, the failing findings by severity and,
+// with a usage report, the cpu and memory used and requested per namespace
+func Points(r report.Report, usage *k8s.ResourceUsageReport) []Point {
+	points := []Point{}
+	add := func(name string, value float64, labels ...string) {
+		point := Point{Name: name, Labels: map[string]string{"cluster": r.Cluster}, Value: value, Time: r.Generated}
+		for i := 0; i+1 < len(labels); i += 2 {
+			point.Labels[labels[i]] = labels[i+1]
+		}
+		points = append(points, point)
+	}
+
+	add("healthctl_health_score", float64(r.Scorecard.Cluster.Score))
+	for _, namespace := range r.Scorecard.Namespaces {
+		add("healthctl_namespace_health_score", float64(namespace.Score), "namespace", namespace.Name)
+	}
+	for _, check := range r.Checks {
+		if check.Result == models.ResultSkipped {
+			continue
+		}
+		add("healthctl_check_passed", boolValue(check.Result == models.ResultPass), "check", check.Check)
+		add("healthctl_check_error", boolValue(check.Result == models.ResultError), "check", check.Check)
+	}
+	failing := map[models.Severity]int{models.SeverityCritical: 0, models.SeverityWarning: 0}
+	for _, finding := range r.Findings {
+		if finding.Failing() {
+			failing[finding.Severity]++
+		}
+	}
+	for _, severity := range []models.Severity{models.SeverityCritical, models.SeverityWarning} {
+		add("healthctl_findings", float64(failing[severity]), "severity", string(severity))
+	}
+
+	if usage == nil {
+		return points
+	}
+	for namespace, used := range usage.Namespaces() {
+		add("healthctl_namespace_cpu_requested_millicores", float64(used.CPURequested), "namespace", namespace)
+		add("healthctl_namespace_memory_requested_bytes", float64(used.MemoryRequested), "namespace", namespace)
+		if usage.MetricsAvailable {
+			add("healthctl_namespace_cpu_used_millicores", float64(used.CPUUsed), "namespace", namespace)
+			add("healthctl_namespace_memory_used_bytes", float64(used.MemoryUsed), "namespace", namespace)
+		}
+	}
+	return points
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Exporter pushes points to the database of an exporter configuration
+type Exporter struct {
+	cfg    config.Exporter
+	client *http.Client
+}
+
+// New returns the exporter of the configuration
+func New(cfg config.Exporter) *Exporter {
+	return &Exporter{cfg: cfg, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// FromConfig returns the exporters of the configuration
+func FromConfig(cfg *config.Config) []*Exporter {
+	exporters := []*Exporter{}
+	for _, exporterConfig := range cfg.Exporters {
+		exporters = append(exporters, New(exporterConfig))
+	}
+	return exporters
+}
+
+// Push sends the points with the labels of the exporter
+func (e *Exporter) Push(points []Point) error {
+	if len(e.cfg.Labels) > 0 {
+		labeled := make([]Point, len(points))
+		for i, point := range points {
+			point.Labels = maps.Clone(point.Labels)
+			for key, value := range e.cfg.Labels {
+				if _, set := point.Labels[key]; !set {
+					point.Labels[key] = value
+				}
+			}
+			labeled[i] = point
+		}
+		points = labeled
+	}
+	switch {
+	case e.cfg.Influx != nil:
+		return e.pushInflux(points)
+	case e.cfg.RemoteWrite != nil:
+		return e.pushRemoteWrite(points)
+	}
+	return fmt.Errorf("exporter has neither influx nor remoteWrite")
+}
+
+// Push sends the series of the report to every exporter and returns the errors of all exporters that failed
+func Push(exporters []*Exporter, r report.Report, usage *k8s.ResourceUsageReport) error {
+	if len(exporters) == 0 {
+		return nil
+	}
+	points := Points(r, usage)
+	failed := []string{}
+	for _, exporter := range exporters {
+		if err := exporter.Push(points); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("exporting metrics: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// send sends a write request and returns an error with the response body when it is not accepted
+func (e *Exporter) send(req *http.Request) error {
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		// the query is left out, it may hold credentials
+		return fmt.Errorf("%s %s://%s%s returned %s: %s", req.Method, req.URL.Scheme, req.URL.Host, req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
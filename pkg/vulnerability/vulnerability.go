@@ -0,0 +1,214 @@
+package vulnerability
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"healthctl/pkg/registry"
+)
+
+// Counts are the vulnerabilities of an image by severity
+type Counts struct {
+	Critical int `json:"critical"`
+	High     int `json:"high"`
+}
+
+// Add sums the counts of several images
+func (c *Counts) Add(other Counts) {
+	c.Critical += other.Critical
+	c.High += other.High
+}
+
+// ErrNotScanned is returned for images the scanner has no results for
+var ErrNotScanned = fmt.Errorf("image was not scanned")
+
+// Scanner returns the scan results of an image from an existing vulnerability scanner
+type Scanner interface {
+	Scan(image string) (Counts, error)
+}
+
+// cached wraps a scanner so every image is only looked up once
+type cached struct {
+	scanner Scanner
+	lock    sync.Mutex
+	results map[string]Counts
+	errors  map[string]error
+}
+
+func (c *cached) Scan(image string) (Counts, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if counts, found := c.results[image]; found {
+		return counts, c.errors[image]
+	}
+	counts, err := c.scanner.Scan(image)
+	c.results[image] = counts
+	c.errors[image] = err
+	return counts, err
+}
+
+// Cached returns a scanner that remembers the results of every image
+func Cached(scanner Scanner) Scanner {
+	return &cached{scanner: scanner, results: map[string]Counts{}, errors: map[string]error{}}
+}
+
+// trivyOperator looks images up in the VulnerabilityReports of trivy-operator
+type trivyOperator map[string]Counts
+
+// NewTrivyOperator reads a list of aquasecurity.github.io VulnerabilityReports
+func NewTrivyOperator(reports []byte) (Scanner, error) {
+	list := struct {
+		Items []struct {
+			Report struct {
+				Registry struct {
+					Server string `json:"server"`
+				} `json:"registry"`
+				Artifact struct {
+					Repository string `json:"repository"`
+					Tag        string `json:"tag"`
+					Digest     string `json:"digest"`
+				} `json:"artifact"`
+				Summary struct {
+					CriticalCount int `json:"criticalCount"`
+					HighCount     int `json:"highCount"`
+				} `json:"summary"`
+			} `json:"report"`
+		} `json:"items"`
+	}{}
+	if err := json.Unmarshal(reports, &list); err != nil {
+		return nil, fmt.Errorf("parsing vulnerability reports: %v", err)
+	}
+	scanner := trivyOperator{}
+	for _, item := range list.Items {
+		report := item.Report
+		counts := Counts{Critical: report.Summary.CriticalCount, High: report.Summary.HighCount}
+		// trivy-operator names Docker Hub index.docker.io, ParseReference normalizes it like the images of the pods
+		name := report.Registry.Server + "/" + report.Artifact.Repository
+		for _, image := range []string{name + ":" + report.Artifact.Tag, name + "@" + report.Artifact.Digest} {
+			if strings.HasSuffix(image, ":") || strings.HasSuffix(image, "@") {
+				continue
+			}
+			scanner[key(registry.ParseReference(image))] = counts
+		}
+	}
+	return scanner, nil
+}
+
+func (t trivyOperator) Scan(image string) (Counts, error) {
+	if counts, found := t[key(registry.ParseReference(image))]; found {
+		return counts, nil
+	}
+	return Counts{}, ErrNotScanned
+}
+
+func key(ref registry.Reference) string {
+	return ref.Registry + "/" + ref.Repository + "@" + ref.Reference
+}
+
+// harbor reads the scan overview of artifacts from the Harbor API
+type harbor struct {
+	url      *url.URL
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewHarbor returns a scanner for the images in the Harbor registry at url
+func NewHarbor(harborURL, username, password string) (Scanner, error) {
+	parsed, err := url.Parse(harborURL)
+	if err != nil {
+		return nil, err
+	}
+	return &harbor{url: parsed, username: username, password: password, client: &http.Client{Timeout: 15 * time.Second}}, nil
+}
+
+func (h *harbor) Scan(image string) (Counts, error) {
+	ref := registry.ParseReference(image)
+	project, repository, found := strings.Cut(ref.Repository, "/")
+	if ref.Registry != registry.NormalizeRegistry(h.url.Host) || !found {
+		return Counts{}, ErrNotScanned
+	}
+	// harbor expects slashes in repository names to be encoded twice
+	path := fmt.Sprintf("/api/v2.0/projects/%s/repositories/%s/artifacts/%s?with_scan_overview=true",
+		url.PathEscape(project), url.PathEscape(url.PathEscape(repository)), url.PathEscape(ref.Reference))
+	request, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(h.url.String(), "/")+path, nil)
+	if err != nil {
+		return Counts{}, err
+	}
+	if h.username != "" {
+		request.SetBasicAuth(h.username, h.password)
+	}
+	response, err := h.client.Do(request)
+	if err != nil {
+		return Counts{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return Counts{}, ErrNotScanned
+	}
+	if response.StatusCode != http.StatusOK {
+		return Counts{}, fmt.Errorf("harbor returned %s for %s", response.Status, image)
+	}
+	artifact := struct {
+		ScanOverview map[string]struct {
+			ScanStatus string `json:"scan_status"`
+			Summary    struct {
+				Summary map[string]int `json:"summary"`
+			} `json:"summary"`
+		} `json:"scan_overview"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&artifact); err != nil {
+		return Counts{}, err
+	}
+	for _, overview := range artifact.ScanOverview {
+		if overview.ScanStatus != "Success" {
+			continue
+		}
+		return Counts{Critical: overview.Summary.Summary["Critical"], High: overview.Summary.Summary["High"]}, nil
+	}
+	return Counts{}, ErrNotScanned
+}
+
+// ecr reads the image scan findings of ECR with the aws CLI, which must be installed and logged in
+type ecr struct{}
+
+// NewECR returns a scanner for images in ECR registries
+func NewECR() Scanner {
+	return ecr{}
+}
+
+func (ecr) Scan(image string) (Counts, error) {
+	ref := registry.ParseReference(image)
+	// <account>.dkr.ecr.<region>.amazonaws.com
+	parts := strings.Split(ref.Registry, ".")
+	if len(parts) < 6 || parts[1] != "dkr" || parts[2] != "ecr" {
+		return Counts{}, ErrNotScanned
+	}
+	imageID := "imageTag=" + ref.Reference
+	if strings.HasPrefix(ref.Reference, "sha256:") {
+		imageID = "imageDigest=" + ref.Reference
+	}
+	output, err := exec.Command("aws", "ecr", "describe-image-scan-findings", "--output", "json",
+		"--registry-id", parts[0], "--region", parts[3], "--repository-name", ref.Repository, "--image-id", imageID).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && strings.Contains(string(exitErr.Stderr), "ScanNotFoundException") {
+			return Counts{}, ErrNotScanned
+		}
+		return Counts{}, fmt.Errorf("aws ecr describe-image-scan-findings: %v", err)
+	}
+	result := struct {
+		ImageScanFindings struct {
+			FindingSeverityCounts map[string]int `json:"findingSeverityCounts"`
+		} `json:"imageScanFindings"`
+	}{}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return Counts{}, err
+	}
+	return Counts{Critical: result.ImageScanFindings.FindingSeverityCounts["CRITICAL"], High: result.ImageScanFindings.FindingSeverityCounts["HIGH"]}, nil
+}
@@ -0,0 +1,211 @@
+// healthctl dashboard, everything is loaded from the REST API of healthctl serve
+"use strict";
+
+const refreshInterval = 15000;
+let cluster = "";
+
+async function api(path, options) {
+  let response = await fetch(path, withToken(options));
+  if (response.status === 401) {
+    const token = prompt("Token for the healthctl API, a static token or an OIDC ID token");
+    if (token) {
+      localStorage.setItem("healthctl-token", token);
+      response = await fetch(path, withToken(options));
+    }
+  }
+  if (!response.ok) {
+    throw new Error(`${path}: ${response.status} ${await response.text()}`);
+  }
+  return response.status === 204 || response.status === 202 ? null : response.json();
+}
+
+function withToken(options) {
+  const token = localStorage.getItem("healthctl-token");
+  if (!token) {
+    return options;
+  }
+  return { ...options, headers: { ...(options || {}).headers, Authorization: `Bearer ${token}` } };
+}
+
+function query(path) {
+  return `${path}?cluster=${encodeURIComponent(cluster)}`;
+}
+
+function element(tag, attributes, ...children) {
+  const node = document.createElement(tag);
+  Object.entries(attributes || {}).forEach(([key, value]) => node.setAttribute(key, value));
+  children.forEach(child => node.append(child));
+  return node;
+}
+
+async function loadClusters() {
+  const selector = document.getElementById("selector").value;
+  const clusters = await api(`/api/v1/clusters?selector=${encodeURIComponent(selector)}`);
+  if (!clusters.some(c => c.name === cluster)) {
+    cluster = clusters.length > 0 ? clusters[0].name : "";
+  }
+  const nav = document.getElementById("clusters");
+  nav.replaceChildren(...clusters.map(c => {
+    let label = c.latest ? `${c.name} (${c.latest.score})` : c.name;
+    if (c.stale) {
+      label += " stale";
+    }
+    const classes = [c.name === cluster ? "active" : "", c.stale ? "stale" : ""].join(" ");
+    const button = element("button", { class: classes, title: c.agent ? "reported by an agent" : "" }, label);
+    button.onclick = () => { cluster = c.name; refresh(); };
+    return button;
+  }));
+}
+
+function drawHistory(runs) {
+  const svg = document.getElementById("history");
+  svg.replaceChildren();
+  if (runs.length < 2) {
+    return;
+  }
+  const maxFindings = Math.max(1, ...runs.map(run => Math.max(run.critical, run.warning)));
+  const x = i => (i / (runs.length - 1)) * 800;
+  const line = (name, value, max) => {
+    const points = runs.map((run, i) => `${x(i)},${160 - (value(run) / max) * 150}`).join(" ");
+    const polyline = document.createElementNS("http://www.w3.org/2000/svg", "polyline");
+    polyline.setAttribute("class", name);
+    polyline.setAttribute("points", points);
+    svg.append(polyline);
+  };
+  line("score", run => run.score, 100);
+  line("critical", run => run.critical, maxFindings);
+  line("warning", run => run.warning, maxFindings);
+}
+
+function drawGrid(checks) {
+  document.getElementById("grid").replaceChildren(...checks.map(check =>
+    element("div", { class: `check ${check.result}`, title: check.cause || check.details || "" }, check.check)));
+}
+
+function drawFindings(findings) {
+  const showAll = document.getElementById("show-all").checked;
+  const rows = findings
+    .filter(f => showAll || (!f.suppressed && f.severity !== "info"))
+    .map(f => {
+      const state = f.suppressed ? "suppressed" : f.acknowledgement ? "acknowledged" : "";
+      const message = element("td", {}, f.message);
+      if (f.hint) {
+        message.append(element("div", { class: "hint" }, f.hint));
+      }
+      const actions = element("td");
+      if (f.suppressed) {
+        actions.append(`suppressed: ${f.suppressionReason}`);
+      } else {
+        actions.append(button(f.acknowledgement ? "Reassign" : "Acknowledge", () => acknowledge(f)));
+        if (f.acknowledgement) {
+          actions.append(button("Reopen", () => reopen(f)));
+        }
+        actions.append(button("Suppress", () => suppress(f)));
+      }
+      return element("tr", { class: state },
+        element("td", { class: f.severity }, f.severity),
+        element("td", { title: f.check }, resourceText(f.resource)),
+        message,
+        element("td", {}, f.team || ""),
+        element("td", {}, new Date(f.firstSeen).toLocaleString()),
+        element("td", { title: f.acknowledgement ? f.acknowledgement.comment || "" : "" }, stateText(f)),
+        actions);
+    });
+  document.querySelector("#findings tbody").replaceChildren(...rows);
+}
+
+function stateText(f) {
+  const ack = f.acknowledgement;
+  if (!ack || f.state === "suppressed") {
+    return f.state || "";
+  }
+  return `${f.state} by ${ack.by}` + (ack.assignee ? `, assigned to ${ack.assignee}` : "");
+}
+
+function resourceText(resource) {
+  return resource.namespace ? `${resource.kind}/${resource.namespace}/${resource.name}` : `${resource.kind}/${resource.name}`;
+}
+
+function drawResolved(records) {
+  const rows = records.map(r => element("tr", {},
+    element("td", { class: r.severity }, r.severity),
+    element("td", { title: r.check }, resourceText(r.resource)),
+    element("td", {}, r.message),
+    element("td", {}, new Date(r.opened).toLocaleString()),
+    element("td", {}, new Date(r.resolved).toLocaleString()),
+    element("td", {}, r.acknowledgement ? r.acknowledgement.assignee || r.acknowledgement.by : "")));
+  document.querySelector("#resolved tbody").replaceChildren(...rows);
+}
+
+function button(label, action) {
+  const node = element("button", {}, label);
+  node.onclick = () => action().then(refresh).catch(error => alert(error.message));
+  return node;
+}
+
+function user() {
+  let name = localStorage.getItem("healthctl-user");
+  if (!name) {
+    name = prompt("Your name, recorded with acknowledgements and suppressions");
+    if (name) {
+      localStorage.setItem("healthctl-user", name);
+    }
+  }
+  return name;
+}
+
+async function acknowledge(finding) {
+  const by = user();
+  if (!by) {
+    return;
+  }
+  const current = finding.acknowledgement || {};
+  const assignee = prompt("Assignee", current.assignee || by);
+  if (assignee === null) {
+    return;
+  }
+  const comment = prompt("Comment", current.comment || "") || "";
+  await api(query("/api/v1/acknowledge"), { method: "POST", body: JSON.stringify({ id: finding.id, by, assignee, comment }) });
+}
+
+async function reopen(finding) {
+  const by = user();
+  if (by) {
+    await api(query("/api/v1/reopen"), { method: "POST", body: JSON.stringify({ id: finding.id, by }) });
+  }
+}
+
+async function suppress(finding) {
+  const by = user();
+  const reason = by && prompt(`Reason to suppress ${finding.message}`);
+  if (!reason) {
+    return;
+  }
+  const expires = prompt("Expires (YYYY-MM-DD), empty to never expire") || "";
+  await api(query("/api/v1/suppress"), { method: "POST", body: JSON.stringify({ id: finding.id, by, reason, expires }) });
+}
+
+async function refresh() {
+  try {
+    await loadClusters();
+    if (!cluster) {
+      return;
+    }
+    drawHistory(await api(query("/api/v1/history")));
+    const report = await api(query("/api/v1/report"));
+    document.getElementById("labels").textContent = Object.entries(report.labels || {})
+      .map(([key, value]) => `${key}=${value}`).sort().join(", ");
+    drawGrid(report.checks);
+    drawFindings(report.findings);
+    drawResolved(await api(query("/api/v1/findings") + "&state=resolved"));
+    document.getElementById("updated").textContent = `checked ${new Date(report.generated).toLocaleString()}`;
+  } catch (error) {
+    document.getElementById("updated").textContent = error.message;
+  }
+}
+
+document.getElementById("run").onclick = () => api(query("/api/v1/run"), { method: "POST" }).catch(error => alert(error.message));
+document.getElementById("show-all").onchange = refresh;
+document.getElementById("selector").onchange = refresh;
+refresh();
+setInterval(refresh, refreshInterval);
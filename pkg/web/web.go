@@ -0,0 +1,21 @@
+// Package web embeds the dashboard of healthctl serve, a static page built on the REST API
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed assets
+var assets embed.FS
+
+// Handler serves the dashboard assets
+func Handler() http.Handler {
+	root, err := fs.Sub(assets, "assets")
+	if err != nil {
+		// the embedded directory always exists
+		panic(err)
+	}
+	return http.FileServer(http.FS(root))
+}